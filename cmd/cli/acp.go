@@ -72,7 +72,7 @@ func runACP(ctx context.Context, args []string) error {
 		return fmt.Errorf("unknown arguments: %v", fs.Args())
 	}
 
-	credentials, err := loadOrInitCredentialStore(initialAppName, credentialStoreModeAuto)
+	credentials, err := loadOrInitCredentialStore(initialAppName, configStore.CredentialStoreMode())
 	if err != nil {
 		return err
 	}
@@ -84,6 +84,9 @@ func runACP(ctx context.Context, args []string) error {
 	if err != nil {
 		return err
 	}
+	for _, warn := range configStore.LoadSubagentProfiles(resolvedWorkspaceRoot) {
+		fmt.Fprintf(os.Stderr, "warn: %v\n", warn)
+	}
 	_ = skillsDirs
 	skillDirList := activeSkillDirs()
 
@@ -96,6 +99,9 @@ func runACP(ctx context.Context, args []string) error {
 		strings.TrimSpace(*sandboxType),
 		sandboxHelperPath,
 		configStore.SandboxPolicy(),
+		configStore.BashLimits(),
+		configStore.BashPersistentShell(),
+		configStore.SSHWorkspace(resolvedWorkspaceRoot),
 	)
 	if err != nil {
 		return err
@@ -122,6 +128,7 @@ func runACP(ctx context.Context, args []string) error {
 	sessionModes := []internalacp.SessionMode{
 		{ID: "default", Name: "Default", Description: "Normal coding mode with execution enabled."},
 		{ID: "plan", Name: "Plan", Description: "Planning-first mode that focuses on analysis before making changes."},
+		{ID: "staged", Name: "Staged", Description: "Stage file writes and sandboxed commands for review before they touch the workspace."},
 		{ID: "full_access", Name: "Full Access", Description: "Execute changes directly without interactive approval, while still blocking dangerous destructive commands."},
 	}
 	sessionConfig := buildACPSessionConfigOptions(sessionModes, factory, configStore, alias)
@@ -154,6 +161,7 @@ func runACP(ctx context.Context, args []string) error {
 		WorkspaceCWD:         workspace.CWD,
 		ClientRuntime:        baseRuntime,
 		ResolveAgentRegistry: configStore.AgentRegistry,
+		SubagentBudget:       configStore.SubagentBudget(),
 		NewAdapter: func(conn *internalacp.Conn) (internalacp.Adapter, error) {
 			if newACPAdapter == nil {
 				return nil, fmt.Errorf("self acp adapter is not initialized")
@@ -242,15 +250,25 @@ func runACP(ctx context.Context, args []string) error {
 					}(),
 				})
 			},
-			NewSessionResources: func(ctx context.Context, acpConn *internalacp.Conn, sessionID string, sessionCWD string, caps internalacp.ClientCapabilities, modeResolver func() string) (*internalacp.SessionResources, error) {
+			NewSessionResources: func(ctx context.Context, acpConn *internalacp.Conn, sessionID string, sessionCWD string, caps internalacp.ClientCapabilities, mcpServers []internalacp.MCPServer, modeResolver func() string) (*internalacp.SessionResources, error) {
 				execRuntime := internalacp.NewRuntime(baseRuntime, acpConn, sessionID, resolvedWorkspaceRoot, sessionCWD, caps, modeResolver)
+				sessionMCPServers, err := resolveSessionMCPServers(configStore.MCPServerConfigs(), mcpServers)
+				if err != nil {
+					return nil, err
+				}
+				sessionRules, err := loadPermissionRules(configStore, sessionCWD)
+				if err != nil {
+					return nil, err
+				}
 				registry := plugin.NewRegistry()
 				if err := appassembly.RegisterBuiltinProviders(registry, appassembly.RegisterOptions{
 					ExecutionRuntime: execRuntime,
+					MCPServers:       sessionMCPServers,
+					PermissionRules:  sessionRules,
 				}); err != nil {
 					return nil, err
 				}
-				resolvedToolProviders := splitCSV(*toolProviders)
+				resolvedToolProviders := withMCPToolProvider(splitCSV(*toolProviders), sessionMCPServers)
 				if *experimentalLSP {
 					resolvedToolProviders = appendProviderIfMissing(resolvedToolProviders, providerLSPTools)
 				}
@@ -294,9 +312,10 @@ func runACP(ctx context.Context, args []string) error {
 			Title:   "caelis",
 			Version: version.String(),
 		},
-		AuthMethods:  authMethods,
-		Authenticate: authValidator,
-		Adapter:      adapter,
+		AuthMethods:    authMethods,
+		Authenticate:   authValidator,
+		Adapter:        adapter,
+		ApprovalGrants: configStore.ApprovalGrantStore(),
 	})
 	if err != nil {
 		return err
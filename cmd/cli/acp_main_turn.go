@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/url"
 	"strings"
 	"sync"
 	"time"
@@ -813,6 +814,29 @@ func marshalMainACPContentParts(parts []model.ContentPart) []json.RawMessage {
 				Data:     part.Data,
 				Name:     strings.TrimSpace(part.FileName),
 			}))
+		case model.ContentPartAudio:
+			if strings.TrimSpace(part.Data) == "" {
+				continue
+			}
+			out = append(out, mustMarshalMainACPContent(acpclient.AudioContent{
+				Type:     "audio",
+				MimeType: strings.TrimSpace(part.MimeType),
+				Data:     part.Data,
+			}))
+		case model.ContentPartDocument:
+			if strings.TrimSpace(part.Data) == "" {
+				continue
+			}
+			name := strings.TrimSpace(part.FileName)
+			out = append(out, mustMarshalMainACPContent(acpclient.EmbeddedResource{
+				Type: "resource",
+				Resource: acpclient.EmbeddedResourceData{
+					URI:      "attachment:///" + url.PathEscape(name),
+					Name:     name,
+					Blob:     part.Data,
+					MimeType: strings.TrimSpace(part.MimeType),
+				},
+			}))
 		}
 	}
 	return out
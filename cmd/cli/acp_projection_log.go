@@ -532,7 +532,7 @@ func subagentReplayMessage(ev acpProjectionPersistedEvent) (any, bool) {
 		return replayProjectionMsgFromEvent(ev, tuievents.ACPProjectionSubagent), true
 	case "status":
 		state := strings.ToLower(strings.TrimSpace(ev.Status))
-		if state == "completed" || state == "failed" || state == "interrupted" || state == "timed_out" {
+		if state == "completed" || state == "failed" || state == "interrupted" || state == "timed_out" || state == "terminated" {
 			return tuievents.SubagentDoneMsg{
 				SpawnID:    strings.TrimSpace(ev.ScopeID),
 				State:      state,
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	appassembly "github.com/OnslaughtSnail/caelis/internal/app/assembly"
+	appbootstrap "github.com/OnslaughtSnail/caelis/internal/app/bootstrap"
+	"github.com/OnslaughtSnail/caelis/internal/app/httpapi"
+	"github.com/OnslaughtSnail/caelis/internal/cli/webui"
+	"github.com/OnslaughtSnail/caelis/internal/version"
+	"github.com/OnslaughtSnail/caelis/kernel/agent"
+	toolexec "github.com/OnslaughtSnail/caelis/kernel/execenv"
+	"github.com/OnslaughtSnail/caelis/kernel/model"
+	modelproviders "github.com/OnslaughtSnail/caelis/kernel/model/providers"
+	"github.com/OnslaughtSnail/caelis/kernel/plugin"
+	"github.com/OnslaughtSnail/caelis/kernel/session"
+)
+
+const (
+	defaultAPIAddr = "127.0.0.1:7878"
+	defaultWebAddr = "127.0.0.1:7879"
+)
+
+// httpFrontend selects what runHTTPFrontend serves on top of the shared
+// session setup.
+type httpFrontend string
+
+const (
+	httpFrontendAPI httpFrontend = "api"
+	httpFrontendWeb httpFrontend = "web"
+)
+
+func runAPI(ctx context.Context, args []string) error {
+	return runHTTPFrontend(ctx, args, httpFrontendAPI)
+}
+
+func runWeb(ctx context.Context, args []string) error {
+	return runHTTPFrontend(ctx, args, httpFrontendWeb)
+}
+
+func runHTTPFrontend(ctx context.Context, args []string, frontend httpFrontend) error {
+	if ctx == nil {
+		return fmt.Errorf("cli: context is required")
+	}
+	initialAppName := appNameFromArgs(args, "caelis")
+	configStore, err := loadOrInitAppConfig(initialAppName)
+	if err != nil {
+		return err
+	}
+	defaultStoreDir, err := sessionStoreDir(initialAppName)
+	if err != nil {
+		return err
+	}
+	defaultSessionIndexPath, err := sessionIndexPath(initialAppName)
+	if err != nil {
+		return err
+	}
+
+	defaultAddr := defaultAPIAddr
+	if frontend == httpFrontendWeb {
+		defaultAddr = defaultWebAddr
+	}
+	fs := flag.NewFlagSet(string(frontend), flag.ContinueOnError)
+	var (
+		addr             = fs.String("addr", defaultAddr, "HTTP listen address")
+		authTokenEnv     = fs.String("auth-token-env", "", "Env var containing the bearer token clients must send; a random token is generated and printed when unset")
+		toolProviders    = fs.String("tool-providers", appassembly.ProviderWorkspaceTools+","+appassembly.ProviderShellTools, "Comma-separated tool providers")
+		policyProviders  = fs.String("policy-providers", appassembly.ProviderDefaultPolicy, "Comma-separated policy providers")
+		modelAlias       = fs.String("model", configStore.DefaultModel(), "Model alias")
+		appName          = fs.String("app", initialAppName, "App name")
+		userID           = fs.String("user", "local-user", "User id")
+		storeDir         = fs.String("store-dir", defaultStoreDir, "Local event store directory")
+		sessionIndexFile = fs.String("session-index", defaultSessionIndexPath, "Session index sqlite file path")
+		systemPrompt     = fs.String("system-prompt", "", "Base system prompt")
+		compactWatermark = fs.Float64("compact-watermark", 0.7, "Auto compaction watermark ratio (0.5-0.9)")
+		contextWindow    = fs.Int("context-window", 0, "Model context window tokens override")
+		permissionMode   = fs.String("permission-mode", configStore.PermissionMode(), "Permission mode: default|full_control")
+		sandboxType      = fs.String("sandbox-type", configStore.SandboxType(), "Sandbox backend type when permission-mode=default (Linux auto tries bwrap then landlock)")
+		experimentalLSP  = fs.Bool("experimental-lsp", false, "Enable experimental CLI LSP tools plugin")
+		showVersion      = fs.Bool("version", false, "Show version and exit")
+	)
+	if err := rejectRemovedExecutionFlags(args); err != nil {
+		return err
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *showVersion {
+		fmt.Println(version.String())
+		return nil
+	}
+	if len(fs.Args()) > 0 {
+		return fmt.Errorf("unknown arguments: %v", fs.Args())
+	}
+	authToken, err := resolveAPIAuthToken(strings.TrimSpace(*authTokenEnv))
+	if err != nil {
+		return err
+	}
+
+	credentials, err := loadOrInitCredentialStore(initialAppName, configStore.CredentialStoreMode())
+	if err != nil {
+		return err
+	}
+	workspace, err := resolveWorkspaceContext()
+	if err != nil {
+		return err
+	}
+	resolvedWorkspaceRoot, err := resolveWorkspaceRoot(workspace.CWD, "")
+	if err != nil {
+		return err
+	}
+	for _, warn := range configStore.LoadSubagentProfiles(resolvedWorkspaceRoot) {
+		fmt.Fprintf(os.Stderr, "warn: %v\n", warn)
+	}
+	skillDirList := activeSkillDirs()
+
+	sandboxHelperPath, err := resolveSandboxHelperPath()
+	if err != nil {
+		return err
+	}
+	execRuntime, err := newExecutionRuntime(
+		toolexec.PermissionMode(strings.TrimSpace(*permissionMode)),
+		strings.TrimSpace(*sandboxType),
+		sandboxHelperPath,
+		configStore.SandboxPolicy(),
+		configStore.BashLimits(),
+		configStore.BashPersistentShell(),
+		configStore.SSHWorkspace(workspace.CWD),
+	)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := toolexec.Close(execRuntime); closeErr != nil {
+			fmt.Fprintf(os.Stderr, "warn: close execution runtime failed: %v\n", closeErr)
+		}
+	}()
+	if execRuntime.FallbackToHost() {
+		fmt.Fprintf(os.Stderr, "warn: sandbox unavailable, fallback to host+approval: %s\n", execRuntime.FallbackReason())
+	}
+	pluginRegistry := plugin.NewRegistry()
+	mcpServers := configStore.MCPServerConfigs()
+	permissionRules, err := loadPermissionRules(configStore, workspace.CWD)
+	if err != nil {
+		return err
+	}
+	if err := appassembly.RegisterBuiltinProviders(pluginRegistry, appassembly.RegisterOptions{
+		ExecutionRuntime: execRuntime,
+		MCPServers:       mcpServers,
+		PermissionRules:  permissionRules,
+	}); err != nil {
+		return err
+	}
+	resolvedToolProviders := withMCPToolProvider(splitCSV(*toolProviders), mcpServers)
+	if *experimentalLSP {
+		resolvedToolProviders = appendProviderIfMissing(resolvedToolProviders, providerLSPTools)
+	}
+	if includesProvider(resolvedToolProviders, providerLSPTools) {
+		if err := registerCLILSPToolProvider(pluginRegistry, workspace.CWD, execRuntime); err != nil {
+			return err
+		}
+	}
+	resolved, err := appassembly.Assemble(ctx, appassembly.AssembleSpec{
+		Registry:        pluginRegistry,
+		ToolProviders:   resolvedToolProviders,
+		PolicyProviders: splitCSV(*policyProviders),
+	})
+	if err != nil {
+		return err
+	}
+
+	factory := buildModelFactory(configStore, credentials)
+	alias := resolveModelAliasFromConfig(*modelAlias, configStore)
+	if alias == "" {
+		return fmt.Errorf("no model configured, run /connect first or pass -model with a configured provider/model")
+	}
+	modelRuntime := configStore.ModelRuntimeSettings(alias)
+	if _, err := factory.NewByAlias(alias); err != nil {
+		return err
+	}
+
+	sessionRT, err := setupSessionRuntime(ctx, *storeDir, workspace.Key, *appName, *userID, *sessionIndexFile, *compactWatermark, workspace)
+	if err != nil {
+		return err
+	}
+	index := sessionRT.Index
+	defer func() {
+		if closeErr := index.Close(); closeErr != nil {
+			fmt.Fprintf(os.Stderr, "warn: close session index failed: %v\n", closeErr)
+		}
+		if sessionRT.DB != nil {
+			if closeErr := sessionRT.DB.Close(); closeErr != nil {
+				fmt.Fprintf(os.Stderr, "warn: close local store db failed: %v\n", closeErr)
+			}
+		}
+	}()
+	serviceSet, err := appbootstrap.Build(appbootstrap.Config{
+		Runtime:      sessionRT.Runtime,
+		Store:        sessionRT.Store,
+		AppName:      *appName,
+		UserID:       *userID,
+		DefaultAgent: configStore.DefaultAgent(),
+		WorkspaceCWD: workspace.CWD,
+		Execution:    execRuntime,
+		Tools:        resolved.Tools,
+		Policies:     resolved.Policies,
+		Resolved:     resolved,
+		EnablePlan:   true,
+		Index:        &cliSessionIndexAdapter{index: index},
+	})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := serviceSet.Close(context.WithoutCancel(ctx)); closeErr != nil {
+			fmt.Fprintf(os.Stderr, "warn: close assembled providers failed: %v\n", closeErr)
+		}
+	}()
+
+	var decorateEvent func(*session.Event) map[string]any
+	if frontend == httpFrontendWeb {
+		decorateEvent = webui.DiffDecorator(execRuntime)
+	}
+	listener, err := net.Listen("tcp", strings.TrimSpace(*addr))
+	if err != nil {
+		return fmt.Errorf("%s: listen %s: %w", frontend, strings.TrimSpace(*addr), err)
+	}
+	defer listener.Close()
+	server, err := httpapi.New(httpapi.Config{
+		Addr:                listener.Addr().String(),
+		Service:             serviceSet.SessionService,
+		Gateway:             serviceSet.Gateway,
+		AppName:             *appName,
+		UserID:              *userID,
+		WorkspaceKey:        workspace.Key,
+		WorkspaceCWD:        workspace.CWD,
+		ContextWindowTokens: *contextWindow,
+		AuthToken:           authToken,
+		DecorateEvent:       decorateEvent,
+		ApprovalGrants:      configStore.ApprovalGrantStore(),
+		NewTurn: func(context.Context, string) (agent.Agent, model.LLM, error) {
+			llm, err := factory.NewByAlias(alias)
+			if err != nil {
+				return nil, nil, err
+			}
+			ag, err := buildAgent(buildAgentInput{
+				AppName:                     *appName,
+				PromptRole:                  promptRoleMainSession,
+				WorkspaceDir:                workspace.CWD,
+				EnableExperimentalLSPPrompt: hasLSPTools(resolved.Tools),
+				BasePrompt:                  *systemPrompt,
+				SkillDirs:                   skillDirList,
+				DefaultAgent:                configStore.DefaultAgent(),
+				AgentDescriptors:            configStore.AgentDescriptors(),
+				StreamModel:                 true,
+				ThinkingBudget:              modelRuntime.ThinkingBudget,
+				ReasoningEffort:             modelRuntime.ReasoningEffort,
+				ModelProvider:               resolveProviderName(factory, alias),
+				ModelName:                   resolveModelName(factory, alias),
+				ModelConfig: func() modelproviders.Config {
+					cfg, _ := factory.ConfigForAlias(alias)
+					return cfg
+				}(),
+				WorkspaceRoot: resolvedWorkspaceRoot,
+			})
+			if err != nil {
+				return nil, nil, err
+			}
+			return ag, llm, nil
+		},
+	})
+	if err != nil {
+		return err
+	}
+	var handler http.Handler = server
+	if frontend == httpFrontendWeb {
+		handler, err = webui.New(webui.Config{
+			API:          server,
+			Sessions:     sessionRT.MainScope,
+			AuthToken:    server.AuthToken(),
+			EmbedToken:   authToken == "",
+			Addr:         listener.Addr().String(),
+			WorkspaceCWD: workspace.CWD,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if authToken == "" {
+		fmt.Fprintf(os.Stderr, "caelis %s auth token: %s\n", frontend, server.AuthToken())
+	}
+	fmt.Fprintf(os.Stderr, "caelis %s listening on http://%s (workspace %s)\n", frontend, listener.Addr(), workspace.CWD)
+	return serveAPI(ctx, listener, handler)
+}
+
+// serveAPI runs the HTTP server until ctx ends or the process is interrupted.
+func serveAPI(ctx context.Context, listener net.Listener, handler http.Handler) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	srv := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.Serve(listener)
+	}()
+	select {
+	case err := <-errCh:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	return srv.Shutdown(shutdownCtx)
+}
+
+func resolveAPIAuthToken(envName string) (string, error) {
+	if envName == "" {
+		return "", nil
+	}
+	token := strings.TrimSpace(os.Getenv(envName))
+	if token == "" {
+		return "", fmt.Errorf("api: auth token env %q is empty", envName)
+	}
+	return token, nil
+}
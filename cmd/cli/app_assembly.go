@@ -43,6 +43,8 @@ type buildAgentInput struct {
 	WorkspaceRoot               string
 	ExecutionRuntime            toolexec.Runtime
 	AppVersion                  string
+	// Output requests structured output from the main agent.
+	Output *model.OutputSpec
 }
 
 const (
@@ -70,6 +72,7 @@ func buildResolvedLLMAgent(in buildAgentInput, systemPrompt string) (*llmagent.A
 		StreamModel:       in.StreamModel,
 		Reasoning:         reasoning,
 		EmitPartialEvents: in.StreamModel,
+		Output:            in.Output,
 	})
 }
 
@@ -202,9 +205,20 @@ func buildRuntimePromptHint(execRuntime toolexec.Runtime) string {
 		"## Runtime Execution",
 		"- Informational runtime hints; higher-priority instructions may override.",
 	}
+	if remote := execRuntime.State().Remote; remote != nil {
+		lines = append(lines, fmt.Sprintf("- workspace=remote target=%s workdir=%s", remote.Target, remote.Workdir))
+		lines = append(lines, "- Rule: BASH commands and file tools run on the remote machine over SSH; use its paths, not local ones.")
+		if execRuntime.PermissionMode() != toolexec.PermissionModeFullControl {
+			lines = append(lines, "- Rule: remote commands require approval, except safe inspection commands; file tool writes stay inside the remote workdir.")
+		}
+		return strings.Join(lines, "\n")
+	}
 	if policyHint := runtimePolicyHint(execRuntime.SandboxPolicy()); policyHint != "" {
 		lines = append(lines, "- "+policyHint)
 	}
+	if execRuntime.PermissionMode() != toolexec.PermissionModeFullControl && execRuntime.SandboxPolicy().NetworkProxied() {
+		lines = append(lines, "- Network: sandboxed commands reach only network_allowlist hosts through an HTTP(S) proxy set in the environment. A denied host is offered to the user for approval after the command; rerun it once approved instead of escalating.")
+	}
 	switch execRuntime.PermissionMode() {
 	case toolexec.PermissionModeFullControl:
 		lines = append(lines, "- permission_mode=full_control route=host")
@@ -232,10 +246,13 @@ func runtimePolicyHint(policy toolexec.SandboxPolicy) string {
 		return ""
 	}
 	network := "off"
-	if policy.NetworkAccess {
+	switch {
+	case policy.NetworkAccess:
 		network = "on"
+	case policy.NetworkProxied():
+		network = "allowlist"
 	}
-	return fmt.Sprintf(
+	hint := fmt.Sprintf(
 		"sandbox_policy=%s network=%s readable_roots=%s writable_roots=%s read_only_subpaths=%s",
 		policyType,
 		network,
@@ -243,6 +260,10 @@ func runtimePolicyHint(policy toolexec.SandboxPolicy) string {
 		csvOrDash(policy.WritableRoots),
 		csvOrDash(policy.ReadOnlySubpaths),
 	)
+	if policy.NetworkProxied() {
+		hint += " network_allowlist=" + csvOrDash(policy.NetworkAllowlist)
+	}
+	return hint
 }
 
 func csvOrDash(items []string) string {
@@ -408,10 +429,15 @@ func buildModelFactory(configStore *appConfigStore, credentials *credentialStore
 			fmt.Fprintf(os.Stderr, "warn: skip provider %q: %v\n", providerCfg.Alias, registerErr)
 		}
 	}
+	factory.SetFallbacks(configStore.ModelFallbacks())
 	return factory
 }
 
 func resolveModelAliasFromConfig(alias string, configStore *appConfigStore) string {
+	// Replay aliases carry a case-sensitive cassette path.
+	if _, ok := modelproviders.ReplayCassettePath(alias); ok {
+		return strings.TrimSpace(alias)
+	}
 	alias = strings.TrimSpace(strings.ToLower(alias))
 	if alias == "" {
 		alias = configStore.DefaultModel()
@@ -424,6 +450,7 @@ func resolveModelAliasFromConfig(alias string, configStore *appConfigStore) stri
 
 type sessionRuntimeResult struct {
 	Store      session.Store
+	MainScope  *localstore.ScopeStore
 	TaskStore  task.Store
 	Index      *sessionIndex
 	DB         *localstore.Database
@@ -467,6 +494,7 @@ func setupSessionRuntime(ctx context.Context, storeDir, workspaceKey, _, _ strin
 		Compaction: runtime.CompactionConfig{
 			WatermarkRatio: compactWatermark,
 		},
+		Pricer: estimateCatalogModelCost,
 	})
 	if err != nil {
 		_ = db.Close()
@@ -479,6 +507,7 @@ func setupSessionRuntime(ctx context.Context, storeDir, workspaceKey, _, _ strin
 		Compaction: runtime.CompactionConfig{
 			WatermarkRatio: compactWatermark,
 		},
+		Pricer: estimateCatalogModelCost,
 	})
 	if err != nil {
 		_ = db.Close()
@@ -486,6 +515,7 @@ func setupSessionRuntime(ctx context.Context, storeDir, workspaceKey, _, _ strin
 	}
 	return &sessionRuntimeResult{
 		Store:      mainStore,
+		MainScope:  mainStore,
 		TaskStore:  mainStore,
 		Index:      index,
 		DB:         db,
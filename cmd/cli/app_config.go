@@ -11,13 +11,17 @@ import (
 	stdruntime "runtime"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 	"unicode"
 
 	appagents "github.com/OnslaughtSnail/caelis/internal/app/agents"
+	"github.com/OnslaughtSnail/caelis/internal/approvalgrant"
 	"github.com/OnslaughtSnail/caelis/internal/envload"
+	"github.com/OnslaughtSnail/caelis/internal/mcpclient"
 	toolexec "github.com/OnslaughtSnail/caelis/kernel/execenv"
 	modelproviders "github.com/OnslaughtSnail/caelis/kernel/model/providers"
+	"github.com/OnslaughtSnail/caelis/kernel/task"
 )
 
 const (
@@ -37,11 +41,13 @@ var configEnvPlaceholderPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]
 type appConfig struct {
 	Version                   int                    `json:"version"`
 	DefaultModel              string                 `json:"default_model"`
+	ModelFallbacks            []string               `json:"model_fallbacks,omitempty"`
 	PermissionMode            string                 `json:"permission_mode,omitempty"`
 	SandboxType               string                 `json:"sandbox_type,omitempty"`
 	SandboxReadableRoots      []string               `json:"sandbox_readable_roots,omitempty"`
 	SandboxWritableRoots      []string               `json:"sandbox_writable_roots,omitempty"`
 	SandboxReadOnlySubpaths   []string               `json:"sandbox_read_only_subpaths,omitempty"`
+	SandboxNetworkAllowlist   []string               `json:"sandbox_network_allowlist,omitempty"`
 	MainAgent                 string                 `json:"mainAgent,omitempty"`
 	DefaultAgent              string                 `json:"defaultAgent,omitempty"`
 	DefaultPermissions        string                 `json:"defaultPermissions,omitempty"`
@@ -50,9 +56,62 @@ type appConfig struct {
 	TTL                       int                    `json:"ttl,omitempty"`
 	Timeout                   *int                   `json:"timeout,omitempty"`
 	Format                    string                 `json:"format,omitempty"`
+	CredentialStoreMode       string                 `json:"credential_store_mode,omitempty"`
 	Providers                 []providerRecord       `json:"providers,omitempty"`
 	Agents                    map[string]agentRecord `json:"agents,omitempty"`
+	MCPServers                map[string]mcpRecord   `json:"mcp_servers,omitempty"`
 	Auth                      map[string]string      `json:"auth,omitempty"`
+	ApprovalGrants            []approvalgrant.Grant  `json:"approval_grants,omitempty"`
+	SubagentBudget            *subagentBudgetRecord  `json:"subagent_budget,omitempty"`
+	BashLimits                *bashLimitsRecord      `json:"bash_limits,omitempty"`
+	BashPersistentShell       bool                   `json:"bash_persistent_shell,omitempty"`
+	// SSHWorkspaces maps local workspace directories to the remote machines
+	// their commands and file tools run on.
+	SSHWorkspaces map[string]sshWorkspaceRecord `json:"ssh_workspaces,omitempty"`
+}
+
+// subagentBudgetRecord holds the default limits for SPAWN children. A SPAWN
+// call's own max_steps, max_tokens and max_tool_calls take precedence.
+type subagentBudgetRecord struct {
+	MaxSteps           int     `json:"max_steps,omitempty"`
+	MaxTokens          int     `json:"max_tokens,omitempty"`
+	MaxToolCalls       int     `json:"max_tool_calls,omitempty"`
+	MaxCostUSD         float64 `json:"max_cost_usd,omitempty"`
+	MaxWallTimeSeconds int     `json:"max_wall_time_seconds,omitempty"`
+}
+
+// bashLimitsRecord holds the default resource limits for BASH commands. A
+// BASH call's own max_* args can tighten them but not raise them.
+type bashLimitsRecord struct {
+	CPUSeconds     int   `json:"cpu_seconds,omitempty"`
+	MemoryMB       int   `json:"memory_mb,omitempty"`
+	MaxProcesses   int   `json:"max_processes,omitempty"`
+	MaxOutputBytes int64 `json:"max_output_bytes,omitempty"`
+}
+
+// sshWorkspaceRecord points a local workspace at a checkout on a remote
+// machine. Unset fields fall back to the SSH defaults: port 22, the local
+// user name, the ssh-agent keys and ~/.ssh/known_hosts.
+type sshWorkspaceRecord struct {
+	Host           string `json:"host"`
+	Port           int    `json:"port,omitempty"`
+	User           string `json:"user,omitempty"`
+	IdentityFile   string `json:"identity_file,omitempty"`
+	KnownHostsFile string `json:"known_hosts_file,omitempty"`
+	Workdir        string `json:"workdir,omitempty"`
+}
+
+type mcpRecord struct {
+	Type     string            `json:"type,omitempty"`
+	Command  string            `json:"command,omitempty"`
+	Args     []string          `json:"args,omitempty"`
+	Env      map[string]string `json:"env,omitempty"`
+	WorkDir  string            `json:"workDir,omitempty"`
+	URL      string            `json:"url,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	Disabled bool              `json:"disabled,omitempty"`
+	// Trusted lets the server's read-only tool annotations skip approval.
+	Trusted bool `json:"trusted,omitempty"`
 }
 
 type agentRecord struct {
@@ -87,6 +146,8 @@ type providerRecord struct {
 	API                       string            `json:"api"`
 	Model                     string            `json:"model"`
 	BaseURL                   string            `json:"base_url"`
+	APIVersion                string            `json:"api_version,omitempty"`
+	Deployment                string            `json:"deployment,omitempty"`
 	Headers                   map[string]string `json:"headers,omitempty"`
 	TimeoutSeconds            int               `json:"timeout_seconds,omitempty"`
 	MaxOutputTok              int               `json:"max_output_tokens,omitempty"`
@@ -122,6 +183,13 @@ type authRecord struct {
 type appConfigStore struct {
 	path string
 	data appConfig
+
+	grantsOnce sync.Once
+	grants     *approvalgrant.Store
+
+	// profiles are the subagent profiles discovered for the current
+	// workspace; they are appended to the configured ACP agents.
+	profiles []appagents.Descriptor
 }
 
 func loadOrInitAppConfig(appName string) (*appConfigStore, error) {
@@ -234,6 +302,9 @@ func resolveAppConfigEnvPlaceholders(cfg *appConfig, configPath string) error {
 	if err := resolveStringSliceField("sandbox_read_only_subpaths", &cfg.SandboxReadOnlySubpaths); err != nil {
 		return err
 	}
+	if err := resolveStringSliceField("sandbox_network_allowlist", &cfg.SandboxNetworkAllowlist); err != nil {
+		return err
+	}
 	if err := resolveField("mainAgent", &cfg.MainAgent); err != nil {
 		return err
 	}
@@ -272,6 +343,12 @@ func resolveAppConfigEnvPlaceholders(cfg *appConfig, configPath string) error {
 		if err := resolveField(prefix+".base_url", &rec.BaseURL); err != nil {
 			return err
 		}
+		if err := resolveField(prefix+".api_version", &rec.APIVersion); err != nil {
+			return err
+		}
+		if err := resolveField(prefix+".deployment", &rec.Deployment); err != nil {
+			return err
+		}
 		if err := resolveField(prefix+".thinking_mode", &rec.ThinkingMode); err != nil {
 			return err
 		}
@@ -333,6 +410,20 @@ func resolveAppConfigEnvPlaceholders(cfg *appConfig, configPath string) error {
 			cfg.Agents[key] = rec
 		}
 	}
+	if len(cfg.MCPServers) > 0 {
+		keys := make([]string, 0, len(cfg.MCPServers))
+		for key := range cfg.MCPServers {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			rec := cfg.MCPServers[key]
+			if err := resolveAppConfigMCPPlaceholders(&rec, "mcp_servers."+key, resolveField); err != nil {
+				return err
+			}
+			cfg.MCPServers[key] = rec
+		}
+	}
 	if len(cfg.Auth) > 0 {
 		keys := make([]string, 0, len(cfg.Auth))
 		for key := range cfg.Auth {
@@ -389,6 +480,44 @@ func resolveAppConfigAgentPlaceholders(rec *agentRecord, prefix string, resolveF
 	return nil
 }
 
+func resolveAppConfigMCPPlaceholders(rec *mcpRecord, prefix string, resolveField func(string, *string) error) error {
+	if rec == nil {
+		return nil
+	}
+	if err := resolveField(prefix+".command", &rec.Command); err != nil {
+		return err
+	}
+	if err := resolveField(prefix+".workDir", &rec.WorkDir); err != nil {
+		return err
+	}
+	if err := resolveField(prefix+".url", &rec.URL); err != nil {
+		return err
+	}
+	for i := range rec.Args {
+		if err := resolveField(fmt.Sprintf("%s.args[%d]", prefix, i), &rec.Args[i]); err != nil {
+			return err
+		}
+	}
+	for _, group := range []struct {
+		name   string
+		values map[string]string
+	}{{name: "env", values: rec.Env}, {name: "headers", values: rec.Headers}} {
+		keys := make([]string, 0, len(group.values))
+		for key := range group.values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			value := group.values[key]
+			if err := resolveField(prefix+"."+group.name+"."+key, &value); err != nil {
+				return err
+			}
+			group.values[key] = value
+		}
+	}
+	return nil
+}
+
 func resolveConfigStringPlaceholders(raw string) (string, error) {
 	matches := configEnvPlaceholderPattern.FindAllStringSubmatchIndex(raw, -1)
 	if len(matches) == 0 {
@@ -421,6 +550,21 @@ func (s *appConfigStore) DefaultModel() string {
 	return strings.ToLower(value)
 }
 
+// ModelFallbacks returns the ordered model aliases every model fails over to
+// when its provider is rate limited, down or out of context.
+func (s *appConfigStore) ModelFallbacks() []string {
+	if s == nil {
+		return nil
+	}
+	out := make([]string, 0, len(s.data.ModelFallbacks))
+	for _, alias := range s.data.ModelFallbacks {
+		if alias = s.ResolveModelAlias(alias); alias != "" {
+			out = append(out, alias)
+		}
+	}
+	return out
+}
+
 func (s *appConfigStore) DefaultAgent() string {
 	if s == nil {
 		return ""
@@ -481,9 +625,35 @@ func (s *appConfigStore) resolvedAgentDescriptors() ([]appagents.Descriptor, err
 		}
 		out = append(out, desc)
 	}
+	for _, profile := range s.profiles {
+		if _, ok := s.data.Agents[profile.ID]; ok {
+			continue
+		}
+		out = append(out, profile)
+	}
 	return out, nil
 }
 
+// LoadSubagentProfiles discovers subagent profiles from ~/.agents/agents and
+// the workspace's .agents/agents. A profile named like a configured ACP agent
+// is ignored and reported as a warning.
+func (s *appConfigStore) LoadSubagentProfiles(workspaceDir string) []error {
+	if s == nil {
+		return nil
+	}
+	result := appagents.DiscoverProfiles(appagents.ProfileDirs(workspaceDir))
+	warnings := result.Warnings
+	s.profiles = s.profiles[:0]
+	for _, desc := range result.Descriptors {
+		if _, ok := s.data.Agents[desc.ID]; ok {
+			warnings = append(warnings, fmt.Errorf("agents: profile %q is shadowed by configured agent %q", desc.Profile.Path, desc.ID))
+			continue
+		}
+		s.profiles = append(s.profiles, desc)
+	}
+	return warnings
+}
+
 func (s *appConfigStore) AgentRegistry() (*appagents.Registry, error) {
 	descs, err := s.resolvedAgentDescriptors()
 	if err != nil {
@@ -608,8 +778,138 @@ func (s *appConfigStore) DeleteAgent(name string) error {
 	return s.save()
 }
 
+// MCPServerConfigs returns enabled MCP servers from config sorted by name.
+func (s *appConfigStore) MCPServerConfigs() []mcpclient.ServerConfig {
+	if s == nil || len(s.data.MCPServers) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(s.data.MCPServers))
+	for key := range s.data.MCPServers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	out := make([]mcpclient.ServerConfig, 0, len(keys))
+	for _, key := range keys {
+		rec := s.data.MCPServers[key]
+		if rec.Disabled || strings.TrimSpace(key) == "" {
+			continue
+		}
+		out = append(out, mcpclient.ServerConfig{
+			Name:      strings.TrimSpace(key),
+			Transport: mcpclient.TransportType(strings.TrimSpace(rec.Type)),
+			Command:   strings.TrimSpace(rec.Command),
+			Args:      append([]string(nil), rec.Args...),
+			Env:       copyStringMap(rec.Env),
+			WorkDir:   strings.TrimSpace(rec.WorkDir),
+			URL:       strings.TrimSpace(rec.URL),
+			Headers:   copyStringMap(rec.Headers),
+			Trusted:   rec.Trusted,
+		})
+	}
+	return out
+}
+
+// ApprovalGrantStore returns the persisted "always allow" approvals, backed by
+// this config file.
+func (s *appConfigStore) ApprovalGrantStore() *approvalgrant.Store {
+	if s == nil {
+		return nil
+	}
+	s.grantsOnce.Do(func() {
+		s.grants = approvalgrant.NewStore(s)
+	})
+	return s.grants
+}
+
+func (s *appConfigStore) LoadApprovalGrants() []approvalgrant.Grant {
+	return append([]approvalgrant.Grant(nil), s.data.ApprovalGrants...)
+}
+
+func (s *appConfigStore) SaveApprovalGrants(grants []approvalgrant.Grant) error {
+	s.data.ApprovalGrants = append([]approvalgrant.Grant(nil), grants...)
+	return s.save()
+}
+
+// SubagentBudget returns the configured default budget for SPAWN children.
+// Negative limits are ignored.
+func (s *appConfigStore) SubagentBudget() task.Budget {
+	if s == nil || s.data.SubagentBudget == nil {
+		return task.Budget{}
+	}
+	rec := s.data.SubagentBudget
+	return task.Budget{
+		MaxSteps:     max(rec.MaxSteps, 0),
+		MaxTokens:    max(rec.MaxTokens, 0),
+		MaxToolCalls: max(rec.MaxToolCalls, 0),
+		MaxCostUSD:   max(rec.MaxCostUSD, 0),
+		MaxWallTime:  time.Duration(max(rec.MaxWallTimeSeconds, 0)) * time.Second,
+	}
+}
+
+// BashLimits returns the configured default resource limits for BASH
+// commands. Negative limits are ignored.
+func (s *appConfigStore) BashLimits() toolexec.ResourceLimits {
+	if s == nil || s.data.BashLimits == nil {
+		return toolexec.ResourceLimits{}
+	}
+	rec := s.data.BashLimits
+	return toolexec.ResourceLimits{
+		CPUTime:        time.Duration(max(rec.CPUSeconds, 0)) * time.Second,
+		MemoryBytes:    int64(max(rec.MemoryMB, 0)) << 20,
+		MaxProcesses:   max(rec.MaxProcesses, 0),
+		MaxOutputBytes: max(rec.MaxOutputBytes, 0),
+	}
+}
+
+// BashPersistentShell reports whether BASH commands run in one persistent
+// shell per session.
+func (s *appConfigStore) BashPersistentShell() bool {
+	return s != nil && s.data.BashPersistentShell
+}
+
+// SSHWorkspace returns the remote machine configured for the workspace that
+// contains dir, or nil when the workspace is local. The most specific
+// configured directory wins.
+func (s *appConfigStore) SSHWorkspace(dir string) *toolexec.SSHConfig {
+	if s == nil || len(s.data.SSHWorkspaces) == 0 || strings.TrimSpace(dir) == "" {
+		return nil
+	}
+	dir = filepath.Clean(dir)
+	var (
+		bestRoot string
+		best     *sshWorkspaceRecord
+	)
+	for key, rec := range s.data.SSHWorkspaces {
+		root, err := resolvePromptPath(key)
+		if err != nil || strings.TrimSpace(rec.Host) == "" {
+			continue
+		}
+		rel, err := filepath.Rel(root, dir)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if best == nil || len(root) > len(bestRoot) {
+			bestRoot, best = root, &rec
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	return &toolexec.SSHConfig{
+		Host:           strings.TrimSpace(best.Host),
+		Port:           max(best.Port, 0),
+		User:           strings.TrimSpace(best.User),
+		IdentityFile:   strings.TrimSpace(best.IdentityFile),
+		KnownHostsFile: strings.TrimSpace(best.KnownHostsFile),
+		Workdir:        strings.TrimSpace(best.Workdir),
+	}
+}
+
 func (s *appConfigStore) CredentialStoreMode() string {
-	return defaultCredentialStoreMode
+	if s == nil {
+		return defaultCredentialStoreMode
+	}
+	return normalizeCredentialStoreMode(s.data.CredentialStoreMode)
 }
 
 func (s *appConfigStore) StreamModel() bool {
@@ -654,6 +954,7 @@ func (s *appConfigStore) SandboxPolicy() toolexec.SandboxPolicy {
 		ReadableRoots:    normalizeStringSlice(s.data.SandboxReadableRoots),
 		WritableRoots:    normalizeStringSlice(s.data.SandboxWritableRoots),
 		ReadOnlySubpaths: normalizeStringSlice(s.data.SandboxReadOnlySubpaths),
+		NetworkAllowlist: normalizeStringSlice(s.data.SandboxNetworkAllowlist),
 	}
 }
 
@@ -675,6 +976,8 @@ func (s *appConfigStore) ProviderConfigs() []modelproviders.Config {
 			API:                       modelproviders.APIType(strings.TrimSpace(rec.API)),
 			Model:                     strings.TrimSpace(rec.Model),
 			BaseURL:                   strings.TrimSpace(rec.BaseURL),
+			APIVersion:                strings.TrimSpace(rec.APIVersion),
+			Deployment:                strings.TrimSpace(rec.Deployment),
 			Headers:                   copyHeaders(rec.Headers),
 			ContextWindowTokens:       rec.ContextWindowTokens,
 			MaxOutputTok:              rec.MaxOutputTok,
@@ -809,9 +1112,15 @@ func (s *appConfigStore) SetDefaultModel(alias string) error {
 }
 
 func (s *appConfigStore) SetCredentialStoreMode(mode string) error {
-	_ = s
-	_ = mode
-	return nil
+	if s == nil {
+		return nil
+	}
+	mode = normalizeCredentialStoreMode(mode)
+	if mode == defaultCredentialStoreMode {
+		mode = ""
+	}
+	s.data.CredentialStoreMode = mode
+	return s.save()
 }
 
 func (s *appConfigStore) SetRuntimeSettings(settings runtimeSettings) error {
@@ -890,6 +1199,8 @@ func (s *appConfigStore) UpsertProvider(cfg modelproviders.Config) error {
 		API:                       string(cfg.API),
 		Model:                     strings.TrimSpace(cfg.Model),
 		BaseURL:                   strings.TrimSpace(cfg.BaseURL),
+		APIVersion:                strings.TrimSpace(cfg.APIVersion),
+		Deployment:                strings.TrimSpace(cfg.Deployment),
 		Headers:                   copyHeaders(cfg.Headers),
 		ContextWindowTokens:       cfg.ContextWindowTokens,
 		MaxOutputTok:              cfg.MaxOutputTok,
@@ -1081,6 +1392,7 @@ func mergeAppConfigDefaults(cfg *appConfig) {
 	cfg.SandboxReadableRoots = normalizeStringSlice(cfg.SandboxReadableRoots)
 	cfg.SandboxWritableRoots = normalizeStringSlice(cfg.SandboxWritableRoots)
 	cfg.SandboxReadOnlySubpaths = normalizeStringSlice(cfg.SandboxReadOnlySubpaths)
+	cfg.SandboxNetworkAllowlist = normalizeStringSlice(cfg.SandboxNetworkAllowlist)
 	if len(cfg.Agents) > 0 {
 		keys := make([]string, 0, len(cfg.Agents))
 		for key := range cfg.Agents {
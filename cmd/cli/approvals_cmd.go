@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+const approvalsUsage = "usage: /approvals [list|revoke <id|key>]"
+
+func handleApprovals(c *cliConsole, args []string) (bool, error) {
+	grants := c.configStore.ApprovalGrantStore()
+	if grants == nil {
+		return false, fmt.Errorf("approval grants are not available")
+	}
+	if len(args) == 0 || (len(args) == 1 && strings.EqualFold(strings.TrimSpace(args[0]), "list")) {
+		c.printApprovalGrants()
+		return false, nil
+	}
+	if len(args) != 2 || !strings.EqualFold(strings.TrimSpace(args[0]), "revoke") {
+		return false, fmt.Errorf(approvalsUsage)
+	}
+	removed, err := grants.Revoke(c.workspace.CWD, args[1])
+	if err != nil {
+		return false, err
+	}
+	if len(removed) == 0 {
+		return false, fmt.Errorf("no stored approval matches %q", strings.TrimSpace(args[1]))
+	}
+	for _, grant := range removed {
+		c.printf("revoked %s approval for %s (%s)\n", grant.Kind, grant.Key, grant.Scope)
+	}
+	return false, nil
+}
+
+func (c *cliConsole) printApprovalGrants() {
+	grants := c.configStore.ApprovalGrantStore().List(c.workspace.CWD)
+	if len(grants) == 0 {
+		c.printf("no stored approvals; choose workspace or global at an approval prompt to add one\n")
+		return
+	}
+	c.ui.Section("Approvals")
+	for _, grant := range grants {
+		c.ui.Plain("  %-8s  %-9s  %-7s  %s\n", grant.ID, grant.Scope, grant.Kind, grant.Key)
+	}
+}
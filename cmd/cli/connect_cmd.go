@@ -58,6 +58,22 @@ var providerTemplates = []providerTemplate{
 		defaultContextToken: 128000,
 		commonModels:        []string{"gpt-4o", "gpt-4o-mini", "o3", "o4-mini"},
 	},
+	{
+		// Same provider as "openai" so the catalog, credentials and aliases
+		// are shared; only the wire dialect differs.
+		label:               "openai-responses",
+		api:                 modelproviders.APIOpenAIResponses,
+		provider:            "openai",
+		defaultBaseURL:      "https://api.openai.com/v1",
+		defaultContextToken: 128000,
+		commonModels:        []string{"gpt-4o", "gpt-4o-mini", "o3", "o4-mini"},
+	},
+	{
+		label:               "azure-openai",
+		api:                 modelproviders.APIAzureOpenAI,
+		provider:            "azure-openai",
+		defaultContextToken: 128000,
+	},
 	{
 		label:               "openai-compatible",
 		api:                 modelproviders.APIOpenAICompatible,
@@ -170,7 +186,7 @@ func handleConnect(c *cliConsole, args []string) (bool, error) {
 	}
 
 	baseURL := strings.TrimSpace(tpl.defaultBaseURL)
-	if tpl.provider == "openai-compatible" || tpl.provider == "anthropic-compatible" {
+	if tpl.provider == "openai-compatible" || tpl.provider == "anthropic-compatible" || tpl.api == modelproviders.APIAzureOpenAI {
 		baseURL, err = c.promptText("base_url", tpl.defaultBaseURL, false)
 		if err != nil {
 			return false, err
@@ -180,6 +196,14 @@ func handleConnect(c *cliConsole, args []string) (bool, error) {
 			return false, fmt.Errorf("base_url is required")
 		}
 	}
+	apiVersion := ""
+	if tpl.api == modelproviders.APIAzureOpenAI {
+		apiVersion, err = c.promptText("api_version", modelproviders.AzureOpenAIDefaultAPIVersion, false)
+		if err != nil {
+			return false, err
+		}
+		apiVersion = strings.TrimSpace(apiVersion)
+	}
 
 	timeoutSeconds := 60
 	token := ""
@@ -202,10 +226,11 @@ func handleConnect(c *cliConsole, args []string) (bool, error) {
 	}
 
 	baseCfg := modelproviders.Config{
-		Provider: strings.TrimSpace(tpl.provider),
-		API:      tpl.api,
-		BaseURL:  baseURL,
-		Timeout:  time.Duration(timeoutSeconds) * time.Second,
+		Provider:   strings.TrimSpace(tpl.provider),
+		API:        tpl.api,
+		BaseURL:    baseURL,
+		APIVersion: apiVersion,
+		Timeout:    time.Duration(timeoutSeconds) * time.Second,
 		Auth: modelproviders.AuthConfig{
 			Type:          authType,
 			Token:         token,
@@ -309,6 +334,9 @@ func promptProviderTemplate(c *cliConsole) (providerTemplate, error) {
 	choices := make([]promptChoiceItem, 0, len(providerTemplates))
 	for _, tpl := range providerTemplates {
 		detail := tpl.defaultBaseURL
+		if tpl.api == modelproviders.APIAzureOpenAI {
+			detail = "https://<resource>.openai.azure.com"
+		}
 		if tpl.noAuthRequired {
 			detail += " · no auth"
 		}
@@ -850,7 +878,7 @@ func connectProviderReasoningProfile(baseCfg modelproviders.Config) reasoningPro
 	switch baseCfg.API {
 	case modelproviders.APIDeepSeek, modelproviders.APIMimo, modelproviders.APIVolcengine, modelproviders.APIVolcengineCoding:
 		return reasoningProfile{Mode: reasoningModeToggle}
-	case modelproviders.APIOpenAI, modelproviders.APIOpenAICompatible, modelproviders.APIOpenRouter:
+	case modelproviders.APIOpenAI, modelproviders.APIOpenAICompatible, modelproviders.APIOpenRouter, modelproviders.APIOpenAIResponses, modelproviders.APIAzureOpenAI:
 		return reasoningProfile{Mode: reasoningModeEffort, SupportedEfforts: append([]string(nil), openAICompatibleStandardEfforts...), DefaultEffort: "medium"}
 	case modelproviders.APIGemini, modelproviders.APIAnthropic:
 		return reasoningProfile{Mode: reasoningModeEffort, SupportedEfforts: []string{"low", "medium", "high"}, DefaultEffort: "medium"}
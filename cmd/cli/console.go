@@ -32,6 +32,7 @@ import (
 	toolshell "github.com/OnslaughtSnail/caelis/kernel/tool/builtin/shell"
 	coreacpmeta "github.com/OnslaughtSnail/caelis/pkg/acpmeta"
 
+	"github.com/OnslaughtSnail/caelis/internal/approvalgrant"
 	"github.com/OnslaughtSnail/caelis/internal/approvalqueue"
 	image "github.com/OnslaughtSnail/caelis/internal/cli/imageutil"
 	"github.com/OnslaughtSnail/caelis/internal/cli/tuiapp"
@@ -286,6 +287,8 @@ func newCLIConsole(cfg cliConsoleConfig) *cliConsole {
 	}
 	console.approver = newTerminalApprover(console.prompter, out, baseUI)
 	console.approver.modeResolver = func() string { return console.currentApprovalMode() }
+	console.approver.grants = console.configStore.ApprovalGrantStore()
+	console.approver.workspace = console.workspace.CWD
 	console.commands = map[string]slashCommand{
 		"help":    {Usage: "/help", Description: "Show available commands", Handle: handleHelp},
 		"btw":     {Usage: "/btw <question>", Description: "Ask an ephemeral side question without modifying history", Handle: handleBTW},
@@ -294,7 +297,19 @@ func newCLIConsole(cfg cliConsoleConfig) *cliConsole {
 		"new":     {Usage: "/new", Description: "Start a new conversation session", Handle: handleNew},
 		"fork":    {Usage: "/fork", Description: "Fork current conversation into a new session", Handle: handleFork},
 		"compact": {Usage: "/compact [note]", Description: "Compact context history", Handle: handleCompact},
+		"rewind":  {Usage: "/rewind [turn|list]", Description: "Undo file edits and history back to before a turn", Handle: handleRewind},
+		"staged":  {Usage: "/staged [apply [path ...]|discard]", Description: "Review, apply or discard changes staged in staged mode", Handle: handleStaged},
 		"status":  {Usage: "/status", Description: "Show current session status", Handle: handleStatus},
+		"approvals": {
+			Usage:       "/approvals [list|revoke <id|key>]",
+			Description: "List or revoke approvals remembered for this workspace or globally",
+			Handle:      handleApprovals,
+		},
+		"credentials": {
+			Usage:       "/credentials [status|encrypt]",
+			Description: "Show the credential store or encrypt it with a passphrase",
+			Handle:      handleCredentials,
+		},
 		"sandbox": {
 			Usage:       "/sandbox [auto|<type>]",
 			Description: "View or switch sandbox type (auto/bwrap/landlock experimental)",
@@ -535,6 +550,7 @@ func (c *cliConsole) sessionBoundary() (*sessionsvc.Service, error) {
 			WorkspaceCWD:         c.workspace.CWD,
 			ClientRuntime:        execRuntime,
 			ResolveAgentRegistry: c.configStore.AgentRegistry,
+			SubagentBudget:       c.configStore.SubagentBudget(),
 			NewAdapter:           c.newACPAdapter,
 		}),
 	})
@@ -626,21 +642,29 @@ func (c *cliConsole) preparePromptSubmission(input string, attachments []tuiapp.
 			}
 		}
 	}
-	// Load image content parts from resolved file references.
+	// Load image, document and audio content parts from resolved file references.
 	var resolvedImageParts []model.ContentPart
 	if c.inputRefs != nil && len(resolvedPaths) > 0 {
 		for _, relPath := range resolvedPaths {
-			if !image.IsImagePath(relPath) {
-				continue
-			}
 			absPath := c.inputRefs.AbsPath(relPath)
-			part, err := image.LoadAsContentPartCached(absPath, c.imageCache)
-			if err != nil {
-				c.ui.Warn("image load skipped: %s: %v\n", relPath, err)
-				continue
+			switch {
+			case image.IsImagePath(relPath):
+				part, err := image.LoadAsContentPartCached(absPath, c.imageCache)
+				if err != nil {
+					c.ui.Warn("image load skipped: %s: %v\n", relPath, err)
+					continue
+				}
+				resolvedImageParts = append(resolvedImageParts, part)
+				c.ui.Note("attached image: %s\n", relPath)
+			case image.IsMediaPath(relPath):
+				part, err := image.LoadMediaAsContentPart(absPath)
+				if err != nil {
+					c.ui.Warn("attachment load skipped: %s: %v\n", relPath, err)
+					continue
+				}
+				resolvedImageParts = append(resolvedImageParts, part)
+				c.ui.Note("attached %s: %s\n", part.Type, relPath)
 			}
-			resolvedImageParts = append(resolvedImageParts, part)
-			c.ui.Note("attached image: %s\n", relPath)
 		}
 	}
 	var contentParts []model.ContentPart
@@ -714,6 +738,7 @@ func (c *cliConsole) runPreparedSubmissionContext(ctx context.Context, prepared
 		return err
 	}
 	c.closePersistentMainACP()
+	ctx = sessionmode.WithTurnContext(ctx, c.sessionMode)
 	ctx = toolexec.WithApprover(ctx, c.approver)
 	ctx = kernelpolicy.WithToolAuthorizer(ctx, c.approver)
 	pendingTUIToolCalls := map[string]toolCallSnapshot{}
@@ -790,7 +815,7 @@ func (c *cliConsole) runPreparedSubmissionContext(ctx context.Context, prepared
 		c.clearActiveRun()
 		_ = runner.Close() // Close always returns nil; safe to ignore.
 	}()
-	return runRunner(runner, runRenderConfig{
+	if err := runRunner(runner, runRenderConfig{
 		ShowReasoning: c.showReasoning,
 		Verbose:       c.ui.verbose,
 		Writer:        c.out,
@@ -802,7 +827,11 @@ func (c *cliConsole) runPreparedSubmissionContext(ctx context.Context, prepared
 		OnUsage: func(floor int) {
 			c.refreshContextUsageEstimate(floor)
 		},
-	})
+	}); err != nil {
+		return err
+	}
+	c.reviewStagedChanges()
+	return nil
 }
 
 func (c *cliConsole) persistSessionModelAlias(ctx context.Context) error {
@@ -1273,7 +1302,11 @@ func (c *cliConsole) forwardEventToTUIWithOptionsContext(ctx context.Context, ev
 			visualsOK := false
 			if isFileMutationTool(call.Name) && !opts.ReplayMode {
 				visuals, visualsOK = buildToolCallMutationVisuals(previewRuntime, call.Name, parsedArgs)
-				if visualsOK && previewFS != nil && strings.TrimSpace(visuals.PreviewPath) != "" {
+				if visualsOK && previewFS != nil && len(visuals.PreviewFiles) > 0 {
+					for _, file := range visuals.PreviewFiles {
+						previewFS.Stage(file.Path, file.New)
+					}
+				} else if visualsOK && previewFS != nil && strings.TrimSpace(visuals.PreviewPath) != "" {
 					previewFS.Stage(visuals.PreviewPath, visuals.PreviewNew)
 				}
 			}
@@ -1820,9 +1853,9 @@ func handleHelp(c *cliConsole, args []string) (bool, error) {
 			c.ui.Plain("  %-24s %s\n", cmd.Usage, cmd.Description)
 		}
 	}
-	helpSection("Session", []string{"new", "fork", "attach", "back", "resume", "compact", "status"})
+	helpSection("Session", []string{"new", "fork", "attach", "back", "resume", "compact", "rewind", "staged", "status"})
 	helpSection("Model", []string{"model", "connect", "agent"})
-	helpSection("Security", []string{"sandbox"})
+	helpSection("Security", []string{"sandbox", "approvals", "credentials"})
 	helpSection("Other", []string{"btw", "help", "exit", "quit"})
 	c.ui.Section("Keys")
 	c.ui.Plain("  %-24s %s\n", "shift+tab", "Cycle mode")
@@ -2011,6 +2044,9 @@ func handleLocalStatus(c *cliConsole) (bool, error) {
 	if err := renderStatusRuntime(c); err != nil {
 		return false, err
 	}
+	if err := renderStatusTokenUsage(c); err != nil {
+		return false, err
+	}
 
 	if c.llm == nil {
 		c.ui.Section("Context")
@@ -2483,6 +2519,13 @@ func (c *cliConsole) reloadConfiguredModels() error {
 			}
 		}
 	}
+	// Keep the fallback chain chosen at startup, which may come from
+	// -fallback-models rather than the config file.
+	if c.modelFactory != nil {
+		factory.SetFallbacks(c.modelFactory.Fallbacks())
+	} else if c.configStore != nil {
+		factory.SetFallbacks(c.configStore.ModelFallbacks())
+	}
 	c.modelFactory = factory
 
 	currentAlias := strings.ToLower(strings.TrimSpace(c.modelAlias))
@@ -2845,7 +2888,7 @@ func (c *cliConsole) updateExecutionRuntime(mode toolexec.PermissionMode, sandbo
 		}
 	}
 	prevRuntime := c.execRuntime
-	nextRuntime, err := newExecutionRuntime(mode, sandboxType, c.sandboxHelperPath, c.sandboxPolicy)
+	nextRuntime, err := newExecutionRuntime(mode, sandboxType, c.sandboxHelperPath, c.sandboxPolicy, c.configStore.BashLimits(), c.configStore.BashPersistentShell(), c.configStore.SSHWorkspace(c.workspace.CWD))
 	if err != nil {
 		return err
 	}
@@ -2915,6 +2958,10 @@ type terminalApprover struct {
 	queue          *approvalqueue.Queue
 	sessionAllowed map[string]struct{}
 	authAllowed    map[string]struct{}
+	// grants holds approvals remembered beyond this session; nil disables
+	// the workspace and global choices.
+	grants    *approvalgrant.Store
+	workspace string
 }
 
 func newTerminalApprover(prompter promptReader, out io.Writer, u *ui) *terminalApprover {
@@ -2940,12 +2987,12 @@ func (a *terminalApprover) Approve(ctx context.Context, req toolexec.ApprovalReq
 	if a.prompter == nil {
 		return false, &toolexec.ApprovalAbortedError{Reason: "no interactive approver available"}
 	}
-	if a.isAllowedInSession(req.Command) {
+	if a.isAllowedInSession(req.Command) || a.grants.AllowsCommand(a.workspace, req.Command) {
 		return true, nil
 	}
 	var allowed bool
 	err := a.queue.Do(ctx, func(context.Context) error {
-		if a.isAllowedInSession(req.Command) {
+		if a.isAllowedInSession(req.Command) || a.grants.AllowsCommand(a.workspace, req.Command) {
 			allowed = true
 			return nil
 		}
@@ -2972,6 +3019,18 @@ func (a *terminalApprover) Approve(ctx context.Context, req toolexec.ApprovalReq
 			a.emitCommandApprovalOutcome(req, key, "session")
 			allowed = true
 			return nil
+		case "w", "workspace", "g", "global":
+			if key == "" || a.grants == nil {
+				a.emitCommandApprovalOutcome(req, key, "cancel")
+				return &toolexec.ApprovalAbortedError{Reason: "approval denied by user"}
+			}
+			scope := grantScopeForChoice(line)
+			if _, err := a.grants.Add(a.workspace, approvalgrant.KindCommand, key, scope); err != nil {
+				return err
+			}
+			a.emitCommandApprovalOutcome(req, key, string(scope))
+			allowed = true
+			return nil
 		case "n", "no", "", "c", "cancel":
 			a.emitCommandApprovalOutcome(req, key, "cancel")
 			return &toolexec.ApprovalAbortedError{Reason: "approval denied by user"}
@@ -2995,12 +3054,12 @@ func (a *terminalApprover) AuthorizeTool(ctx context.Context, req kernelpolicy.T
 	if a.prompter == nil {
 		return false, &toolexec.ApprovalAbortedError{Reason: "no interactive approver available"}
 	}
-	if a.isAuthorizationAllowedInSession(scopeKey) {
+	if a.isAuthorizationAllowedInSession(scopeKey) || a.grants.AllowsTool(a.workspace, scopeKey) {
 		return true, nil
 	}
 	var allowed bool
 	err := a.queue.Do(ctx, func(context.Context) error {
-		if a.isAuthorizationAllowedInSession(scopeKey) {
+		if a.isAuthorizationAllowedInSession(scopeKey) || a.grants.AllowsTool(a.workspace, scopeKey) {
 			allowed = true
 			return nil
 		}
@@ -3025,6 +3084,18 @@ func (a *terminalApprover) AuthorizeTool(ctx context.Context, req kernelpolicy.T
 			a.emitToolApprovalOutcome(req, scopeKey, "session")
 			allowed = true
 			return nil
+		case "w", "workspace", "g", "global":
+			if a.grants == nil {
+				a.emitToolApprovalOutcome(req, scopeKey, "cancel")
+				return &toolexec.ApprovalAbortedError{Reason: "approval denied by user"}
+			}
+			scope := grantScopeForChoice(line)
+			if _, err := a.grants.Add(a.workspace, approvalgrant.KindTool, scopeKey, scope); err != nil {
+				return err
+			}
+			a.emitToolApprovalOutcome(req, scopeKey, string(scope))
+			allowed = true
+			return nil
 		case "n", "no", "", "c", "cancel":
 			a.emitToolApprovalOutcome(req, scopeKey, "cancel")
 			return &toolexec.ApprovalAbortedError{Reason: "approval denied by user"}
@@ -3062,27 +3133,15 @@ func (a *terminalApprover) currentMode() string {
 }
 
 func sessionApprovalKey(command string) string {
-	segments := shellCommandSegments(command)
-	for _, segment := range segments {
-		tokens := shellSegmentTokens(segment)
-		if len(tokens) == 0 {
-			continue
-		}
-		base := strings.ToLower(filepath.Base(tokens[0]))
-		if isApprovalWrapperCommand(base) {
-			continue
-		}
-		switch base {
-		case "go", "git", "npm", "pnpm", "yarn", "cargo", "make":
-			if len(tokens) > 1 && !strings.HasPrefix(tokens[1], "-") {
-				return strings.TrimSpace(base + " " + strings.ToLower(tokens[1]))
-			}
-			return strings.TrimSpace(base)
-		default:
-			return ""
-		}
+	return approvalgrant.CommandKey(command)
+}
+
+// grantScopeForChoice maps a workspace or global prompt answer to its scope.
+func grantScopeForChoice(choice string) approvalgrant.Scope {
+	if choice == "g" || choice == "global" {
+		return approvalgrant.ScopeGlobal
 	}
-	return ""
+	return approvalgrant.ScopeWorkspace
 }
 
 func toolApprovalKey(toolName string) string {
@@ -3101,17 +3160,20 @@ func toolAuthorizationScopeKey(req kernelpolicy.ToolAuthorizationRequest) string
 
 func (a *terminalApprover) readApprovalChoice(req toolexec.ApprovalRequest, sessionKey string) (string, error) {
 	if chooser, ok := a.prompter.(structuredPromptReader); ok {
-		return chooser.RequestStructuredPrompt(commandApprovalPromptRequest(req, sessionKey))
+		return chooser.RequestStructuredPrompt(commandApprovalPromptRequest(req, sessionKey, a.grants != nil))
 	}
 	a.renderCommandApprovalRequest(req)
 	if chooser, ok := a.prompter.(choicePromptReader); ok {
 		return chooser.RequestChoicePrompt(
 			commandApprovalTitle(),
-			approvalChoicesForSessionKey(sessionKey),
+			approvalChoicesForSessionKey(sessionKey, a.grants != nil),
 			"y",
 			false,
 		)
 	}
+	if sessionKey != "" && a.grants != nil {
+		return a.prompter.ReadLine(approvalPromptWithGrants)
+	}
 	if sessionKey != "" {
 		return a.prompter.ReadLine(approvalPromptAllowAlwaysDeny)
 	}
@@ -3120,17 +3182,20 @@ func (a *terminalApprover) readApprovalChoice(req toolexec.ApprovalRequest, sess
 
 func (a *terminalApprover) readToolAuthorizationChoice(req kernelpolicy.ToolAuthorizationRequest, scopeKey string) (string, error) {
 	if chooser, ok := a.prompter.(structuredPromptReader); ok {
-		return chooser.RequestStructuredPrompt(toolAuthorizationPromptRequest(req, scopeKey))
+		return chooser.RequestStructuredPrompt(toolAuthorizationPromptRequest(req, scopeKey, a.grants != nil))
 	}
 	a.renderToolAuthorizationRequest(req)
 	if chooser, ok := a.prompter.(choicePromptReader); ok {
 		return chooser.RequestChoicePrompt(
 			toolAuthorizationTitle(req),
-			toolAuthorizationChoices(scopeKey),
+			toolAuthorizationChoices(scopeKey, a.grants != nil),
 			"y",
 			false,
 		)
 	}
+	if a.grants != nil {
+		return a.prompter.ReadLine(approvalPromptWithGrants)
+	}
 	return a.prompter.ReadLine(toolAuthPrompt)
 }
 
@@ -3160,7 +3225,7 @@ func (a *terminalApprover) renderToolAuthorizationRequest(req kernelpolicy.ToolA
 	}
 }
 
-func approvalChoicesForSessionKey(sessionKey string) []tuievents.PromptChoice {
+func approvalChoicesForSessionKey(sessionKey string, persist bool) []tuievents.PromptChoice {
 	choices := []tuievents.PromptChoice{
 		{Label: "approve", Value: "y", Detail: "this time"},
 	}
@@ -3170,6 +3235,9 @@ func approvalChoicesForSessionKey(sessionKey string) []tuievents.PromptChoice {
 			Value:  "a",
 			Detail: "remember " + compactApprovalScope(sessionKey),
 		})
+		if persist {
+			choices = append(choices, grantPromptChoices(sessionKey)...)
+		}
 	}
 	choices = append(choices, tuievents.PromptChoice{
 		Label:  "reject",
@@ -3179,11 +3247,23 @@ func approvalChoicesForSessionKey(sessionKey string) []tuievents.PromptChoice {
 	return choices
 }
 
-func toolAuthorizationChoices(scopeKey string) []tuievents.PromptChoice {
-	return []tuievents.PromptChoice{
+func toolAuthorizationChoices(scopeKey string, persist bool) []tuievents.PromptChoice {
+	choices := []tuievents.PromptChoice{
 		{Label: "approve", Value: "y", Detail: "this time"},
 		{Label: "always", Value: "a", Detail: "remember " + compactApprovalScope(scopeKey)},
-		{Label: "reject", Value: "n", Detail: "skip it"},
+	}
+	if persist {
+		choices = append(choices, grantPromptChoices(scopeKey)...)
+	}
+	return append(choices, tuievents.PromptChoice{Label: "reject", Value: "n", Detail: "skip it"})
+}
+
+// grantPromptChoices offers to remember an approval beyond this session.
+func grantPromptChoices(key string) []tuievents.PromptChoice {
+	scope := compactApprovalScope(key)
+	return []tuievents.PromptChoice{
+		{Label: "workspace", Value: "w", Detail: "remember " + scope + " in this workspace"},
+		{Label: "global", Value: "g", Detail: "remember " + scope + " everywhere"},
 	}
 }
 
@@ -3218,6 +3298,10 @@ func (a *terminalApprover) emitCommandApprovalOutcome(req toolexec.ApprovalReque
 			scope = sessionKey
 		}
 		a.ui.ApprovalOutcome(true, "You approved this session for commands matching "+scope+".")
+	case string(approvalgrant.ScopeWorkspace):
+		a.ui.ApprovalOutcome(true, "You approved commands matching "+sessionKey+" in this workspace. Use /approvals to revoke.")
+	case string(approvalgrant.ScopeGlobal):
+		a.ui.ApprovalOutcome(true, "You approved commands matching "+sessionKey+" in every workspace. Use /approvals to revoke.")
 	case "cancel":
 		a.ui.ApprovalOutcome(false, "You did not approve running "+target+".")
 	}
@@ -3244,6 +3328,10 @@ func (a *terminalApprover) emitToolApprovalOutcome(req kernelpolicy.ToolAuthoriz
 		} else {
 			a.ui.ApprovalOutcome(true, "You approved this session for tool requests under "+scope+".")
 		}
+	case string(approvalgrant.ScopeWorkspace):
+		a.ui.ApprovalOutcome(true, "You approved "+target+" in this workspace. Use /approvals to revoke.")
+	case string(approvalgrant.ScopeGlobal):
+		a.ui.ApprovalOutcome(true, "You approved "+target+" in every workspace. Use /approvals to revoke.")
 	case "cancel":
 		a.ui.ApprovalOutcome(false, "You did not approve "+target+".")
 	}
@@ -3257,7 +3345,7 @@ func (a *terminalApprover) usesStructuredPrompts() bool {
 	return ok
 }
 
-func commandApprovalPromptRequest(req toolexec.ApprovalRequest, sessionKey string) tuievents.PromptRequestMsg {
+func commandApprovalPromptRequest(req toolexec.ApprovalRequest, sessionKey string, persist bool) tuievents.PromptRequestMsg {
 	details := make([]tuievents.PromptDetail, 0, 2)
 	if label, value := commandApprovalSummary(req); label != "" && value != "" {
 		details = append(details, tuievents.PromptDetail{Label: label, Value: value, Emphasis: true})
@@ -3269,12 +3357,12 @@ func commandApprovalPromptRequest(req toolexec.ApprovalRequest, sessionKey strin
 		Title:         commandApprovalTitle(),
 		Prompt:        commandApprovalTitle(),
 		Details:       details,
-		Choices:       approvalChoicesForSessionKey(sessionKey),
+		Choices:       approvalChoicesForSessionKey(sessionKey, persist),
 		DefaultChoice: "y",
 	}
 }
 
-func toolAuthorizationPromptRequest(req kernelpolicy.ToolAuthorizationRequest, scopeKey string) tuievents.PromptRequestMsg {
+func toolAuthorizationPromptRequest(req kernelpolicy.ToolAuthorizationRequest, scopeKey string, persist bool) tuievents.PromptRequestMsg {
 	details := make([]tuievents.PromptDetail, 0, 2)
 	if label, value := toolAuthorizationSummary(req); label != "" && value != "" {
 		details = append(details, tuievents.PromptDetail{Label: label, Value: value, Emphasis: true})
@@ -3287,7 +3375,7 @@ func toolAuthorizationPromptRequest(req kernelpolicy.ToolAuthorizationRequest, s
 		Title:         title,
 		Prompt:        title,
 		Details:       details,
-		Choices:       toolAuthorizationChoices(scopeKey),
+		Choices:       toolAuthorizationChoices(scopeKey, persist),
 		DefaultChoice: "y",
 	}
 }
@@ -3467,142 +3555,6 @@ func shortApprovalTarget(text string) string {
 	return strconv.Quote(text)
 }
 
-func isApprovalWrapperCommand(base string) bool {
-	switch strings.ToLower(strings.TrimSpace(base)) {
-	case "", "cd", "pwd", "export", "unset", "alias", "source", ".", "grep", "egrep", "fgrep", "head", "tail":
-		return true
-	default:
-		return false
-	}
-}
-
-func shellCommandSegments(command string) []string {
-	var (
-		segments []string
-		buf      strings.Builder
-		squote   bool
-		dquote   bool
-		escape   bool
-	)
-	flush := func() {
-		part := strings.TrimSpace(buf.String())
-		if part != "" {
-			segments = append(segments, part)
-		}
-		buf.Reset()
-	}
-	runes := []rune(command)
-	for i := 0; i < len(runes); i++ {
-		r := runes[i]
-		if escape {
-			buf.WriteRune(r)
-			escape = false
-			continue
-		}
-		switch r {
-		case '\\':
-			escape = true
-			buf.WriteRune(r)
-		case '\'':
-			if !dquote {
-				squote = !squote
-			}
-			buf.WriteRune(r)
-		case '"':
-			if !squote {
-				dquote = !dquote
-			}
-			buf.WriteRune(r)
-		case ';':
-			if squote || dquote {
-				buf.WriteRune(r)
-				continue
-			}
-			flush()
-		case '&':
-			if squote || dquote {
-				buf.WriteRune(r)
-				continue
-			}
-			if i+1 < len(runes) && runes[i+1] == '&' {
-				flush()
-				i++
-				continue
-			}
-			buf.WriteRune(r)
-		case '|':
-			if squote || dquote {
-				buf.WriteRune(r)
-				continue
-			}
-			flush()
-			if i+1 < len(runes) && runes[i+1] == '|' {
-				i++
-			}
-		default:
-			buf.WriteRune(r)
-		}
-	}
-	flush()
-	return segments
-}
-
-func shellSegmentTokens(segment string) []string {
-	var (
-		tokens []string
-		buf    strings.Builder
-		squote bool
-		dquote bool
-		escape bool
-	)
-	flush := func() {
-		token := strings.TrimSpace(buf.String())
-		if token == "" {
-			buf.Reset()
-			return
-		}
-		if strings.Contains(token, "=") && !strings.HasPrefix(token, "=") && len(tokens) == 0 {
-			buf.Reset()
-			return
-		}
-		tokens = append(tokens, token)
-		buf.Reset()
-	}
-	for _, r := range segment {
-		if escape {
-			buf.WriteRune(r)
-			escape = false
-			continue
-		}
-		switch r {
-		case '\\':
-			escape = true
-		case '\'':
-			if !dquote {
-				squote = !squote
-				continue
-			}
-			buf.WriteRune(r)
-		case '"':
-			if !squote {
-				dquote = !dquote
-				continue
-			}
-			buf.WriteRune(r)
-		case ' ', '\t', '\n':
-			if squote || dquote {
-				buf.WriteRune(r)
-				continue
-			}
-			flush()
-		default:
-			buf.WriteRune(r)
-		}
-	}
-	flush()
-	return tokens
-}
-
 func (c *cliConsole) addPendingAttachment(part model.ContentPart) {
 	c.pendingAttachmentMu.Lock()
 	defer c.pendingAttachmentMu.Unlock()
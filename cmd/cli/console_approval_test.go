@@ -8,6 +8,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/OnslaughtSnail/caelis/internal/approvalgrant"
 	"github.com/OnslaughtSnail/caelis/internal/cli/tuievents"
 	toolexec "github.com/OnslaughtSnail/caelis/kernel/execenv"
 	kernelpolicy "github.com/OnslaughtSnail/caelis/kernel/policy"
@@ -523,7 +524,7 @@ func TestCommandApprovalPromptRequestOmitsGenericReasonAndPermission(t *testing.
 		Action:   "execute_command",
 		Reason:   "require_escalated requested",
 		Command:  "pfctl -s info",
-	}, "pfctl")
+	}, "pfctl", false)
 	if len(req.Details) != 1 {
 		t.Fatalf("expected exactly one detail, got %+v", req.Details)
 	}
@@ -538,7 +539,7 @@ func TestCommandApprovalPromptRequestTruncatesLongCommandPreview(t *testing.T) {
 		ToolName: "BASH",
 		Command:  command,
 		Reason:   strings.Repeat("host escalation required because this command mutates files ", 8),
-	}, "cat")
+	}, "cat", false)
 	if len(req.Details) != 2 {
 		t.Fatalf("expected command and reason details, got %+v", req.Details)
 	}
@@ -552,3 +553,43 @@ func TestCommandApprovalPromptRequestTruncatesLongCommandPreview(t *testing.T) {
 		t.Fatalf("expected reason to be compact, got len=%d value=%q", got, req.Details[1].Value)
 	}
 }
+
+func TestTerminalApprover_WorkspaceChoicePersistsGrant(t *testing.T) {
+	grants := approvalgrant.NewStore(nil)
+	editor := &stubChoiceEditor{response: "w"}
+	approver := newTerminalApprover(editor, io.Discard, nil)
+	approver.grants = grants
+	approver.workspace = "/repo"
+
+	allowed, err := approver.Approve(context.Background(), toolexec.ApprovalRequest{
+		ToolName: "BASH",
+		Command:  "go build ./...",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !allowed {
+		t.Fatal("expected approval to succeed")
+	}
+	labels := make([]string, 0, len(editor.lastChoices))
+	for _, choice := range editor.lastChoices {
+		labels = append(labels, choice.Label)
+	}
+	if strings.Join(labels, ",") != "approve,always,workspace,global,reject" {
+		t.Fatalf("unexpected approval choices %v", labels)
+	}
+	if got := grants.List("/repo"); len(got) != 1 || got[0].Key != "go build" || got[0].Scope != approvalgrant.ScopeWorkspace {
+		t.Fatalf("expected stored workspace grant, got %#v", got)
+	}
+
+	next := newTerminalApprover(&stubChoiceEditor{response: "n"}, io.Discard, nil)
+	next.grants = grants
+	next.workspace = "/repo"
+	allowed, err = next.Approve(context.Background(), toolexec.ApprovalRequest{
+		ToolName: "BASH",
+		Command:  "go build -o bin/app .",
+	})
+	if err != nil || !allowed {
+		t.Fatalf("expected stored grant to approve without prompting, allowed=%v err=%v", allowed, err)
+	}
+}
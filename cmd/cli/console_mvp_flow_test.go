@@ -34,7 +34,7 @@ func newConsoleFlowAdapterFactory(rt *runtime.Runtime, store session.Store, exec
 			NewAgent: func(bool, string, string, internalacp.AgentSessionConfig) (agent.Agent, error) {
 				return ag, nil
 			},
-			NewSessionResources: func(_ context.Context, sessionID string, sessionCWD string, caps internalacp.ClientCapabilities, modeResolver func() string) (*internalacp.SessionResources, error) {
+			NewSessionResources: func(_ context.Context, sessionID string, sessionCWD string, caps internalacp.ClientCapabilities, mcpServers []internalacp.MCPServer, modeResolver func() string) (*internalacp.SessionResources, error) {
 				return &internalacp.SessionResources{
 					Runtime: internalacp.NewRuntime(execRT, conn, sessionID, "/workspace", sessionCWD, caps, modeResolver),
 				}, nil
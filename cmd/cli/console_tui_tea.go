@@ -293,6 +293,8 @@ func (c *cliConsole) loopTUITea(ctx context.Context) error {
 	c.prompter = promptBroker
 	c.approver = newTerminalApprover(c.prompter, writer, c.ui)
 	c.approver.modeResolver = func() string { return c.currentApprovalMode() }
+	c.approver.grants = c.configStore.ApprovalGrantStore()
+	c.approver.workspace = c.workspace.CWD
 	if c.tuiDiag != nil {
 		c.tuiDiag.SetRedrawMode("fullscreen")
 	}
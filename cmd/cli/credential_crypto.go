@@ -0,0 +1,220 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/term"
+)
+
+const (
+	credentialPassphraseEnv = "CAELIS_CREDENTIALS_PASSPHRASE"
+	credentialKeyFileEnv    = "CAELIS_CREDENTIALS_KEY_FILE"
+
+	encryptedCredentialFileSuffix = "_credentials.enc.json"
+	encryptedCredentialKDF        = "scrypt"
+	encryptedCredentialCipher     = "nacl-secretbox"
+
+	credentialScryptN = 1 << 15
+	credentialScryptR = 8
+	credentialScryptP = 1
+
+	// The envelope names its own scrypt cost, so a tampered file could ask
+	// for any amount of work. These bounds sit well above the defaults and
+	// keep key derivation within 256 MiB.
+	maxCredentialScryptN      = 1 << 20
+	maxCredentialScryptR      = 32
+	maxCredentialScryptP      = 16
+	maxCredentialScryptMemory = 256 << 20
+)
+
+// encryptedCredentialFile is the on-disk envelope of an encrypted credential
+// store. Box holds the sealed credentialFile JSON.
+type encryptedCredentialFile struct {
+	Version int    `json:"version"`
+	KDF     string `json:"kdf"`
+	Cipher  string `json:"cipher"`
+	Salt    []byte `json:"salt"`
+	N       int    `json:"n"`
+	R       int    `json:"r"`
+	P       int    `json:"p"`
+	Nonce   []byte `json:"nonce"`
+	Box     []byte `json:"box"`
+}
+
+// credentialCipher seals credential files with a key derived once from the
+// passphrase, so saves do not pay for the KDF again.
+type credentialCipher struct {
+	salt    []byte
+	n, r, p int
+	key     [32]byte
+}
+
+func newCredentialCipher(passphrase string) (*credentialCipher, error) {
+	salt := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, fmt.Errorf("credential store: generate salt: %w", err)
+	}
+	return deriveCredentialCipher(passphrase, salt, credentialScryptN, credentialScryptR, credentialScryptP)
+}
+
+func deriveCredentialCipher(passphrase string, salt []byte, n, r, p int) (*credentialCipher, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("credential store: passphrase is empty")
+	}
+	derived, err := scrypt.Key([]byte(passphrase), salt, n, r, p, 32)
+	if err != nil {
+		return nil, fmt.Errorf("credential store: derive key: %w", err)
+	}
+	c := &credentialCipher{salt: salt, n: n, r: r, p: p}
+	copy(c.key[:], derived)
+	return c, nil
+}
+
+func (c *credentialCipher) seal(plain []byte) ([]byte, error) {
+	var nonce [24]byte
+	if _, err := io.ReadFull(rand.Reader, nonce[:]); err != nil {
+		return nil, fmt.Errorf("credential store: generate nonce: %w", err)
+	}
+	envelope := encryptedCredentialFile{
+		Version: credentialFileVersion,
+		KDF:     encryptedCredentialKDF,
+		Cipher:  encryptedCredentialCipher,
+		Salt:    c.salt,
+		N:       c.n,
+		R:       c.r,
+		P:       c.p,
+		Nonce:   nonce[:],
+		Box:     secretbox.Seal(nil, plain, &nonce, &c.key),
+	}
+	raw, err := json.MarshalIndent(envelope, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("credential store: marshal: %w", err)
+	}
+	return append(raw, '\n'), nil
+}
+
+// openEncryptedCredentialFile decrypts raw and returns the plaintext together
+// with a cipher that re-seals under the same salt.
+func openEncryptedCredentialFile(raw []byte, passphrase string) ([]byte, *credentialCipher, error) {
+	var envelope encryptedCredentialFile
+	if err := json.Unmarshal(raw, &envelope); err != nil {
+		return nil, nil, fmt.Errorf("credential store: parse encrypted file: %w", err)
+	}
+	if envelope.KDF != encryptedCredentialKDF || envelope.Cipher != encryptedCredentialCipher {
+		return nil, nil, fmt.Errorf("credential store: unsupported encryption %s/%s", envelope.KDF, envelope.Cipher)
+	}
+	if len(envelope.Nonce) != 24 {
+		return nil, nil, fmt.Errorf("credential store: malformed nonce")
+	}
+	if err := checkCredentialScryptParams(envelope.N, envelope.R, envelope.P); err != nil {
+		return nil, nil, err
+	}
+	c, err := deriveCredentialCipher(passphrase, envelope.Salt, envelope.N, envelope.R, envelope.P)
+	if err != nil {
+		return nil, nil, err
+	}
+	var nonce [24]byte
+	copy(nonce[:], envelope.Nonce)
+	plain, ok := secretbox.Open(nil, envelope.Box, &nonce, &c.key)
+	if !ok {
+		return nil, nil, fmt.Errorf("credential store: wrong passphrase or corrupted file")
+	}
+	return plain, c, nil
+}
+
+// checkCredentialScryptParams rejects scrypt costs that are malformed or
+// larger than any this store writes.
+func checkCredentialScryptParams(n, r, p int) error {
+	if n < 2 || n > maxCredentialScryptN || n&(n-1) != 0 {
+		return fmt.Errorf("credential store: unsupported scrypt N=%d", n)
+	}
+	if r < 1 || r > maxCredentialScryptR || p < 1 || p > maxCredentialScryptP {
+		return fmt.Errorf("credential store: unsupported scrypt r=%d p=%d", r, p)
+	}
+	if 128*n*r > maxCredentialScryptMemory {
+		return fmt.Errorf("credential store: scrypt N=%d r=%d needs too much memory", n, r)
+	}
+	return nil
+}
+
+func encryptedCredentialPath(appName string) (string, error) {
+	root, err := appDataDir(appName)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, normalizedAppName(appName)+encryptedCredentialFileSuffix), nil
+}
+
+// credentialPassphraseFromEnv returns the passphrase held by the passphrase
+// env var or the key file it names, if either is set.
+func credentialPassphraseFromEnv() (string, bool, error) {
+	if value := normalizeCredentialPassphrase(os.Getenv(credentialPassphraseEnv)); value != "" {
+		return value, true, nil
+	}
+	path := strings.TrimSpace(os.Getenv(credentialKeyFileEnv))
+	if path == "" {
+		return "", false, nil
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return "", false, fmt.Errorf("credential store: read key file: %w", err)
+	}
+	key := normalizeCredentialPassphrase(string(raw))
+	if key == "" {
+		return "", false, fmt.Errorf("credential store: key file %q is empty", path)
+	}
+	return key, true, nil
+}
+
+// credentialPassphrase unlocks the encrypted store from the environment, or
+// from a terminal prompt when stdin is interactive. With confirm set, as when
+// the store is first created, a typed passphrase must be entered twice.
+func credentialPassphrase(confirm bool) (string, error) {
+	passphrase, ok, err := credentialPassphraseFromEnv()
+	if err != nil || ok {
+		return passphrase, err
+	}
+	if !isTTY(os.Stdin) {
+		return "", fmt.Errorf("credential store is encrypted; set %s or %s", credentialPassphraseEnv, credentialKeyFileEnv)
+	}
+	passphrase, err = readTerminalSecret("Credential store passphrase: ")
+	if err != nil || !confirm {
+		return passphrase, err
+	}
+	again, err := readTerminalSecret("Repeat passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if again != passphrase {
+		return "", fmt.Errorf("credential store: passphrases do not match")
+	}
+	return passphrase, nil
+}
+
+func readTerminalSecret(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	raw, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("credential store: read passphrase: %w", err)
+	}
+	passphrase := normalizeCredentialPassphrase(string(raw))
+	if passphrase == "" {
+		return "", fmt.Errorf("credential store: passphrase is empty")
+	}
+	return passphrase, nil
+}
+
+// normalizeCredentialPassphrase trims a passphrase the same way wherever it
+// is entered, so every path derives the same key.
+func normalizeCredentialPassphrase(raw string) string {
+	return strings.TrimSpace(raw)
+}
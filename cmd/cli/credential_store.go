@@ -17,6 +17,7 @@ const (
 	credentialStoreModeAuto      = "auto"
 	credentialStoreModeFile      = "file"
 	credentialStoreModeEphemeral = "ephemeral"
+	credentialStoreModeEncrypted = "encrypted"
 
 	defaultCredentialStoreMode = credentialStoreModeAuto
 	credentialFileVersion      = 1
@@ -39,6 +40,8 @@ type credentialFile struct {
 type credentialStore struct {
 	path string
 	data credentialFile
+	// cipher seals the file at rest; nil keeps the plaintext format.
+	cipher *credentialCipher
 }
 
 func normalizeCredentialStoreMode(input string) string {
@@ -49,6 +52,8 @@ func normalizeCredentialStoreMode(input string) string {
 		return credentialStoreModeFile
 	case credentialStoreModeEphemeral:
 		return credentialStoreModeEphemeral
+	case credentialStoreModeEncrypted:
+		return credentialStoreModeEncrypted
 	default:
 		return credentialStoreModeAuto
 	}
@@ -61,6 +66,19 @@ func loadOrInitCredentialStore(appName, mode string) (*credentialStore, error) {
 		return nil, nil
 	}
 
+	encPath, err := encryptedCredentialPath(appName)
+	if err != nil {
+		return nil, err
+	}
+	if mode == credentialStoreModeEncrypted {
+		return loadOrInitEncryptedCredentialStore(encPath)
+	}
+	if mode == credentialStoreModeAuto {
+		if _, err := os.Stat(encPath); err == nil {
+			return loadOrInitEncryptedCredentialStore(encPath)
+		}
+	}
+
 	path, err := credentialPath(appName)
 	if err != nil {
 		return nil, err
@@ -83,9 +101,61 @@ func loadOrInitCredentialStore(appName, mode string) (*credentialStore, error) {
 		}
 		return store, nil
 	}
+	if err := store.decode(raw); err != nil {
+		return nil, err
+	}
+	if err := ensureFilePermission(path, 0o600); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// loadOrInitEncryptedCredentialStore opens the encrypted store at path,
+// creating it under a fresh passphrase when it does not exist yet.
+func loadOrInitEncryptedCredentialStore(path string) (*credentialStore, error) {
+	store := &credentialStore{
+		path: path,
+		data: credentialFile{
+			Version:     credentialFileVersion,
+			Credentials: map[string]credentialRecord{},
+		},
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("credential store: read %q: %w", path, err)
+	}
+	creating := err != nil
+	passphrase, err := credentialPassphrase(creating)
+	if err != nil {
+		return nil, err
+	}
+	if creating {
+		if store.cipher, err = newCredentialCipher(passphrase); err != nil {
+			return nil, err
+		}
+		if err := store.save(); err != nil {
+			return nil, err
+		}
+		return store, nil
+	}
+	plain, cipher, err := openEncryptedCredentialFile(raw, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("%w (%s)", err, path)
+	}
+	store.cipher = cipher
+	if err := store.decode(plain); err != nil {
+		return nil, err
+	}
+	if err := ensureFilePermission(path, 0o600); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+func (s *credentialStore) decode(raw []byte) error {
 	var loaded credentialFile
 	if err := json.Unmarshal(raw, &loaded); err != nil {
-		return nil, fmt.Errorf("credential store: parse %q: %w", path, err)
+		return fmt.Errorf("credential store: parse %q: %w", s.path, err)
 	}
 	if loaded.Version <= 0 {
 		loaded.Version = credentialFileVersion
@@ -93,11 +163,55 @@ func loadOrInitCredentialStore(appName, mode string) (*credentialStore, error) {
 	if loaded.Credentials == nil {
 		loaded.Credentials = map[string]credentialRecord{}
 	}
-	store.data = loaded
-	if err := ensureFilePermission(path, 0o600); err != nil {
-		return nil, err
+	s.data = loaded
+	return nil
+}
+
+// Encrypted reports whether the store is sealed at rest.
+func (s *credentialStore) Encrypted() bool {
+	return s != nil && s.cipher != nil
+}
+
+// Path returns the file backing the store.
+func (s *credentialStore) Path() string {
+	if s == nil {
+		return ""
 	}
-	return store, nil
+	return s.path
+}
+
+// Len returns the number of stored credentials.
+func (s *credentialStore) Len() int {
+	if s == nil {
+		return 0
+	}
+	return len(s.data.Credentials)
+}
+
+// MigrateToEncrypted re-seals a plaintext store at encPath under passphrase and
+// removes the plaintext file. The store switches over in place, so every
+// holder keeps writing to the encrypted file afterwards.
+func (s *credentialStore) MigrateToEncrypted(encPath, passphrase string) error {
+	if s == nil {
+		return fmt.Errorf("credential store: persistence is disabled")
+	}
+	if s.cipher != nil {
+		return fmt.Errorf("credential store: %q is already encrypted", s.path)
+	}
+	cipher, err := newCredentialCipher(passphrase)
+	if err != nil {
+		return err
+	}
+	plainPath := s.path
+	s.path, s.cipher = encPath, cipher
+	if err := s.save(); err != nil {
+		s.path, s.cipher = plainPath, nil
+		return err
+	}
+	if err := os.Remove(plainPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("credential store: remove plaintext file %q: %w", plainPath, err)
+	}
+	return nil
 }
 
 func (s *credentialStore) Upsert(ref string, record credentialRecord) error {
@@ -179,6 +293,11 @@ func (s *credentialStore) save() error {
 		return fmt.Errorf("credential store: marshal: %w", err)
 	}
 	raw = append(raw, '\n')
+	if s.cipher != nil {
+		if raw, err = s.cipher.seal(raw); err != nil {
+			return err
+		}
+	}
 	tmp := s.path + ".tmp"
 	if err := os.WriteFile(tmp, raw, 0o600); err != nil {
 		return fmt.Errorf("credential store: write tmp: %w", err)
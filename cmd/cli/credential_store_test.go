@@ -1,8 +1,11 @@
 package main
 
 import (
+	"encoding/json"
 	"os"
+	"path/filepath"
 	"runtime"
+	"strings"
 	"testing"
 
 	modelproviders "github.com/OnslaughtSnail/caelis/kernel/model/providers"
@@ -145,3 +148,137 @@ func TestHydrateProviderAuthToken_PrefersCredentialRef(t *testing.T) {
 		t.Fatalf("expected credential_ref token to win, got %q", hydrated.Auth.Token)
 	}
 }
+
+func TestCredentialStore_EncryptedModeSealsTokens(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(credentialPassphraseEnv, "correct horse")
+
+	store, err := loadOrInitCredentialStore("demo-app", credentialStoreModeEncrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !store.Encrypted() {
+		t.Fatal("expected encrypted store")
+	}
+	if err := store.Upsert("openai", credentialRecord{Token: "secret-token"}); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := os.ReadFile(store.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(raw), "secret-token") {
+		t.Fatalf("expected token to be sealed, got %s", raw)
+	}
+	plainPath, err := credentialPath("demo-app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(plainPath); !os.IsNotExist(err) {
+		t.Fatalf("expected no plaintext credential file, stat err=%v", err)
+	}
+
+	reopened, err := loadOrInitCredentialStore("demo-app", credentialStoreModeEncrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := reopened.Get("openai"); !ok || got.Token != "secret-token" {
+		t.Fatalf("unexpected credential after reopen: %+v ok=%v", got, ok)
+	}
+
+	t.Setenv(credentialPassphraseEnv, "")
+	keyFile := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(keyFile, []byte("wrong\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(credentialKeyFileEnv, keyFile)
+	if _, err := loadOrInitCredentialStore("demo-app", credentialStoreModeAuto); err == nil || !strings.Contains(err.Error(), "wrong passphrase") {
+		t.Fatalf("expected wrong passphrase error, got %v", err)
+	}
+}
+
+func TestCredentialStore_MigrateToEncrypted(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(credentialPassphraseEnv, "")
+	t.Setenv(credentialKeyFileEnv, "")
+
+	store, err := loadOrInitCredentialStore("demo-app", credentialStoreModeAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Upsert("openai", credentialRecord{Token: "secret-token"}); err != nil {
+		t.Fatal(err)
+	}
+	plainPath := store.Path()
+	encPath, err := encryptedCredentialPath("demo-app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.MigrateToEncrypted(encPath, "correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(plainPath); !os.IsNotExist(err) {
+		t.Fatalf("expected plaintext file to be removed, stat err=%v", err)
+	}
+	if err := store.Upsert("anthropic", credentialRecord{Token: "other-token"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.MigrateToEncrypted(encPath, "correct horse"); err == nil {
+		t.Fatal("expected second migration to fail")
+	}
+
+	// Auto mode picks up the encrypted file once it exists.
+	t.Setenv(credentialPassphraseEnv, "correct horse")
+	reopened, err := loadOrInitCredentialStore("demo-app", credentialStoreModeAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reopened.Encrypted() || reopened.Len() != 2 {
+		t.Fatalf("expected encrypted store with 2 credentials, got encrypted=%v len=%d", reopened.Encrypted(), reopened.Len())
+	}
+	if _, err := os.Stat(plainPath); !os.IsNotExist(err) {
+		t.Fatalf("expected auto mode not to recreate plaintext file, stat err=%v", err)
+	}
+}
+
+func TestOpenEncryptedCredentialFile_RejectsTamperedScryptParams(t *testing.T) {
+	cipher, err := newCredentialCipher("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := cipher.seal([]byte(`{"version":1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct{ n, r, p int }{
+		{n: 3 << 14, r: 8, p: 1},
+		{n: 1 << 30, r: 8, p: 1},
+		{n: 1 << 20, r: 32, p: 1},
+		{n: 1 << 15, r: 0, p: 1},
+		{n: 1 << 15, r: 8, p: 1 << 20},
+	} {
+		var envelope map[string]any
+		if err := json.Unmarshal(sealed, &envelope); err != nil {
+			t.Fatal(err)
+		}
+		envelope["n"], envelope["r"], envelope["p"] = tc.n, tc.r, tc.p
+		raw, err := json.Marshal(envelope)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := openEncryptedCredentialFile(raw, "correct horse"); err == nil || !strings.Contains(err.Error(), "scrypt") {
+			t.Fatalf("expected N=%d r=%d p=%d to be rejected, got %v", tc.n, tc.r, tc.p, err)
+		}
+	}
+	if _, _, err := openEncryptedCredentialFile(sealed, "correct horse"); err != nil {
+		t.Fatalf("expected the untampered file to open, got %v", err)
+	}
+}
+
+func TestCredentialPassphraseFromEnv_TrimsLikeThePrompt(t *testing.T) {
+	t.Setenv(credentialPassphraseEnv, "  correct horse\n")
+	passphrase, ok, err := credentialPassphraseFromEnv()
+	if err != nil || !ok || passphrase != "correct horse" {
+		t.Fatalf("expected the trimmed passphrase, got %q ok=%v err=%v", passphrase, ok, err)
+	}
+}
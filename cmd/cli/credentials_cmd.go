@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+const credentialsUsage = "usage: /credentials [status|encrypt]"
+
+func handleCredentials(c *cliConsole, args []string) (bool, error) {
+	if len(args) == 0 || (len(args) == 1 && strings.EqualFold(strings.TrimSpace(args[0]), "status")) {
+		c.printCredentialStoreStatus()
+		return false, nil
+	}
+	if len(args) != 1 || !strings.EqualFold(strings.TrimSpace(args[0]), "encrypt") {
+		return false, fmt.Errorf(credentialsUsage)
+	}
+	store := c.credentialStore
+	if store == nil {
+		return false, fmt.Errorf("credential store is ephemeral; nothing to encrypt")
+	}
+	if store.Encrypted() {
+		return false, fmt.Errorf("credential store is already encrypted")
+	}
+	passphrase, err := c.promptCredentialPassphrase()
+	if err != nil {
+		return false, err
+	}
+	encPath, err := encryptedCredentialPath(c.appName)
+	if err != nil {
+		return false, err
+	}
+	plainPath := store.Path()
+	if err := store.MigrateToEncrypted(encPath, passphrase); err != nil {
+		return false, err
+	}
+	if err := c.configStore.SetCredentialStoreMode(credentialStoreModeEncrypted); err != nil {
+		return false, err
+	}
+	c.printf("encrypted %d credential(s) into %s and removed %s\n", store.Len(), encPath, plainPath)
+	c.printf("unlock it with %s, %s or the startup prompt\n", credentialPassphraseEnv, credentialKeyFileEnv)
+	return false, nil
+}
+
+// promptCredentialPassphrase takes the passphrase from the environment when
+// set, otherwise asks for it twice.
+func (c *cliConsole) promptCredentialPassphrase() (string, error) {
+	passphrase, ok, err := credentialPassphraseFromEnv()
+	if err != nil || ok {
+		return passphrase, err
+	}
+	passphrase, err = c.promptText("passphrase", "", true)
+	if err != nil {
+		return "", err
+	}
+	passphrase = normalizeCredentialPassphrase(passphrase)
+	if passphrase == "" {
+		return "", fmt.Errorf("passphrase is empty")
+	}
+	again, err := c.promptText("repeat passphrase", "", true)
+	if err != nil {
+		return "", err
+	}
+	if normalizeCredentialPassphrase(again) != passphrase {
+		return "", fmt.Errorf("passphrases do not match")
+	}
+	return passphrase, nil
+}
+
+func (c *cliConsole) printCredentialStoreStatus() {
+	store := c.credentialStore
+	c.ui.Section("Credentials")
+	c.ui.Plain("  mode       %s\n", c.configStore.CredentialStoreMode())
+	if store == nil {
+		c.ui.Plain("  storage    memory only\n")
+		return
+	}
+	format := "plaintext (file permissions only)"
+	if store.Encrypted() {
+		format = "encrypted (scrypt + secretbox)"
+	}
+	c.ui.Plain("  file       %s\n", store.Path())
+	c.ui.Plain("  format     %s\n", format)
+	c.ui.Plain("  stored     %d\n", store.Len())
+	if !store.Encrypted() {
+		c.ui.Plain("  run /credentials encrypt to seal it with a passphrase\n")
+	}
+}
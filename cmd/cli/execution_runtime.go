@@ -15,6 +15,7 @@ var cliSandboxSelector = toolexec.SelectSandbox
 type swappableRuntime struct {
 	mu      sync.RWMutex
 	current toolexec.Runtime
+	overlay *toolexec.Overlay
 }
 
 func newSwappableRuntime(rt toolexec.Runtime) *swappableRuntime {
@@ -39,6 +40,26 @@ func (r *swappableRuntime) Current() toolexec.Runtime {
 	return r.current
 }
 
+// SetOverlay routes file tool writes and sandboxed commands into overlay,
+// or back to the workspace when overlay is nil.
+func (r *swappableRuntime) SetOverlay(overlay *toolexec.Overlay) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	r.overlay = overlay
+	r.mu.Unlock()
+}
+
+func (r *swappableRuntime) Overlay() *toolexec.Overlay {
+	if r == nil {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.overlay
+}
+
 func (r *swappableRuntime) PermissionMode() toolexec.PermissionMode {
 	if current := r.Current(); current != nil {
 		return current.PermissionMode()
@@ -89,14 +110,21 @@ func (r *swappableRuntime) State() toolexec.RuntimeState {
 }
 
 func (r *swappableRuntime) FileSystem() toolexec.FileSystem {
-	if current := r.Current(); current != nil {
-		return current.FileSystem()
+	current := r.Current()
+	if current == nil {
+		return nil
 	}
-	return nil
+	if overlay := r.Overlay(); overlay != nil {
+		return overlay.FileSystem(current.FileSystem())
+	}
+	return current.FileSystem()
 }
 
 func (r *swappableRuntime) Execute(ctx context.Context, req toolexec.CommandRequest) (toolexec.CommandResult, error) {
 	if current := r.Current(); current != nil {
+		if overlay := r.Overlay(); overlay != nil {
+			req.Overlay = overlay
+		}
 		return current.Execute(ctx, req)
 	}
 	return toolexec.CommandResult{}, fmt.Errorf("runtime unavailable")
@@ -104,6 +132,9 @@ func (r *swappableRuntime) Execute(ctx context.Context, req toolexec.CommandRequ
 
 func (r *swappableRuntime) Start(ctx context.Context, req toolexec.CommandRequest) (toolexec.Session, error) {
 	if current := r.Current(); current != nil {
+		if overlay := r.Overlay(); overlay != nil {
+			req.Overlay = overlay
+		}
 		return current.Start(ctx, req)
 	}
 	return nil, fmt.Errorf("runtime unavailable")
@@ -116,6 +147,36 @@ func (r *swappableRuntime) OpenSession(ref toolexec.CommandSessionRef) (toolexec
 	return nil, fmt.Errorf("runtime unavailable")
 }
 
+func (r *swappableRuntime) AllowNetworkHosts(hosts ...string) {
+	if updater, ok := r.Current().(toolexec.NetworkAllowlistUpdater); ok {
+		updater.AllowNetworkHosts(hosts...)
+	}
+}
+
+func (r *swappableRuntime) PersistentShellEnabled() bool {
+	// A long-lived shell would keep writing the workspace after staging
+	// starts, so staged commands each get a fresh sandbox instead.
+	if r.Overlay() != nil {
+		return false
+	}
+	runner, ok := r.Current().(toolexec.PersistentShellRunner)
+	return ok && runner.PersistentShellEnabled()
+}
+
+func (r *swappableRuntime) RunInShell(ctx context.Context, key string, req toolexec.CommandRequest) (toolexec.ShellResult, error) {
+	if runner, ok := r.Current().(toolexec.PersistentShellRunner); ok {
+		return runner.RunInShell(ctx, key, req)
+	}
+	return toolexec.ShellResult{}, fmt.Errorf("runtime unavailable")
+}
+
+func (r *swappableRuntime) StartInShell(ctx context.Context, key string, req toolexec.CommandRequest) (toolexec.ShellSession, error) {
+	if runner, ok := r.Current().(toolexec.PersistentShellRunner); ok {
+		return runner.StartInShell(ctx, key, req)
+	}
+	return nil, fmt.Errorf("runtime unavailable")
+}
+
 func (r *swappableRuntime) Decide(ctx context.Context, req toolexec.RouteRequest) (toolexec.CommandDecision, error) {
 	_ = ctx
 	if current := r.Current(); current != nil {
@@ -132,12 +193,15 @@ func (r *swappableRuntime) DecideRoute(command string, sandboxPermission toolexe
 	return decision
 }
 
-func newExecutionRuntime(mode toolexec.PermissionMode, sandboxType string, sandboxHelperPath string, sandboxPolicy toolexec.SandboxPolicy) (toolexec.Runtime, error) {
+func newExecutionRuntime(mode toolexec.PermissionMode, sandboxType string, sandboxHelperPath string, sandboxPolicy toolexec.SandboxPolicy, resourceLimits toolexec.ResourceLimits, persistentShell bool, ssh *toolexec.SSHConfig) (toolexec.Runtime, error) {
 	return cliExecRuntimeBuilder(toolexec.Config{
 		PermissionMode:    mode,
 		SandboxType:       normalizeSandboxType(strings.TrimSpace(sandboxType)),
 		SandboxPolicy:     sandboxPolicy,
 		SandboxHelperPath: strings.TrimSpace(sandboxHelperPath),
+		ResourceLimits:    resourceLimits,
+		PersistentShell:   persistentShell,
+		SSH:               ssh,
 	})
 }
 
@@ -42,7 +42,7 @@ func TestNewExecutionRuntime_PassesSandboxHelperPath(t *testing.T) {
 		return fakeRuntime{permissionMode: cfg.PermissionMode, sandboxType: cfg.SandboxType}, nil
 	}
 
-	_, err := newExecutionRuntime(toolexec.PermissionModeDefault, "landlock", "/tmp/helper", toolexec.SandboxPolicy{})
+	_, err := newExecutionRuntime(toolexec.PermissionModeDefault, "landlock", "/tmp/helper", toolexec.SandboxPolicy{}, toolexec.ResourceLimits{}, false, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -63,7 +63,7 @@ func TestNewExecutionRuntime_NormalizesAutoSandboxSelection(t *testing.T) {
 		return fakeRuntime{permissionMode: cfg.PermissionMode, sandboxType: cfg.SandboxType}, nil
 	}
 
-	_, err := newExecutionRuntime(toolexec.PermissionModeDefault, "auto", "/tmp/helper", toolexec.SandboxPolicy{})
+	_, err := newExecutionRuntime(toolexec.PermissionModeDefault, "auto", "/tmp/helper", toolexec.SandboxPolicy{}, toolexec.ResourceLimits{}, false, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -89,7 +89,7 @@ func TestNewExecutionRuntime_PassesSandboxPolicy(t *testing.T) {
 		return fakeRuntime{permissionMode: cfg.PermissionMode, sandboxType: cfg.SandboxType}, nil
 	}
 
-	_, err := newExecutionRuntime(toolexec.PermissionModeDefault, "landlock", "/tmp/helper", want)
+	_, err := newExecutionRuntime(toolexec.PermissionModeDefault, "landlock", "/tmp/helper", want, toolexec.ResourceLimits{}, false, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
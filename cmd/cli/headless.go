@@ -5,11 +5,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"os"
 	"strings"
 
 	toolexec "github.com/OnslaughtSnail/caelis/kernel/execenv"
 	"github.com/OnslaughtSnail/caelis/kernel/model"
 	"github.com/OnslaughtSnail/caelis/kernel/sessionsvc"
+	"github.com/OnslaughtSnail/caelis/kernel/tokenusage"
 )
 
 type headlessOutputFormat string
@@ -23,6 +25,10 @@ type headlessRunResult struct {
 	SessionID    string `json:"session_id"`
 	Output       string `json:"output"`
 	PromptTokens int    `json:"prompt_tokens,omitempty"`
+	// Usage is the token usage and cost of this run across all model calls.
+	Usage *tokenusage.Totals `json:"usage,omitempty"`
+	// Structured is the validated answer when -output-schema is set.
+	Structured json.RawMessage `json:"structured,omitempty"`
 }
 
 func parseHeadlessOutputFormat(raw string) (headlessOutputFormat, error) {
@@ -36,6 +42,42 @@ func parseHeadlessOutputFormat(raw string) (headlessOutputFormat, error) {
 	}
 }
 
+// loadOutputSchemaFile reads the JSON Schema passed with -output-schema.
+func loadOutputSchemaFile(path string) (*model.OutputSpec, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		//nolint:nilnil // No schema means unstructured output.
+		return nil, nil
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read output schema: %w", err)
+	}
+	var schema map[string]any
+	if err := json.Unmarshal(raw, &schema); err != nil {
+		return nil, fmt.Errorf("parse output schema %q: %w", path, err)
+	}
+	if len(schema) == 0 {
+		return nil, fmt.Errorf("output schema %q is empty", path)
+	}
+	return &model.OutputSpec{Mode: model.OutputModeSchema, JSONSchema: schema}, nil
+}
+
+// applyOutputSchema checks the final answer against spec and replaces it with
+// the compact JSON value.
+func applyOutputSchema(result *headlessRunResult, spec *model.OutputSpec) error {
+	if result == nil || !spec.Structured() {
+		return nil
+	}
+	structured, err := spec.ValidateOutput(result.Output)
+	if err != nil {
+		return err
+	}
+	result.Output = string(structured)
+	result.Structured = structured
+	return nil
+}
+
 func resolveSingleShotInput(
 	promptFlag string,
 	stdin io.Reader,
@@ -74,6 +116,7 @@ func runHeadlessOnce(ctx context.Context, svc *sessionsvc.Service, req sessionsv
 		lastAssistant string
 		answerPartial strings.Builder
 		promptTokens  int
+		usage         tokenusage.State
 	)
 	runResult, err := svc.RunTurn(ctx, req)
 	if err != nil {
@@ -106,6 +149,10 @@ func runHeadlessOnce(ctx context.Context, svc *sessionsvc.Service, req sessionsv
 		if msg.Role != model.RoleAssistant {
 			continue
 		}
+		if provider, modelName, one, ok := tokenusage.FromEventMeta(ev.Meta); ok && !eventIsPartial(ev) {
+			cost, priced := estimateCatalogModelCost(provider, modelName, one)
+			usage.Record("", provider, modelName, one, cost, priced)
+		}
 		if eventIsPartial(ev) {
 			if eventChannel(ev) == "answer" {
 				answerPartial.WriteString(msg.TextContent())
@@ -122,11 +169,15 @@ func runHeadlessOnce(ctx context.Context, svc *sessionsvc.Service, req sessionsv
 	if strings.TrimSpace(lastAssistant) == "" {
 		lastAssistant = strings.TrimSpace(answerPartial.String())
 	}
-	return headlessRunResult{
+	result := headlessRunResult{
 		SessionID:    strings.TrimSpace(runResult.Session.SessionID),
 		Output:       strings.TrimSpace(lastAssistant),
 		PromptTokens: promptTokens,
-	}, nil
+	}
+	if !usage.IsZero() {
+		result.Usage = &usage.Total
+	}
+	return result, nil
 }
 
 func writeHeadlessResult(w io.Writer, format headlessOutputFormat, result headlessRunResult) error {
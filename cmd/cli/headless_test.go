@@ -2,6 +2,8 @@ package main
 
 import (
 	"bytes"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -80,3 +82,27 @@ func TestWriteHeadlessResult_Text(t *testing.T) {
 		t.Fatalf("unexpected text output %q", got)
 	}
 }
+
+func TestApplyOutputSchema_ValidatesAndCompactsAnswer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schema.json")
+	if err := os.WriteFile(path, []byte(`{"type":"object","required":["ok"],"properties":{"ok":{"type":"boolean"}}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	spec, err := loadOutputSchemaFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := headlessRunResult{Output: "```json\n{ \"ok\": true }\n```"}
+	if err := applyOutputSchema(&result, spec); err != nil {
+		t.Fatalf("expected valid answer, got %v", err)
+	}
+	if result.Output != `{"ok":true}` || string(result.Structured) != `{"ok":true}` {
+		t.Fatalf("unexpected structured result %+v", result)
+	}
+
+	bad := headlessRunResult{Output: `{"ok":"yes"}`}
+	if err := applyOutputSchema(&bad, spec); err == nil || !strings.Contains(err.Error(), "$.ok: expected boolean") {
+		t.Fatalf("expected schema violation, got %v", err)
+	}
+}
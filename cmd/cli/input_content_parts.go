@@ -68,7 +68,7 @@ func compactContentParts(parts []model.ContentPart) []model.ContentPart {
 				out[n-1].Text += part.Text
 				continue
 			}
-		case model.ContentPartImage:
+		case model.ContentPartImage, model.ContentPartDocument, model.ContentPartAudio:
 			if strings.TrimSpace(part.Data) == "" && strings.TrimSpace(part.FileName) == "" {
 				continue
 			}
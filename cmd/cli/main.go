@@ -29,7 +29,7 @@ func main() {
 	if sandboxhelper.MaybeRun(os.Args[1:]) {
 		return
 	}
-	launcher := launcherfull.NewLauncher(runCLI, runACP)
+	launcher := launcherfull.NewLauncher(runCLI, runACP, runAPI, runWeb)
 	if err := launcher.Execute(context.Background(), os.Args[1:]); err != nil {
 		exitErr(err)
 	}
@@ -58,12 +58,14 @@ func runCLI(ctx context.Context, args []string) error {
 		toolProviders    = fs.String("tool-providers", appassembly.ProviderWorkspaceTools+","+appassembly.ProviderShellTools, "Comma-separated tool providers")
 		policyProviders  = fs.String("policy-providers", appassembly.ProviderDefaultPolicy, "Comma-separated policy providers")
 		modelAlias       = fs.String("model", configStore.DefaultModel(), "Model alias")
+		fallbackModels   = fs.String("fallback-models", strings.Join(configStore.ModelFallbacks(), ","), "Comma-separated model aliases to fail over to on rate limits, 5xx and context overflow")
 		uiMode           = fs.String("ui", string(uiModeAuto), "Interactive UI mode: auto|tui")
 		appName          = fs.String("app", initialAppName, "App name")
 		userID           = fs.String("user", "local-user", "User id")
 		sessionID        = fs.String("session", "default", "Session id")
 		prompt           = fs.String("p", "", "Single-shot prompt text (headless mode)")
 		outputFormat     = fs.String("format", string(headlessFormatText), "Output format for headless mode: text|json")
+		outputSchema     = fs.String("output-schema", "", "JSON Schema file the headless answer must conform to")
 		storeDir         = fs.String("store-dir", defaultStoreDir, "Local event store directory")
 		sessionIndexFile = fs.String("session-index", defaultSessionIndexPath, "Session index sqlite file path")
 		systemPrompt     = fs.String("system-prompt", "", "Base system prompt")
@@ -102,6 +104,13 @@ func runCLI(ctx context.Context, args []string) error {
 	if err != nil {
 		return err
 	}
+	outputSpec, err := loadOutputSchemaFile(*outputSchema)
+	if err != nil {
+		return err
+	}
+	if outputSpec != nil && !singleShotMode {
+		return fmt.Errorf("-output-schema requires headless mode (-p or piped stdin)")
+	}
 	resolvedUIMode := uiModeTUI
 	if !singleShotMode {
 		mode, err := resolveInteractiveUIMode(*uiMode, stdinTTY, stdoutTTY)
@@ -113,7 +122,7 @@ func runCLI(ctx context.Context, args []string) error {
 	if !flagProvided(args, "session") {
 		*sessionID = nextConversationSessionID()
 	}
-	credentials, err := loadOrInitCredentialStore(initialAppName, credentialStoreModeAuto)
+	credentials, err := loadOrInitCredentialStore(initialAppName, configStore.CredentialStoreMode())
 	if err != nil {
 		return err
 	}
@@ -125,6 +134,9 @@ func runCLI(ctx context.Context, args []string) error {
 	if err != nil {
 		return err
 	}
+	for _, warn := range configStore.LoadSubagentProfiles(resolvedWorkspaceRoot) {
+		fmt.Fprintf(os.Stderr, "warn: %v\n", warn)
+	}
 	_ = skillsDirs
 	skillDirList := activeSkillDirs()
 	inputRefs, inputRefWarnings, err := newInputReferenceResolver(workspace.CWD, skillDirList)
@@ -148,6 +160,9 @@ func runCLI(ctx context.Context, args []string) error {
 		strings.TrimSpace(*sandboxType),
 		sandboxHelperPath,
 		configStore.SandboxPolicy(),
+		configStore.BashLimits(),
+		configStore.BashPersistentShell(),
+		configStore.SSHWorkspace(workspace.CWD),
 	)
 	if err != nil {
 		return err
@@ -168,6 +183,13 @@ func runCLI(ctx context.Context, args []string) error {
 		}
 	}()
 	execRuntimeView := newSwappableRuntime(execRuntime)
+	defer func() {
+		overlay := execRuntimeView.Overlay()
+		if changes, _ := overlay.Changes(); len(changes) > 0 {
+			fmt.Fprintf(os.Stderr, "warn: discarded %d staged file(s) on exit\n", len(changes))
+		}
+		_ = overlay.Close()
+	}()
 	if err := configStore.SetRuntimeSettings(runtimeSettings{
 		PermissionMode: *permissionMode,
 		SandboxType:    *sandboxType,
@@ -178,12 +200,19 @@ func runCLI(ctx context.Context, args []string) error {
 		fmt.Fprintf(os.Stderr, "warn: sandbox unavailable, fallback to host+approval: %s\n", execRuntime.FallbackReason())
 	}
 	pluginRegistry := plugin.NewRegistry()
+	mcpServers := configStore.MCPServerConfigs()
+	permissionRules, err := loadPermissionRules(configStore, workspace.CWD)
+	if err != nil {
+		return err
+	}
 	if err := appassembly.RegisterBuiltinProviders(pluginRegistry, appassembly.RegisterOptions{
 		ExecutionRuntime: execRuntimeView,
+		MCPServers:       mcpServers,
+		PermissionRules:  permissionRules,
 	}); err != nil {
 		return err
 	}
-	resolvedToolProviders := splitCSV(*toolProviders)
+	resolvedToolProviders := withMCPToolProvider(splitCSV(*toolProviders), mcpServers)
 	if *experimentalLSP {
 		resolvedToolProviders = appendProviderIfMissing(resolvedToolProviders, providerLSPTools)
 	}
@@ -201,6 +230,11 @@ func runCLI(ctx context.Context, args []string) error {
 		return err
 	}
 	factory := buildModelFactory(configStore, credentials)
+	fallbackAliases := splitCSV(*fallbackModels)
+	for i, one := range fallbackAliases {
+		fallbackAliases[i] = resolveModelAliasFromConfig(one, configStore)
+	}
+	factory.SetFallbacks(fallbackAliases)
 
 	alias := resolveModelAliasFromConfig(*modelAlias, configStore)
 	modelRuntime := defaultModelRuntimeSettings()
@@ -213,8 +247,10 @@ func runCLI(ctx context.Context, args []string) error {
 		if err != nil {
 			return err
 		}
-		if err := configStore.SetDefaultModel(alias); err != nil {
-			fmt.Fprintf(os.Stderr, "warn: update default model failed: %v\n", err)
+		if _, replay := modelproviders.ReplayCassettePath(alias); !replay {
+			if err := configStore.SetDefaultModel(alias); err != nil {
+				fmt.Fprintf(os.Stderr, "warn: update default model failed: %v\n", err)
+			}
 		}
 	}
 
@@ -245,6 +281,7 @@ func runCLI(ctx context.Context, args []string) error {
 	sessionModes := []internalacp.SessionMode{
 		{ID: "default", Name: "Default", Description: "Normal coding mode with execution enabled."},
 		{ID: "plan", Name: "Plan", Description: "Planning-first mode that focuses on analysis before making changes."},
+		{ID: "staged", Name: "Staged", Description: "Stage file writes and sandboxed commands for review before they touch the workspace."},
 		{ID: "full_access", Name: "Full Access", Description: "Execute changes directly without interactive approval, while still blocking dangerous destructive commands."},
 	}
 	sessionConfig := buildACPSessionConfigOptions(sessionModes, factory, configStore, alias)
@@ -259,6 +296,7 @@ func runCLI(ctx context.Context, args []string) error {
 		WorkspaceCWD:         workspace.CWD,
 		ClientRuntime:        execRuntimeView,
 		ResolveAgentRegistry: configStore.AgentRegistry,
+		SubagentBudget:       configStore.SubagentBudget(),
 		NewAdapter: func(conn *internalacp.Conn) (internalacp.Adapter, error) {
 			if newACPAdapter == nil {
 				return nil, fmt.Errorf("self acp adapter is not initialized")
@@ -351,15 +389,25 @@ func runCLI(ctx context.Context, args []string) error {
 					}(),
 				})
 			},
-			NewSessionResources: func(ctx context.Context, acpConn *internalacp.Conn, sessionID string, sessionCWD string, caps internalacp.ClientCapabilities, modeResolver func() string) (*internalacp.SessionResources, error) {
+			NewSessionResources: func(ctx context.Context, acpConn *internalacp.Conn, sessionID string, sessionCWD string, caps internalacp.ClientCapabilities, acpMCPServers []internalacp.MCPServer, modeResolver func() string) (*internalacp.SessionResources, error) {
 				execRuntimeACP := internalacp.NewRuntime(execRuntimeView, acpConn, sessionID, resolvedWorkspaceRoot, sessionCWD, caps, modeResolver)
+				sessionMCPServers, err := resolveSessionMCPServers(mcpServers, acpMCPServers)
+				if err != nil {
+					return nil, err
+				}
+				sessionRules, err := loadPermissionRules(configStore, sessionCWD)
+				if err != nil {
+					return nil, err
+				}
 				registry := plugin.NewRegistry()
 				if err := appassembly.RegisterBuiltinProviders(registry, appassembly.RegisterOptions{
 					ExecutionRuntime: execRuntimeACP,
+					MCPServers:       sessionMCPServers,
+					PermissionRules:  sessionRules,
 				}); err != nil {
 					return nil, err
 				}
-				resolvedACPProviders := append([]string(nil), resolvedToolProviders...)
+				resolvedACPProviders := withMCPToolProvider(append([]string(nil), resolvedToolProviders...), sessionMCPServers)
 				if includesProvider(resolvedACPProviders, providerLSPTools) {
 					if err := registerCLILSPToolProvider(registry, sessionCWD, execRuntimeACP); err != nil {
 						return nil, err
@@ -420,11 +468,15 @@ func runCLI(ctx context.Context, args []string) error {
 			WorkspaceRoot:    resolvedWorkspaceRoot,
 			ExecutionRuntime: execRuntimeView,
 			AppVersion:       version.String(),
+			Output:           outputSpec,
 		}
 		_, usesACP, err := resolveMainSessionAgentDescriptor(mainAgentInput)
 		if err != nil {
 			return err
 		}
+		if usesACP && outputSpec != nil {
+			return fmt.Errorf("-output-schema is not supported when the main agent is an external ACP agent")
+		}
 		if !usesACP && llm == nil {
 			return fmt.Errorf("no model configured, run /connect first or pass -model with a configured provider/model")
 		}
@@ -478,17 +530,25 @@ func runCLI(ctx context.Context, args []string) error {
 					fmt.Fprintf(os.Stderr, "note: %s\n", note)
 				}
 				for _, relPath := range result.ResolvedPaths {
-					if !image.IsImagePath(relPath) {
-						continue
-					}
 					absPath := inputRefs.AbsPath(relPath)
-					part, loadErr := image.LoadAsContentPart(absPath)
-					if loadErr != nil {
-						fmt.Fprintf(os.Stderr, "warn: image load skipped: %s: %v\n", relPath, loadErr)
-						continue
+					switch {
+					case image.IsImagePath(relPath):
+						part, loadErr := image.LoadAsContentPart(absPath)
+						if loadErr != nil {
+							fmt.Fprintf(os.Stderr, "warn: image load skipped: %s: %v\n", relPath, loadErr)
+							continue
+						}
+						contentParts = append(contentParts, part)
+						fmt.Fprintf(os.Stderr, "note: attached image: %s\n", relPath)
+					case image.IsMediaPath(relPath):
+						part, loadErr := image.LoadMediaAsContentPart(absPath)
+						if loadErr != nil {
+							fmt.Fprintf(os.Stderr, "warn: attachment load skipped: %s: %v\n", relPath, loadErr)
+							continue
+						}
+						contentParts = append(contentParts, part)
+						fmt.Fprintf(os.Stderr, "note: attached %s: %s\n", part.Type, relPath)
 					}
-					contentParts = append(contentParts, part)
-					fmt.Fprintf(os.Stderr, "note: attached image: %s\n", relPath)
 				}
 			}
 		}
@@ -508,6 +568,9 @@ func runCLI(ctx context.Context, args []string) error {
 		if runErr != nil {
 			return runErr
 		}
+		if err := applyOutputSchema(&headlessResult, outputSpec); err != nil {
+			return err
+		}
 		return writeHeadlessResult(os.Stdout, outFormat, headlessResult)
 	}
 
@@ -0,0 +1,30 @@
+package main
+
+import (
+	internalacp "github.com/OnslaughtSnail/caelis/internal/acp"
+	appassembly "github.com/OnslaughtSnail/caelis/internal/app/assembly"
+	"github.com/OnslaughtSnail/caelis/internal/mcpclient"
+)
+
+// resolveSessionMCPServers merges configured MCP servers with the ones an ACP
+// client passed to session/new or session/load. Client entries win on name
+// collisions so editors can override a configured server per session.
+func resolveSessionMCPServers(configured []mcpclient.ServerConfig, acpServers []internalacp.MCPServer) ([]mcpclient.ServerConfig, error) {
+	raw := make([]map[string]any, 0, len(acpServers))
+	for _, one := range acpServers {
+		raw = append(raw, map[string]any(one))
+	}
+	requested, err := mcpclient.ParseACPServers(raw)
+	if err != nil {
+		return nil, err
+	}
+	return mcpclient.MergeServers(configured, requested), nil
+}
+
+// withMCPToolProvider enables the MCP tool provider when any server is present.
+func withMCPToolProvider(providers []string, servers []mcpclient.ServerConfig) []string {
+	if len(servers) == 0 {
+		return providers
+	}
+	return appendProviderIfMissing(providers, appassembly.ProviderMCPTools)
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"github.com/OnslaughtSnail/caelis/internal/cli/modelcatalog"
+	"github.com/OnslaughtSnail/caelis/kernel/model"
 	modelproviders "github.com/OnslaughtSnail/caelis/kernel/model/providers"
 )
 
@@ -63,3 +64,7 @@ func normalizeCatalogReasoningMode(mode string) string {
 func catalogSupportsReasoningEffortList(levels []string, effort string) bool {
 	return modelcatalog.SupportsReasoningEffortList(levels, effort)
 }
+
+func estimateCatalogModelCost(provider, modelName string, usage model.Usage) (float64, bool) {
+	return modelcatalog.EstimateCost(provider, modelName, usage)
+}
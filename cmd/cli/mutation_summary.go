@@ -19,6 +19,9 @@ type toolCallMutationVisuals struct {
 	CallSummary  string
 	PreviewPath  string
 	PreviewNew   string
+	// PreviewFiles carries per-file new content for multi-file tools such as
+	// EDIT, whose aggregated PreviewNew is not any one file's content.
+	PreviewFiles []toolfs.MutationPreview
 }
 
 type mutationPreviewRender struct {
@@ -46,6 +49,7 @@ func buildToolCallMutationVisuals(runtime toolexec.Runtime, toolName string, cal
 		CallSummary:  render.CallSummary,
 		PreviewPath:  render.Preview.Path,
 		PreviewNew:   render.Preview.New,
+		PreviewFiles: render.Preview.Files,
 	}
 	if !render.HasChanges || render.TooLarge {
 		return visuals, true
@@ -106,6 +110,14 @@ func mutationChangeCountsForTool(toolName string, preview toolfs.MutationPreview
 	case "PATCH", "WRITE":
 		stats := toolfs.CountLineDiff(preview.Old, preview.New)
 		return mutationChangeCounts{Added: stats.Added, Removed: stats.Removed}
+	case "EDIT":
+		counts := mutationChangeCounts{}
+		for _, file := range preview.Files {
+			stats := toolfs.CountLineDiff(file.Old, file.New)
+			counts.Added += stats.Added
+			counts.Removed += stats.Removed
+		}
+		return counts
 	default:
 		return mutationChangeCounts{}
 	}
@@ -146,6 +158,9 @@ func mutationToolCallSummary(preview toolfs.MutationPreview, counts mutationChan
 	if display == "" {
 		return ""
 	}
+	if len(preview.Files) > 1 {
+		display = fmt.Sprintf("%s (+%d files)", display, len(preview.Files)-1)
+	}
 	if mutationHasNoChanges(preview, counts) {
 		return display
 	}
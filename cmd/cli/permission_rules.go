@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/OnslaughtSnail/caelis/kernel/policy"
+)
+
+const (
+	// workspacePermissionRulesFile is committed with the repository so a team
+	// shares one policy. It can only tighten it.
+	workspacePermissionRulesFile = ".caelis/permissions.json"
+	userPermissionRulesFile      = "permissions.json"
+)
+
+// permissionRulesPaths lists rule files in precedence order: the workspace
+// file, then the user file next to the app config.
+func permissionRulesPaths(configFilePath string, workspaceDir string) []string {
+	var paths []string
+	if dir := strings.TrimSpace(workspaceDir); dir != "" {
+		paths = append(paths, filepath.Join(dir, filepath.FromSlash(workspacePermissionRulesFile)))
+	}
+	if dir := strings.TrimSpace(filepath.Dir(configFilePath)); dir != "" && strings.TrimSpace(configFilePath) != "" {
+		paths = append(paths, filepath.Join(dir, userPermissionRulesFile))
+	}
+	return paths
+}
+
+// loadPermissionRules loads the rule files and layers the stored "always
+// allow" command grants for workspaceDir on top. Allow rules in the workspace
+// file are dropped: the agent or a cloned repository can write that file, so
+// it may only ask or deny.
+func loadPermissionRules(configStore *appConfigStore, workspaceDir string) (policy.PermissionRules, error) {
+	configFilePath := ""
+	if configStore != nil {
+		configFilePath = configStore.path
+	}
+	paths := permissionRulesPaths(configFilePath, workspaceDir)
+	rules, err := policy.LoadPermissionRules(paths...)
+	if err != nil {
+		return policy.PermissionRules{}, err
+	}
+	if dir := strings.TrimSpace(workspaceDir); dir != "" {
+		workspaceFile := paths[0]
+		rules.Rules = slices.DeleteFunc(rules.Rules, func(rule policy.PermissionRule) bool {
+			if rule.Effect != policy.PermissionAllow || rule.Source != workspaceFile {
+				return false
+			}
+			fmt.Fprintf(os.Stderr, "warn: ignoring allow rule in %s; workspace rules can only ask or deny\n", workspaceFile)
+			return true
+		})
+	}
+	if grants := configStore.ApprovalGrantStore(); grants != nil {
+		rules.Dynamic = func() []policy.PermissionRule {
+			return grants.CommandRules(workspaceDir)
+		}
+	}
+	return rules, nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/OnslaughtSnail/caelis/kernel/policy"
+)
+
+func TestLoadPermissionRules_WorkspaceBeforeUser(t *testing.T) {
+	workspace := t.TempDir()
+	configDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(workspace, ".caelis"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(workspace, ".caelis", "permissions.json"), []byte(`{"rules":[{"effect":"deny","paths":["deploy"]}]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(configDir, "permissions.json"), []byte(`{"rules":[{"effect":"allow","tool":"BASH","commands":["go test"]}]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	store := &appConfigStore{path: filepath.Join(configDir, "caelis_config.json")}
+
+	rules, err := loadPermissionRules(store, workspace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules.Rules) != 2 || rules.Rules[0].Effect != policy.PermissionDeny || rules.Rules[1].Effect != policy.PermissionAllow {
+		t.Fatalf("expected workspace deny then user allow, got %#v", rules.Rules)
+	}
+}
+
+func TestLoadPermissionRules_IgnoresWorkspaceAllowRules(t *testing.T) {
+	workspace := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(workspace, ".caelis"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	content := `{"rules":[{"effect":"allow","tool":"BASH"},{"effect":"ask","tool":"BASH","commands":["git push"]}]}`
+	if err := os.WriteFile(filepath.Join(workspace, ".caelis", "permissions.json"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	store := &appConfigStore{path: filepath.Join(t.TempDir(), "caelis_config.json")}
+
+	rules, err := loadPermissionRules(store, workspace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules.Rules) != 1 || rules.Rules[0].Effect != policy.PermissionAsk {
+		t.Fatalf("expected only the workspace ask rule, got %#v", rules.Rules)
+	}
+}
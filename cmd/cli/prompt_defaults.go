@@ -57,7 +57,8 @@ func builtInCapabilityGuidancePrompt(_ string) string {
 	return strings.Join([]string{
 		"## Capability Guidance",
 		"",
-		"- Tool families: use READ/SEARCH/GLOB/LIST to inspect, WRITE/PATCH for targeted file changes, BASH for shell work, TASK for async follow-up, and SPAWN for delegated child sessions.",
+		"- Tool families: use READ/SEARCH/GLOB/LIST to inspect, WRITE/PATCH for targeted file changes, EDIT for coordinated multi-file changes, BASH for shell work, TASK for async follow-up, and SPAWN for delegated child sessions.",
+		"- Code search: use SEARCH with regex, include globs, context lines, or files_only/count output instead of running rg/grep through BASH.",
 		"- Skills: load a skill only when its description clearly matches the current task; read the minimum needed from its SKILL.md.",
 		"- Delegation: keep critical-path decisions in the current session and use child sessions for bounded side work or specialization.",
 		"- Modes: obey active session mode rules and avoid leaking planning-only behavior into execution turns.",
@@ -13,6 +13,7 @@ import (
 	"github.com/OnslaughtSnail/caelis/kernel/model"
 	"github.com/OnslaughtSnail/caelis/kernel/runtime"
 	"github.com/OnslaughtSnail/caelis/kernel/session"
+	toolfs "github.com/OnslaughtSnail/caelis/kernel/tool/builtin/filesystem"
 	"github.com/OnslaughtSnail/caelis/pkg/idutil"
 )
 
@@ -401,12 +402,12 @@ func userMessageDisplayText(msg model.Message) string {
 				if text != "" {
 					segments = append(segments, text)
 				}
-			case model.ContentPartImage:
+			case model.ContentPartImage, model.ContentPartDocument, model.ContentPartAudio:
 				name := strings.TrimSpace(part.FileName)
 				if name == "" {
-					name = "image"
+					name = string(part.Type)
 				}
-				segments = append(segments, "[image: "+name+"]")
+				segments = append(segments, "["+string(part.Type)+": "+name+"]")
 			}
 		}
 		if len(segments) > 0 {
@@ -468,13 +469,19 @@ func summarizeToolArgs(toolName string, args map[string]any) string {
 	case "PATCH":
 		path := strings.TrimSpace(asString(args["path"]))
 		return displayFileName(path)
+	case "EDIT":
+		return summarizeEditTargets(toolfs.EditTargetPaths(args))
 	case "WRITE":
 		path := strings.TrimSpace(asString(args["path"]))
 		return displayFileName(path)
 	case "SEARCH":
 		path := strings.TrimSpace(asString(args["path"]))
 		query := strings.TrimSpace(asString(args["query"]))
-		return fmt.Sprintf("%s {query=%s}", displayFileName(path), truncateInline(query, 60))
+		key := "query"
+		if regex, _ := args["regex"].(bool); regex {
+			key = "regex"
+		}
+		return fmt.Sprintf("%s {%s=%s}", displayFileName(path), key, truncateInline(query, 60))
 	case "GLOB":
 		pattern := strings.TrimSpace(asString(args["pattern"]))
 		if pattern != "" {
@@ -490,6 +497,9 @@ func summarizeToolArgs(toolName string, args map[string]any) string {
 		if prompt != "" {
 			return strings.Join(strings.Fields(prompt), " ")
 		}
+		if tasks, ok := args["tasks"].([]any); ok && len(tasks) > 0 {
+			return fmt.Sprintf("batch of %d children", len(tasks))
+		}
 	}
 	keys := make([]string, 0, len(args))
 	for k := range args {
@@ -565,6 +575,12 @@ func summarizeCompactToolResponseForTUI(toolName string, result map[string]any)
 	case "SEARCH":
 		count, _ := asInt(result["count"])
 		fileCount, _ := asInt(result["file_count"])
+		if asString(result["output_mode"]) == "files_only" {
+			if fmt.Sprint(result["truncated"]) == "true" {
+				return fmt.Sprintf("%d files, truncated", fileCount)
+			}
+			return fmt.Sprintf("%d files", fileCount)
+		}
 		parts := []string{fmt.Sprintf("%d matches", count)}
 		if fileCount > 0 {
 			parts = append(parts, fmt.Sprintf("%d files", fileCount))
@@ -633,6 +649,19 @@ func summarizeToolResponseWithCall(toolName string, result map[string]any, callA
 			}
 			return fmt.Sprintf("%s %s", action, display)
 		}
+	case "EDIT":
+		fileCount, _ := asInt(result["file_count"])
+		display := displayFileName(strings.TrimSpace(asString(result["path"])))
+		if display == "" {
+			break
+		}
+		if mutationResultHasNoChanges(result) {
+			return fmt.Sprintf("unchanged %s", display)
+		}
+		if fileCount > 1 {
+			return fmt.Sprintf("edited %d files", fileCount)
+		}
+		return fmt.Sprintf("edited %s", display)
 	case "WRITE":
 		path := strings.TrimSpace(asString(result["path"]))
 		created := fmt.Sprint(result["created"]) == "true"
@@ -651,6 +680,12 @@ func summarizeToolResponseWithCall(toolName string, result map[string]any, callA
 		count, _ := asInt(result["count"])
 		fileCount, _ := asInt(result["file_count"])
 		truncated := fmt.Sprint(result["truncated"]) == "true"
+		if asString(result["output_mode"]) == "files_only" {
+			if truncated {
+				return fmt.Sprintf("found %d matching files (truncated)", fileCount)
+			}
+			return fmt.Sprintf("found %d matching files", fileCount)
+		}
 		if truncated {
 			return fmt.Sprintf("found %d matches in %d files (truncated)", count, fileCount)
 		}
@@ -716,11 +751,19 @@ func summarizeACPToolArgs(toolName string, args map[string]any) string {
 		if path := firstNonEmptyText(asString(parsed["path"]), asString(args["path"]), asString(args["target"])); path != "" {
 			return displayFileName(path)
 		}
+	case "EDIT":
+		if summary := summarizeEditTargets(toolfs.EditTargetPaths(args)); summary != "" {
+			return summary
+		}
 	case "SEARCH":
 		path := firstNonEmptyText(asString(parsed["path"]), asString(args["path"]))
 		query := firstNonEmptyText(asString(parsed["query"]), asString(args["query"]), asString(args["pattern"]))
+		key := "query"
+		if regex, _ := args["regex"].(bool); regex {
+			key = "regex"
+		}
 		if path != "" || query != "" {
-			return fmt.Sprintf("%s {query=%s}", displayFileName(path), truncateInline(query, 60))
+			return fmt.Sprintf("%s {%s=%s}", displayFileName(path), key, truncateInline(query, 60))
 		}
 	case "GLOB":
 		if pattern := firstNonEmptyText(asString(parsed["pattern"]), asString(args["pattern"])); pattern != "" {
@@ -1003,7 +1046,7 @@ func displayToolResponseName(toolName string, callArgs map[string]any, _ map[str
 
 func taskActionCallDisplayName(action string) string {
 	switch strings.ToLower(strings.TrimSpace(action)) {
-	case "wait":
+	case "wait", "wait_any", "wait_all":
 		return "WAIT"
 	case "status":
 		return "CHECK"
@@ -1020,7 +1063,7 @@ func taskActionCallDisplayName(action string) string {
 
 func taskActionResultDisplayName(action string) string {
 	switch strings.ToLower(strings.TrimSpace(action)) {
-	case "wait":
+	case "wait", "wait_any", "wait_all":
 		return "WAITED"
 	case "cancel":
 		return "CANCELLED"
@@ -1253,9 +1296,21 @@ func isReadOnlyFSTool(toolName string) bool {
 	}
 }
 
+// summarizeEditTargets shows the first EDIT target and how many more follow.
+func summarizeEditTargets(paths []string) string {
+	switch len(paths) {
+	case 0:
+		return ""
+	case 1:
+		return displayFileName(paths[0])
+	default:
+		return fmt.Sprintf("%s (+%d files)", displayFileName(paths[0]), len(paths)-1)
+	}
+}
+
 func isFileMutationTool(toolName string) bool {
 	switch strings.ToUpper(strings.TrimSpace(toolName)) {
-	case "PATCH", "WRITE":
+	case "PATCH", "WRITE", "EDIT":
 		return true
 	default:
 		return false
@@ -225,3 +225,13 @@ func TestVisibleUserTextStripsHiddenInputReferenceHints(t *testing.T) {
 		t.Fatalf("expected hidden input reference hints removed from visible text, got %q", got)
 	}
 }
+
+func TestSummarizeSearchToolFilesOnlyAndRegex(t *testing.T) {
+	if got := summarizeToolArgs("SEARCH", map[string]any{"path": "/w/pkg", "query": `func \w+`, "regex": true}); !strings.Contains(got, `{regex=func \w+}`) {
+		t.Fatalf("expected regex query label, got %q", got)
+	}
+	got := summarizeToolResponse("SEARCH", map[string]any{"output_mode": "files_only", "count": 3, "file_count": 3, "truncated": false})
+	if got != "found 3 matching files" {
+		t.Fatalf("unexpected files_only summary %q", got)
+	}
+}
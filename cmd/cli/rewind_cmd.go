@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/OnslaughtSnail/caelis/internal/cli/tuievents"
+	"github.com/OnslaughtSnail/caelis/kernel/runtime"
+)
+
+const rewindUsage = "usage: /rewind [turn|list]"
+
+func handleRewind(c *cliConsole, args []string) (bool, error) {
+	if len(args) > 1 {
+		return false, fmt.Errorf(rewindUsage)
+	}
+	if c.rt == nil {
+		return false, fmt.Errorf("runtime is not available")
+	}
+	turn := 0
+	if len(args) == 1 {
+		arg := strings.ToLower(strings.TrimSpace(args[0]))
+		if arg == "list" {
+			return false, c.printRewindTurns()
+		}
+		n, err := strconv.Atoi(arg)
+		if err != nil || n <= 0 {
+			return false, fmt.Errorf(rewindUsage)
+		}
+		turn = n
+	}
+	result, err := c.rt.Rewind(c.baseCtx, runtime.RewindRequest{
+		AppName:     c.appName,
+		UserID:      c.userID,
+		SessionID:   c.sessionID,
+		Turn:        turn,
+		ExecRuntime: c.executionRuntimeForSession(),
+	})
+	if err != nil {
+		return false, err
+	}
+	if c.tuiSender != nil {
+		c.tuiSender.Send(tuievents.ClearHistoryMsg{})
+	}
+	if err := c.renderResumedSessionEvents(); err != nil {
+		return false, err
+	}
+	c.syncTUIStatus()
+	summary := fmt.Sprintf("rewound to before turn %d: %d event(s) dropped, %d file(s) restored, %d file(s) removed",
+		result.Turn, result.RemovedEvents, len(result.RestoredFiles), len(result.RemovedFiles))
+	if c.tuiSender != nil {
+		c.tuiSender.Send(tuievents.SetHintMsg{Hint: summary, ClearAfter: transientHintDuration})
+	}
+	c.printf("%s\n", summary)
+	if input := truncateInline(result.Input, 120); input != "" {
+		c.printf("discarded prompt: %s\n", input)
+	}
+	return false, nil
+}
+
+func (c *cliConsole) printRewindTurns() error {
+	turns, err := c.rt.Turns(c.baseCtx, runtime.TurnsRequest{
+		AppName:   c.appName,
+		UserID:    c.userID,
+		SessionID: c.sessionID,
+	})
+	if err != nil {
+		return err
+	}
+	if len(turns) == 0 {
+		c.printf("no turns to rewind\n")
+		return nil
+	}
+	c.ui.Section("Turns")
+	for _, turn := range turns {
+		files := ""
+		if len(turn.Files) > 0 {
+			files = fmt.Sprintf(" (%d file(s))", len(turn.Files))
+		}
+		c.ui.Plain("  %3d  %s%s\n", turn.Index, truncateInline(turn.Input, 72), files)
+	}
+	return nil
+}
@@ -118,6 +118,8 @@ func (c *cliConsole) togglePlanMode() (string, error) {
 	switch next {
 	case sessionmode.PlanMode:
 		return "plan mode enabled", nil
+	case sessionmode.StagedMode:
+		return "staged mode enabled", nil
 	case sessionmode.FullMode:
 		return "full access mode enabled", nil
 	default:
@@ -165,14 +167,26 @@ func (c *cliConsole) restoreSessionMode(mode string) error {
 		return nil
 	}
 	c.sessionMode = c.sanitizedRestoredSessionMode(mode)
+	if err := c.syncStagedOverlay(c.sessionMode); err != nil {
+		c.sessionMode = sessionmode.DefaultMode
+		return err
+	}
 	return nil
 }
 
 func (c *cliConsole) sanitizedRestoredSessionMode(mode string) string {
 	nextMode := sessionmode.Normalize(mode)
+	// Staged changes belong to the workspace, not the session, so they keep
+	// staged mode on until they are applied or discarded.
+	if c.stagedOverlay() != nil && c.stagedChangesPending() {
+		return sessionmode.StagedMode
+	}
 	if nextMode == sessionmode.FullMode && !c.permissionAllowsFullAccess() {
 		return sessionmode.DefaultMode
 	}
+	if nextMode == sessionmode.StagedMode && c.execRuntimeView == nil {
+		return sessionmode.DefaultMode
+	}
 	return nextMode
 }
 
@@ -198,6 +212,12 @@ func (c *cliConsole) applySessionMode(mode string, opts sessionModeApplyOptions)
 	}
 	nextMode := sessionmode.Normalize(mode)
 	prevMode := c.sessionMode
+	if !sessionmode.IsStaged(nextMode) && c.stagedOverlay() != nil && c.stagedChangesPending() {
+		return fmt.Errorf("staged changes are pending; apply or discard them with /staged first")
+	}
+	if err := c.syncStagedOverlay(nextMode); err != nil {
+		return err
+	}
 	prevPermission := toolexec.PermissionModeDefault
 	if c.execRuntime != nil {
 		prevPermission = c.execRuntime.PermissionMode()
@@ -205,6 +225,7 @@ func (c *cliConsole) applySessionMode(mode string, opts sessionModeApplyOptions)
 	nextPermission := sessionmode.PermissionMode(nextMode)
 	if opts.syncRuntime && prevPermission != nextPermission {
 		if err := c.updateExecutionRuntime(nextPermission, c.sandboxType); err != nil {
+			_ = c.syncStagedOverlay(prevMode)
 			return err
 		}
 	}
@@ -215,6 +236,7 @@ func (c *cliConsole) applySessionMode(mode string, opts sessionModeApplyOptions)
 			if opts.syncRuntime && prevPermission != nextPermission {
 				_ = c.updateExecutionRuntime(prevPermission, c.sandboxType)
 			}
+			_ = c.syncStagedOverlay(prevMode)
 			return err
 		}
 	}
@@ -359,7 +359,7 @@ func subagentDomainUpdatesFromTaskToolResponse(rootSessionID string, resp *model
 		})
 	}
 	switch state {
-	case "completed", "failed", "interrupted", "timed_out":
+	case "completed", "failed", "interrupted", "timed_out", "terminated":
 		updates = append(updates, subagentDomainUpdate{
 			Kind:   subagentDomainTerminal,
 			Target: target,
@@ -707,7 +707,7 @@ func spawnPreviewEventAgent(ev *session.Event) string {
 
 func isTerminalSpawnStatus(status runtime.RunLifecycleStatus) bool {
 	switch status {
-	case runtime.RunLifecycleStatusCompleted, runtime.RunLifecycleStatusFailed, runtime.RunLifecycleStatusInterrupted:
+	case runtime.RunLifecycleStatusCompleted, runtime.RunLifecycleStatusFailed, runtime.RunLifecycleStatusInterrupted, runtime.RunLifecycleStatusTerminated:
 		return true
 	default:
 		return false
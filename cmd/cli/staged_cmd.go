@@ -0,0 +1,254 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/OnslaughtSnail/caelis/internal/cli/tuievents"
+	"github.com/OnslaughtSnail/caelis/internal/sessionmode"
+	toolexec "github.com/OnslaughtSnail/caelis/kernel/execenv"
+	toolfs "github.com/OnslaughtSnail/caelis/kernel/tool/builtin/filesystem"
+)
+
+const stagedUsage = "usage: /staged [diff|apply [path ...]|discard]"
+
+const (
+	stagedReviewApplyAll = "apply"
+	stagedReviewChoose   = "choose"
+	stagedReviewDiscard  = "discard"
+	stagedReviewKeep     = "keep"
+)
+
+func handleStaged(c *cliConsole, args []string) (bool, error) {
+	overlay := c.stagedOverlay()
+	if overlay == nil {
+		return false, fmt.Errorf("staged mode is off; switch to it with shift+tab")
+	}
+	action := "diff"
+	if len(args) > 0 {
+		action = strings.ToLower(strings.TrimSpace(args[0]))
+	}
+	switch action {
+	case "diff":
+		if len(args) > 1 {
+			return false, fmt.Errorf(stagedUsage)
+		}
+		changes, err := overlay.Changes()
+		if err != nil {
+			return false, err
+		}
+		if len(changes) == 0 {
+			c.printf("no staged changes\n")
+			return false, nil
+		}
+		c.showStagedChanges(overlay, changes)
+		return false, nil
+	case "apply":
+		var paths []string
+		if len(args) > 1 {
+			paths = args[1:]
+		}
+		return false, c.applyStagedChanges(overlay, paths)
+	case "discard":
+		if len(args) > 1 {
+			return false, fmt.Errorf(stagedUsage)
+		}
+		return false, c.discardStagedChanges(overlay)
+	default:
+		return false, fmt.Errorf(stagedUsage)
+	}
+}
+
+// stagedOverlay returns the layer the session writes into, or nil outside
+// staged mode.
+func (c *cliConsole) stagedOverlay() *toolexec.Overlay {
+	if c == nil || c.execRuntimeView == nil {
+		return nil
+	}
+	return c.execRuntimeView.Overlay()
+}
+
+// syncStagedOverlay creates the staging layer when the session enters
+// staged mode and removes it when the session leaves.
+func (c *cliConsole) syncStagedOverlay(mode string) error {
+	if c == nil || c.execRuntimeView == nil {
+		if sessionmode.IsStaged(mode) {
+			return fmt.Errorf("staged mode is not available in this session")
+		}
+		return nil
+	}
+	current := c.execRuntimeView.Overlay()
+	if sessionmode.IsStaged(mode) {
+		if current != nil {
+			return nil
+		}
+		if c.execRuntimeView.State().Remote != nil {
+			return fmt.Errorf("staged mode is not available for a remote workspace")
+		}
+		overlay, err := toolexec.NewOverlay(c.workspace.CWD)
+		if err != nil {
+			return err
+		}
+		c.execRuntimeView.SetOverlay(overlay)
+		return nil
+	}
+	if current == nil {
+		return nil
+	}
+	c.execRuntimeView.SetOverlay(nil)
+	return current.Close()
+}
+
+// stagedChangesPending reports whether leaving staged mode would lose
+// changes the user has not reviewed.
+func (c *cliConsole) stagedChangesPending() bool {
+	changes, err := c.stagedOverlay().Changes()
+	return err != nil || len(changes) > 0
+}
+
+// reviewStagedChanges shows what a staged turn changed and asks which of
+// the changes to apply.
+func (c *cliConsole) reviewStagedChanges() {
+	overlay := c.stagedOverlay()
+	if overlay == nil {
+		return
+	}
+	changes, err := overlay.Changes()
+	if err != nil {
+		c.printf("warn: list staged changes failed: %v\n", err)
+		return
+	}
+	if len(changes) == 0 {
+		return
+	}
+	c.showStagedChanges(overlay, changes)
+	if c.prompter == nil {
+		c.printf("review with /staged, then /staged apply [path ...] or /staged discard\n")
+		return
+	}
+	choice, err := c.promptChoice(fmt.Sprintf("Apply %d staged file(s)?", len(changes)), []promptChoiceItem{
+		{Label: "Apply all", Value: stagedReviewApplyAll},
+		{Label: "Choose files", Value: stagedReviewChoose},
+		{Label: "Discard all", Value: stagedReviewDiscard},
+		{Label: "Keep staged", Value: stagedReviewKeep, Detail: "decide later with /staged"},
+	}, stagedReviewApplyAll, false)
+	if err != nil {
+		c.printf("staged changes kept; review them with /staged\n")
+		return
+	}
+	switch choice {
+	case stagedReviewApplyAll:
+		err = c.applyStagedChanges(overlay, nil)
+	case stagedReviewChoose:
+		err = c.chooseStagedChanges(overlay, changes)
+	case stagedReviewDiscard:
+		err = c.discardStagedChanges(overlay)
+	default:
+		c.printf("staged changes kept; review them with /staged\n")
+	}
+	if err != nil {
+		c.printf("error: %v\n", err)
+	}
+}
+
+func (c *cliConsole) chooseStagedChanges(overlay *toolexec.Overlay, changes []toolexec.OverlayChange) error {
+	choices := make([]promptChoiceItem, 0, len(changes))
+	selected := make([]string, 0, len(changes))
+	for _, change := range changes {
+		rel := stagedDisplayPath(overlay, change.Path)
+		choices = append(choices, promptChoiceItem{Label: rel, Value: rel, Detail: string(change.Kind)})
+		selected = append(selected, rel)
+	}
+	paths, err := c.promptMultiChoiceWithDefaults("Files to apply (the rest are discarded)", choices, selected, true)
+	if err != nil {
+		c.printf("staged changes kept; review them with /staged\n")
+		return nil
+	}
+	if len(paths) == 0 {
+		return c.discardStagedChanges(overlay)
+	}
+	return c.applyStagedChanges(overlay, paths)
+}
+
+// applyStagedChanges writes the staged changes for paths, or all of them
+// when paths is nil, and drops the rest.
+func (c *cliConsole) applyStagedChanges(overlay *toolexec.Overlay, paths []string) error {
+	if paths != nil {
+		changes, err := overlay.Changes()
+		if err != nil {
+			return err
+		}
+		known := make(map[string]bool, len(changes))
+		for _, change := range changes {
+			known[change.Path] = true
+		}
+		for _, path := range paths {
+			abs := path
+			if !filepath.IsAbs(abs) {
+				abs = filepath.Join(overlay.Root(), abs)
+			}
+			if !known[filepath.Clean(abs)] {
+				return fmt.Errorf("no staged change for %q", path)
+			}
+		}
+	}
+	applied, err := overlay.Apply(paths)
+	if err != nil {
+		return err
+	}
+	summary := fmt.Sprintf("applied %d staged file(s) to the workspace", len(applied))
+	if c.tuiSender != nil {
+		c.tuiSender.Send(tuievents.SetHintMsg{Hint: summary, ClearAfter: transientHintDuration})
+	}
+	c.printf("%s\n", summary)
+	return nil
+}
+
+func (c *cliConsole) discardStagedChanges(overlay *toolexec.Overlay) error {
+	if err := overlay.Discard(); err != nil {
+		return err
+	}
+	c.printf("discarded staged changes\n")
+	return nil
+}
+
+// showStagedChanges renders one diff per staged file in the TUI, or a list
+// of files with line counts otherwise.
+func (c *cliConsole) showStagedChanges(overlay *toolexec.Overlay, changes []toolexec.OverlayChange) {
+	if c.tuiSender == nil {
+		c.ui.Section("Staged changes")
+	}
+	for _, change := range changes {
+		rel := stagedDisplayPath(overlay, change.Path)
+		binary := !utf8.Valid(change.Before) || !utf8.Valid(change.After)
+		if c.tuiSender != nil && !binary {
+			c.tuiSender.Send(tuievents.DiffBlockMsg{
+				Tool:    "STAGED",
+				Path:    rel,
+				Created: change.Kind == toolexec.OverlayChangeAdded,
+				Old:     string(change.Before),
+				New:     string(change.After),
+			})
+			continue
+		}
+		detail := "binary"
+		if !binary {
+			detail = formatMutationChangeSummary(stagedChangeCounts(change))
+		}
+		c.ui.Plain("  %-8s  %s  %s\n", change.Kind, rel, detail)
+	}
+}
+
+func stagedChangeCounts(change toolexec.OverlayChange) mutationChangeCounts {
+	stats := toolfs.CountLineDiff(string(change.Before), string(change.After))
+	return mutationChangeCounts{Added: stats.Added, Removed: stats.Removed}
+}
+
+func stagedDisplayPath(overlay *toolexec.Overlay, path string) string {
+	if rel, err := filepath.Rel(overlay.Root(), path); err == nil {
+		return rel
+	}
+	return path
+}
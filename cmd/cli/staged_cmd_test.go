@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/OnslaughtSnail/caelis/internal/sessionmode"
+	toolexec "github.com/OnslaughtSnail/caelis/kernel/execenv"
+)
+
+func TestStagedMode_StagesWritesUntilApplied(t *testing.T) {
+	workspace := t.TempDir()
+	target := filepath.Join(workspace, "main.go")
+	if err := os.WriteFile(target, []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rt := newCLITestExecRuntime(t, toolexec.PermissionModeDefault)
+	var out bytes.Buffer
+	console := &cliConsole{
+		baseCtx:         context.Background(),
+		execRuntime:     rt,
+		execRuntimeView: newSwappableRuntime(rt),
+		sandboxType:     cliTestSandboxType(),
+		workspace:       workspaceContext{CWD: workspace},
+		out:             &out,
+	}
+	if err := console.setSessionMode(sessionmode.StagedMode); err != nil {
+		t.Fatal(err)
+	}
+	fsys := console.executionRuntimeForSession().FileSystem()
+	if err := fsys.WriteFile(target, []byte("package main\n\nfunc main() {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(target); string(data) != "package main\n" {
+		t.Fatalf("expected the workspace untouched while staged, got %q", data)
+	}
+	if console.execRuntimeView.PersistentShellEnabled() {
+		t.Fatal("expected persistent shells off while staged")
+	}
+
+	if err := console.setSessionMode(sessionmode.DefaultMode); err == nil || !strings.Contains(err.Error(), "/staged") {
+		t.Fatalf("expected leaving staged mode with pending changes to be refused, got %v", err)
+	}
+	if _, err := handleStaged(console, []string{"apply", "missing.go"}); err == nil {
+		t.Fatal("expected applying an unknown path to fail")
+	}
+	if _, err := handleStaged(console, []string{"apply", "main.go"}); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(target); !strings.Contains(string(data), "func main") {
+		t.Fatalf("expected the staged change applied, got %q", data)
+	}
+	if err := console.setSessionMode(sessionmode.DefaultMode); err != nil {
+		t.Fatal(err)
+	}
+	if console.stagedOverlay() != nil {
+		t.Fatal("expected the overlay removed after leaving staged mode")
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/OnslaughtSnail/caelis/kernel/runtime"
+	"github.com/OnslaughtSnail/caelis/kernel/tokenusage"
+)
+
+func (c *cliConsole) sessionTokenUsage() (runtime.TokenUsage, error) {
+	if c.rt == nil || strings.TrimSpace(c.sessionID) == "" {
+		return runtime.TokenUsage{}, nil
+	}
+	return c.rt.TokenUsage(c.baseCtx, runtime.TokenUsageRequest{
+		AppName:   c.appName,
+		UserID:    c.userID,
+		SessionID: c.sessionID,
+	})
+}
+
+func renderStatusTokenUsage(c *cliConsole) error {
+	usage, err := c.sessionTokenUsage()
+	if err != nil {
+		return err
+	}
+	c.ui.Section("Usage")
+	if usage.IsZero() {
+		c.ui.KeyValue("tokens", "none recorded")
+		return nil
+	}
+	c.ui.KeyValue("session", formatTokenTotals(usage.Total))
+	if turn, ok := usage.LastTurn(); ok {
+		c.ui.KeyValue("last_turn", formatTokenTotals(turn.Totals))
+	}
+	for _, item := range usage.Models {
+		label := strings.Trim(strings.TrimSpace(item.Provider)+"/"+strings.TrimSpace(item.Model), "/")
+		c.ui.KeyValue(stringOrDash(label), formatTokenTotals(item.Totals))
+	}
+	return nil
+}
+
+// formatTokenTotals renders one usage line, e.g.
+// "in=12.3k (cache_read=8k) out=1.2k (reasoning=400) calls=3 cost=$0.0412".
+func formatTokenTotals(totals tokenusage.Totals) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "in=%s", formatCompactTokenUsage(totals.PromptTokens))
+	var cache []string
+	if totals.CacheReadTokens > 0 {
+		cache = append(cache, "cache_read="+formatCompactTokenUsage(totals.CacheReadTokens))
+	}
+	if totals.CacheWriteTokens > 0 {
+		cache = append(cache, "cache_write="+formatCompactTokenUsage(totals.CacheWriteTokens))
+	}
+	if len(cache) > 0 {
+		fmt.Fprintf(&b, " (%s)", strings.Join(cache, " "))
+	}
+	fmt.Fprintf(&b, " out=%s", formatCompactTokenUsage(totals.CompletionTokens))
+	if totals.ReasoningTokens > 0 {
+		fmt.Fprintf(&b, " (reasoning=%s)", formatCompactTokenUsage(totals.ReasoningTokens))
+	}
+	fmt.Fprintf(&b, " calls=%d cost=%s", totals.Requests, formatUsageCost(totals))
+	return b.String()
+}
+
+func formatUsageCost(totals tokenusage.Totals) string {
+	if totals.UnpricedRequests >= totals.Requests {
+		return "unknown"
+	}
+	cost := fmt.Sprintf("$%.4f", totals.CostUSD)
+	if totals.UnpricedRequests > 0 {
+		cost += fmt.Sprintf(" (+%d unpriced)", totals.UnpricedRequests)
+	}
+	return cost
+}
@@ -94,6 +94,7 @@ func (u *ui) Separator() {
 const approvalPromptAllowDeny = "  proceed / cancel (Esc): "
 const approvalPromptAllowAlwaysDeny = "  proceed / session / cancel (Esc): "
 const toolAuthPrompt = "  proceed / session / cancel (Esc): "
+const approvalPromptWithGrants = "  proceed / session / workspace / global / cancel (Esc): "
 
 // ApprovalTitle prints the approval request title.
 func (u *ui) ApprovalTitle(title string) {
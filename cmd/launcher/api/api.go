@@ -26,16 +26,16 @@ func (l *apiLauncher) Parse(args []string) ([]string, error) {
 }
 
 func (l *apiLauncher) CommandLineSyntax() string {
-	return "  api [flags] (reserved for HTTP API service launcher)"
+	return "  api [shared CLI flags] [-addr host:port] [-auth-token-env NAME]\n  Example: api -addr 127.0.0.1:7878 -auth-token-env CAELIS_API_TOKEN"
 }
 
 func (l *apiLauncher) SimpleDescription() string {
-	return "start API frontend (placeholder)"
+	return "start HTTP/SSE API frontend"
 }
 
 func (l *apiLauncher) Run(ctx context.Context) error {
 	if l.run == nil {
-		return fmt.Errorf("launcher(api): not implemented")
+		return fmt.Errorf("launcher(api): run function is nil")
 	}
 	return l.run(ctx, l.args)
 }
@@ -6,16 +6,17 @@ import (
 	"testing"
 )
 
-func TestNewLauncherExposesOnlyConsoleAndACP(t *testing.T) {
+func TestNewLauncherExposesConsoleACPAPIAndWeb(t *testing.T) {
 	launcher := NewLauncher(
 		func(context.Context, []string) error { return nil },
 		func(context.Context, []string) error { return nil },
+		func(context.Context, []string) error { return nil },
+		func(context.Context, []string) error { return nil },
 	)
 	syntax := launcher.CommandLineSyntax()
-	if !strings.Contains(syntax, "console") || !strings.Contains(syntax, "acp") {
-		t.Fatalf("expected console and acp in launcher syntax, got %q", syntax)
-	}
-	if strings.Contains(syntax, "api") || strings.Contains(syntax, "web") {
-		t.Fatalf("did not expect api/web in launcher syntax, got %q", syntax)
+	for _, keyword := range []string{"console", "acp", "[api]", "[web]"} {
+		if !strings.Contains(syntax, keyword) {
+			t.Fatalf("expected %s in launcher syntax, got %q", keyword, syntax)
+		}
 	}
 }
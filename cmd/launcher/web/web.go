@@ -26,16 +26,16 @@ func (l *webLauncher) Parse(args []string) ([]string, error) {
 }
 
 func (l *webLauncher) CommandLineSyntax() string {
-	return "  web [flags] (reserved for Web frontend launcher)"
+	return "  web [shared CLI flags] [-addr host:port] [-auth-token-env NAME]\n  Example: web -addr 127.0.0.1:7879"
 }
 
 func (l *webLauncher) SimpleDescription() string {
-	return "start Web frontend (placeholder)"
+	return "start browser console frontend"
 }
 
 func (l *webLauncher) Run(ctx context.Context) error {
 	if l.run == nil {
-		return fmt.Errorf("launcher(web): not implemented")
+		return fmt.Errorf("launcher(web): run function is nil")
 	}
 	return l.run(ctx, l.args)
 }
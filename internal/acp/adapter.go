@@ -27,6 +27,7 @@ type AdapterSessionState struct {
 	Modes             *SessionModeState
 	AvailableCommands []AvailableCommand
 	PlanEntries       []PlanEntry
+	Usage             *SessionUsage
 }
 
 type LoadedSessionState struct {
@@ -38,7 +39,7 @@ type StartPromptRequest struct {
 	SessionID       string
 	InputText       string
 	ContentParts    []model.ContentPart
-	HasImages       bool
+	HasMedia        bool
 	Meta            map[string]any
 	OnSessionStream func(sessionstream.Update) error
 }
@@ -64,3 +65,17 @@ type Adapter interface {
 	CancelPrompt(string)
 	SessionFS(string) toolexec.FileSystem
 }
+
+// SessionStateProvider is optionally implemented by adapters whose session
+// state depends on conversation history. The server re-reads the state after
+// each prompt and notifies clients when the config options changed.
+type SessionStateProvider interface {
+	SessionState(context.Context, string) (AdapterSessionState, error)
+}
+
+// SessionOverlayProvider is optionally implemented by adapters whose sessions
+// can run in staged mode. After each turn the server asks the client which
+// of the staged changes to apply.
+type SessionOverlayProvider interface {
+	SessionOverlay(string) *toolexec.Overlay
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/OnslaughtSnail/caelis/internal/approvalgrant"
 	"github.com/OnslaughtSnail/caelis/internal/approvalqueue"
 	"github.com/OnslaughtSnail/caelis/internal/sessionmode"
 	toolexec "github.com/OnslaughtSnail/caelis/kernel/execenv"
@@ -24,8 +25,24 @@ type permissionBridge struct {
 	callAuth map[string]bool
 	toolAuth map[string]int
 	queue    *approvalqueue.Queue
+
+	// grants holds approvals remembered beyond this session; nil hides the
+	// workspace and global options.
+	grants    *approvalgrant.Store
+	workspace string
+}
+
+// grantTarget is what a workspace or global answer remembers.
+type grantTarget struct {
+	kind approvalgrant.Kind
+	key  string
 }
 
+const (
+	permOptionAllowWorkspace = "allow_workspace"
+	permOptionAllowGlobal    = "allow_global"
+)
+
 func newPermissionBridge(conn *Conn, sessionID string, modeResolver func() string) *permissionBridge {
 	return &permissionBridge{
 		conn:      conn,
@@ -50,6 +67,10 @@ func (p *permissionBridge) Approve(ctx context.Context, req toolexec.ApprovalReq
 	if allowed, decided := p.cached(scope); decided {
 		return allowed, nil
 	}
+	if p.grants.AllowsCommand(p.workspace, req.Command) {
+		return true, nil
+	}
+	grant := grantTarget{kind: approvalgrant.KindCommand, key: approvalgrant.CommandKey(req.Command)}
 	info, _ := toolexec.ToolCallInfoFromContext(ctx)
 	callID := strings.TrimSpace(info.ID)
 	if callID == "" {
@@ -81,12 +102,12 @@ func (p *permissionBridge) Approve(ctx context.Context, req toolexec.ApprovalReq
 			allowed = toolAllowed
 			return nil
 		}
-		outcome, err := p.request(ctx, scope, toolCall)
+		outcome, err := p.request(ctx, scope, grant, toolCall)
 		if err != nil {
 			return err
 		}
 		var applyErr error
-		allowed, applyErr = p.applyOutcome(scope, outcome)
+		allowed, applyErr = p.applyOutcome(scope, grant, outcome)
 		return applyErr
 	})
 	return allowed, err
@@ -106,6 +127,10 @@ func (p *permissionBridge) AuthorizeTool(ctx context.Context, req policy.ToolAut
 	if allowed, decided := p.cached(scope); decided {
 		return allowed, nil
 	}
+	if p.grants.AllowsTool(p.workspace, scope) {
+		return true, nil
+	}
+	grant := grantTarget{kind: approvalgrant.KindTool, key: scope}
 	info, _ := toolexec.ToolCallInfoFromContext(ctx)
 	callID := strings.TrimSpace(info.ID)
 	if callID == "" {
@@ -118,12 +143,12 @@ func (p *permissionBridge) AuthorizeTool(ctx context.Context, req policy.ToolAut
 			allowed = cachedAllowed
 			return nil
 		}
-		outcome, err := p.request(ctx, scope, toolCall)
+		outcome, err := p.request(ctx, scope, grant, toolCall)
 		if err != nil {
 			return err
 		}
 		var applyErr error
-		allowed, applyErr = p.applyOutcome(scope, outcome)
+		allowed, applyErr = p.applyOutcome(scope, grant, outcome)
 		if applyErr == nil {
 			p.rememberCallDecision(callID, allowed)
 			p.rememberToolDecision(req.ToolName, allowed)
@@ -133,7 +158,7 @@ func (p *permissionBridge) AuthorizeTool(ctx context.Context, req policy.ToolAut
 	return allowed, err
 }
 
-func (p *permissionBridge) request(ctx context.Context, scope string, toolCall ToolCallUpdate) (RequestPermissionResponse, error) {
+func (p *permissionBridge) request(ctx context.Context, scope string, grant grantTarget, toolCall ToolCallUpdate) (RequestPermissionResponse, error) {
 	options := []PermissionOption{
 		{OptionID: "allow_once", Name: "Allow once", Kind: PermAllowOnce},
 		{OptionID: "reject_once", Name: "Reject once", Kind: PermRejectOnce},
@@ -144,6 +169,12 @@ func (p *permissionBridge) request(ctx context.Context, scope string, toolCall T
 			{OptionID: "reject_always", Name: "Always reject", Kind: PermRejectAlways},
 		}, options...)
 	}
+	if p.grants != nil && strings.TrimSpace(grant.key) != "" {
+		options = append([]PermissionOption{
+			{OptionID: permOptionAllowWorkspace, Name: "Always allow " + grant.key + " in this workspace", Kind: PermAllowAlways},
+			{OptionID: permOptionAllowGlobal, Name: "Always allow " + grant.key + " everywhere", Kind: PermAllowAlways},
+		}, options...)
+	}
 	var resp RequestPermissionResponse
 	err := p.conn.Call(ctx, MethodSessionReqPermission, RequestPermissionRequest{
 		SessionID: p.sessionID,
@@ -153,7 +184,7 @@ func (p *permissionBridge) request(ctx context.Context, scope string, toolCall T
 	return resp, err
 }
 
-func (p *permissionBridge) applyOutcome(scope string, resp RequestPermissionResponse) (bool, error) {
+func (p *permissionBridge) applyOutcome(scope string, grant grantTarget, resp RequestPermissionResponse) (bool, error) {
 	var kind struct {
 		Outcome string `json:"outcome"`
 	}
@@ -174,6 +205,16 @@ func (p *permissionBridge) applyOutcome(scope string, resp RequestPermissionResp
 		case "allow_always":
 			p.cache(scope, true)
 			return true, nil
+		case permOptionAllowWorkspace, permOptionAllowGlobal:
+			grantScope := approvalgrant.ScopeWorkspace
+			if selected.OptionID == permOptionAllowGlobal {
+				grantScope = approvalgrant.ScopeGlobal
+			}
+			if _, err := p.grants.Add(p.workspace, grant.kind, grant.key, grantScope); err != nil {
+				return false, err
+			}
+			p.cache(scope, true)
+			return true, nil
 		case "reject_always":
 			p.cache(scope, false)
 			return false, &toolexec.ApprovalAbortedError{Reason: "permission rejected"}
@@ -2,11 +2,13 @@ package acp
 
 import (
 	"context"
+	"encoding/json"
 	"io"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/OnslaughtSnail/caelis/internal/approvalgrant"
 	toolexec "github.com/OnslaughtSnail/caelis/kernel/execenv"
 	"github.com/OnslaughtSnail/caelis/kernel/policy"
 )
@@ -405,3 +407,66 @@ func TestPermissionBridge_AuthorizeToolRequestUsesScopedToolMetadata(t *testing.
 		t.Fatal("expected tool authorization payload")
 	}
 }
+
+func TestPermissionBridge_WorkspaceOptionPersistsAcrossSessions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c2sR, c2sW := io.Pipe()
+	s2cR, s2cW := io.Pipe()
+	clientConn := NewConn(s2cR, c2sW)
+	serverConn := NewConn(c2sR, s2cW)
+
+	var (
+		mu       sync.Mutex
+		requests int
+		options  []PermissionOption
+	)
+	go func() {
+		_ = serverConn.Serve(ctx, func(_ context.Context, msg Message) (any, *RPCError) {
+			var req RequestPermissionRequest
+			_ = json.Unmarshal(msg.Params, &req)
+			mu.Lock()
+			requests++
+			options = req.Options
+			mu.Unlock()
+			return RequestPermissionResponse{
+				Outcome: mustMarshalRaw(SelectedPermissionOutcome{
+					Outcome:  "selected",
+					OptionID: permOptionAllowWorkspace,
+				}),
+			}, nil
+		}, func(context.Context, Message) {})
+	}()
+	go func() {
+		_ = clientConn.Serve(ctx, func(context.Context, Message) (any, *RPCError) {
+			return nil, &RPCError{Code: -32601, Message: "method not found"}
+		}, func(context.Context, Message) {})
+	}()
+
+	grants := approvalgrant.NewStore(nil)
+	first := newPermissionBridge(clientConn, "session-1", nil)
+	first.grants = grants
+	first.workspace = "/repo"
+	allowed, err := first.Approve(context.Background(), toolexec.ApprovalRequest{ToolName: "BASH", Command: "go build ./..."})
+	if err != nil || !allowed {
+		t.Fatalf("expected approval, allowed=%v err=%v", allowed, err)
+	}
+
+	second := newPermissionBridge(clientConn, "session-2", nil)
+	second.grants = grants
+	second.workspace = "/repo"
+	allowed, err = second.Approve(context.Background(), toolexec.ApprovalRequest{ToolName: "BASH", Command: "go build ./cmd/..."})
+	if err != nil || !allowed {
+		t.Fatalf("expected stored grant to approve, allowed=%v err=%v", allowed, err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if requests != 1 {
+		t.Fatalf("expected one permission request, got %d", requests)
+	}
+	if len(options) == 0 || options[0].OptionID != permOptionAllowWorkspace || options[0].Kind != PermAllowAlways {
+		t.Fatalf("expected workspace option first, got %#v", options)
+	}
+}
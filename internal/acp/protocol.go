@@ -48,6 +48,7 @@ const (
 	UpdateCurrentMode   = "current_mode_update"
 	UpdateConfigOption  = "config_option_update"
 	UpdateSessionInfo   = "session_info_update"
+	UpdateUsage         = "usage_update"
 )
 
 const (
@@ -228,6 +229,12 @@ type ImageContent struct {
 	URI      string `json:"uri,omitempty"`
 }
 
+type AudioContent struct {
+	Type     string `json:"type"`
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
 type ResourceLink struct {
 	Type        string `json:"type"`
 	Name        string `json:"name"`
@@ -329,6 +336,36 @@ type SessionInfoUpdate struct {
 	UpdatedAt     *string `json:"updatedAt,omitempty"`
 }
 
+// TokenUsage is a token count breakdown. InputTokens includes the cached
+// tokens and OutputTokens includes the thought tokens.
+type TokenUsage struct {
+	InputTokens       int `json:"inputTokens"`
+	OutputTokens      int `json:"outputTokens"`
+	TotalTokens       int `json:"totalTokens"`
+	ThoughtTokens     int `json:"thoughtTokens,omitempty"`
+	CachedReadTokens  int `json:"cachedReadTokens,omitempty"`
+	CachedWriteTokens int `json:"cachedWriteTokens,omitempty"`
+}
+
+type UsageCost struct {
+	Amount   float64 `json:"amount"`
+	Currency string  `json:"currency"`
+}
+
+// SessionUsage is the cumulative token usage and cost of a session together
+// with the share spent on the latest turn.
+type SessionUsage struct {
+	Total    TokenUsage  `json:"total"`
+	Cost     *UsageCost  `json:"cost,omitempty"`
+	LastTurn *TokenUsage `json:"lastTurn,omitempty"`
+	LastCost *UsageCost  `json:"lastTurnCost,omitempty"`
+}
+
+type UsageUpdate struct {
+	SessionUpdate string `json:"sessionUpdate"`
+	SessionUsage
+}
+
 type SetSessionModeRequest struct {
 	SessionID string `json:"sessionId"`
 	ModeID    string `json:"modeId"`
@@ -369,6 +406,11 @@ type ToolCallContent struct {
 	Type       string `json:"type"`
 	Content    any    `json:"content,omitempty"`
 	TerminalID string `json:"terminalId,omitempty"`
+	// Path, OldText and NewText describe a "diff" entry. OldText is nil for
+	// a new file.
+	Path    string  `json:"path,omitempty"`
+	OldText *string `json:"oldText,omitempty"`
+	NewText *string `json:"newText,omitempty"`
 }
 
 type ToolCall struct {
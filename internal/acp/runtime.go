@@ -33,10 +33,24 @@ func NewRuntime(base toolexec.Runtime, conn *Conn, sessionID, workspaceRoot, ses
 		sessionCWD = normalizeSessionDir(workspaceRoot)
 	}
 	baseFS := base.FileSystem()
+	hostBackend := "host"
+	remote := base.State().Remote != nil
+	if remote {
+		// The client's files and terminal are on this machine, not on the
+		// remote one, so the session works in the remote workdir through the
+		// base runtime only.
+		caps = ClientCapabilities{}
+		if wd, err := baseFS.Getwd(); err == nil {
+			sessionCWD = wd
+		}
+		hostBackend = remoteBackendName(base.State())
+	}
 	bridge := &runtimeBridge{
 		base:          base,
 		workspaceRoot: workspaceRoot,
 		sessionCWD:    sessionCWD,
+		hostBackend:   hostBackend,
+		remote:        remote,
 		modeResolver:  modeResolver,
 		strategy:      BridgeStrategyBaseOnly,
 	}
@@ -66,12 +80,15 @@ type runtimeBridge struct {
 	base            toolexec.Runtime
 	workspaceRoot   string
 	sessionCWD      string
+	hostBackend     string
+	remote          bool
 	fileSystem      toolexec.FileSystem
 	terminalBackend toolexec.Backend
 	modeResolver    func() string
 	strategy        BridgeStrategy
 	mu              sync.Mutex
 	tempFiles       []string
+	overlay         *toolexec.Overlay
 }
 
 func (r *runtimeBridge) PermissionMode() toolexec.PermissionMode {
@@ -121,32 +138,138 @@ func (r *runtimeBridge) State() toolexec.RuntimeState {
 	return state
 }
 
-func (r *runtimeBridge) FileSystem() toolexec.FileSystem { return r.fileSystem }
+func (r *runtimeBridge) FileSystem() toolexec.FileSystem {
+	overlay, staged, err := r.stagedOverlay()
+	if err != nil {
+		return stagedUnavailableFileSystem{err: err}
+	}
+	if staged {
+		return overlay.FileSystem(r.fileSystem)
+	}
+	return r.fileSystem
+}
+
+// Overlay returns the layer staged mode writes into, or nil in other modes.
+func (r *runtimeBridge) Overlay() *toolexec.Overlay {
+	overlay, _, _ := r.stagedOverlay()
+	return overlay
+}
+
+// stagedOverlay creates the staging layer the first time the session runs
+// in staged mode. Staged commands skip the client terminal, which cannot
+// mount the layer, and run in the sandbox instead.
+func (r *runtimeBridge) stagedOverlay() (*toolexec.Overlay, bool, error) {
+	if r == nil || r.modeResolver == nil || !sessionmode.IsStaged(r.modeResolver()) {
+		return nil, false, nil
+	}
+	if r.remote {
+		return nil, true, fmt.Errorf("acp: staged mode is not available for a remote workspace")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.overlay == nil {
+		overlay, err := toolexec.NewOverlay(r.workspaceRoot)
+		if err != nil {
+			return nil, true, err
+		}
+		r.overlay = overlay
+	}
+	return r.overlay, true, nil
+}
 
 func (r *runtimeBridge) Execute(ctx context.Context, req toolexec.CommandRequest) (toolexec.CommandResult, error) {
+	if overlay, staged, err := r.stagedOverlay(); staged {
+		if err != nil {
+			return toolexec.CommandResult{}, err
+		}
+		req.Overlay = overlay
+		return r.base.Execute(ctx, sessionCommandRequest(req, r.sessionCWD))
+	}
 	if backend := r.takeoverBackend(); backend != nil {
 		req.BackendName = backend.Name()
 		return backend.Execute(ctx, sessionCommandRequest(req, r.sessionCWD))
 	}
 	if r.PermissionMode() == toolexec.PermissionModeFullControl {
 		req.RouteHint = toolexec.ExecutionRouteHost
-		req.BackendName = "host"
+		req.BackendName = r.hostBackend
 	}
 	return r.base.Execute(ctx, sessionCommandRequest(req, r.sessionCWD))
 }
 
 func (r *runtimeBridge) Start(ctx context.Context, req toolexec.CommandRequest) (toolexec.Session, error) {
+	if overlay, staged, err := r.stagedOverlay(); staged {
+		if err != nil {
+			return nil, err
+		}
+		req.Overlay = overlay
+		return r.base.Start(ctx, sessionCommandRequest(req, r.sessionCWD))
+	}
 	if backend := r.takeoverBackend(); backend != nil {
 		req.BackendName = backend.Name()
 		return backend.Start(ctx, sessionCommandRequest(req, r.sessionCWD))
 	}
 	if r.PermissionMode() == toolexec.PermissionModeFullControl {
 		req.RouteHint = toolexec.ExecutionRouteHost
-		req.BackendName = "host"
+		req.BackendName = r.hostBackend
 	}
 	return r.base.Start(ctx, sessionCommandRequest(req, r.sessionCWD))
 }
 
+// PersistentShellEnabled reports whether commands run in the base runtime's
+// persistent shells. Commands that go to the client terminal or over a
+// staging layer cannot.
+func (r *runtimeBridge) PersistentShellEnabled() bool {
+	if r.takeoverBackend() != nil {
+		return false
+	}
+	if _, staged, _ := r.stagedOverlay(); staged {
+		return false
+	}
+	runner, ok := r.base.(toolexec.PersistentShellRunner)
+	return ok && runner.PersistentShellEnabled()
+}
+
+func (r *runtimeBridge) RunInShell(ctx context.Context, key string, req toolexec.CommandRequest) (toolexec.ShellResult, error) {
+	runner, err := r.shellRunner()
+	if err != nil {
+		return toolexec.ShellResult{}, err
+	}
+	return runner.RunInShell(ctx, key, r.shellCommandRequest(req))
+}
+
+func (r *runtimeBridge) StartInShell(ctx context.Context, key string, req toolexec.CommandRequest) (toolexec.ShellSession, error) {
+	runner, err := r.shellRunner()
+	if err != nil {
+		return nil, err
+	}
+	return runner.StartInShell(ctx, key, r.shellCommandRequest(req))
+}
+
+func (r *runtimeBridge) shellRunner() (toolexec.PersistentShellRunner, error) {
+	if !r.PersistentShellEnabled() {
+		return nil, fmt.Errorf("acp: persistent shell is not enabled")
+	}
+	return r.base.(toolexec.PersistentShellRunner), nil
+}
+
+// shellCommandRequest routes req like Start. A shell keeps its working
+// directory, so a command without one does not move it; a shell that has
+// not yet run a command of this session starts in the session directory,
+// which a shell variable records.
+func (r *runtimeBridge) shellCommandRequest(req toolexec.CommandRequest) toolexec.CommandRequest {
+	if r.PermissionMode() == toolexec.PermissionModeFullControl {
+		req.RouteHint = toolexec.ExecutionRouteHost
+		req.BackendName = r.hostBackend
+	}
+	if strings.TrimSpace(req.Dir) == "" && r.sessionCWD != "" {
+		quoted := "'" + strings.ReplaceAll(r.sessionCWD, "'", `'\''`) + "'"
+		req.Command = "[ -n \"${__caelis_session_dir-}\" ] || cd -- " + quoted + "\n__caelis_session_dir=1\n" + req.Command
+	} else {
+		req.Command = "__caelis_session_dir=1\n" + req.Command
+	}
+	return req
+}
+
 func (r *runtimeBridge) OpenSession(ref toolexec.CommandSessionRef) (toolexec.Session, error) {
 	if backend := r.takeoverBackend(); backend != nil && strings.EqualFold(strings.TrimSpace(ref.Backend), backend.Name()) {
 		return backend.OpenSession(ref.SessionID)
@@ -154,12 +277,21 @@ func (r *runtimeBridge) OpenSession(ref toolexec.CommandSessionRef) (toolexec.Se
 	return r.base.OpenSession(ref)
 }
 
+func (r *runtimeBridge) AllowNetworkHosts(hosts ...string) {
+	if updater, ok := r.base.(toolexec.NetworkAllowlistUpdater); ok {
+		updater.AllowNetworkHosts(hosts...)
+	}
+}
+
 func (r *runtimeBridge) Decide(ctx context.Context, req toolexec.RouteRequest) (toolexec.CommandDecision, error) {
 	_ = ctx
 	if r.PermissionMode() == toolexec.PermissionModeFullControl {
-		return toolexec.CommandDecision{Route: toolexec.ExecutionRouteHost, Backend: "host"}, nil
+		return toolexec.CommandDecision{Route: toolexec.ExecutionRouteHost, Backend: r.hostBackend}, nil
 	}
 	decision := r.base.DecideRoute(req.Command, req.SandboxPermission)
+	if _, staged, _ := r.stagedOverlay(); staged {
+		return decision, nil
+	}
 	if backend := r.takeoverBackend(); backend != nil {
 		decision.Backend = backend.Name()
 	}
@@ -197,6 +329,8 @@ func (r *runtimeBridge) Close() error {
 	r.mu.Lock()
 	tempFiles := append([]string(nil), r.tempFiles...)
 	r.tempFiles = nil
+	overlay := r.overlay
+	r.overlay = nil
 	r.mu.Unlock()
 	var firstErr error
 	for _, path := range tempFiles {
@@ -204,12 +338,26 @@ func (r *runtimeBridge) Close() error {
 			firstErr = err
 		}
 	}
+	if err := overlay.Close(); err != nil && firstErr == nil {
+		firstErr = err
+	}
 	if err := toolexec.Close(r.base); err != nil && firstErr == nil {
 		firstErr = err
 	}
 	return firstErr
 }
 
+// remoteBackendName returns the backend that runs commands on the remote
+// machine of state.
+func remoteBackendName(state toolexec.RuntimeState) string {
+	for _, backend := range state.Backends {
+		if backend.Kind == toolexec.BackendKindSSH {
+			return backend.Name
+		}
+	}
+	return string(toolexec.BackendKindSSH)
+}
+
 func (r *runtimeBridge) takeoverBackend() toolexec.Backend {
 	if r == nil || r.PermissionMode() == toolexec.PermissionModeFullControl {
 		return nil
@@ -217,6 +365,22 @@ func (r *runtimeBridge) takeoverBackend() toolexec.Backend {
 	return r.terminalBackend
 }
 
+// stagedUnavailableFileSystem fails every call so a staged session never
+// writes the workspace when its layer could not be created.
+type stagedUnavailableFileSystem struct {
+	err error
+}
+
+func (f stagedUnavailableFileSystem) Getwd() (string, error)                      { return "", f.err }
+func (f stagedUnavailableFileSystem) UserHomeDir() (string, error)                { return "", f.err }
+func (f stagedUnavailableFileSystem) Open(string) (*os.File, error)               { return nil, f.err }
+func (f stagedUnavailableFileSystem) ReadDir(string) ([]os.DirEntry, error)       { return nil, f.err }
+func (f stagedUnavailableFileSystem) Stat(string) (os.FileInfo, error)            { return nil, f.err }
+func (f stagedUnavailableFileSystem) ReadFile(string) ([]byte, error)             { return nil, f.err }
+func (f stagedUnavailableFileSystem) WriteFile(string, []byte, os.FileMode) error { return f.err }
+func (f stagedUnavailableFileSystem) Glob(string) ([]string, error)               { return nil, f.err }
+func (f stagedUnavailableFileSystem) WalkDir(string, fs.WalkDirFunc) error        { return f.err }
+
 type clientFileSystem struct {
 	base      toolexec.FileSystem
 	conn      *Conn
@@ -363,6 +527,14 @@ func (f *clientFileSystem) WriteFile(path string, data []byte, perm os.FileMode)
 	}, nil)
 }
 
+// Remove deletes path through the base file system, which may be remote.
+func (f *clientFileSystem) Remove(path string) error {
+	if remover, ok := f.base.(interface{ Remove(string) error }); ok {
+		return remover.Remove(path)
+	}
+	return os.Remove(path)
+}
+
 func (f *clientFileSystem) useClientReadFS() bool {
 	return f != nil && f.conn != nil && f.caps.FS.ReadTextFile
 }
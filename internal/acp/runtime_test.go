@@ -388,6 +388,48 @@ func TestNewRuntime_FullAccessModeBypassesSandbox(t *testing.T) {
 	}
 }
 
+func TestNewRuntime_StagedModeWritesIntoOverlay(t *testing.T) {
+	workspace := t.TempDir()
+	target := filepath.Join(workspace, "notes.txt")
+	if err := os.WriteFile(target, []byte("before\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	baseRuntime, err := toolexec.New(toolexec.Config{
+		PermissionMode: toolexec.PermissionModeDefault,
+		SandboxType:    testSandboxType(),
+		HostRunner:     stubRunner{},
+		SandboxRunner:  stubRunner{},
+	})
+	if err != nil {
+		t.Fatalf("new runtime: %v", err)
+	}
+	defer func() { _ = toolexec.Close(baseRuntime) }()
+
+	mode := "default"
+	rt := NewRuntime(baseRuntime, nil, "session-1", workspace, workspace, ClientCapabilities{}, func() string { return mode })
+	provider := rt.(toolexec.OverlayProvider)
+	if provider.Overlay() != nil {
+		t.Fatal("did not expect an overlay outside staged mode")
+	}
+	mode = "staged"
+	if err := rt.FileSystem().WriteFile(target, []byte("after\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(target); string(data) != "before\n" {
+		t.Fatalf("expected the workspace untouched, got %q", data)
+	}
+	changes, err := provider.Overlay().Changes()
+	if err != nil || len(changes) != 1 || changes[0].Path != target {
+		t.Fatalf("expected one staged change for %s, got %+v (%v)", target, changes, err)
+	}
+	if _, err := rt.Execute(context.Background(), toolexec.CommandRequest{Command: "true"}); !toolexec.IsErrorCode(err, toolexec.ErrorCodeStagedUnsupported) {
+		t.Fatalf("expected staged commands to need an overlay-capable sandbox, got %v", err)
+	}
+	if err := toolexec.Close(rt); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestNewRuntime_FullAccessModeKeepsLocalHostRunnerEvenWithTerminalCapability(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -793,3 +835,42 @@ func TestNewRuntime_TerminalCapabilityPreservesBaseFallbackDecision(t *testing.T
 		t.Fatalf("expected terminal takeover strategy, got %q", bridge.BridgeStrategy())
 	}
 }
+
+func TestNewRuntime_ForwardsPersistentShellsStartingInSessionCWD(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	baseRuntime, err := toolexec.New(toolexec.Config{
+		PermissionMode:  toolexec.PermissionModeFullControl,
+		SandboxType:     testSandboxType(),
+		SandboxRunner:   stubRunner{},
+		PersistentShell: true,
+	})
+	if err != nil {
+		t.Fatalf("new runtime: %v", err)
+	}
+	defer func() { _ = toolexec.Close(baseRuntime) }()
+
+	sessionCWD := t.TempDir()
+	rt := NewRuntime(baseRuntime, nil, "session-1", sessionCWD, sessionCWD, ClientCapabilities{}, nil)
+	runner, ok := rt.(toolexec.PersistentShellRunner)
+	if !ok || !runner.PersistentShellEnabled() {
+		t.Fatalf("expected the bridge to forward persistent shells, got %T", rt)
+	}
+	ctx := context.Background()
+	first, err := runner.RunInShell(ctx, "session-1", toolexec.CommandRequest{Command: "pwd", Timeout: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Stdout != sessionCWD+"\n" {
+		t.Fatalf("expected a new shell to start in %q, got %+v", sessionCWD, first)
+	}
+	if _, err := runner.RunInShell(ctx, "session-1", toolexec.CommandRequest{Command: "cd /", Timeout: time.Minute}); err != nil {
+		t.Fatal(err)
+	}
+	session, err := runner.StartInShell(ctx, "session-1", toolexec.CommandRequest{Command: "pwd", Timeout: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result, err := session.Wait(ctx, 0); err != nil || result.Stdout != "/\n" {
+		t.Fatalf("expected the shell to keep its directory, got %+v, %v", result, err)
+	}
+}
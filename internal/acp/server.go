@@ -7,6 +7,7 @@ import (
 	"reflect"
 	"strings"
 
+	"github.com/OnslaughtSnail/caelis/internal/approvalgrant"
 	"github.com/OnslaughtSnail/caelis/kernel/agent"
 	toolexec "github.com/OnslaughtSnail/caelis/kernel/execenv"
 	"github.com/OnslaughtSnail/caelis/kernel/model"
@@ -21,7 +22,7 @@ type SessionResources struct {
 	Close    func(context.Context) error
 }
 
-type SessionResourceFactory func(context.Context, string, string, ClientCapabilities, func() string) (*SessionResources, error)
+type SessionResourceFactory func(context.Context, string, string, ClientCapabilities, []MCPServer, func() string) (*SessionResources, error)
 type AgentSessionConfig struct {
 	ModeID       string
 	ConfigValues map[string]string
@@ -53,6 +54,9 @@ type ServerConfig struct {
 	AuthMethods     []AuthMethod
 	Authenticate    AuthValidator
 	Adapter         Adapter
+	// ApprovalGrants, when set, lets clients remember an approval for the
+	// session's workspace or globally.
+	ApprovalGrants *approvalgrant.Store
 }
 
 type Server struct {
@@ -104,6 +108,10 @@ func (s *Server) notifyConfigOptions(sessionID string, options []SessionConfigOp
 	return s.core.notifyConfigOptions(sessionID, options)
 }
 
+func (s *Server) notifyUsage(sessionID string, usage SessionUsage) error {
+	return s.core.notifyUsage(sessionID, usage)
+}
+
 func (s *Server) notifySessionInfo(sessionID string, title string, updatedAt string) error {
 	return s.core.notifySessionInfo(sessionID, title, updatedAt)
 }
@@ -7,6 +7,7 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/OnslaughtSnail/caelis/internal/sessionmode"
 	toolexec "github.com/OnslaughtSnail/caelis/kernel/execenv"
 	"github.com/OnslaughtSnail/caelis/kernel/model"
 	"github.com/OnslaughtSnail/caelis/kernel/policy"
@@ -45,8 +46,9 @@ func (c *serverCore) handleRequest(ctx context.Context, msg Message) (any, *RPCE
 			ProtocolVersion: s.cfg.ProtocolVersion,
 			AgentCapabilities: AgentCapabilities{
 				LoadSession:     true,
-				MCPCapabilities: MCPCapabilities{},
+				MCPCapabilities: MCPCapabilities{HTTP: true},
 				Prompt: PromptCapabilities{
+					Audio:           true,
 					EmbeddedContext: true,
 					Image:           caps.PromptImage,
 				},
@@ -177,9 +179,16 @@ func (c *serverCore) prompt(ctx context.Context, req PromptRequest) (resp Prompt
 			err = flushErr
 			resp = PromptResponse{}
 		}
+		if err == nil {
+			if refreshErr := s.refreshSessionState(ctx, req.SessionID, sess); refreshErr != nil {
+				err = refreshErr
+				resp = PromptResponse{}
+			}
+		}
 	}()
 
-	approver := sess.permissionBridge(s.cfg.Conn)
+	approver := sess.permissionBridge(s.cfg.Conn, s.cfg.ApprovalGrants)
+	runCtx = sessionmode.WithTurnContext(runCtx, sess.currentMode())
 	runCtx = toolexec.WithApprover(runCtx, approver)
 	runCtx = policy.WithToolAuthorizer(runCtx, approver)
 	var (
@@ -206,7 +215,7 @@ func (c *serverCore) prompt(ctx context.Context, req PromptRequest) (resp Prompt
 		SessionID:    req.SessionID,
 		InputText:    input.text,
 		ContentParts: append([]model.ContentPart(nil), input.contentParts...),
-		HasImages:    input.hasImages,
+		HasMedia:     input.hasMedia,
 		Meta:         CloneMeta(req.Meta),
 		OnSessionStream: func(update sessionstream.Update) error {
 			if err := s.notifySessionStreamUpdate(req.SessionID, update); err != nil {
@@ -265,6 +274,14 @@ func (c *serverCore) prompt(ctx context.Context, req PromptRequest) (resp Prompt
 	if streamErr := getSessionStreamErr(); streamErr != nil {
 		return PromptResponse{}, streamErr
 	}
+	if stopReason == StopReasonEndTurn {
+		if err := s.flushPendingContent(req.SessionID, sess); err != nil {
+			return PromptResponse{}, err
+		}
+		if err := s.reviewStagedChanges(ctx, req.SessionID); err != nil {
+			return PromptResponse{}, err
+		}
+	}
 	return PromptResponse{StopReason: stopReason}, nil
 }
 
@@ -308,6 +325,16 @@ func (c *serverCore) notifyConfigOptions(sessionID string, options []SessionConf
 	})
 }
 
+func (c *serverCore) notifyUsage(sessionID string, usage SessionUsage) error {
+	return c.server.cfg.Conn.Notify(MethodSessionUpdate, SessionNotification{
+		SessionID: sessionID,
+		Update: UsageUpdate{
+			SessionUpdate: UpdateUsage,
+			SessionUsage:  usage,
+		},
+	})
+}
+
 func (c *serverCore) notifySessionInfo(sessionID string, title string, updatedAt string) error {
 	update := SessionInfoUpdate{SessionUpdate: UpdateSessionInfo}
 	if trimmed := strings.TrimSpace(title); trimmed != "" {
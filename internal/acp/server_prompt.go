@@ -17,7 +17,7 @@ import (
 type promptInputResult struct {
 	text         string
 	contentParts []model.ContentPart
-	hasImages    bool
+	hasMedia     bool
 }
 
 func (s *Server) promptInput(ctx context.Context, sessionID string, blocks []json.RawMessage) (promptInputResult, error) {
@@ -54,9 +54,22 @@ func (s *Server) promptInput(ctx context.Context, sessionID string, blocks []jso
 			if err != nil {
 				return promptInputResult{}, err
 			}
-			if part.Type == model.ContentPartImage {
+			if part.IsMedia() {
 				orderedParts = append(orderedParts, part)
-				result.hasImages = true
+				result.hasMedia = true
+			}
+		case "audio":
+			var block AudioContent
+			if err := json.Unmarshal(raw, &block); err != nil {
+				return promptInputResult{}, err
+			}
+			part, err := resolveAudioBlock(block)
+			if err != nil {
+				return promptInputResult{}, err
+			}
+			if part.IsMedia() {
+				orderedParts = append(orderedParts, part)
+				result.hasMedia = true
 			}
 		case "resource_link":
 			var block ResourceLink
@@ -67,9 +80,9 @@ func (s *Server) promptInput(ctx context.Context, sessionID string, blocks []jso
 			if err != nil {
 				return promptInputResult{}, err
 			}
-			if part.Type == model.ContentPartImage {
+			if part.IsMedia() {
 				orderedParts = append(orderedParts, part)
-				result.hasImages = true
+				result.hasMedia = true
 			}
 			if strings.TrimSpace(text) != "" {
 				textParts = append(textParts, text)
@@ -87,9 +100,9 @@ func (s *Server) promptInput(ctx context.Context, sessionID string, blocks []jso
 			if err != nil {
 				return promptInputResult{}, err
 			}
-			if part.Type == model.ContentPartImage {
+			if part.IsMedia() {
 				orderedParts = append(orderedParts, part)
-				result.hasImages = true
+				result.hasMedia = true
 			}
 			if text != "" {
 				textParts = append(textParts, text)
@@ -103,7 +116,7 @@ func (s *Server) promptInput(ctx context.Context, sessionID string, blocks []jso
 		}
 	}
 	result.text = strings.TrimSpace(strings.Join(textParts, "\n\n"))
-	if result.hasImages {
+	if result.hasMedia {
 		result.contentParts = compactContentParts(orderedParts)
 	}
 	return result, nil
@@ -126,6 +139,10 @@ func (s *Server) resolveResourceLink(ctx context.Context, sessionID string, link
 		part, err := s.loadImageContentPart(sessionID, path, link.MimeType, link.Name)
 		return part, "", err
 	}
+	if mime, ok := linkMediaMIME(path, link.MimeType); ok {
+		part, err := s.loadMediaContentPart(sessionID, path, mime, link.Name)
+		return part, "", err
+	}
 	var resp ReadTextFileResponse
 	err = s.cfg.Conn.Call(ctx, MethodReadTextFile, ReadTextFileRequest{
 		SessionID: sessionID,
@@ -158,7 +175,7 @@ func (s *Server) resolveEmbeddedResource(block EmbeddedResource) (model.ContentP
 		if raw == "" {
 			return model.ContentPart{}, "", nil
 		}
-		data, mime, err := decodeEmbeddedImageData(raw, resource.MimeType)
+		data, mime, err := decodeEmbeddedData(raw, resource.MimeType)
 		if err != nil {
 			return model.ContentPart{}, "", err
 		}
@@ -169,6 +186,24 @@ func (s *Server) resolveEmbeddedResource(block EmbeddedResource) (model.ContentP
 		part, err := imageutil.ContentPartFromBytes(data, mime, name, nil)
 		return part, "", err
 	}
+	raw := strings.TrimSpace(resource.Blob)
+	if raw == "" {
+		raw = strings.TrimSpace(resource.Data)
+	}
+	if raw != "" {
+		if _, ok := imageutil.MediaTypeForMime(resource.MimeType); ok {
+			data, mime, err := decodeEmbeddedData(raw, resource.MimeType)
+			if err != nil {
+				return model.ContentPart{}, "", err
+			}
+			name := strings.TrimSpace(resource.Name)
+			if name == "" {
+				name = filepath.Base(strings.TrimSpace(resource.URI))
+			}
+			part, err := imageutil.MediaContentPartFromBytes(data, mime, name)
+			return part, "", err
+		}
+	}
 	text := strings.TrimSpace(resource.Text)
 	if text == "" {
 		return model.ContentPart{}, "", nil
@@ -195,7 +230,7 @@ func (s *Server) resolveImageBlock(ctx context.Context, sessionID string, block
 	if raw == "" {
 		return model.ContentPart{}, nil
 	}
-	data, mime, err := decodeEmbeddedImageData(raw, block.MimeType)
+	data, mime, err := decodeEmbeddedData(raw, block.MimeType)
 	if err != nil {
 		return model.ContentPart{}, err
 	}
@@ -226,6 +261,46 @@ func (s *Server) loadImageContentPart(sessionID string, path string, mime string
 	return imageutil.ContentPartFromBytes(data, mime, name, nil)
 }
 
+func resolveAudioBlock(block AudioContent) (model.ContentPart, error) {
+	raw := strings.TrimSpace(block.Data)
+	if raw == "" {
+		return model.ContentPart{}, nil
+	}
+	data, mime, err := decodeEmbeddedData(raw, block.MimeType)
+	if err != nil {
+		return model.ContentPart{}, err
+	}
+	if _, ok := imageutil.MediaTypeForMime(mime); !ok {
+		return model.ContentPart{}, fmt.Errorf("unsupported audio mime type %q", mime)
+	}
+	return imageutil.MediaContentPartFromBytes(data, mime, "audio")
+}
+
+func (s *Server) loadMediaContentPart(sessionID string, path string, mime string, name string) (model.ContentPart, error) {
+	fsys := s.sessionFS(sessionID)
+	if fsys == nil {
+		return model.ContentPart{}, fmt.Errorf("session file system is not available")
+	}
+	data, err := fsys.ReadFile(path)
+	if err != nil {
+		return model.ContentPart{}, err
+	}
+	if strings.TrimSpace(name) == "" {
+		name = filepath.Base(path)
+	}
+	return imageutil.MediaContentPartFromBytes(data, mime, name)
+}
+
+// linkMediaMIME reports whether a linked file should be attached as a PDF or
+// audio part rather than read as text.
+func linkMediaMIME(path string, mime string) (string, bool) {
+	mime = strings.ToLower(strings.TrimSpace(mime))
+	if mime == "application/pdf" || strings.HasPrefix(mime, "audio/") {
+		return mime, true
+	}
+	return imageutil.MediaMimeTypeForPath(path)
+}
+
 func (s *Server) linkIsImage(path string, mime string) bool {
 	if imageMIME(mime) {
 		return true
@@ -237,12 +312,12 @@ func imageMIME(mime string) bool {
 	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(mime)), "image/")
 }
 
-func decodeEmbeddedImageData(raw string, fallbackMime string) ([]byte, string, error) {
+func decodeEmbeddedData(raw string, fallbackMime string) ([]byte, string, error) {
 	value := strings.TrimSpace(raw)
 	if strings.HasPrefix(strings.ToLower(value), "data:") {
 		parts := strings.SplitN(value, ",", 2)
 		if len(parts) != 2 {
-			return nil, "", fmt.Errorf("invalid image data URL")
+			return nil, "", fmt.Errorf("invalid data URL")
 		}
 		header := strings.ToLower(parts[0])
 		mime := fallbackMime
@@ -252,13 +327,13 @@ func decodeEmbeddedImageData(raw string, fallbackMime string) ([]byte, string, e
 		}
 		data, err := base64.StdEncoding.DecodeString(parts[1])
 		if err != nil {
-			return nil, "", fmt.Errorf("invalid image data: %w", err)
+			return nil, "", fmt.Errorf("invalid base64 data: %w", err)
 		}
 		return data, mime, nil
 	}
 	data, err := base64.StdEncoding.DecodeString(value)
 	if err != nil {
-		return nil, "", fmt.Errorf("invalid image data: %w", err)
+		return nil, "", fmt.Errorf("invalid base64 data: %w", err)
 	}
 	return data, fallbackMime, nil
 }
@@ -303,7 +378,7 @@ func compactContentParts(parts []model.ContentPart) []model.ContentPart {
 				out[n-1].Text += "\n\n" + part.Text
 				continue
 			}
-		case model.ContentPartImage:
+		case model.ContentPartImage, model.ContentPartDocument, model.ContentPartAudio:
 			if strings.TrimSpace(part.Data) == "" && strings.TrimSpace(part.FileName) == "" {
 				continue
 			}
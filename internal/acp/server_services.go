@@ -102,3 +102,26 @@ func (s *serverServices) sessionFS(sessionID string) toolexec.FileSystem {
 	}
 	return s.adapter.SessionFS(sessionID)
 }
+
+func (s *serverServices) sessionOverlay(sessionID string) *toolexec.Overlay {
+	if s == nil || s.adapter == nil {
+		return nil
+	}
+	provider, ok := s.adapter.(SessionOverlayProvider)
+	if !ok {
+		return nil
+	}
+	return provider.SessionOverlay(sessionID)
+}
+
+func (s *serverServices) sessionState(ctx context.Context, sessionID string) (AdapterSessionState, bool, error) {
+	if s == nil || s.adapter == nil {
+		return AdapterSessionState{}, false, nil
+	}
+	provider, ok := s.adapter.(SessionStateProvider)
+	if !ok {
+		return AdapterSessionState{}, false, nil
+	}
+	state, err := provider.SessionState(ctx, sessionID)
+	return state, err == nil, err
+}
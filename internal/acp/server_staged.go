@@ -0,0 +1,160 @@
+package acp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	toolexec "github.com/OnslaughtSnail/caelis/kernel/execenv"
+)
+
+const (
+	stagedOptionApplyAll = "apply_all"
+	stagedOptionChoose   = "choose_files"
+	stagedOptionDiscard  = "discard_all"
+	stagedOptionApply    = "apply"
+	stagedOptionSkip     = "skip"
+)
+
+// reviewStagedChanges shows the changes a staged turn left in the session's
+// overlay as one tool call with a diff per file, then asks the client to
+// apply all, some or none of them. A cancelled request keeps the changes
+// staged for the next turn.
+func (s *Server) reviewStagedChanges(ctx context.Context, sessionID string) error {
+	overlay := s.svcs.sessionOverlay(sessionID)
+	if overlay == nil {
+		return nil
+	}
+	changes, err := overlay.Changes()
+	if err != nil || len(changes) == 0 {
+		return err
+	}
+	callID := "staged-review-" + sessionID
+	title := fmt.Sprintf("Review %d staged file(s)", len(changes))
+	content := make([]ToolCallContent, 0, len(changes))
+	locations := make([]ToolCallLocation, 0, len(changes))
+	for _, change := range changes {
+		content = append(content, stagedDiffContent(change))
+		locations = append(locations, ToolCallLocation{Path: change.Path})
+	}
+	if err := s.cfg.Conn.Notify(MethodSessionUpdate, SessionNotification{
+		SessionID: sessionID,
+		Update: ToolCall{
+			SessionUpdate: UpdateToolCall,
+			ToolCallID:    callID,
+			Title:         title,
+			Kind:          ToolKindEdit,
+			Status:        ToolStatusPending,
+			Content:       content,
+			Locations:     locations,
+		},
+	}); err != nil {
+		return err
+	}
+	choice, ok, err := s.requestStagedChoice(ctx, sessionID, ToolCallUpdate{
+		SessionUpdate: UpdateToolCallState,
+		ToolCallID:    callID,
+		Title:         &title,
+	}, []PermissionOption{
+		{OptionID: stagedOptionApplyAll, Name: "Apply all", Kind: PermAllowOnce},
+		{OptionID: stagedOptionChoose, Name: "Choose files", Kind: PermAllowOnce},
+		{OptionID: stagedOptionDiscard, Name: "Discard all", Kind: PermRejectOnce},
+	})
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return s.finishStagedReview(sessionID, callID, ToolStatusFailed, "Staged changes kept for review")
+	}
+	var paths []string
+	switch choice {
+	case stagedOptionApplyAll:
+	case stagedOptionChoose:
+		paths = []string{}
+		for _, change := range changes {
+			fileTitle := fmt.Sprintf("Apply %s (%s)?", change.Path, change.Kind)
+			fileChoice, ok, err := s.requestStagedChoice(ctx, sessionID, ToolCallUpdate{
+				SessionUpdate: UpdateToolCallState,
+				ToolCallID:    callID,
+				Title:         &fileTitle,
+				Content:       []ToolCallContent{stagedDiffContent(change)},
+				Locations:     []ToolCallLocation{{Path: change.Path}},
+			}, []PermissionOption{
+				{OptionID: stagedOptionApply, Name: "Apply", Kind: PermAllowOnce},
+				{OptionID: stagedOptionSkip, Name: "Skip", Kind: PermRejectOnce},
+			})
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return s.finishStagedReview(sessionID, callID, ToolStatusFailed, "Staged changes kept for review")
+			}
+			if fileChoice == stagedOptionApply {
+				paths = append(paths, change.Path)
+			}
+		}
+	default:
+		if err := overlay.Discard(); err != nil {
+			return err
+		}
+		return s.finishStagedReview(sessionID, callID, ToolStatusCompleted, "Discarded staged changes")
+	}
+	applied, err := overlay.Apply(paths)
+	if err != nil {
+		return s.finishStagedReview(sessionID, callID, ToolStatusFailed, "Apply staged changes failed: "+err.Error())
+	}
+	return s.finishStagedReview(sessionID, callID, ToolStatusCompleted, fmt.Sprintf("Applied %d staged file(s)", len(applied)))
+}
+
+// requestStagedChoice returns the selected option, or false when the client
+// cancelled the request.
+func (s *Server) requestStagedChoice(ctx context.Context, sessionID string, toolCall ToolCallUpdate, options []PermissionOption) (string, bool, error) {
+	var resp RequestPermissionResponse
+	if err := s.cfg.Conn.Call(ctx, MethodSessionReqPermission, RequestPermissionRequest{
+		SessionID: sessionID,
+		ToolCall:  toolCall,
+		Options:   options,
+	}, &resp); err != nil {
+		return "", false, err
+	}
+	var selected SelectedPermissionOutcome
+	if err := json.Unmarshal(resp.Outcome, &selected); err != nil {
+		return "", false, err
+	}
+	if strings.TrimSpace(selected.Outcome) != "selected" {
+		return "", false, nil
+	}
+	return strings.TrimSpace(selected.OptionID), true, nil
+}
+
+func (s *Server) finishStagedReview(sessionID string, callID string, status string, title string) error {
+	return s.cfg.Conn.Notify(MethodSessionUpdate, SessionNotification{
+		SessionID: sessionID,
+		Update: ToolCallUpdate{
+			SessionUpdate: UpdateToolCallState,
+			ToolCallID:    callID,
+			Title:         &title,
+			Status:        &status,
+		},
+	})
+}
+
+// stagedDiffContent describes one staged change as a diff. Binary files get
+// a text note instead, since diff entries carry text.
+func stagedDiffContent(change toolexec.OverlayChange) ToolCallContent {
+	if !utf8.Valid(change.Before) || !utf8.Valid(change.After) {
+		return ToolCallContent{
+			Type:    "content",
+			Content: TextContent{Type: "text", Text: fmt.Sprintf("%s: binary file %s", change.Path, change.Kind)},
+		}
+	}
+	newText := string(change.After)
+	content := ToolCallContent{Type: "diff", Path: change.Path, NewText: &newText}
+	if change.Kind != toolexec.OverlayChangeAdded {
+		oldText := string(change.Before)
+		content.OldText = &oldText
+	}
+	return content
+}
@@ -1,10 +1,14 @@
 package acp
 
 import (
+	"context"
 	"fmt"
+	"reflect"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/OnslaughtSnail/caelis/internal/approvalgrant"
 )
 
 type serverState struct {
@@ -25,6 +29,7 @@ type serverSession struct {
 	configOptions     []SessionConfigOption
 	availableCommands []AvailableCommand
 	planEntries       []PlanEntry
+	usage             *SessionUsage
 
 	streamMu      sync.Mutex
 	answerStream  partialContentState
@@ -161,6 +166,20 @@ func (s *serverSession) applyState(state AdapterSessionState) {
 	s.configOptions = append([]SessionConfigOption(nil), state.ConfigOptions...)
 	s.availableCommands = append([]AvailableCommand(nil), state.AvailableCommands...)
 	s.planEntries = append([]PlanEntry(nil), state.PlanEntries...)
+	if state.Usage != nil {
+		usage := *state.Usage
+		s.usage = &usage
+	}
+}
+
+func (s *serverSession) usageSnapshot() *SessionUsage {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+	if s.usage == nil {
+		return nil
+	}
+	usage := *s.usage
+	return &usage
 }
 
 func (s *serverSession) currentMode() string {
@@ -169,7 +188,7 @@ func (s *serverSession) currentMode() string {
 	return strings.TrimSpace(s.currentModeID)
 }
 
-func (s *serverSession) permissionBridge(conn *Conn) *permissionBridge {
+func (s *serverSession) permissionBridge(conn *Conn, grants *approvalgrant.Store) *permissionBridge {
 	if s == nil {
 		return nil
 	}
@@ -177,6 +196,8 @@ func (s *serverSession) permissionBridge(conn *Conn) *permissionBridge {
 	defer s.approvalMu.Unlock()
 	if s.approver == nil {
 		s.approver = newPermissionBridge(conn, s.id, s.currentMode)
+		s.approver.grants = grants
+		s.approver.workspace = s.cwd
 	}
 	return s.approver
 }
@@ -227,6 +248,34 @@ func (s *Server) syncSessionSnapshot(sessionID string, sess *serverSession, upda
 	if err := s.notifyPlan(sessionID, sess.planSnapshot()); err != nil {
 		return err
 	}
+	if usage := sess.usageSnapshot(); usage != nil {
+		if err := s.notifyUsage(sessionID, *usage); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// refreshSessionState pulls history-derived adapter state after a prompt and
+// re-sends config options and usage when they changed.
+func (s *Server) refreshSessionState(ctx context.Context, sessionID string, sess *serverSession) error {
+	state, ok, err := s.svcs.sessionState(ctx, sessionID)
+	if err != nil || !ok {
+		return nil
+	}
+	beforeOptions := sess.configOptionsSnapshot()
+	beforeUsage := sess.usageSnapshot()
+	sess.applyState(state)
+	if options := sess.configOptionsSnapshot(); !reflect.DeepEqual(beforeOptions, options) {
+		if err := s.notifyConfigOptions(sessionID, options); err != nil {
+			return err
+		}
+	}
+	if usage := sess.usageSnapshot(); usage != nil && !reflect.DeepEqual(beforeUsage, usage) {
+		if err := s.notifyUsage(sessionID, *usage); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
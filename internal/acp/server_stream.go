@@ -168,7 +168,7 @@ func (s *Server) notifySessionStreamUpdate(rootSessionID string, update sessions
 	}
 	if info, ok := runtime.LifecycleFromEvent(update.Event); ok {
 		switch info.Status {
-		case runtime.RunLifecycleStatusCompleted, runtime.RunLifecycleStatusFailed, runtime.RunLifecycleStatusInterrupted:
+		case runtime.RunLifecycleStatusCompleted, runtime.RunLifecycleStatusFailed, runtime.RunLifecycleStatusInterrupted, runtime.RunLifecycleStatusTerminated:
 			s.state.dropLiveStreamSession(sessionID)
 		}
 	}
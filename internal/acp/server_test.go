@@ -3,6 +3,7 @@ package acp
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"image"
@@ -209,6 +210,61 @@ func TestServer_PromptWithImageBlockForwardsImageContentParts(t *testing.T) {
 	}
 }
 
+func TestServer_PromptWithResourceAndAudioBlocksForwardsMediaContentParts(t *testing.T) {
+	llm := &scriptedLLM{
+		calls: [][]*model.Response{
+			{{Message: model.NewTextMessage(model.RoleAssistant, "read")}},
+		},
+	}
+	h := newHarness(t, harnessConfig{llm: llm})
+	defer h.close()
+
+	pdfPath := filepath.Join(t.TempDir(), "spec.pdf")
+	if err := os.WriteFile(pdfPath, []byte("%PDF-1.4 linked"), 0o644); err != nil {
+		t.Fatalf("write pdf: %v", err)
+	}
+
+	var initResp InitializeResponse
+	mustCall(t, h.client, MethodInitialize, InitializeRequest{
+		ProtocolVersion: CurrentProtocolVersion,
+	}, &initResp)
+	if !initResp.AgentCapabilities.Prompt.Audio {
+		t.Fatal("expected audio prompt capability")
+	}
+	var newResp NewSessionResponse
+	mustCall(t, h.client, MethodSessionNew, NewSessionRequest{CWD: h.workspace}, &newResp)
+	mustCall(t, h.client, MethodSessionPrompt, PromptRequest{
+		SessionID: newResp.SessionID,
+		Prompt: []json.RawMessage{
+			mustRaw(t, TextContent{Type: "text", Text: "compare these"}),
+			mustRaw(t, EmbeddedResource{Type: "resource", Resource: EmbeddedResourceData{
+				URI:      "file:///tmp/report.pdf",
+				Name:     "report.pdf",
+				Blob:     base64.StdEncoding.EncodeToString([]byte("%PDF-1.4 embedded")),
+				MimeType: "application/pdf",
+			}}),
+			mustRaw(t, ResourceLink{Type: "resource_link", Name: "spec.pdf", URI: "file://" + pdfPath}),
+			mustRaw(t, AudioContent{Type: "audio", MimeType: "audio/wav", Data: base64.StdEncoding.EncodeToString([]byte("RIFF"))}),
+		},
+	}, &PromptResponse{})
+
+	if len(llm.reqs) == 0 {
+		t.Fatal("expected model request")
+	}
+	var got []string
+	for _, msg := range llm.reqs[0].Messages {
+		if msg.Role != model.RoleUser {
+			continue
+		}
+		for _, part := range model.ContentPartsFromParts(msg.Parts) {
+			got = append(got, string(part.Type)+":"+part.FileName)
+		}
+	}
+	if strings.Join(got, ",") != "text:,document:report.pdf,document:spec.pdf,audio:audio" {
+		t.Fatalf("expected ordered text, document and audio parts, got %v", got)
+	}
+}
+
 func TestServer_PromptSilentlyDropsImagesWhenModelDoesNotSupportIt(t *testing.T) {
 	llm := &scriptedLLM{
 		calls: [][]*model.Response{
@@ -274,7 +274,7 @@ func (a *harnessAdapter) StartPrompt(ctx context.Context, req StartPromptRequest
 	if err != nil {
 		return StartPromptResult{}, err
 	}
-	if !req.HasImages && len(req.ContentParts) == 0 {
+	if !req.HasMedia && len(req.ContentParts) == 0 {
 		if inv, ok := slashcmd.Parse(req.InputText); ok && a.hasCommand(sess, inv.Name) {
 			return a.handleSlash(ctx, sess, inv)
 		}
@@ -298,7 +298,7 @@ func (a *harnessAdapter) StartPrompt(ctx context.Context, req StartPromptRequest
 	}
 	runInput := sessionmode.Inject(req.InputText, sess.mode())
 	runParts := append([]model.ContentPart(nil), req.ContentParts...)
-	if req.HasImages {
+	if req.HasMedia {
 		if controlText := strings.TrimSpace(sessionmode.Inject("", sess.mode())); controlText != "" {
 			runParts = append([]model.ContentPart{{Type: model.ContentPartText, Text: controlText}}, runParts...)
 		}
@@ -8,6 +8,7 @@ import (
 
 	toolexec "github.com/OnslaughtSnail/caelis/kernel/execenv"
 	"github.com/OnslaughtSnail/caelis/kernel/model"
+	toolfs "github.com/OnslaughtSnail/caelis/kernel/tool/builtin/filesystem"
 	"github.com/OnslaughtSnail/caelis/pkg/idutil"
 )
 
@@ -20,7 +21,7 @@ func toolKindForName(name string) string {
 	switch strings.ToUpper(strings.TrimSpace(name)) {
 	case "READ":
 		return ToolKindRead
-	case "WRITE", "PATCH":
+	case "WRITE", "PATCH", "EDIT":
 		return ToolKindEdit
 	case "SEARCH", "GLOB", "LIST":
 		return ToolKindSearch
@@ -40,6 +41,15 @@ func summarizeToolCallTitle(name string, args map[string]any) string {
 		if path, _ := args["path"].(string); strings.TrimSpace(path) != "" {
 			return fmt.Sprintf("%s %s", name, strings.TrimSpace(path))
 		}
+	case "EDIT":
+		paths := toolfs.EditTargetPaths(args)
+		switch len(paths) {
+		case 0:
+		case 1:
+			return fmt.Sprintf("EDIT %s", paths[0])
+		default:
+			return fmt.Sprintf("EDIT %s (+%d files)", paths[0], len(paths)-1)
+		}
 	case "BASH":
 		if command, _ := args["command"].(string); strings.TrimSpace(command) != "" {
 			return fmt.Sprintf("BASH %s", strings.TrimSpace(command))
@@ -112,6 +122,9 @@ func toolStatusForResult(toolName string, result map[string]any) string {
 }
 
 func toolLocations(args map[string]any, result map[string]any) []ToolCallLocation {
+	if locations := editToolLocations(args, result); len(locations) > 0 {
+		return locations
+	}
 	path := ""
 	if result != nil {
 		path, _ = result["path"].(string)
@@ -126,6 +139,36 @@ func toolLocations(args map[string]any, result map[string]any) []ToolCallLocatio
 	return []ToolCallLocation{{Path: path}}
 }
 
+// editToolLocations lists every file of a multi-file EDIT call or result.
+func editToolLocations(args map[string]any, result map[string]any) []ToolCallLocation {
+	var paths []string
+	if files, ok := result["files"].([]map[string]any); ok {
+		for _, file := range files {
+			if path, _ := file["path"].(string); strings.TrimSpace(path) != "" {
+				paths = append(paths, strings.TrimSpace(path))
+			}
+		}
+	} else if files, ok := result["files"].([]any); ok {
+		for _, one := range files {
+			file, _ := one.(map[string]any)
+			if path, _ := file["path"].(string); strings.TrimSpace(path) != "" {
+				paths = append(paths, strings.TrimSpace(path))
+			}
+		}
+	}
+	if len(paths) == 0 && result == nil && args != nil {
+		paths = toolfs.EditTargetPaths(args)
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+	out := make([]ToolCallLocation, 0, len(paths))
+	for _, path := range paths {
+		out = append(out, ToolCallLocation{Path: path})
+	}
+	return out
+}
+
 func hasToolError(result map[string]any) bool {
 	if result == nil {
 		return false
@@ -315,6 +315,12 @@ func decodeUpdate(raw json.RawMessage) (Update, error) {
 			return nil, err
 		}
 		return update, nil
+	case UpdateUsage:
+		var update UsageUpdate
+		if err := json.Unmarshal(raw, &update); err != nil {
+			return nil, err
+		}
+		return update, nil
 	default:
 		return nil, fmt.Errorf("%w: %s", errUnknownSessionUpdate, probe.SessionUpdate)
 	}
@@ -40,6 +40,7 @@ const (
 	UpdateCurrentMode   = internalacp.UpdateCurrentMode
 	UpdateConfigOption  = internalacp.UpdateConfigOption
 	UpdateSessionInfo   = internalacp.UpdateSessionInfo
+	UpdateUsage         = internalacp.UpdateUsage
 )
 
 type Message = internalacp.Message
@@ -72,6 +73,9 @@ type SessionSummary = internalacp.SessionSummary
 type SessionListResponse = internalacp.SessionListResponse
 type TextContent = internalacp.TextContent
 type ImageContent = internalacp.ImageContent
+type AudioContent = internalacp.AudioContent
+type EmbeddedResource = internalacp.EmbeddedResource
+type EmbeddedResourceData = internalacp.EmbeddedResourceData
 type PromptRequest = internalacp.PromptRequest
 type PromptResponse = internalacp.PromptResponse
 type SessionMode = internalacp.SessionMode
@@ -87,6 +91,7 @@ type PlanEntry = internalacp.PlanEntry
 type PlanUpdate = internalacp.PlanUpdate
 type CurrentModeUpdate = internalacp.CurrentModeUpdate
 type SessionInfoUpdate = internalacp.SessionInfoUpdate
+type UsageUpdate = internalacp.UsageUpdate
 type PermissionOption = internalacp.PermissionOption
 type RequestPermissionRequest = internalacp.RequestPermissionRequest
 type RequestPermissionResponse = internalacp.RequestPermissionResponse
@@ -0,0 +1,100 @@
+package acpadapter
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	internalacp "github.com/OnslaughtSnail/caelis/internal/acp"
+	"github.com/OnslaughtSnail/caelis/kernel/runtime"
+)
+
+const (
+	rewindConfigID     = "rewind"
+	rewindNoneValue    = "none"
+	rewindValuePrefix  = "turn-"
+	rewindLabelMaxRune = 60
+)
+
+func (s *Service) loadRewindTurns(ctx context.Context, sess *managedSession) {
+	if sess == nil {
+		return
+	}
+	turns, err := s.runtime.Turns(ctx, runtime.TurnsRequest{
+		AppName:   s.appName,
+		UserID:    s.userID,
+		SessionID: sess.id,
+	})
+	if err != nil {
+		turns = nil
+	}
+	sess.stateMu.Lock()
+	sess.rewindTurns = turns
+	sess.stateMu.Unlock()
+}
+
+// setRewindOption rewinds the session to before the selected turn. The option
+// is an action rather than a setting, so it always reads back as "none".
+func (s *Service) setRewindOption(ctx context.Context, sess *managedSession, value string) (internalacp.AdapterSessionState, error) {
+	if value != rewindNoneValue {
+		turn, err := strconv.Atoi(strings.TrimPrefix(value, rewindValuePrefix))
+		if !strings.HasPrefix(value, rewindValuePrefix) || err != nil || turn <= 0 {
+			return internalacp.AdapterSessionState{}, fmt.Errorf("unsupported value %q for config option %q", value, rewindConfigID)
+		}
+		if sess.activeHandle() != nil {
+			return internalacp.AdapterSessionState{}, fmt.Errorf("cannot rewind while a prompt is running")
+		}
+		req := runtime.RewindRequest{
+			AppName:   s.appName,
+			UserID:    s.userID,
+			SessionID: sess.id,
+			Turn:      turn,
+		}
+		if sess.resources != nil {
+			req.ExecRuntime = sess.resources.Runtime
+		}
+		if _, err := s.runtime.Rewind(ctx, req); err != nil {
+			return internalacp.AdapterSessionState{}, err
+		}
+	}
+	s.loadHistoryState(ctx, sess)
+	s.refreshDerivedState(sess)
+	return s.snapshot(sess), nil
+}
+
+func rewindConfigOption(turns []runtime.Turn) (internalacp.SessionConfigOption, bool) {
+	if len(turns) == 0 {
+		return internalacp.SessionConfigOption{}, false
+	}
+	options := make([]internalacp.SessionConfigSelectOption, 0, len(turns)+1)
+	options = append(options, internalacp.SessionConfigSelectOption{Value: rewindNoneValue, Name: "Keep current state"})
+	for i := len(turns) - 1; i >= 0; i-- {
+		turn := turns[i]
+		option := internalacp.SessionConfigSelectOption{
+			Value: rewindValuePrefix + strconv.Itoa(turn.Index),
+			Name:  fmt.Sprintf("%d. %s", turn.Index, clipRewindLabel(turn.Input)),
+		}
+		if len(turn.Files) > 0 {
+			option.Description = fmt.Sprintf("restores %d file(s)", len(turn.Files))
+		}
+		options = append(options, option)
+	}
+	return internalacp.SessionConfigOption{
+		Type:         "select",
+		ID:           rewindConfigID,
+		Name:         "Rewind",
+		Description:  "Undo file edits and history back to before a turn",
+		CurrentValue: rewindNoneValue,
+		Options:      options,
+	}, true
+}
+
+func clipRewindLabel(input string) string {
+	input = strings.Join(strings.Fields(input), " ")
+	runes := []rune(input)
+	if len(runes) <= rewindLabelMaxRune {
+		return input
+	}
+	return string(runes[:rewindLabelMaxRune-1]) + "…"
+}
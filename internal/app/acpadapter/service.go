@@ -25,6 +25,7 @@ import (
 	"github.com/OnslaughtSnail/caelis/kernel/sessionsvc"
 	"github.com/OnslaughtSnail/caelis/kernel/task"
 	"github.com/OnslaughtSnail/caelis/kernel/tool"
+	"github.com/OnslaughtSnail/caelis/kernel/tool/capability"
 	coreacpmeta "github.com/OnslaughtSnail/caelis/pkg/acpmeta"
 	"github.com/OnslaughtSnail/caelis/pkg/idutil"
 )
@@ -87,9 +88,10 @@ type Service struct {
 }
 
 type managedSession struct {
-	id        string
-	cwd       string
-	resources *internalacp.SessionResources
+	id         string
+	cwd        string
+	mcpServers []internalacp.MCPServer
+	resources  *internalacp.SessionResources
 
 	stateMu           sync.Mutex
 	modeID            string
@@ -98,6 +100,8 @@ type managedSession struct {
 	configOptions     []internalacp.SessionConfigOption
 	availableCommands []internalacp.AvailableCommand
 	planEntries       []internalacp.PlanEntry
+	rewindTurns       []runtime.Turn
+	usage             *internalacp.SessionUsage
 	promptText        string
 
 	runMu     sync.Mutex
@@ -191,13 +195,15 @@ func (s *Service) NewSession(ctx context.Context, req internalacp.AdapterNewSess
 	sess := &managedSession{
 		id:           sessionID,
 		cwd:          cwd,
+		mcpServers:   append([]internalacp.MCPServer(nil), req.MCPServers...),
 		modeID:       s.initialModeID(),
 		configValues: s.initialConfigValues(),
 		meta:         internalacp.CloneMeta(req.Meta),
 	}
 	s.applyMetaModelAlias(sess)
+	s.applyMetaAgentProfile(sess)
 	s.normalizeSessionConfig(sess)
-	resources, err := s.newSessionResources(ctx, sessionID, sess.cwd, caps, sess.mode)
+	resources, err := s.newSessionResources(ctx, sessionID, sess.cwd, caps, sess.mcpServers, sess.mode)
 	if err != nil {
 		return internalacp.AdapterSessionState{}, err
 	}
@@ -243,7 +249,7 @@ func (s *Service) LoadSession(ctx context.Context, req internalacp.AdapterLoadSe
 	if err := s.ensureSessionExists(ctx, sessRef); err != nil {
 		return internalacp.LoadedSessionState{}, err
 	}
-	loaded, state, err := s.loadSessionState(ctx, strings.TrimSpace(req.SessionID), cwd, caps, req.Meta)
+	loaded, state, err := s.loadSessionState(ctx, strings.TrimSpace(req.SessionID), cwd, caps, req.MCPServers, req.Meta)
 	if err != nil {
 		return internalacp.LoadedSessionState{}, err
 	}
@@ -270,6 +276,9 @@ func (s *Service) SetMode(ctx context.Context, req internalacp.AdapterSetModeReq
 	if !s.modeExists(modeID) {
 		return internalacp.AdapterSessionState{}, fmt.Errorf("unsupported mode %q", modeID)
 	}
+	if err := s.checkStagedModeChange(sess, modeID); err != nil {
+		return internalacp.AdapterSessionState{}, err
+	}
 	sess.stateMu.Lock()
 	sess.modeID = modeID
 	if s.hasConfigCategory("mode") {
@@ -301,6 +310,9 @@ func (s *Service) SetConfigOption(ctx context.Context, req internalacp.AdapterSe
 		return internalacp.AdapterSessionState{}, fmt.Errorf("configId is required")
 	}
 	value := strings.TrimSpace(req.Value)
+	if configID == rewindConfigID {
+		return s.setRewindOption(ctx, sess, value)
+	}
 	if !s.configOptionSupports(sess, configID, value) {
 		if _, ok := s.configTemplate(configID); !ok {
 			return internalacp.AdapterSessionState{}, fmt.Errorf("unsupported config option %q", configID)
@@ -308,6 +320,11 @@ func (s *Service) SetConfigOption(ctx context.Context, req internalacp.AdapterSe
 		return internalacp.AdapterSessionState{}, fmt.Errorf("unsupported value %q for config option %q", value, configID)
 	}
 	template, _ := s.configTemplate(configID)
+	if strings.TrimSpace(template.Category) == "mode" {
+		if err := s.checkStagedModeChange(sess, value); err != nil {
+			return internalacp.AdapterSessionState{}, err
+		}
+	}
 	sess.stateMu.Lock()
 	if sess.configValues == nil {
 		sess.configValues = map[string]string{}
@@ -339,7 +356,7 @@ func (s *Service) StartPrompt(ctx context.Context, req internalacp.StartPromptRe
 			return internalacp.StartPromptResult{}, err
 		}
 	}
-	if !req.HasImages && len(req.ContentParts) == 0 {
+	if !req.HasMedia && len(req.ContentParts) == 0 {
 		if inv, ok := slashcmd.Parse(req.InputText); ok && s.hasAvailableCommand(sess, inv.Name) {
 			return s.handleSlashCommand(ctx, sess, inv)
 		}
@@ -363,7 +380,7 @@ func (s *Service) StartPrompt(ctx context.Context, req internalacp.StartPromptRe
 	}
 	runInput := sessionmode.Inject(req.InputText, sess.mode())
 	runParts := append([]model.ContentPart(nil), req.ContentParts...)
-	if req.HasImages {
+	if req.HasMedia {
 		if controlText := strings.TrimSpace(sessionmode.Inject("", sess.mode())); controlText != "" {
 			runParts = append([]model.ContentPart{{
 				Type: model.ContentPartText,
@@ -404,6 +421,7 @@ func (s *Service) StartPrompt(ctx context.Context, req internalacp.StartPromptRe
 		ContentParts: runParts,
 		Agent:        ag,
 		Model:        llm,
+		Budget:       sessionAgentBudget(sess),
 	})
 	if err != nil {
 		cancel()
@@ -430,6 +448,30 @@ func (s *Service) CancelPrompt(sessionID string) {
 	sess.cancelActiveRun()
 }
 
+// SessionOverlay returns the layer a staged session writes into.
+func (s *Service) SessionOverlay(sessionID string) *toolexec.Overlay {
+	sess, err := s.session(sessionID)
+	if err != nil || sess == nil || sess.resources == nil {
+		return nil
+	}
+	if provider, ok := sess.resources.Runtime.(toolexec.OverlayProvider); ok {
+		return provider.Overlay()
+	}
+	return nil
+}
+
+// checkStagedModeChange keeps a session in staged mode while it has changes
+// the client has not applied or discarded.
+func (s *Service) checkStagedModeChange(sess *managedSession, modeID string) error {
+	if sessionmode.IsStaged(modeID) || !sessionmode.IsStaged(sess.mode()) {
+		return nil
+	}
+	if changes, err := s.SessionOverlay(sess.id).Changes(); err != nil || len(changes) > 0 {
+		return fmt.Errorf("session has staged changes; apply or discard them before leaving staged mode")
+	}
+	return nil
+}
+
 func (s *Service) SessionFS(sessionID string) toolexec.FileSystem {
 	sess, err := s.session(sessionID)
 	if err != nil || sess == nil || sess.resources == nil || sess.resources.Runtime == nil {
@@ -517,7 +559,7 @@ func (s *Service) appendAssistantText(ctx context.Context, sessionID string, tex
 	return ev, nil
 }
 
-func (s *Service) loadSessionState(ctx context.Context, sessionID string, resolvedCWD string, caps internalacp.ClientCapabilities, reqMeta map[string]any) (*managedSession, []*session.Event, error) {
+func (s *Service) loadSessionState(ctx context.Context, sessionID string, resolvedCWD string, caps internalacp.ClientCapabilities, mcpServers []internalacp.MCPServer, reqMeta map[string]any) (*managedSession, []*session.Event, error) {
 	loadFrom, err := s.baseSessionService(nil)
 	if err != nil {
 		return nil, nil, err
@@ -548,24 +590,28 @@ func (s *Service) loadSessionState(ctx context.Context, sessionID string, resolv
 		}
 		existing.setState(modeID, configValues, planEntries, meta)
 		s.normalizeSessionConfig(existing)
+		s.loadHistoryState(ctx, existing)
 		s.refreshDerivedState(existing)
 		return existing, loaded.Events, nil
 	}
 	sess := &managedSession{
 		id:           sessionID,
 		cwd:          resolvedCWD,
+		mcpServers:   append([]internalacp.MCPServer(nil), mcpServers...),
 		modeID:       modeID,
 		configValues: configValues,
 		meta:         internalacp.CloneMeta(meta),
 		planEntries:  append([]internalacp.PlanEntry(nil), planEntries...),
 	}
 	s.applyMetaModelAlias(sess)
+	s.applyMetaAgentProfile(sess)
 	s.normalizeSessionConfig(sess)
-	resources, err := s.newSessionResources(ctx, sessionID, sess.cwd, caps, sess.mode)
+	resources, err := s.newSessionResources(ctx, sessionID, sess.cwd, caps, sess.mcpServers, sess.mode)
 	if err != nil {
 		return nil, nil, err
 	}
 	sess.resources = resources
+	s.loadHistoryState(ctx, sess)
 	s.refreshDerivedState(sess)
 	s.storeSession(sess)
 	return sess, loaded.Events, nil
@@ -594,9 +640,73 @@ func (s *Service) sessionService(sess *managedSession) (*sessionsvc.Service, err
 	if err != nil {
 		return nil, fmt.Errorf("acpadapter: session service: %w", err)
 	}
+	profile, ok := sessionAgentProfile(sess)
+	if !ok || (len(profile.Tools) == 0 && !profile.ReadOnly) {
+		return service, nil
+	}
+	// Core tools are injected by the runtime, so a profile narrows the
+	// visible set with a policy hook rather than by editing cfg.Tools.
+	visible, err := service.VisibleTools()
+	if err != nil {
+		return nil, fmt.Errorf("acpadapter: session service: %w", err)
+	}
+	cfg.Policies = append(cfg.Policies, policy.ToolAllowlist(policy.ToolAllowlistConfig{
+		Allowed: profileToolNames(profile, visible),
+	}))
+	service, err = sessionsvc.New(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("acpadapter: session service: %w", err)
+	}
 	return service, nil
 }
 
+func sessionAgentProfile(sess *managedSession) (coreacpmeta.AgentProfile, bool) {
+	if sess == nil {
+		return coreacpmeta.AgentProfile{}, false
+	}
+	return coreacpmeta.AgentProfileFromMeta(sess.metaSnapshot())
+}
+
+// sessionAgentBudget returns the budget a delegated child session was spawned
+// with; the runtime enforces it on every turn of the child.
+func sessionAgentBudget(sess *managedSession) task.Budget {
+	if sess == nil {
+		return task.Budget{}
+	}
+	budget, _ := coreacpmeta.AgentBudgetFromMeta(sess.metaSnapshot())
+	return budget
+}
+
+// profileToolNames returns the visible tools a subagent profile keeps: those
+// on its allowlist, if any, and for read-only profiles only tools that declare
+// neither file writes nor command execution.
+func profileToolNames(profile coreacpmeta.AgentProfile, visible []tool.Tool) []string {
+	allowed := map[string]struct{}{}
+	for _, name := range profile.Tools {
+		allowed[strings.ToUpper(strings.TrimSpace(name))] = struct{}{}
+	}
+	out := make([]string, 0, len(visible))
+	for _, one := range visible {
+		if one == nil {
+			continue
+		}
+		name := strings.ToUpper(strings.TrimSpace(one.Name()))
+		if _, ok := allowed[name]; len(allowed) > 0 && !ok {
+			continue
+		}
+		if profile.ReadOnly {
+			declared := capability.Of(one)
+			if declared.Risk == capability.RiskUnknown ||
+				declared.HasOperation(capability.OperationFileWrite) ||
+				declared.HasOperation(capability.OperationExec) {
+				continue
+			}
+		}
+		out = append(out, name)
+	}
+	return out
+}
+
 func (s *Service) enableSelfSpawnForSession(sess *managedSession) bool {
 	if s == nil || !s.enableSelfSpawn {
 		return false
@@ -651,7 +761,25 @@ func (s *Service) snapshot(sess *managedSession) internalacp.AdapterSessionState
 		},
 		AvailableCommands: append([]internalacp.AvailableCommand(nil), sess.availableCommands...),
 		PlanEntries:       append([]internalacp.PlanEntry(nil), sess.planEntries...),
+		Usage:             sess.usage,
+	}
+}
+
+// SessionState re-reads history-derived state (rewind targets and token
+// usage) after a prompt so the server can push it to the client.
+func (s *Service) SessionState(ctx context.Context, sessionID string) (internalacp.AdapterSessionState, error) {
+	sess, err := s.session(sessionID)
+	if err != nil {
+		return internalacp.AdapterSessionState{}, err
 	}
+	s.loadHistoryState(ctx, sess)
+	s.refreshDerivedState(sess)
+	return s.snapshot(sess), nil
+}
+
+func (s *Service) loadHistoryState(ctx context.Context, sess *managedSession) {
+	s.loadRewindTurns(ctx, sess)
+	s.loadTokenUsage(ctx, sess)
 }
 
 func (s *Service) refreshDerivedState(sess *managedSession) {
@@ -665,7 +793,18 @@ func (s *Service) refreshDerivedState(sess *managedSession) {
 }
 
 func (s *Service) sessionConfigOptionsLocked(sess *managedSession) []internalacp.SessionConfigOption {
-	if sess == nil || len(s.sessionConfig) == 0 {
+	if sess == nil {
+		return nil
+	}
+	out := s.templateConfigOptionsLocked(sess)
+	if option, ok := rewindConfigOption(sess.rewindTurns); ok {
+		out = append(out, option)
+	}
+	return out
+}
+
+func (s *Service) templateConfigOptionsLocked(sess *managedSession) []internalacp.SessionConfigOption {
+	if len(s.sessionConfig) == 0 {
 		return nil
 	}
 	cfg := internalacp.AgentSessionConfig{
@@ -744,6 +883,27 @@ func (s *Service) applyMetaModelAlias(sess *managedSession) {
 	}
 }
 
+// applyMetaAgentProfile pins the model and reasoning effort a subagent
+// profile asks for. Unlike a model alias hint, the profile wins over any
+// inherited config value.
+func (s *Service) applyMetaAgentProfile(sess *managedSession) {
+	profile, ok := sessionAgentProfile(sess)
+	if !ok {
+		return
+	}
+	sess.stateMu.Lock()
+	defer sess.stateMu.Unlock()
+	if sess.configValues == nil {
+		sess.configValues = map[string]string{}
+	}
+	if profile.Model != "" {
+		sess.configValues["model"] = profile.Model
+	}
+	if profile.ReasoningEffort != "" {
+		sess.configValues["reasoning_effort"] = profile.ReasoningEffort
+	}
+}
+
 func (s *Service) persistSessionState(ctx context.Context, sessRef *session.Session, sess *managedSession) error {
 	if sessRef == nil || sess == nil {
 		return nil
@@ -836,10 +996,18 @@ func (s *Service) adjustSystemPrompt(sess *managedSession, promptText string) st
 	})
 	promptText = strings.TrimSpace(promptText)
 	const constraint = "## Session Constraints\n\nThis delegated ACP child session cannot call SPAWN. Complete the assigned task with the tools available in this session."
-	if promptText == "" {
-		return constraint
+	sections := []string{promptText, constraint}
+	if profile, ok := sessionAgentProfile(sess); ok {
+		lines := []string{"## Subagent Profile", "", "You are running as the " + profile.ID + " subagent."}
+		if profile.ReadOnly {
+			lines = append(lines, "This session is read-only: inspect and report, do not try to change files or run commands.")
+		}
+		if profile.Prompt != "" {
+			lines = append(lines, "", profile.Prompt)
+		}
+		sections = append(sections, strings.Join(lines, "\n"))
 	}
-	return strings.TrimSpace(promptText + "\n\n" + constraint)
+	return strings.TrimSpace(strings.Join(sections, "\n\n"))
 }
 
 func stripMarkdownSection(text string, heading string) string {
@@ -1080,8 +1248,8 @@ func (s *Service) configOptionSupports(sess *managedSession, id string, value st
 	return false
 }
 
-func (s *Service) newSessionResources(ctx context.Context, sessionID string, sessionCWD string, caps internalacp.ClientCapabilities, modeResolver func() string) (*internalacp.SessionResources, error) {
-	return s.sessionResourceFactory(ctx, sessionID, sessionCWD, caps, modeResolver)
+func (s *Service) newSessionResources(ctx context.Context, sessionID string, sessionCWD string, caps internalacp.ClientCapabilities, mcpServers []internalacp.MCPServer, modeResolver func() string) (*internalacp.SessionResources, error) {
+	return s.sessionResourceFactory(ctx, sessionID, sessionCWD, caps, mcpServers, modeResolver)
 }
 
 func (s *Service) hasAvailableCommand(sess *managedSession, name string) bool {
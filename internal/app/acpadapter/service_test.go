@@ -173,6 +173,118 @@ func TestServiceStartPromptEmitsCanonicalEvents(t *testing.T) {
 	}
 }
 
+func TestServiceRewindConfigOptionTruncatesHistory(t *testing.T) {
+	llm := &scriptedLLM{
+		calls: [][]*model.Response{
+			{{Message: model.NewTextMessage(model.RoleAssistant, "one")}},
+			{{Message: model.NewTextMessage(model.RoleAssistant, "two")}},
+		},
+	}
+	svc, cleanup := newTestService(t, testServiceConfig{llm: llm})
+	defer cleanup()
+
+	ctx := context.Background()
+	created, err := svc.NewSession(ctx, internalacp.AdapterNewSessionRequest{
+		CWD: "/workspace/project",
+	}, internalacp.ClientCapabilities{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if option := findConfigOption(created.ConfigOptions, rewindConfigID); option != nil {
+		t.Fatalf("expected no rewind option before the first turn, got %+v", option)
+	}
+	for _, input := range []string{"first", "second"} {
+		result, err := svc.StartPrompt(ctx, internalacp.StartPromptRequest{SessionID: created.SessionID, InputText: input})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, errs := drainPromptEvents(result.Handle.Events()); len(errs) > 0 {
+			t.Fatalf("unexpected prompt errors: %v", errs)
+		}
+		_ = result.Handle.Close()
+	}
+	state, err := svc.SessionState(ctx, created.SessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	option := findConfigOption(state.ConfigOptions, rewindConfigID)
+	if option == nil || option.CurrentValue != rewindNoneValue || len(option.Options) != 3 || option.Options[1].Value != "turn-2" {
+		t.Fatalf("unexpected rewind option %+v", option)
+	}
+
+	state, err = svc.SetConfigOption(ctx, internalacp.AdapterSetConfigOptionRequest{
+		SessionID: created.SessionID,
+		ConfigID:  rewindConfigID,
+		Value:     "turn-2",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if option := findConfigOption(state.ConfigOptions, rewindConfigID); option == nil || len(option.Options) != 2 {
+		t.Fatalf("expected one remaining turn, got %+v", option)
+	}
+	events, err := svc.store.ListEvents(ctx, svc.sessionRef(created.SessionID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ev := range events {
+		if ev.Message.Role == model.RoleUser && ev.Message.TextContent() == "second" {
+			t.Fatalf("expected second turn to be discarded, got %+v", events)
+		}
+	}
+	if _, err := svc.SetConfigOption(ctx, internalacp.AdapterSetConfigOptionRequest{
+		SessionID: created.SessionID,
+		ConfigID:  rewindConfigID,
+		Value:     "later",
+	}); err == nil {
+		t.Fatal("expected invalid rewind value to fail")
+	}
+}
+
+func TestServiceSessionStateReportsTokenUsage(t *testing.T) {
+	llm := &scriptedLLM{
+		calls: [][]*model.Response{
+			{{
+				Message: model.NewTextMessage(model.RoleAssistant, "hello"),
+				Usage:   model.Usage{PromptTokens: 30, CompletionTokens: 12, TotalTokens: 42, CacheReadTokens: 20, ReasoningTokens: 5},
+			}},
+		},
+	}
+	svc, cleanup := newTestService(t, testServiceConfig{llm: llm})
+	defer cleanup()
+
+	ctx := context.Background()
+	created, err := svc.NewSession(ctx, internalacp.AdapterNewSessionRequest{
+		CWD: "/workspace/project",
+	}, internalacp.ClientCapabilities{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.Usage != nil {
+		t.Fatalf("expected no usage before the first prompt, got %+v", created.Usage)
+	}
+	result, err := svc.StartPrompt(ctx, internalacp.StartPromptRequest{SessionID: created.SessionID, InputText: "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, errs := drainPromptEvents(result.Handle.Events()); len(errs) > 0 {
+		t.Fatalf("unexpected prompt errors: %v", errs)
+	}
+	_ = result.Handle.Close()
+
+	state, err := svc.SessionState(ctx, created.SessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := internalacp.TokenUsage{InputTokens: 30, OutputTokens: 12, TotalTokens: 42, ThoughtTokens: 5, CachedReadTokens: 20}
+	if state.Usage == nil || state.Usage.Total != want || state.Usage.LastTurn == nil || *state.Usage.LastTurn != want {
+		t.Fatalf("unexpected usage %+v", state.Usage)
+	}
+	if state.Usage.Cost != nil {
+		t.Fatalf("expected no cost without a pricer, got %+v", state.Usage.Cost)
+	}
+}
+
 func TestServiceCancelPromptStopsActiveRun(t *testing.T) {
 	blocking := &blockingLLM{started: make(chan struct{})}
 	svc, cleanup := newTestService(t, testServiceConfig{llm: blocking})
@@ -261,7 +373,7 @@ func TestServiceStartPromptFreezesSystemPromptPerLoadedSession(t *testing.T) {
 		SessionModes:      []internalacp.SessionMode{{ID: "default", Name: "Default"}},
 		DefaultModeID:     "default",
 		BuildSystemPrompt: func(string) (string, error) { return promptText, nil },
-		NewSessionResources: func(context.Context, string, string, internalacp.ClientCapabilities, func() string) (*internalacp.SessionResources, error) {
+		NewSessionResources: func(context.Context, string, string, internalacp.ClientCapabilities, []internalacp.MCPServer, func() string) (*internalacp.SessionResources, error) {
 			return &internalacp.SessionResources{Runtime: execRT}, nil
 		},
 		NewAgent: func(stream bool, _ string, systemPrompt string, _ internalacp.AgentSessionConfig) (agent.Agent, error) {
@@ -438,7 +550,7 @@ func TestServiceNewSession_SeedsModelFromSessionMeta(t *testing.T) {
 			return &scriptedLLM{calls: [][]*model.Response{{{Message: model.NewTextMessage(model.RoleAssistant, "ok")}}}}, nil
 		},
 		BuildSystemPrompt: func(string) (string, error) { return "test", nil },
-		NewSessionResources: func(context.Context, string, string, internalacp.ClientCapabilities, func() string) (*internalacp.SessionResources, error) {
+		NewSessionResources: func(context.Context, string, string, internalacp.ClientCapabilities, []internalacp.MCPServer, func() string) (*internalacp.SessionResources, error) {
 			return &internalacp.SessionResources{Runtime: execRT}, nil
 		},
 		NewAgent: func(stream bool, _ string, systemPrompt string, _ internalacp.AgentSessionConfig) (agent.Agent, error) {
@@ -541,6 +653,58 @@ func TestServiceSessionServiceDisablesSpawnForDelegatedChildSessions(t *testing.
 	}
 }
 
+func TestServiceAppliesSubagentProfileFromSessionMeta(t *testing.T) {
+	svc, cleanup := newTestService(t, testServiceConfig{})
+	defer cleanup()
+
+	state, err := svc.NewSession(context.Background(), internalacp.AdapterNewSessionRequest{
+		CWD: "/workspace/project",
+		Meta: map[string]any{
+			"caelis": map[string]any{
+				"delegatedChild": true,
+				"agentProfile": map[string]any{
+					"id":       "explorer",
+					"prompt":   "Map the code and report file paths.",
+					"tools":    []any{"read", "BASH", "WRITE", "TASK"},
+					"model":    "gpt-b",
+					"readOnly": true,
+				},
+			},
+		},
+	}, internalacp.ClientCapabilities{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := currentOptionValue(state.ConfigOptions, "model"); got != "gpt-b" {
+		t.Fatalf("expected profile model to override the default, got %q", got)
+	}
+	sess, err := svc.session(state.SessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessSvc, err := svc.sessionService(sess)
+	if err != nil {
+		t.Fatal(err)
+	}
+	visible, err := sessSvc.VisibleTools()
+	if err != nil {
+		t.Fatal(err)
+	}
+	profile, ok := sessionAgentProfile(sess)
+	if !ok {
+		t.Fatal("expected session to carry an agent profile")
+	}
+	if got := strings.Join(profileToolNames(profile, visible), ","); got != "READ,TASK" {
+		t.Fatalf("expected read-only allowlist READ,TASK, got %q", got)
+	}
+	prompt := svc.adjustSystemPrompt(sess, "base")
+	for _, want := range []string{"## Subagent Profile", "explorer subagent", "read-only", "Map the code and report file paths."} {
+		if !strings.Contains(prompt, want) {
+			t.Fatalf("expected system prompt to contain %q, got %q", want, prompt)
+		}
+	}
+}
+
 func TestServiceDelegatedChildPromptRemovesSpawnGuidance(t *testing.T) {
 	store := inmemory.New()
 	rt, err := runtime.New(runtime.Config{LogStore: store, StateStore: store})
@@ -584,7 +748,7 @@ func TestServiceDelegatedChildPromptRemovesSpawnGuidance(t *testing.T) {
 				"- Use SPAWN only for bounded delegated work or specialization.",
 			}, "\n"), nil
 		},
-		NewSessionResources: func(context.Context, string, string, internalacp.ClientCapabilities, func() string) (*internalacp.SessionResources, error) {
+		NewSessionResources: func(context.Context, string, string, internalacp.ClientCapabilities, []internalacp.MCPServer, func() string) (*internalacp.SessionResources, error) {
 			return &internalacp.SessionResources{Runtime: execRT}, nil
 		},
 		NewAgent: func(stream bool, _ string, systemPrompt string, _ internalacp.AgentSessionConfig) (agent.Agent, error) {
@@ -721,7 +885,7 @@ func newTestService(t *testing.T, cfg testServiceConfig) (*Service, func()) {
 			},
 		},
 		BuildSystemPrompt: func(string) (string, error) { return "test", nil },
-		NewSessionResources: func(context.Context, string, string, internalacp.ClientCapabilities, func() string) (*internalacp.SessionResources, error) {
+		NewSessionResources: func(context.Context, string, string, internalacp.ClientCapabilities, []internalacp.MCPServer, func() string) (*internalacp.SessionResources, error) {
 			return &internalacp.SessionResources{Runtime: execRT}, nil
 		},
 		NewAgent: func(stream bool, _ string, systemPrompt string, _ internalacp.AgentSessionConfig) (agent.Agent, error) {
@@ -838,6 +1002,15 @@ func currentOptionValue(options []internalacp.SessionConfigOption, id string) st
 	return ""
 }
 
+func findConfigOption(options []internalacp.SessionConfigOption, id string) *internalacp.SessionConfigOption {
+	for i := range options {
+		if options[i].ID == id {
+			return &options[i]
+		}
+	}
+	return nil
+}
+
 func hasAvailableCommand(cmds []internalacp.AvailableCommand, name string) bool {
 	for _, item := range cmds {
 		if item.Name == name {
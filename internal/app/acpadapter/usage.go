@@ -0,0 +1,59 @@
+package acpadapter
+
+import (
+	"context"
+
+	internalacp "github.com/OnslaughtSnail/caelis/internal/acp"
+	"github.com/OnslaughtSnail/caelis/kernel/runtime"
+	"github.com/OnslaughtSnail/caelis/kernel/tokenusage"
+)
+
+func (s *Service) loadTokenUsage(ctx context.Context, sess *managedSession) {
+	if sess == nil {
+		return
+	}
+	state, err := s.runtime.TokenUsage(ctx, runtime.TokenUsageRequest{
+		AppName:   s.appName,
+		UserID:    s.userID,
+		SessionID: sess.id,
+	})
+	var usage *internalacp.SessionUsage
+	if err == nil && !state.IsZero() {
+		usage = sessionUsageFromState(state)
+	}
+	sess.stateMu.Lock()
+	sess.usage = usage
+	sess.stateMu.Unlock()
+}
+
+func sessionUsageFromState(state runtime.TokenUsage) *internalacp.SessionUsage {
+	out := &internalacp.SessionUsage{
+		Total: tokenUsageFromTotals(state.Total),
+		Cost:  usageCostFromTotals(state.Total),
+	}
+	if turn, ok := state.LastTurn(); ok {
+		last := tokenUsageFromTotals(turn.Totals)
+		out.LastTurn = &last
+		out.LastCost = usageCostFromTotals(turn.Totals)
+	}
+	return out
+}
+
+func tokenUsageFromTotals(totals tokenusage.Totals) internalacp.TokenUsage {
+	return internalacp.TokenUsage{
+		InputTokens:       totals.PromptTokens,
+		OutputTokens:      totals.CompletionTokens,
+		TotalTokens:       totals.TotalTokens,
+		ThoughtTokens:     totals.ReasoningTokens,
+		CachedReadTokens:  totals.CacheReadTokens,
+		CachedWriteTokens: totals.CacheWriteTokens,
+	}
+}
+
+// usageCostFromTotals omits the cost when no call in the totals was priced.
+func usageCostFromTotals(totals tokenusage.Totals) *internalacp.UsageCost {
+	if totals.UnpricedRequests >= totals.Requests {
+		return nil
+	}
+	return &internalacp.UsageCost{Amount: totals.CostUSD, Currency: "USD"}
+}
@@ -24,6 +24,8 @@ import (
 	"github.com/OnslaughtSnail/caelis/kernel/runtime"
 	"github.com/OnslaughtSnail/caelis/kernel/session"
 	"github.com/OnslaughtSnail/caelis/kernel/sessionstream"
+	"github.com/OnslaughtSnail/caelis/kernel/task"
+	coreacpmeta "github.com/OnslaughtSnail/caelis/pkg/acpmeta"
 	"github.com/OnslaughtSnail/caelis/pkg/idutil"
 )
 
@@ -37,6 +39,9 @@ type Config struct {
 	ClientRuntime        toolexec.Runtime
 	ResolveAgentRegistry AgentRegistryResolver
 	NewAdapter           AdapterFactory
+	// SubagentBudget fills the limits a SPAWN request leaves unset for
+	// children that run on this process's own runtime.
+	SubagentBudget task.Budget
 }
 
 func NewACPSubagentRunnerFactory(cfg Config) runtime.SubagentRunnerFactory {
@@ -63,6 +68,7 @@ func NewACPSubagentRunnerFactory(cfg Config) runtime.SubagentRunnerFactory {
 			clientRuntime: cfg.ClientRuntime,
 			snapshots:     snapshots,
 			newAdapter:    cfg.NewAdapter,
+			budget:        cfg.SubagentBudget,
 			shared:        shared,
 		}
 	}
@@ -81,6 +87,7 @@ type selfACPSubagentRunner struct {
 	clientRuntime toolexec.Runtime
 	snapshots     *agentSnapshotCache
 	newAdapter    AdapterFactory
+	budget        task.Budget
 	shared        *sharedACPSubagentState
 }
 
@@ -191,6 +198,10 @@ func (r *selfACPSubagentRunner) RunSubagent(ctx context.Context, req agent.Subag
 		UserID:  r.parent.UserID,
 		ID:      strings.TrimSpace(target.requestedSessionID),
 	}, desc.ID)
+	sessionMeta = profileSessionMeta(sessionMeta, desc)
+	if desc.Transport == appagents.TransportSelf {
+		sessionMeta = coreacpmeta.WithAgentBudget(sessionMeta, req.Budget.WithDefaults(r.budget))
+	}
 	metaBase := r.delegationMetadata(ctx, target.requestedSessionID)
 	idleTimeout := req.IdleTimeout
 	if idleTimeout <= 0 {
@@ -598,6 +609,23 @@ func (r *selfACPSubagentRunner) runACPSubagent(ctx context.Context, desc appagen
 	return actualSessionID, meta, created, err
 }
 
+// profileSessionMeta stamps a subagent profile onto the child's session meta
+// so the loopback adapter applies its prompt, tools and model to the child.
+func profileSessionMeta(meta map[string]any, desc appagents.Descriptor) map[string]any {
+	if desc.Transport != appagents.TransportSelf || desc.Profile == nil {
+		return meta
+	}
+	meta = coreacpmeta.WithDelegatedChild(meta, true)
+	return coreacpmeta.WithAgentProfile(meta, coreacpmeta.AgentProfile{
+		ID:              desc.ID,
+		Prompt:          desc.Profile.Prompt,
+		Tools:           desc.Profile.Tools,
+		Model:           desc.Profile.Model,
+		ReasoningEffort: desc.Profile.ReasoningEffort,
+		ReadOnly:        desc.Profile.ReadOnly,
+	})
+}
+
 func remoteACPStartupContext(ctx context.Context, agentID string) (context.Context, context.CancelFunc) {
 	if !strings.EqualFold(strings.TrimSpace(agentID), "openclaw") {
 		return context.WithCancel(ctx)
@@ -849,8 +877,11 @@ func (r *selfACPSubagentRunner) InspectSubagent(ctx context.Context, childSessio
 
 func (r *selfACPSubagentRunner) failedResult(ctx context.Context, childSessionID string, childCreated bool, meta runtime.DelegationMetadata, agentName string, timeout, idleTimeout time.Duration, cause error) (agent.SubagentRunResult, error) {
 	status := runtime.RunLifecycleStatusFailed
-	if strings.Contains(strings.ToLower(strings.TrimSpace(fmt.Sprint(cause))), "context canceled") {
+	switch causeText := strings.ToLower(strings.TrimSpace(fmt.Sprint(cause))); {
+	case strings.Contains(causeText, "context canceled"):
 		status = runtime.RunLifecycleStatusInterrupted
+	case strings.Contains(causeText, "budget exhausted"):
+		status = runtime.RunLifecycleStatusTerminated
 	}
 	if strings.TrimSpace(childSessionID) != "" && r != nil && r.shared != nil && r.shared.tracker != nil {
 		r.shared.tracker.finish(agentName, childSessionID, meta.DelegationID, "", string(status), "", fmt.Sprint(cause))
@@ -46,7 +46,7 @@ func newTestACPAdapterFactory(rt *runtime.Runtime, store session.Store, execRT t
 			NewAgent: func(bool, string, string, internalacp.AgentSessionConfig) (agent.Agent, error) {
 				return ag, nil
 			},
-			NewSessionResources: func(_ context.Context, sessionID string, sessionCWD string, caps internalacp.ClientCapabilities, modeResolver func() string) (*internalacp.SessionResources, error) {
+			NewSessionResources: func(_ context.Context, sessionID string, sessionCWD string, caps internalacp.ClientCapabilities, mcpServers []internalacp.MCPServer, modeResolver func() string) (*internalacp.SessionResources, error) {
 				execRuntimeACP := internalacp.NewRuntime(execRT, conn, sessionID, workspaceRoot, sessionCWD, caps, modeResolver)
 				tools, err := tool.RebindRuntime(extraTools, execRuntimeACP)
 				if err != nil {
@@ -251,7 +251,7 @@ func TestACPSpawnLeavesChildSessionInDefaultMode(t *testing.T) {
 			NewAgent: func(bool, string, string, internalacp.AgentSessionConfig) (agent.Agent, error) {
 				return ag, nil
 			},
-			NewSessionResources: func(_ context.Context, sessionID string, sessionCWD string, caps internalacp.ClientCapabilities, modeResolver func() string) (*internalacp.SessionResources, error) {
+			NewSessionResources: func(_ context.Context, sessionID string, sessionCWD string, caps internalacp.ClientCapabilities, mcpServers []internalacp.MCPServer, modeResolver func() string) (*internalacp.SessionResources, error) {
 				execRuntimeACP := internalacp.NewRuntime(execRT, conn, sessionID, "/workspace", sessionCWD, caps, modeResolver)
 				return &internalacp.SessionResources{Runtime: execRuntimeACP}, nil
 			},
@@ -1182,7 +1182,7 @@ func TestDelegatedSelfChildSessionCannotSpawnExternalACPChild(t *testing.T) {
 			EnablePlan:            true,
 			EnableSelfSpawn:       true,
 			SubagentRunnerFactory: subagentFactory,
-			NewSessionResources: func(_ context.Context, sessionID string, sessionCWD string, caps internalacp.ClientCapabilities, modeResolver func() string) (*internalacp.SessionResources, error) {
+			NewSessionResources: func(_ context.Context, sessionID string, sessionCWD string, caps internalacp.ClientCapabilities, mcpServers []internalacp.MCPServer, modeResolver func() string) (*internalacp.SessionResources, error) {
 				execRuntimeACP := internalacp.NewRuntime(execRT, conn, sessionID, workspace, sessionCWD, caps, modeResolver)
 				return &internalacp.SessionResources{Runtime: execRuntimeACP}, nil
 			},
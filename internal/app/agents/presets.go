@@ -174,6 +174,7 @@ func ResolveDescriptor(d Descriptor) (Descriptor, error) {
 func cloneDescriptor(d Descriptor) Descriptor {
 	d.Args = append([]string(nil), d.Args...)
 	d.Env = cloneStringMap(d.Env)
+	d.Profile = cloneProfile(d.Profile)
 	return d
 }
 
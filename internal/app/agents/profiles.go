@@ -0,0 +1,201 @@
+package agents
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ProfileDirName is the directory, under ~/.agents and a workspace's
+// .agents, that holds subagent profile files.
+const ProfileDirName = "agents"
+
+// Profile customizes a self-hosted child session: its extra instructions,
+// the tools it may use, the model it runs on and whether it may change
+// anything.
+type Profile struct {
+	Prompt          string   `json:"prompt,omitempty"`
+	Tools           []string `json:"tools,omitempty"`
+	Model           string   `json:"model,omitempty"`
+	ReasoningEffort string   `json:"reasoningEffort,omitempty"`
+	ReadOnly        bool     `json:"readOnly,omitempty"`
+	Path            string   `json:"path,omitempty"`
+}
+
+// ProfileDiscoverResult includes discovered profiles and non-fatal warnings.
+type ProfileDiscoverResult struct {
+	Descriptors []Descriptor
+	Warnings    []error
+}
+
+var profileIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// ProfileDirs returns the profile directories for a workspace in load order:
+// the user's ~/.agents/agents first, then the workspace's .agents/agents, so
+// a workspace profile replaces a user profile with the same name.
+func ProfileDirs(workspaceDir string) []string {
+	dirs := []string{filepath.Join("~", ".agents", ProfileDirName)}
+	if workspaceDir = strings.TrimSpace(workspaceDir); workspaceDir != "" {
+		dirs = append(dirs, filepath.Join(workspaceDir, ".agents", ProfileDirName))
+	}
+	return dirs
+}
+
+// DiscoverProfiles loads every *.md subagent profile in dirs. Each file holds
+// front matter with name, description, model, reasoning_effort, tools and
+// read_only keys; the body becomes the profile's system prompt section.
+func DiscoverProfiles(dirs []string) ProfileDiscoverResult {
+	out := ProfileDiscoverResult{}
+	byID := map[string]Descriptor{}
+	for _, dir := range dirs {
+		dir = strings.TrimSpace(dir)
+		if dir == "" {
+			continue
+		}
+		resolvedDir, err := resolveProfileDir(dir)
+		if err != nil {
+			out.Warnings = append(out.Warnings, fmt.Errorf("agents: resolve %q: %w", dir, err))
+			continue
+		}
+		entries, err := os.ReadDir(resolvedDir)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				out.Warnings = append(out.Warnings, fmt.Errorf("agents: read dir %q: %w", resolvedDir, err))
+			}
+			continue
+		}
+		for _, entry := range entries {
+			if entry == nil || entry.IsDir() || !strings.EqualFold(filepath.Ext(entry.Name()), ".md") {
+				continue
+			}
+			desc, err := parseProfile(filepath.Join(resolvedDir, entry.Name()))
+			if err != nil {
+				out.Warnings = append(out.Warnings, err)
+				continue
+			}
+			byID[desc.ID] = desc
+		}
+	}
+	for _, desc := range byID {
+		out.Descriptors = append(out.Descriptors, desc)
+	}
+	sort.Slice(out.Descriptors, func(i, j int) bool { return out.Descriptors[i].ID < out.Descriptors[j].ID })
+	return out
+}
+
+func parseProfile(path string) (Descriptor, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return Descriptor{}, fmt.Errorf("agents: read %q: %w", path, err)
+	}
+	content := strings.ReplaceAll(string(raw), "\r\n", "\n")
+	fm, body := parseProfileFrontMatter(content)
+	id := strings.ToLower(strings.TrimSpace(fm["name"]))
+	if id == "" {
+		id = strings.ToLower(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)))
+	}
+	if !profileIDPattern.MatchString(id) {
+		return Descriptor{}, fmt.Errorf("agents: profile %q has invalid name %q", path, id)
+	}
+	if id == selfAgentID {
+		return Descriptor{}, fmt.Errorf("agents: profile %q cannot use the reserved name %q", path, selfAgentID)
+	}
+	description := strings.TrimSpace(fm["description"])
+	if description == "" {
+		return Descriptor{}, fmt.Errorf("agents: profile %q requires a description", path)
+	}
+	readOnly := false
+	switch value := strings.ToLower(strings.TrimSpace(fm["read_only"])); value {
+	case "", "false", "no":
+	case "true", "yes":
+		readOnly = true
+	default:
+		return Descriptor{}, fmt.Errorf("agents: profile %q has invalid read_only value %q", path, value)
+	}
+	return Descriptor{
+		ID:          id,
+		Name:        id,
+		Description: description,
+		Stability:   StabilityStable,
+		Transport:   TransportSelf,
+		Profile: &Profile{
+			Prompt:          strings.TrimSpace(body),
+			Tools:           parseProfileList(fm["tools"]),
+			Model:           strings.TrimSpace(fm["model"]),
+			ReasoningEffort: strings.ToLower(strings.TrimSpace(firstNonEmptyValue(fm["reasoning_effort"], fm["reasoning"]))),
+			ReadOnly:        readOnly,
+			Path:            path,
+		},
+	}, nil
+}
+
+func parseProfileFrontMatter(content string) (map[string]string, string) {
+	trimmed := strings.TrimLeft(content, "\n\t ")
+	if !strings.HasPrefix(trimmed, "---\n") {
+		return map[string]string{}, content
+	}
+	rest := strings.TrimPrefix(trimmed, "---\n")
+	idx := strings.Index(rest, "\n---")
+	if idx < 0 {
+		return map[string]string{}, content
+	}
+	front := rest[:idx]
+	body := strings.TrimPrefix(rest[idx+len("\n---"):], "\n")
+	result := map[string]string{}
+	for _, line := range strings.Split(front, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		result[strings.ToLower(strings.TrimSpace(key))] = strings.Trim(strings.TrimSpace(value), `"'`)
+	}
+	return result, body
+}
+
+func parseProfileList(raw string) []string {
+	raw = strings.TrimSpace(raw)
+	raw = strings.TrimSuffix(strings.TrimPrefix(raw, "["), "]")
+	var out []string
+	for _, one := range strings.Split(raw, ",") {
+		if name := strings.TrimSpace(strings.Trim(strings.TrimSpace(one), `"'`)); name != "" {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+func resolveProfileDir(dir string) (string, error) {
+	if dir == "~" || strings.HasPrefix(dir, "~/") || strings.HasPrefix(dir, "~"+string(filepath.Separator)) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, strings.TrimPrefix(dir[1:], string(filepath.Separator)))
+	}
+	return filepath.Abs(dir)
+}
+
+func cloneProfile(p *Profile) *Profile {
+	if p == nil {
+		return nil
+	}
+	out := *p
+	out.Tools = append([]string(nil), p.Tools...)
+	return &out
+}
+
+func firstNonEmptyValue(values ...string) string {
+	for _, value := range values {
+		if strings.TrimSpace(value) != "" {
+			return value
+		}
+	}
+	return ""
+}
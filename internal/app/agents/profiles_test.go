@@ -0,0 +1,88 @@
+package agents
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeProfile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiscoverProfilesParsesFrontMatterAndWorkspaceOverridesUser(t *testing.T) {
+	userDir := filepath.Join(t.TempDir(), "user")
+	workspaceDir := filepath.Join(t.TempDir(), "workspace")
+	writeProfile(t, userDir, "reviewer.md", "---\nname: reviewer\ndescription: user reviewer\n---\nUser prompt.\n")
+	writeProfile(t, userDir, "explorer.md", "---\ndescription: Cheap read-only explorer\nmodel: fast\nreasoning_effort: Low\ntools: [READ, SEARCH, \"GLOB\"]\nread_only: true\n---\nExplore and report file paths.\n")
+	writeProfile(t, workspaceDir, "reviewer.md", "---\nname: reviewer\ndescription: Strict reviewer\ntools: READ, SEARCH\nread_only: yes\n---\nReview strictly.\n")
+	writeProfile(t, workspaceDir, "notes.txt", "ignored")
+
+	result := DiscoverProfiles([]string{userDir, workspaceDir})
+	if len(result.Warnings) != 0 {
+		t.Fatalf("unexpected warnings: %v", result.Warnings)
+	}
+	if len(result.Descriptors) != 2 {
+		t.Fatalf("expected two profiles, got %+v", result.Descriptors)
+	}
+	explorer, reviewer := result.Descriptors[0], result.Descriptors[1]
+	if explorer.ID != "explorer" || explorer.Transport != TransportSelf || explorer.Profile == nil {
+		t.Fatalf("unexpected explorer descriptor %+v", explorer)
+	}
+	want := Profile{
+		Prompt:          "Explore and report file paths.",
+		Tools:           []string{"READ", "SEARCH", "GLOB"},
+		Model:           "fast",
+		ReasoningEffort: "low",
+		ReadOnly:        true,
+		Path:            filepath.Join(userDir, "explorer.md"),
+	}
+	if !reflect.DeepEqual(*explorer.Profile, want) {
+		t.Fatalf("unexpected explorer profile %+v", *explorer.Profile)
+	}
+	if reviewer.Description != "Strict reviewer" || reviewer.Profile.Prompt != "Review strictly." || !reviewer.Profile.ReadOnly {
+		t.Fatalf("expected workspace reviewer to win, got %+v %+v", reviewer, *reviewer.Profile)
+	}
+}
+
+func TestDiscoverProfilesWarnsOnInvalidProfiles(t *testing.T) {
+	dir := t.TempDir()
+	writeProfile(t, dir, "self.md", "---\ndescription: shadow\n---\n")
+	writeProfile(t, dir, "bare.md", "no front matter\n")
+	writeProfile(t, dir, "odd.md", "---\ndescription: odd\nread_only: maybe\n---\n")
+
+	result := DiscoverProfiles([]string{dir})
+	if len(result.Descriptors) != 0 {
+		t.Fatalf("expected no valid profiles, got %+v", result.Descriptors)
+	}
+	if len(result.Warnings) != 3 {
+		t.Fatalf("expected three warnings, got %v", result.Warnings)
+	}
+}
+
+func TestRegistryAcceptsSelfTransportProfiles(t *testing.T) {
+	r := NewRegistry(Descriptor{
+		ID:          "explorer",
+		Description: "explorer",
+		Transport:   TransportSelf,
+		Profile:     &Profile{Tools: []string{"READ"}, ReadOnly: true},
+	})
+	if err := r.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	d, ok := r.Lookup("explorer")
+	if !ok || d.Profile == nil || !d.Profile.ReadOnly {
+		t.Fatalf("expected explorer profile, got %+v", d)
+	}
+	d.Profile.Tools[0] = "WRITE"
+	if again, _ := r.Lookup("explorer"); again.Profile.Tools[0] != "READ" {
+		t.Fatal("expected Lookup to return a copy of the profile")
+	}
+}
@@ -30,6 +30,9 @@ type Descriptor struct {
 	Env         map[string]string `json:"env,omitempty"`
 	WorkDir     string            `json:"workDir,omitempty"`
 	Builtin     bool              `json:"builtin,omitempty"`
+	// Profile customizes a TransportSelf child session. It is set for
+	// subagent profiles loaded from .agents/agents/*.md.
+	Profile *Profile `json:"profile,omitempty"`
 }
 
 const selfAgentID = "self"
@@ -73,6 +76,7 @@ func (r *Registry) Lookup(id string) (Descriptor, bool) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 	d, ok := r.agents[strings.TrimSpace(id)]
+	d.Profile = cloneProfile(d.Profile)
 	return d, ok
 }
 
@@ -103,6 +107,7 @@ func (r *Registry) List() []Descriptor {
 	defer r.mu.RUnlock()
 	out := make([]Descriptor, 0, len(r.agents))
 	for _, d := range r.agents {
+		d.Profile = cloneProfile(d.Profile)
 		out = append(out, d)
 	}
 	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
@@ -158,6 +163,7 @@ func normalizeDescriptor(d Descriptor) Descriptor {
 	d.WorkDir = strings.TrimSpace(d.WorkDir)
 	d.Args = append([]string(nil), d.Args...)
 	d.Env = cloneStringMap(d.Env)
+	d.Profile = cloneProfile(d.Profile)
 	return d
 }
 
@@ -0,0 +1,105 @@
+package assembly
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/OnslaughtSnail/caelis/internal/mcpclient"
+	"github.com/OnslaughtSnail/caelis/kernel/tool"
+)
+
+const ProviderMCPTools = "mcp_tools"
+
+// mcpToolProvider connects configured MCP servers on Start and exposes their
+// tools until Stop. A server that cannot be reached is reported through warn
+// and left out, so one broken server does not take the others down with it.
+type mcpToolProvider struct {
+	servers []mcpclient.ServerConfig
+	connect func(context.Context, mcpclient.ServerConfig) (*mcpclient.Client, error)
+	warn    func(error)
+
+	mu      sync.Mutex
+	clients []*mcpclient.Client
+	tools   []tool.Tool
+}
+
+func newMCPToolProvider(servers []mcpclient.ServerConfig) *mcpToolProvider {
+	return &mcpToolProvider{
+		servers: append([]mcpclient.ServerConfig(nil), servers...),
+		connect: func(ctx context.Context, cfg mcpclient.ServerConfig) (*mcpclient.Client, error) {
+			return mcpclient.Connect(ctx, cfg, mcpclient.ConnectOptions{})
+		},
+		warn: func(err error) {
+			fmt.Fprintf(os.Stderr, "warn: %v\n", err)
+		},
+	}
+}
+
+func (p *mcpToolProvider) Name() string {
+	return ProviderMCPTools
+}
+
+func (p *mcpToolProvider) Start(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	taken := map[string]struct{}{}
+	for _, server := range p.servers {
+		if err := ctx.Err(); err != nil {
+			p.closeLocked()
+			return err
+		}
+		client, err := p.connect(ctx, server)
+		if err != nil {
+			p.warn(fmt.Errorf("mcp server %q skipped: %w", server.Name, err))
+			continue
+		}
+		tools, err := mcpclient.NewToolsAvoiding(ctx, client, taken)
+		if err != nil {
+			p.warn(fmt.Errorf("mcp server %q skipped: list tools: %w", server.Name, err))
+			_ = client.Close()
+			continue
+		}
+		p.clients = append(p.clients, client)
+		p.tools = append(p.tools, tools...)
+	}
+	return nil
+}
+
+func (p *mcpToolProvider) Tools(context.Context) ([]tool.Tool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]tool.Tool(nil), p.tools...), nil
+}
+
+func (p *mcpToolProvider) Stop(context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.closeLocked()
+}
+
+func (p *mcpToolProvider) closeLocked() error {
+	var errs []error
+	for _, client := range p.clients {
+		if err := client.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("close mcp server %q: %w", client.Name(), err))
+		}
+	}
+	p.clients = nil
+	p.tools = nil
+	return errors.Join(errs...)
+}
+
+func (p *mcpToolProvider) ConfigSchema() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"mcp_servers": map[string]any{
+				"type":        "object",
+				"description": "MCP servers keyed by name; stdio servers set command/args/env, http servers set url/headers.",
+			},
+		},
+	}
+}
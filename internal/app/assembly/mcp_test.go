@@ -0,0 +1,82 @@
+package assembly
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/OnslaughtSnail/caelis/internal/mcpclient"
+)
+
+func newMCPTestServer(t *testing.T, tools ...string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg struct {
+			ID     any    `json:"id"`
+			Method string `json:"method"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil || msg.ID == nil {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		var result any
+		switch msg.Method {
+		case "initialize":
+			result = map[string]any{
+				"protocolVersion": mcpclient.ProtocolVersion,
+				"serverInfo":      map[string]any{"name": "fake", "version": "1.0.0"},
+				"capabilities":    map[string]any{"tools": map[string]any{}},
+			}
+		case "tools/list":
+			list := []any{}
+			for _, name := range tools {
+				list = append(list, map[string]any{"name": name, "inputSchema": map[string]any{"type": "object"}})
+			}
+			result = map[string]any{"tools": list}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": msg.ID, "result": result})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestMCPToolProvider_SkipsFailingServersAndDedupesNames(t *testing.T) {
+	first := newMCPTestServer(t, "echo")
+	second := newMCPTestServer(t, "echo")
+	provider := newMCPToolProvider([]mcpclient.ServerConfig{
+		{Name: "a.b", URL: first.URL},
+		{Name: "broken", URL: "http://127.0.0.1:1"},
+		{Name: "a_b", URL: second.URL},
+	})
+	provider.connect = func(ctx context.Context, cfg mcpclient.ServerConfig) (*mcpclient.Client, error) {
+		if cfg.Name == "broken" {
+			return nil, errors.New("connection refused")
+		}
+		return mcpclient.Connect(ctx, cfg, mcpclient.ConnectOptions{})
+	}
+	var warnings []error
+	provider.warn = func(err error) { warnings = append(warnings, err) }
+
+	if err := provider.Start(context.Background()); err != nil {
+		t.Fatalf("expected a failing server to be skipped, got %v", err)
+	}
+	defer provider.Stop(context.Background())
+	if len(warnings) != 1 {
+		t.Fatalf("expected one warning for the failing server, got %v", warnings)
+	}
+	tools, err := provider.Tools(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tools) != 2 || tools[0].Name() != "mcp__a_b__echo" || tools[1].Name() != "mcp__a_b__echo_2" {
+		names := []string{}
+		for _, one := range tools {
+			names = append(names, one.Name())
+		}
+		t.Fatalf("expected colliding names to be made unique, got %v", names)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/OnslaughtSnail/caelis/internal/mcpclient"
 	toolexec "github.com/OnslaughtSnail/caelis/kernel/execenv"
 	"github.com/OnslaughtSnail/caelis/kernel/plugin"
 	"github.com/OnslaughtSnail/caelis/kernel/policy"
@@ -20,6 +21,9 @@ const (
 
 type RegisterOptions struct {
 	ExecutionRuntime toolexec.Runtime
+	MCPServers       []mcpclient.ServerConfig
+	// PermissionRules are the loaded workspace and user permission rules.
+	PermissionRules policy.PermissionRules
 }
 
 func RegisterBuiltinProviders(r *plugin.Registry, options RegisterOptions) error {
@@ -32,7 +36,10 @@ func RegisterBuiltinProviders(r *plugin.Registry, options RegisterOptions) error
 	if err := r.RegisterToolProvider(shellToolProvider{runtime: options.ExecutionRuntime}); err != nil {
 		return err
 	}
-	if err := r.RegisterPolicyProvider(defaultPolicyProvider{runtime: options.ExecutionRuntime}); err != nil {
+	if err := r.RegisterToolProvider(newMCPToolProvider(options.MCPServers)); err != nil {
+		return err
+	}
+	if err := r.RegisterPolicyProvider(defaultPolicyProvider{runtime: options.ExecutionRuntime, rules: options.PermissionRules}); err != nil {
 		return err
 	}
 	return nil
@@ -72,11 +79,16 @@ func (p workspaceToolProvider) Tools(context.Context) ([]tool.Tool, error) {
 	if err != nil {
 		return nil, err
 	}
-	return []tool.Tool{listTool, globTool, searchTool}, nil
+	editTool, err := toolfs.NewEditWithRuntime(p.runtime)
+	if err != nil {
+		return nil, err
+	}
+	return []tool.Tool{listTool, globTool, searchTool, editTool}, nil
 }
 
 type defaultPolicyProvider struct {
 	runtime toolexec.Runtime
+	rules   policy.PermissionRules
 }
 
 func (p defaultPolicyProvider) Name() string {
@@ -84,11 +96,22 @@ func (p defaultPolicyProvider) Name() string {
 }
 
 func (p defaultPolicyProvider) Policies(context.Context) ([]policy.Hook, error) {
-	hooks := []policy.Hook{policy.DefaultSecurityBaseline()}
+	var hooks []policy.Hook
+	if !p.rules.Empty() {
+		// Rules run first so a deny short-circuits later approval prompts.
+		hooks = append(hooks, policy.EnforcePermissionRules(policy.PermissionRulesConfig{
+			Rules:   p.rules,
+			Runtime: p.runtime,
+		}))
+	}
+	hooks = append(hooks, policy.NewSecurityBaseline(policy.SecurityBaselineConfig{
+		AutoAllowTools: p.rules.AllowedTools(),
+	}))
 	if p.runtime != nil {
 		hooks = append(hooks, policy.RouteCommandExecution(policy.CommandExecutionConfig{
 			Runtime:  p.runtime,
 			ToolName: toolshell.BashToolName,
+			Rules:    p.rules,
 		}))
 		hooks = append(hooks, policy.WorkspaceBoundary(policy.WorkspaceBoundaryConfig{
 			Runtime: p.runtime,
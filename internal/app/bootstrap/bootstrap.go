@@ -17,7 +17,7 @@ import (
 	"github.com/OnslaughtSnail/caelis/kernel/tool"
 )
 
-type ACPResourceFactory func(context.Context, *internalacp.Conn, string, string, internalacp.ClientCapabilities, func() string) (*internalacp.SessionResources, error)
+type ACPResourceFactory func(context.Context, *internalacp.Conn, string, string, internalacp.ClientCapabilities, []internalacp.MCPServer, func() string) (*internalacp.SessionResources, error)
 
 type ACPConfig struct {
 	WorkspaceRoot       string
@@ -147,8 +147,8 @@ func Build(cfg Config) (ServiceSet, error) {
 				EnablePlan:            cfg.EnablePlan,
 				EnableSelfSpawn:       cfg.EnableSelfSpawn,
 				SubagentRunnerFactory: cfg.SubagentRunnerFactory,
-				NewSessionResources: func(ctx context.Context, sessionID string, sessionCWD string, caps internalacp.ClientCapabilities, modeResolver func() string) (*internalacp.SessionResources, error) {
-					return cfg.ACP.NewSessionResources(ctx, conn, sessionID, sessionCWD, caps, modeResolver)
+				NewSessionResources: func(ctx context.Context, sessionID string, sessionCWD string, caps internalacp.ClientCapabilities, mcpServers []internalacp.MCPServer, modeResolver func() string) (*internalacp.SessionResources, error) {
+					return cfg.ACP.NewSessionResources(ctx, conn, sessionID, sessionCWD, caps, mcpServers, modeResolver)
 				},
 			})
 		}
@@ -79,7 +79,7 @@ func TestBuildProvidesGatewayAndACPAdapterAgainstSameStore(t *testing.T) {
 					EmitPartialEvents: stream,
 				})
 			},
-			NewSessionResources: func(context.Context, *internalacp.Conn, string, string, internalacp.ClientCapabilities, func() string) (*internalacp.SessionResources, error) {
+			NewSessionResources: func(context.Context, *internalacp.Conn, string, string, internalacp.ClientCapabilities, []internalacp.MCPServer, func() string) (*internalacp.SessionResources, error) {
 				return &internalacp.SessionResources{Runtime: execRT}, nil
 			},
 		},
@@ -0,0 +1,289 @@
+package httpapi
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OnslaughtSnail/caelis/internal/approvalgrant"
+	"github.com/OnslaughtSnail/caelis/internal/approvalqueue"
+	toolexec "github.com/OnslaughtSnail/caelis/kernel/execenv"
+	"github.com/OnslaughtSnail/caelis/kernel/policy"
+	"github.com/google/uuid"
+)
+
+// Approval decisions accepted by POST /v1/approvals/{id}.
+const (
+	DecisionAllowOnce   = "allow_once"
+	DecisionAllowAlways = "allow_always"
+	DecisionReject      = "reject"
+)
+
+// approvalJSON describes one pending approval prompt.
+type approvalJSON struct {
+	ID         string    `json:"id"`
+	SessionID  string    `json:"session_id"`
+	ToolCallID string    `json:"tool_call_id,omitempty"`
+	ToolName   string    `json:"tool_name"`
+	Kind       string    `json:"kind"`
+	Action     string    `json:"action,omitempty"`
+	Reason     string    `json:"reason,omitempty"`
+	Command    string    `json:"command,omitempty"`
+	Path       string    `json:"path,omitempty"`
+	Target     string    `json:"target,omitempty"`
+	Preview    string    `json:"preview,omitempty"`
+	Options    []string  `json:"options"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type approvalDecisionJSON struct {
+	Decision string `json:"decision"`
+}
+
+type approvalResolvedJSON struct {
+	ID       string `json:"id"`
+	Decision string `json:"decision"`
+}
+
+type pendingApproval struct {
+	info     approvalJSON
+	decision chan string
+}
+
+// approvalBroker tracks approval prompts raised by running turns until an HTTP
+// client answers them.
+type approvalBroker struct {
+	mu      sync.Mutex
+	pending map[string]*pendingApproval
+}
+
+func newApprovalBroker() *approvalBroker {
+	return &approvalBroker{pending: map[string]*pendingApproval{}}
+}
+
+func (b *approvalBroker) list(sessionID string) []approvalJSON {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := make([]approvalJSON, 0, len(b.pending))
+	for _, item := range b.pending {
+		if sessionID != "" && item.info.SessionID != sessionID {
+			continue
+		}
+		out = append(out, item.info)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].CreatedAt.Before(out[j].CreatedAt)
+	})
+	return out
+}
+
+// resolve delivers one decision. It reports false when the approval is no
+// longer pending.
+func (b *approvalBroker) resolve(id string, decision string) bool {
+	b.mu.Lock()
+	item, ok := b.pending[id]
+	if ok {
+		delete(b.pending, id)
+	}
+	b.mu.Unlock()
+	if !ok {
+		return false
+	}
+	item.decision <- decision
+	return true
+}
+
+// wait registers one approval, announces it and blocks until it is resolved or
+// ctx ends.
+func (b *approvalBroker) wait(ctx context.Context, info approvalJSON, announce func(approvalJSON)) (string, error) {
+	info.ID = "apr-" + strings.ReplaceAll(uuid.NewString(), "-", "")[:12]
+	info.CreatedAt = time.Now()
+	info.Options = []string{DecisionAllowOnce, DecisionAllowAlways, DecisionReject}
+	item := &pendingApproval{info: info, decision: make(chan string, 1)}
+	b.mu.Lock()
+	b.pending[info.ID] = item
+	b.mu.Unlock()
+	if announce != nil {
+		announce(info)
+	}
+	select {
+	case decision := <-item.decision:
+		return decision, nil
+	case <-ctx.Done():
+		b.mu.Lock()
+		delete(b.pending, info.ID)
+		b.mu.Unlock()
+		return "", ctx.Err()
+	}
+}
+
+func validDecision(decision string) bool {
+	switch decision {
+	case DecisionAllowOnce, DecisionAllowAlways, DecisionReject:
+		return true
+	default:
+		return false
+	}
+}
+
+// sessionApprover routes one session's approval prompts through the broker.
+// "allow_always" answers are remembered per scope for the session lifetime
+// and, when the approval has a grant key, stored as a workspace grant that
+// the console and ACP sessions honor too.
+type sessionApprover struct {
+	broker    *approvalBroker
+	sessionID string
+	queue     *approvalqueue.Queue
+
+	// grants holds approvals remembered beyond this session; nil keeps
+	// "allow_always" answers to the session.
+	grants    *approvalgrant.Store
+	workspace string
+
+	mu       sync.Mutex
+	allowed  map[string]bool
+	announce func(approvalJSON)
+	resolved func(approvalResolvedJSON)
+}
+
+// grantTarget is what an "allow_always" answer stores in the grant store.
+type grantTarget struct {
+	kind approvalgrant.Kind
+	key  string
+}
+
+func newSessionApprover(broker *approvalBroker, sessionID string, grants *approvalgrant.Store, workspace string) *sessionApprover {
+	return &sessionApprover{
+		broker:    broker,
+		sessionID: sessionID,
+		queue:     approvalqueue.New(),
+		grants:    grants,
+		workspace: workspace,
+		allowed:   map[string]bool{},
+	}
+}
+
+// attach points announcements at the currently streaming turn.
+func (a *sessionApprover) attach(announce func(approvalJSON), resolved func(approvalResolvedJSON)) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.announce = announce
+	a.resolved = resolved
+}
+
+func (a *sessionApprover) Approve(ctx context.Context, req toolexec.ApprovalRequest) (bool, error) {
+	if a.grants.AllowsCommand(a.workspace, req.Command) {
+		return true, nil
+	}
+	info, _ := toolexec.ToolCallInfoFromContext(ctx)
+	grant := grantTarget{kind: approvalgrant.KindCommand, key: approvalgrant.CommandKey(req.Command)}
+	return a.ask(ctx, strings.TrimSpace(req.Command), grant, approvalJSON{
+		SessionID:  a.sessionID,
+		ToolCallID: strings.TrimSpace(info.ID),
+		ToolName:   strings.TrimSpace(req.ToolName),
+		Kind:       "command",
+		Action:     strings.TrimSpace(req.Action),
+		Reason:     strings.TrimSpace(req.Reason),
+		Command:    strings.TrimSpace(req.Command),
+	})
+}
+
+func (a *sessionApprover) AuthorizeTool(ctx context.Context, req policy.ToolAuthorizationRequest) (bool, error) {
+	scope := strings.TrimSpace(req.ScopeKey)
+	if scope == "" {
+		scope = strings.TrimSpace(req.Target)
+	}
+	if scope == "" {
+		scope = strings.TrimSpace(req.Path)
+	}
+	if a.grants.AllowsTool(a.workspace, scope) {
+		return true, nil
+	}
+	info, _ := toolexec.ToolCallInfoFromContext(ctx)
+	return a.ask(ctx, scope, grantTarget{kind: approvalgrant.KindTool, key: scope}, approvalJSON{
+		SessionID:  a.sessionID,
+		ToolCallID: strings.TrimSpace(info.ID),
+		ToolName:   strings.TrimSpace(req.ToolName),
+		Kind:       "tool",
+		Action:     strings.TrimSpace(req.Permission),
+		Reason:     strings.TrimSpace(req.Reason),
+		Path:       strings.TrimSpace(req.Path),
+		Target:     strings.TrimSpace(req.Target),
+		Preview:    req.Preview,
+	})
+}
+
+func (a *sessionApprover) ask(ctx context.Context, scope string, grant grantTarget, info approvalJSON) (bool, error) {
+	if a.isAllowed(scope) {
+		return true, nil
+	}
+	var allowed bool
+	err := a.queue.Do(ctx, func(ctx context.Context) error {
+		if a.isAllowed(scope) {
+			allowed = true
+			return nil
+		}
+		a.mu.Lock()
+		announce, resolved := a.announce, a.resolved
+		a.mu.Unlock()
+		var id string
+		decision, err := a.broker.wait(ctx, info, func(pending approvalJSON) {
+			id = pending.ID
+			if announce != nil {
+				announce(pending)
+			}
+		})
+		if err != nil {
+			return err
+		}
+		if resolved != nil {
+			resolved(approvalResolvedJSON{ID: id, Decision: decision})
+		}
+		switch decision {
+		case DecisionAllowOnce:
+			allowed = true
+			return nil
+		case DecisionAllowAlways:
+			if a.grants != nil && strings.TrimSpace(grant.key) != "" {
+				if _, err := a.grants.Add(a.workspace, grant.kind, grant.key, approvalgrant.ScopeWorkspace); err != nil {
+					return err
+				}
+			}
+			a.allow(scope)
+			allowed = true
+			return nil
+		case DecisionReject:
+			return &toolexec.ApprovalAbortedError{Reason: "permission rejected"}
+		default:
+			return fmt.Errorf("httpapi: unknown approval decision %q", decision)
+		}
+	})
+	return allowed, err
+}
+
+func (a *sessionApprover) isAllowed(scope string) bool {
+	if scope == "" {
+		return false
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.allowed[scope]
+}
+
+func (a *sessionApprover) hasGrants() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.allowed) > 0
+}
+
+func (a *sessionApprover) allow(scope string) {
+	if scope == "" {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.allowed[scope] = true
+}
@@ -0,0 +1,676 @@
+// Package httpapi exposes sessionsvc over HTTP: REST endpoints for session
+// management, server-sent events for turn streaming, and an approvals endpoint
+// for answering tool permission prompts.
+package httpapi
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	appgateway "github.com/OnslaughtSnail/caelis/internal/app/gateway"
+	"github.com/OnslaughtSnail/caelis/internal/approvalgrant"
+	"github.com/OnslaughtSnail/caelis/internal/version"
+	"github.com/OnslaughtSnail/caelis/kernel/agent"
+	toolexec "github.com/OnslaughtSnail/caelis/kernel/execenv"
+	"github.com/OnslaughtSnail/caelis/kernel/model"
+	"github.com/OnslaughtSnail/caelis/kernel/policy"
+	"github.com/OnslaughtSnail/caelis/kernel/session"
+	"github.com/OnslaughtSnail/caelis/kernel/sessionsvc"
+)
+
+// maxRequestBytes bounds JSON request bodies.
+const maxRequestBytes = 4 << 20
+
+// defaultEventLimit caps history returned by session endpoints unless the
+// caller asks for more.
+const defaultEventLimit = 200
+
+// TurnFactory builds the agent and model used for one turn.
+type TurnFactory func(ctx context.Context, sessionID string) (agent.Agent, model.LLM, error)
+
+// Config wires the HTTP front end to the session service.
+type Config struct {
+	Service             *sessionsvc.Service
+	Gateway             *appgateway.Gateway
+	AppName             string
+	UserID              string
+	WorkspaceKey        string
+	WorkspaceCWD        string
+	NewTurn             TurnFactory
+	ContextWindowTokens int
+	// AuthToken is required as a bearer token on every request. New
+	// generates one when it is empty; read it back with Server.AuthToken.
+	AuthToken string
+	// Addr is the address the server listens on. Requests must name it in
+	// their Host header, or a loopback name for a loopback address, so a
+	// DNS-rebound page cannot reach the server. Empty skips the check.
+	Addr string
+	// DecorateEvent, when set, attaches front-end specific extras (for example
+	// rendered diffs) to every event the server writes.
+	DecorateEvent func(*session.Event) map[string]any
+	// ApprovalGrants, when set, is checked before prompting and stores
+	// "allow_always" answers for the workspace, as in the console and ACP.
+	ApprovalGrants *approvalgrant.Store
+}
+
+// Server serves the HTTP API.
+type Server struct {
+	cfg       Config
+	approvals *approvalBroker
+	mux       *http.ServeMux
+
+	mu        sync.Mutex
+	approvers map[string]*sessionApprover
+}
+
+func New(cfg Config) (*Server, error) {
+	if cfg.Service == nil {
+		return nil, fmt.Errorf("httpapi: session service is required")
+	}
+	if cfg.Gateway == nil {
+		return nil, fmt.Errorf("httpapi: gateway is required")
+	}
+	if cfg.NewTurn == nil {
+		return nil, fmt.Errorf("httpapi: turn factory is required")
+	}
+	if strings.TrimSpace(cfg.AppName) == "" || strings.TrimSpace(cfg.UserID) == "" {
+		return nil, fmt.Errorf("httpapi: app_name and user_id are required")
+	}
+	if cfg.AuthToken == "" {
+		token, err := NewAuthToken()
+		if err != nil {
+			return nil, err
+		}
+		cfg.AuthToken = token
+	}
+	s := &Server{
+		cfg:       cfg,
+		approvals: newApprovalBroker(),
+		mux:       http.NewServeMux(),
+		approvers: map[string]*sessionApprover{},
+	}
+	s.routes()
+	return s, nil
+}
+
+func (s *Server) routes() {
+	s.mux.HandleFunc("GET /v1/health", s.handleHealth)
+	s.mux.HandleFunc("GET /v1/sessions", s.handleListSessions)
+	s.mux.HandleFunc("POST /v1/sessions", s.handleStartSession)
+	s.mux.HandleFunc("GET /v1/sessions/{id}", s.handleLoadSession)
+	s.mux.HandleFunc("DELETE /v1/sessions/{id}", s.handleCloseSession)
+	s.mux.HandleFunc("GET /v1/sessions/{id}/events", s.handleSessionEvents)
+	s.mux.HandleFunc("GET /v1/sessions/{id}/delegations", s.handleListDelegations)
+	s.mux.HandleFunc("POST /v1/sessions/{id}/turns", s.handleRunTurn)
+	s.mux.HandleFunc("POST /v1/sessions/{id}/interrupt", s.handleInterrupt)
+	s.mux.HandleFunc("GET /v1/approvals", s.handleListApprovals)
+	s.mux.HandleFunc("POST /v1/approvals/{id}", s.handleResolveApproval)
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	RequireSameOrigin(s.cfg.Addr, RequireBearer(s.cfg.AuthToken, s.mux)).ServeHTTP(w, r)
+}
+
+// AuthToken returns the bearer token clients must send.
+func (s *Server) AuthToken() string {
+	return s.cfg.AuthToken
+}
+
+// NewAuthToken returns a random bearer token.
+func NewAuthToken() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("httpapi: generate auth token: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// RequireSameOrigin refuses requests a browser sends on behalf of another
+// site. The Host header must match addr (see Config.Addr), and an Origin
+// header, when present, must name that same host.
+func RequireSameOrigin(addr string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !hostMatchesAddr(r.Host, addr) {
+			writeError(w, http.StatusForbidden, fmt.Errorf("host %q is not allowed", r.Host))
+			return
+		}
+		if origin := r.Header.Get("Origin"); origin != "" {
+			parsed, err := url.Parse(origin)
+			if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || !strings.EqualFold(parsed.Host, r.Host) {
+				writeError(w, http.StatusForbidden, fmt.Errorf("origin %q is not allowed", origin))
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// hostMatchesAddr reports whether a request Host header names the listen
+// address addr. A server on a loopback address answers to any loopback name
+// on its port, and one on an unspecified address to any name, leaving those
+// requests to the bearer token.
+func hostMatchesAddr(host string, addr string) bool {
+	if addr == "" {
+		return true
+	}
+	bindHost, bindPort, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	reqHost, reqPort, err := net.SplitHostPort(host)
+	if err != nil {
+		reqHost, reqPort = strings.Trim(host, "[]"), "80"
+	}
+	if reqPort != bindPort {
+		return false
+	}
+	ip := net.ParseIP(bindHost)
+	switch {
+	case ip == nil:
+		return strings.EqualFold(reqHost, bindHost)
+	case ip.IsUnspecified():
+		return true
+	case ip.IsLoopback():
+		if strings.EqualFold(reqHost, "localhost") {
+			return true
+		}
+		reqIP := net.ParseIP(reqHost)
+		return reqIP != nil && reqIP.IsLoopback()
+	default:
+		reqIP := net.ParseIP(reqHost)
+		return reqIP != nil && reqIP.Equal(ip)
+	}
+}
+
+// RequireBearer wraps next so requests must carry token as a bearer token.
+// An empty token disables the check.
+func RequireBearer(token string, next http.Handler) http.Handler {
+	if token == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(strings.TrimSpace(got)), []byte(token)) != 1 {
+			writeError(w, http.StatusUnauthorized, fmt.Errorf("missing or invalid bearer token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"status":  "ok",
+		"version": version.String(),
+	})
+}
+
+func (s *Server) handleListSessions(w http.ResponseWriter, r *http.Request) {
+	limit, err := queryInt(r, "limit", 20)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	list, err := s.cfg.Service.ListSessions(r.Context(), sessionsvc.SessionListRequest{
+		AppName:      s.cfg.AppName,
+		UserID:       s.cfg.UserID,
+		WorkspaceKey: s.cfg.WorkspaceKey,
+		Cursor:       r.URL.Query().Get("cursor"),
+		Limit:        limit,
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	out := sessionListJSON{Sessions: make([]sessionJSON, 0, len(list.Sessions)), NextCursor: list.NextCursor}
+	for _, item := range list.Sessions {
+		out.Sessions = append(out.Sessions, sessionFromSummary(item))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) handleStartSession(w http.ResponseWriter, r *http.Request) {
+	var req startSessionJSON
+	if err := decodeBody(r, &req, true); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	info, err := s.cfg.Gateway.StartSession(r.Context(), appgateway.StartSessionRequest{
+		Channel:            s.channel(""),
+		PreferredSessionID: strings.TrimSpace(req.SessionID),
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, sessionFromInfo(info))
+}
+
+// handleCloseSession stops the session's running turn and forgets its
+// session-scoped approvals. The history stays in the store.
+func (s *Server) handleCloseSession(w http.ResponseWriter, r *http.Request) {
+	sessionID := strings.TrimSpace(r.PathValue("id"))
+	if err := s.cfg.Service.InterruptSession(r.Context(), sessionsvc.InterruptSessionRequest{
+		SessionRef: s.sessionRef(sessionID),
+		Reason:     "api session closed",
+	}); err != nil && !errors.Is(err, session.ErrSessionNotFound) {
+		writeServiceError(w, err)
+		return
+	}
+	s.mu.Lock()
+	delete(s.approvers, sessionID)
+	s.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleLoadSession(w http.ResponseWriter, r *http.Request) {
+	limit, err := queryInt(r, "limit", defaultEventLimit)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	loaded, err := s.cfg.Service.LoadSession(r.Context(), sessionsvc.LoadSessionRequest{
+		SessionRef: s.sessionRef(r.PathValue("id")),
+		CWD:        s.cfg.WorkspaceCWD,
+		Limit:      limit,
+	})
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, loadedSessionJSON{
+		sessionJSON: sessionFromInfo(loaded.SessionInfo),
+		Events:      s.encodeEvents(loaded.Events),
+		State:       encodableMeta(loaded.State),
+	})
+}
+
+func (s *Server) handleSessionEvents(w http.ResponseWriter, r *http.Request) {
+	limit, err := queryInt(r, "limit", defaultEventLimit)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	lifecycle := r.URL.Query().Get("lifecycle") == "true"
+	events, err := s.cfg.Service.SessionEvents(r.Context(), s.sessionRef(r.PathValue("id")), limit, lifecycle)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"events": s.encodeEvents(events)})
+}
+
+func (s *Server) handleListDelegations(w http.ResponseWriter, r *http.Request) {
+	items, err := s.cfg.Service.ListDelegations(r.Context(), s.sessionRef(r.PathValue("id")))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	out := make([]delegationJSON, 0, len(items))
+	for _, item := range items {
+		out = append(out, delegationFromRef(item))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"delegations": out})
+}
+
+func (s *Server) handleInterrupt(w http.ResponseWriter, r *http.Request) {
+	var req interruptJSON
+	if err := decodeBody(r, &req, true); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		reason = "api interrupt"
+	}
+	if err := s.cfg.Service.InterruptSession(r.Context(), sessionsvc.InterruptSessionRequest{
+		SessionRef: s.sessionRef(r.PathValue("id")),
+		Reason:     reason,
+	}); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleListApprovals(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"approvals": s.approvals.list(strings.TrimSpace(r.URL.Query().Get("session_id"))),
+	})
+}
+
+func (s *Server) handleResolveApproval(w http.ResponseWriter, r *http.Request) {
+	var req approvalDecisionJSON
+	if err := decodeBody(r, &req, false); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	decision := strings.TrimSpace(req.Decision)
+	if !validDecision(decision) {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("decision must be one of %s, %s, %s", DecisionAllowOnce, DecisionAllowAlways, DecisionReject))
+		return
+	}
+	if !s.approvals.resolve(r.PathValue("id"), decision) {
+		writeError(w, http.StatusNotFound, fmt.Errorf("approval %q is not pending", r.PathValue("id")))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleRunTurn(w http.ResponseWriter, r *http.Request) {
+	var req turnRequestJSON
+	if err := decodeBody(r, &req, false); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	acceptsStream := strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+	env := inboundFromRequest(s.sessionRef(r.PathValue("id")), req, acceptsStream)
+	if env.Content == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("input is required"))
+		return
+	}
+	ag, llm, err := s.cfg.NewTurn(r.Context(), env.Session.SessionID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	var stream *sseWriter
+	if env.Stream {
+		stream, err = newSSEWriter(w)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+	approver := s.approver(env.Session.SessionID)
+	defer s.releaseApprover(env.Session.SessionID, approver)
+	if stream != nil {
+		approver.attach(
+			func(item approvalJSON) { stream.send(EventApprovalRequested, item) },
+			func(item approvalResolvedJSON) { stream.send(EventApprovalResolved, item) },
+		)
+		defer approver.attach(nil, nil)
+	}
+	ctx := toolexec.WithApprover(r.Context(), approver)
+	ctx = policy.WithToolAuthorizer(ctx, approver)
+	result, err := s.cfg.Gateway.RunTurn(ctx, appgateway.RunTurnRequest{
+		Channel:             s.channel(env.Actor.ID),
+		SessionID:           env.Session.SessionID,
+		Input:               env.Content,
+		ContentParts:        env.Parts,
+		Agent:               ag,
+		Model:               llm,
+		ContextWindowTokens: s.cfg.ContextWindowTokens,
+	})
+	if err != nil {
+		if stream != nil {
+			stream.send(EventTurnFailed, errorJSON{Error: err.Error()})
+			return
+		}
+		writeServiceError(w, err)
+		return
+	}
+	handle := result.Handle
+	defer handle.Close() // Close always returns nil; safe to ignore.
+	if stream != nil {
+		stream.send(EventTurnStarted, turnStartedJSON{SessionID: result.Session.SessionID, RunID: handle.RunID()})
+	}
+	out, events, err := collectTurn(handle, func(ev *session.Event) eventJSON {
+		encoded := s.encodeEvent(ev)
+		if stream != nil {
+			stream.send(EventSessionEvent, encoded)
+		}
+		return encoded
+	})
+	outbound := appgateway.OutboundEnvelope{
+		Session: result.Session.SessionRef,
+		Metadata: map[string]any{
+			"run_id":      handle.RunID(),
+			"output":      out.output,
+			"stop_reason": out.stopReason,
+			"actor":       env.Actor.ID,
+		},
+	}
+	for key, value := range env.Metadata {
+		if _, exists := outbound.Metadata[key]; !exists {
+			outbound.Metadata[key] = value
+		}
+	}
+	if err != nil {
+		if stream != nil {
+			stream.send(EventTurnFailed, errorJSON{Error: err.Error()})
+			return
+		}
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	wire := resultFromOutbound(outbound)
+	if stream != nil {
+		stream.send(EventTurnCompleted, wire)
+		return
+	}
+	wire.Events = events
+	writeJSON(w, http.StatusOK, wire)
+}
+
+type turnOutcome struct {
+	output     string
+	stopReason string
+}
+
+// collectTurn drains one turn, reporting every event to onEvent and returning
+// the final assistant answer.
+func collectTurn(handle sessionsvc.TurnHandle, onEvent func(*session.Event) eventJSON) (turnOutcome, []eventJSON, error) {
+	var (
+		out     = turnOutcome{stopReason: "end_turn"}
+		partial strings.Builder
+		events  []eventJSON
+	)
+	for ev, err := range handle.Events() {
+		if err != nil {
+			if errors.Is(err, context.Canceled) || toolexec.IsApprovalAborted(err) ||
+				toolexec.IsErrorCode(err, toolexec.ErrorCodeApprovalAborted) {
+				out.stopReason = "cancelled"
+				return out, events, nil
+			}
+			return out, events, err
+		}
+		if ev == nil {
+			continue
+		}
+		encoded := onEvent(ev)
+		if !session.IsPartial(ev) {
+			events = append(events, encoded)
+		}
+		if ev.Message.Role != model.RoleAssistant {
+			continue
+		}
+		if session.IsPartial(ev) {
+			if session.PartialChannelOf(ev) == session.PartialChannelAnswer {
+				partial.WriteString(ev.Message.TextContent())
+			}
+			continue
+		}
+		if text := strings.TrimSpace(ev.Message.TextContent()); text != "" {
+			out.output = text
+			partial.Reset()
+		}
+	}
+	if out.output == "" {
+		out.output = strings.TrimSpace(partial.String())
+	}
+	return out, events, nil
+}
+
+func (s *Server) encodeEvent(ev *session.Event) eventJSON {
+	out := eventFromSession(ev)
+	if s.cfg.DecorateEvent != nil {
+		out.Extras = encodableMeta(s.cfg.DecorateEvent(ev))
+	}
+	return out
+}
+
+func (s *Server) encodeEvents(events []*session.Event) []eventJSON {
+	out := make([]eventJSON, 0, len(events))
+	for _, ev := range events {
+		if ev == nil {
+			continue
+		}
+		out = append(out, s.encodeEvent(ev))
+	}
+	return out
+}
+
+func (s *Server) approver(sessionID string) *sessionApprover {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if existing, ok := s.approvers[sessionID]; ok {
+		return existing
+	}
+	created := newSessionApprover(s.approvals, sessionID, s.cfg.ApprovalGrants, s.cfg.WorkspaceCWD)
+	s.approvers[sessionID] = created
+	return created
+}
+
+// releaseApprover drops the session's approver after a turn unless it holds
+// "allow always" grants the next turn should keep.
+func (s *Server) releaseApprover(sessionID string, approver *sessionApprover) {
+	if approver.hasGrants() {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.approvers[sessionID] == approver {
+		delete(s.approvers, sessionID)
+	}
+}
+
+func (s *Server) channel(actor string) appgateway.ChannelRef {
+	id := "api"
+	if actor = strings.TrimSpace(actor); actor != "" {
+		id = "api:" + actor
+	}
+	return appgateway.ChannelRef{
+		ID:           id,
+		AppName:      s.cfg.AppName,
+		UserID:       s.cfg.UserID,
+		WorkspaceKey: s.cfg.WorkspaceKey,
+		WorkspaceCWD: s.cfg.WorkspaceCWD,
+	}
+}
+
+func (s *Server) sessionRef(sessionID string) sessionsvc.SessionRef {
+	return sessionsvc.SessionRef{
+		AppName:      s.cfg.AppName,
+		UserID:       s.cfg.UserID,
+		SessionID:    strings.TrimSpace(sessionID),
+		WorkspaceKey: s.cfg.WorkspaceKey,
+	}
+}
+
+// sseWriter serializes server-sent events from the turn loop and approval
+// callbacks onto one response.
+type sseWriter struct {
+	mu      sync.Mutex
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+func newSSEWriter(w http.ResponseWriter) (*sseWriter, error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, fmt.Errorf("httpapi: response writer does not support streaming")
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	return &sseWriter{w: w, flusher: flusher}, nil
+}
+
+func (s *sseWriter) send(event string, payload any) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		raw, _ = json.Marshal(errorJSON{Error: err.Error()})
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, _ = fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, raw)
+	s.flusher.Flush()
+}
+
+var errNotJSON = errors.New("Content-Type must be application/json")
+
+func decodeBody(r *http.Request, out any, allowEmpty bool) error {
+	if r.Body == nil || r.ContentLength == 0 {
+		if allowEmpty {
+			return nil
+		}
+		return fmt.Errorf("request body is required")
+	}
+	// Requiring JSON keeps browsers from sending bodies cross-site without
+	// a CORS preflight, which a text/plain form post would skip.
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != "application/json" {
+		return errNotJSON
+	}
+	dec := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxRequestBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(out); err != nil {
+		if allowEmpty && errors.Is(err, io.EOF) {
+			return nil
+		}
+		return fmt.Errorf("invalid request body: %w", err)
+	}
+	return nil
+}
+
+func queryInt(r *http.Request, key string, fallback int) (int, error) {
+	raw := strings.TrimSpace(r.URL.Query().Get(key))
+	if raw == "" {
+		return fallback, nil
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid %s %q", key, raw)
+	}
+	return value, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, payload any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(payload)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	if errors.Is(err, errNotJSON) {
+		status = http.StatusUnsupportedMediaType
+	}
+	writeJSON(w, status, errorJSON{Error: err.Error()})
+}
+
+func writeServiceError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, session.ErrSessionNotFound):
+		writeError(w, http.StatusNotFound, err)
+	case errors.Is(err, sessionsvc.ErrSessionBusy):
+		writeError(w, http.StatusConflict, err)
+	default:
+		writeError(w, http.StatusInternalServerError, err)
+	}
+}
@@ -0,0 +1,382 @@
+package httpapi
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"iter"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	appgateway "github.com/OnslaughtSnail/caelis/internal/app/gateway"
+	"github.com/OnslaughtSnail/caelis/internal/approvalgrant"
+	"github.com/OnslaughtSnail/caelis/kernel/agent"
+	toolexec "github.com/OnslaughtSnail/caelis/kernel/execenv"
+	"github.com/OnslaughtSnail/caelis/kernel/llmagent"
+	"github.com/OnslaughtSnail/caelis/kernel/model"
+	"github.com/OnslaughtSnail/caelis/kernel/runtime"
+	"github.com/OnslaughtSnail/caelis/kernel/session/inmemory"
+	"github.com/OnslaughtSnail/caelis/kernel/sessionsvc"
+	"github.com/OnslaughtSnail/caelis/kernel/tool"
+)
+
+type noopExecRunner struct{}
+
+func (noopExecRunner) Run(context.Context, toolexec.CommandRequest) (toolexec.CommandResult, error) {
+	return toolexec.CommandResult{}, nil
+}
+
+// guardedTool asks the context approver before running, like BASH does for
+// sandbox escapes.
+type guardedTool struct {
+	approved chan bool
+}
+
+func (t *guardedTool) Name() string        { return "GUARDED" }
+func (t *guardedTool) Description() string { return "needs approval" }
+func (t *guardedTool) Declaration() model.ToolDefinition {
+	return model.ToolDefinition{Name: t.Name(), Description: t.Description(), Parameters: map[string]any{"type": "object"}}
+}
+
+func (t *guardedTool) Run(ctx context.Context, _ map[string]any) (map[string]any, error) {
+	approver, ok := toolexec.ApproverFromContext(ctx)
+	if !ok {
+		return nil, &toolexec.ApprovalRequiredError{Reason: "no approver"}
+	}
+	allowed, err := approver.Approve(ctx, toolexec.ApprovalRequest{ToolName: t.Name(), Command: "rm -rf build"})
+	if err != nil {
+		return nil, err
+	}
+	t.approved <- allowed
+	return map[string]any{"ok": allowed}, nil
+}
+
+type scriptedLLM struct{}
+
+func (scriptedLLM) Name() string { return "scripted" }
+
+func (scriptedLLM) Generate(_ context.Context, req *model.Request) iter.Seq2[*model.StreamEvent, error] {
+	last := req.Messages[len(req.Messages)-1]
+	resp := &model.Response{Message: model.NewTextMessage(model.RoleAssistant, "ok"), TurnComplete: true}
+	switch {
+	case last.Role == model.RoleUser && last.TextContent() == "clean up":
+		resp.Message = model.MessageFromToolCalls(model.RoleAssistant, []model.ToolCall{{ID: "call-1", Name: "GUARDED", Args: "{}"}}, "")
+	case last.Role == model.RoleTool:
+		resp.Message = model.NewTextMessage(model.RoleAssistant, "done")
+	}
+	return func(yield func(*model.StreamEvent, error) bool) {
+		yield(model.StreamEventFromResponse(resp), nil)
+	}
+}
+
+// newTestServer starts the API on a test listener and returns it with a
+// client that sends the server's bearer token.
+func newTestServer(t *testing.T, token string, tools ...tool.Tool) (*httptest.Server, *http.Client) {
+	t.Helper()
+	store := inmemory.New()
+	rt, err := runtime.New(runtime.Config{LogStore: store, StateStore: store})
+	if err != nil {
+		t.Fatal(err)
+	}
+	execRT, err := toolexec.New(toolexec.Config{
+		PermissionMode: toolexec.PermissionModeFullControl,
+		SandboxRunner:  noopExecRunner{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = toolexec.Close(execRT) })
+	svc, err := sessionsvc.New(sessionsvc.ServiceConfig{
+		Runtime:      rt,
+		Store:        store,
+		AppName:      "app",
+		UserID:       "u",
+		WorkspaceCWD: "/workspace",
+		Execution:    execRT,
+		Tools:        tools,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gw, err := appgateway.New(svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewUnstartedServer(nil)
+	srv, err := New(Config{
+		Addr:         ts.Listener.Addr().String(),
+		Service:      svc,
+		Gateway:      gw,
+		AppName:      "app",
+		UserID:       "u",
+		WorkspaceKey: "wk",
+		WorkspaceCWD: "/workspace",
+		AuthToken:    token,
+		NewTurn: func(context.Context, string) (agent.Agent, model.LLM, error) {
+			ag, err := llmagent.New(llmagent.Config{Name: "test", SystemPrompt: "test"})
+			return ag, scriptedLLM{}, err
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.Config.Handler = srv
+	ts.Start()
+	t.Cleanup(ts.Close)
+	return ts, &http.Client{Transport: bearerTransport{token: srv.AuthToken(), base: ts.Client().Transport}}
+}
+
+func doJSON(t *testing.T, client *http.Client, method, url, body string, out any) int {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: decode: %v", method, url, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestServer_StreamsTurnAndResolvesApproval(t *testing.T) {
+	guarded := &guardedTool{approved: make(chan bool, 1)}
+	ts, client := newTestServer(t, "", guarded)
+
+	var started sessionJSON
+	if code := doJSON(t, client, http.MethodPost, ts.URL+"/v1/sessions", `{"session_id":"s-1"}`, &started); code != http.StatusCreated || started.SessionID != "s-1" {
+		t.Fatalf("start session: status %d, %+v", code, started)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+"/v1/sessions/s-1/turns", strings.NewReader(`{"input":"clean up"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if got := resp.Header.Get("Content-Type"); got != "text/event-stream" {
+		t.Fatalf("expected event stream, got %q", got)
+	}
+
+	var (
+		names     []string
+		completed turnResultJSON
+	)
+	scanner := bufio.NewScanner(resp.Body)
+	event := ""
+	for scanner.Scan() {
+		line := scanner.Text()
+		if name, ok := strings.CutPrefix(line, "event: "); ok {
+			event = name
+			names = append(names, name)
+			continue
+		}
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok {
+			continue
+		}
+		switch event {
+		case EventApprovalRequested:
+			var pending approvalJSON
+			if err := json.Unmarshal([]byte(data), &pending); err != nil {
+				t.Fatal(err)
+			}
+			if pending.Command != "rm -rf build" || pending.SessionID != "s-1" {
+				t.Fatalf("unexpected approval payload %+v", pending)
+			}
+			var listed struct {
+				Approvals []approvalJSON `json:"approvals"`
+			}
+			doJSON(t, client, http.MethodGet, ts.URL+"/v1/approvals?session_id=s-1", "", &listed)
+			if len(listed.Approvals) != 1 || listed.Approvals[0].ID != pending.ID {
+				t.Fatalf("expected pending approval to be listed, got %+v", listed.Approvals)
+			}
+			if code := doJSON(t, client, http.MethodPost, ts.URL+"/v1/approvals/"+pending.ID, `{"decision":"maybe"}`, nil); code != http.StatusBadRequest {
+				t.Fatalf("expected invalid decision to be rejected, got %d", code)
+			}
+			if code := doJSON(t, client, http.MethodPost, ts.URL+"/v1/approvals/"+pending.ID, `{"decision":"allow_once"}`, nil); code != http.StatusNoContent {
+				t.Fatalf("resolve approval: status %d", code)
+			}
+		case EventTurnCompleted:
+			if err := json.Unmarshal([]byte(data), &completed); err != nil {
+				t.Fatal(err)
+			}
+		case EventTurnFailed:
+			t.Fatalf("turn failed: %s", data)
+		}
+	}
+	if !<-guarded.approved {
+		t.Fatal("expected tool to observe the approval")
+	}
+	if completed.Output != "done" || completed.SessionID != "s-1" {
+		t.Fatalf("unexpected completion %+v (events %v)", completed, names)
+	}
+	if names[0] != EventTurnStarted || !contains(names, EventSessionEvent) || !contains(names, EventApprovalResolved) {
+		t.Fatalf("unexpected event sequence %v", names)
+	}
+}
+
+func TestServer_BlockingTurnAndSessionEndpoints(t *testing.T) {
+	ts, client := newTestServer(t, "secret")
+
+	if code := doJSON(t, ts.Client(), http.MethodGet, ts.URL+"/v1/health", "", nil); code != http.StatusUnauthorized {
+		t.Fatalf("expected missing token to be rejected, got %d", code)
+	}
+
+	var result turnResultJSON
+	if code := doJSON(t, client, http.MethodPost, ts.URL+"/v1/sessions/s-2/turns", `{"input":"hi","metadata":{"build":"42"}}`, &result); code != http.StatusOK {
+		t.Fatalf("run turn: status %d", code)
+	}
+	if result.Output != "ok" || result.StopReason != "end_turn" || result.Metadata["build"] != "42" {
+		t.Fatalf("unexpected turn result %+v", result)
+	}
+	if len(result.Events) == 0 {
+		t.Fatal("expected blocking turn to return its events")
+	}
+
+	var loaded loadedSessionJSON
+	if code := doJSON(t, client, http.MethodGet, ts.URL+"/v1/sessions/s-2", "", &loaded); code != http.StatusOK {
+		t.Fatalf("load session: status %d", code)
+	}
+	if len(loaded.Events) < 2 || loaded.Events[len(loaded.Events)-1].Message.TextContent() != "ok" {
+		t.Fatalf("expected persisted history, got %+v", loaded.Events)
+	}
+	if code := doJSON(t, client, http.MethodGet, ts.URL+"/v1/sessions/missing", "", nil); code != http.StatusNotFound {
+		t.Fatalf("expected unknown session to 404, got %d", code)
+	}
+	if code := doJSON(t, client, http.MethodPost, ts.URL+"/v1/sessions/s-2/turns", `{"input":"  "}`, nil); code != http.StatusBadRequest {
+		t.Fatalf("expected empty input to be rejected, got %d", code)
+	}
+	if code := doJSON(t, client, http.MethodPost, ts.URL+"/v1/approvals/apr-unknown", `{"decision":"reject"}`, nil); code != http.StatusNotFound {
+		t.Fatalf("expected unknown approval to 404, got %d", code)
+	}
+	if code := doJSON(t, client, http.MethodPost, ts.URL+"/v1/sessions/s-2/interrupt", "", nil); code != http.StatusNoContent {
+		t.Fatalf("interrupt idle session: status %d", code)
+	}
+}
+
+func TestServer_RefusesCrossSiteRequests(t *testing.T) {
+	ts, client := newTestServer(t, "")
+
+	if code := doJSON(t, ts.Client(), http.MethodGet, ts.URL+"/v1/health", "", nil); code != http.StatusUnauthorized {
+		t.Fatalf("expected the generated token to be required, got %d", code)
+	}
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+"/v1/sessions", strings.NewReader(`{"session_id":"s-3"}`))
+	req.Header.Set("Content-Type", "text/plain")
+	if resp, err := client.Do(req); err != nil || resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected a text/plain body to be refused, got %v (%v)", resp, err)
+	}
+	req, _ = http.NewRequest(http.MethodGet, ts.URL+"/v1/health", nil)
+	req.Header.Set("Origin", "https://evil.example")
+	if resp, err := client.Do(req); err != nil || resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected a foreign origin to be refused, got %v (%v)", resp, err)
+	}
+	req, _ = http.NewRequest(http.MethodGet, ts.URL+"/v1/health", nil)
+	req.Host = "evil.example:" + ts.URL[strings.LastIndex(ts.URL, ":")+1:]
+	if resp, err := client.Do(req); err != nil || resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected a rebound host to be refused, got %v (%v)", resp, err)
+	}
+	req, _ = http.NewRequest(http.MethodGet, ts.URL+"/v1/health", nil)
+	req.Header.Set("Origin", ts.URL)
+	if resp, err := client.Do(req); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected a same-origin request to pass, got %v (%v)", resp, err)
+	}
+}
+
+func TestServer_CloseSessionDropsApprover(t *testing.T) {
+	ts, client := newTestServer(t, "")
+	srv := ts.Config.Handler.(*Server)
+
+	srv.approver("s-4").allow("rm -rf build")
+	if code := doJSON(t, client, http.MethodPost, ts.URL+"/v1/sessions/s-4/turns", `{"input":"hi"}`, nil); code != http.StatusOK {
+		t.Fatalf("run turn: status %d", code)
+	}
+	srv.approver("s-5")
+	if code := doJSON(t, client, http.MethodPost, ts.URL+"/v1/sessions/s-5/turns", `{"input":"hi"}`, nil); code != http.StatusOK {
+		t.Fatalf("run turn: status %d", code)
+	}
+	if code := doJSON(t, client, http.MethodDelete, ts.URL+"/v1/sessions/s-4", "", nil); code != http.StatusNoContent {
+		t.Fatalf("close session: status %d", code)
+	}
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if len(srv.approvers) != 0 {
+		t.Fatalf("expected no approvers after close, got %v", srv.approvers)
+	}
+}
+
+type memoryGrantBackend struct {
+	grants []approvalgrant.Grant
+}
+
+func (b *memoryGrantBackend) LoadApprovalGrants() []approvalgrant.Grant { return b.grants }
+
+func (b *memoryGrantBackend) SaveApprovalGrants(grants []approvalgrant.Grant) error {
+	b.grants = grants
+	return nil
+}
+
+func TestSessionApprover_UsesAndStoresApprovalGrants(t *testing.T) {
+	grants := approvalgrant.NewStore(&memoryGrantBackend{})
+	if _, err := grants.Add("/workspace", approvalgrant.KindCommand, "go test", approvalgrant.ScopeWorkspace); err != nil {
+		t.Fatal(err)
+	}
+	broker := newApprovalBroker()
+	approver := newSessionApprover(broker, "s-1", grants, "/workspace")
+	ctx := context.Background()
+
+	allowed, err := approver.Approve(ctx, toolexec.ApprovalRequest{ToolName: "BASH", Command: "go test ./..."})
+	if err != nil || !allowed {
+		t.Fatalf("expected the stored grant to approve without a prompt, got %v, %v", allowed, err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := approver.Approve(ctx, toolexec.ApprovalRequest{ToolName: "BASH", Command: "go build ./..."})
+		done <- err
+	}()
+	var pending []approvalJSON
+	for len(pending) == 0 {
+		pending = broker.list("s-1")
+		time.Sleep(time.Millisecond)
+	}
+	broker.resolve(pending[0].ID, DecisionAllowAlways)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if !grants.AllowsCommand("/workspace", "go build ./cmd/...") {
+		t.Fatalf("expected allow_always to store a workspace grant, got %+v", grants.List("/workspace"))
+	}
+}
+
+type bearerTransport struct {
+	token string
+	base  http.RoundTripper
+}
+
+func (b bearerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+b.token)
+	return b.base.RoundTrip(req)
+}
+
+func contains(items []string, want string) bool {
+	for _, item := range items {
+		if item == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,201 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	appgateway "github.com/OnslaughtSnail/caelis/internal/app/gateway"
+	"github.com/OnslaughtSnail/caelis/kernel/model"
+	"github.com/OnslaughtSnail/caelis/kernel/runtime"
+	"github.com/OnslaughtSnail/caelis/kernel/session"
+	"github.com/OnslaughtSnail/caelis/kernel/sessionsvc"
+)
+
+// SSE event names written on turn streams.
+const (
+	EventTurnStarted       = "turn.started"
+	EventSessionEvent      = "session.event"
+	EventApprovalRequested = "approval.requested"
+	EventApprovalResolved  = "approval.resolved"
+	EventTurnCompleted     = "turn.completed"
+	EventTurnFailed        = "turn.failed"
+)
+
+type errorJSON struct {
+	Error string `json:"error"`
+}
+
+type sessionJSON struct {
+	SessionID    string    `json:"session_id"`
+	WorkspaceKey string    `json:"workspace_key,omitempty"`
+	CWD          string    `json:"cwd,omitempty"`
+	Title        string    `json:"title,omitempty"`
+	UpdatedAt    time.Time `json:"updated_at,omitzero"`
+}
+
+type sessionListJSON struct {
+	Sessions   []sessionJSON `json:"sessions"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+type loadedSessionJSON struct {
+	sessionJSON
+	Events []eventJSON    `json:"events"`
+	State  map[string]any `json:"state,omitempty"`
+}
+
+type eventJSON struct {
+	ID        string         `json:"id,omitempty"`
+	SessionID string         `json:"session_id,omitempty"`
+	Time      time.Time      `json:"time,omitzero"`
+	Partial   bool           `json:"partial,omitempty"`
+	Channel   string         `json:"channel,omitempty"`
+	Lifecycle string         `json:"lifecycle,omitempty"`
+	Message   model.Message  `json:"message"`
+	Meta      map[string]any `json:"meta,omitempty"`
+	Extras    map[string]any `json:"extras,omitempty"`
+}
+
+type delegationJSON struct {
+	ParentSessionID  string `json:"parent_session_id"`
+	ChildSessionID   string `json:"child_session_id"`
+	DelegationID     string `json:"delegation_id,omitempty"`
+	ParentToolCallID string `json:"parent_tool_call_id,omitempty"`
+	ParentToolName   string `json:"parent_tool_name,omitempty"`
+}
+
+type startSessionJSON struct {
+	SessionID string `json:"session_id,omitempty"`
+}
+
+// turnRequestJSON is the wire form of one inbound envelope.
+type turnRequestJSON struct {
+	Input    string         `json:"input"`
+	Stream   *bool          `json:"stream,omitempty"`
+	Actor    string         `json:"actor,omitempty"`
+	Metadata map[string]any `json:"metadata,omitempty"`
+}
+
+// turnResultJSON is the wire form of one outbound envelope.
+type turnResultJSON struct {
+	SessionID  string         `json:"session_id"`
+	RunID      string         `json:"run_id,omitempty"`
+	Output     string         `json:"output"`
+	StopReason string         `json:"stop_reason,omitempty"`
+	Metadata   map[string]any `json:"metadata,omitempty"`
+	Events     []eventJSON    `json:"events,omitempty"`
+}
+
+type turnStartedJSON struct {
+	SessionID string `json:"session_id"`
+	RunID     string `json:"run_id,omitempty"`
+}
+
+type interruptJSON struct {
+	Reason string `json:"reason,omitempty"`
+}
+
+func sessionFromInfo(info sessionsvc.SessionInfo) sessionJSON {
+	return sessionJSON{
+		SessionID:    info.SessionID,
+		WorkspaceKey: info.WorkspaceKey,
+		CWD:          info.CWD,
+		Title:        info.Title,
+	}
+}
+
+func sessionFromSummary(item sessionsvc.SessionSummary) sessionJSON {
+	return sessionJSON{
+		SessionID:    item.SessionID,
+		WorkspaceKey: item.WorkspaceKey,
+		CWD:          item.CWD,
+		Title:        item.Title,
+		UpdatedAt:    item.UpdatedAt,
+	}
+}
+
+func delegationFromRef(ref sessionsvc.DelegationRef) delegationJSON {
+	return delegationJSON{
+		ParentSessionID:  ref.ParentSessionID,
+		ChildSessionID:   ref.ChildSessionID,
+		DelegationID:     ref.DelegationID,
+		ParentToolCallID: ref.ParentToolCallID,
+		ParentToolName:   ref.ParentToolName,
+	}
+}
+
+func eventFromSession(ev *session.Event) eventJSON {
+	out := eventJSON{
+		ID:        ev.ID,
+		SessionID: ev.SessionID,
+		Time:      ev.Time,
+		Partial:   session.IsPartial(ev),
+		Channel:   string(session.PartialChannelOf(ev)),
+		Message:   ev.Message,
+		Meta:      encodableMeta(ev.Meta),
+	}
+	if info, ok := runtime.LifecycleFromEvent(ev); ok {
+		out.Lifecycle = string(info.Status)
+	}
+	return out
+}
+
+// encodableMeta drops event metadata that cannot be JSON encoded so one odd
+// value does not break the whole stream.
+func encodableMeta(meta map[string]any) map[string]any {
+	if len(meta) == 0 {
+		return nil
+	}
+	if _, err := json.Marshal(meta); err == nil {
+		return meta
+	}
+	out := make(map[string]any, len(meta))
+	for key, value := range meta {
+		if _, err := json.Marshal(value); err == nil {
+			out[key] = value
+		}
+	}
+	return out
+}
+
+func inboundFromRequest(ref sessionsvc.SessionRef, req turnRequestJSON, defaultStream bool) appgateway.InboundEnvelope {
+	stream := defaultStream
+	if req.Stream != nil {
+		stream = *req.Stream
+	}
+	actor := strings.TrimSpace(req.Actor)
+	if actor == "" {
+		actor = "api"
+	}
+	return appgateway.InboundEnvelope{
+		Session:  ref,
+		Actor:    appgateway.ActorRef{ID: actor, Kind: "api"},
+		Content:  strings.TrimSpace(req.Input),
+		Stream:   stream,
+		Metadata: req.Metadata,
+	}
+}
+
+func resultFromOutbound(env appgateway.OutboundEnvelope) turnResultJSON {
+	out := turnResultJSON{
+		SessionID: env.Session.SessionID,
+		Metadata:  map[string]any{},
+	}
+	for key, value := range env.Metadata {
+		switch key {
+		case "run_id":
+			out.RunID, _ = value.(string)
+		case "output":
+			out.Output, _ = value.(string)
+		case "stop_reason":
+			out.StopReason, _ = value.(string)
+		default:
+			out.Metadata[key] = value
+		}
+	}
+	if len(out.Metadata) == 0 {
+		out.Metadata = nil
+	}
+	return out
+}
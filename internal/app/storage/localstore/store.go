@@ -1,6 +1,7 @@
 package localstore
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
 	"encoding/json"
@@ -298,6 +299,77 @@ func (s *ScopeStore) ListEvents(ctx context.Context, req *session.Session) ([]*s
 	return readLogEvents(meta.RolloutPath)
 }
 
+// TruncateEvents rewrites the rollout without the event eventID and anything
+// after it, then recomputes the session row from the shortened rollout.
+func (s *ScopeStore) TruncateEvents(ctx context.Context, req *session.Session, eventID string) (int, error) {
+	if err := validateSession(req); err != nil {
+		return 0, err
+	}
+	meta, err := s.lookupSession(ctx, req)
+	if err != nil {
+		return 0, err
+	}
+	removed, err := s.truncateRollout(meta.RolloutPath, eventID)
+	if err != nil {
+		return 0, err
+	}
+	return removed, s.backfillRollout(ctx, meta.RolloutPath)
+}
+
+func (s *ScopeStore) truncateRollout(path string, eventID string) (int, error) {
+	s.db.logMu.Lock()
+	defer s.db.logMu.Unlock()
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, session.ErrEventNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	var (
+		kept    bytes.Buffer
+		cut     = false
+		removed = 0
+	)
+	for {
+		var rawLine json.RawMessage
+		if err := dec.Decode(&rawLine); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return 0, err
+		}
+		var line logLine
+		if err := json.Unmarshal(rawLine, &line); err != nil {
+			return 0, err
+		}
+		if line.Type == "event" && line.Event != nil && line.Event.ID == eventID {
+			cut = true
+		}
+		if cut {
+			if line.Type == "event" {
+				removed++
+			}
+			continue
+		}
+		kept.Write(rawLine)
+		kept.WriteByte('\n')
+	}
+	if !cut {
+		return 0, session.ErrEventNotFound
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, kept.Bytes(), 0o644); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return 0, err
+	}
+	return removed, nil
+}
+
 func (s *ScopeStore) ListEventsAfter(ctx context.Context, req *session.Session, afterCursor string, limit int) ([]*session.Event, string, error) {
 	events, err := s.ListEvents(ctx, req)
 	if err != nil {
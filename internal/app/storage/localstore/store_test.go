@@ -3,6 +3,7 @@ package localstore
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -76,6 +77,55 @@ func TestScopeStore_AppendsEventsToRolloutAndCatalog(t *testing.T) {
 	}
 }
 
+func TestScopeStore_TruncateEventsRewritesRolloutAndCatalog(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "sessions")
+	db, err := Open(root, filepath.Join(filepath.Dir(root), "state.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	store := db.Scope(Workspace{Key: "ws", CWD: "/tmp/ws"}, ScopeMain)
+	sess := &session.Session{AppName: "caelis", UserID: "local-user", ID: "s-trunc"}
+	for i, text := range []string{"first", "reply", "second", "reply"} {
+		role := model.RoleUser
+		if i%2 == 1 {
+			role = model.RoleAssistant
+		}
+		if err := store.AppendEvent(context.Background(), sess, &session.Event{
+			ID:      fmt.Sprintf("e%d", i+1),
+			Message: model.NewTextMessage(role, text),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := store.TruncateEvents(context.Background(), sess, "e3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 2 {
+		t.Fatalf("expected 2 removed events, got %d", removed)
+	}
+	events, err := store.ListEvents(context.Background(), sess)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[1].ID != "e2" {
+		t.Fatalf("unexpected events after truncate %+v", events)
+	}
+	items, err := store.ListSessionsPage(context.Background(), 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].EventCount != 2 || items[0].LastUserMessage != "first" {
+		t.Fatalf("expected catalog to follow the truncated rollout, got %+v", items)
+	}
+	if _, err := store.TruncateEvents(context.Background(), sess, "missing"); !errors.Is(err, session.ErrEventNotFound) {
+		t.Fatalf("expected ErrEventNotFound, got %v", err)
+	}
+}
+
 func TestDatabaseWithWriteTx_RollsBackOnError(t *testing.T) {
 	root := filepath.Join(t.TempDir(), "sessions")
 	dbPath := filepath.Join(filepath.Dir(root), "state.db")
@@ -0,0 +1,304 @@
+// Package approvalgrant keeps "always allow" approval decisions that outlive
+// one session, scoped to a workspace or to every workspace.
+package approvalgrant
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	toolexec "github.com/OnslaughtSnail/caelis/kernel/execenv"
+	"github.com/OnslaughtSnail/caelis/kernel/policy"
+)
+
+// Scope is how widely one grant applies.
+type Scope string
+
+const (
+	ScopeWorkspace Scope = "workspace"
+	ScopeGlobal    Scope = "global"
+)
+
+// Kind is what one grant key identifies.
+type Kind string
+
+const (
+	// KindCommand keys are command prefixes such as "go build".
+	KindCommand Kind = "command"
+	// KindTool keys are tool authorization scope keys: a tool name, a
+	// directory or a network host.
+	KindTool Kind = "tool"
+)
+
+// Grant is one stored approval.
+type Grant struct {
+	ID        string    `json:"id"`
+	Kind      Kind      `json:"kind"`
+	Key       string    `json:"key"`
+	Scope     Scope     `json:"scope"`
+	Workspace string    `json:"workspace,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Backend persists grants, typically in the app config file.
+type Backend interface {
+	LoadApprovalGrants() []Grant
+	SaveApprovalGrants([]Grant) error
+}
+
+// Store answers grant lookups and writes changes through to its backend. A
+// nil Store grants nothing.
+type Store struct {
+	mu      sync.Mutex
+	backend Backend
+	grants  []Grant
+}
+
+// NewStore loads the grants held by backend.
+func NewStore(backend Backend) *Store {
+	s := &Store{backend: backend}
+	if backend != nil {
+		s.grants = slices.Clone(backend.LoadApprovalGrants())
+	}
+	return s
+}
+
+// AllowsCommand reports whether a command grant covers every command in a
+// shell chain. Unlike session approvals, stored grants exempt no wrapper
+// commands: a granted "go build" after "source env.sh" still needs approval,
+// since the sourced file can redefine what runs. Commands using shell syntax
+// beyond plain chains, such as "go test $(...)" or "go test > file", are
+// never covered.
+func (s *Store) AllowsCommand(workspace, command string) bool {
+	if s == nil {
+		return false
+	}
+	segments, ok := toolexec.PlainCommandSegments(command)
+	if !ok {
+		return false
+	}
+	keys := s.keys(workspace, KindCommand)
+	if len(keys) == 0 {
+		return false
+	}
+	for _, tokens := range segments {
+		if !slices.ContainsFunc(keys, func(key string) bool { return hasCommandPrefix(tokens, key) }) {
+			return false
+		}
+	}
+	return true
+}
+
+// AllowsTool reports whether a tool grant covers one authorization scope key.
+func (s *Store) AllowsTool(workspace, scopeKey string) bool {
+	if s == nil {
+		return false
+	}
+	scopeKey = strings.TrimSpace(scopeKey)
+	return scopeKey != "" && slices.Contains(s.keys(workspace, KindTool), scopeKey)
+}
+
+// Add stores one grant. Workspace grants are bound to workspace; adding a
+// grant that already exists is a no-op.
+func (s *Store) Add(workspace string, kind Kind, key string, scope Scope) (Grant, error) {
+	if s == nil {
+		return Grant{}, fmt.Errorf("approvalgrant: store is unavailable")
+	}
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return Grant{}, fmt.Errorf("approvalgrant: key is required")
+	}
+	if kind != KindCommand && kind != KindTool {
+		return Grant{}, fmt.Errorf("approvalgrant: unsupported kind %q", kind)
+	}
+	grant := Grant{Kind: kind, Key: key, Scope: scope}
+	switch scope {
+	case ScopeWorkspace:
+		grant.Workspace = normalizeWorkspace(workspace)
+		if grant.Workspace == "" {
+			return Grant{}, fmt.Errorf("approvalgrant: workspace is required for workspace grants")
+		}
+	case ScopeGlobal:
+	default:
+		return Grant{}, fmt.Errorf("approvalgrant: unsupported scope %q", scope)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, existing := range s.grants {
+		if existing.Kind == grant.Kind && existing.Key == grant.Key && existing.Scope == grant.Scope && existing.Workspace == grant.Workspace {
+			return existing, nil
+		}
+	}
+	grant.ID = newGrantID()
+	grant.CreatedAt = time.Now().UTC()
+	next := append(slices.Clone(s.grants), grant)
+	if err := s.save(next); err != nil {
+		return Grant{}, err
+	}
+	return grant, nil
+}
+
+// List returns the grants that apply in workspace, oldest first.
+func (s *Store) List(workspace string) []Grant {
+	if s == nil {
+		return nil
+	}
+	workspace = normalizeWorkspace(workspace)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []Grant
+	for _, grant := range s.grants {
+		if grant.appliesTo(workspace) {
+			out = append(out, grant)
+		}
+	}
+	return out
+}
+
+// Revoke removes the grant with id, or the grants matching key when id is a
+// command prefix or scope key instead. It returns the removed grants.
+func (s *Store) Revoke(workspace, idOrKey string) ([]Grant, error) {
+	if s == nil {
+		return nil, nil
+	}
+	idOrKey = strings.TrimSpace(idOrKey)
+	workspace = normalizeWorkspace(workspace)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var removed, kept []Grant
+	for _, grant := range s.grants {
+		if grant.appliesTo(workspace) && (grant.ID == idOrKey || grant.Key == idOrKey) {
+			removed = append(removed, grant)
+			continue
+		}
+		kept = append(kept, grant)
+	}
+	if len(removed) == 0 {
+		return nil, nil
+	}
+	if err := s.save(kept); err != nil {
+		return nil, err
+	}
+	return removed, nil
+}
+
+// CommandRules returns command grants as allow rules, so the policy chain can
+// route granted host commands without prompting.
+func (s *Store) CommandRules(workspace string) []policy.PermissionRule {
+	if s == nil {
+		return nil
+	}
+	keys := s.keys(workspace, KindCommand)
+	if len(keys) == 0 {
+		return nil
+	}
+	return []policy.PermissionRule{{
+		Effect:   policy.PermissionAllow,
+		Tool:     "BASH",
+		Commands: keys,
+		Source:   "approval grants",
+	}}
+}
+
+func (s *Store) keys(workspace string, kind Kind) []string {
+	workspace = normalizeWorkspace(workspace)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []string
+	for _, grant := range s.grants {
+		if grant.Kind == kind && grant.appliesTo(workspace) {
+			out = append(out, grant.Key)
+		}
+	}
+	return out
+}
+
+func (s *Store) save(grants []Grant) error {
+	if s.backend != nil {
+		if err := s.backend.SaveApprovalGrants(grants); err != nil {
+			return err
+		}
+	}
+	s.grants = grants
+	return nil
+}
+
+func (g Grant) appliesTo(workspace string) bool {
+	return g.Scope == ScopeGlobal || (g.Scope == ScopeWorkspace && g.Workspace != "" && g.Workspace == workspace)
+}
+
+// CommandKey returns the command family an approval can be remembered for,
+// such as "go test", or "" when the command should be approved every time.
+func CommandKey(command string) string {
+	segments, ok := toolexec.PlainCommandSegments(command)
+	if !ok {
+		return ""
+	}
+	for _, tokens := range segments {
+		base := commandBase(tokens)
+		if isWrapperCommand(base) {
+			continue
+		}
+		switch base {
+		case "go", "git", "npm", "pnpm", "yarn", "cargo", "make":
+			if len(tokens) > 1 && !strings.HasPrefix(tokens[1], "-") {
+				return strings.TrimSpace(base + " " + strings.ToLower(tokens[1]))
+			}
+			return strings.TrimSpace(base)
+		default:
+			return ""
+		}
+	}
+	return ""
+}
+
+func commandBase(tokens []string) string {
+	if len(tokens) == 0 {
+		return ""
+	}
+	return strings.ToLower(filepath.Base(tokens[0]))
+}
+
+func hasCommandPrefix(tokens []string, key string) bool {
+	words := strings.Fields(key)
+	if len(words) == 0 || len(tokens) < len(words) || commandBase(tokens) != words[0] {
+		return false
+	}
+	for i := 1; i < len(words); i++ {
+		if strings.ToLower(tokens[i]) != words[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func isWrapperCommand(base string) bool {
+	switch strings.ToLower(strings.TrimSpace(base)) {
+	case "", "cd", "pwd", "export", "unset", "alias", "source", ".", "grep", "egrep", "fgrep", "head", "tail":
+		return true
+	default:
+		return false
+	}
+}
+
+func normalizeWorkspace(workspace string) string {
+	workspace = strings.TrimSpace(workspace)
+	if workspace == "" {
+		return ""
+	}
+	return filepath.Clean(workspace)
+}
+
+func newGrantID() string {
+	var buf [4]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return fmt.Sprintf("%08x", time.Now().UnixNano()&0xffffffff)
+	}
+	return hex.EncodeToString(buf[:])
+}
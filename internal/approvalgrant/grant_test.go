@@ -0,0 +1,171 @@
+package approvalgrant
+
+import (
+	"testing"
+)
+
+type memoryBackend struct {
+	grants []Grant
+	saves  int
+}
+
+func (b *memoryBackend) LoadApprovalGrants() []Grant {
+	return b.grants
+}
+
+func (b *memoryBackend) SaveApprovalGrants(grants []Grant) error {
+	b.grants = append([]Grant(nil), grants...)
+	b.saves++
+	return nil
+}
+
+func TestStore_WorkspaceAndGlobalCommandGrants(t *testing.T) {
+	backend := &memoryBackend{}
+	store := NewStore(backend)
+
+	if _, err := store.Add("/repo", KindCommand, "go build", ScopeWorkspace); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Add("/repo", KindCommand, "make", ScopeGlobal); err != nil {
+		t.Fatal(err)
+	}
+	if !store.AllowsCommand("/repo", "go build ./... && make lint") {
+		t.Fatal("expected grants to cover a chain of granted commands")
+	}
+	for _, command := range []string{
+		"cd /repo && go build ./... 2>&1 | tail -5",
+		"source env.sh && go build ./...",
+		". ./env.sh; go build ./...",
+		"export GOFLAGS=-toolexec=./x && go build ./...",
+		"alias go=./evil; go build ./...",
+	} {
+		if store.AllowsCommand("/repo", command) {
+			t.Fatalf("expected stored grants to skip no wrapper in %q", command)
+		}
+	}
+	if store.AllowsCommand("/other", "go build ./...") {
+		t.Fatal("did not expect workspace grant to apply in another workspace")
+	}
+	if !store.AllowsCommand("/other", "make test") {
+		t.Fatal("expected global grant to apply everywhere")
+	}
+	if store.AllowsCommand("/repo", "go build ./... && curl example.com") {
+		t.Fatal("expected ungranted command in chain to need approval")
+	}
+	if store.AllowsCommand("/repo", "go buildx") {
+		t.Fatal("expected prefix to compare whole words")
+	}
+
+	reloaded := NewStore(backend)
+	if got := reloaded.List("/repo"); len(got) != 2 {
+		t.Fatalf("expected grants to persist, got %#v", got)
+	}
+	if got := reloaded.List("/other"); len(got) != 1 || got[0].Key != "make" {
+		t.Fatalf("expected only global grant outside workspace, got %#v", got)
+	}
+}
+
+func TestStore_CommandGrantsRefuseShellSyntaxBeyondPlainChains(t *testing.T) {
+	store := NewStore(&memoryBackend{})
+	if _, err := store.Add("/repo", KindCommand, "go test", ScopeWorkspace); err != nil {
+		t.Fatal(err)
+	}
+	for _, command := range []string{
+		"go test ./... 2>&1 | go test -run X",
+		"go test ./... >&2",
+		`go test -run "a|b" ./...`,
+	} {
+		if !store.AllowsCommand("/repo", command) {
+			t.Fatalf("expected the grant to cover %q", command)
+		}
+	}
+	for _, command := range []string{
+		"go test ./... & rm -rf ~",
+		"go test $(rm -rf ~)",
+		`go test "$(rm -rf ~)"`,
+		"go test `curl example.com`",
+		"go test > ~/.bashrc",
+		"go test 2>> ~/.bashrc",
+		"go test < /etc/passwd",
+		"go test >&2file",
+		"go test &> out.txt",
+		"go test |& go test",
+		"go test <(rm -rf ~)",
+		"go test\nrm -rf ~",
+		"go test ./... \\\nrm -rf ~",
+		"LD_PRELOAD=/tmp/x.so go test",
+		"go test 'unterminated",
+	} {
+		if store.AllowsCommand("/repo", command) {
+			t.Fatalf("expected the grant not to cover %q", command)
+		}
+	}
+}
+
+func TestStore_AddIsIdempotentAndRevokeByIDOrKey(t *testing.T) {
+	backend := &memoryBackend{}
+	store := NewStore(backend)
+	first, err := store.Add("/repo", KindTool, "MCP__FETCH", ScopeWorkspace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := store.Add("/repo", KindTool, "MCP__FETCH", ScopeWorkspace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID != first.ID || backend.saves != 1 {
+		t.Fatalf("expected duplicate add to be a no-op, got %#v saves=%d", again, backend.saves)
+	}
+	if !store.AllowsTool("/repo", "MCP__FETCH") {
+		t.Fatal("expected tool grant")
+	}
+	if _, err := store.Add("/repo", KindCommand, "go test", ScopeGlobal); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := store.Revoke("/repo", first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 1 || store.AllowsTool("/repo", "MCP__FETCH") {
+		t.Fatalf("expected revoke by id, got %#v", removed)
+	}
+	removed, err = store.Revoke("/repo", "go test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 1 || len(backend.grants) != 0 {
+		t.Fatalf("expected revoke by key to empty the backend, got %#v", backend.grants)
+	}
+}
+
+func TestStore_CommandRulesAndNilStore(t *testing.T) {
+	var nilStore *Store
+	if nilStore.AllowsCommand("/repo", "go test") || nilStore.CommandRules("/repo") != nil {
+		t.Fatal("expected nil store to grant nothing")
+	}
+	store := NewStore(nil)
+	if _, err := store.Add("/repo", KindCommand, "go test", ScopeWorkspace); err != nil {
+		t.Fatal(err)
+	}
+	rules := store.CommandRules("/repo")
+	if len(rules) != 1 || rules[0].Tool != "BASH" || len(rules[0].Commands) != 1 || rules[0].Commands[0] != "go test" {
+		t.Fatalf("unexpected command rules %#v", rules)
+	}
+	if _, err := store.Add("", KindCommand, "go test", ScopeWorkspace); err == nil {
+		t.Fatal("expected workspace grant without workspace to fail")
+	}
+}
+
+func TestCommandKey(t *testing.T) {
+	for command, want := range map[string]string{
+		`cd /tmp && go test ./kernel/... -count=1 2>&1 | grep PASS`: "go test",
+		"make -j4":                     "make",
+		"grep hi a.txt && rm -f a.txt": "",
+		"go test $(curl example.com)":  "",
+	} {
+		if got := CommandKey(command); got != want {
+			t.Fatalf("CommandKey(%q) = %q, want %q", command, got, want)
+		}
+	}
+}
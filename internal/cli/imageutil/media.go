@@ -0,0 +1,118 @@
+package imageutil
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/OnslaughtSnail/caelis/kernel/model"
+)
+
+// DocumentExtensions maps attachable document extensions to their MIME types.
+var DocumentExtensions = map[string]string{
+	".pdf": "application/pdf",
+}
+
+// AudioExtensions maps attachable audio extensions to their MIME types.
+var AudioExtensions = map[string]string{
+	".mp3":  "audio/mpeg",
+	".wav":  "audio/wav",
+	".m4a":  "audio/mp4",
+	".aac":  "audio/aac",
+	".ogg":  "audio/ogg",
+	".flac": "audio/flac",
+}
+
+// MaxMediaBytes is the maximum file size allowed for document and audio
+// attachments (20MB).
+const MaxMediaBytes = 20 * 1024 * 1024
+
+// IsMediaPath returns true if the file path is a document or audio file that
+// can be attached as a media part.
+func IsMediaPath(path string) bool {
+	_, _, ok := mediaTypeForPath(path)
+	return ok
+}
+
+// LoadMediaAsContentPart reads a document or audio file from disk and returns
+// it as a ContentPart. Unlike images, the bytes are attached unchanged.
+func LoadMediaAsContentPart(absPath string) (model.ContentPart, error) {
+	partType, mime, ok := mediaTypeForPath(absPath)
+	if !ok {
+		return model.ContentPart{}, fmt.Errorf("unsupported media type: %s", filepath.Ext(absPath))
+	}
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return model.ContentPart{}, fmt.Errorf("media stat: %w", err)
+	}
+	if info.IsDir() {
+		return model.ContentPart{}, fmt.Errorf("path is a directory, not a media file: %s", absPath)
+	}
+	if info.Size() > MaxMediaBytes {
+		return model.ContentPart{}, fmt.Errorf("media too large: %d bytes (max %d)", info.Size(), MaxMediaBytes)
+	}
+	data, err := os.ReadFile(absPath)
+	if err != nil {
+		return model.ContentPart{}, fmt.Errorf("media read: %w", err)
+	}
+	return mediaContentPart(partType, data, mime, filepath.Base(absPath))
+}
+
+// MediaContentPartFromBytes creates a document or audio ContentPart from raw
+// bytes. The part type follows the MIME type: application/pdf and text/*
+// become documents and audio/* becomes audio.
+func MediaContentPartFromBytes(raw []byte, mime string, fileName string) (model.ContentPart, error) {
+	partType, ok := MediaTypeForMime(mime)
+	if !ok {
+		return model.ContentPart{}, fmt.Errorf("unsupported media type: %s", mime)
+	}
+	if len(raw) > MaxMediaBytes {
+		return model.ContentPart{}, fmt.Errorf("media too large: %d bytes (max %d)", len(raw), MaxMediaBytes)
+	}
+	return mediaContentPart(partType, raw, mime, fileName)
+}
+
+// MediaTypeForMime returns the content part type for a document or audio MIME
+// type.
+func MediaTypeForMime(mime string) (model.ContentPartType, bool) {
+	mime = strings.ToLower(strings.TrimSpace(mime))
+	switch {
+	case mime == "application/pdf", strings.HasPrefix(mime, "text/"):
+		return model.ContentPartDocument, true
+	case strings.HasPrefix(mime, "audio/"):
+		return model.ContentPartAudio, true
+	}
+	return "", false
+}
+
+// MediaMimeTypeForPath returns the MIME type for a recognized document or
+// audio file path.
+func MediaMimeTypeForPath(path string) (string, bool) {
+	_, mime, ok := mediaTypeForPath(path)
+	return mime, ok
+}
+
+func mediaContentPart(partType model.ContentPartType, raw []byte, mime string, fileName string) (model.ContentPart, error) {
+	if len(raw) == 0 {
+		return model.ContentPart{}, fmt.Errorf("empty media data")
+	}
+	return model.ContentPart{
+		Type:     partType,
+		MimeType: strings.TrimSpace(mime),
+		Data:     base64.StdEncoding.EncodeToString(raw),
+		FileName: fileName,
+	}, nil
+}
+
+func mediaTypeForPath(path string) (model.ContentPartType, string, bool) {
+	ext := strings.ToLower(filepath.Ext(path))
+	if mime, ok := DocumentExtensions[ext]; ok {
+		return model.ContentPartDocument, mime, true
+	}
+	if mime, ok := AudioExtensions[ext]; ok {
+		return model.ContentPartAudio, mime, true
+	}
+	return "", "", false
+}
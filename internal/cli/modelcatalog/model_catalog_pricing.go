@@ -0,0 +1,68 @@
+package modelcatalog
+
+import (
+	"strings"
+
+	"github.com/OnslaughtSnail/caelis/kernel/model"
+)
+
+// ModelPricing is the list price of a model in USD per million tokens. Cache
+// prices of zero fall back to the input price.
+type ModelPricing struct {
+	Input      float64 `json:"input"`
+	Output     float64 `json:"output"`
+	CacheRead  float64 `json:"cache_read,omitempty"`
+	CacheWrite float64 `json:"cache_write,omitempty"`
+}
+
+// Cost returns the USD cost of one model call at this price.
+func (p ModelPricing) Cost(usage model.Usage) float64 {
+	cacheRead := p.CacheRead
+	if cacheRead <= 0 {
+		cacheRead = p.Input
+	}
+	cacheWrite := p.CacheWrite
+	if cacheWrite <= 0 {
+		cacheWrite = p.Input
+	}
+	uncached := usage.PromptTokens - usage.CacheReadTokens - usage.CacheWriteTokens
+	if uncached < 0 {
+		uncached = 0
+	}
+	total := float64(uncached)*p.Input +
+		float64(usage.CacheReadTokens)*cacheRead +
+		float64(usage.CacheWriteTokens)*cacheWrite +
+		float64(usage.CompletionTokens)*p.Output
+	return total / 1_000_000
+}
+
+// LookupModelPricing returns the price of a model from the local override
+// file, the remote models.dev catalog or the embedded snapshot, in that order.
+func LookupModelPricing(provider, modelName string) (ModelPricing, bool) {
+	provider = strings.ToLower(strings.TrimSpace(provider))
+	modelName = strings.ToLower(strings.TrimSpace(modelName))
+	if provider == "" || modelName == "" {
+		return ModelPricing{}, false
+	}
+
+	dynamicMu.RLock()
+	catalogs := []capSnapshot{localOverrides, remoteCatalog, embeddedCatalog}
+	dynamicMu.RUnlock()
+
+	for _, snap := range catalogs {
+		if entry, ok := searchCapEntry(snap, provider, modelName); ok && entry.Cost != nil {
+			return *entry.Cost, true
+		}
+	}
+	return ModelPricing{}, false
+}
+
+// EstimateCost prices one model call. It matches tokenusage.Pricer so the
+// runtime can record cost as usage arrives.
+func EstimateCost(provider, modelName string, usage model.Usage) (float64, bool) {
+	pricing, ok := LookupModelPricing(provider, modelName)
+	if !ok {
+		return 0, false
+	}
+	return pricing.Cost(usage), true
+}
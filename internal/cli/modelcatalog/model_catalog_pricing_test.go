@@ -0,0 +1,39 @@
+package modelcatalog
+
+import (
+	"math"
+	"testing"
+
+	"github.com/OnslaughtSnail/caelis/kernel/model"
+)
+
+func TestLookupModelPricing_FromEmbeddedSnapshot(t *testing.T) {
+	pricing, ok := LookupModelPricing("anthropic", "claude-sonnet-4-5-20250929")
+	if !ok {
+		t.Fatal("expected claude-sonnet-4-5 pricing in the embedded snapshot")
+	}
+	if pricing.Input <= 0 || pricing.Output <= pricing.Input || pricing.CacheRead <= 0 {
+		t.Fatalf("unexpected pricing %+v", pricing)
+	}
+	if _, ok := LookupModelPricing("anthropic", "no-such-model"); ok {
+		t.Fatal("expected unknown model to have no pricing")
+	}
+}
+
+func TestModelPricing_CostSplitsCachedInput(t *testing.T) {
+	pricing := ModelPricing{Input: 3, Output: 15, CacheRead: 0.3, CacheWrite: 3.75}
+	got := pricing.Cost(model.Usage{
+		PromptTokens:     1_000_000,
+		CacheReadTokens:  500_000,
+		CacheWriteTokens: 100_000,
+		CompletionTokens: 200_000,
+	})
+	want := 0.4*3 + 0.5*0.3 + 0.1*3.75 + 0.2*15
+	if math.Abs(got-want) > 1e-9 {
+		t.Fatalf("cost = %v, want %v", got, want)
+	}
+	// Missing cache prices fall back to the input price.
+	if got := (ModelPricing{Input: 2}).Cost(model.Usage{PromptTokens: 1_000_000, CacheReadTokens: 1_000_000}); math.Abs(got-2) > 1e-9 {
+		t.Fatalf("cost = %v, want 2", got)
+	}
+}
@@ -69,6 +69,8 @@ type capEntry struct {
 	DefaultReasoningEffort string   `json:"default_reasoning_effort,omitempty"`
 	Images                 bool     `json:"images"`
 	JSONOutput             bool     `json:"json_output"`
+	// Cost is the list price in USD per million tokens, when known.
+	Cost *ModelPricing `json:"cost,omitempty"`
 }
 
 // capSnapshot is the in-memory representation: map["provider:model"] → capEntry.
@@ -194,9 +196,18 @@ func LookupDynamicModelCapabilities(provider, modelName string) (ModelCapabiliti
 // searchCapSnapshot performs a longest-prefix match inside a capSnapshot.
 // Keys in the snapshot are "provider:model_prefix" (lower-case).
 func searchCapSnapshot(snap capSnapshot, provider, modelName string) (ModelCapabilities, bool) {
-	if len(snap) == 0 {
+	entry, ok := searchCapEntry(snap, provider, modelName)
+	if !ok {
 		return ModelCapabilities{}, false
 	}
+	return entryToCaps(entry), true
+}
+
+// searchCapEntry returns the raw entry behind searchCapSnapshot.
+func searchCapEntry(snap capSnapshot, provider, modelName string) (capEntry, bool) {
+	if len(snap) == 0 {
+		return capEntry{}, false
+	}
 	var bestKey string
 	bestLen := 0
 
@@ -217,9 +228,9 @@ func searchCapSnapshot(snap capSnapshot, provider, modelName string) (ModelCapab
 		}
 	}
 	if bestKey == "" {
-		return ModelCapabilities{}, false
+		return capEntry{}, false
 	}
-	return entryToCaps(snap[bestKey]), true
+	return snap[bestKey], true
 }
 
 // splitCatalogKey splits "provider:model" → ("provider", "model", true).
@@ -315,6 +326,7 @@ type modelsDevModel struct {
 	Attachment       bool                `json:"attachment"` // image input
 	StructuredOutput bool                `json:"structured_output"`
 	Limit            modelsDevModelLimit `json:"limit"`
+	Cost             *ModelPricing       `json:"cost"`
 }
 
 // modelsDevModelLimit holds token limits from models.dev.
@@ -404,6 +416,7 @@ func parseModelsDevJSON(data []byte) (capSnapshot, error) {
 				Reasoning:  m.Reasoning,
 				Images:     m.Attachment,
 				JSONOutput: m.StructuredOutput,
+				Cost:       m.Cost,
 			}
 			insertCapEntry(snap, internalProvider+":"+strings.ToLower(modelID), entry)
 			if derivedProvider, derivedModel, ok := splitVendorModelID(modelID); ok {
@@ -436,6 +449,9 @@ func mergeCapEntry(existing, next capEntry) capEntry {
 	existing.Reasoning = existing.Reasoning || next.Reasoning
 	existing.Images = existing.Images || next.Images
 	existing.JSONOutput = existing.JSONOutput || next.JSONOutput
+	if existing.Cost == nil {
+		existing.Cost = next.Cost
+	}
 	return existing
 }
 
@@ -214,7 +214,7 @@ func parseMutationSummaryLine(line string) (string, string, bool) {
 		return "", "", false
 	}
 	toolName := strings.ToUpper(strings.TrimSpace(parts[0]))
-	if toolName != "PATCH" && toolName != "WRITE" {
+	if !isMutationToolName(toolName) {
 		return "", "", false
 	}
 	if !strings.HasPrefix(parts[1], "+") || !strings.HasPrefix(parts[2], "-") {
@@ -69,7 +69,7 @@ func extractToolCallName(line string) (string, bool) {
 
 func isMutationToolName(toolName string) bool {
 	switch strings.ToUpper(strings.TrimSpace(toolName)) {
-	case "PATCH", "WRITE":
+	case "PATCH", "WRITE", "EDIT":
 		return true
 	default:
 		return false
@@ -263,8 +263,17 @@ func colorizeToolLine(line string, theme Theme) string {
 	return theme.ReasoningStyle().Render(line)
 }
 
+func isMutationToolName(toolName string) bool {
+	switch strings.ToUpper(strings.TrimSpace(toolName)) {
+	case "PATCH", "WRITE", "EDIT":
+		return true
+	default:
+		return false
+	}
+}
+
 func renderToolCallSuffix(toolName string, suffix string, theme Theme) string {
-	if !strings.EqualFold(toolName, "PATCH") && !strings.EqualFold(toolName, "WRITE") {
+	if !isMutationToolName(toolName) {
 		return lipgloss.NewStyle().Foreground(theme.ReasoningFg).Render(LinkifyText(suffix, theme.LinkStyle()))
 	}
 	fields := strings.Fields(strings.TrimSpace(suffix))
@@ -287,7 +296,7 @@ func renderToolCallSuffix(toolName string, suffix string, theme Theme) string {
 }
 
 func renderToolResultSuffix(toolName string, suffix string, theme Theme) string {
-	if !strings.EqualFold(toolName, "PATCH") && !strings.EqualFold(toolName, "WRITE") {
+	if !isMutationToolName(toolName) {
 		return LinkifyText(suffix, theme.LinkStyle())
 	}
 	fields := strings.Fields(strings.TrimSpace(suffix))
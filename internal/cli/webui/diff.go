@@ -0,0 +1,236 @@
+package webui
+
+import (
+	"strings"
+
+	"github.com/OnslaughtSnail/caelis/internal/cli/tuidiff"
+	toolexec "github.com/OnslaughtSnail/caelis/kernel/execenv"
+	"github.com/OnslaughtSnail/caelis/kernel/model"
+	"github.com/OnslaughtSnail/caelis/kernel/session"
+	toolfs "github.com/OnslaughtSnail/caelis/kernel/tool/builtin/filesystem"
+)
+
+// maxDiffRows mirrors the console's rich diff cutoff; larger changes are sent
+// with counts only.
+const maxDiffRows = 800
+
+// Diff sources reported to the browser. Workspace diffs are computed against
+// the files on disk and carry real line numbers; argument diffs are rebuilt
+// from the tool call alone, for calls whose change has already been applied.
+const (
+	diffSourceWorkspace = "workspace"
+	diffSourceArguments = "arguments"
+)
+
+type diffJSON struct {
+	ToolCallID string        `json:"tool_call_id"`
+	Tool       string        `json:"tool"`
+	Path       string        `json:"path"`
+	Created    bool          `json:"created,omitempty"`
+	Hunk       string        `json:"hunk,omitempty"`
+	Source     string        `json:"source"`
+	Added      int           `json:"added"`
+	Removed    int           `json:"removed"`
+	TooLarge   bool          `json:"too_large,omitempty"`
+	Rows       []diffRowJSON `json:"rows,omitempty"`
+}
+
+type diffRowJSON struct {
+	Kind       string     `json:"kind"`
+	OldLine    int        `json:"old_line,omitempty"`
+	NewLine    int        `json:"new_line,omitempty"`
+	OldLineEnd int        `json:"old_line_end,omitempty"`
+	NewLineEnd int        `json:"new_line_end,omitempty"`
+	Old        []spanJSON `json:"old,omitempty"`
+	New        []spanJSON `json:"new,omitempty"`
+}
+
+type spanJSON struct {
+	Text string `json:"text"`
+	Kind string `json:"kind,omitempty"`
+}
+
+// DiffDecorator returns an httpapi event decorator that attaches structured
+// diffs to assistant tool calls that mutate files, built with the same
+// tuidiff model the terminal console renders.
+func DiffDecorator(runtime toolexec.Runtime) func(*session.Event) map[string]any {
+	return func(ev *session.Event) map[string]any {
+		if ev == nil || ev.Message.Role != model.RoleAssistant || session.IsPartial(ev) {
+			return nil
+		}
+		var diffs []diffJSON
+		for _, call := range ev.Message.ToolCalls() {
+			diffs = append(diffs, buildCallDiffs(runtime, call)...)
+		}
+		if len(diffs) == 0 {
+			return nil
+		}
+		return map[string]any{"diffs": diffs}
+	}
+}
+
+func buildCallDiffs(runtime toolexec.Runtime, call model.ToolCall) []diffJSON {
+	toolName := strings.ToUpper(strings.TrimSpace(call.Name))
+	switch toolName {
+	case toolfs.PatchToolName, toolfs.WriteToolName, toolfs.EditToolName:
+	default:
+		return nil
+	}
+	args, err := model.ParseToolCallArgs(call.Args)
+	if err != nil {
+		return nil
+	}
+	if toolName == toolfs.EditToolName {
+		previews, source := editPreviews(runtime, args)
+		out := make([]diffJSON, 0, len(previews))
+		for _, preview := range previews {
+			if diff, ok := diffFromPreview(call.ID, toolName, preview, source); ok {
+				out = append(out, diff)
+			}
+		}
+		return out
+	}
+	source := diffSourceWorkspace
+	preview, err := toolfs.BuildMutationPreview(runtime, toolName, args)
+	if err != nil || preview.Old == preview.New {
+		// The change is already on disk (history) or no longer applies, so
+		// fall back to what the call itself asked for.
+		preview, source = previewFromArgs(toolName, args), diffSourceArguments
+	}
+	if diff, ok := diffFromPreview(call.ID, toolName, preview, source); ok {
+		return []diffJSON{diff}
+	}
+	return nil
+}
+
+// editPreviews returns one preview per EDIT target. When the edit no longer
+// applies, each listed replacement is shown on its own; unified diff input
+// has no such fallback.
+func editPreviews(runtime toolexec.Runtime, args map[string]any) ([]toolfs.MutationPreview, string) {
+	if preview, err := toolfs.BuildMutationPreview(runtime, toolfs.EditToolName, args); err == nil {
+		for _, file := range preview.Files {
+			if file.Old != file.New {
+				return preview.Files, diffSourceWorkspace
+			}
+		}
+	}
+	edits, _ := args["edits"].([]any)
+	out := make([]toolfs.MutationPreview, 0, len(edits))
+	for _, raw := range edits {
+		edit, _ := raw.(map[string]any)
+		preview := previewFromArgs(toolfs.PatchToolName, edit)
+		preview.Tool = toolfs.EditToolName
+		out = append(out, preview)
+	}
+	return out, diffSourceArguments
+}
+
+func diffFromPreview(callID, toolName string, preview toolfs.MutationPreview, source string) (diffJSON, bool) {
+	if strings.TrimSpace(preview.Path) == "" && preview.Old == preview.New {
+		return diffJSON{}, false
+	}
+	stats := toolfs.CountLineDiff(preview.Old, preview.New)
+	out := diffJSON{
+		ToolCallID: strings.TrimSpace(callID),
+		Tool:       toolName,
+		Path:       preview.Path,
+		Created:    preview.Created,
+		Hunk:       strings.TrimSpace(preview.Hunk),
+		Source:     source,
+		Added:      stats.Added,
+		Removed:    stats.Removed,
+	}
+	if stats.Added+stats.Removed > maxDiffRows {
+		out.TooLarge = true
+		return out, true
+	}
+	diffModel := tuidiff.BuildModel(tuidiff.Payload{
+		Tool:    toolName,
+		Path:    preview.Path,
+		Created: preview.Created,
+		Hunk:    preview.Hunk,
+		Old:     preview.Old,
+		New:     preview.New,
+	})
+	out.Rows = rowsFromModel(diffModel.Rows)
+	return out, true
+}
+
+func previewFromArgs(toolName string, args map[string]any) toolfs.MutationPreview {
+	preview := toolfs.MutationPreview{Tool: toolName, Path: stringArg(args, "path")}
+	switch toolName {
+	case toolfs.PatchToolName:
+		preview.Old = stringArg(args, "old")
+		preview.New = stringArg(args, "new")
+		preview.Created = preview.Old == ""
+	case toolfs.WriteToolName:
+		preview.New = stringArg(args, "content")
+	}
+	return preview
+}
+
+func stringArg(args map[string]any, key string) string {
+	value, _ := args[key].(string)
+	return value
+}
+
+func rowsFromModel(rows []tuidiff.Row) []diffRowJSON {
+	out := make([]diffRowJSON, 0, len(rows))
+	for _, row := range rows {
+		item := diffRowJSON{
+			Kind:       rowKindName(row.Kind),
+			OldLine:    row.OldLineNo,
+			NewLine:    row.NewLineNo,
+			OldLineEnd: row.OldLineEnd,
+			NewLineEnd: row.NewLineEnd,
+			Old:        spansFromRow(row.OldSpans, row.OldText, row.Kind == tuidiff.RowRemove),
+			New:        spansFromRow(row.NewSpans, row.NewText, row.Kind == tuidiff.RowAdd),
+		}
+		out = append(out, item)
+	}
+	return out
+}
+
+func spansFromRow(spans []tuidiff.InlineSpan, text string, changed bool) []spanJSON {
+	if len(spans) == 0 {
+		if text == "" {
+			return nil
+		}
+		span := spanJSON{Text: text}
+		if changed {
+			span.Kind = "changed"
+		}
+		return []spanJSON{span}
+	}
+	out := make([]spanJSON, 0, len(spans))
+	for _, span := range spans {
+		out = append(out, spanJSON{Text: span.Text, Kind: inlineKindName(span.Kind)})
+	}
+	return out
+}
+
+func rowKindName(kind tuidiff.RowKind) string {
+	switch kind {
+	case tuidiff.RowAdd:
+		return "add"
+	case tuidiff.RowRemove:
+		return "remove"
+	case tuidiff.RowModified:
+		return "modified"
+	case tuidiff.RowFold:
+		return "fold"
+	default:
+		return "context"
+	}
+}
+
+func inlineKindName(kind tuidiff.InlineKind) string {
+	switch kind {
+	case tuidiff.InlineAdd:
+		return "add"
+	case tuidiff.InlineRemove:
+		return "remove"
+	default:
+		return ""
+	}
+}
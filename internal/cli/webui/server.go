@@ -0,0 +1,177 @@
+// Package webui serves the browser console: an embedded single-page app on top
+// of the httpapi endpoints plus a workspace session listing backed by the local
+// session catalog.
+package webui
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io/fs"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/OnslaughtSnail/caelis/internal/app/httpapi"
+	"github.com/OnslaughtSnail/caelis/internal/app/storage/localstore"
+)
+
+//go:embed static
+var staticFiles embed.FS
+
+const defaultPageSize = 30
+
+// SessionLister lists the sessions of one workspace, newest first.
+// *localstore.ScopeStore satisfies it.
+type SessionLister interface {
+	ListSessionsPage(ctx context.Context, page, pageSize int) ([]localstore.SessionSummary, error)
+}
+
+// Config wires the browser console.
+type Config struct {
+	// API serves /v1/ requests, normally an *httpapi.Server.
+	API http.Handler
+	// Sessions backs GET /v1/workspace/sessions.
+	Sessions SessionLister
+	// AuthToken guards the workspace listing the same way httpapi guards its
+	// own routes. Static assets are served without it. New generates a
+	// per-launch token when it is empty.
+	AuthToken string
+	// EmbedToken puts AuthToken in the served page so the console can call
+	// the API without asking for it. Anyone who can load the page can read
+	// it, so set it only for a token generated for this launch. It is
+	// implied when New generates the token.
+	EmbedToken bool
+	// Addr is the listen address every request's Host and Origin headers
+	// are checked against; see httpapi.RequireSameOrigin.
+	Addr         string
+	WorkspaceCWD string
+}
+
+type workspaceSessionJSON struct {
+	SessionID       string    `json:"session_id"`
+	CreatedAt       time.Time `json:"created_at,omitzero"`
+	LastEventAt     time.Time `json:"last_event_at,omitzero"`
+	EventCount      int64     `json:"event_count"`
+	LastUserMessage string    `json:"last_user_message,omitempty"`
+}
+
+type workspaceSessionsJSON struct {
+	Workspace string                 `json:"workspace,omitempty"`
+	Sessions  []workspaceSessionJSON `json:"sessions"`
+	Page      int                    `json:"page"`
+	HasMore   bool                   `json:"has_more"`
+}
+
+// New returns the handler serving the console at / and the API under /v1/.
+func New(cfg Config) (http.Handler, error) {
+	if cfg.API == nil {
+		return nil, fmt.Errorf("webui: api handler is required")
+	}
+	if cfg.Sessions == nil {
+		return nil, fmt.Errorf("webui: session lister is required")
+	}
+	if cfg.AuthToken == "" {
+		token, err := httpapi.NewAuthToken()
+		if err != nil {
+			return nil, err
+		}
+		cfg.AuthToken, cfg.EmbedToken = token, true
+	}
+	assets, err := fs.Sub(staticFiles, "static")
+	if err != nil {
+		return nil, err
+	}
+	index, err := fs.ReadFile(assets, "index.html")
+	if err != nil {
+		return nil, err
+	}
+	if cfg.EmbedToken {
+		index = []byte(strings.Replace(string(index), `<meta charset="utf-8">`,
+			`<meta charset="utf-8">`+"\n  "+`<meta name="caelis-token" content="`+html.EscapeString(cfg.AuthToken)+`">`, 1))
+	}
+	mux := http.NewServeMux()
+	mux.Handle("GET /v1/workspace/sessions", httpapi.RequireBearer(cfg.AuthToken, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		listWorkspaceSessions(w, r, cfg)
+	})))
+	mux.Handle("/v1/", cfg.API)
+	mux.Handle("GET /{$}", withAssetHeaders(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The page carries a per-launch token, so it is never stored.
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write(index)
+	})))
+	mux.Handle("/", withAssetHeaders(http.FileServerFS(assets)))
+	return httpapi.RequireSameOrigin(cfg.Addr, mux), nil
+}
+
+func listWorkspaceSessions(w http.ResponseWriter, r *http.Request, cfg Config) {
+	page, err := queryInt(r, "page", 1)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	pageSize, err := queryInt(r, "page_size", defaultPageSize)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	page = max(page, 1)
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	items, err := cfg.Sessions.ListSessionsPage(r.Context(), page, pageSize)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	out := workspaceSessionsJSON{
+		Workspace: cfg.WorkspaceCWD,
+		Sessions:  make([]workspaceSessionJSON, 0, len(items)),
+		Page:      page,
+		// A full page may be followed by an empty one; the console simply
+		// stops offering more when that happens.
+		HasMore: len(items) == pageSize,
+	}
+	for _, item := range items {
+		out.Sessions = append(out.Sessions, workspaceSessionJSON{
+			SessionID:       item.SessionID,
+			CreatedAt:       item.CreatedAt,
+			LastEventAt:     item.LastEventAt,
+			EventCount:      item.EventCount,
+			LastUserMessage: strings.TrimSpace(item.LastUserMessage),
+		})
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// withAssetHeaders keeps browsers from caching a console built into an older
+// binary.
+func withAssetHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		next.ServeHTTP(w, r)
+	})
+}
+
+func queryInt(r *http.Request, key string, fallback int) (int, error) {
+	raw := strings.TrimSpace(r.URL.Query().Get(key))
+	if raw == "" {
+		return fallback, nil
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid %s %q", key, raw)
+	}
+	return value, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, payload any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(payload)
+}
@@ -0,0 +1,236 @@
+package webui
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OnslaughtSnail/caelis/internal/app/storage/localstore"
+	toolexec "github.com/OnslaughtSnail/caelis/kernel/execenv"
+	"github.com/OnslaughtSnail/caelis/kernel/model"
+	"github.com/OnslaughtSnail/caelis/kernel/session"
+)
+
+type fakeLister struct {
+	items    []localstore.SessionSummary
+	gotPage  int
+	gotLimit int
+}
+
+func (f *fakeLister) ListSessionsPage(_ context.Context, page, pageSize int) ([]localstore.SessionSummary, error) {
+	f.gotPage, f.gotLimit = page, pageSize
+	return f.items, nil
+}
+
+type noopExecRunner struct{}
+
+func (noopExecRunner) Run(context.Context, toolexec.CommandRequest) (toolexec.CommandResult, error) {
+	return toolexec.CommandResult{}, nil
+}
+
+func TestHandler_ServesConsoleAndGuardsWorkspaceSessions(t *testing.T) {
+	lister := &fakeLister{items: []localstore.SessionSummary{{
+		SessionID:       "s-1",
+		LastEventAt:     time.Unix(1700000000, 0),
+		EventCount:      4,
+		LastUserMessage: "  fix the build ",
+	}}}
+	api := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-From", "api")
+		w.WriteHeader(http.StatusTeapot)
+	})
+	handler, err := New(Config{API: api, Sessions: lister, AuthToken: "secret", WorkspaceCWD: "/work"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), `<script src="app.js">`) {
+		t.Fatalf("expected embedded console at /, got %d %q", resp.StatusCode, body)
+	}
+	if resp, err := http.Get(ts.URL + "/app.js"); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected app.js to be served, got %v %v", resp, err)
+	}
+
+	resp, err = http.Get(ts.URL + "/v1/workspace/sessions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected workspace listing to require the token, got %d", resp.StatusCode)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/v1/workspace/sessions?page=2&page_size=1", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var listed workspaceSessionsJSON
+	if err := json.NewDecoder(resp.Body).Decode(&listed); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if lister.gotPage != 2 || lister.gotLimit != 1 {
+		t.Fatalf("expected page 2 size 1 to reach the store, got %d/%d", lister.gotPage, lister.gotLimit)
+	}
+	if len(listed.Sessions) != 1 || listed.Sessions[0].LastUserMessage != "fix the build" || !listed.HasMore || listed.Workspace != "/work" {
+		t.Fatalf("unexpected listing %+v", listed)
+	}
+
+	resp, err = http.Get(ts.URL + "/v1/health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTeapot || resp.Header.Get("X-From") != "api" {
+		t.Fatalf("expected /v1 routes to reach the api handler, got %d", resp.StatusCode)
+	}
+}
+
+func TestHandler_EmbedsLaunchTokenAndChecksOrigin(t *testing.T) {
+	api := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	ts := httptest.NewUnstartedServer(nil)
+	handler, err := New(Config{API: api, Sessions: &fakeLister{}, Addr: ts.Listener.Addr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.Config.Handler = handler
+	ts.Start()
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	_, rest, ok := strings.Cut(string(body), `<meta name="caelis-token" content="`)
+	token, _, _ := strings.Cut(rest, `"`)
+	if !ok || len(token) < 32 || resp.Header.Get("Cache-Control") != "no-store" {
+		t.Fatalf("expected a generated token in an uncached page, got %q (%q)", body, resp.Header.Get("Cache-Control"))
+	}
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/v1/workspace/sessions", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	if resp, err := http.DefaultClient.Do(req); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected the embedded token to work, got %v (%v)", resp, err)
+	}
+
+	for _, header := range []string{"Origin", "Host"} {
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+"/v1/sessions/s-1/interrupt", nil)
+		if header == "Origin" {
+			req.Header.Set("Origin", "http://evil.example")
+		} else {
+			req.Host = "evil.example"
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusForbidden {
+			t.Fatalf("expected a foreign %s to be refused, got %d", header, resp.StatusCode)
+		}
+	}
+	req, _ = http.NewRequest(http.MethodPost, ts.URL+"/v1/sessions/s-1/interrupt", nil)
+	req.Header.Set("Origin", ts.URL)
+	if resp, err := http.DefaultClient.Do(req); err != nil || resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected a same-origin request to reach the api, got %v (%v)", resp, err)
+	}
+}
+
+func TestDiffDecorator_UsesWorkspaceThenFallsBackToArguments(t *testing.T) {
+	execRT, err := toolexec.New(toolexec.Config{
+		PermissionMode: toolexec.PermissionModeFullControl,
+		SandboxRunner:  noopExecRunner{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer toolexec.Close(execRT)
+	path := filepath.Join(t.TempDir(), "main.go")
+	if err := os.WriteFile(path, []byte("package main\n\nfunc main() {\n\tprintln(\"hi\")\n}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	args, _ := json.Marshal(map[string]any{"path": path, "old": `println("hi")`, "new": `println("hello")`})
+	ev := &session.Event{Message: model.MessageFromToolCalls(model.RoleAssistant, []model.ToolCall{
+		{ID: "call-1", Name: "PATCH", Args: string(args)},
+		{ID: "call-2", Name: "READ", Args: `{"path":"main.go"}`},
+	}, "")}
+	decorate := DiffDecorator(execRT)
+
+	diffs := decoratedDiffs(t, decorate(ev))
+	if len(diffs) != 1 {
+		t.Fatalf("expected one diff for the PATCH call, got %+v", diffs)
+	}
+	live := diffs[0]
+	if live.ToolCallID != "call-1" || live.Source != diffSourceWorkspace || live.Added != 1 || live.Removed != 1 {
+		t.Fatalf("unexpected workspace diff %+v", live)
+	}
+	modified := findRow(live.Rows, "modified")
+	if modified == nil || modified.OldLine != 4 || !hasSpan(modified.New, "add") {
+		t.Fatalf("expected an inline-highlighted modified row on line 4, got %+v", live.Rows)
+	}
+
+	// Once applied, the old text is gone; the call arguments still describe
+	// the change.
+	if err := os.WriteFile(path, []byte("package main\n\nfunc main() {\n\tprintln(\"hello\")\n}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	applied := decoratedDiffs(t, decorate(ev))
+	if len(applied) != 1 || applied[0].Source != diffSourceArguments || findRow(applied[0].Rows, "modified") == nil {
+		t.Fatalf("expected argument fallback diff, got %+v", applied)
+	}
+
+	if extras := decorate(&session.Event{Message: model.NewTextMessage(model.RoleAssistant, "done")}); extras != nil {
+		t.Fatalf("expected no extras for plain text, got %+v", extras)
+	}
+}
+
+func decoratedDiffs(t *testing.T, extras map[string]any) []diffJSON {
+	t.Helper()
+	raw, err := json.Marshal(extras["diffs"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []diffJSON
+	if err := json.Unmarshal(raw, &out); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func findRow(rows []diffRowJSON, kind string) *diffRowJSON {
+	for i := range rows {
+		if rows[i].Kind == kind {
+			return &rows[i]
+		}
+	}
+	return nil
+}
+
+func hasSpan(spans []spanJSON, kind string) bool {
+	for _, span := range spans {
+		if span.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
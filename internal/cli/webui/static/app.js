@@ -0,0 +1,451 @@
+"use strict";
+
+// Browser console for `caelis web`. Talks to the same /v1 endpoints as
+// `caelis api`; turns are streamed over fetch so the bearer token can be sent.
+
+const TOKEN_KEY = "caelis.web.token";
+// A token generated for this launch is embedded in the page by the server.
+const PAGE_TOKEN = document.querySelector('meta[name="caelis-token"]')?.content || "";
+const RESULT_PREVIEW_CHARS = 4000;
+
+const state = {
+  sessionID: "",
+  page: 1,
+  running: false,
+  tools: new Map(),
+  streaming: { answer: null, reasoning: null },
+};
+
+const $ = (id) => document.getElementById(id);
+
+function el(tag, className, text) {
+  const node = document.createElement(tag);
+  if (className) node.className = className;
+  if (text !== undefined) node.textContent = text;
+  return node;
+}
+
+async function api(method, path, body, retried) {
+  const headers = { "Content-Type": "application/json" };
+  const token = PAGE_TOKEN || localStorage.getItem(TOKEN_KEY);
+  if (token) headers.Authorization = "Bearer " + token;
+  const resp = await fetch(path, {
+    method,
+    headers,
+    body: body === undefined ? undefined : JSON.stringify(body),
+  });
+  if (resp.status === 401 && !retried && !PAGE_TOKEN) {
+    const entered = window.prompt("This caelis server requires an access token:");
+    if (entered) {
+      localStorage.setItem(TOKEN_KEY, entered.trim());
+      return api(method, path, body, true);
+    }
+  }
+  return resp;
+}
+
+async function apiJSON(method, path, body) {
+  const resp = await api(method, path, body);
+  if (resp.status === 204) return null;
+  const payload = await resp.json().catch(() => ({}));
+  if (!resp.ok) throw new Error(payload.error || resp.statusText);
+  return payload;
+}
+
+function setStatus(text) {
+  $("status").textContent = text || "";
+}
+
+function setRunning(running) {
+  state.running = running;
+  $("interrupt").hidden = !running;
+  $("send").disabled = running;
+  setStatus(running ? "running…" : "");
+}
+
+function relativeTime(value) {
+  if (!value) return "";
+  const seconds = Math.max(0, (Date.now() - new Date(value).getTime()) / 1000);
+  if (seconds < 60) return "just now";
+  if (seconds < 3600) return Math.floor(seconds / 60) + "m ago";
+  if (seconds < 86400) return Math.floor(seconds / 3600) + "h ago";
+  return Math.floor(seconds / 86400) + "d ago";
+}
+
+// ---- sessions ----
+
+async function loadSessions(append) {
+  if (!append) state.page = 1;
+  const data = await apiJSON("GET", "/v1/workspace/sessions?page=" + state.page);
+  $("workspace").textContent = data.workspace || "";
+  const list = $("sessions");
+  if (!append) list.replaceChildren();
+  for (const item of data.sessions) {
+    const li = el("li");
+    li.dataset.sessionId = item.session_id;
+    li.classList.toggle("active", item.session_id === state.sessionID);
+    li.append(
+      el("span", "preview", item.last_user_message || item.session_id),
+      el("span", "meta", relativeTime(item.last_event_at) + " · " + item.event_count + " events"),
+    );
+    li.addEventListener("click", () => openSession(item.session_id));
+    list.append(li);
+  }
+  $("more-sessions").hidden = !data.has_more;
+}
+
+async function newSession() {
+  const created = await apiJSON("POST", "/v1/sessions", {});
+  await openSession(created.session_id);
+  await loadSessions(false);
+}
+
+async function openSession(sessionID) {
+  state.sessionID = sessionID;
+  state.tools.clear();
+  state.streaming = { answer: null, reasoning: null };
+  for (const li of $("sessions").children) {
+    li.classList.toggle("active", li.dataset.sessionId === sessionID);
+  }
+  $("session-title").textContent = sessionID;
+  $("transcript").replaceChildren();
+  $("approvals").replaceChildren();
+  setRunning(false);
+  const loaded = await apiJSON("GET", "/v1/sessions/" + encodeURIComponent(sessionID) + "?limit=500");
+  for (const ev of loaded.events || []) renderEvent(ev);
+  const pending = await apiJSON("GET", "/v1/approvals?session_id=" + encodeURIComponent(sessionID));
+  for (const item of pending.approvals || []) showApproval(item);
+  scrollToEnd();
+}
+
+// ---- transcript ----
+
+function scrollToEnd() {
+  const transcript = $("transcript");
+  transcript.scrollTop = transcript.scrollHeight;
+}
+
+function appendMessage(kind, text) {
+  const wrap = el("div", "msg " + kind);
+  wrap.append(el("div", "body", text));
+  $("transcript").append(wrap);
+  return wrap;
+}
+
+function streamingNode(channel) {
+  if (!state.streaming[channel]) {
+    state.streaming[channel] = appendMessage(channel === "reasoning" ? "reasoning" : "assistant", "");
+  }
+  return state.streaming[channel].querySelector(".body");
+}
+
+function clearStreaming() {
+  for (const channel of ["answer", "reasoning"]) {
+    if (state.streaming[channel]) state.streaming[channel].remove();
+    state.streaming[channel] = null;
+  }
+}
+
+function partText(part) {
+  if (part.kind === "text" && part.text) return part.text.text || "";
+  if (part.kind === "json" && part.json) return JSON.stringify(part.json.value, null, 2);
+  return "";
+}
+
+function renderEvent(ev) {
+  const msg = ev.message || {};
+  if (!msg.role || msg.role === "system") return;
+  if (ev.partial) {
+    const text = (msg.parts || []).map(partText).join("");
+    if (text) {
+      streamingNode(ev.channel === "reasoning" ? "reasoning" : "answer").textContent += text;
+    }
+    return;
+  }
+  if (msg.role === "assistant") clearStreaming();
+  // EDIT calls may carry one diff per file, so group by tool call.
+  const diffs = new Map();
+  for (const d of (ev.extras && ev.extras.diffs) || []) {
+    if (!diffs.has(d.tool_call_id)) diffs.set(d.tool_call_id, []);
+    diffs.get(d.tool_call_id).push(d);
+  }
+  for (const part of msg.parts || []) {
+    switch (part.kind) {
+      case "text":
+        if (part.text && part.text.text && part.text.text.trim()) {
+          appendMessage(msg.role === "user" ? "user" : "assistant", part.text.text);
+        }
+        break;
+      case "reasoning":
+        if (part.reasoning && part.reasoning.visible_text) {
+          appendMessage("reasoning", part.reasoning.visible_text);
+        }
+        break;
+      case "tool_use":
+        renderToolCall(part.tool_use, diffs.get(part.tool_use.id));
+        break;
+      case "tool_result":
+        renderToolResult(part.tool_result);
+        break;
+      case "media":
+        appendMessage("notice", "[attachment]");
+        break;
+    }
+  }
+}
+
+function toolArgs(use) {
+  if (!use.input) return {};
+  if (typeof use.input === "object") return use.input;
+  try {
+    return JSON.parse(use.input);
+  } catch {
+    return {};
+  }
+}
+
+function toolSummary(name, args) {
+  if (args.path) return args.path;
+  if (Array.isArray(args.edits) && args.edits.length > 0) {
+    const paths = [...new Set(args.edits.map((e) => e.path))];
+    return paths.length > 1 ? paths[0] + " (+" + (paths.length - 1) + " files)" : paths[0];
+  }
+  if (args.command) return args.command;
+  if (args.pattern) return args.pattern;
+  const compact = JSON.stringify(args);
+  return compact === "{}" ? "" : compact.slice(0, 160);
+}
+
+function renderToolCall(use, diffs) {
+  const args = toolArgs(use);
+  const details = el("details", "tool");
+  const summary = el("summary");
+  summary.append(el("strong", "", use.name || "TOOL"), " ", toolSummary(use.name, args));
+  if (diffs && diffs.length > 0) {
+    const added = diffs.reduce((sum, d) => sum + d.added, 0);
+    const removed = diffs.reduce((sum, d) => sum + d.removed, 0);
+    const counts = el("span", "counts");
+    counts.append(" ", el("span", "add", "+" + added), " ", el("span", "remove", "-" + removed));
+    summary.append(counts);
+    details.open = true;
+  }
+  details.append(summary);
+  if (diffs && diffs.length > 0) {
+    for (const diff of diffs) {
+      if (diffs.length > 1) details.append(el("div", "diff-file", diff.path));
+      details.append(renderDiff(diff));
+    }
+  } else if (Object.keys(args).length > 0) {
+    details.append(el("pre", "args", JSON.stringify(args, null, 2)));
+  }
+  $("transcript").append(details);
+  if (use.id) state.tools.set(use.id, details);
+}
+
+function renderToolResult(result) {
+  const text = (result.content || []).map(partText).join("\n");
+  const node = el("pre", "result" + (result.is_error ? " error" : ""));
+  node.textContent = text.length > RESULT_PREVIEW_CHARS ? text.slice(0, RESULT_PREVIEW_CHARS) + "\n…" : text;
+  const owner = state.tools.get(result.tool_use_id);
+  if (owner) {
+    owner.append(node);
+    if (result.is_error) owner.open = true;
+    return;
+  }
+  const orphan = el("details", "tool");
+  orphan.append(el("summary", "", (result.name || "TOOL") + " result"), node);
+  $("transcript").append(orphan);
+}
+
+// renderDiff draws the rows of a tuidiff model as a unified diff.
+function renderDiff(diff) {
+  const wrap = el("div");
+  if (diff.too_large) {
+    wrap.append(el("div", "diff-note", "Diff too large to display."));
+    return wrap;
+  }
+  if (diff.source === "arguments") {
+    wrap.append(el("div", "diff-note", "Showing the requested change; line numbers are relative."));
+  }
+  const table = el("table", "diff");
+  for (const row of diff.rows || []) {
+    if (row.kind === "fold") {
+      const tr = el("tr", "fold");
+      const td = el("td", "", foldLabel(row));
+      td.colSpan = 3;
+      tr.append(td);
+      table.append(tr);
+      continue;
+    }
+    if (row.kind === "modified") {
+      table.append(diffLine("remove", "-", row.old_line, row.old));
+      table.append(diffLine("add", "+", row.new_line, row.new));
+      continue;
+    }
+    if (row.kind === "remove") {
+      table.append(diffLine("remove", "-", row.old_line, row.old));
+      continue;
+    }
+    if (row.kind === "add") {
+      table.append(diffLine("add", "+", row.new_line, row.new));
+      continue;
+    }
+    table.append(diffLine("context", " ", row.new_line || row.old_line, row.new || row.old));
+  }
+  wrap.append(table);
+  return wrap;
+}
+
+function foldLabel(row) {
+  const oldCount = row.old_line && row.old_line_end >= row.old_line ? row.old_line_end - row.old_line + 1 : 0;
+  const newCount = row.new_line && row.new_line_end >= row.new_line ? row.new_line_end - row.new_line + 1 : 0;
+  const omitted = Math.max(oldCount, newCount);
+  if (omitted <= 0) return "⋯";
+  return "⋯ " + omitted + (omitted === 1 ? " unchanged line" : " unchanged lines") + " ⋯";
+}
+
+function diffLine(kind, marker, lineNo, spans) {
+  const tr = el("tr", kind);
+  tr.append(el("td", "ln", lineNo ? String(lineNo) : ""), el("td", "marker", marker));
+  const content = el("td");
+  for (const span of spans || []) {
+    content.append(span.kind === "add" || span.kind === "remove" ? el("span", span.kind, span.text) : span.text);
+  }
+  tr.append(content);
+  return tr;
+}
+
+// ---- approvals ----
+
+function showApproval(item) {
+  if (document.querySelector('[data-approval-id="' + item.id + '"]')) return;
+  const node = $("approval-template").content.firstElementChild.cloneNode(true);
+  node.dataset.approvalId = item.id;
+  node.querySelector(".approval-title").textContent =
+    (item.tool_name || "Tool") + " needs approval" + (item.reason ? ": " + item.reason : "");
+  node.querySelector(".approval-detail").textContent =
+    item.command || item.preview || item.path || item.target || item.action || "";
+  for (const button of node.querySelectorAll("button")) {
+    button.addEventListener("click", async () => {
+      for (const other of node.querySelectorAll("button")) other.disabled = true;
+      try {
+        await apiJSON("POST", "/v1/approvals/" + encodeURIComponent(item.id), { decision: button.dataset.decision });
+      } catch (err) {
+        appendMessage("error", "approval failed: " + err.message);
+      }
+      node.remove();
+    });
+  }
+  $("approvals").append(node);
+}
+
+function dismissApproval(id) {
+  const node = document.querySelector('[data-approval-id="' + id + '"]');
+  if (node) node.remove();
+}
+
+// ---- turns ----
+
+async function sendTurn(input) {
+  if (!state.sessionID) await newSession();
+  const sessionID = state.sessionID;
+  appendMessage("user", input);
+  scrollToEnd();
+  setRunning(true);
+  try {
+    const resp = await api("POST", "/v1/sessions/" + encodeURIComponent(sessionID) + "/turns", { input, stream: true });
+    if (!resp.ok) {
+      const payload = await resp.json().catch(() => ({}));
+      throw new Error(payload.error || resp.statusText);
+    }
+    await readEventStream(resp, (name, data) => {
+      if (sessionID !== state.sessionID) return;
+      handleStreamEvent(name, data);
+    });
+  } catch (err) {
+    appendMessage("error", err.message);
+  } finally {
+    if (sessionID === state.sessionID) setRunning(false);
+    loadSessions(false).catch(() => {});
+  }
+}
+
+function handleStreamEvent(name, data) {
+  switch (name) {
+    case "session.event":
+      renderEvent(data);
+      break;
+    case "approval.requested":
+      showApproval(data);
+      break;
+    case "approval.resolved":
+      dismissApproval(data.id);
+      break;
+    case "turn.completed":
+      clearStreaming();
+      if (data.stop_reason === "cancelled") appendMessage("notice", "turn cancelled");
+      break;
+    case "turn.failed":
+      clearStreaming();
+      appendMessage("error", data.error || "turn failed");
+      break;
+  }
+  scrollToEnd();
+}
+
+async function readEventStream(resp, onEvent) {
+  const reader = resp.body.getReader();
+  const decoder = new TextDecoder();
+  let buffer = "";
+  for (;;) {
+    const { value, done } = await reader.read();
+    if (done) break;
+    buffer += decoder.decode(value, { stream: true });
+    let boundary;
+    while ((boundary = buffer.indexOf("\n\n")) >= 0) {
+      const chunk = buffer.slice(0, boundary);
+      buffer = buffer.slice(boundary + 2);
+      let name = "message";
+      const data = [];
+      for (const line of chunk.split("\n")) {
+        if (line.startsWith("event: ")) name = line.slice(7);
+        else if (line.startsWith("data: ")) data.push(line.slice(6));
+      }
+      if (data.length > 0) onEvent(name, JSON.parse(data.join("\n")));
+    }
+  }
+}
+
+// ---- wiring ----
+
+$("composer").addEventListener("submit", (event) => {
+  event.preventDefault();
+  const input = $("input").value.trim();
+  if (!input || state.running) return;
+  $("input").value = "";
+  sendTurn(input);
+});
+
+$("input").addEventListener("keydown", (event) => {
+  if (event.key === "Enter" && !event.shiftKey && !event.isComposing) {
+    event.preventDefault();
+    $("composer").requestSubmit();
+  }
+});
+
+$("interrupt").addEventListener("click", () => {
+  if (!state.sessionID) return;
+  apiJSON("POST", "/v1/sessions/" + encodeURIComponent(state.sessionID) + "/interrupt", { reason: "web interrupt" })
+    .catch((err) => appendMessage("error", err.message));
+});
+
+$("new-session").addEventListener("click", () => {
+  newSession().catch((err) => appendMessage("error", err.message));
+});
+
+$("more-sessions").addEventListener("click", () => {
+  state.page += 1;
+  loadSessions(true).catch((err) => appendMessage("error", err.message));
+});
+
+loadSessions(false).catch((err) => appendMessage("error", err.message));
@@ -0,0 +1,293 @@
+// Package mcpclient connects to Model Context Protocol servers and exposes
+// their tools to the kernel tool registry.
+package mcpclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/OnslaughtSnail/caelis/internal/version"
+)
+
+// ProtocolVersion is the MCP revision announced during initialize.
+const ProtocolVersion = "2025-03-26"
+
+// maxToolPages bounds tools/list pagination against misbehaving servers.
+const maxToolPages = 64
+
+// Implementation identifies one MCP client or server.
+type Implementation struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+// ToolAnnotations are the optional behavior hints a server attaches to tools.
+type ToolAnnotations struct {
+	Title           string `json:"title,omitempty"`
+	ReadOnlyHint    *bool  `json:"readOnlyHint,omitempty"`
+	DestructiveHint *bool  `json:"destructiveHint,omitempty"`
+	IdempotentHint  *bool  `json:"idempotentHint,omitempty"`
+	OpenWorldHint   *bool  `json:"openWorldHint,omitempty"`
+}
+
+// ToolInfo is one entry returned by tools/list.
+type ToolInfo struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	InputSchema map[string]any   `json:"inputSchema,omitempty"`
+	Annotations *ToolAnnotations `json:"annotations,omitempty"`
+}
+
+// Content is one tools/call result content block.
+type Content struct {
+	Type     string         `json:"type"`
+	Text     string         `json:"text,omitempty"`
+	Data     string         `json:"data,omitempty"`
+	MimeType string         `json:"mimeType,omitempty"`
+	URI      string         `json:"uri,omitempty"`
+	Resource map[string]any `json:"resource,omitempty"`
+}
+
+// CallResult is the tools/call response payload.
+type CallResult struct {
+	Content           []Content      `json:"content,omitempty"`
+	StructuredContent map[string]any `json:"structuredContent,omitempty"`
+	IsError           bool           `json:"isError,omitempty"`
+}
+
+type initializeResult struct {
+	ProtocolVersion string         `json:"protocolVersion"`
+	ServerInfo      Implementation `json:"serverInfo"`
+	Capabilities    map[string]any `json:"capabilities,omitempty"`
+	Instructions    string         `json:"instructions,omitempty"`
+}
+
+type listToolsResult struct {
+	Tools      []ToolInfo `json:"tools"`
+	NextCursor string     `json:"nextCursor,omitempty"`
+}
+
+// Client is one initialized MCP server connection.
+type Client struct {
+	cfg       ServerConfig
+	transport transport
+	server    Implementation
+	hasTools  bool
+}
+
+// ConnectOptions customizes transport construction.
+type ConnectOptions struct {
+	HTTPClient *http.Client
+}
+
+// Connect spawns or attaches one MCP server and completes the initialize
+// handshake.
+func Connect(ctx context.Context, cfg ServerConfig, opts ConnectOptions) (*Client, error) {
+	if ctx == nil {
+		return nil, fmt.Errorf("mcp: context is required")
+	}
+	normalized, err := cfg.Normalize()
+	if err != nil {
+		return nil, err
+	}
+	var t transport
+	switch normalized.Transport {
+	case TransportStdio:
+		stdio, err := startStdioTransport(normalized)
+		if err != nil {
+			return nil, fmt.Errorf("mcp: start server %q: %w", normalized.Name, err)
+		}
+		t = stdio
+	case TransportHTTP:
+		t = newHTTPTransport(normalized, opts.HTTPClient)
+	}
+	client := &Client{cfg: normalized, transport: t}
+	if err := client.initialize(ctx); err != nil {
+		_ = t.close()
+		return nil, err
+	}
+	return client, nil
+}
+
+func newClientWithTransport(ctx context.Context, cfg ServerConfig, t transport) (*Client, error) {
+	client := &Client{cfg: cfg, transport: t}
+	if err := client.initialize(ctx); err != nil {
+		_ = t.close()
+		return nil, err
+	}
+	return client, nil
+}
+
+func (c *Client) initialize(ctx context.Context) error {
+	var result initializeResult
+	err := c.transport.call(ctx, "initialize", map[string]any{
+		"protocolVersion": ProtocolVersion,
+		"capabilities":    map[string]any{},
+		"clientInfo": Implementation{
+			Name:    "caelis",
+			Version: version.String(),
+		},
+	}, &result)
+	if err != nil {
+		return fmt.Errorf("mcp: initialize server %q: %w", c.cfg.Name, err)
+	}
+	c.server = result.ServerInfo
+	_, c.hasTools = result.Capabilities["tools"]
+	if err := c.transport.notify(ctx, "notifications/initialized", map[string]any{}); err != nil {
+		return fmt.Errorf("mcp: initialize server %q: %w", c.cfg.Name, err)
+	}
+	return nil
+}
+
+// Name returns the configured server name.
+func (c *Client) Name() string {
+	if c == nil {
+		return ""
+	}
+	return c.cfg.Name
+}
+
+// Transport returns the configured transport type.
+func (c *Client) Transport() TransportType {
+	if c == nil {
+		return ""
+	}
+	return c.cfg.Transport
+}
+
+// Trusted reports whether the user marked the server trusted in the config.
+func (c *Client) Trusted() bool {
+	return c != nil && c.cfg.Trusted
+}
+
+// ServerInfo returns the implementation reported by initialize.
+func (c *Client) ServerInfo() Implementation {
+	if c == nil {
+		return Implementation{}
+	}
+	return c.server
+}
+
+// ListTools returns every tool the server exposes, following pagination.
+func (c *Client) ListTools(ctx context.Context) ([]ToolInfo, error) {
+	if c == nil {
+		return nil, fmt.Errorf("mcp: client is nil")
+	}
+	if !c.hasTools {
+		return nil, nil
+	}
+	var out []ToolInfo
+	cursor := ""
+	for range maxToolPages {
+		params := map[string]any{}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+		var page listToolsResult
+		if err := c.transport.call(ctx, "tools/list", params, &page); err != nil {
+			return nil, fmt.Errorf("mcp: list tools on %q: %w", c.cfg.Name, err)
+		}
+		out = append(out, page.Tools...)
+		cursor = strings.TrimSpace(page.NextCursor)
+		if cursor == "" {
+			return out, nil
+		}
+	}
+	return out, nil
+}
+
+// CallTool invokes one remote tool.
+func (c *Client) CallTool(ctx context.Context, name string, args map[string]any) (CallResult, error) {
+	if c == nil {
+		return CallResult{}, fmt.Errorf("mcp: client is nil")
+	}
+	if args == nil {
+		args = map[string]any{}
+	}
+	var result CallResult
+	if err := c.transport.call(ctx, "tools/call", map[string]any{
+		"name":      name,
+		"arguments": args,
+	}, &result); err != nil {
+		return CallResult{}, fmt.Errorf("mcp: call %s/%s: %w", c.cfg.Name, name, err)
+	}
+	return result, nil
+}
+
+// Close terminates the server connection.
+func (c *Client) Close() error {
+	if c == nil || c.transport == nil {
+		return nil
+	}
+	return c.transport.close()
+}
+
+// Text joins the text content blocks of one call result.
+func (r CallResult) Text() string {
+	texts := make([]string, 0, len(r.Content))
+	for _, one := range r.Content {
+		switch one.Type {
+		case "text":
+			if strings.TrimSpace(one.Text) != "" {
+				texts = append(texts, one.Text)
+			}
+		case "resource":
+			if text, ok := one.Resource["text"].(string); ok && strings.TrimSpace(text) != "" {
+				texts = append(texts, text)
+			}
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+func (r CallResult) toolOutput() map[string]any {
+	out := map[string]any{}
+	if text := r.Text(); text != "" {
+		out["content"] = text
+	}
+	if len(r.StructuredContent) > 0 {
+		out["structured_content"] = r.StructuredContent
+	}
+	var attachments []map[string]any
+	for _, one := range r.Content {
+		switch one.Type {
+		case "image", "audio":
+			attachments = append(attachments, map[string]any{
+				"type":      one.Type,
+				"mime_type": one.MimeType,
+				"bytes":     len(one.Data) * 3 / 4,
+			})
+		case "resource_link":
+			attachments = append(attachments, map[string]any{"type": one.Type, "uri": one.URI})
+		case "resource":
+			if uri, ok := one.Resource["uri"].(string); ok && uri != "" {
+				attachments = append(attachments, map[string]any{"type": one.Type, "uri": uri})
+			}
+		}
+	}
+	if len(attachments) > 0 {
+		out["attachments"] = attachments
+	}
+	return out
+}
+
+func decodeSchema(raw map[string]any) map[string]any {
+	if len(raw) == 0 {
+		return map[string]any{"type": "object", "properties": map[string]any{}}
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return map[string]any{"type": "object", "properties": map[string]any{}}
+	}
+	var out map[string]any
+	if err := json.Unmarshal(data, &out); err != nil {
+		return map[string]any{"type": "object", "properties": map[string]any{}}
+	}
+	if _, ok := out["type"]; !ok {
+		out["type"] = "object"
+	}
+	return out
+}
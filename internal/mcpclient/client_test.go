@@ -0,0 +1,300 @@
+package mcpclient
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/OnslaughtSnail/caelis/kernel/tool/capability"
+)
+
+type fakeServer struct {
+	tools []ToolInfo
+}
+
+func (f fakeServer) handle(method string, params json.RawMessage) (any, error) {
+	switch method {
+	case "initialize":
+		return map[string]any{
+			"protocolVersion": ProtocolVersion,
+			"serverInfo":      map[string]any{"name": "fake", "version": "1.0.0"},
+			"capabilities":    map[string]any{"tools": map[string]any{}},
+		}, nil
+	case "tools/list":
+		var req struct {
+			Cursor string `json:"cursor"`
+		}
+		_ = json.Unmarshal(params, &req)
+		if req.Cursor == "" && len(f.tools) > 1 {
+			return map[string]any{"tools": f.tools[:1], "nextCursor": "page-2"}, nil
+		}
+		if req.Cursor == "page-2" {
+			return map[string]any{"tools": f.tools[1:]}, nil
+		}
+		return map[string]any{"tools": f.tools}, nil
+	case "tools/call":
+		var req struct {
+			Name      string         `json:"name"`
+			Arguments map[string]any `json:"arguments"`
+		}
+		if err := json.Unmarshal(params, &req); err != nil {
+			return nil, err
+		}
+		if req.Name == "fail" {
+			return map[string]any{
+				"content": []any{map[string]any{"type": "text", "text": "boom"}},
+				"isError": true,
+			}, nil
+		}
+		return map[string]any{
+			"content": []any{map[string]any{"type": "text", "text": fmt.Sprintf("echo:%v", req.Arguments["text"])}},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown method %s", method)
+	}
+}
+
+func (f fakeServer) serveStdio(t *testing.T, in io.Reader, out io.Writer) {
+	t.Helper()
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		var msg struct {
+			ID     any             `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil || msg.ID == nil {
+			continue
+		}
+		result, err := f.handle(msg.Method, msg.Params)
+		resp := map[string]any{"jsonrpc": "2.0", "id": msg.ID}
+		if err != nil {
+			resp["error"] = map[string]any{"code": -32601, "message": err.Error()}
+		} else {
+			resp["result"] = result
+		}
+		raw, _ := json.Marshal(resp)
+		if _, err := out.Write(append(raw, '\n')); err != nil {
+			return
+		}
+	}
+}
+
+func sampleTools() []ToolInfo {
+	readOnly := true
+	return []ToolInfo{
+		{
+			Name:        "echo",
+			Description: "Echo text back.",
+			InputSchema: map[string]any{
+				"type":       "object",
+				"properties": map[string]any{"text": map[string]any{"type": "string"}},
+			},
+			Annotations: &ToolAnnotations{ReadOnlyHint: &readOnly},
+		},
+		{Name: "fail"},
+	}
+}
+
+func TestClient_StdioListAndCall(t *testing.T) {
+	clientIn, serverOut := io.Pipe()
+	serverIn, clientOut := io.Pipe()
+	go fakeServer{tools: sampleTools()}.serveStdio(t, serverIn, serverOut)
+
+	ctx := context.Background()
+	client, err := newClientWithTransport(ctx, ServerConfig{Name: "local", Transport: TransportStdio}, newStdioTransport(clientIn, clientOut))
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer client.Close()
+	if got := client.ServerInfo().Name; got != "fake" {
+		t.Fatalf("expected server info from initialize, got %q", got)
+	}
+
+	tools, err := NewTools(ctx, client)
+	if err != nil {
+		t.Fatalf("list tools: %v", err)
+	}
+	if len(tools) != 2 {
+		t.Fatalf("expected paginated tools to be merged, got %d", len(tools))
+	}
+	echo := tools[0]
+	if echo.Name() != "mcp__local__echo" {
+		t.Fatalf("unexpected tool name %q", echo.Name())
+	}
+	if params := echo.Declaration().Parameters; params["type"] != "object" {
+		t.Fatalf("expected input schema to be forwarded, got %#v", params)
+	}
+	if got := capability.Of(echo); got.Risk != capability.RiskMedium || !got.HasOperation(capability.OperationExec) {
+		t.Fatalf("expected an untrusted server's read-only hint to be ignored, got %+v", got)
+	}
+	if got := capability.Of(tools[1]); got.Risk != capability.RiskMedium || !got.HasOperation(capability.OperationExec) {
+		t.Fatalf("expected unannotated tool to default to medium exec, got %+v", got)
+	}
+
+	out, err := echo.Run(ctx, map[string]any{"text": "hi"})
+	if err != nil {
+		t.Fatalf("call echo: %v", err)
+	}
+	if out["content"] != "echo:hi" {
+		t.Fatalf("unexpected echo output %#v", out)
+	}
+	if _, err := tools[1].Run(ctx, nil); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("expected isError result to surface as error, got %v", err)
+	}
+}
+
+func TestTool_CapabilityHonorsReadOnlyHintOnlyForTrustedServers(t *testing.T) {
+	readOnly, destructive := true, true
+	readTool := ToolInfo{Name: "read", Annotations: &ToolAnnotations{ReadOnlyHint: &readOnly}}
+	trusted := &Tool{client: &Client{cfg: ServerConfig{Name: "docs", Trusted: true}}, info: readTool}
+	if got := capability.Of(trusted); got.Risk != capability.RiskLow || !got.HasOperation(capability.OperationFileRead) {
+		t.Fatalf("expected a trusted server's read-only hint to map to low risk, got %+v", got)
+	}
+	untrusted := &Tool{client: &Client{cfg: ServerConfig{Name: "docs"}}, info: readTool}
+	if got := capability.Of(untrusted); got.Risk != capability.RiskMedium {
+		t.Fatalf("expected an untrusted server's read-only hint to be ignored, got %+v", got)
+	}
+	mixed := &Tool{client: &Client{cfg: ServerConfig{Name: "docs"}}, info: ToolInfo{
+		Name:        "wipe",
+		Annotations: &ToolAnnotations{ReadOnlyHint: &readOnly, DestructiveHint: &destructive},
+	}}
+	if got := capability.Of(mixed); got.Risk != capability.RiskHigh {
+		t.Fatalf("expected a destructive hint to raise risk even when untrusted, got %+v", got)
+	}
+}
+
+func TestClient_HTTPJSONAndEventStream(t *testing.T) {
+	fake := fakeServer{tools: sampleTools()[:1]}
+	var sawSession bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		var msg struct {
+			ID     any             `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if msg.Method != "initialize" && r.Header.Get(mcpSessionHeader) == "sess-1" {
+			sawSession = true
+		}
+		if msg.ID == nil {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		result, _ := fake.handle(msg.Method, msg.Params)
+		raw, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": msg.ID, "result": result})
+		if msg.Method == "initialize" {
+			w.Header().Set(mcpSessionHeader, "sess-1")
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write(raw)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = fmt.Fprintf(w, "event: message\ndata: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/progress\"}\n\n")
+		_, _ = fmt.Fprintf(w, "event: message\ndata: %s\n\n", raw)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	client, err := Connect(ctx, ServerConfig{
+		Name:    "remote",
+		URL:     srv.URL,
+		Headers: map[string]string{"Authorization": "Bearer secret"},
+	}, ConnectOptions{HTTPClient: srv.Client()})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer client.Close()
+	tools, err := NewTools(ctx, client)
+	if err != nil {
+		t.Fatalf("list tools: %v", err)
+	}
+	if len(tools) != 1 {
+		t.Fatalf("expected 1 tool, got %d", len(tools))
+	}
+	if !capability.Of(tools[0]).HasOperation(capability.OperationNetwork) {
+		t.Fatalf("expected http tools to declare network operation")
+	}
+	out, err := tools[0].Run(ctx, map[string]any{"text": "sse"})
+	if err != nil {
+		t.Fatalf("call: %v", err)
+	}
+	if out["content"] != "echo:sse" {
+		t.Fatalf("unexpected output %#v", out)
+	}
+	if !sawSession {
+		t.Fatalf("expected Mcp-Session-Id to be echoed after initialize")
+	}
+}
+
+func TestParseACPServers(t *testing.T) {
+	servers, err := ParseACPServers([]map[string]any{
+		{
+			"name":    "files",
+			"command": "/usr/bin/mcp-files",
+			"args":    []any{"--root", "."},
+			"env":     []any{map[string]any{"name": "TOKEN", "value": "x"}},
+		},
+		{
+			"type":    "http",
+			"name":    "docs",
+			"url":     "https://mcp.example.com",
+			"headers": []any{map[string]any{"name": "Authorization", "value": "Bearer y"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if servers[0].Transport != TransportStdio || servers[0].Env["TOKEN"] != "x" || len(servers[0].Args) != 2 {
+		t.Fatalf("unexpected stdio server %+v", servers[0])
+	}
+	if servers[1].Transport != TransportHTTP || servers[1].Headers["Authorization"] != "Bearer y" {
+		t.Fatalf("unexpected http server %+v", servers[1])
+	}
+	if _, err := ParseACPServers([]map[string]any{{"type": "sse", "name": "legacy", "url": "https://x"}}); err == nil {
+		t.Fatalf("expected sse transport to be rejected")
+	}
+
+	merged := MergeServers([]ServerConfig{{Name: "docs", URL: "https://old"}, {Name: "git", Command: "git-mcp"}}, servers)
+	if len(merged) != 3 || merged[0].URL != "https://mcp.example.com" {
+		t.Fatalf("expected session servers to override configured ones by name, got %+v", merged)
+	}
+}
+
+func TestToolName_SanitizesAndBoundsLength(t *testing.T) {
+	if got := ToolName("my server", "search.files"); got != "mcp__my_server__search_files" {
+		t.Fatalf("unexpected sanitized name %q", got)
+	}
+	if got := ToolName(strings.Repeat("s", 40), strings.Repeat("t", 40)); len(got) != maxToolNameLength {
+		t.Fatalf("expected name to be truncated to %d, got %d", maxToolNameLength, len(got))
+	}
+}
+
+func TestUniqueToolName_SuffixesWithinLengthLimit(t *testing.T) {
+	long := ToolName(strings.Repeat("s", 40), strings.Repeat("t", 40))
+	taken := map[string]struct{}{long: {}}
+	got := uniqueToolName(long, taken)
+	if len(got) != maxToolNameLength || !strings.HasSuffix(got, "_2") {
+		t.Fatalf("expected a suffixed name within the limit, got %q", got)
+	}
+	if got := uniqueToolName("mcp__a__b", taken); got != "mcp__a__b" {
+		t.Fatalf("expected a free name to be kept, got %q", got)
+	}
+}
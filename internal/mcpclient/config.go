@@ -0,0 +1,213 @@
+package mcpclient
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// TransportType identifies how one MCP server is reached.
+type TransportType string
+
+const (
+	TransportStdio TransportType = "stdio"
+	TransportHTTP  TransportType = "http"
+)
+
+// ServerConfig describes one MCP server to spawn or attach.
+type ServerConfig struct {
+	Name      string
+	Transport TransportType
+	Command   string
+	Args      []string
+	Env       map[string]string
+	WorkDir   string
+	URL       string
+	Headers   map[string]string
+	// Trusted lets the server's readOnlyHint annotations lower its tools'
+	// risk. Only servers the user marks trusted in the config set it.
+	Trusted bool
+}
+
+// Normalize trims fields and infers the transport when it is omitted.
+func (c ServerConfig) Normalize() (ServerConfig, error) {
+	out := ServerConfig{
+		Name:      strings.TrimSpace(c.Name),
+		Transport: TransportType(strings.ToLower(strings.TrimSpace(string(c.Transport)))),
+		Command:   strings.TrimSpace(c.Command),
+		Args:      append([]string(nil), c.Args...),
+		Env:       copyStringMap(c.Env),
+		WorkDir:   strings.TrimSpace(c.WorkDir),
+		URL:       strings.TrimSpace(c.URL),
+		Headers:   copyStringMap(c.Headers),
+		Trusted:   c.Trusted,
+	}
+	if out.Name == "" {
+		return ServerConfig{}, fmt.Errorf("mcp: server name is required")
+	}
+	if out.Transport == "" {
+		if out.URL != "" {
+			out.Transport = TransportHTTP
+		} else {
+			out.Transport = TransportStdio
+		}
+	}
+	switch out.Transport {
+	case TransportStdio:
+		if out.Command == "" {
+			return ServerConfig{}, fmt.Errorf("mcp: server %q: command is required for stdio transport", out.Name)
+		}
+	case TransportHTTP:
+		if out.URL == "" {
+			return ServerConfig{}, fmt.Errorf("mcp: server %q: url is required for http transport", out.Name)
+		}
+	default:
+		return ServerConfig{}, fmt.Errorf("mcp: server %q: unsupported transport %q", out.Name, out.Transport)
+	}
+	return out, nil
+}
+
+// ParseACPServer converts one ACP session/new mcpServers entry into a server
+// config. ACP encodes env and headers as {name,value} lists; stdio entries
+// omit "type".
+func ParseACPServer(raw map[string]any) (ServerConfig, error) {
+	if len(raw) == 0 {
+		return ServerConfig{}, fmt.Errorf("mcp: empty acp server entry")
+	}
+	cfg := ServerConfig{
+		Name:    stringValue(raw["name"]),
+		Command: stringValue(raw["command"]),
+		URL:     stringValue(raw["url"]),
+		Args:    stringList(raw["args"]),
+		Env:     nameValueMap(raw["env"]),
+		Headers: nameValueMap(raw["headers"]),
+	}
+	switch kind := strings.ToLower(stringValue(raw["type"])); kind {
+	case "", "stdio":
+		if cfg.URL == "" {
+			cfg.Transport = TransportStdio
+		}
+	case "http", "streamable_http", "streamable-http":
+		cfg.Transport = TransportHTTP
+	default:
+		return ServerConfig{}, fmt.Errorf("mcp: server %q: unsupported acp transport %q", cfg.Name, kind)
+	}
+	return cfg.Normalize()
+}
+
+// ParseACPServers converts a list of ACP mcpServers entries.
+func ParseACPServers(raw []map[string]any) ([]ServerConfig, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	out := make([]ServerConfig, 0, len(raw))
+	seen := map[string]struct{}{}
+	for _, one := range raw {
+		cfg, err := ParseACPServer(one)
+		if err != nil {
+			return nil, err
+		}
+		if _, exists := seen[cfg.Name]; exists {
+			return nil, fmt.Errorf("mcp: duplicate server %q", cfg.Name)
+		}
+		seen[cfg.Name] = struct{}{}
+		out = append(out, cfg)
+	}
+	return out, nil
+}
+
+// MergeServers returns base servers overridden by name with extra servers.
+func MergeServers(base []ServerConfig, extra []ServerConfig) []ServerConfig {
+	if len(extra) == 0 {
+		return append([]ServerConfig(nil), base...)
+	}
+	index := map[string]int{}
+	out := make([]ServerConfig, 0, len(base)+len(extra))
+	for _, one := range base {
+		index[one.Name] = len(out)
+		out = append(out, one)
+	}
+	for _, one := range extra {
+		if i, ok := index[one.Name]; ok {
+			out[i] = one
+			continue
+		}
+		index[one.Name] = len(out)
+		out = append(out, one)
+	}
+	return out
+}
+
+func stringValue(raw any) string {
+	text, _ := raw.(string)
+	return strings.TrimSpace(text)
+}
+
+func stringList(raw any) []string {
+	switch typed := raw.(type) {
+	case []string:
+		return append([]string(nil), typed...)
+	case []any:
+		out := make([]string, 0, len(typed))
+		for _, one := range typed {
+			if text, ok := one.(string); ok {
+				out = append(out, text)
+			}
+		}
+		return out
+	default:
+		return nil
+	}
+}
+
+func nameValueMap(raw any) map[string]string {
+	switch typed := raw.(type) {
+	case map[string]any:
+		out := make(map[string]string, len(typed))
+		for key, value := range typed {
+			if text, ok := value.(string); ok {
+				out[key] = text
+			}
+		}
+		return out
+	case map[string]string:
+		return copyStringMap(typed)
+	case []any:
+		out := make(map[string]string, len(typed))
+		for _, one := range typed {
+			entry, ok := one.(map[string]any)
+			if !ok {
+				continue
+			}
+			name := stringValue(entry["name"])
+			if name == "" {
+				continue
+			}
+			value, _ := entry["value"].(string)
+			out[name] = value
+		}
+		return out
+	default:
+		return nil
+	}
+}
+
+func copyStringMap(in map[string]string) map[string]string {
+	if len(in) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(in))
+	for key, value := range in {
+		out[key] = value
+	}
+	return out
+}
+
+func sortedKeys(in map[string]string) []string {
+	keys := make([]string, 0, len(in))
+	for key := range in {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,180 @@
+package mcpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/OnslaughtSnail/caelis/kernel/model"
+	"github.com/OnslaughtSnail/caelis/kernel/tool"
+	"github.com/OnslaughtSnail/caelis/kernel/tool/capability"
+)
+
+// ToolNamePrefix marks model-visible names of MCP-backed tools.
+const ToolNamePrefix = "mcp__"
+
+// maxToolNameLength keeps names within the strictest provider limit.
+const maxToolNameLength = 64
+
+// Tool adapts one remote MCP tool to the kernel tool contract.
+type Tool struct {
+	client *Client
+	info   ToolInfo
+	name   string
+}
+
+// NewTools lists the server's tools and wraps each as a kernel tool.
+func NewTools(ctx context.Context, client *Client) ([]tool.Tool, error) {
+	return NewToolsAvoiding(ctx, client, map[string]struct{}{})
+}
+
+// NewToolsAvoiding is NewTools for a server whose tools join others already
+// named in taken. A tool whose name is taken, which sanitizing and truncation
+// make possible, gets a numbered suffix instead; every name used is added to
+// taken.
+func NewToolsAvoiding(ctx context.Context, client *Client, taken map[string]struct{}) ([]tool.Tool, error) {
+	infos, err := client.ListTools(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]tool.Tool, 0, len(infos))
+	remotes := map[string]struct{}{}
+	for _, info := range infos {
+		if strings.TrimSpace(info.Name) == "" {
+			continue
+		}
+		if _, exists := remotes[info.Name]; exists {
+			continue
+		}
+		remotes[info.Name] = struct{}{}
+		name := uniqueToolName(ToolName(client.Name(), info.Name), taken)
+		taken[name] = struct{}{}
+		out = append(out, &Tool{client: client, info: info, name: name})
+	}
+	return out, nil
+}
+
+func uniqueToolName(name string, taken map[string]struct{}) string {
+	if _, exists := taken[name]; !exists {
+		return name
+	}
+	for i := 2; ; i++ {
+		suffix := fmt.Sprintf("_%d", i)
+		candidate := name
+		if len(candidate)+len(suffix) > maxToolNameLength {
+			candidate = candidate[:maxToolNameLength-len(suffix)]
+		}
+		candidate += suffix
+		if _, exists := taken[candidate]; !exists {
+			return candidate
+		}
+	}
+}
+
+// ToolName derives the model-visible name for one server tool.
+func ToolName(server string, remote string) string {
+	name := ToolNamePrefix + sanitizeNameSegment(server) + "__" + sanitizeNameSegment(remote)
+	if len(name) > maxToolNameLength {
+		name = name[:maxToolNameLength]
+	}
+	return name
+}
+
+func sanitizeNameSegment(value string) string {
+	value = strings.TrimSpace(value)
+	var b strings.Builder
+	for _, r := range value {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	if b.Len() == 0 {
+		return "_"
+	}
+	return b.String()
+}
+
+func (t *Tool) Name() string {
+	return t.name
+}
+
+func (t *Tool) Description() string {
+	desc := strings.TrimSpace(t.info.Description)
+	if desc == "" && t.info.Annotations != nil {
+		desc = strings.TrimSpace(t.info.Annotations.Title)
+	}
+	if desc == "" {
+		desc = t.info.Name
+	}
+	return fmt.Sprintf("[MCP %s] %s", t.client.Name(), desc)
+}
+
+func (t *Tool) Declaration() model.ToolDefinition {
+	return model.ToolDefinition{
+		Name:        t.Name(),
+		Description: t.Description(),
+		Parameters:  decodeSchema(t.info.InputSchema),
+	}
+}
+
+// MCPSpec reports which server tool this kernel tool resolves to.
+func (t *Tool) MCPSpec() model.MCPToolSpec {
+	return model.MCPToolSpec{
+		Name:   t.Name(),
+		Server: t.client.Name(),
+		Tool:   t.info.Name,
+	}
+}
+
+// Capability derives a policy profile from the server's tool annotations.
+// Annotations are untrusted hints: they may raise a tool's risk, but a
+// readOnlyHint only lowers it for servers the user marked trusted. Any other
+// tool is treated as a medium-risk side effect rather than a read.
+func (t *Tool) Capability() capability.Capability {
+	ops := []capability.Operation{}
+	if t.client.Transport() == TransportHTTP {
+		ops = append(ops, capability.OperationNetwork)
+	}
+	risk := capability.RiskMedium
+	annotations := t.info.Annotations
+	if annotations == nil {
+		return capability.Capability{Operations: append(ops, capability.OperationExec), Risk: risk}
+	}
+	if isTrue(annotations.OpenWorldHint) {
+		ops = append(ops, capability.OperationNetwork)
+	}
+	switch {
+	case isTrue(annotations.DestructiveHint):
+		ops = append(ops, capability.OperationExec, capability.OperationFileWrite)
+		risk = capability.RiskHigh
+	case isTrue(annotations.ReadOnlyHint) && t.client.Trusted():
+		ops = append(ops, capability.OperationFileRead)
+		risk = capability.RiskLow
+	default:
+		ops = append(ops, capability.OperationExec)
+	}
+	return capability.Capability{Operations: ops, Risk: risk}
+}
+
+func (t *Tool) Run(ctx context.Context, args map[string]any) (map[string]any, error) {
+	result, err := t.client.CallTool(ctx, t.info.Name, args)
+	if err != nil {
+		return nil, err
+	}
+	if result.IsError {
+		text := strings.TrimSpace(result.Text())
+		if text == "" {
+			text = "tool reported an error"
+		}
+		return nil, errors.New(text)
+	}
+	return result.toolOutput(), nil
+}
+
+func isTrue(value *bool) bool {
+	return value != nil && *value
+}
@@ -0,0 +1,350 @@
+package mcpclient
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/OnslaughtSnail/caelis/internal/acpconn"
+)
+
+const stderrTailLimit = 4096
+
+type transport interface {
+	call(ctx context.Context, method string, params any, out any) error
+	notify(ctx context.Context, method string, params any) error
+	close() error
+}
+
+// stdioTransport speaks newline-delimited JSON-RPC with a spawned process.
+type stdioTransport struct {
+	cmd    *exec.Cmd
+	conn   *acpconn.Conn
+	cancel context.CancelFunc
+	done   chan error
+
+	stderrMu sync.Mutex
+	stderr   bytes.Buffer
+}
+
+func startStdioTransport(cfg ServerConfig) (*stdioTransport, error) {
+	cmd := exec.Command(cfg.Command, cfg.Args...)
+	cmd.Dir = cfg.WorkDir
+	cmd.Env = mergedEnv(cfg.Env)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	t := newStdioTransport(stdout, stdin)
+	t.cmd = cmd
+	go func() {
+		_, _ = io.Copy(stderrTailWriter{t: t}, stderr)
+	}()
+	return t, nil
+}
+
+func newStdioTransport(reader io.Reader, writer io.Writer) *stdioTransport {
+	serveCtx, cancel := context.WithCancel(context.Background())
+	t := &stdioTransport{
+		conn:   acpconn.New(reader, writer),
+		cancel: cancel,
+		done:   make(chan error, 1),
+	}
+	go func() {
+		t.done <- t.conn.Serve(serveCtx, handleServerRequest, nil)
+	}()
+	return t
+}
+
+// handleServerRequest answers the few server-initiated requests a tool-only
+// client must support.
+func handleServerRequest(_ context.Context, msg acpconn.Message) (any, *acpconn.RPCError) {
+	switch msg.Method {
+	case "ping":
+		return map[string]any{}, nil
+	case "roots/list":
+		return map[string]any{"roots": []any{}}, nil
+	default:
+		return nil, &acpconn.RPCError{Code: -32601, Message: "method not found"}
+	}
+}
+
+func (t *stdioTransport) call(ctx context.Context, method string, params any, out any) error {
+	if err := t.conn.Call(ctx, method, params, out); err != nil {
+		if tail := t.stderrTail(); tail != "" {
+			return fmt.Errorf("%w (stderr: %s)", err, tail)
+		}
+		return err
+	}
+	return nil
+}
+
+func (t *stdioTransport) notify(_ context.Context, method string, params any) error {
+	return t.conn.Notify(method, params)
+}
+
+func (t *stdioTransport) close() error {
+	if t.cancel != nil {
+		t.cancel()
+	}
+	if t.cmd == nil || t.cmd.Process == nil {
+		return nil
+	}
+	_ = t.cmd.Process.Kill()
+	select {
+	case <-time.After(100 * time.Millisecond):
+	case <-t.done:
+	}
+	_ = t.cmd.Wait()
+	return nil
+}
+
+func (t *stdioTransport) stderrTail() string {
+	t.stderrMu.Lock()
+	defer t.stderrMu.Unlock()
+	return strings.TrimSpace(t.stderr.String())
+}
+
+type stderrTailWriter struct {
+	t *stdioTransport
+}
+
+func (w stderrTailWriter) Write(p []byte) (int, error) {
+	w.t.stderrMu.Lock()
+	defer w.t.stderrMu.Unlock()
+	w.t.stderr.Write(p)
+	if over := w.t.stderr.Len() - stderrTailLimit; over > 0 {
+		w.t.stderr.Next(over)
+	}
+	return len(p), nil
+}
+
+// httpTransport implements the MCP streamable HTTP transport: every message is
+// POSTed and the reply arrives as JSON or as a short SSE stream.
+type httpTransport struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+	nextID  atomic.Int64
+
+	mu        sync.Mutex
+	sessionID string
+}
+
+const mcpSessionHeader = "Mcp-Session-Id"
+
+func newHTTPTransport(cfg ServerConfig, client *http.Client) *httpTransport {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &httpTransport{
+		url:     cfg.URL,
+		headers: copyStringMap(cfg.Headers),
+		client:  client,
+	}
+}
+
+func (t *httpTransport) call(ctx context.Context, method string, params any, out any) error {
+	id := t.nextID.Add(1)
+	resp, err := t.post(ctx, acpconn.Message{
+		JSONRPC: acpconn.JSONRPCVersion,
+		ID:      id,
+		Method:  method,
+		Params:  acpconn.MustMarshalRaw(params),
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	msg, err := readHTTPResponse(resp, id)
+	if err != nil {
+		return err
+	}
+	if msg.Error != nil {
+		return acpconn.FormatRPCError(msg.Error)
+	}
+	if out == nil {
+		return nil
+	}
+	raw, err := json.Marshal(msg.Result)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, out)
+}
+
+func (t *httpTransport) notify(ctx context.Context, method string, params any) error {
+	resp, err := t.post(ctx, acpconn.Message{
+		JSONRPC: acpconn.JSONRPCVersion,
+		Method:  method,
+		Params:  acpconn.MustMarshalRaw(params),
+	})
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return resp.Body.Close()
+}
+
+func (t *httpTransport) close() error {
+	t.mu.Lock()
+	sessionID := t.sessionID
+	t.mu.Unlock()
+	if sessionID == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, t.url, nil)
+	if err != nil {
+		return nil
+	}
+	t.applyHeaders(req)
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil
+	}
+	_ = resp.Body.Close()
+	return nil
+}
+
+func (t *httpTransport) post(ctx context.Context, msg acpconn.Message) (*http.Response, error) {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	t.applyHeaders(req)
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("mcp: http %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	if sessionID := strings.TrimSpace(resp.Header.Get(mcpSessionHeader)); sessionID != "" {
+		t.mu.Lock()
+		t.sessionID = sessionID
+		t.mu.Unlock()
+	}
+	return resp, nil
+}
+
+func (t *httpTransport) applyHeaders(req *http.Request) {
+	for _, key := range sortedKeys(t.headers) {
+		req.Header.Set(key, t.headers[key])
+	}
+	t.mu.Lock()
+	sessionID := t.sessionID
+	t.mu.Unlock()
+	if sessionID != "" {
+		req.Header.Set(mcpSessionHeader, sessionID)
+	}
+}
+
+func readHTTPResponse(resp *http.Response, id int64) (acpconn.Message, error) {
+	contentType := strings.ToLower(resp.Header.Get("Content-Type"))
+	if !strings.HasPrefix(contentType, "text/event-stream") {
+		var msg acpconn.Message
+		if err := json.NewDecoder(resp.Body).Decode(&msg); err != nil {
+			return acpconn.Message{}, fmt.Errorf("mcp: decode response: %w", err)
+		}
+		return msg, nil
+	}
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	var data strings.Builder
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line != "" {
+			if payload, ok := strings.CutPrefix(line, "data:"); ok {
+				if data.Len() > 0 {
+					data.WriteByte('\n')
+				}
+				data.WriteString(strings.TrimPrefix(payload, " "))
+			}
+			continue
+		}
+		if data.Len() == 0 {
+			continue
+		}
+		var msg acpconn.Message
+		err := json.Unmarshal([]byte(data.String()), &msg)
+		data.Reset()
+		if err != nil || msg.Method != "" {
+			continue
+		}
+		if matchesID(msg.ID, id) {
+			return msg, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return acpconn.Message{}, err
+	}
+	return acpconn.Message{}, errors.New("mcp: event stream ended before response")
+}
+
+func matchesID(raw any, id int64) bool {
+	switch typed := raw.(type) {
+	case float64:
+		return int64(typed) == id
+	case json.Number:
+		n, err := typed.Int64()
+		return err == nil && n == id
+	case string:
+		return typed == fmt.Sprint(id)
+	default:
+		return false
+	}
+}
+
+func mergedEnv(overrides map[string]string) []string {
+	base := os.Environ()
+	if len(overrides) == 0 {
+		return base
+	}
+	out := make([]string, 0, len(base)+len(overrides))
+	for _, item := range base {
+		name, _, _ := strings.Cut(item, "=")
+		if _, overridden := overrides[name]; overridden {
+			continue
+		}
+		out = append(out, item)
+	}
+	for _, key := range sortedKeys(overrides) {
+		if strings.TrimSpace(key) == "" {
+			continue
+		}
+		out = append(out, key+"="+overrides[key])
+	}
+	return out
+}
@@ -1,15 +1,18 @@
 package sessionmode
 
 import (
+	"context"
 	"strings"
 
 	"github.com/OnslaughtSnail/caelis/internal/cmdsafety"
 	toolexec "github.com/OnslaughtSnail/caelis/kernel/execenv"
+	"github.com/OnslaughtSnail/caelis/kernel/task"
 )
 
 const (
 	DefaultMode = "default"
 	PlanMode    = "plan"
+	StagedMode  = "staged"
 	FullMode    = "full_access"
 
 	snapshotKey = "session_mode"
@@ -21,6 +24,8 @@ func Normalize(mode string) string {
 	switch strings.ToLower(strings.TrimSpace(mode)) {
 	case PlanMode:
 		return PlanMode
+	case StagedMode:
+		return StagedMode
 	case FullMode:
 		return FullMode
 	default:
@@ -33,6 +38,8 @@ func Next(mode string) string {
 	case DefaultMode:
 		return PlanMode
 	case PlanMode:
+		return StagedMode
+	case StagedMode:
 		return FullMode
 	default:
 		return DefaultMode
@@ -45,6 +52,8 @@ func DisplayLabel(mode string) string {
 		return DefaultMode
 	case PlanMode:
 		return "plan"
+	case StagedMode:
+		return "staged"
 	case FullMode:
 		return "full_access"
 	default:
@@ -56,6 +65,12 @@ func IsFullAccess(mode string) bool {
 	return Normalize(mode) == FullMode
 }
 
+// IsStaged reports whether writes in mode go to an overlay that the user
+// reviews before anything reaches the workspace.
+func IsStaged(mode string) bool {
+	return Normalize(mode) == StagedMode
+}
+
 func PermissionMode(mode string) toolexec.PermissionMode {
 	if IsFullAccess(mode) {
 		return toolexec.PermissionModeFullControl
@@ -68,23 +83,33 @@ func ModeForPermission(permissionMode toolexec.PermissionMode, currentMode strin
 	case toolexec.PermissionModeFullControl:
 		return FullMode
 	default:
-		if Normalize(currentMode) == PlanMode {
-			return PlanMode
+		switch Normalize(currentMode) {
+		case PlanMode, StagedMode:
+			return Normalize(currentMode)
 		}
 		return DefaultMode
 	}
 }
 
+// WithTurnContext marks ctx with what a turn in mode may not do. Plan-mode
+// turns may not merge SPAWN worktrees into the working tree.
+func WithTurnContext(ctx context.Context, mode string) context.Context {
+	if Normalize(mode) == PlanMode {
+		return task.WithWorktreeApplyBlocked(ctx, "plan mode does not change the working tree")
+	}
+	return ctx
+}
+
 func IsDangerousCommand(command string) bool {
 	return cmdsafety.IsDangerousCommand(command)
 }
 
 func Inject(input string, mode string) string {
 	visible := Strip(input)
-	if Normalize(mode) != PlanMode {
+	control := controlBlock(mode)
+	if control == "" {
 		return visible
 	}
-	control := controlBlock(PlanMode)
 	visible = strings.TrimSpace(visible)
 	if visible == "" {
 		return control
@@ -131,6 +156,10 @@ func controlBlock(mode string) string {
 	case PlanMode:
 		return `<caelis-session-mode mode="plan" hidden="true">
 This turn is running in PLAN mode. Focus on analysis, planning, tradeoffs, and implementation strategy. Do not make changes unless the user explicitly asks you to execute them. Do not call the PLAN tool in this mode.
+</caelis-session-mode>`
+	case StagedMode:
+		return `<caelis-session-mode mode="staged" hidden="true">
+This turn is running in STAGED mode. File writes and sandboxed commands go to a staging layer that the user reviews and applies after the turn; you see your own changes as usual. Commands cannot run outside the sandbox in this mode, so do not request escalation.
 </caelis-session-mode>`
 	default:
 		return ""
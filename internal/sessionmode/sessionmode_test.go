@@ -20,6 +20,10 @@ func TestInjectAndVisibleText(t *testing.T) {
 	if got := Inject("", FullMode); got != "" {
 		t.Fatalf("expected empty full_access input to remain empty, got %q", got)
 	}
+	staged := Inject("Refactor the parser.", StagedMode)
+	if staged == "Refactor the parser." || VisibleText(staged) != "Refactor the parser." {
+		t.Fatalf("expected staged mode to inject a hidden control block, got %q", staged)
+	}
 }
 
 func TestSnapshotRoundTrip(t *testing.T) {
@@ -36,8 +40,11 @@ func TestNextCyclesThroughModes(t *testing.T) {
 	if got := Next(DefaultMode); got != PlanMode {
 		t.Fatalf("expected default -> plan, got %q", got)
 	}
-	if got := Next(PlanMode); got != FullMode {
-		t.Fatalf("expected plan -> full_access, got %q", got)
+	if got := Next(PlanMode); got != StagedMode {
+		t.Fatalf("expected plan -> staged, got %q", got)
+	}
+	if got := Next(StagedMode); got != FullMode {
+		t.Fatalf("expected staged -> full_access, got %q", got)
 	}
 	if got := Next(FullMode); got != DefaultMode {
 		t.Fatalf("expected full_access -> default, got %q", got)
@@ -109,6 +116,9 @@ func TestModeForPermission(t *testing.T) {
 	if got := ModeForPermission(toolexec.PermissionModeDefault, PlanMode); got != PlanMode {
 		t.Fatalf("expected default permission mode to preserve plan mode, got %q", got)
 	}
+	if got := ModeForPermission(toolexec.PermissionModeDefault, StagedMode); got != StagedMode {
+		t.Fatalf("expected default permission mode to preserve staged mode, got %q", got)
+	}
 	if got := ModeForPermission(toolexec.PermissionModeDefault, FullMode); got != DefaultMode {
 		t.Fatalf("expected default permission mode to clear full_access back to default, got %q", got)
 	}
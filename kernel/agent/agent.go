@@ -8,6 +8,7 @@ import (
 	"github.com/OnslaughtSnail/caelis/kernel/model"
 	"github.com/OnslaughtSnail/caelis/kernel/policy"
 	"github.com/OnslaughtSnail/caelis/kernel/session"
+	"github.com/OnslaughtSnail/caelis/kernel/task"
 	"github.com/OnslaughtSnail/caelis/kernel/tool"
 )
 
@@ -69,6 +70,7 @@ type SubagentRunRequest struct {
 	Yield       time.Duration
 	Timeout     time.Duration
 	IdleTimeout time.Duration
+	Budget      task.Budget
 }
 
 // SubagentRunResult captures the final delegated child run summary.
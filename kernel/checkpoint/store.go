@@ -0,0 +1,327 @@
+// Package checkpoint records the pre-images of files that tools mutate during
+// a turn so a session can later be rewound to the workspace state it had
+// before that turn.
+package checkpoint
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	manifestFileName = "manifest.json"
+	blobDirName      = "blobs"
+)
+
+// FileSystem is the subset of the tool filesystem used to capture and restore
+// pre-images. toolexec.FileSystem satisfies it.
+type FileSystem interface {
+	Stat(path string) (os.FileInfo, error)
+	ReadFile(path string) ([]byte, error)
+	WriteFile(path string, data []byte, perm os.FileMode) error
+}
+
+// File is the pre-image of one path as it was before its first mutation in a
+// turn.
+type File struct {
+	Path    string      `json:"path"`
+	Existed bool        `json:"existed"`
+	Mode    os.FileMode `json:"mode,omitempty"`
+	Blob    string      `json:"blob,omitempty"`
+}
+
+// Checkpoint groups the pre-images captured during one turn.
+type Checkpoint struct {
+	TurnID    string    `json:"turn_id"`
+	CreatedAt time.Time `json:"created_at"`
+	Files     []File    `json:"files"`
+}
+
+// RestoreResult reports what Restore changed on disk.
+type RestoreResult struct {
+	Restored []string
+	Removed  []string
+}
+
+type manifest struct {
+	Checkpoints []Checkpoint `json:"checkpoints"`
+}
+
+// Store persists checkpoints for one session under a directory: a manifest
+// plus content-addressed blobs so unchanged pre-images are stored once.
+type Store struct {
+	dir string
+	mu  sync.Mutex
+}
+
+func New(dir string) (*Store, error) {
+	dir = strings.TrimSpace(dir)
+	if dir == "" {
+		return nil, fmt.Errorf("checkpoint: dir is required")
+	}
+	return &Store{dir: filepath.Clean(dir)}, nil
+}
+
+// Dir returns the directory backing the store.
+func (s *Store) Dir() string {
+	if s == nil {
+		return ""
+	}
+	return s.dir
+}
+
+// Capture records the current content of paths under turnID. Only the first
+// capture of a path within a turn is kept, since later writes in the same turn
+// must not overwrite the pre-image. Directories are skipped.
+func (s *Store) Capture(fsys FileSystem, turnID string, paths []string) error {
+	if s == nil || fsys == nil {
+		return nil
+	}
+	turnID = strings.TrimSpace(turnID)
+	if turnID == "" || len(paths) == 0 {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, err := s.loadManifest()
+	if err != nil {
+		return err
+	}
+	idx := slices.IndexFunc(m.Checkpoints, func(cp Checkpoint) bool { return cp.TurnID == turnID })
+	if idx < 0 {
+		m.Checkpoints = append(m.Checkpoints, Checkpoint{TurnID: turnID, CreatedAt: time.Now()})
+		idx = len(m.Checkpoints) - 1
+	}
+	cp := &m.Checkpoints[idx]
+	changed := false
+	for _, path := range paths {
+		path = strings.TrimSpace(path)
+		if path == "" || slices.ContainsFunc(cp.Files, func(f File) bool { return f.Path == path }) {
+			continue
+		}
+		file, ok, err := s.captureFile(fsys, path)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		cp.Files = append(cp.Files, file)
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	return s.saveManifest(m)
+}
+
+func (s *Store) captureFile(fsys FileSystem, path string) (File, bool, error) {
+	info, err := fsys.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return File{Path: path}, true, nil
+	}
+	if err != nil {
+		return File{}, false, fmt.Errorf("checkpoint: stat %q: %w", path, err)
+	}
+	if info.IsDir() {
+		return File{}, false, nil
+	}
+	data, err := fsys.ReadFile(path)
+	if err != nil {
+		return File{}, false, fmt.Errorf("checkpoint: read %q: %w", path, err)
+	}
+	blob, err := s.writeBlob(data)
+	if err != nil {
+		return File{}, false, err
+	}
+	return File{Path: path, Existed: true, Mode: info.Mode().Perm(), Blob: blob}, true, nil
+}
+
+// List returns the recorded checkpoints in capture order.
+func (s *Store) List() ([]Checkpoint, error) {
+	if s == nil {
+		return nil, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, err := s.loadManifest()
+	if err != nil {
+		return nil, err
+	}
+	return m.Checkpoints, nil
+}
+
+// Restore puts every file touched by the given turns back to the state it had
+// before the earliest of them, then drops those checkpoints. Files that did
+// not exist before are removed.
+func (s *Store) Restore(fsys FileSystem, turnIDs []string) (RestoreResult, error) {
+	if s == nil || fsys == nil || len(turnIDs) == 0 {
+		return RestoreResult{}, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, err := s.loadManifest()
+	if err != nil {
+		return RestoreResult{}, err
+	}
+	var (
+		result RestoreResult
+		seen   = map[string]struct{}{}
+		kept   = make([]Checkpoint, 0, len(m.Checkpoints))
+	)
+	for _, cp := range m.Checkpoints {
+		if !slices.Contains(turnIDs, cp.TurnID) {
+			kept = append(kept, cp)
+			continue
+		}
+		for _, file := range cp.Files {
+			if _, done := seen[file.Path]; done {
+				continue
+			}
+			seen[file.Path] = struct{}{}
+			removed, err := s.restoreFile(fsys, file)
+			if err != nil {
+				return result, err
+			}
+			if removed {
+				result.Removed = append(result.Removed, file.Path)
+			} else if file.Existed {
+				result.Restored = append(result.Restored, file.Path)
+			}
+		}
+	}
+	m.Checkpoints = kept
+	if err := s.saveManifest(m); err != nil {
+		return result, err
+	}
+	s.pruneBlobs(m)
+	return result, nil
+}
+
+func (s *Store) restoreFile(fsys FileSystem, file File) (bool, error) {
+	if !file.Existed {
+		err := removeFile(fsys, file.Path)
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("checkpoint: remove %q: %w", file.Path, err)
+		}
+		return true, nil
+	}
+	data, err := os.ReadFile(filepath.Join(s.dir, blobDirName, file.Blob))
+	if err != nil {
+		return false, fmt.Errorf("checkpoint: pre-image of %q is missing: %w", file.Path, err)
+	}
+	mode := file.Mode
+	if mode == 0 {
+		mode = 0o644
+	}
+	if err := fsys.WriteFile(file.Path, data, mode); err != nil {
+		return false, fmt.Errorf("checkpoint: restore %q: %w", file.Path, err)
+	}
+	return false, nil
+}
+
+// removeFile deletes path through fsys when it supports removal, as the
+// runtime file systems do, and on the host otherwise.
+func removeFile(fsys FileSystem, path string) error {
+	if remover, ok := fsys.(interface{ Remove(string) error }); ok {
+		return remover.Remove(path)
+	}
+	return os.Remove(path)
+}
+
+func (s *Store) writeBlob(data []byte) (string, error) {
+	sum := sha256.Sum256(data)
+	name := hex.EncodeToString(sum[:])
+	path := filepath.Join(s.dir, blobDirName, name)
+	if _, err := os.Stat(path); err == nil {
+		return name, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	if err := writeFileAtomic(path, data); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+func (s *Store) pruneBlobs(m manifest) {
+	referenced := map[string]struct{}{}
+	for _, cp := range m.Checkpoints {
+		for _, file := range cp.Files {
+			if file.Blob != "" {
+				referenced[file.Blob] = struct{}{}
+			}
+		}
+	}
+	entries, err := os.ReadDir(filepath.Join(s.dir, blobDirName))
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if _, ok := referenced[entry.Name()]; ok {
+			continue
+		}
+		_ = os.Remove(filepath.Join(s.dir, blobDirName, entry.Name()))
+	}
+}
+
+func (s *Store) loadManifest() (manifest, error) {
+	raw, err := os.ReadFile(filepath.Join(s.dir, manifestFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return manifest{}, nil
+	}
+	if err != nil {
+		return manifest{}, err
+	}
+	var m manifest
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return manifest{}, fmt.Errorf("checkpoint: decode manifest: %w", err)
+	}
+	return m, nil
+}
+
+func (s *Store) saveManifest(m manifest) error {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return err
+	}
+	raw, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(s.dir, manifestFileName), raw)
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package checkpoint
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type hostFS struct{}
+
+func (hostFS) Stat(path string) (os.FileInfo, error) { return os.Stat(path) }
+func (hostFS) ReadFile(path string) ([]byte, error)  { return os.ReadFile(path) }
+func (hostFS) WriteFile(path string, data []byte, perm os.FileMode) error {
+	return os.WriteFile(path, data, perm)
+}
+
+func TestStore_RestoresEarliestPreImageAndRemovesCreatedFiles(t *testing.T) {
+	work := t.TempDir()
+	edited := filepath.Join(work, "main.go")
+	created := filepath.Join(work, "new.go")
+	untouched := filepath.Join(work, "keep.go")
+	if err := os.WriteFile(edited, []byte("v1"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	store, err := New(filepath.Join(t.TempDir(), "checkpoints"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys := hostFS{}
+
+	// Turn 1 edits main.go twice; only the first pre-image counts.
+	if err := store.Capture(fsys, "turn-1", []string{edited}); err != nil {
+		t.Fatal(err)
+	}
+	_ = os.WriteFile(edited, []byte("v2"), 0o600)
+	if err := store.Capture(fsys, "turn-1", []string{edited}); err != nil {
+		t.Fatal(err)
+	}
+	_ = os.WriteFile(edited, []byte("v3"), 0o600)
+	// Turn 2 edits main.go again and creates new.go.
+	if err := store.Capture(fsys, "turn-2", []string{edited, created}); err != nil {
+		t.Fatal(err)
+	}
+	_ = os.WriteFile(edited, []byte("v4"), 0o600)
+	_ = os.WriteFile(created, []byte("new"), 0o644)
+	if err := store.Capture(fsys, "turn-3", []string{untouched}); err != nil {
+		t.Fatal(err)
+	}
+
+	checkpoints, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(checkpoints) != 3 || len(checkpoints[0].Files) != 1 || checkpoints[2].Files[0].Existed {
+		t.Fatalf("unexpected checkpoints %+v", checkpoints)
+	}
+
+	result, err := store.Restore(fsys, []string{"turn-1", "turn-2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Restored) != 1 || result.Restored[0] != edited || len(result.Removed) != 1 || result.Removed[0] != created {
+		t.Fatalf("unexpected restore result %+v", result)
+	}
+	raw, err := os.ReadFile(edited)
+	if err != nil || string(raw) != "v1" {
+		t.Fatalf("expected the turn-1 pre-image, got %q %v", raw, err)
+	}
+	if info, err := os.Stat(edited); err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("expected mode to be restored, got %v %v", info, err)
+	}
+	if _, err := os.Stat(created); !os.IsNotExist(err) {
+		t.Fatalf("expected created file to be removed, got %v", err)
+	}
+	remaining, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(remaining) != 1 || remaining[0].TurnID != "turn-3" {
+		t.Fatalf("expected only turn-3 to remain, got %+v", remaining)
+	}
+	blobs, _ := os.ReadDir(filepath.Join(store.Dir(), blobDirName))
+	if len(blobs) != 0 {
+		t.Fatalf("expected unreferenced blobs to be pruned, got %d", len(blobs))
+	}
+}
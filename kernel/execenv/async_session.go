@@ -56,6 +56,10 @@ type AsyncSession struct {
 	idleTimeout  time.Duration // idle timeout
 	tty          bool
 	buildCommand func(context.Context, AsyncSessionConfig) (*exec.Cmd, error)
+	onExit       func()
+	limits       ResourceLimits
+	limiter      *commandLimiter
+	limitHit     string
 }
 
 // AsyncOutputChunk represents a chunk of output from stdout or stderr in async sessions.
@@ -96,6 +100,7 @@ type SessionStatus struct {
 	StdoutEarliestMarker int64
 	StderrEarliestMarker int64
 	Error                string
+	LimitExceeded        string
 }
 
 // AsyncSessionConfig configures an async session.
@@ -108,6 +113,8 @@ type AsyncSessionConfig struct {
 	IdleTimeout     time.Duration // Idle timeout (0 = no idle limit)
 	TTY             bool
 	BuildCommand    func(context.Context, AsyncSessionConfig) (*exec.Cmd, error)
+	OnExit          func() // Called once after the process exits (optional)
+	Limits          ResourceLimits
 }
 
 const (
@@ -139,6 +146,8 @@ func NewAsyncSession(cfg AsyncSessionConfig) *AsyncSession {
 		idleTimeout:  cfg.IdleTimeout,
 		tty:          cfg.TTY,
 		buildCommand: cfg.BuildCommand,
+		onExit:       cfg.OnExit,
+		limits:       cfg.Limits,
 	}
 	session.state.Store(SessionStateRunning)
 	session.lastActivity.Store(time.Now().UnixNano())
@@ -153,7 +162,7 @@ func (s *AsyncSession) Start() error {
 	defer s.mu.Unlock()
 
 	cfg := AsyncSessionConfig{
-		Command:      s.Command,
+		Command:      s.limits.shellCommand(s.Command),
 		Dir:          s.Dir,
 		Env:          append([]string(nil), s.Env...),
 		Timeout:      s.timeout,
@@ -170,6 +179,9 @@ func (s *AsyncSession) Start() error {
 		return err
 	}
 	setProcessGroup(cmd)
+	s.limiter = newCommandLimiter(s.limits)
+	s.limiter.attach(cmd)
+	s.limiter.onExceeded(func() { _ = s.Terminate() })
 	if s.Dir != "" && strings.TrimSpace(cmd.Dir) == "" {
 		cmd.Dir = s.Dir
 	}
@@ -201,6 +213,7 @@ func (s *AsyncSession) Start() error {
 
 	// Start the command
 	if err := cmd.Start(); err != nil {
+		s.limiter.finish(nil)
 		return fmt.Errorf("failed to start command: %w", err)
 	}
 	s.cmd = cmd
@@ -226,6 +239,7 @@ func (s *AsyncSession) readOutput(reader io.Reader, stream string, buffer *RingB
 	buf := make([]byte, 8192)
 	for {
 		n, err := reader.Read(buf)
+		n = s.limiter.allowOutput(n)
 		if n > 0 {
 			data := make([]byte, n)
 			copy(data, buf[:n])
@@ -265,12 +279,20 @@ func (s *AsyncSession) waitForExit() {
 	// ring buffers before marking the session complete. Without this, callers
 	// can observe HasExited/Wait returning before the final output is readable.
 	s.readersWg.Wait()
+	limitHit := s.limiter.finish(err)
+	if s.onExit != nil {
+		s.onExit()
+	}
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	s.exited.Store(true)
 	s.exitErr = err
+	s.limitHit = limitHit
+	if limitHit != "" {
+		s.state.Store(SessionStateTerminated)
+	}
 
 	exitCode := 0
 	if err != nil {
@@ -412,6 +434,7 @@ func (s *AsyncSession) Status() SessionStatus {
 
 	s.mu.RLock()
 	exitErr := s.exitErr
+	status.LimitExceeded = s.limitHit
 	s.mu.RUnlock()
 	if exitErr != nil && state == SessionStateError {
 		status.Error = exitErr.Error()
@@ -547,9 +570,13 @@ func (s *AsyncSession) GetResult() (CommandResult, error) {
 	}
 
 	stdout, stderr := s.ReadAllOutput()
+	s.mu.RLock()
+	limitHit := s.limitHit
+	s.mu.RUnlock()
 	return CommandResult{
-		Stdout:   stdout,
-		Stderr:   stderr,
-		ExitCode: int(s.exitCode.Load()),
+		Stdout:        stdout,
+		Stderr:        stderr,
+		ExitCode:      int(s.exitCode.Load()),
+		LimitExceeded: limitHit,
 	}, nil
 }
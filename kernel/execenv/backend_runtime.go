@@ -232,9 +232,15 @@ type runtimeView struct {
 	permissionMode    PermissionMode
 	requestedSandbox  string
 	baseSandboxPolicy SandboxPolicy
+	resourceLimits    ResourceLimits
 	diagnostics       SandboxDiagnostics
 	fs                FileSystem
 	backends          *backendSet
+	remote            *RemoteWorkspace
+
+	persistentShell bool
+	shellsMu        sync.Mutex
+	shells          map[string]*persistentShell
 }
 
 func newRuntimeView(cfg Config) (*runtimeView, error) {
@@ -242,6 +248,9 @@ func newRuntimeView(cfg Config) (*runtimeView, error) {
 	if err != nil {
 		return nil, err
 	}
+	if cfg.SSH != nil {
+		return newSSHRuntimeView(cfg, mode)
+	}
 
 	filesystem := cfg.FileSystem
 	if filesystem == nil {
@@ -279,9 +288,11 @@ func newRuntimeView(cfg Config) (*runtimeView, error) {
 		permissionMode:    mode,
 		requestedSandbox:  strings.TrimSpace(strings.ToLower(cfg.SandboxType)),
 		baseSandboxPolicy: cloneSandboxPolicy(cfg.SandboxPolicy),
+		resourceLimits:    cfg.ResourceLimits,
 		diagnostics:       diagnostics,
 		fs:                filesystem,
 		backends:          newBackendSet(hostBackend, sandboxBackend),
+		persistentShell:   cfg.PersistentShell,
 	}
 	rt.fs = newPolicyFileSystem(filesystem, rt.SandboxPolicy)
 	return rt, nil
@@ -307,6 +318,18 @@ func (r *runtimeView) SetPermissionMode(mode PermissionMode) error {
 	return nil
 }
 
+// AllowNetworkHosts adds hosts to the sandbox network allowlist. Commands
+// started afterwards reach them through the proxy; running commands keep the
+// list they started with.
+func (r *runtimeView) AllowNetworkHosts(hosts ...string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	r.baseSandboxPolicy.NetworkAllowlist = normalizeNetworkHosts(append(r.baseSandboxPolicy.NetworkAllowlist, hosts...))
+	r.mu.Unlock()
+}
+
 func (r *runtimeView) SandboxType() string {
 	state := r.State()
 	return cmp.Or(state.ResolvedSandbox, state.RequestedSandbox)
@@ -358,9 +381,9 @@ func (r *runtimeView) Decide(ctx context.Context, req RouteRequest) (CommandDeci
 	if sandboxBackend, ok := r.backends.DefaultSandbox(); ok {
 		sandboxName = sandboxBackend.Name()
 	}
-	hostName := hostBackendName
-	if hostBackend := r.backends.DefaultHost(); hostBackend != nil {
-		hostName = hostBackend.Name()
+	hostName := r.hostName()
+	if r.remote != nil {
+		return decideRemoteRoute(r.PermissionMode(), hostName, req.Command), nil
 	}
 	return decideRoute(r.PermissionMode(), r.Diagnostics(), hostName, sandboxName, req.Command, req.SandboxPermission), nil
 }
@@ -374,10 +397,11 @@ func (r *runtimeView) DecideRoute(command string, sandboxPermission SandboxPermi
 }
 
 func (r *runtimeView) Execute(ctx context.Context, req CommandRequest) (CommandResult, error) {
-	backend, _, err := r.resolveBackend(ctx, req)
+	backend, resolvedReq, err := r.resolveBackend(ctx, req)
 	if err != nil {
 		return CommandResult{}, err
 	}
+	req.Limits = resolvedReq.Limits
 	return backend.Execute(ctx, req)
 }
 
@@ -399,7 +423,7 @@ func (r *runtimeView) OpenSession(ref CommandSessionRef) (Session, error) {
 	}
 	backendName := strings.TrimSpace(ref.Backend)
 	if backendName == "" {
-		backendName = hostBackendName
+		backendName = r.hostName()
 	}
 	backend, ok := r.backends.Backend(backendName)
 	if !ok {
@@ -408,6 +432,15 @@ func (r *runtimeView) OpenSession(ref CommandSessionRef) (Session, error) {
 	return backend.OpenSession(sessionID)
 }
 
+// hostName returns the backend that runs unsandboxed commands: the
+// local host, or the remote machine of an SSH runtime.
+func (r *runtimeView) hostName() string {
+	if hostBackend := r.backends.DefaultHost(); hostBackend != nil {
+		return hostBackend.Name()
+	}
+	return hostBackendName
+}
+
 func (r *runtimeView) State() RuntimeState {
 	if r == nil {
 		return RuntimeState{}
@@ -424,6 +457,10 @@ func (r *runtimeView) State() RuntimeState {
 		Backends:         r.backends.Snapshot(),
 		RouterState:      RouterState{Diagnostics: diagnostics},
 	}
+	if r.remote != nil {
+		remote := *r.remote
+		state.Remote = &remote
+	}
 	switch {
 	case mode == PermissionModeFullControl:
 		state.SandboxStatus = SandboxStatusReady
@@ -442,6 +479,7 @@ func (r *runtimeView) Close() error {
 	if r == nil || r.backends == nil {
 		return nil
 	}
+	r.closeShells()
 	return r.backends.Close()
 }
 
@@ -466,7 +504,7 @@ func (r *runtimeView) resolveBackend(ctx context.Context, req CommandRequest) (B
 	if backendName == "" {
 		switch req.RouteHint {
 		case ExecutionRouteHost:
-			backendName = hostBackendName
+			backendName = r.hostName()
 		case ExecutionRouteSandbox:
 			if sandbox, ok := r.backends.DefaultSandbox(); ok {
 				backendName = sandbox.Name()
@@ -479,10 +517,16 @@ func (r *runtimeView) resolveBackend(ctx context.Context, req CommandRequest) (B
 	if !ok {
 		return nil, CommandRequest{}, fmt.Errorf("execenv: backend %q is unavailable", backendName)
 	}
+	if req.Overlay != nil {
+		if err := checkOverlayBackend(backend); err != nil {
+			return nil, CommandRequest{}, err
+		}
+	}
 	req.BackendName = backendName
+	req.Limits = MergeResourceLimits(r.resourceLimits, req.Limits)
 	if req.RouteHint == "" {
 		switch backend.Kind() {
-		case BackendKindHost:
+		case BackendKindHost, BackendKindSSH:
 			req.RouteHint = ExecutionRouteHost
 		case BackendKindSandbox:
 			req.RouteHint = ExecutionRouteSandbox
@@ -494,3 +538,31 @@ func (r *runtimeView) resolveBackend(ctx context.Context, req CommandRequest) (B
 	}
 	return backend, req, nil
 }
+
+// overlayRunner is implemented by sandbox runners that can mount an Overlay
+// over the workspace.
+type overlayRunner interface {
+	SupportsOverlay() bool
+}
+
+// checkOverlayBackend rejects backends that would let a staged command write
+// the workspace directly.
+func checkOverlayBackend(backend Backend) error {
+	if backend.Kind() != BackendKindSandbox {
+		return NewCodedError(
+			ErrorCodeStagedUnsupported,
+			"tool: staged mode runs commands only in the sandbox; on %s the command would change the workspace directly",
+			backend.Name(),
+		)
+	}
+	if one, ok := backend.(*commandBackend); ok {
+		if runner, ok := one.runner.(overlayRunner); ok && runner.SupportsOverlay() {
+			return nil
+		}
+	}
+	return NewCodedError(
+		ErrorCodeStagedUnsupported,
+		"tool: the %s sandbox cannot stage command writes; staged mode needs bubblewrap 0.8 or newer",
+		backend.Name(),
+	)
+}
@@ -12,6 +12,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 )
@@ -28,11 +29,13 @@ func (f bwrapSandboxFactory) Type() string {
 }
 
 func (f bwrapSandboxFactory) Build(cfg Config) (CommandRunner, error) {
-	return newBwrapRunner(deriveSandboxPolicy(PermissionModeDefault, cloneSandboxPolicy(cfg.SandboxPolicy))), nil
+	return newBwrapRunner(deriveSandboxPolicy(PermissionModeDefault, cloneSandboxPolicy(cfg.SandboxPolicy)), cfg.SandboxHelperPath), nil
 }
 
 type bwrapRunner struct {
 	execCommand    func(context.Context, string, ...string) *exec.Cmd
+	executablePath func() (string, error)
+	helperPath     string
 	lookPath       func(string) (string, error)
 	readFile       func(string) ([]byte, error)
 	stat           func(string) (os.FileInfo, error)
@@ -40,11 +43,16 @@ type bwrapRunner struct {
 	policy         SandboxPolicy
 	sessionManager *SessionManager
 	closed         atomic.Bool
+
+	overlayOnce sync.Once
+	overlayOK   bool
 }
 
-func newBwrapRunner(policy SandboxPolicy) CommandRunner {
+func newBwrapRunner(policy SandboxPolicy, helperPath string) CommandRunner {
 	return &bwrapRunner{
 		execCommand:    exec.CommandContext,
+		executablePath: os.Executable,
+		helperPath:     strings.TrimSpace(helperPath),
 		lookPath:       exec.LookPath,
 		readFile:       os.ReadFile,
 		stat:           os.Stat,
@@ -234,28 +242,37 @@ func (b *bwrapRunner) Run(ctx context.Context, req CommandRequest) (CommandResul
 		return CommandResult{}, fmt.Errorf("tool: resolve bwrap workdir failed: %w", err)
 	}
 	effectivePolicy := sandboxPolicyForCommand(b.policy, req)
-	bwrapArgs := buildBwrapArgs(effectivePolicy, workDir)
-
-	bwrapArgs = append(bwrapArgs, "--", "bash", "-lc", req.Command)
-	args := bwrapArgs
+	proxy, err := startCommandNetworkProxy(effectivePolicy, "unix", req)
+	if err != nil {
+		return CommandResult{}, fmt.Errorf("tool: bwrap sandbox network proxy failed: %w", err)
+	}
+	defer proxy.Close()
+	args, err := b.sandboxArgs(effectivePolicy, workDir, proxy, req.Overlay, req.Limits.shellCommand(req.Command))
+	if err != nil {
+		return CommandResult{}, err
+	}
 	cmd := b.execCommand(runCtx, "bwrap", args...)
 	applyNonInteractiveCommandDefaults(cmd)
 	if strings.TrimSpace(req.Dir) != "" {
 		cmd.Dir = req.Dir
 	}
-	cmd.Env = mergeCommandEnv(req.EnvOverrides)
+	cmd.Env = mergeCommandEnv(commandProxyEnv(req.EnvOverrides, proxy, sandboxProxyPort))
+	limiter := newCommandLimiter(req.Limits)
+	limiter.attach(cmd)
 
 	var stdout bytes.Buffer
 	var stderr bytes.Buffer
 	lastOutput := atomic.Int64{}
 	lastOutput.Store(time.Now().UnixNano())
-	cmd.Stdout = &activityWriter{buffer: &stdout, lastOutput: &lastOutput, stream: "stdout", onOutput: req.OnOutput}
-	cmd.Stderr = &activityWriter{buffer: &stderr, lastOutput: &lastOutput, stream: "stderr", onOutput: req.OnOutput}
+	cmd.Stdout = &activityWriter{buffer: &stdout, lastOutput: &lastOutput, stream: "stdout", onOutput: req.OnOutput, limiter: limiter}
+	cmd.Stderr = &activityWriter{buffer: &stderr, lastOutput: &lastOutput, stream: "stderr", onOutput: req.OnOutput, limiter: limiter}
 
 	if err := cmd.Start(); err != nil {
+		limiter.finish(nil)
 		return CommandResult{}, fmt.Errorf("tool: bwrap sandbox command start failed: %w", err)
 	}
 	waitErr := waitWithIdleTimeout(runCtx, cmd, req.IdleTimeout, &lastOutput)
+	limitHit := limiter.finish(waitErr)
 
 	result := CommandResult{
 		Stdout: stdout.String(),
@@ -265,6 +282,10 @@ func (b *bwrapRunner) Run(ctx context.Context, req CommandRequest) (CommandResul
 		return result, nil
 	}
 	result.ExitCode = resolveExitCode(waitErr)
+	if limitHit != "" {
+		result.LimitExceeded = limitHit
+		return result, resourceLimitError("bwrap sandbox command", limitHit, req.Limits, result)
+	}
 	if errors.Is(runCtx.Err(), context.DeadlineExceeded) || errors.Is(waitErr, context.DeadlineExceeded) {
 		label := "context deadline"
 		if req.Timeout > 0 {
@@ -306,17 +327,23 @@ func (b *bwrapRunner) StartAsync(_ context.Context, req CommandRequest) (string,
 		return "", fmt.Errorf("tool: resolve bwrap workdir failed: %w", err)
 	}
 	effectivePolicy := sandboxPolicyForCommand(b.policy, req)
+	proxy, err := startCommandNetworkProxy(effectivePolicy, "unix", req)
+	if err != nil {
+		return "", fmt.Errorf("tool: bwrap sandbox network proxy failed: %w", err)
+	}
 	session, err := manager.StartSession(AsyncSessionConfig{
 		Command:         req.Command,
 		Dir:             req.Dir,
-		Env:             mergeCommandEnv(req.EnvOverrides),
+		Env:             mergeCommandEnv(commandProxyEnv(req.EnvOverrides, proxy, sandboxProxyPort)),
 		OutputBufferCap: 256 * 1024,
 		Timeout:         req.Timeout,
 		IdleTimeout:     req.IdleTimeout,
+		Limits:          req.Limits,
 		BuildCommand: func(ctx context.Context, cfg AsyncSessionConfig) (*exec.Cmd, error) {
-			bwrapArgs := buildBwrapArgs(effectivePolicy, workDir)
-			bwrapArgs = append(bwrapArgs, "--", "bash", "-lc", cfg.Command)
-			args := bwrapArgs
+			args, err := b.sandboxArgs(effectivePolicy, workDir, proxy, req.Overlay, cfg.Command)
+			if err != nil {
+				return nil, err
+			}
 			cmd := b.execCommand(ctx, "bwrap", args...)
 			if strings.TrimSpace(cfg.Dir) != "" {
 				cmd.Dir = cfg.Dir
@@ -324,13 +351,86 @@ func (b *bwrapRunner) StartAsync(_ context.Context, req CommandRequest) (string,
 			cmd.Env = append([]string(nil), cfg.Env...)
 			return cmd, nil
 		},
+		OnExit: func() { _ = proxy.Close() },
 	})
 	if err != nil {
+		_ = proxy.Close()
 		return "", err
 	}
 	return session.ID, nil
 }
 
+// sandboxArgs returns the full bwrap argument list for one command. With a
+// network proxy the command runs under the sandbox helper, which bridges a
+// loopback port inside the sandbox to the proxy's unix socket.
+func (b *bwrapRunner) sandboxArgs(policy SandboxPolicy, workDir string, proxy *networkProxy, overlay *Overlay, command string) ([]string, error) {
+	if proxy == nil {
+		args := buildBwrapArgs(policy, workDir, overlay)
+		return append(args, "--", "bash", "-lc", command), nil
+	}
+	helperPath, err := b.resolveHelperPath()
+	if err != nil {
+		return nil, fmt.Errorf("tool: resolve bwrap helper path failed: %w", err)
+	}
+	if hasExplicitReadableRoots(policy) {
+		policy.ReadableRoots = append(policy.ReadableRoots, helperPath, filepath.Dir(proxy.SocketPath()))
+	}
+	args := buildBwrapArgs(policy, workDir, overlay)
+	return append(args, "--",
+		helperPath, internalHelperCommand,
+		"--proxy-socket", proxy.SocketPath(),
+		"--proxy-port", strconv.Itoa(sandboxProxyPort),
+		"--command", command,
+	), nil
+}
+
+// SupportsOverlay reports whether the installed bubblewrap can mount an
+// overlay, which --overlay needs version 0.8 or newer for.
+func (b *bwrapRunner) SupportsOverlay() bool {
+	b.overlayOnce.Do(func() {
+		if b.execCommand == nil {
+			return
+		}
+		output, err := b.execCommand(context.Background(), "bwrap", "--version").Output()
+		if err != nil {
+			return
+		}
+		b.overlayOK = bwrapVersionAtLeast(string(output), 0, 8)
+	})
+	return b.overlayOK
+}
+
+// bwrapVersionAtLeast parses "bubblewrap 0.8.0" style version output.
+func bwrapVersionAtLeast(output string, major, minor int) bool {
+	fields := strings.Fields(output)
+	if len(fields) == 0 {
+		return false
+	}
+	parts := strings.SplitN(fields[len(fields)-1], ".", 3)
+	if len(parts) < 2 {
+		return false
+	}
+	gotMajor, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return false
+	}
+	gotMinor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return false
+	}
+	return gotMajor > major || (gotMajor == major && gotMinor >= minor)
+}
+
+func (b *bwrapRunner) resolveHelperPath() (string, error) {
+	if path := strings.TrimSpace(b.helperPath); path != "" {
+		return path, nil
+	}
+	if b.executablePath == nil {
+		return os.Executable()
+	}
+	return b.executablePath()
+}
+
 func (b *bwrapRunner) WriteInput(sessionID string, input []byte) error {
 	manager, err := b.asyncSessionManager()
 	if err != nil {
@@ -411,8 +511,9 @@ func (b *bwrapRunner) asyncSessionManager() (*SessionManager, error) {
 // buildBwrapArgs constructs bubblewrap arguments from the sandbox policy.
 // All policy types are always wrapped in bwrap for consistent sandboxing.
 // DangerFull uses the same --ro-bind / / + scoped writable roots model
-// as other policies, matching seatbelt's behavior on macOS.
-func buildBwrapArgs(policy SandboxPolicy, workDir string) []string {
+// as other policies, matching seatbelt's behavior on macOS. A non-nil
+// overlay is mounted over its root so writes there are staged.
+func buildBwrapArgs(policy SandboxPolicy, workDir string, overlay *Overlay) []string {
 	args := []string{
 		"--new-session",
 		"--die-with-parent",
@@ -440,6 +541,11 @@ func buildBwrapArgs(policy SandboxPolicy, workDir string) []string {
 		for _, root := range bwrapWritableRoots(policy, workDir) {
 			args = append(args, "--bind", root, root)
 		}
+		// Before the read-only subpaths, which must stay read-only inside
+		// the overlay too.
+		if overlay != nil {
+			args = append(args, overlay.bwrapArgs()...)
+		}
 	}
 
 	// Read-only subpath overrides (applied after writable binds)
@@ -395,7 +395,7 @@ func TestBuildBwrapArgsWorkspaceWrite(t *testing.T) {
 		NetworkAccess:    true,
 		WritableRoots:    []string{tmpDir},
 		ReadOnlySubpaths: []string{gitDir},
-	}, tmpDir)
+	}, tmpDir, nil)
 
 	joined := strings.Join(args, " ")
 	if !strings.Contains(joined, "--ro-bind / /") {
@@ -428,7 +428,7 @@ func TestBuildBwrapArgs_ExplicitReadableRootsUsesScopedRootfs(t *testing.T) {
 		ReadableRoots:    []string{"."},
 		WritableRoots:    []string{"."},
 		ReadOnlySubpaths: []string{".git"},
-	}, workDir)
+	}, workDir, nil)
 
 	joined := strings.Join(args, " ")
 	if strings.Contains(joined, "--ro-bind / /") {
@@ -452,7 +452,7 @@ func TestBuildBwrapArgsDangerFullNetwork(t *testing.T) {
 	args := buildBwrapArgs(SandboxPolicy{
 		Type:          SandboxPolicyDangerFull,
 		NetworkAccess: true,
-	}, "/tmp")
+	}, "/tmp", nil)
 	if args == nil {
 		t.Fatal("expected non-nil args for DangerFull+network (always wrapped)")
 	}
@@ -470,7 +470,7 @@ func TestBuildBwrapArgsDangerFullNoNetwork(t *testing.T) {
 	args := buildBwrapArgs(SandboxPolicy{
 		Type:          SandboxPolicyDangerFull,
 		NetworkAccess: false,
-	}, "/tmp")
+	}, "/tmp", nil)
 	joined := strings.Join(args, " ")
 	if !strings.Contains(joined, "--ro-bind / /") {
 		t.Fatalf("expected --ro-bind / / for DangerFull no-network, got %q", joined)
@@ -487,7 +487,7 @@ func TestBuildBwrapArgsDangerFullGetsScopedWritableRoots(t *testing.T) {
 		Type:          SandboxPolicyDangerFull,
 		NetworkAccess: true,
 		WritableRoots: nil,
-	}, "/tmp")
+	}, "/tmp", nil)
 	joined := strings.Join(args, " ")
 	// Must have --ro-bind / / (not --bind / /)
 	if !strings.Contains(joined, "--ro-bind / /") {
@@ -654,3 +654,73 @@ func TestBwrapReadOnlySubpathsSkipsMissingPaths(t *testing.T) {
 		t.Fatalf("expected no read-only subpaths for nonexistent dirs, got %v", subs)
 	}
 }
+
+func TestBwrapRunner_RunWithNetworkAllowlistUsesProxyHelper(t *testing.T) {
+	var capturedArgs []string
+	r := &bwrapRunner{
+		goos:       "linux",
+		helperPath: "/custom/helper",
+		policy: SandboxPolicy{
+			Type:             SandboxPolicyWorkspaceWrite,
+			NetworkAllowlist: []string{"proxy.golang.org"},
+		},
+		execCommand: func(_ context.Context, _ string, args ...string) *exec.Cmd {
+			capturedArgs = append([]string(nil), args...)
+			return exec.Command("bash", "-lc", "echo ok")
+		},
+	}
+	if _, err := r.Run(context.Background(), CommandRequest{Command: "go mod download"}); err != nil {
+		t.Fatal(err)
+	}
+	joined := strings.Join(capturedArgs, " ")
+	for _, want := range []string{"--unshare-net", "-- /custom/helper " + internalHelperCommand, "--proxy-socket ", "--proxy-port 3128", "--command go mod download"} {
+		if !strings.Contains(joined, want) {
+			t.Fatalf("expected %q in bwrap args: %q", want, joined)
+		}
+	}
+}
+
+func TestBuildBwrapArgs_OverlayMountsBeforeReadOnlySubpaths(t *testing.T) {
+	workDir := t.TempDir()
+	gitDir := filepath.Join(workDir, ".git")
+	if err := os.Mkdir(gitDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	overlay, err := NewOverlay(workDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer overlay.Close()
+
+	joined := strings.Join(buildBwrapArgs(SandboxPolicy{
+		Type:             SandboxPolicyWorkspaceWrite,
+		WritableRoots:    []string{workDir},
+		ReadOnlySubpaths: []string{gitDir},
+	}, workDir, overlay), " ")
+	mount := "--overlay-src " + workDir + " --overlay " + overlay.upperDir() + " " + overlay.workDir() + " " + workDir
+	mountAt := strings.Index(joined, mount)
+	if mountAt < 0 {
+		t.Fatalf("expected overlay mount %q in args, got %q", mount, joined)
+	}
+	if gitAt := strings.Index(joined, "--ro-bind "+gitDir); gitAt < mountAt {
+		t.Fatalf("expected .git to stay read-only over the overlay, got %q", joined)
+	}
+	if !strings.Contains(joined, "--tmpfs "+overlay.dir) {
+		t.Fatalf("expected the staging directory hidden, got %q", joined)
+	}
+}
+
+func TestBwrapVersionAtLeast(t *testing.T) {
+	for output, want := range map[string]bool{
+		"bubblewrap 0.8.0\n": true,
+		"bubblewrap 0.11.1":  true,
+		"bubblewrap 1.0":     true,
+		"bubblewrap 0.6.2":   false,
+		"bubblewrap":         false,
+		"":                   false,
+	} {
+		if got := bwrapVersionAtLeast(output, 0, 8); got != want {
+			t.Fatalf("bwrapVersionAtLeast(%q) = %v, want %v", output, got, want)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+//go:build linux
+
+package execenv
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const cgroupMountPoint = "/sys/fs/cgroup"
+
+// commandCgroup is a cgroup v2 child of the caller's own cgroup that holds
+// one command, with memory.max and pids.max set from its limits.
+type commandCgroup struct {
+	dir string
+	fd  int
+}
+
+// newCommandCgroup creates the cgroup for one command. It returns nil when no
+// memory or process limit is set, cgroup v2 is not mounted, or the caller's
+// cgroup does not hand the needed controllers down to children, which is the
+// usual case outside containers and delegated systemd scopes.
+func newCommandCgroup(limits ResourceLimits) *commandCgroup {
+	if limits.MemoryBytes <= 0 && limits.MaxProcesses <= 0 {
+		return nil
+	}
+	parent, ok := ownCgroupDir()
+	if !ok {
+		return nil
+	}
+	controllers, err := os.ReadFile(filepath.Join(parent, "cgroup.subtree_control"))
+	if err != nil {
+		return nil
+	}
+	enabled := strings.Fields(string(controllers))
+	if limits.MemoryBytes > 0 && !slices.Contains(enabled, "memory") {
+		return nil
+	}
+	if limits.MaxProcesses > 0 && !slices.Contains(enabled, "pids") {
+		return nil
+	}
+	dir, err := os.MkdirTemp(parent, "caelis-cmd-")
+	if err != nil {
+		return nil
+	}
+	cgroup := &commandCgroup{dir: dir, fd: -1}
+	if limits.MemoryBytes > 0 {
+		if err := cgroup.write("memory.max", strconv.FormatInt(limits.MemoryBytes, 10)); err != nil {
+			cgroup.Close()
+			return nil
+		}
+		_ = cgroup.write("memory.swap.max", "0")
+	}
+	if limits.MaxProcesses > 0 {
+		if err := cgroup.write("pids.max", strconv.Itoa(limits.MaxProcesses)); err != nil {
+			cgroup.Close()
+			return nil
+		}
+	}
+	fd, err := syscall.Open(dir, syscall.O_RDONLY|syscall.O_DIRECTORY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		cgroup.Close()
+		return nil
+	}
+	cgroup.fd = fd
+	return cgroup
+}
+
+// ownCgroupDir returns the cgroup v2 directory of the current process.
+func ownCgroupDir() (string, bool) {
+	data, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return "", false
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		path, ok := strings.CutPrefix(scanner.Text(), "0::")
+		if !ok {
+			continue
+		}
+		dir := filepath.Join(cgroupMountPoint, filepath.Clean("/"+path))
+		if _, err := os.Stat(filepath.Join(dir, "cgroup.controllers")); err != nil {
+			return "", false
+		}
+		return dir, true
+	}
+	return "", false
+}
+
+// attach starts cmd directly inside the cgroup.
+func (c *commandCgroup) attach(cmd *exec.Cmd) {
+	if c == nil || cmd == nil || c.fd < 0 {
+		return
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = c.fd
+}
+
+// exceeded returns the limit the kernel enforced against the command, or "".
+func (c *commandCgroup) exceeded() string {
+	if c == nil {
+		return ""
+	}
+	if cgroupEventCount(filepath.Join(c.dir, "memory.events"), "oom_kill") > 0 {
+		return ResourceLimitMemory
+	}
+	if cgroupEventCount(filepath.Join(c.dir, "pids.events"), "max") > 0 {
+		return ResourceLimitProcesses
+	}
+	return ""
+}
+
+// Close kills anything left in the cgroup and removes it.
+func (c *commandCgroup) Close() {
+	if c == nil {
+		return
+	}
+	if c.fd >= 0 {
+		_ = syscall.Close(c.fd)
+		c.fd = -1
+	}
+	_ = c.write("cgroup.kill", "1")
+	for attempt := 0; attempt < 10; attempt++ {
+		if err := os.Remove(c.dir); err == nil || os.IsNotExist(err) {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func (c *commandCgroup) write(name string, value string) error {
+	return os.WriteFile(filepath.Join(c.dir, name), []byte(value), 0o644)
+}
+
+func cgroupEventCount(path string, key string) int64 {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == key {
+			count, _ := strconv.ParseInt(fields[1], 10, 64)
+			return count
+		}
+	}
+	return 0
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+
+package execenv
+
+import "os/exec"
+
+// commandCgroup is only available on linux.
+type commandCgroup struct{}
+
+func newCommandCgroup(ResourceLimits) *commandCgroup { return nil }
+
+func (c *commandCgroup) attach(*exec.Cmd) {}
+
+func (c *commandCgroup) exceeded() string { return "" }
+
+func (c *commandCgroup) Close() {}
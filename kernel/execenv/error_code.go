@@ -19,6 +19,9 @@ const (
 	ErrorCodeSandboxIdleTimeout    ErrorCode = "ERR_SANDBOX_IDLE_TIMEOUT"
 	ErrorCodeHostCommandTimeout    ErrorCode = "ERR_HOST_COMMAND_TIMEOUT"
 	ErrorCodeHostIdleTimeout       ErrorCode = "ERR_HOST_IDLE_TIMEOUT"
+	ErrorCodeBudgetExhausted       ErrorCode = "ERR_BUDGET_EXHAUSTED"
+	ErrorCodeResourceLimit         ErrorCode = "ERR_RESOURCE_LIMIT"
+	ErrorCodeStagedUnsupported     ErrorCode = "ERR_STAGED_UNSUPPORTED"
 )
 
 // CodedError exposes a stable code for programmatic handling.
@@ -121,7 +121,7 @@ func (h *hostRunner) Run(ctx context.Context, req CommandRequest) (CommandResult
 	}
 	defer cancel()
 
-	cmd, err := buildAsyncSessionCommand(runCtx, req.Command, req.TTY)
+	cmd, err := buildAsyncSessionCommand(runCtx, req.Limits.shellCommand(req.Command), req.TTY)
 	if err != nil {
 		return CommandResult{}, err
 	}
@@ -130,13 +130,16 @@ func (h *hostRunner) Run(ctx context.Context, req CommandRequest) (CommandResult
 		cmd.Dir = req.Dir
 	}
 	cmd.Env = mergeCommandEnv(req.EnvOverrides)
+	limiter := newCommandLimiter(req.Limits)
+	limiter.attach(cmd)
 	var stdout bytes.Buffer
 	var stderr bytes.Buffer
 	lastOutput := atomic.Int64{}
 	lastOutput.Store(time.Now().UnixNano())
-	cmd.Stdout = &activityWriter{buffer: &stdout, lastOutput: &lastOutput, stream: "stdout", onOutput: req.OnOutput}
-	cmd.Stderr = &activityWriter{buffer: &stderr, lastOutput: &lastOutput, stream: "stderr", onOutput: req.OnOutput}
+	cmd.Stdout = &activityWriter{buffer: &stdout, lastOutput: &lastOutput, stream: "stdout", onOutput: req.OnOutput, limiter: limiter}
+	cmd.Stderr = &activityWriter{buffer: &stderr, lastOutput: &lastOutput, stream: "stderr", onOutput: req.OnOutput, limiter: limiter}
 	if err := cmd.Start(); err != nil {
+		limiter.finish(nil)
 		return CommandResult{}, fmt.Errorf("tool: command start failed: %w", err)
 	}
 
@@ -147,6 +150,7 @@ func (h *hostRunner) Run(ctx context.Context, req CommandRequest) (CommandResult
 	} else {
 		runErr = waitWithIdleTimeout(runCtx, cmd, req.IdleTimeout, &lastOutput)
 	}
+	limitHit := limiter.finish(runErr)
 
 	result := CommandResult{
 		Stdout: stdout.String(),
@@ -156,12 +160,22 @@ func (h *hostRunner) Run(ctx context.Context, req CommandRequest) (CommandResult
 		return result, nil
 	}
 	result.ExitCode = resolveExitCode(runErr)
+	result.LimitExceeded = limitHit
+	return result, commandRunError(runCtx, runErr, req, result)
+}
+
+// commandRunError describes why a command run by a runner failed. runCtx is
+// the context the command ran under and runErr the error it ended with.
+func commandRunError(runCtx context.Context, runErr error, req CommandRequest, result CommandResult) error {
+	if result.LimitExceeded != "" {
+		return resourceLimitError("command", result.LimitExceeded, req.Limits, result)
+	}
 	if errors.Is(runCtx.Err(), context.DeadlineExceeded) || errors.Is(runErr, context.DeadlineExceeded) {
 		label := "context deadline"
 		if req.Timeout > 0 {
 			label = req.Timeout.String()
 		}
-		return result, WrapCodedError(
+		return WrapCodedError(
 			ErrorCodeHostCommandTimeout,
 			runErr,
 			"tool: command timed out after %s; %s",
@@ -174,7 +188,7 @@ func (h *hostRunner) Run(ctx context.Context, req CommandRequest) (CommandResult
 		if req.IdleTimeout > 0 {
 			label = req.IdleTimeout.String()
 		}
-		return result, NewCodedError(
+		return NewCodedError(
 			ErrorCodeHostIdleTimeout,
 			"tool: command produced no output for %s and was terminated (likely interactive or long-running); %s",
 			label,
@@ -182,13 +196,13 @@ func (h *hostRunner) Run(ctx context.Context, req CommandRequest) (CommandResult
 		)
 	}
 	if errors.Is(runErr, errInteractivePrompt) {
-		return result, NewCodedError(
+		return NewCodedError(
 			ErrorCodeHostIdleTimeout,
 			"tool: command appears to be waiting for interactive input and was terminated; %s",
 			commandOutputSummary(result),
 		)
 	}
-	return result, fmt.Errorf("tool: command failed: %w; %s", runErr, commandOutputSummary(result))
+	return fmt.Errorf("tool: command failed: %w; %s", runErr, commandOutputSummary(result))
 }
 
 var errInteractivePrompt = errors.New("interactive prompt detected")
@@ -290,6 +304,7 @@ func (h *hostRunner) StartAsync(_ context.Context, req CommandRequest) (string,
 		Timeout:         req.Timeout,
 		IdleTimeout:     req.IdleTimeout,
 		TTY:             req.TTY,
+		Limits:          req.Limits,
 	})
 	if err != nil {
 		return "", err
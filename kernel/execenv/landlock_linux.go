@@ -14,6 +14,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	stdruntime "runtime"
+	"strconv"
 	"strings"
 	"sync/atomic"
 	"time"
@@ -22,8 +23,6 @@ import (
 	"golang.org/x/sys/unix"
 )
 
-const internalHelperCommand = "__caelis_execenv_helper__"
-
 type landlockSandboxFactory struct{}
 
 func (f landlockSandboxFactory) Type() string {
@@ -90,26 +89,36 @@ func (l *landlockRunner) Run(ctx context.Context, req CommandRequest) (CommandRe
 	if err != nil {
 		return CommandResult{}, fmt.Errorf("tool: resolve landlock helper path failed: %w", err)
 	}
-	helperArgs, err := buildLandlockHelperArgs(effectivePolicy, policyCWD, policyCWD, req.Command)
+	helperArgs, err := buildLandlockHelperArgs(effectivePolicy, policyCWD, policyCWD, req.Limits.shellCommand(req.Command))
 	if err != nil {
 		return CommandResult{}, fmt.Errorf("tool: build landlock helper args failed: %w", err)
 	}
+	proxy, err := startCommandNetworkProxy(effectivePolicy, "tcp", req)
+	if err != nil {
+		return CommandResult{}, fmt.Errorf("tool: landlock sandbox network proxy failed: %w", err)
+	}
+	defer proxy.Close()
+	helperArgs = append(helperArgs, landlockProxyArgs(proxy)...)
 
 	cmd := l.execCommand(runCtx, exePath, helperArgs...)
 	applyNonInteractiveCommandDefaults(cmd)
-	cmd.Env = mergeCommandEnv(req.EnvOverrides)
+	cmd.Env = mergeCommandEnv(commandProxyEnv(req.EnvOverrides, proxy, proxy.Port()))
+	limiter := newCommandLimiter(req.Limits)
+	limiter.attach(cmd)
 
 	var stdout bytes.Buffer
 	var stderr bytes.Buffer
 	lastOutput := atomic.Int64{}
 	lastOutput.Store(time.Now().UnixNano())
-	cmd.Stdout = &activityWriter{buffer: &stdout, lastOutput: &lastOutput, stream: "stdout", onOutput: req.OnOutput}
-	cmd.Stderr = &activityWriter{buffer: &stderr, lastOutput: &lastOutput, stream: "stderr", onOutput: req.OnOutput}
+	cmd.Stdout = &activityWriter{buffer: &stdout, lastOutput: &lastOutput, stream: "stdout", onOutput: req.OnOutput, limiter: limiter}
+	cmd.Stderr = &activityWriter{buffer: &stderr, lastOutput: &lastOutput, stream: "stderr", onOutput: req.OnOutput, limiter: limiter}
 
 	if err := cmd.Start(); err != nil {
+		limiter.finish(nil)
 		return CommandResult{}, fmt.Errorf("tool: landlock sandbox command start failed: %w", err)
 	}
 	waitErr := waitWithIdleTimeout(runCtx, cmd, req.IdleTimeout, &lastOutput)
+	limitHit := limiter.finish(waitErr)
 
 	result := CommandResult{
 		Stdout: stdout.String(),
@@ -119,6 +128,10 @@ func (l *landlockRunner) Run(ctx context.Context, req CommandRequest) (CommandRe
 		return result, nil
 	}
 	result.ExitCode = resolveExitCode(waitErr)
+	if limitHit != "" {
+		result.LimitExceeded = limitHit
+		return result, resourceLimitError("landlock sandbox command", limitHit, req.Limits, result)
+	}
 	if errors.Is(runCtx.Err(), context.DeadlineExceeded) || errors.Is(waitErr, context.DeadlineExceeded) {
 		label := "context deadline"
 		if req.Timeout > 0 {
@@ -164,24 +177,32 @@ func (l *landlockRunner) StartAsync(_ context.Context, req CommandRequest) (stri
 	if err != nil {
 		return "", fmt.Errorf("tool: resolve landlock helper path failed: %w", err)
 	}
+	proxy, err := startCommandNetworkProxy(effectivePolicy, "tcp", req)
+	if err != nil {
+		return "", fmt.Errorf("tool: landlock sandbox network proxy failed: %w", err)
+	}
 	session, err := manager.StartSession(AsyncSessionConfig{
 		Command:         req.Command,
 		Dir:             req.Dir,
-		Env:             mergeCommandEnv(req.EnvOverrides),
+		Env:             mergeCommandEnv(commandProxyEnv(req.EnvOverrides, proxy, proxy.Port())),
 		OutputBufferCap: 256 * 1024,
 		Timeout:         req.Timeout,
 		IdleTimeout:     req.IdleTimeout,
+		Limits:          req.Limits,
 		BuildCommand: func(ctx context.Context, cfg AsyncSessionConfig) (*exec.Cmd, error) {
 			helperArgs, err := buildLandlockHelperArgs(effectivePolicy, policyCWD, policyCWD, cfg.Command)
 			if err != nil {
 				return nil, err
 			}
+			helperArgs = append(helperArgs, landlockProxyArgs(proxy)...)
 			cmd := l.execCommand(ctx, exePath, helperArgs...)
 			cmd.Env = append([]string(nil), cfg.Env...)
 			return cmd, nil
 		},
+		OnExit: func() { _ = proxy.Close() },
 	})
 	if err != nil {
+		_ = proxy.Close()
 		return "", err
 	}
 	return session.ID, nil
@@ -279,6 +300,15 @@ func buildLandlockHelperArgs(policy SandboxPolicy, policyCWD, commandCWD, comman
 	return args, nil
 }
 
+// landlockProxyArgs tells the helper which loopback port the network proxy
+// listens on, so it can limit TCP connects to that port.
+func landlockProxyArgs(proxy *networkProxy) []string {
+	if proxy == nil {
+		return nil
+	}
+	return []string{"--proxy-port", strconv.Itoa(proxy.Port())}
+}
+
 func (l *landlockRunner) resolveHelperPath() (string, error) {
 	exePath := strings.TrimSpace(l.helperPath)
 	if exePath != "" {
@@ -337,11 +367,13 @@ func MaybeRunInternalHelper(args []string) bool {
 }
 
 type internalHelperConfig struct {
-	Probe      bool
-	PolicyJSON string
-	PolicyCWD  string
-	CommandCWD string
-	Command    string
+	Probe       bool
+	PolicyJSON  string
+	PolicyCWD   string
+	CommandCWD  string
+	Command     string
+	ProxySocket string
+	ProxyPort   int
 }
 
 func runInternalHelper(args []string) error {
@@ -356,12 +388,17 @@ func runInternalHelper(args []string) error {
 	fs.StringVar(&cfg.PolicyCWD, "policy-cwd", "", "sandbox policy cwd")
 	fs.StringVar(&cfg.CommandCWD, "command-cwd", "", "command cwd")
 	fs.StringVar(&cfg.Command, "command", "", "command to execute")
+	fs.StringVar(&cfg.ProxySocket, "proxy-socket", "", "network proxy unix socket to bridge")
+	fs.IntVar(&cfg.ProxyPort, "proxy-port", 0, "network proxy loopback port")
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
 	if cfg.Probe {
 		return nil
 	}
+	if strings.TrimSpace(cfg.ProxySocket) != "" {
+		return runProxyBridgedCommand(cfg)
+	}
 	if strings.TrimSpace(cfg.PolicyJSON) == "" {
 		return errors.New("missing --policy-json")
 	}
@@ -380,7 +417,15 @@ func runInternalHelper(args []string) error {
 			return fmt.Errorf("set no_new_privs: %w", err)
 		}
 	}
-	if !policy.NetworkAccess {
+	switch {
+	case policy.NetworkProxied() && cfg.ProxyPort > 0:
+		if err := applyLandlockProxyNetworkPolicy(cfg.ProxyPort); err != nil {
+			return err
+		}
+		if err := installProxiedNetworkSeccomp(); err != nil {
+			return fmt.Errorf("install seccomp: %w", err)
+		}
+	case !policy.NetworkAccess:
 		if err := installRestrictedNetworkSeccomp(); err != nil {
 			return fmt.Errorf("install seccomp: %w", err)
 		}
@@ -512,6 +557,58 @@ func landlockAddPathRule(rulesetFD int, path string, access uint64) error {
 	return nil
 }
 
+// landlockRuleNetPort and landlockNetPortAttr mirror the kernel's
+// LANDLOCK_RULE_NET_PORT and struct landlock_net_port_attr, which
+// golang.org/x/sys does not define yet.
+const landlockRuleNetPort = 2
+
+type landlockNetPortAttr struct {
+	AllowedAccess uint64
+	Port          uint64
+}
+
+// applyLandlockProxyNetworkPolicy adds a landlock layer that allows TCP
+// connects only to the proxy port and no TCP binds. Network rules need
+// landlock ABI 4 (linux 6.7).
+func applyLandlockProxyNetworkPolicy(proxyPort int) error {
+	abi, err := landlockABI()
+	if err != nil {
+		return err
+	}
+	if abi < 4 {
+		return fmt.Errorf("landlock ABI %d cannot restrict network to the proxy; proxied network needs linux 6.7 or newer", abi)
+	}
+	attr := unix.LandlockRulesetAttr{
+		Access_net: unix.LANDLOCK_ACCESS_NET_BIND_TCP | unix.LANDLOCK_ACCESS_NET_CONNECT_TCP,
+	}
+	rulesetFD, err := landlockCreateRuleset(&attr, 0)
+	if err != nil {
+		return fmt.Errorf("create landlock network ruleset: %w", err)
+	}
+	defer unix.Close(rulesetFD)
+
+	rule := landlockNetPortAttr{
+		AllowedAccess: unix.LANDLOCK_ACCESS_NET_CONNECT_TCP,
+		Port:          uint64(proxyPort),
+	}
+	_, _, errno := unix.Syscall6(
+		unix.SYS_LANDLOCK_ADD_RULE,
+		uintptr(rulesetFD),
+		uintptr(landlockRuleNetPort),
+		uintptr(unsafe.Pointer(&rule)),
+		0,
+		0,
+		0,
+	)
+	if errno != 0 {
+		return fmt.Errorf("allow proxy port %d: %w", proxyPort, errno)
+	}
+	if err := landlockRestrictSelf(rulesetFD); err != nil {
+		return fmt.Errorf("restrict self with landlock: %w", err)
+	}
+	return nil
+}
+
 func landlockRestrictSelf(rulesetFD int) error {
 	_, _, errno := unix.Syscall(unix.SYS_LANDLOCK_RESTRICT_SELF, uintptr(rulesetFD), 0, 0)
 	if errno != 0 {
@@ -574,6 +671,69 @@ func installRestrictedNetworkSeccomp() error {
 	return nil
 }
 
+func installProxiedNetworkSeccomp() error {
+	prog, err := buildProxiedNetworkSeccompProgram()
+	if err != nil {
+		return err
+	}
+	if err := unix.Prctl(unix.PR_SET_SECCOMP, unix.SECCOMP_MODE_FILTER, uintptr(unsafe.Pointer(&prog)), 0, 0); err != nil {
+		return err
+	}
+	return nil
+}
+
+// buildProxiedNetworkSeccompProgram allows unix sockets and TCP stream
+// sockets, whose connects landlock limits to the proxy port. UDP and raw
+// sockets stay blocked so DNS and other datagrams cannot bypass the proxy,
+// and accepting connections stays blocked as in the restricted program.
+func buildProxiedNetworkSeccompProgram() (unix.SockFprog, error) {
+	deny := uint32(unix.SECCOMP_RET_ERRNO | (unix.EPERM & unix.SECCOMP_RET_DATA))
+	allow := uint32(unix.SECCOMP_RET_ALLOW)
+	kill := uint32(unix.SECCOMP_RET_KILL_PROCESS)
+
+	filters := []unix.SockFilter{
+		bpfStmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, seccompDataOffsetArch),
+		bpfJump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, seccompAuditArch(), 1, 0),
+		bpfStmt(unix.BPF_RET|unix.BPF_K, kill),
+		bpfStmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, seccompDataOffsetNR),
+	}
+
+	for _, nr := range []int{
+		unix.SYS_ACCEPT,
+		unix.SYS_ACCEPT4,
+		unix.SYS_LISTEN,
+	} {
+		filters = append(filters,
+			bpfJump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, uint32(nr), 0, 1),
+			bpfStmt(unix.BPF_RET|unix.BPF_K, deny),
+		)
+	}
+
+	filters = append(filters,
+		bpfJump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, uint32(unix.SYS_SOCKET), 0, 9),
+		bpfStmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, seccompDataOffsetArg0),
+		bpfJump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, unix.AF_UNIX, 6, 0),
+		bpfJump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, unix.AF_INET, 1, 0),
+		bpfJump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, unix.AF_INET6, 0, 3),
+		bpfStmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, seccompDataOffsetArg1),
+		bpfStmt(unix.BPF_ALU|unix.BPF_AND|unix.BPF_K, 0xf),
+		bpfJump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, unix.SOCK_STREAM, 1, 0),
+		bpfStmt(unix.BPF_RET|unix.BPF_K, deny),
+		bpfStmt(unix.BPF_RET|unix.BPF_K, allow),
+		bpfJump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, uint32(unix.SYS_SOCKETPAIR), 0, 4),
+		bpfStmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, seccompDataOffsetArg0),
+		bpfJump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, unix.AF_UNIX, 1, 0),
+		bpfStmt(unix.BPF_RET|unix.BPF_K, deny),
+		bpfStmt(unix.BPF_RET|unix.BPF_K, allow),
+		bpfStmt(unix.BPF_RET|unix.BPF_K, allow),
+	)
+
+	return unix.SockFprog{
+		Len:    uint16(len(filters)),
+		Filter: &filters[0],
+	}, nil
+}
+
 func buildRestrictedNetworkSeccompProgram() (unix.SockFprog, error) {
 	deny := uint32(unix.SECCOMP_RET_ERRNO | (unix.EPERM & unix.SECCOMP_RET_DATA))
 	allow := uint32(unix.SECCOMP_RET_ALLOW)
@@ -650,6 +810,7 @@ const (
 	seccompDataOffsetNR   = 0
 	seccompDataOffsetArch = 4
 	seccompDataOffsetArg0 = 16
+	seccompDataOffsetArg1 = 24
 )
 
 func init() {
@@ -8,6 +8,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
 
@@ -200,3 +201,28 @@ func TestScratchReadableRootsIncludeHomeCache(t *testing.T) {
 		t.Fatalf("expected scratch roots to include %q", cacheRoot)
 	}
 }
+
+func TestLandlockProxyArgs(t *testing.T) {
+	if args := landlockProxyArgs(nil); args != nil {
+		t.Fatalf("expected no args without a proxy, got %v", args)
+	}
+	proxy, err := startNetworkProxy("tcp", []string{"example.com"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proxy.Close()
+	args := landlockProxyArgs(proxy)
+	if len(args) != 2 || args[0] != "--proxy-port" || args[1] != strconv.Itoa(proxy.Port()) {
+		t.Fatalf("unexpected proxy args %v", args)
+	}
+}
+
+func TestBuildProxiedNetworkSeccompProgram(t *testing.T) {
+	prog, err := buildProxiedNetworkSeccompProgram()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prog.Len == 0 || prog.Filter == nil {
+		t.Fatalf("expected a non-empty seccomp program, got %+v", prog)
+	}
+}
@@ -2,4 +2,7 @@ package execenv
 
 const (
 	landlockSandboxType = "landlock"
+	// internalHelperCommand is the first argument that switches the caelis
+	// binary (or caelis-sandbox-helper) into sandbox helper mode.
+	internalHelperCommand = "__caelis_execenv_helper__"
 )
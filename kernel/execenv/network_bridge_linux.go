@@ -0,0 +1,83 @@
+//go:build linux
+
+package execenv
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+// runProxyBridgedCommand runs inside a bwrap sandbox without network. It
+// forwards a loopback port to the network proxy's unix socket, which bwrap
+// exposes from the host, then runs the command and exits with its status.
+func runProxyBridgedCommand(cfg internalHelperConfig) error {
+	if strings.TrimSpace(cfg.Command) == "" {
+		return errors.New("missing --command")
+	}
+	if cfg.ProxyPort <= 0 {
+		return errors.New("missing --proxy-port")
+	}
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", cfg.ProxyPort))
+	if err != nil {
+		return fmt.Errorf("listen on proxy port: %w", err)
+	}
+	defer listener.Close()
+	go bridgeProxyConnections(listener, cfg.ProxySocket)
+
+	cmd := exec.Command("bash", "-lc", cfg.Command)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("start command: %w", err)
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	go func() {
+		for sig := range signals {
+			_ = cmd.Process.Signal(sig)
+		}
+	}()
+	err = cmd.Wait()
+	signal.Stop(signals)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		os.Exit(exitErr.ExitCode())
+	}
+	return err
+}
+
+func bridgeProxyConnections(listener net.Listener, socketPath string) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			upstream, err := net.Dial("unix", socketPath)
+			if err != nil {
+				return
+			}
+			defer upstream.Close()
+			done := make(chan struct{})
+			go func() {
+				_, _ = io.Copy(upstream, conn)
+				if unixConn, ok := upstream.(*net.UnixConn); ok {
+					_ = unixConn.CloseWrite()
+				}
+				close(done)
+			}()
+			_, _ = io.Copy(conn, upstream)
+			_ = conn.Close()
+			<-done
+		}()
+	}
+}
@@ -0,0 +1,288 @@
+package execenv
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// sandboxProxyPort is the loopback port the bwrap bridge listens on inside
+	// the sandbox network namespace.
+	sandboxProxyPort   = 3128
+	networkProxyDialTO = 30 * time.Second
+)
+
+// networkProxy is a filtering HTTP proxy that lets sandboxed commands reach
+// only allowlisted hosts. It handles CONNECT tunnels and plain HTTP requests
+// in absolute form. One proxy serves one command.
+type networkProxy struct {
+	listener  net.Listener
+	allowlist []string
+	onDenied  func(host string)
+	socketDir string
+
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// startNetworkProxy listens on a random loopback TCP port, or on a unix socket
+// in a fresh temp directory when network is "unix".
+func startNetworkProxy(network string, allowlist []string, onDenied func(string)) (*networkProxy, error) {
+	proxy := &networkProxy{
+		allowlist: normalizeNetworkHosts(allowlist),
+		onDenied:  onDenied,
+	}
+	var (
+		listener net.Listener
+		err      error
+	)
+	switch network {
+	case "unix":
+		proxy.socketDir, err = os.MkdirTemp("", "caelis-netproxy-")
+		if err != nil {
+			return nil, fmt.Errorf("execenv: create network proxy dir: %w", err)
+		}
+		listener, err = net.Listen("unix", filepath.Join(proxy.socketDir, "proxy.sock"))
+	default:
+		listener, err = net.Listen("tcp", "127.0.0.1:0")
+	}
+	if err != nil {
+		if proxy.socketDir != "" {
+			_ = os.RemoveAll(proxy.socketDir)
+		}
+		return nil, fmt.Errorf("execenv: start network proxy: %w", err)
+	}
+	proxy.listener = listener
+	proxy.wg.Add(1)
+	go proxy.serve()
+	return proxy, nil
+}
+
+// SocketPath returns the unix socket path, or "" for a TCP proxy.
+func (p *networkProxy) SocketPath() string {
+	if p == nil || p.socketDir == "" {
+		return ""
+	}
+	return p.listener.Addr().String()
+}
+
+// Port returns the loopback TCP port, or 0 for a unix socket proxy.
+func (p *networkProxy) Port() int {
+	if p == nil {
+		return 0
+	}
+	if addr, ok := p.listener.Addr().(*net.TCPAddr); ok {
+		return addr.Port
+	}
+	return 0
+}
+
+// Close stops the proxy and removes its socket directory. Tunnels that are
+// still open are cut when the sandboxed command exits.
+func (p *networkProxy) Close() error {
+	if p == nil {
+		return nil
+	}
+	p.closeOnce.Do(func() {
+		p.closeErr = p.listener.Close()
+		p.wg.Wait()
+		if p.socketDir != "" {
+			_ = os.RemoveAll(p.socketDir)
+		}
+	})
+	return p.closeErr
+}
+
+func (p *networkProxy) serve() {
+	defer p.wg.Done()
+	for {
+		conn, err := p.listener.Accept()
+		if err != nil {
+			return
+		}
+		go p.handle(conn)
+	}
+}
+
+func (p *networkProxy) handle(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	req, err := http.ReadRequest(reader)
+	if err != nil {
+		return
+	}
+	target := req.Host
+	if req.Method != http.MethodConnect && req.URL != nil && req.URL.Host != "" {
+		target = req.URL.Host
+	}
+	host, port := splitProxyTarget(target, req.Method == http.MethodConnect)
+	if host == "" {
+		writeProxyError(conn, http.StatusBadRequest, "caelis sandbox: proxy request has no target host")
+		return
+	}
+	if !networkHostAllowed(p.allowlist, host) {
+		if p.onDenied != nil {
+			p.onDenied(host)
+		}
+		writeProxyError(conn, http.StatusForbidden, fmt.Sprintf("caelis sandbox: host %s is not in the network allowlist", host))
+		return
+	}
+	upstream, err := net.DialTimeout("tcp", net.JoinHostPort(host, port), networkProxyDialTO)
+	if err != nil {
+		writeProxyError(conn, http.StatusBadGateway, fmt.Sprintf("caelis sandbox: connect %s: %v", host, err))
+		return
+	}
+	defer upstream.Close()
+
+	if req.Method == http.MethodConnect {
+		if _, err := io.WriteString(conn, "HTTP/1.1 200 Connection Established\r\n\r\n"); err != nil {
+			return
+		}
+		done := make(chan struct{})
+		go func() {
+			_, _ = io.Copy(upstream, reader)
+			if tcp, ok := upstream.(*net.TCPConn); ok {
+				_ = tcp.CloseWrite()
+			}
+			close(done)
+		}()
+		_, _ = io.Copy(conn, upstream)
+		_ = conn.Close()
+		<-done
+		return
+	}
+
+	// Plain HTTP: forward one request per connection so a keep-alive client
+	// cannot reuse the connection for a different host.
+	req.Header.Del("Proxy-Connection")
+	req.Header.Del("Proxy-Authorization")
+	req.Close = true
+	if err := req.Write(upstream); err != nil {
+		writeProxyError(conn, http.StatusBadGateway, fmt.Sprintf("caelis sandbox: forward to %s: %v", host, err))
+		return
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(upstream), req)
+	if err != nil {
+		writeProxyError(conn, http.StatusBadGateway, fmt.Sprintf("caelis sandbox: read from %s: %v", host, err))
+		return
+	}
+	defer resp.Body.Close()
+	resp.Close = true
+	_ = resp.Write(conn)
+}
+
+func splitProxyTarget(target string, tunnel bool) (string, string) {
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return "", ""
+	}
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		host = strings.Trim(target, "[]")
+		port = "80"
+		if tunnel {
+			port = "443"
+		}
+	}
+	if _, err := strconv.Atoi(port); err != nil {
+		return "", ""
+	}
+	return strings.ToLower(strings.TrimSuffix(host, ".")), port
+}
+
+func writeProxyError(conn net.Conn, status int, message string) {
+	body := message + "\n"
+	_, _ = fmt.Fprintf(conn, "HTTP/1.1 %d %s\r\nContent-Type: text/plain; charset=utf-8\r\nContent-Length: %d\r\nConnection: close\r\n\r\n%s",
+		status, http.StatusText(status), len(body), body)
+}
+
+// networkHostAllowed reports whether host matches the allowlist. An entry
+// matches the same host; "*.example.com" matches subdomains only and
+// ".example.com" matches the domain and its subdomains.
+func networkHostAllowed(allowlist []string, host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(host), "."))
+	if host == "" {
+		return false
+	}
+	for _, entry := range allowlist {
+		switch {
+		case strings.HasPrefix(entry, "*."):
+			if strings.HasSuffix(host, entry[1:]) {
+				return true
+			}
+		case strings.HasPrefix(entry, "."):
+			if host == entry[1:] || strings.HasSuffix(host, entry) {
+				return true
+			}
+		case host == entry:
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeNetworkHosts lowercases allowlist entries and drops schemes, ports
+// and paths so "https://proxy.golang.org/" and "proxy.golang.org" agree.
+func normalizeNetworkHosts(hosts []string) []string {
+	out := make([]string, 0, len(hosts))
+	for _, raw := range hosts {
+		host := strings.ToLower(strings.TrimSpace(raw))
+		if _, rest, ok := strings.Cut(host, "://"); ok {
+			host = rest
+		}
+		if before, _, ok := strings.Cut(host, "/"); ok {
+			host = before
+		}
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.TrimSuffix(strings.Trim(host, "[]"), ".")
+		if host != "" {
+			out = append(out, host)
+		}
+	}
+	return normalizeStringList(out)
+}
+
+// networkProxyEnv points the usual proxy variables at proxyURL and clears
+// NO_PROXY so host settings cannot route around the proxy.
+func networkProxyEnv(overrides map[string]string, proxyURL string) map[string]string {
+	env := make(map[string]string, len(overrides)+8)
+	for key, value := range overrides {
+		env[key] = value
+	}
+	for _, key := range []string{"HTTP_PROXY", "HTTPS_PROXY", "ALL_PROXY", "http_proxy", "https_proxy", "all_proxy"} {
+		env[key] = proxyURL
+	}
+	env["NO_PROXY"] = ""
+	env["no_proxy"] = ""
+	return env
+}
+
+// startCommandNetworkProxy starts the proxy for one sandboxed command when its
+// policy asks for proxied network, and returns nil otherwise.
+func startCommandNetworkProxy(policy SandboxPolicy, network string, req CommandRequest) (*networkProxy, error) {
+	if !policy.NetworkProxied() {
+		return nil, nil
+	}
+	return startNetworkProxy(network, policy.NetworkAllowlist, req.OnNetworkDenied)
+}
+
+// commandProxyEnv adds the proxy variables for a command that reaches the
+// proxy on the given loopback port. Without a proxy it returns overrides as is.
+func commandProxyEnv(overrides map[string]string, proxy *networkProxy, port int) map[string]string {
+	if proxy == nil {
+		return overrides
+	}
+	return networkProxyEnv(overrides, fmt.Sprintf("http://127.0.0.1:%d", port))
+}
@@ -0,0 +1,142 @@
+package execenv
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestNetworkHostAllowed(t *testing.T) {
+	allowlist := normalizeNetworkHosts([]string{"https://Proxy.Golang.org/", "*.githubusercontent.com", ".npmjs.org", "localhost:8080"})
+	cases := []struct {
+		host string
+		want bool
+	}{
+		{"proxy.golang.org", true},
+		{"PROXY.golang.org.", true},
+		{"sum.golang.org", false},
+		{"raw.githubusercontent.com", true},
+		{"githubusercontent.com", false},
+		{"npmjs.org", true},
+		{"registry.npmjs.org", true},
+		{"evilnpmjs.org", false},
+		{"localhost", true},
+		{"", false},
+	}
+	for _, tc := range cases {
+		if got := networkHostAllowed(allowlist, tc.host); got != tc.want {
+			t.Errorf("networkHostAllowed(%q) = %v, want %v", tc.host, got, tc.want)
+		}
+	}
+}
+
+func TestDeriveSandboxPolicy_NetworkAllowlistProxiesWorkspaceWrite(t *testing.T) {
+	policy := deriveSandboxPolicy(PermissionModeDefault, SandboxPolicy{
+		Type:             SandboxPolicyWorkspaceWrite,
+		NetworkAllowlist: []string{"https://proxy.golang.org"},
+	})
+	if !policy.NetworkProxied() || policy.NetworkAccess {
+		t.Fatalf("expected a proxied policy without direct network, got %+v", policy)
+	}
+	if len(policy.NetworkAllowlist) != 1 || policy.NetworkAllowlist[0] != "proxy.golang.org" {
+		t.Fatalf("expected a normalized allowlist, got %v", policy.NetworkAllowlist)
+	}
+	open := deriveSandboxPolicy(PermissionModeDefault, SandboxPolicy{Type: SandboxPolicyWorkspaceWrite})
+	if open.NetworkProxied() || !open.NetworkAccess {
+		t.Fatalf("expected open network without an allowlist, got %+v", open)
+	}
+}
+
+func TestNetworkProxy_ForwardsAllowedAndRefusesOtherHosts(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "hello from "+r.URL.Path)
+	}))
+	defer upstream.Close()
+	upstreamURL, err := url.Parse(upstream.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		mu     sync.Mutex
+		denied []string
+	)
+	proxy, err := startNetworkProxy("tcp", []string{"127.0.0.1"}, func(host string) {
+		mu.Lock()
+		denied = append(denied, host)
+		mu.Unlock()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(fmt.Sprintf("http://127.0.0.1:%d", proxy.Port()))
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+
+	resp, err := client.Get(upstream.URL + "/plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "hello from /plain" {
+		t.Fatalf("unexpected proxied response %d %q", resp.StatusCode, body)
+	}
+
+	conn, err := net.Dial("tcp", proxyURL.Host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	fmt.Fprintf(conn, "CONNECT %s HTTP/1.1\r\nHost: %s\r\n\r\n", upstreamURL.Host, upstreamURL.Host)
+	reader := bufio.NewReader(conn)
+	status, err := reader.ReadString('\n')
+	if err != nil || !strings.Contains(status, "200") {
+		t.Fatalf("expected an established tunnel, got %q (%v)", status, err)
+	}
+	if _, err := reader.ReadString('\n'); err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprintf(conn, "GET /tunnel HTTP/1.1\r\nHost: %s\r\nConnection: close\r\n\r\n", upstreamURL.Host)
+	tunneled, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ = io.ReadAll(tunneled.Body)
+	tunneled.Body.Close()
+	if string(body) != "hello from /tunnel" {
+		t.Fatalf("unexpected tunneled body %q", body)
+	}
+
+	resp, err = client.Get("http://blocked.example/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden || !strings.Contains(string(body), "blocked.example") {
+		t.Fatalf("expected a 403 for a blocked host, got %d %q", resp.StatusCode, body)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(denied) != 1 || denied[0] != "blocked.example" {
+		t.Fatalf("expected the blocked host to be reported, got %v", denied)
+	}
+}
+
+func TestNetworkProxyEnvClearsNoProxy(t *testing.T) {
+	env := networkProxyEnv(map[string]string{"FOO": "bar"}, "http://127.0.0.1:3128")
+	if env["FOO"] != "bar" || env["HTTPS_PROXY"] != "http://127.0.0.1:3128" || env["https_proxy"] != "http://127.0.0.1:3128" {
+		t.Fatalf("unexpected proxy env %v", env)
+	}
+	if value, ok := env["NO_PROXY"]; !ok || value != "" {
+		t.Fatalf("expected NO_PROXY to be cleared, got %v", env)
+	}
+}
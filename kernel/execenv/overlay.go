@@ -0,0 +1,447 @@
+package execenv
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// OverlayChangeKind describes what a staged change does to one workspace file.
+type OverlayChangeKind string
+
+const (
+	OverlayChangeAdded    OverlayChangeKind = "added"
+	OverlayChangeModified OverlayChangeKind = "modified"
+	OverlayChangeDeleted  OverlayChangeKind = "deleted"
+)
+
+// OverlayChange is one staged change to a workspace file.
+type OverlayChange struct {
+	// Path is the absolute workspace path.
+	Path string
+	Kind OverlayChangeKind
+	// Before is the workspace content, nil for added files.
+	Before []byte
+	// After is the staged content, nil for deleted files. For a symlink it
+	// is the link target.
+	After []byte
+	// Mode is the staged file mode, zero for deleted files.
+	Mode os.FileMode
+}
+
+// Overlay is a copy-on-write layer over a workspace directory. Writes under
+// the root land in a private upper directory, reads see them, and the
+// workspace itself stays untouched until the changes are applied.
+//
+// The upper directory uses the overlayfs layout, so sandboxed commands can
+// mount it over the workspace and share the layer with the file tools:
+// a whiteout (character device 0/0) marks a deleted path and an opaque
+// directory hides the workspace directory below it.
+type Overlay struct {
+	root string
+	dir  string
+
+	mu sync.Mutex
+}
+
+// NewOverlay creates an empty layer over root. Close removes it.
+func NewOverlay(root string) (*Overlay, error) {
+	root = strings.TrimSpace(root)
+	if root == "" {
+		return nil, fmt.Errorf("execenv: overlay root is required")
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(root); err != nil {
+		return nil, err
+	} else if !info.IsDir() {
+		return nil, fmt.Errorf("execenv: overlay root %q is not a directory", root)
+	}
+	dir, err := os.MkdirTemp("", "caelis-staged-")
+	if err != nil {
+		return nil, err
+	}
+	o := &Overlay{root: filepath.Clean(root), dir: dir}
+	if err := o.resetLocked(); err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+	return o, nil
+}
+
+// Root returns the workspace directory the layer covers.
+func (o *Overlay) Root() string {
+	if o == nil {
+		return ""
+	}
+	return o.root
+}
+
+func (o *Overlay) upperDir() string { return filepath.Join(o.dir, "upper") }
+
+func (o *Overlay) workDir() string { return filepath.Join(o.dir, "work") }
+
+// Changes lists the staged changes by path.
+func (o *Overlay) Changes() ([]OverlayChange, error) {
+	if o == nil {
+		return nil, nil
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.changesLocked()
+}
+
+// Apply writes the staged changes for paths to the workspace and then clears
+// the layer, dropping the changes that were not selected. Paths may be
+// absolute or relative to the root; nil applies every change. It returns the
+// changes it applied.
+func (o *Overlay) Apply(paths []string) ([]OverlayChange, error) {
+	if o == nil {
+		return nil, nil
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	changes, err := o.changesLocked()
+	if err != nil {
+		return nil, err
+	}
+	if paths != nil {
+		selected := make(map[string]bool, len(paths))
+		for _, path := range paths {
+			if rel, ok := o.rel(path); ok {
+				selected[filepath.Join(o.root, rel)] = true
+			}
+		}
+		changes = slices.DeleteFunc(changes, func(change OverlayChange) bool {
+			return !selected[change.Path]
+		})
+	}
+	// Deletions go first so a file that replaces a directory, or the other
+	// way round, finds its path free.
+	for _, change := range changes {
+		if change.Kind != OverlayChangeDeleted {
+			continue
+		}
+		if err := os.Remove(change.Path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("execenv: apply %s: %w", change.Path, err)
+		}
+		o.pruneDeletedDirsLocked(filepath.Dir(change.Path))
+	}
+	for _, change := range changes {
+		if change.Kind == OverlayChangeDeleted {
+			continue
+		}
+		if err := writeStagedFile(change); err != nil {
+			return nil, fmt.Errorf("execenv: apply %s: %w", change.Path, err)
+		}
+	}
+	if err := o.resetLocked(); err != nil {
+		return changes, err
+	}
+	return changes, nil
+}
+
+// Discard drops every staged change.
+func (o *Overlay) Discard() error {
+	if o == nil {
+		return nil
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.resetLocked()
+}
+
+// Close removes the layer and everything staged in it.
+func (o *Overlay) Close() error {
+	if o == nil {
+		return nil
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return removeStagedTree(o.dir)
+}
+
+// FileSystem returns base with the staged changes on top. Paths outside the
+// root go straight to base.
+func (o *Overlay) FileSystem(base FileSystem) FileSystem {
+	if o == nil || base == nil {
+		return base
+	}
+	return &overlayFileSystem{overlay: o, base: base}
+}
+
+// bwrapArgs mounts the layer over the root inside a bubblewrap sandbox and
+// hides the staging directory from the command.
+func (o *Overlay) bwrapArgs() []string {
+	return []string{
+		"--overlay-src", o.root,
+		"--overlay", o.upperDir(), o.workDir(), o.root,
+		"--tmpfs", o.dir,
+	}
+}
+
+// rel returns path relative to the root, or false when path is outside it.
+func (o *Overlay) rel(path string) (string, bool) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return "", false
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(o.root, path)
+	}
+	rel, err := filepath.Rel(o.root, filepath.Clean(path))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return rel, true
+}
+
+// locate reports how rel resolves in the layer. staged means the upper
+// directory has an entry for it. hidden means the workspace path does not
+// show through: a whiteout or a staged file covers it or one of its parents,
+// or an opaque directory covers its parent. For a staged directory, hidden
+// also means its workspace entries are not merged in.
+func (o *Overlay) locate(rel string) (staged bool, hidden bool) {
+	current := o.upperDir()
+	if rel == "." {
+		return true, false
+	}
+	parts := strings.Split(rel, string(filepath.Separator))
+	for i, part := range parts {
+		current = filepath.Join(current, part)
+		info, err := os.Lstat(current)
+		if err != nil {
+			return false, hidden
+		}
+		if isWhiteout(info) {
+			return false, true
+		}
+		if i == len(parts)-1 {
+			return true, hidden || !info.IsDir() || isOpaqueDir(current)
+		}
+		if !info.IsDir() {
+			return false, true
+		}
+		if isOpaqueDir(current) {
+			hidden = true
+		}
+	}
+	return false, hidden
+}
+
+// ensureUpperDir creates the upper directory for rel and its parents. A
+// whiteout in the way becomes an opaque directory so the deleted workspace
+// directory stays hidden.
+func (o *Overlay) ensureUpperDir(rel string) error {
+	current := o.upperDir()
+	if rel == "." {
+		return nil
+	}
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		current = filepath.Join(current, part)
+		info, err := os.Lstat(current)
+		switch {
+		case err == nil && isWhiteout(info):
+			if err := os.Remove(current); err != nil {
+				return err
+			}
+			if err := os.Mkdir(current, 0o755); err != nil {
+				return err
+			}
+			if err := setOpaqueDir(current); err != nil {
+				return err
+			}
+		case err == nil && info.IsDir():
+		case err == nil:
+			return &fs.PathError{Op: "mkdir", Path: current, Err: errors.New("not a directory")}
+		case errors.Is(err, fs.ErrNotExist):
+			mode := os.FileMode(0o755)
+			if lower, err := os.Stat(filepath.Join(o.root, mustRel(o.upperDir(), current))); err == nil && lower.IsDir() {
+				mode = lower.Mode().Perm()
+			}
+			if err := os.Mkdir(current, mode|0o700); err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *Overlay) changesLocked() ([]OverlayChange, error) {
+	var changes []OverlayChange
+	upper := o.upperDir()
+	err := filepath.WalkDir(upper, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == upper {
+			return nil
+		}
+		rel := mustRel(upper, path)
+		lowerPath := filepath.Join(o.root, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		lower, lowerErr := os.Lstat(lowerPath)
+		lowerExists := lowerErr == nil
+		switch {
+		case isWhiteout(info):
+			if lowerExists {
+				changes = appendDeleted(changes, lowerPath)
+			}
+			return nil
+		case info.IsDir():
+			if lowerExists && !lower.IsDir() {
+				changes = appendDeleted(changes, lowerPath)
+			} else if _, hidden := o.locate(rel); lowerExists && hidden {
+				entries, _ := os.ReadDir(lowerPath)
+				for _, entry := range entries {
+					if _, err := os.Lstat(filepath.Join(path, entry.Name())); errors.Is(err, fs.ErrNotExist) {
+						changes = appendDeleted(changes, filepath.Join(lowerPath, entry.Name()))
+					}
+				}
+			}
+			return nil
+		}
+		after, err := readStagedEntry(path, info)
+		if err != nil {
+			return err
+		}
+		change := OverlayChange{Path: lowerPath, Kind: OverlayChangeAdded, After: after, Mode: info.Mode()}
+		if lowerExists && lower.IsDir() {
+			changes = appendDeleted(changes, lowerPath)
+		} else if lowerExists {
+			before, err := readStagedEntry(lowerPath, lower)
+			if err != nil {
+				return err
+			}
+			if bytes.Equal(before, after) && lower.Mode() == info.Mode() {
+				return nil
+			}
+			change.Kind = OverlayChangeModified
+			change.Before = before
+		}
+		changes = append(changes, change)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(changes, func(a, b OverlayChange) int { return strings.Compare(a.Path, b.Path) })
+	return changes, nil
+}
+
+// pruneDeletedDirsLocked removes workspace directories left empty by applied
+// deletions when the layer no longer shows them.
+func (o *Overlay) pruneDeletedDirsLocked(dir string) {
+	for {
+		rel, ok := o.rel(dir)
+		if !ok || rel == "." {
+			return
+		}
+		if staged, hidden := o.locate(rel); staged || !hidden {
+			return
+		}
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+func (o *Overlay) resetLocked() error {
+	if err := removeStagedTree(o.upperDir()); err != nil {
+		return err
+	}
+	if err := removeStagedTree(o.workDir()); err != nil {
+		return err
+	}
+	if err := os.Mkdir(o.upperDir(), 0o755); err != nil {
+		return err
+	}
+	return os.Mkdir(o.workDir(), 0o755)
+}
+
+// appendDeleted adds a deletion for path, or for every file below it when
+// it is a directory.
+func appendDeleted(changes []OverlayChange, path string) []OverlayChange {
+	_ = filepath.WalkDir(path, func(one string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		before, err := readStagedEntry(one, info)
+		if err != nil {
+			return nil
+		}
+		changes = append(changes, OverlayChange{Path: one, Kind: OverlayChangeDeleted, Before: before})
+		return nil
+	})
+	return changes
+}
+
+func readStagedEntry(path string, info os.FileInfo) ([]byte, error) {
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(path)
+		return []byte(target), err
+	}
+	return os.ReadFile(path)
+}
+
+func writeStagedFile(change OverlayChange) error {
+	if err := os.MkdirAll(filepath.Dir(change.Path), 0o755); err != nil {
+		return err
+	}
+	if change.Mode&os.ModeSymlink != 0 {
+		if err := os.Remove(change.Path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return os.Symlink(string(change.After), change.Path)
+	}
+	if info, err := os.Lstat(change.Path); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		if err := os.Remove(change.Path); err != nil {
+			return err
+		}
+	}
+	if err := os.WriteFile(change.Path, change.After, change.Mode.Perm()); err != nil {
+		return err
+	}
+	return os.Chmod(change.Path, change.Mode.Perm())
+}
+
+// removeStagedTree removes path even when overlayfs left directories in it
+// without write permission.
+func removeStagedTree(path string) error {
+	if err := os.RemoveAll(path); err == nil {
+		return nil
+	}
+	_ = filepath.WalkDir(path, func(one string, d fs.DirEntry, err error) error {
+		if err == nil && d.IsDir() {
+			_ = os.Chmod(one, 0o700)
+		}
+		return nil
+	})
+	return os.RemoveAll(path)
+}
+
+func mustRel(base, path string) string {
+	rel, err := filepath.Rel(base, path)
+	if err != nil {
+		return path
+	}
+	return rel
+}
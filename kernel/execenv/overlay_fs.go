@@ -0,0 +1,332 @@
+package execenv
+
+import (
+	"cmp"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	stdruntime "runtime"
+	"slices"
+	"strings"
+)
+
+// overlayFileSystem is the file tools' view of an Overlay: staged entries
+// shadow the workspace and writes under the root go to the upper directory.
+type overlayFileSystem struct {
+	overlay *Overlay
+	base    FileSystem
+}
+
+// overlayPathChecker is implemented by policyFileSystem. The layer writes
+// its upper directory directly, so it asks the policy first.
+type overlayPathChecker interface {
+	checkReadPath(path string) error
+	checkWritePath(path string) error
+}
+
+func (f *overlayFileSystem) Getwd() (string, error)       { return f.base.Getwd() }
+func (f *overlayFileSystem) UserHomeDir() (string, error) { return f.base.UserHomeDir() }
+
+func (f *overlayFileSystem) Open(path string) (*os.File, error) {
+	upper, ok, err := f.resolve("open", path)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return f.base.Open(path)
+	}
+	return os.Open(upper)
+}
+
+func (f *overlayFileSystem) Stat(path string) (os.FileInfo, error) {
+	upper, ok, err := f.resolve("stat", path)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return f.base.Stat(path)
+	}
+	return os.Stat(upper)
+}
+
+func (f *overlayFileSystem) ReadFile(path string) ([]byte, error) {
+	upper, ok, err := f.resolve("open", path)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return f.base.ReadFile(path)
+	}
+	return os.ReadFile(upper)
+}
+
+func (f *overlayFileSystem) ReadDir(path string) ([]os.DirEntry, error) {
+	rel, inside := f.overlay.rel(f.abs(path))
+	if !inside {
+		return f.base.ReadDir(path)
+	}
+	staged, hidden := f.overlay.locate(rel)
+	if !staged {
+		if hidden {
+			return nil, &fs.PathError{Op: "open", Path: path, Err: fs.ErrNotExist}
+		}
+		return f.base.ReadDir(path)
+	}
+	if err := f.checkRead(path); err != nil {
+		return nil, err
+	}
+	upperEntries, err := os.ReadDir(filepath.Join(f.overlay.upperDir(), rel))
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(upperEntries))
+	entries := make([]os.DirEntry, 0, len(upperEntries))
+	for _, entry := range upperEntries {
+		seen[entry.Name()] = true
+		if info, err := entry.Info(); err == nil && isWhiteout(info) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if !hidden {
+		lowerEntries, err := f.base.ReadDir(path)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		for _, entry := range lowerEntries {
+			if !seen[entry.Name()] {
+				entries = append(entries, entry)
+			}
+		}
+	}
+	slices.SortFunc(entries, func(a, b os.DirEntry) int { return strings.Compare(a.Name(), b.Name()) })
+	return entries, nil
+}
+
+// maxOverlaySymlinkHops bounds how many staged symlinks one write follows,
+// like the kernel's ELOOP limit.
+const maxOverlaySymlinkHops = 40
+
+func (f *overlayFileSystem) WriteFile(path string, data []byte, perm os.FileMode) error {
+	return f.writeFile(path, data, perm, 0)
+}
+
+func (f *overlayFileSystem) writeFile(path string, data []byte, perm os.FileMode, hops int) error {
+	rel, inside := f.overlay.rel(f.abs(path))
+	if !inside {
+		return f.base.WriteFile(path, data, perm)
+	}
+	if checker, ok := f.base.(overlayPathChecker); ok {
+		if err := checker.checkWritePath(path); err != nil {
+			return err
+		}
+	}
+	f.overlay.mu.Lock()
+	if err := f.overlay.ensureUpperDir(filepath.Dir(rel)); err != nil {
+		f.overlay.mu.Unlock()
+		return err
+	}
+	upper := filepath.Join(f.overlay.upperDir(), rel)
+	info, err := os.Lstat(upper)
+	if err == nil && info.Mode()&os.ModeSymlink != 0 {
+		// A staged symlink is written through to its target, as on disk.
+		// The target is read in the merged view, not the upper directory,
+		// and written like any other path so staging and the policy still
+		// apply to it.
+		link, err := os.Readlink(upper)
+		f.overlay.mu.Unlock()
+		if err != nil {
+			return err
+		}
+		if hops >= maxOverlaySymlinkHops {
+			return &fs.PathError{Op: "write", Path: path, Err: errors.New("too many levels of symbolic links")}
+		}
+		if !filepath.IsAbs(link) {
+			link = filepath.Join(filepath.Dir(f.abs(path)), link)
+		}
+		return f.writeFile(link, data, perm, hops+1)
+	}
+	defer f.overlay.mu.Unlock()
+	switch {
+	case err == nil && isWhiteout(info):
+		if err := os.Remove(upper); err != nil {
+			return err
+		}
+	case errors.Is(err, fs.ErrNotExist):
+		if _, hidden := f.overlay.locate(rel); !hidden {
+			if lower, err := os.Stat(filepath.Join(f.overlay.root, rel)); err == nil && lower.Mode().IsRegular() {
+				perm = lower.Mode().Perm()
+			}
+		}
+	}
+	return os.WriteFile(upper, data, perm)
+}
+
+// Remove stages the deletion of path. Checkpoint rewinds use it to drop
+// files a turn created.
+func (f *overlayFileSystem) Remove(path string) error {
+	rel, inside := f.overlay.rel(f.abs(path))
+	if !inside {
+		if remover, ok := f.base.(interface{ Remove(string) error }); ok {
+			return remover.Remove(path)
+		}
+		return os.Remove(path)
+	}
+	if checker, ok := f.base.(overlayPathChecker); ok {
+		if err := checker.checkWritePath(path); err != nil {
+			return err
+		}
+	}
+	f.overlay.mu.Lock()
+	defer f.overlay.mu.Unlock()
+	staged, hidden := f.overlay.locate(rel)
+	upper := filepath.Join(f.overlay.upperDir(), rel)
+	if staged {
+		if err := os.Remove(upper); err != nil {
+			return err
+		}
+		_, hidden = f.overlay.locate(rel)
+	} else if hidden {
+		return &fs.PathError{Op: "remove", Path: path, Err: fs.ErrNotExist}
+	}
+	if hidden {
+		return nil
+	}
+	if _, err := os.Lstat(filepath.Join(f.overlay.root, rel)); err != nil {
+		if staged {
+			return nil
+		}
+		return err
+	}
+	if err := f.overlay.ensureUpperDir(filepath.Dir(rel)); err != nil {
+		return err
+	}
+	return makeWhiteout(upper)
+}
+
+func (f *overlayFileSystem) Glob(pattern string) ([]string, error) {
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	if !hasGlobMeta(pattern) {
+		if _, err := f.Stat(pattern); err != nil {
+			return nil, nil
+		}
+		return []string{pattern}, nil
+	}
+	dir, file := filepath.Split(pattern)
+	dir = filepath.Clean(cmp.Or(dir, "."))
+	dirs := []string{dir}
+	if hasGlobMeta(dir) {
+		var err error
+		if dirs, err = f.Glob(dir); err != nil {
+			return nil, err
+		}
+	}
+	var matches []string
+	for _, one := range dirs {
+		entries, err := f.ReadDir(one)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if matched, _ := filepath.Match(file, entry.Name()); matched {
+				matches = append(matches, filepath.Join(one, entry.Name()))
+			}
+		}
+	}
+	return matches, nil
+}
+
+// WalkDir walks the merged tree the way filepath.WalkDir walks the disk.
+func (f *overlayFileSystem) WalkDir(root string, fn fs.WalkDirFunc) error {
+	return walkFileSystem(f, root, fn)
+}
+
+// walkFileSystem walks fsys the way filepath.WalkDir walks the disk, for file
+// systems that can only stat and list directories.
+func walkFileSystem(fsys FileSystem, root string, fn fs.WalkDirFunc) error {
+	info, err := fsys.Stat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = walkFileSystemDir(fsys, root, fs.FileInfoToDirEntry(info), fn)
+	}
+	if errors.Is(err, filepath.SkipDir) || errors.Is(err, filepath.SkipAll) {
+		return nil
+	}
+	return err
+}
+
+func walkFileSystemDir(fsys FileSystem, path string, d fs.DirEntry, fn fs.WalkDirFunc) error {
+	if err := fn(path, d, nil); err != nil || !d.IsDir() {
+		if errors.Is(err, filepath.SkipDir) && d.IsDir() {
+			err = nil
+		}
+		return err
+	}
+	entries, err := fsys.ReadDir(path)
+	if err != nil {
+		if err = fn(path, d, err); err != nil {
+			if errors.Is(err, filepath.SkipDir) && d.IsDir() {
+				err = nil
+			}
+			return err
+		}
+	}
+	for _, entry := range entries {
+		if err := walkFileSystemDir(fsys, filepath.Join(path, entry.Name()), entry, fn); err != nil {
+			if errors.Is(err, filepath.SkipDir) {
+				break
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// resolve returns the upper path to read for path, false when the read
+// should go to base, or a not-exist error when the layer hides the path.
+func (f *overlayFileSystem) resolve(op string, path string) (string, bool, error) {
+	rel, inside := f.overlay.rel(f.abs(path))
+	if !inside {
+		return "", false, nil
+	}
+	staged, hidden := f.overlay.locate(rel)
+	if !staged {
+		if hidden {
+			return "", false, &fs.PathError{Op: op, Path: path, Err: fs.ErrNotExist}
+		}
+		return "", false, nil
+	}
+	if err := f.checkRead(path); err != nil {
+		return "", false, err
+	}
+	return filepath.Join(f.overlay.upperDir(), rel), true, nil
+}
+
+func (f *overlayFileSystem) checkRead(path string) error {
+	if checker, ok := f.base.(overlayPathChecker); ok {
+		return checker.checkReadPath(path)
+	}
+	return nil
+}
+
+func (f *overlayFileSystem) abs(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	if wd, err := f.base.Getwd(); err == nil {
+		return filepath.Join(wd, path)
+	}
+	return path
+}
+
+func hasGlobMeta(path string) bool {
+	magic := `*?[`
+	if stdruntime.GOOS != "windows" {
+		magic = `*?[\\`
+	}
+	return strings.ContainsAny(path, magic)
+}
@@ -0,0 +1,41 @@
+//go:build linux
+
+package execenv
+
+import (
+	"os"
+	"syscall"
+)
+
+// Opaque directory markers: overlayfs mounted by an unprivileged user, as
+// bubblewrap does, uses the user namespace; a privileged mount the trusted
+// one.
+var overlayOpaqueXattrs = []string{"user.overlay.opaque", "trusted.overlay.opaque"}
+
+// isWhiteout reports whether info is an overlayfs whiteout, a character
+// device with device number 0/0 that marks a deleted path.
+func isWhiteout(info os.FileInfo) bool {
+	if info == nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	return ok && stat.Rdev == 0
+}
+
+func isOpaqueDir(path string) bool {
+	value := make([]byte, 1)
+	for _, name := range overlayOpaqueXattrs {
+		if n, err := syscall.Getxattr(path, name, value); err == nil && n == 1 && value[0] == 'y' {
+			return true
+		}
+	}
+	return false
+}
+
+func setOpaqueDir(path string) error {
+	return syscall.Setxattr(path, overlayOpaqueXattrs[0], []byte("y"), 0)
+}
+
+func makeWhiteout(path string) error {
+	return syscall.Mknod(path, syscall.S_IFCHR, 0)
+}
@@ -0,0 +1,22 @@
+//go:build !linux
+
+package execenv
+
+import (
+	"fmt"
+	"os"
+)
+
+// Only Linux has overlayfs, so no other platform finds whiteouts or opaque
+// directories in a layer, and the file tools cannot create them.
+func isWhiteout(os.FileInfo) bool { return false }
+
+func isOpaqueDir(string) bool { return false }
+
+func setOpaqueDir(path string) error {
+	return fmt.Errorf("execenv: cannot stage a directory over deleted %s on this platform", path)
+}
+
+func makeWhiteout(path string) error {
+	return fmt.Errorf("execenv: cannot stage the deletion of %s on this platform", path)
+}
@@ -0,0 +1,264 @@
+package execenv
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type overlayCaptureRunner struct {
+	noopRunner
+	supports bool
+	lastReq  CommandRequest
+}
+
+func (r *overlayCaptureRunner) Run(ctx context.Context, req CommandRequest) (CommandResult, error) {
+	_ = ctx
+	r.lastReq = req
+	return CommandResult{}, nil
+}
+
+func (r *overlayCaptureRunner) SupportsOverlay() bool { return r.supports }
+
+func newTestOverlay(t *testing.T) (*Overlay, string) {
+	t.Helper()
+	root := t.TempDir()
+	for path, content := range map[string]string{
+		"keep.txt":       "keep\n",
+		"edit.txt":       "before\n",
+		"gone/a.txt":     "a\n",
+		"nested/old.txt": "old\n",
+	} {
+		full := filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	overlay, err := NewOverlay(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = overlay.Close() })
+	return overlay, root
+}
+
+func TestOverlayFileSystem_StagesWritesAndMergesReads(t *testing.T) {
+	overlay, root := newTestOverlay(t)
+	fsys := overlay.FileSystem(newHostFileSystem())
+	remover := fsys.(interface{ Remove(string) error })
+
+	if err := fsys.WriteFile(filepath.Join(root, "edit.txt"), []byte("after\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.WriteFile(filepath.Join(root, "nested", "new.txt"), []byte("new\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := remover.Remove(filepath.Join(root, "gone", "a.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, err := fsys.ReadFile(filepath.Join(root, "edit.txt")); err != nil || string(data) != "after\n" {
+		t.Fatalf("expected the staged content, got %q (%v)", data, err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "edit.txt")); string(data) != "before\n" {
+		t.Fatalf("expected the workspace untouched, got %q", data)
+	}
+	if info, err := fsys.Stat(filepath.Join(root, "edit.txt")); err != nil || info.Mode().Perm() != 0o644 {
+		t.Fatalf("expected the workspace mode kept for a modified file, got %v (%v)", info, err)
+	}
+	if _, err := fsys.Stat(filepath.Join(root, "gone", "a.txt")); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected the removed file hidden, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "gone", "a.txt")); err != nil {
+		t.Fatalf("expected the removed file kept in the workspace, got %v", err)
+	}
+
+	entries, err := fsys.ReadDir(filepath.Join(root, "nested"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if want := []string{"new.txt", "old.txt"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("expected merged entries %v, got %v", want, names)
+	}
+	matches, err := fsys.Glob(filepath.Join(root, "*", "*.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 2 || !strings.HasSuffix(matches[0], "new.txt") || !strings.HasSuffix(matches[1], "old.txt") {
+		t.Fatalf("expected glob to see the staged file and skip the removed one, got %v", matches)
+	}
+	var walked []string
+	if err := fsys.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			walked = append(walked, mustRel(root, path))
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"edit.txt", "keep.txt", filepath.Join("nested", "new.txt"), filepath.Join("nested", "old.txt")}; !reflect.DeepEqual(walked, want) {
+		t.Fatalf("expected walk %v, got %v", want, walked)
+	}
+}
+
+func TestOverlayFileSystem_WritesThroughStagedSymlinksInTheLayer(t *testing.T) {
+	overlay, root := newTestOverlay(t)
+	outside := t.TempDir()
+	fsys := overlay.FileSystem(newPolicyFileSystem(newHostFileSystem(), func() SandboxPolicy {
+		return SandboxPolicy{WritableRoots: []string{root}, ReadOnlySubpaths: []string{outside}}
+	}))
+	// A sandboxed command staged these links; they name merged-view paths.
+	for name, target := range map[string]string{
+		"abs.txt":     filepath.Join(root, "keep.txt"),
+		"rel.txt":     "edit.txt",
+		"escape.txt":  filepath.Join(outside, "x.txt"),
+		"nested/loop": "loop",
+	} {
+		upper := filepath.Join(overlay.upperDir(), name)
+		if err := os.MkdirAll(filepath.Dir(upper), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(target, upper); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := fsys.WriteFile(filepath.Join(root, "abs.txt"), []byte("via abs\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.WriteFile(filepath.Join(root, "rel.txt"), []byte("via rel\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]string{"keep.txt": "via abs\n", "edit.txt": "via rel\n"} {
+		if data, err := fsys.ReadFile(filepath.Join(root, path)); err != nil || string(data) != want {
+			t.Fatalf("expected %s staged as %q, got %q (%v)", path, want, data, err)
+		}
+	}
+	for path, want := range map[string]string{"keep.txt": "keep\n", "edit.txt": "before\n"} {
+		if data, _ := os.ReadFile(filepath.Join(root, path)); string(data) != want {
+			t.Fatalf("expected the workspace %s untouched, got %q", path, data)
+		}
+	}
+	if err := fsys.WriteFile(filepath.Join(root, "escape.txt"), []byte("x"), 0o644); !errors.Is(err, fs.ErrPermission) {
+		t.Fatalf("expected a link out of the writable roots to be refused, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "x.txt")); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected nothing written outside, got %v", err)
+	}
+	if err := fsys.WriteFile(filepath.Join(root, "nested", "loop"), []byte("x"), 0o644); err == nil {
+		t.Fatal("expected a symlink loop to fail")
+	}
+}
+
+func TestOverlay_ChangesApplyAndDiscard(t *testing.T) {
+	overlay, root := newTestOverlay(t)
+	fsys := overlay.FileSystem(newHostFileSystem())
+	remover := fsys.(interface{ Remove(string) error })
+	if err := fsys.WriteFile(filepath.Join(root, "edit.txt"), []byte("after\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.WriteFile(filepath.Join(root, "added.txt"), []byte("added\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := remover.Remove(filepath.Join(root, "gone", "a.txt")); err != nil {
+		t.Fatal(err)
+	}
+	// Writing a file back to its workspace content is not a change.
+	if err := fsys.WriteFile(filepath.Join(root, "keep.txt"), []byte("keep\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := overlay.Changes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]OverlayChangeKind{}
+	for _, change := range changes {
+		got[mustRel(root, change.Path)] = change.Kind
+	}
+	want := map[string]OverlayChangeKind{
+		"added.txt":                    OverlayChangeAdded,
+		"edit.txt":                     OverlayChangeModified,
+		filepath.Join("gone", "a.txt"): OverlayChangeDeleted,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected changes %v, got %v", want, got)
+	}
+
+	applied, err := overlay.Apply([]string{"edit.txt", filepath.Join(root, "gone", "a.txt")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 2 {
+		t.Fatalf("expected 2 applied changes, got %+v", applied)
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "edit.txt")); string(data) != "after\n" {
+		t.Fatalf("expected the modification applied, got %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(root, "gone", "a.txt")); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected the deletion applied, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "added.txt")); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected the unselected file dropped, got %v", err)
+	}
+	if changes, err := overlay.Changes(); err != nil || len(changes) != 0 {
+		t.Fatalf("expected an empty layer after apply, got %+v (%v)", changes, err)
+	}
+
+	if err := fsys.WriteFile(filepath.Join(root, "keep.txt"), []byte("changed\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := overlay.Discard(); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := fsys.ReadFile(filepath.Join(root, "keep.txt")); err != nil || string(data) != "keep\n" {
+		t.Fatalf("expected discard to drop the staged write, got %q (%v)", data, err)
+	}
+}
+
+func TestExecute_OverlayRequiresOverlaySandbox(t *testing.T) {
+	overlay, _ := newTestOverlay(t)
+	runner := &overlayCaptureRunner{}
+	rt, err := New(Config{
+		PermissionMode: PermissionModeDefault,
+		SandboxType:    platformDefaultSandboxType(),
+		SandboxRunner:  runner,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if _, err := rt.Execute(ctx, CommandRequest{Command: "true", Overlay: overlay}); !IsErrorCode(err, ErrorCodeStagedUnsupported) {
+		t.Fatalf("expected a sandbox without overlay support to be rejected, got %v", err)
+	}
+	runner.supports = true
+	if _, err := rt.Execute(ctx, CommandRequest{Command: "true", Overlay: overlay}); err != nil {
+		t.Fatal(err)
+	}
+	if runner.lastReq.Overlay != overlay {
+		t.Fatal("expected the overlay passed to the sandbox runner")
+	}
+	_, err = rt.Execute(ctx, CommandRequest{
+		Command:           "true",
+		SandboxPermission: SandboxPermissionRequireEscalated,
+		Overlay:           overlay,
+	})
+	if !IsErrorCode(err, ErrorCodeStagedUnsupported) {
+		t.Fatalf("expected a host command to be rejected, got %v", err)
+	}
+}
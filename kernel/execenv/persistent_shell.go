@@ -0,0 +1,616 @@
+package execenv
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// persistentShellCommand replaces the backend's login shell with a plain
+	// bash that reads commands from stdin. The login shell has already loaded
+	// the profile, so its exported environment carries over.
+	persistentShellCommand      = "exec bash --noprofile --norc"
+	persistentShellStartTimeout = 30 * time.Second
+	persistentShellPollInterval = 20 * time.Millisecond
+	// persistentShellIdleTTL is how long an unused shell is kept before the
+	// next RunInShell call stops it.
+	persistentShellIdleTTL = 30 * time.Minute
+)
+
+// ErrShellBusy is returned for a command whose persistent shell is still
+// running an earlier one.
+var ErrShellBusy = errors.New("execenv: persistent shell is busy")
+
+// PersistentShellEnabled reports whether the runtime was configured to run
+// commands in persistent shells.
+func (r *runtimeView) PersistentShellEnabled() bool {
+	return r != nil && r.persistentShell
+}
+
+// RunInShell runs req in the persistent shell for key and waits for it. See
+// StartInShell.
+func (r *runtimeView) RunInShell(ctx context.Context, key string, req CommandRequest) (ShellResult, error) {
+	session, err := r.startInShell(ctx, key, req)
+	if err != nil {
+		return ShellResult{}, err
+	}
+	if _, err := session.Wait(ctx, 0); err != nil {
+		_ = session.Terminate()
+		result, _ := session.waitResult()
+		return result, err
+	}
+	return session.ShellResult()
+}
+
+// StartInShell starts req in the persistent shell for key, starting a shell
+// when key has none yet, and returns without waiting for the command. The
+// shell is replaced, losing its state, when it has died or timed out, or when
+// req resolves to a different backend or sandbox policy than the one the
+// shell was started with. While the command runs, further commands for key
+// get ErrShellBusy.
+func (r *runtimeView) StartInShell(ctx context.Context, key string, req CommandRequest) (ShellSession, error) {
+	return r.startInShell(ctx, key, req)
+}
+
+func (r *runtimeView) startInShell(ctx context.Context, key string, req CommandRequest) (*shellCommand, error) {
+	if !r.PersistentShellEnabled() {
+		return nil, fmt.Errorf("execenv: persistent shell is not enabled")
+	}
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return nil, fmt.Errorf("execenv: persistent shell key is required")
+	}
+	if req.Overlay != nil {
+		// The file tools write the layer while a shell would keep it
+		// mounted, and overlayfs does not promise to show such writes.
+		return nil, NewCodedError(ErrorCodeStagedUnsupported, "tool: persistent shells do not run over staged changes")
+	}
+	backend, resolvedReq, err := r.resolveBackend(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	shell, restarted, err := r.shellFor(ctx, key, backend, resolvedReq)
+	if err != nil {
+		return nil, err
+	}
+	return startShellCommand(ctx, shell, backend.Name(), resolvedReq, restarted), nil
+}
+
+// shellFor returns the shell for key locked for one command.
+func (r *runtimeView) shellFor(ctx context.Context, key string, backend Backend, req CommandRequest) (*persistentShell, bool, error) {
+	signature := persistentShellSignature(backend, req)
+	r.shellsMu.Lock()
+	defer r.shellsMu.Unlock()
+	for other, shell := range r.shells {
+		if other != key && shell.idleSince() > persistentShellIdleTTL {
+			shell.close()
+			delete(r.shells, other)
+		}
+	}
+	existing := r.shells[key]
+	if existing != nil {
+		if !existing.mu.TryLock() {
+			return nil, false, ErrShellBusy
+		}
+		if existing.signature == signature && existing.alive() {
+			existing.touch()
+			return existing, false, nil
+		}
+		existing.mu.Unlock()
+		existing.close()
+		delete(r.shells, key)
+	}
+	shell, err := startPersistentShell(ctx, backend, req, signature)
+	if err != nil {
+		return nil, false, err
+	}
+	if r.shells == nil {
+		r.shells = make(map[string]*persistentShell)
+	}
+	r.shells[key] = shell
+	shell.mu.Lock()
+	return shell, existing != nil, nil
+}
+
+func (r *runtimeView) closeShells() {
+	r.shellsMu.Lock()
+	defer r.shellsMu.Unlock()
+	for key, shell := range r.shells {
+		shell.close()
+		delete(r.shells, key)
+	}
+}
+
+// persistentShellSignature identifies where a shell runs. A command whose
+// signature differs from its shell's needs a new shell.
+func persistentShellSignature(backend Backend, req CommandRequest) string {
+	policy := ""
+	if req.SandboxPolicyOverride != nil {
+		policy = fmt.Sprintf("%+v", *req.SandboxPolicyOverride)
+	}
+	return fmt.Sprintf("%s|%s|%s|%v", backend.Name(), req.RouteHint, policy, req.EnvOverrides)
+}
+
+// persistentShell is one long-lived bash process. It runs as an async session
+// on a backend, so the backend's SessionManager owns the process. Commands are
+// written to its stdin one at a time and their output is cut out of the
+// session streams at sentinel lines carrying a per-command token.
+type persistentShell struct {
+	signature string
+	kind      BackendKind
+	session   Session
+
+	mu       sync.Mutex
+	stdoutAt int64
+	stderrAt int64
+	broken   atomic.Bool
+	lastUsed atomic.Int64
+
+	deniedMu sync.Mutex
+	onDenied func(string)
+}
+
+func startPersistentShell(ctx context.Context, backend Backend, req CommandRequest, signature string) (*persistentShell, error) {
+	shell := &persistentShell{signature: signature, kind: backend.Kind()}
+	session, err := backend.Start(ctx, CommandRequest{
+		Command:               persistentShellCommand,
+		Dir:                   req.Dir,
+		SandboxPermission:     req.SandboxPermission,
+		RouteHint:             req.RouteHint,
+		BackendName:           req.BackendName,
+		EnvOverrides:          req.EnvOverrides,
+		SandboxPolicyOverride: req.SandboxPolicyOverride,
+		OnNetworkDenied:       shell.networkDenied,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("execenv: start persistent shell: %w", err)
+	}
+	shell.session = session
+	shell.touch()
+	// Wait for the login profile to finish and drop whatever it printed.
+	if _, err := shell.run(ctx, CommandRequest{Command: ":", Timeout: persistentShellStartTimeout}); err != nil {
+		shell.close()
+		return nil, fmt.Errorf("execenv: start persistent shell: %w", err)
+	}
+	return shell, nil
+}
+
+func (s *persistentShell) alive() bool {
+	if s.broken.Load() {
+		return false
+	}
+	status, err := s.session.Status()
+	return err == nil && status.State == SessionStateRunning
+}
+
+func (s *persistentShell) touch() {
+	s.lastUsed.Store(time.Now().UnixNano())
+}
+
+func (s *persistentShell) idleSince() time.Duration {
+	if s.mu.TryLock() {
+		defer s.mu.Unlock()
+		return time.Since(time.Unix(0, s.lastUsed.Load()))
+	}
+	return 0
+}
+
+func (s *persistentShell) close() {
+	s.broken.Store(true)
+	_ = s.session.Terminate()
+}
+
+func (s *persistentShell) networkDenied(host string) {
+	s.deniedMu.Lock()
+	onDenied := s.onDenied
+	s.deniedMu.Unlock()
+	if onDenied != nil {
+		onDenied(host)
+	}
+}
+
+func (s *persistentShell) setOnDenied(onDenied func(string)) {
+	s.deniedMu.Lock()
+	s.onDenied = onDenied
+	s.deniedMu.Unlock()
+}
+
+// run sends one command to the shell and waits for its sentinel lines. A
+// timeout, a cancelled context or an output limit stops the whole shell,
+// which is replaced on the next call.
+func (s *persistentShell) run(ctx context.Context, req CommandRequest) (ShellResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.runLocked(ctx, req)
+}
+
+func (s *persistentShell) runLocked(ctx context.Context, req CommandRequest) (ShellResult, error) {
+	defer s.touch()
+	s.setOnDenied(req.OnNetworkDenied)
+	defer s.setOnDenied(nil)
+
+	token := "__CAELIS_SHELL_" + strings.ReplaceAll(uuid.New().String(), "-", "")
+	if err := s.session.WriteInput([]byte(persistentShellScript(req, token))); err != nil {
+		s.broken.Store(true)
+		return ShellResult{}, fmt.Errorf("execenv: persistent shell is unavailable: %w", err)
+	}
+	var timeout <-chan time.Time
+	if req.Timeout > 0 {
+		timer := time.NewTimer(req.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	ticker := time.NewTicker(persistentShellPollInterval)
+	defer ticker.Stop()
+
+	capture := &shellCapture{
+		stop:     []byte("\n" + token),
+		limit:    req.Limits.MaxOutputBytes,
+		onOutput: req.OnOutput,
+	}
+	lastOutput := time.Now()
+	for {
+		// Check for exit before reading so the read sees all final output.
+		status, statusErr := s.session.Status()
+		stdout, stderr, nextStdout, nextStderr, err := s.session.ReadOutput(s.stdoutAt, s.stderrAt)
+		if err != nil {
+			s.close()
+			return ShellResult{}, fmt.Errorf("execenv: persistent shell is unavailable: %w", err)
+		}
+		s.stdoutAt, s.stderrAt = nextStdout, nextStderr
+		if len(stdout)+len(stderr) > 0 {
+			lastOutput = time.Now()
+		}
+		if capture.add(stdout, stderr) {
+			s.close()
+			result := capture.result()
+			result.LimitExceeded = ResourceLimitOutput
+			return ShellResult{CommandResult: result}, resourceLimitError("command", ResourceLimitOutput, req.Limits, result)
+		}
+		if exitCode, cwd, ok := capture.done(); ok {
+			result := capture.result()
+			result.ExitCode = exitCode
+			if req.Limits.CPUTime > 0 && cpuLimitExitStatus(exitCode) {
+				result.LimitExceeded = ResourceLimitCPU
+				return ShellResult{CommandResult: result, Cwd: cwd}, resourceLimitError("command", ResourceLimitCPU, req.Limits, result)
+			}
+			return ShellResult{CommandResult: result, Cwd: cwd}, nil
+		}
+		if statusErr != nil {
+			s.close()
+			return ShellResult{}, fmt.Errorf("execenv: persistent shell is unavailable: %w", statusErr)
+		}
+		if status.State != SessionStateRunning {
+			// The command ended the shell itself, for example with exit.
+			s.broken.Store(true)
+			result := capture.result()
+			result.ExitCode = status.ExitCode
+			return ShellResult{CommandResult: result}, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			s.close()
+			return ShellResult{CommandResult: capture.result()}, ctx.Err()
+		case <-timeout:
+			s.close()
+			result := capture.result()
+			return ShellResult{CommandResult: result}, s.timeoutError("timed out after "+req.Timeout.String(), result)
+		case <-ticker.C:
+			if req.IdleTimeout > 0 && time.Since(lastOutput) > req.IdleTimeout {
+				s.close()
+				result := capture.result()
+				return ShellResult{CommandResult: result}, s.timeoutError("produced no output for "+req.IdleTimeout.String(), result)
+			}
+		}
+	}
+}
+
+// shellCommand is one command running in a persistent shell. It is a Session
+// so a task can keep waiting on the command after the tool call that started
+// it has returned. It holds the shell's lock until the command ends.
+type shellCommand struct {
+	ref     CommandSessionRef
+	command string
+	dir     string
+	started time.Time
+	cancel  context.CancelFunc
+	done    chan struct{}
+
+	mu           sync.Mutex
+	stdout       []byte
+	stderr       []byte
+	lastActivity time.Time
+	terminated   bool
+	result       ShellResult
+	err          error
+}
+
+// startShellCommand runs req in shell, which the caller has locked, until it
+// ends or is terminated. The command outlives ctx, whose values it keeps.
+func startShellCommand(ctx context.Context, shell *persistentShell, backend string, req CommandRequest, restarted bool) *shellCommand {
+	runCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	now := time.Now()
+	cmd := &shellCommand{
+		ref:          CommandSessionRef{Backend: backend, SessionID: "shell-" + uuid.New().String()},
+		command:      req.Command,
+		dir:          req.Dir,
+		started:      now,
+		cancel:       cancel,
+		done:         make(chan struct{}),
+		lastActivity: now,
+	}
+	onOutput := req.OnOutput
+	req.OnOutput = func(chunk CommandOutputChunk) {
+		cmd.addOutput(chunk)
+		if onOutput != nil {
+			onOutput(chunk)
+		}
+	}
+	go func() {
+		defer cancel()
+		result, err := shell.runLocked(runCtx, req)
+		shell.mu.Unlock()
+		result.Restarted = restarted
+		cmd.finish(result, err)
+	}()
+	return cmd
+}
+
+func (c *shellCommand) addOutput(chunk CommandOutputChunk) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if chunk.Stream == "stderr" {
+		c.stderr = append(c.stderr, chunk.Text...)
+	} else {
+		c.stdout = append(c.stdout, chunk.Text...)
+	}
+	c.lastActivity = time.Now()
+}
+
+func (c *shellCommand) finish(result ShellResult, err error) {
+	c.mu.Lock()
+	c.result, c.err = result, err
+	c.stdout = []byte(result.Stdout)
+	c.stderr = []byte(result.Stderr)
+	c.mu.Unlock()
+	close(c.done)
+}
+
+func (c *shellCommand) Ref() CommandSessionRef {
+	return c.ref
+}
+
+func (c *shellCommand) WriteInput([]byte) error {
+	return fmt.Errorf("execenv: persistent shell commands do not read input")
+}
+
+func (c *shellCommand) ReadOutput(stdoutMarker, stderrMarker int64) (stdout, stderr []byte, newStdoutMarker, newStderrMarker int64, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stdoutMarker = min(max(stdoutMarker, 0), int64(len(c.stdout)))
+	stderrMarker = min(max(stderrMarker, 0), int64(len(c.stderr)))
+	stdout = append([]byte(nil), c.stdout[stdoutMarker:]...)
+	stderr = append([]byte(nil), c.stderr[stderrMarker:]...)
+	return stdout, stderr, int64(len(c.stdout)), int64(len(c.stderr)), nil
+}
+
+func (c *shellCommand) Status() (SessionStatus, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	status := SessionStatus{
+		ID:           c.ref.SessionID,
+		Command:      c.command,
+		Dir:          c.dir,
+		State:        SessionStateRunning,
+		StartTime:    c.started,
+		LastActivity: c.lastActivity,
+		StdoutBytes:  int64(len(c.stdout)),
+		StderrBytes:  int64(len(c.stderr)),
+	}
+	select {
+	case <-c.done:
+	default:
+		return status, nil
+	}
+	status.ExitCode = c.result.ExitCode
+	status.LimitExceeded = c.result.LimitExceeded
+	switch {
+	case c.terminated:
+		status.State = SessionStateTerminated
+	case c.err != nil:
+		status.State = SessionStateError
+		status.Error = c.err.Error()
+	default:
+		status.State = SessionStateCompleted
+	}
+	return status, nil
+}
+
+// Wait waits up to timeout, or without limit when it is zero, for the
+// command to end. It returns context.DeadlineExceeded while it still runs.
+func (c *shellCommand) Wait(ctx context.Context, timeout time.Duration) (CommandResult, error) {
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case <-c.done:
+		result, _ := c.ShellResult()
+		return result.CommandResult, nil
+	case <-ctx.Done():
+		return CommandResult{}, ctx.Err()
+	case <-expired:
+		return CommandResult{}, context.DeadlineExceeded
+	}
+}
+
+// Terminate stops the command, and with it the shell, which is replaced on
+// the next command.
+func (c *shellCommand) Terminate() error {
+	c.mu.Lock()
+	select {
+	case <-c.done:
+	default:
+		c.terminated = true
+	}
+	c.mu.Unlock()
+	c.cancel()
+	return nil
+}
+
+// ShellResult returns the result of a command that has ended.
+func (c *shellCommand) ShellResult() (ShellResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.result, c.err
+}
+
+func (c *shellCommand) waitResult() (ShellResult, error) {
+	<-c.done
+	return c.ShellResult()
+}
+
+func (s *persistentShell) timeoutError(what string, result CommandResult) error {
+	code := ErrorCodeHostCommandTimeout
+	if s.kind == BackendKindSandbox {
+		code = ErrorCodeSandboxCommandTimeout
+	}
+	return NewCodedError(
+		code,
+		"tool: command %s; its persistent shell was stopped and starts fresh on the next command; %s",
+		what,
+		commandOutputSummary(result),
+	)
+}
+
+// persistentShellScript wraps command for the shell's stdin. The command runs
+// through eval in the shell itself, so cd, exports and functions persist, and
+// a syntax error in it cannot break the framing. Stdin is /dev/null so the
+// command cannot read the scripts that follow. Afterwards the shell prints
+// the exit status and working directory after the token on stdout, and the
+// token alone on stderr.
+func persistentShellScript(req CommandRequest, token string) string {
+	var b strings.Builder
+	setLimits, resetLimits := req.Limits.softLimitCommands()
+	for _, line := range setLimits {
+		b.WriteString(line + "\n")
+	}
+	b.WriteString("{ ")
+	if dir := strings.TrimSpace(req.Dir); dir != "" {
+		b.WriteString("cd -- " + shellQuote(dir) + " && ")
+	}
+	b.WriteString("eval " + shellQuote(req.Command) + "\n} </dev/null\n")
+	b.WriteString("__caelis_status=$?\n")
+	for _, line := range resetLimits {
+		b.WriteString(line + "\n")
+	}
+	fmt.Fprintf(&b, "printf '\\n%%s %%d %%s\\n' %s \"$__caelis_status\" \"$PWD\"\n", token)
+	fmt.Fprintf(&b, "printf '\\n%%s\\n' %s >&2\n", token)
+	return b.String()
+}
+
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// shellCapture collects one command's stdout and stderr up to the sentinel
+// lines and streams the part that is known not to belong to them.
+type shellCapture struct {
+	stop     []byte
+	limit    int64
+	onOutput func(CommandOutputChunk)
+
+	stdout     []byte
+	stderr     []byte
+	stdoutSent int
+	stderrSent int
+}
+
+// add appends new output and reports whether it went over the output limit.
+func (c *shellCapture) add(stdout, stderr []byte) bool {
+	c.stdout = append(c.stdout, stdout...)
+	c.stderr = append(c.stderr, stderr...)
+	stdoutEnd, stderrEnd, over := c.visible()
+	if c.onOutput != nil {
+		if stdoutEnd > c.stdoutSent {
+			c.onOutput(CommandOutputChunk{Stream: "stdout", Text: string(c.stdout[c.stdoutSent:stdoutEnd])})
+		}
+		if stderrEnd > c.stderrSent {
+			c.onOutput(CommandOutputChunk{Stream: "stderr", Text: string(c.stderr[c.stderrSent:stderrEnd])})
+		}
+	}
+	c.stdoutSent = max(c.stdoutSent, stdoutEnd)
+	c.stderrSent = max(c.stderrSent, stderrEnd)
+	return over
+}
+
+// visible returns how much of each stream is command output, trimmed to the
+// output limit.
+func (c *shellCapture) visible() (int, int, bool) {
+	stdoutEnd, _ := shellOutputEnd(c.stdout, c.stop)
+	stderrEnd, _ := shellOutputEnd(c.stderr, c.stop)
+	if c.limit <= 0 || int64(stdoutEnd+stderrEnd) <= c.limit {
+		return stdoutEnd, stderrEnd, false
+	}
+	stdoutEnd = int(min(int64(stdoutEnd), c.limit))
+	stderrEnd = int(c.limit) - stdoutEnd
+	return stdoutEnd, stderrEnd, true
+}
+
+// done returns the exit status and working directory once both sentinel
+// lines have arrived.
+func (c *shellCapture) done() (int, string, bool) {
+	if _, ok := shellOutputEnd(c.stderr, c.stop); !ok {
+		return 0, "", false
+	}
+	end, ok := shellOutputEnd(c.stdout, c.stop)
+	if !ok {
+		return 0, "", false
+	}
+	line := c.stdout[end+len(c.stop):]
+	lineEnd := bytes.IndexByte(line, '\n')
+	if lineEnd < 0 {
+		return 0, "", false
+	}
+	status, cwd, _ := strings.Cut(strings.TrimPrefix(string(line[:lineEnd]), " "), " ")
+	exitCode, err := strconv.Atoi(status)
+	if err != nil {
+		exitCode = -1
+	}
+	return exitCode, cwd, true
+}
+
+func (c *shellCapture) result() CommandResult {
+	stdoutEnd, stderrEnd, _ := c.visible()
+	return CommandResult{
+		Stdout: string(c.stdout[:stdoutEnd]),
+		Stderr: string(c.stderr[:stderrEnd]),
+	}
+}
+
+// shellOutputEnd returns where command output ends in data: at stop when it
+// is present, or otherwise before any trailing bytes that could be the start
+// of stop.
+func shellOutputEnd(data []byte, stop []byte) (int, bool) {
+	if i := bytes.Index(data, stop); i >= 0 {
+		return i, true
+	}
+	for n := min(len(stop)-1, len(data)); n > 0; n-- {
+		if bytes.HasSuffix(data, stop[:n]) {
+			return len(data) - n, false
+		}
+	}
+	return len(data), false
+}
@@ -0,0 +1,176 @@
+package execenv
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newPersistentShellRuntime(t *testing.T) PersistentShellRunner {
+	t.Helper()
+	// Keep the login shell from loading the developer's profile.
+	t.Setenv("HOME", t.TempDir())
+	rt, err := New(Config{
+		PermissionMode:  PermissionModeFullControl,
+		SandboxType:     platformDefaultSandboxType(),
+		SandboxRunner:   noopRunner{},
+		PersistentShell: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if closer, ok := rt.(interface{ Close() error }); ok {
+			_ = closer.Close()
+		}
+	})
+	runner, ok := rt.(PersistentShellRunner)
+	if !ok || !runner.PersistentShellEnabled() {
+		t.Fatal("expected the runtime to run persistent shells")
+	}
+	return runner
+}
+
+func TestRunInShell_KeepsStateBetweenCommands(t *testing.T) {
+	runner := newPersistentShellRuntime(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	first, err := runner.RunInShell(ctx, "session-1", CommandRequest{
+		Command: "cd " + dir + " && export CAELIS_SHELL_TEST=kept && greet() { echo \"hi $1\"; }",
+		Timeout: time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Cwd != dir || first.Restarted {
+		t.Fatalf("expected cwd %q on a fresh shell, got %+v", dir, first)
+	}
+	second, err := runner.RunInShell(ctx, "session-1", CommandRequest{
+		Command: "pwd; echo \"$CAELIS_SHELL_TEST\"; greet there; printf partial",
+		Timeout: time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := dir + "\nkept\nhi there\npartial"; second.Stdout != want || second.ExitCode != 0 {
+		t.Fatalf("expected stdout %q, got %+v", want, second)
+	}
+
+	broken, err := runner.RunInShell(ctx, "session-1", CommandRequest{Command: "echo 'unterminated", Timeout: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if broken.ExitCode == 0 || broken.Cwd != dir {
+		t.Fatalf("expected a syntax error to fail without losing the shell, got %+v", broken)
+	}
+
+	other, err := runner.RunInShell(ctx, "session-2", CommandRequest{Command: "echo \"[$CAELIS_SHELL_TEST]\"", Timeout: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.Stdout != "[]\n" {
+		t.Fatalf("expected another key to get its own shell, got %q", other.Stdout)
+	}
+}
+
+func TestRunInShell_RestartsAfterTimeoutAndExit(t *testing.T) {
+	runner := newPersistentShellRuntime(t)
+	ctx := context.Background()
+
+	if _, err := runner.RunInShell(ctx, "session", CommandRequest{Command: "export CAELIS_SHELL_TEST=lost", Timeout: time.Minute}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := runner.RunInShell(ctx, "session", CommandRequest{Command: "sleep 30", Timeout: 300 * time.Millisecond})
+	if !IsErrorCode(err, ErrorCodeHostCommandTimeout) {
+		t.Fatalf("expected a host timeout, got %v", err)
+	}
+	after, err := runner.RunInShell(ctx, "session", CommandRequest{Command: "echo \"[$CAELIS_SHELL_TEST]\"", Timeout: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !after.Restarted || after.Stdout != "[]\n" {
+		t.Fatalf("expected a fresh shell after the timeout, got %+v", after)
+	}
+
+	exited, err := runner.RunInShell(ctx, "session", CommandRequest{Command: "exit 3", Timeout: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exited.ExitCode != 3 {
+		t.Fatalf("expected exit code 3, got %+v", exited)
+	}
+	next, err := runner.RunInShell(ctx, "session", CommandRequest{Command: "true", Timeout: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !next.Restarted {
+		t.Fatalf("expected the shell to restart after exit, got %+v", next)
+	}
+}
+
+func TestRunInShell_OutputLimitStopsShell(t *testing.T) {
+	runner := newPersistentShellRuntime(t)
+	result, err := runner.RunInShell(context.Background(), "session", CommandRequest{
+		Command: "yes",
+		Timeout: time.Minute,
+		Limits:  ResourceLimits{MaxOutputBytes: 100},
+	})
+	if !IsErrorCode(err, ErrorCodeResourceLimit) {
+		t.Fatalf("expected a resource limit error, got %v", err)
+	}
+	if result.LimitExceeded != ResourceLimitOutput || len(result.Stdout)+len(result.Stderr) != 100 {
+		t.Fatalf("expected output cut at 100 bytes, got %q / %q (%s)", result.Stdout, result.Stderr, result.LimitExceeded)
+	}
+}
+
+func TestStartInShell_RunsInBackgroundAndKeepsTheShellBusy(t *testing.T) {
+	runner := newPersistentShellRuntime(t)
+	ctx := context.Background()
+
+	session, err := runner.StartInShell(ctx, "session-1", CommandRequest{Command: "sleep 0.5; echo done", Timeout: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := session.Wait(ctx, 20*time.Millisecond); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the command to still run, got %v", err)
+	}
+	if status, _ := session.Status(); status.State != SessionStateRunning {
+		t.Fatalf("expected a running status, got %+v", status)
+	}
+	if _, err := runner.RunInShell(ctx, "session-1", CommandRequest{Command: "true"}); !errors.Is(err, ErrShellBusy) {
+		t.Fatalf("expected a busy shell, got %v", err)
+	}
+	result, err := session.Wait(ctx, 0)
+	if err != nil || result.Stdout != "done\n" {
+		t.Fatalf("expected the command to finish, got %+v, %v", result, err)
+	}
+	if status, _ := session.Status(); status.State != SessionStateCompleted {
+		t.Fatalf("expected a completed status, got %+v", status)
+	}
+	next, err := runner.RunInShell(ctx, "session-1", CommandRequest{Command: "echo again"})
+	if err != nil || next.Stdout != "again\n" || next.Restarted {
+		t.Fatalf("expected the same shell to run the next command, got %+v, %v", next, err)
+	}
+}
+
+func TestShellOutputEnd(t *testing.T) {
+	stop := []byte("\n__TOKEN")
+	for _, tc := range []struct {
+		data  string
+		end   int
+		found bool
+	}{
+		{"output", 6, false},
+		{"output\n__TO", 6, false},
+		{"output\n", 6, false},
+		{"output\n__TOKEN 0 /tmp\n", 6, true},
+		{"out\n__TOKEN\n", 3, true},
+	} {
+		end, found := shellOutputEnd([]byte(tc.data), stop)
+		if end != tc.end || found != tc.found {
+			t.Fatalf("shellOutputEnd(%q) = %d, %v; want %d, %v", tc.data, end, found, tc.end, tc.found)
+		}
+	}
+}
@@ -77,6 +77,18 @@ func (f *policyFileSystem) WriteFile(path string, data []byte, perm os.FileMode)
 	return f.base.WriteFile(path, data, perm)
 }
 
+// Remove deletes path through base when base can remove files, so removals
+// reach remote file systems, and on the host otherwise.
+func (f *policyFileSystem) Remove(path string) error {
+	if err := f.checkWritePath(path); err != nil {
+		return err
+	}
+	if remover, ok := f.base.(interface{ Remove(string) error }); ok {
+		return remover.Remove(path)
+	}
+	return os.Remove(path)
+}
+
 func (f *policyFileSystem) checkWritePath(path string) error {
 	policy := f.policy()
 	switch policy.Type {
@@ -3,6 +3,7 @@
 package execenv
 
 import (
+	"errors"
 	"os/exec"
 	"syscall"
 )
@@ -17,3 +18,26 @@ func setProcessGroup(cmd *exec.Cmd) {
 func killProcessGroup(pid int) error {
 	return syscall.Kill(-pid, syscall.SIGKILL)
 }
+
+// cpuLimitSignaled reports whether a command ended on SIGXCPU, either
+// directly or through a shell that reports it as exit status 128+SIGXCPU.
+func cpuLimitSignaled(waitErr error) bool {
+	var exitErr *exec.ExitError
+	if !errors.As(waitErr, &exitErr) {
+		return false
+	}
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	if !ok {
+		return false
+	}
+	if status.Signaled() {
+		return status.Signal() == syscall.SIGXCPU
+	}
+	return cpuLimitExitStatus(status.ExitStatus())
+}
+
+// cpuLimitExitStatus reports whether a shell exit status means the command
+// was killed by SIGXCPU.
+func cpuLimitExitStatus(code int) bool {
+	return code == 128+int(syscall.SIGXCPU)
+}
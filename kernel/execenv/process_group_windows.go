@@ -14,3 +14,11 @@ func setProcessGroup(cmd *exec.Cmd) {
 func killProcessGroup(pid int) error {
 	return errors.New("process groups are not supported on windows")
 }
+
+func cpuLimitSignaled(error) bool {
+	return false
+}
+
+func cpuLimitExitStatus(int) bool {
+	return false
+}
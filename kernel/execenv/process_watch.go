@@ -16,31 +16,41 @@ type activityWriter struct {
 	lastOutput *atomic.Int64
 	stream     string
 	onOutput   func(CommandOutputChunk)
+	limiter    *commandLimiter
 }
 
 func (w *activityWriter) Write(p []byte) (int, error) {
 	if w.lastOutput != nil {
 		w.lastOutput.Store(time.Now().UnixNano())
 	}
-	if w.onOutput != nil && len(p) > 0 {
+	kept := p[:w.limiter.allowOutput(len(p))]
+	if w.onOutput != nil && len(kept) > 0 {
 		w.onOutput(CommandOutputChunk{
 			Stream: w.stream,
-			Text:   string(p),
+			Text:   string(kept),
 		})
 	}
-	if w.buffer == nil {
-		return len(p), nil
+	if w.buffer != nil {
+		if _, err := w.buffer.Write(kept); err != nil {
+			return 0, err
+		}
 	}
-	return w.buffer.Write(p)
+	return len(p), nil
 }
 
 func waitWithIdleTimeout(ctx context.Context, cmd *exec.Cmd, idleTimeout time.Duration, lastOutput *atomic.Int64) error {
 	if cmd == nil {
 		return errors.New("nil command")
 	}
+	return waitWithIdleTimeoutFunc(ctx, cmd.Wait, func() { _ = killProcess(cmd) }, idleTimeout, lastOutput)
+}
+
+// waitWithIdleTimeoutFunc waits for a started command through wait and stops
+// it with kill when ctx ends or no output arrives for idleTimeout.
+func waitWithIdleTimeoutFunc(ctx context.Context, wait func() error, kill func(), idleTimeout time.Duration, lastOutput *atomic.Int64) error {
 	waitCh := make(chan error, 1)
 	go func() {
-		waitCh <- cmd.Wait()
+		waitCh <- wait()
 	}()
 
 	if idleTimeout <= 0 {
@@ -48,7 +58,7 @@ func waitWithIdleTimeout(ctx context.Context, cmd *exec.Cmd, idleTimeout time.Du
 		case err := <-waitCh:
 			return err
 		case <-ctx.Done():
-			_ = killProcess(cmd)
+			kill()
 			<-waitCh
 			return ctx.Err()
 		}
@@ -62,7 +72,7 @@ func waitWithIdleTimeout(ctx context.Context, cmd *exec.Cmd, idleTimeout time.Du
 		case err := <-waitCh:
 			return err
 		case <-ctx.Done():
-			_ = killProcess(cmd)
+			kill()
 			<-waitCh
 			return ctx.Err()
 		case <-ticker.C:
@@ -71,7 +81,7 @@ func waitWithIdleTimeout(ctx context.Context, cmd *exec.Cmd, idleTimeout time.Du
 			}
 			last := time.Unix(0, lastOutput.Load())
 			if time.Since(last) > idleTimeout {
-				_ = killProcess(cmd)
+				kill()
 				<-waitCh
 				return errIdleTimeout
 			}
@@ -0,0 +1,224 @@
+package execenv
+
+import (
+	"fmt"
+	"math"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Resource limit names reported in CommandResult.LimitExceeded and
+// SessionStatus.LimitExceeded.
+const (
+	ResourceLimitCPU       = "cpu"
+	ResourceLimitMemory    = "memory"
+	ResourceLimitProcesses = "processes"
+	ResourceLimitOutput    = "output"
+)
+
+// ResourceLimits caps what one command may consume. Zero fields mean no
+// limit. CPU time, memory and process count are set as rlimits in the command
+// shell and, where a delegated cgroup v2 is available, also as a child cgroup
+// for the command. The output cap counts stdout and stderr together.
+type ResourceLimits struct {
+	CPUTime        time.Duration `json:"cpu_time,omitempty"`
+	MemoryBytes    int64         `json:"memory_bytes,omitempty"`
+	MaxProcesses   int           `json:"max_processes,omitempty"`
+	MaxOutputBytes int64         `json:"max_output_bytes,omitempty"`
+}
+
+// IsZero reports whether no limit is set.
+func (l ResourceLimits) IsZero() bool {
+	return l.CPUTime <= 0 && l.MemoryBytes <= 0 && l.MaxProcesses <= 0 && l.MaxOutputBytes <= 0
+}
+
+// Describe returns the configured value of the named limit for messages.
+func (l ResourceLimits) Describe(name string) string {
+	switch name {
+	case ResourceLimitCPU:
+		return l.CPUTime.String()
+	case ResourceLimitMemory:
+		return fmt.Sprintf("%d bytes", l.MemoryBytes)
+	case ResourceLimitProcesses:
+		return fmt.Sprintf("%d processes", l.MaxProcesses)
+	case ResourceLimitOutput:
+		return fmt.Sprintf("%d bytes", l.MaxOutputBytes)
+	default:
+		return ""
+	}
+}
+
+// MergeResourceLimits applies per-call limits on top of defaults. A call may
+// tighten a default limit but not raise or remove it; a limit the defaults
+// leave unset takes the call's value.
+func MergeResourceLimits(defaults, requested ResourceLimits) ResourceLimits {
+	return ResourceLimits{
+		CPUTime:        tighterLimit(defaults.CPUTime, requested.CPUTime),
+		MemoryBytes:    tighterLimit(defaults.MemoryBytes, requested.MemoryBytes),
+		MaxProcesses:   tighterLimit(defaults.MaxProcesses, requested.MaxProcesses),
+		MaxOutputBytes: tighterLimit(defaults.MaxOutputBytes, requested.MaxOutputBytes),
+	}
+}
+
+func tighterLimit[T ~int | ~int64](base, requested T) T {
+	switch {
+	case base <= 0:
+		return max(requested, 0)
+	case requested <= 0:
+		return base
+	default:
+		return min(base, requested)
+	}
+}
+
+// shellCommand prefixes command with ulimit calls for the CPU, memory and
+// process limits. A ulimit that fails is ignored: it only fails when the
+// inherited hard limit is already lower. The CPU hard limit sits one second
+// above the soft one so the command first gets SIGXCPU, which is how the
+// limit is recognised afterwards.
+func (l ResourceLimits) shellCommand(command string) string {
+	var lines []string
+	if l.CPUTime > 0 {
+		seconds := max(int64(math.Ceil(l.CPUTime.Seconds())), 1)
+		lines = append(lines,
+			fmt.Sprintf("ulimit -H -t %d 2>/dev/null", seconds+1),
+			fmt.Sprintf("ulimit -S -t %d 2>/dev/null", seconds),
+		)
+	}
+	if l.MemoryBytes > 0 {
+		lines = append(lines, fmt.Sprintf("ulimit -d %d 2>/dev/null", max(l.MemoryBytes/1024, 1)))
+	}
+	if l.MaxProcesses > 0 {
+		lines = append(lines, fmt.Sprintf("ulimit -u %d 2>/dev/null", l.MaxProcesses))
+	}
+	if len(lines) == 0 {
+		return command
+	}
+	return strings.Join(lines, "\n") + "\n" + command
+}
+
+// softLimitCommands returns ulimit calls that apply the CPU, memory and
+// process limits to the next command of a persistent shell, and the calls
+// that lift them afterwards. Only soft limits are set because a lowered hard
+// limit could not be raised again for later commands. The CPU limit also
+// counts the time the shell itself has used.
+func (l ResourceLimits) softLimitCommands() (set []string, reset []string) {
+	if l.CPUTime > 0 {
+		seconds := max(int64(math.Ceil(l.CPUTime.Seconds())), 1)
+		set = append(set, fmt.Sprintf("ulimit -S -t %d 2>/dev/null", seconds))
+		reset = append(reset, "ulimit -S -t hard 2>/dev/null")
+	}
+	if l.MemoryBytes > 0 {
+		set = append(set, fmt.Sprintf("ulimit -S -d %d 2>/dev/null", max(l.MemoryBytes/1024, 1)))
+		reset = append(reset, "ulimit -S -d hard 2>/dev/null")
+	}
+	if l.MaxProcesses > 0 {
+		set = append(set, fmt.Sprintf("ulimit -S -u %d 2>/dev/null", l.MaxProcesses))
+		reset = append(reset, "ulimit -S -u hard 2>/dev/null")
+	}
+	return set, reset
+}
+
+// commandLimiter enforces one command's limits around its process: it joins
+// the process to a cgroup when one is available, cuts output at the output
+// limit and works out afterwards which limit, if any, stopped the command.
+type commandLimiter struct {
+	limits ResourceLimits
+	cgroup *commandCgroup
+
+	mu     sync.Mutex
+	stop   func()
+	output int64
+	hit    string
+}
+
+func newCommandLimiter(limits ResourceLimits) *commandLimiter {
+	if limits.IsZero() {
+		return nil
+	}
+	return &commandLimiter{limits: limits, cgroup: newCommandCgroup(limits)}
+}
+
+// attach puts cmd in the limiter's cgroup and kills it when output goes over
+// the limit. Call it after cmd.SysProcAttr is set up.
+func (l *commandLimiter) attach(cmd *exec.Cmd) {
+	if l == nil || cmd == nil {
+		return
+	}
+	l.cgroup.attach(cmd)
+	l.mu.Lock()
+	if l.stop == nil {
+		l.stop = func() { _ = killProcess(cmd) }
+	}
+	l.mu.Unlock()
+}
+
+// onExceeded replaces how the command is stopped when output goes over the
+// limit.
+func (l *commandLimiter) onExceeded(stop func()) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	l.stop = stop
+	l.mu.Unlock()
+}
+
+// allowOutput returns how many of n new output bytes fit under the output
+// limit. The first time the limit is reached the command is stopped.
+func (l *commandLimiter) allowOutput(n int) int {
+	if l == nil || l.limits.MaxOutputBytes <= 0 {
+		return n
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	remaining := l.limits.MaxOutputBytes - l.output
+	if int64(n) <= remaining {
+		l.output += int64(n)
+		return n
+	}
+	l.output = l.limits.MaxOutputBytes
+	if l.hit == "" {
+		l.hit = ResourceLimitOutput
+		if l.stop != nil {
+			go l.stop()
+		}
+	}
+	return int(max(remaining, 0))
+}
+
+// finish releases the cgroup and returns the limit that stopped the command,
+// or "" when it exited on its own. waitErr is the error from cmd.Wait.
+func (l *commandLimiter) finish(waitErr error) string {
+	if l == nil {
+		return ""
+	}
+	defer l.cgroup.Close()
+	l.mu.Lock()
+	hit := l.hit
+	l.mu.Unlock()
+	if waitErr == nil || hit != "" {
+		return hit
+	}
+	if hit := l.cgroup.exceeded(); hit != "" {
+		return hit
+	}
+	if l.limits.CPUTime > 0 && cpuLimitSignaled(waitErr) {
+		return ResourceLimitCPU
+	}
+	return ""
+}
+
+// resourceLimitError reports a command that a resource limit stopped.
+func resourceLimitError(subject string, limit string, limits ResourceLimits, result CommandResult) error {
+	return NewCodedError(
+		ErrorCodeResourceLimit,
+		"tool: %s was stopped after reaching its %s limit (%s); %s",
+		subject,
+		limit,
+		limits.Describe(limit),
+		commandOutputSummary(result),
+	)
+}
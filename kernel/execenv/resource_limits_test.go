@@ -0,0 +1,76 @@
+package execenv
+
+import (
+	"context"
+	stdruntime "runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMergeResourceLimits_CallCanOnlyTighten(t *testing.T) {
+	defaults := ResourceLimits{CPUTime: time.Minute, MemoryBytes: 1 << 30, MaxOutputBytes: 1 << 20}
+	got := MergeResourceLimits(defaults, ResourceLimits{
+		CPUTime:      2 * time.Minute,
+		MemoryBytes:  256 << 20,
+		MaxProcesses: 64,
+	})
+	want := ResourceLimits{CPUTime: time.Minute, MemoryBytes: 256 << 20, MaxProcesses: 64, MaxOutputBytes: 1 << 20}
+	if got != want {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+	if merged := MergeResourceLimits(ResourceLimits{}, ResourceLimits{}); !merged.IsZero() {
+		t.Fatalf("expected no limits, got %+v", merged)
+	}
+}
+
+func TestResourceLimitsShellCommand(t *testing.T) {
+	if got := (ResourceLimits{}).shellCommand("make"); got != "make" {
+		t.Fatalf("expected the command unchanged without limits, got %q", got)
+	}
+	got := ResourceLimits{CPUTime: 1500 * time.Millisecond, MemoryBytes: 64 << 20, MaxProcesses: 32}.shellCommand("make")
+	for _, want := range []string{"ulimit -H -t 3 ", "ulimit -S -t 2 ", "ulimit -d 65536 ", "ulimit -u 32 "} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("expected %q in %q", want, got)
+		}
+	}
+	if !strings.HasSuffix(got, "\nmake") {
+		t.Fatalf("expected the command last, got %q", got)
+	}
+}
+
+func TestHostRunner_RunStopsAtOutputLimit(t *testing.T) {
+	runner := newHostRunner()
+	result, err := runner.Run(context.Background(), CommandRequest{
+		Command: "yes caelis",
+		Timeout: 10 * time.Second,
+		Limits:  ResourceLimits{MaxOutputBytes: 64},
+	})
+	if !IsErrorCode(err, ErrorCodeResourceLimit) {
+		t.Fatalf("expected a resource limit error, got %v", err)
+	}
+	if result.LimitExceeded != ResourceLimitOutput {
+		t.Fatalf("expected the output limit to be reported, got %q", result.LimitExceeded)
+	}
+	if got := len(result.Stdout) + len(result.Stderr); got != 64 {
+		t.Fatalf("expected output cut at 64 bytes, got %d", got)
+	}
+}
+
+func TestHostRunner_RunStopsAtCPULimit(t *testing.T) {
+	if stdruntime.GOOS == "windows" {
+		t.Skip("rlimits are not available on windows")
+	}
+	runner := newHostRunner()
+	result, err := runner.Run(context.Background(), CommandRequest{
+		Command: "while :; do :; done",
+		Timeout: 20 * time.Second,
+		Limits:  ResourceLimits{CPUTime: time.Second},
+	})
+	if !IsErrorCode(err, ErrorCodeResourceLimit) {
+		t.Fatalf("expected a resource limit error, got %v", err)
+	}
+	if result.LimitExceeded != ResourceLimitCPU {
+		t.Fatalf("expected the cpu limit to be reported, got %q", result.LimitExceeded)
+	}
+}
@@ -38,6 +38,15 @@ type SandboxPolicy struct {
 	ReadableRoots    []string          `json:"readable_roots"`
 	WritableRoots    []string          `json:"writable_roots"`
 	ReadOnlySubpaths []string          `json:"read_only_subpaths"`
+	// NetworkAllowlist switches sandboxed commands to proxied network: they
+	// reach only these hosts, through a filtering proxy started per command.
+	NetworkAllowlist []string `json:"network_allowlist,omitempty"`
+}
+
+// NetworkProxied reports whether sandboxed commands get network only through
+// the allowlist proxy.
+func (p SandboxPolicy) NetworkProxied() bool {
+	return !p.NetworkAccess && len(p.NetworkAllowlist) > 0
 }
 
 // ExecutionRoute indicates where one command should run.
@@ -85,6 +94,15 @@ type Config struct {
 	SandboxType       string
 	SandboxPolicy     SandboxPolicy
 	SandboxHelperPath string
+	// ResourceLimits are the default limits for every command. A request's
+	// own Limits may tighten them but not raise them.
+	ResourceLimits ResourceLimits
+	// PersistentShell lets tools run commands through RunInShell, in one
+	// long-lived shell per agent session instead of a fresh shell per command.
+	PersistentShell bool
+	// SSH moves the workspace to a remote machine: every command runs there
+	// and the file tools work on its files. The local sandbox is not used.
+	SSH *SSHConfig
 
 	FileSystem    FileSystem
 	HostRunner    CommandRunner
@@ -118,6 +136,15 @@ type CommandRequest struct {
 	EnvOverrides          map[string]string
 	SandboxPolicyOverride *SandboxPolicy
 	OnOutput              func(CommandOutputChunk)
+	// OnNetworkDenied is called with the host whenever the sandbox network
+	// proxy refuses a connection for this command.
+	OnNetworkDenied func(host string)
+	// Limits caps the command's CPU time, memory, process count and output.
+	Limits ResourceLimits
+	// Overlay runs the command over a staging layer: its writes under the
+	// overlay root are staged instead of applied. Only sandboxes that can
+	// mount the layer, currently bwrap, run such commands.
+	Overlay *Overlay
 }
 
 type CommandOutputChunk struct {
@@ -130,6 +157,9 @@ type CommandResult struct {
 	Stdout   string
 	Stderr   string
 	ExitCode int
+	// LimitExceeded names the resource limit that stopped the command, such
+	// as ResourceLimitOutput, or is empty.
+	LimitExceeded string
 }
 
 type BackendKind string
@@ -137,6 +167,7 @@ type BackendKind string
 const (
 	BackendKindHost    BackendKind = "host"
 	BackendKindSandbox BackendKind = "sandbox"
+	BackendKindSSH     BackendKind = "ssh"
 )
 
 type BackendCapabilities struct {
@@ -175,6 +206,16 @@ type RuntimeState struct {
 	FallbackReason   string
 	Backends         []BackendSnapshot
 	RouterState      RouterState
+	// Remote is set when the workspace lives on a remote machine.
+	Remote *RemoteWorkspace
+}
+
+// RemoteWorkspace describes the machine a remote runtime works on.
+type RemoteWorkspace struct {
+	// Target is user@host, with the port when it is not 22.
+	Target string
+	// Workdir is the workspace directory on the remote machine.
+	Workdir string
 }
 
 type RouteRequest struct {
@@ -245,6 +286,47 @@ type PermissionModeSetter interface {
 	SetPermissionMode(mode PermissionMode) error
 }
 
+// NetworkAllowlistUpdater allows callers to widen the sandbox network
+// allowlist of a live runtime, for example after the user approves a host
+// that the proxy denied.
+type NetworkAllowlistUpdater interface {
+	AllowNetworkHosts(hosts ...string)
+}
+
+// PersistentShellRunner runs commands in a long-lived shell per key, usually
+// the agent session ID, so the working directory, exported variables and
+// shell functions carry over from one command to the next.
+type PersistentShellRunner interface {
+	PersistentShellEnabled() bool
+	RunInShell(ctx context.Context, key string, req CommandRequest) (ShellResult, error)
+	StartInShell(ctx context.Context, key string, req CommandRequest) (ShellSession, error)
+}
+
+// ShellSession is a command started in a persistent shell. It reads no input.
+type ShellSession interface {
+	Session
+	// ShellResult returns the command's result once Wait has reported that
+	// it ended.
+	ShellResult() (ShellResult, error)
+}
+
+// ShellResult is the result of one command run in a persistent shell.
+type ShellResult struct {
+	CommandResult
+	// Cwd is the shell's working directory after the command.
+	Cwd string
+	// Restarted reports that the key's earlier shell was replaced before the
+	// command ran, so its state was lost. That happens after a timeout, an
+	// exit from the shell, or a change of backend or sandbox policy.
+	Restarted bool
+}
+
+// OverlayProvider is implemented by runtimes that can stage writes in an
+// Overlay. Overlay returns nil while writes go straight to the workspace.
+type OverlayProvider interface {
+	Overlay() *Overlay
+}
+
 // ApprovalRequiredError indicates that the call should be reviewed by upper
 // application layer. Kernel tool layer does not handle approval workflow.
 type ApprovalRequiredError struct {
@@ -263,6 +345,7 @@ func cloneSandboxPolicy(policy SandboxPolicy) SandboxPolicy {
 	policy.ReadableRoots = append([]string(nil), policy.ReadableRoots...)
 	policy.WritableRoots = append([]string(nil), policy.WritableRoots...)
 	policy.ReadOnlySubpaths = append([]string(nil), policy.ReadOnlySubpaths...)
+	policy.NetworkAllowlist = append([]string(nil), policy.NetworkAllowlist...)
 	return policy
 }
 
@@ -324,6 +407,121 @@ func commandIsApprovalWhitelisted(command string) bool {
 	return sawCommand
 }
 
+// CommandSegments splits a shell command on control operators and returns the
+// tokens of each simple command, without leading environment assignments.
+func CommandSegments(command string) [][]string {
+	var out [][]string
+	for _, segment := range shellCommandSegments(command) {
+		if tokens := shellSegmentTokens(segment); len(tokens) > 0 {
+			out = append(out, tokens)
+		}
+	}
+	return out
+}
+
+// PlainCommandSegments is CommandSegments for callers that approve a command
+// by matching the words of each simple command. It reports false when the
+// command uses shell syntax that could run something those words do not show:
+// a background &, a newline, command or process substitution, redirection
+// other than fd duplication such as 2>&1, or a leading variable assignment.
+func PlainCommandSegments(command string) ([][]string, bool) {
+	if !isPlainShellSyntax(command) {
+		return nil, false
+	}
+	var out [][]string
+	for _, segment := range shellCommandSegments(command) {
+		if fields := strings.Fields(segment); len(fields) > 0 && strings.Contains(fields[0], "=") {
+			return nil, false
+		}
+		if tokens := shellSegmentTokens(segment); len(tokens) > 0 {
+			out = append(out, tokens)
+		}
+	}
+	return out, len(out) > 0
+}
+
+func isPlainShellSyntax(command string) bool {
+	var (
+		squote bool
+		dquote bool
+		escape bool
+	)
+	runes := []rune(command)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if r == '\n' || r == '\r' {
+			return false
+		}
+		if escape {
+			escape = false
+			continue
+		}
+		if squote {
+			if r == '\'' {
+				squote = false
+			}
+			continue
+		}
+		switch r {
+		case '\\':
+			escape = true
+		case '\'':
+			if !dquote {
+				squote = true
+			}
+		case '"':
+			dquote = !dquote
+		case '`':
+			return false
+		case '$':
+			if i+1 < len(runes) && runes[i+1] == '(' {
+				return false
+			}
+		case '<', '>':
+			if dquote {
+				continue
+			}
+			end, ok := fdDuplicationEnd(runes, i)
+			if !ok {
+				return false
+			}
+			i = end
+		case '&':
+			if dquote {
+				continue
+			}
+			if i+1 < len(runes) && runes[i+1] == '&' {
+				i++
+				continue
+			}
+			return false
+		}
+	}
+	return !squote && !dquote && !escape
+}
+
+// fdDuplicationEnd reports whether the redirection at runes[i] only
+// duplicates a file descriptor, as in 2>&1, and returns the index of its last
+// rune.
+func fdDuplicationEnd(runes []rune, i int) (int, bool) {
+	j := i + 1
+	if j >= len(runes) || runes[j] != '&' {
+		return 0, false
+	}
+	j++
+	start := j
+	for j < len(runes) && runes[j] >= '0' && runes[j] <= '9' {
+		j++
+	}
+	if j == start {
+		return 0, false
+	}
+	if j < len(runes) && !strings.ContainsRune(" \t;|&", runes[j]) {
+		return 0, false
+	}
+	return j - 1, true
+}
+
 func isApprovalWhitelistedBase(base string) bool {
 	switch strings.ToLower(strings.TrimSpace(base)) {
 	case "cd", "pwd", "ls", "stat", "file", "head", "tail", "cat", "grep", "egrep", "fgrep", "find", "which", "whereis", "env", "printenv", "uname", "id", "whoami":
@@ -695,6 +893,7 @@ func sandboxTypeCandidatesForPlatform(requested string, goos string) []string {
 }
 
 func deriveSandboxPolicy(mode PermissionMode, policy SandboxPolicy) SandboxPolicy {
+	policy.NetworkAllowlist = normalizeNetworkHosts(policy.NetworkAllowlist)
 	switch normalizeSandboxPolicyType(policy.Type, mode) {
 	case SandboxPolicyReadOnly:
 		policy.Type = SandboxPolicyReadOnly
@@ -709,9 +908,9 @@ func deriveSandboxPolicy(mode PermissionMode, policy SandboxPolicy) SandboxPolic
 		if len(policy.WritableRoots) == 0 {
 			policy.WritableRoots = []string{"."}
 		}
-		if !policy.NetworkAccess {
-			policy.NetworkAccess = true
-		}
+		// Workspace writes keep full network unless an allowlist asks for
+		// proxied access.
+		policy.NetworkAccess = len(policy.NetworkAllowlist) == 0
 	case SandboxPolicyExternal:
 		policy.Type = SandboxPolicyExternal
 	case SandboxPolicyDangerFull:
@@ -722,6 +921,7 @@ func deriveSandboxPolicy(mode PermissionMode, policy SandboxPolicy) SandboxPolic
 		policy.ReadableRoots = nil
 		policy.WritableRoots = nil
 		policy.ReadOnlySubpaths = nil
+		policy.NetworkAllowlist = nil
 	}
 	policy.ReadableRoots = normalizeStringList(policy.ReadableRoots)
 	policy.WritableRoots = normalizeStringList(policy.WritableRoots)
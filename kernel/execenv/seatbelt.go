@@ -83,25 +83,29 @@ func (s *seatbeltRunner) Run(ctx context.Context, req CommandRequest) (CommandRe
 	effectivePolicy := sandboxPolicyForCommand(s.policy, req)
 	profile := buildSeatbeltProfile(effectivePolicy, workDir)
 
-	args := []string{"-p", profile, "bash", "-lc", req.Command}
+	args := []string{"-p", profile, "bash", "-lc", req.Limits.shellCommand(req.Command)}
 	cmd := s.execCommand(runCtx, "sandbox-exec", args...)
 	applyNonInteractiveCommandDefaults(cmd)
 	if strings.TrimSpace(req.Dir) != "" {
 		cmd.Dir = req.Dir
 	}
 	cmd.Env = mergeCommandEnv(req.EnvOverrides)
+	limiter := newCommandLimiter(req.Limits)
+	limiter.attach(cmd)
 
 	var stdout bytes.Buffer
 	var stderr bytes.Buffer
 	lastOutput := atomic.Int64{}
 	lastOutput.Store(time.Now().UnixNano())
-	cmd.Stdout = &activityWriter{buffer: &stdout, lastOutput: &lastOutput, stream: "stdout", onOutput: req.OnOutput}
-	cmd.Stderr = &activityWriter{buffer: &stderr, lastOutput: &lastOutput, stream: "stderr", onOutput: req.OnOutput}
+	cmd.Stdout = &activityWriter{buffer: &stdout, lastOutput: &lastOutput, stream: "stdout", onOutput: req.OnOutput, limiter: limiter}
+	cmd.Stderr = &activityWriter{buffer: &stderr, lastOutput: &lastOutput, stream: "stderr", onOutput: req.OnOutput, limiter: limiter}
 
 	if err := cmd.Start(); err != nil {
+		limiter.finish(nil)
 		return CommandResult{}, fmt.Errorf("tool: seatbelt sandbox command start failed: %w", err)
 	}
 	waitErr := waitWithIdleTimeout(runCtx, cmd, req.IdleTimeout, &lastOutput)
+	limitHit := limiter.finish(waitErr)
 
 	result := CommandResult{
 		Stdout: stdout.String(),
@@ -111,6 +115,10 @@ func (s *seatbeltRunner) Run(ctx context.Context, req CommandRequest) (CommandRe
 		return result, nil
 	}
 	result.ExitCode = resolveExitCode(waitErr)
+	if limitHit != "" {
+		result.LimitExceeded = limitHit
+		return result, resourceLimitError("seatbelt sandbox command", limitHit, req.Limits, result)
+	}
 	if errors.Is(runCtx.Err(), context.DeadlineExceeded) || errors.Is(waitErr, context.DeadlineExceeded) {
 		label := "context deadline"
 		if req.Timeout > 0 {
@@ -159,6 +167,7 @@ func (s *seatbeltRunner) StartAsync(_ context.Context, req CommandRequest) (stri
 		OutputBufferCap: 256 * 1024,
 		Timeout:         req.Timeout,
 		IdleTimeout:     req.IdleTimeout,
+		Limits:          req.Limits,
 		BuildCommand: func(ctx context.Context, cfg AsyncSessionConfig) (*exec.Cmd, error) {
 			profile := buildSeatbeltProfile(effectivePolicy, workDir)
 			cmd := s.execCommand(ctx, "sandbox-exec", "-p", profile, "bash", "-lc", cfg.Command)
@@ -0,0 +1,853 @@
+package execenv
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const (
+	sshBackendName        = "ssh"
+	sshDefaultPort        = 22
+	sshDialTimeout        = 15 * time.Second
+	sshAsyncOutputCap     = 256 * 1024
+	sshFinishedSessionTTL = 30 * time.Minute
+)
+
+// SSHConfig points a runtime at a remote machine. Commands run there over
+// SSH and the file tools read and write there over SFTP, so the agent runs
+// locally but works in a checkout on, for example, a build machine.
+type SSHConfig struct {
+	Host string
+	// Port defaults to 22.
+	Port int
+	// User defaults to the local user name.
+	User string
+	// IdentityFile is the private key to log in with. Without one the keys
+	// of the SSH agent at SSH_AUTH_SOCK are used. Keys with a passphrase
+	// have to go through the agent.
+	IdentityFile string
+	// KnownHostsFile verifies the host key and defaults to
+	// ~/.ssh/known_hosts. Hosts that are not listed are refused.
+	KnownHostsFile string
+	// Workdir is the remote workspace. A relative path is taken from the
+	// remote home directory, which is also the default.
+	Workdir string
+}
+
+// Target returns user@host, with the port when it is not 22.
+func (c SSHConfig) Target() string {
+	target := c.Host
+	if c.Port != 0 && c.Port != sshDefaultPort {
+		target = net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+	}
+	if c.User == "" {
+		return target
+	}
+	return c.User + "@" + target
+}
+
+func (c SSHConfig) address() string {
+	return net.JoinHostPort(c.Host, strconv.Itoa(cmp.Or(c.Port, sshDefaultPort)))
+}
+
+// newSSHRuntimeView builds a runtime whose only backend is the remote
+// machine. The local sandbox cannot contain remote commands, so outside full
+// control they need approval instead, and the file tools keep to the remote
+// workdir.
+func newSSHRuntimeView(cfg Config, mode PermissionMode) (*runtimeView, error) {
+	remote, err := dialSSH(*cfg.SSH)
+	if err != nil {
+		return nil, err
+	}
+	workspace := remote.workspace()
+	rt := &runtimeView{
+		permissionMode:  mode,
+		resourceLimits:  cfg.ResourceLimits,
+		backends:        newBackendSet(newCommandBackend(sshBackendName, BackendKindSSH, newSSHRunner(remote)), nil),
+		remote:          &workspace,
+		persistentShell: cfg.PersistentShell,
+	}
+	rt.fs = newPolicyFileSystem(newSSHFileSystem(remote), rt.SandboxPolicy)
+	return rt, nil
+}
+
+// decideRemoteRoute sends every command to the remote backend. Commands
+// other than safe inspection need approval outside full control.
+func decideRemoteRoute(mode PermissionMode, backend string, command string) CommandDecision {
+	if mode == PermissionModeFullControl || commandIsApprovalWhitelisted(command) {
+		return CommandDecision{Route: ExecutionRouteHost, Backend: backend}
+	}
+	return CommandDecision{
+		Route:        ExecutionRouteHost,
+		Backend:      backend,
+		Escalation:   &EscalationReason{Message: "remote command over ssh requires approval in default permission mode"},
+		NeedApproval: true,
+	}
+}
+
+// sshRemote is the connection to one remote machine. It dials again on the
+// next use after the connection drops.
+type sshRemote struct {
+	cfg          SSHConfig
+	clientConfig *ssh.ClientConfig
+	agentConn    net.Conn
+	home         string
+	workdir      string
+
+	mu        sync.Mutex
+	conn      *ssh.Client
+	files     *sftp.Client
+	closed    bool
+	tempFiles []string
+}
+
+func dialSSH(cfg SSHConfig) (*sshRemote, error) {
+	cfg.Host = strings.TrimSpace(cfg.Host)
+	if cfg.Host == "" {
+		return nil, fmt.Errorf("execenv: ssh host is required")
+	}
+	cfg.Port = cmp.Or(cfg.Port, sshDefaultPort)
+	if cfg.User = strings.TrimSpace(cfg.User); cfg.User == "" {
+		current, err := user.Current()
+		if err != nil {
+			return nil, fmt.Errorf("execenv: ssh user is required: %w", err)
+		}
+		cfg.User = current.Username
+	}
+	hostKeys, err := sshHostKeyCallback(cfg.KnownHostsFile)
+	if err != nil {
+		return nil, err
+	}
+	auth, agentConn, err := sshAuthMethod(cfg.IdentityFile)
+	if err != nil {
+		return nil, err
+	}
+	remote := &sshRemote{
+		cfg: cfg,
+		clientConfig: &ssh.ClientConfig{
+			User:            cfg.User,
+			Auth:            []ssh.AuthMethod{auth},
+			HostKeyCallback: hostKeys,
+			Timeout:         sshDialTimeout,
+		},
+		agentConn: agentConn,
+	}
+	_, files, err := remote.clients()
+	if err != nil {
+		_ = remote.Close()
+		return nil, err
+	}
+	if remote.home, err = files.Getwd(); err != nil {
+		_ = remote.Close()
+		return nil, fmt.Errorf("execenv: resolve remote home on %s: %w", cfg.Target(), err)
+	}
+	remote.workdir = remote.resolve(remote.home, expandRemoteHome(strings.TrimSpace(cfg.Workdir), remote.home))
+	info, err := files.Stat(remote.workdir)
+	if err == nil && !info.IsDir() {
+		err = fmt.Errorf("not a directory")
+	}
+	if err != nil {
+		_ = remote.Close()
+		return nil, fmt.Errorf("execenv: remote workdir %s on %s: %w", remote.workdir, cfg.Target(), err)
+	}
+	return remote, nil
+}
+
+func sshHostKeyCallback(knownHostsFile string) (ssh.HostKeyCallback, error) {
+	knownHostsFile = strings.TrimSpace(knownHostsFile)
+	if knownHostsFile == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("execenv: locate ssh known_hosts: %w", err)
+		}
+		knownHostsFile = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	callback, err := knownhosts.New(expandLocalHome(knownHostsFile))
+	if err != nil {
+		return nil, fmt.Errorf("execenv: load ssh known hosts: %w; add the host with a first ssh login or ssh-keyscan", err)
+	}
+	return callback, nil
+}
+
+// sshAuthMethod logs in with identityFile, or with the agent's keys when no
+// file is given. The agent connection stays open for reconnects.
+func sshAuthMethod(identityFile string) (ssh.AuthMethod, net.Conn, error) {
+	if identityFile = strings.TrimSpace(identityFile); identityFile != "" {
+		data, err := os.ReadFile(expandLocalHome(identityFile))
+		if err != nil {
+			return nil, nil, fmt.Errorf("execenv: read ssh identity file: %w", err)
+		}
+		signer, err := ssh.ParsePrivateKey(data)
+		var missing *ssh.PassphraseMissingError
+		if errors.As(err, &missing) {
+			return nil, nil, fmt.Errorf("execenv: ssh identity file %s has a passphrase; add it to ssh-agent and leave identity_file unset", identityFile)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("execenv: parse ssh identity file %s: %w", identityFile, err)
+		}
+		return ssh.PublicKeys(signer), nil, nil
+	}
+	socket := strings.TrimSpace(os.Getenv("SSH_AUTH_SOCK"))
+	if socket == "" {
+		return nil, nil, fmt.Errorf("execenv: ssh needs an identity file or a running ssh-agent")
+	}
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, nil, fmt.Errorf("execenv: connect to ssh-agent: %w", err)
+	}
+	return ssh.PublicKeysCallback(agent.NewClient(conn).Signers), conn, nil
+}
+
+// clients returns the live connection and its SFTP client, dialing when
+// there is none.
+func (r *sshRemote) clients() (*ssh.Client, *sftp.Client, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil, nil, fmt.Errorf("execenv: ssh connection to %s is closed", r.cfg.Target())
+	}
+	if r.conn != nil {
+		return r.conn, r.files, nil
+	}
+	conn, err := ssh.Dial("tcp", r.cfg.address(), r.clientConfig)
+	if err != nil {
+		return nil, nil, fmt.Errorf("execenv: ssh connect to %s: %w", r.cfg.Target(), err)
+	}
+	files, err := sftp.NewClient(conn)
+	if err != nil {
+		_ = conn.Close()
+		return nil, nil, fmt.Errorf("execenv: start sftp on %s: %w", r.cfg.Target(), err)
+	}
+	r.conn, r.files = conn, files
+	go func() {
+		_ = conn.Wait()
+		r.mu.Lock()
+		if r.conn == conn {
+			r.conn, r.files = nil, nil
+		}
+		r.mu.Unlock()
+		_ = files.Close()
+	}()
+	return conn, files, nil
+}
+
+func (r *sshRemote) newSession() (*ssh.Session, error) {
+	conn, _, err := r.clients()
+	if err != nil {
+		return nil, err
+	}
+	session, err := conn.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("execenv: open ssh session on %s: %w", r.cfg.Target(), err)
+	}
+	return session, nil
+}
+
+// withFiles runs fn with the SFTP client, once more on a fresh connection
+// when the connection was lost underneath it.
+func (r *sshRemote) withFiles(fn func(*sftp.Client) error) error {
+	for attempt := 0; ; attempt++ {
+		_, files, err := r.clients()
+		if err != nil {
+			return err
+		}
+		err = fn(files)
+		if attempt > 0 || !errors.Is(err, sftp.ErrSSHFxConnectionLost) {
+			return err
+		}
+		r.dropConnection(files)
+	}
+}
+
+func (r *sshRemote) dropConnection(files *sftp.Client) {
+	r.mu.Lock()
+	conn := r.conn
+	if r.files != files {
+		conn = nil
+	}
+	r.mu.Unlock()
+	if conn != nil {
+		_ = conn.Close()
+		_ = conn.Wait()
+	}
+}
+
+// resolve makes a remote path absolute against dir.
+func (r *sshRemote) resolve(dir string, name string) string {
+	if name == "" {
+		return path.Clean(dir)
+	}
+	if path.IsAbs(name) {
+		return path.Clean(name)
+	}
+	return path.Join(dir, name)
+}
+
+func (r *sshRemote) workspace() RemoteWorkspace {
+	return RemoteWorkspace{Target: r.cfg.Target(), Workdir: r.workdir}
+}
+
+func (r *sshRemote) trackTempFile(name string) {
+	r.mu.Lock()
+	r.tempFiles = append(r.tempFiles, name)
+	r.mu.Unlock()
+}
+
+func (r *sshRemote) Close() error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil
+	}
+	r.closed = true
+	conn := r.conn
+	r.conn, r.files = nil, nil
+	tempFiles := r.tempFiles
+	r.tempFiles = nil
+	r.mu.Unlock()
+	var errs []error
+	if conn != nil {
+		errs = append(errs, conn.Close())
+	}
+	if r.agentConn != nil {
+		errs = append(errs, r.agentConn.Close())
+	}
+	for _, name := range tempFiles {
+		_ = os.Remove(name)
+	}
+	err := errors.Join(errs...)
+	if errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return err
+}
+
+// command builds the remote command line for req. The script runs in bash
+// like host commands do, whatever the remote login shell is.
+func (r *sshRemote) command(req CommandRequest) string {
+	var script strings.Builder
+	fmt.Fprintf(&script, "cd %s || exit 1\n", shellQuote(r.resolve(r.workdir, strings.TrimSpace(req.Dir))))
+	for _, entry := range sshCommandEnv(req.EnvOverrides) {
+		key, value, _ := strings.Cut(entry, "=")
+		fmt.Fprintf(&script, "export %s=%s\n", key, shellQuote(value))
+	}
+	script.WriteString(req.Limits.shellCommand(req.Command))
+	return "exec bash -lc " + shellQuote(script.String())
+}
+
+// sshCommandEnv returns the non-interactive defaults followed by the
+// request's overrides. SSH servers usually refuse env requests, so the
+// variables are exported in the command script instead.
+func sshCommandEnv(overrides map[string]string) []string {
+	env := append([]string(nil), defaultCommandEnvVars...)
+	keys := make([]string, 0, len(overrides))
+	for key := range overrides {
+		if key = strings.TrimSpace(key); isShellName(key) {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		env = append(env, key+"="+overrides[key])
+	}
+	return env
+}
+
+func isShellName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		switch {
+		case r == '_', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case r >= '0' && r <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func expandLocalHome(name string) string {
+	if name != "~" && !strings.HasPrefix(name, "~/") {
+		return name
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return name
+	}
+	return filepath.Join(home, strings.TrimPrefix(strings.TrimPrefix(name, "~"), "/"))
+}
+
+func expandRemoteHome(name string, home string) string {
+	if name != "~" && !strings.HasPrefix(name, "~/") {
+		return name
+	}
+	return path.Join(home, strings.TrimPrefix(name, "~"))
+}
+
+// requestSSHPty gives a TTY request a terminal. Its stderr then arrives on
+// stdout, as with the host runner's script wrapper.
+func requestSSHPty(session *ssh.Session) error {
+	if err := session.RequestPty("xterm", 40, 120, ssh.TerminalModes{
+		ssh.ECHO:          0,
+		ssh.TTY_OP_ISPEED: 14400,
+		ssh.TTY_OP_OSPEED: 14400,
+	}); err != nil {
+		return fmt.Errorf("tool: request remote terminal: %w", err)
+	}
+	return nil
+}
+
+// newRemoteCommandLimiter is newCommandLimiter without the local cgroup; the
+// CPU, memory and process limits are set with ulimit in the remote script.
+func newRemoteCommandLimiter(limits ResourceLimits) *commandLimiter {
+	if limits.IsZero() {
+		return nil
+	}
+	return &commandLimiter{limits: limits}
+}
+
+// remoteLimitHit returns the limit that stopped a remote command. waitErr is
+// the error from the SSH session.
+func remoteLimitHit(limiter *commandLimiter, limits ResourceLimits, waitErr error) string {
+	if hit := limiter.finish(nil); hit != "" {
+		return hit
+	}
+	var exitErr *ssh.ExitError
+	if limits.CPUTime <= 0 || !errors.As(waitErr, &exitErr) {
+		return ""
+	}
+	if exitErr.Signal() == "XCPU" || exitErr.ExitStatus() == 128+sshSIGXCPU {
+		return ResourceLimitCPU
+	}
+	return ""
+}
+
+// sshSIGXCPU is SIGXCPU on Linux and the BSDs, whatever the local platform.
+const sshSIGXCPU = 24
+
+func remoteExitCode(err error) int {
+	var exitErr *ssh.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitStatus()
+	}
+	return -1
+}
+
+func killSSHSession(session *ssh.Session) {
+	_ = session.Signal(ssh.SIGKILL)
+	_ = session.Close()
+}
+
+// sshRunner runs commands on the remote machine, one SSH session each.
+type sshRunner struct {
+	remote *sshRemote
+
+	mu       sync.Mutex
+	sessions map[string]*sshCommandSession
+	closed   bool
+}
+
+func newSSHRunner(remote *sshRemote) *sshRunner {
+	return &sshRunner{remote: remote, sessions: make(map[string]*sshCommandSession)}
+}
+
+func (r *sshRunner) Run(ctx context.Context, req CommandRequest) (CommandResult, error) {
+	runCtx := ctx
+	cancel := func() {}
+	if req.Timeout > 0 {
+		runCtx, cancel = context.WithTimeout(ctx, req.Timeout)
+	}
+	defer cancel()
+
+	session, err := r.remote.newSession()
+	if err != nil {
+		return CommandResult{}, err
+	}
+	defer session.Close()
+	if req.TTY {
+		if err := requestSSHPty(session); err != nil {
+			return CommandResult{}, err
+		}
+	}
+	limiter := newRemoteCommandLimiter(req.Limits)
+	limiter.onExceeded(func() { killSSHSession(session) })
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	lastOutput := atomic.Int64{}
+	lastOutput.Store(time.Now().UnixNano())
+	session.Stdout = &activityWriter{buffer: &stdout, lastOutput: &lastOutput, stream: "stdout", onOutput: req.OnOutput, limiter: limiter}
+	session.Stderr = &activityWriter{buffer: &stderr, lastOutput: &lastOutput, stream: "stderr", onOutput: req.OnOutput, limiter: limiter}
+	if err := session.Start(r.remote.command(req)); err != nil {
+		return CommandResult{}, fmt.Errorf("tool: command start failed: %w", err)
+	}
+	runErr := waitWithIdleTimeoutFunc(runCtx, session.Wait, func() { killSSHSession(session) }, req.IdleTimeout, &lastOutput)
+
+	result := CommandResult{
+		Stdout: stdout.String(),
+		Stderr: stderr.String(),
+	}
+	if runErr == nil {
+		return result, nil
+	}
+	result.ExitCode = remoteExitCode(runErr)
+	result.LimitExceeded = remoteLimitHit(limiter, req.Limits, runErr)
+	return result, commandRunError(runCtx, runErr, req, result)
+}
+
+// StartAsync starts a command in its own SSH session and returns its ID.
+func (r *sshRunner) StartAsync(_ context.Context, req CommandRequest) (string, error) {
+	session, err := r.remote.newSession()
+	if err != nil {
+		return "", err
+	}
+	one, err := startSSHCommandSession(session, r.remote.command(req), req)
+	if err != nil {
+		_ = session.Close()
+		return "", err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		one.terminate()
+		return "", fmt.Errorf("execenv: ssh runner is closed")
+	}
+	for id, other := range r.sessions {
+		if other.finishedFor() > sshFinishedSessionTTL {
+			delete(r.sessions, id)
+		}
+	}
+	r.sessions[one.id] = one
+	return one.id, nil
+}
+
+func (r *sshRunner) session(sessionID string) (*sshCommandSession, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	one, ok := r.sessions[strings.TrimSpace(sessionID)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrSessionNotFound, sessionID)
+	}
+	return one, nil
+}
+
+// WriteInput sends input to an async session's stdin.
+func (r *sshRunner) WriteInput(sessionID string, input []byte) error {
+	one, err := r.session(sessionID)
+	if err != nil {
+		return err
+	}
+	return one.writeInput(input)
+}
+
+// ReadOutput reads new output from an async session.
+func (r *sshRunner) ReadOutput(sessionID string, stdoutMarker, stderrMarker int64) (stdout, stderr []byte, newStdoutMarker, newStderrMarker int64, err error) {
+	one, err := r.session(sessionID)
+	if err != nil {
+		return nil, nil, 0, 0, err
+	}
+	stdout, newStdoutMarker = one.stdout.ReadNewSince(stdoutMarker)
+	stderr, newStderrMarker = one.stderr.ReadNewSince(stderrMarker)
+	return stdout, stderr, newStdoutMarker, newStderrMarker, nil
+}
+
+// GetSessionStatus returns the status of an async session.
+func (r *sshRunner) GetSessionStatus(sessionID string) (SessionStatus, error) {
+	one, err := r.session(sessionID)
+	if err != nil {
+		return SessionStatus{}, err
+	}
+	return one.status(), nil
+}
+
+// WaitSession waits for an async session to complete with optional timeout.
+func (r *sshRunner) WaitSession(ctx context.Context, sessionID string, timeout time.Duration) (CommandResult, error) {
+	one, err := r.session(sessionID)
+	if err != nil {
+		return CommandResult{}, err
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	select {
+	case <-one.done:
+		return one.result(), nil
+	case <-ctx.Done():
+		return CommandResult{}, ctx.Err()
+	}
+}
+
+// TerminateSession forcefully terminates an async session.
+func (r *sshRunner) TerminateSession(sessionID string) error {
+	one, err := r.session(sessionID)
+	if err != nil {
+		return err
+	}
+	one.terminate()
+	return nil
+}
+
+// ListSessions returns information about all async sessions.
+func (r *sshRunner) ListSessions() []SessionInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	infos := make([]SessionInfo, 0, len(r.sessions))
+	for _, one := range r.sessions {
+		status := one.status()
+		infos = append(infos, SessionInfo{
+			ID:           status.ID,
+			Command:      status.Command,
+			State:        status.State,
+			StartTime:    status.StartTime,
+			LastActivity: status.LastActivity,
+			ExitCode:     status.ExitCode,
+			HasOutput:    one.stdout.Len() > 0 || one.stderr.Len() > 0,
+		})
+	}
+	return infos
+}
+
+// Close terminates the async sessions and closes the connection.
+func (r *sshRunner) Close() error {
+	r.mu.Lock()
+	r.closed = true
+	sessions := r.sessions
+	r.sessions = map[string]*sshCommandSession{}
+	r.mu.Unlock()
+	for _, one := range sessions {
+		one.terminate()
+	}
+	return r.remote.Close()
+}
+
+// sshCommandSession is one async command on the remote machine. Its output
+// is kept in ring buffers like an AsyncSession's.
+type sshCommandSession struct {
+	id      string
+	command string
+	dir     string
+	tty     bool
+	start   time.Time
+	session *ssh.Session
+	stdin   io.WriteCloser
+	stdout  *RingBuffer
+	stderr  *RingBuffer
+	limits  ResourceLimits
+	limiter *commandLimiter
+	readers sync.WaitGroup
+	done    chan struct{}
+
+	lastActivity atomic.Int64
+
+	mu         sync.Mutex
+	state      SessionState
+	exitCode   int
+	exitErr    error
+	limitHit   string
+	finishedAt time.Time
+}
+
+func startSSHCommandSession(session *ssh.Session, command string, req CommandRequest) (*sshCommandSession, error) {
+	one := &sshCommandSession{
+		id:       uuid.New().String(),
+		command:  req.Command,
+		dir:      req.Dir,
+		tty:      req.TTY,
+		start:    time.Now(),
+		session:  session,
+		stdout:   NewRingBuffer(sshAsyncOutputCap),
+		stderr:   NewRingBuffer(sshAsyncOutputCap),
+		limits:   req.Limits,
+		limiter:  newRemoteCommandLimiter(req.Limits),
+		done:     make(chan struct{}),
+		state:    SessionStateRunning,
+		exitCode: -1,
+	}
+	one.lastActivity.Store(time.Now().UnixNano())
+	one.limiter.onExceeded(one.terminate)
+	if req.TTY {
+		if err := requestSSHPty(session); err != nil {
+			return nil, err
+		}
+	}
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stdin pipe: %w", err)
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stdout pipe: %w", err)
+	}
+	stderr, err := session.StderrPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stderr pipe: %w", err)
+	}
+	one.stdin = stdin
+	if err := session.Start(command); err != nil {
+		return nil, fmt.Errorf("failed to start command: %w", err)
+	}
+	one.readers.Add(2)
+	go one.readOutput(stdout, one.stdout)
+	go one.readOutput(stderr, one.stderr)
+	go one.waitForExit()
+	if req.Timeout > 0 || req.IdleTimeout > 0 {
+		go one.enforceTimeouts(req.Timeout, req.IdleTimeout)
+	}
+	return one, nil
+}
+
+func (s *sshCommandSession) readOutput(reader io.Reader, buffer *RingBuffer) {
+	defer s.readers.Done()
+	buf := make([]byte, 8192)
+	for {
+		n, err := reader.Read(buf)
+		if n = s.limiter.allowOutput(n); n > 0 {
+			_, _ = buffer.Write(buf[:n])
+			s.lastActivity.Store(time.Now().UnixNano())
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (s *sshCommandSession) waitForExit() {
+	err := s.session.Wait()
+	s.readers.Wait()
+	limitHit := remoteLimitHit(s.limiter, s.limits, err)
+	_ = s.session.Close()
+
+	s.mu.Lock()
+	s.exitErr = err
+	s.limitHit = limitHit
+	s.exitCode = 0
+	if err != nil {
+		s.exitCode = remoteExitCode(err)
+	}
+	if limitHit != "" {
+		s.state = SessionStateTerminated
+	}
+	if s.state == SessionStateRunning {
+		s.state = SessionStateCompleted
+	}
+	s.finishedAt = time.Now()
+	s.mu.Unlock()
+	close(s.done)
+}
+
+func (s *sshCommandSession) enforceTimeouts(timeout time.Duration, idleTimeout time.Duration) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			idle := time.Since(time.Unix(0, s.lastActivity.Load()))
+			if (timeout > 0 && time.Since(s.start) > timeout) || (idleTimeout > 0 && idle > idleTimeout) {
+				s.terminate()
+				return
+			}
+		}
+	}
+}
+
+func (s *sshCommandSession) writeInput(input []byte) error {
+	select {
+	case <-s.done:
+		return errors.New("session has already exited")
+	default:
+	}
+	if _, err := s.stdin.Write(input); err != nil {
+		return fmt.Errorf("failed to write to stdin: %w", err)
+	}
+	return nil
+}
+
+func (s *sshCommandSession) terminate() {
+	s.mu.Lock()
+	if s.state == SessionStateRunning {
+		s.state = SessionStateTerminated
+	}
+	s.mu.Unlock()
+	select {
+	case <-s.done:
+	default:
+		killSSHSession(s.session)
+	}
+}
+
+// finishedFor returns how long ago the command ended, or 0 while it runs.
+func (s *sshCommandSession) finishedFor() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.finishedAt.IsZero() {
+		return 0
+	}
+	return time.Since(s.finishedAt)
+}
+
+func (s *sshCommandSession) status() SessionStatus {
+	s.mu.Lock()
+	state, exitCode, exitErr, limitHit := s.state, s.exitCode, s.exitErr, s.limitHit
+	s.mu.Unlock()
+	status := SessionStatus{
+		ID:                   s.id,
+		Command:              s.command,
+		Dir:                  s.dir,
+		TTY:                  s.tty,
+		State:                state,
+		StartTime:            s.start,
+		LastActivity:         time.Unix(0, s.lastActivity.Load()),
+		ExitCode:             exitCode,
+		CaptureCapBytes:      int64(s.stdout.Cap()),
+		StdoutBytes:          s.stdout.TotalWritten(),
+		StderrBytes:          s.stderr.TotalWritten(),
+		StdoutRetainedBytes:  int64(s.stdout.Len()),
+		StderrRetainedBytes:  int64(s.stderr.Len()),
+		StdoutDroppedBytes:   s.stdout.DroppedBytes(),
+		StderrDroppedBytes:   s.stderr.DroppedBytes(),
+		StdoutEarliestMarker: s.stdout.EarliestMarker(),
+		StderrEarliestMarker: s.stderr.EarliestMarker(),
+		LimitExceeded:        limitHit,
+	}
+	if exitErr != nil && state == SessionStateError {
+		status.Error = exitErr.Error()
+	}
+	return status
+}
+
+func (s *sshCommandSession) result() CommandResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return CommandResult{
+		Stdout:        string(s.stdout.ReadAll()),
+		Stderr:        string(s.stderr.ReadAll()),
+		ExitCode:      s.exitCode,
+		LimitExceeded: s.limitHit,
+	}
+}
@@ -0,0 +1,144 @@
+package execenv
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/pkg/sftp"
+)
+
+// sshFileSystem serves the file tools from the remote machine over SFTP.
+// Relative paths are taken from the remote workdir.
+type sshFileSystem struct {
+	remote *sshRemote
+}
+
+func newSSHFileSystem(remote *sshRemote) *sshFileSystem {
+	return &sshFileSystem{remote: remote}
+}
+
+func (f *sshFileSystem) Getwd() (string, error)       { return f.remote.workdir, nil }
+func (f *sshFileSystem) UserHomeDir() (string, error) { return f.remote.home, nil }
+
+func (f *sshFileSystem) path(name string) string {
+	return f.remote.resolve(f.remote.workdir, filepath.ToSlash(name))
+}
+
+// Open copies the remote file to a local temporary file, since callers
+// expect an *os.File.
+func (f *sshFileSystem) Open(name string) (*os.File, error) {
+	data, err := f.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	local, err := os.CreateTemp("", "caelis-ssh-*")
+	if err != nil {
+		return nil, err
+	}
+	// Windows cannot remove an open file, so the copy is removed when the
+	// connection closes instead.
+	if runtime.GOOS == "windows" {
+		f.remote.trackTempFile(local.Name())
+	} else {
+		_ = os.Remove(local.Name())
+	}
+	if _, err := local.Write(data); err != nil {
+		_ = local.Close()
+		return nil, err
+	}
+	if _, err := local.Seek(0, io.SeekStart); err != nil {
+		_ = local.Close()
+		return nil, err
+	}
+	return local, nil
+}
+
+func (f *sshFileSystem) ReadDir(name string) ([]os.DirEntry, error) {
+	var infos []os.FileInfo
+	err := f.remote.withFiles(func(files *sftp.Client) (err error) {
+		infos, err = files.ReadDir(f.path(name))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]os.DirEntry, 0, len(infos))
+	for _, info := range infos {
+		entries = append(entries, fs.FileInfoToDirEntry(info))
+	}
+	slices.SortFunc(entries, func(a, b os.DirEntry) int { return strings.Compare(a.Name(), b.Name()) })
+	return entries, nil
+}
+
+func (f *sshFileSystem) Stat(name string) (os.FileInfo, error) {
+	var info os.FileInfo
+	err := f.remote.withFiles(func(files *sftp.Client) (err error) {
+		info, err = files.Stat(f.path(name))
+		return err
+	})
+	return info, err
+}
+
+func (f *sshFileSystem) ReadFile(name string) ([]byte, error) {
+	var data []byte
+	err := f.remote.withFiles(func(files *sftp.Client) error {
+		file, err := files.Open(f.path(name))
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		data, err = io.ReadAll(file)
+		return err
+	})
+	return data, err
+}
+
+// WriteFile truncates or creates the remote file. Like os.WriteFile, perm
+// only applies to new files.
+func (f *sshFileSystem) WriteFile(name string, data []byte, perm os.FileMode) error {
+	target := f.path(name)
+	return f.remote.withFiles(func(files *sftp.Client) error {
+		_, statErr := files.Stat(target)
+		file, err := files.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+		if err != nil {
+			return err
+		}
+		if errors.Is(statErr, fs.ErrNotExist) {
+			if err := file.Chmod(perm.Perm()); err != nil {
+				_ = file.Close()
+				return err
+			}
+		}
+		if _, err := file.Write(data); err != nil {
+			_ = file.Close()
+			return err
+		}
+		return file.Close()
+	})
+}
+
+func (f *sshFileSystem) Glob(pattern string) ([]string, error) {
+	var matches []string
+	err := f.remote.withFiles(func(files *sftp.Client) (err error) {
+		matches, err = files.Glob(f.path(pattern))
+		return err
+	})
+	return matches, err
+}
+
+func (f *sshFileSystem) WalkDir(root string, fn fs.WalkDirFunc) error {
+	return walkFileSystem(f, f.path(root), fn)
+}
+
+// Remove deletes a remote file or empty directory.
+func (f *sshFileSystem) Remove(name string) error {
+	return f.remote.withFiles(func(files *sftp.Client) error {
+		return files.Remove(f.path(name))
+	})
+}
@@ -0,0 +1,356 @@
+//go:build !windows
+
+package execenv
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// startTestSSHServer serves exec and sftp sessions on localhost, running
+// commands with the local sh, and returns a config that logs in to it.
+func startTestSSHServer(t *testing.T) SSHConfig {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostSigner, err := ssh.NewSignerFromKey(hostKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientPub, clientKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authorized, err := ssh.NewPublicKey(clientPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if bytes.Equal(key.Marshal(), authorized.Marshal()) {
+				return nil, nil
+			}
+			return nil, errors.New("unknown key")
+		},
+	}
+	config.AddHostKey(hostSigner)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveTestSSHConn(conn, config)
+		}
+	}()
+
+	dir := t.TempDir()
+	block, err := ssh.MarshalPrivateKey(clientKey, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	identity := filepath.Join(dir, "id_ed25519")
+	if err := os.WriteFile(identity, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	knownHosts := filepath.Join(dir, "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(listener.Addr().String())}, hostSigner.PublicKey())
+	if err := os.WriteFile(knownHosts, []byte(line+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	workdir := filepath.Join(dir, "workspace")
+	if err := os.Mkdir(workdir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	port, _ := strconv.Atoi(strings.TrimPrefix(listener.Addr().String(), "127.0.0.1:"))
+	return SSHConfig{
+		Host:           "127.0.0.1",
+		Port:           port,
+		User:           "tester",
+		IdentityFile:   identity,
+		KnownHostsFile: knownHosts,
+		Workdir:        workdir,
+	}
+}
+
+func serveTestSSHConn(conn net.Conn, config *ssh.ServerConfig) {
+	serverConn, channels, requests, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	defer serverConn.Close()
+	go ssh.DiscardRequests(requests)
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			_ = newChannel.Reject(ssh.UnknownChannelType, "sessions only")
+			continue
+		}
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go serveTestSSHSession(channel, channelRequests)
+	}
+}
+
+func serveTestSSHSession(channel ssh.Channel, requests <-chan *ssh.Request) {
+	defer channel.Close()
+	var cmd *exec.Cmd
+	for req := range requests {
+		switch req.Type {
+		case "pty-req", "env":
+			_ = req.Reply(true, nil)
+		case "exec":
+			var payload struct{ Command string }
+			if err := ssh.Unmarshal(req.Payload, &payload); err != nil {
+				_ = req.Reply(false, nil)
+				continue
+			}
+			cmd = exec.Command("sh", "-c", payload.Command)
+			cmd.Stdout = channel
+			cmd.Stderr = channel.Stderr()
+			stdin, err := cmd.StdinPipe()
+			if err == nil {
+				err = cmd.Start()
+			}
+			if err != nil {
+				_ = req.Reply(false, nil)
+				return
+			}
+			_ = req.Reply(true, nil)
+			go func() {
+				_, _ = io.Copy(stdin, channel)
+				_ = stdin.Close()
+			}()
+			go func() {
+				_ = cmd.Wait()
+				status := struct{ Status uint32 }{uint32(max(cmd.ProcessState.ExitCode(), 0))}
+				if cmd.ProcessState.ExitCode() < 0 {
+					status.Status = 137
+				}
+				_, _ = channel.SendRequest("exit-status", false, ssh.Marshal(status))
+				_ = channel.Close()
+			}()
+		case "signal":
+			if cmd != nil && cmd.Process != nil {
+				_ = cmd.Process.Kill()
+			}
+		case "subsystem":
+			var payload struct{ Name string }
+			if err := ssh.Unmarshal(req.Payload, &payload); err != nil || payload.Name != "sftp" {
+				_ = req.Reply(false, nil)
+				continue
+			}
+			server, err := sftp.NewServer(channel)
+			if err != nil {
+				_ = req.Reply(false, nil)
+				return
+			}
+			_ = req.Reply(true, nil)
+			go func() {
+				_ = server.Serve()
+				_ = channel.Close()
+			}()
+		default:
+			_ = req.Reply(false, nil)
+		}
+	}
+}
+
+func newTestSSHRuntime(t *testing.T, cfg SSHConfig, mode PermissionMode) Runtime {
+	t.Helper()
+	rt, err := New(Config{PermissionMode: mode, SSH: &cfg})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = Close(rt) })
+	return rt
+}
+
+func TestSSHRuntime_RunsCommandsRemotely(t *testing.T) {
+	cfg := startTestSSHServer(t)
+	rt := newTestSSHRuntime(t, cfg, PermissionModeFullControl)
+	if remote := rt.State().Remote; remote == nil || remote.Workdir != cfg.Workdir {
+		t.Fatalf("expected the remote workdir in the state, got %+v", remote)
+	}
+	if err := os.Mkdir(filepath.Join(cfg.Workdir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	result, err := rt.Execute(ctx, CommandRequest{
+		Command:      `pwd; echo "$GREETING"`,
+		Dir:          "sub",
+		EnvOverrides: map[string]string{"GREETING": "hello 'there'"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := filepath.Join(cfg.Workdir, "sub") + "\nhello 'there'\n"
+	if result.Stdout != want {
+		t.Fatalf("expected %q, got %q", want, result.Stdout)
+	}
+	result, err = rt.Execute(ctx, CommandRequest{Command: "echo oops >&2; exit 3"})
+	if err == nil || result.ExitCode != 3 || result.Stderr != "oops\n" {
+		t.Fatalf("expected exit code 3 with stderr, got %+v (%v)", result, err)
+	}
+
+	session, err := rt.Start(ctx, CommandRequest{Command: `read line; echo "got $line"`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ref := session.Ref(); ref.Backend != sshBackendName {
+		t.Fatalf("expected the session on the ssh backend, got %+v", ref)
+	}
+	if err := session.WriteInput([]byte("ping\n")); err != nil {
+		t.Fatal(err)
+	}
+	result, err = session.Wait(ctx, 10*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Stdout != "got ping\n" || result.ExitCode != 0 {
+		t.Fatalf("expected the async command to echo its input, got %+v", result)
+	}
+	if status, err := session.Status(); err != nil || status.State != SessionStateCompleted {
+		t.Fatalf("expected a completed session, got %+v (%v)", status, err)
+	}
+}
+
+func TestSSHFileSystem_WorksOnRemoteFiles(t *testing.T) {
+	cfg := startTestSSHServer(t)
+	rt := newTestSSHRuntime(t, cfg, PermissionModeDefault)
+	fsys := rt.FileSystem()
+	if wd, err := fsys.Getwd(); err != nil || wd != cfg.Workdir {
+		t.Fatalf("expected the remote workdir, got %q (%v)", wd, err)
+	}
+	if err := os.Mkdir(filepath.Join(cfg.Workdir, "docs"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.WriteFile("a.txt", []byte("alpha\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.WriteFile(filepath.Join(cfg.Workdir, "docs", "b.txt"), []byte("beta\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(filepath.Join(cfg.Workdir, "a.txt")); err != nil || string(data) != "alpha\n" {
+		t.Fatalf("expected the write on the remote side, got %q (%v)", data, err)
+	}
+	if info, err := fsys.Stat("a.txt"); err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("expected a new file to get perm, got %v (%v)", info, err)
+	}
+	if data, err := fsys.ReadFile("docs/b.txt"); err != nil || string(data) != "beta\n" {
+		t.Fatalf("expected to read the remote file, got %q (%v)", data, err)
+	}
+	file, err := fsys.Open("a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(file)
+	_ = file.Close()
+	if err != nil || string(data) != "alpha\n" {
+		t.Fatalf("expected Open to return the content, got %q (%v)", data, err)
+	}
+
+	entries, err := fsys.ReadDir(cfg.Workdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if want := []string{"a.txt", "docs"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("expected entries %v, got %v", want, names)
+	}
+	if matches, err := fsys.Glob("*/*.txt"); err != nil || len(matches) != 1 || !strings.HasSuffix(matches[0], "docs/b.txt") {
+		t.Fatalf("expected glob to find docs/b.txt, got %v (%v)", matches, err)
+	}
+	var walked []string
+	if err := fsys.WalkDir(".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			walked = append(walked, mustRel(cfg.Workdir, path))
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a.txt", filepath.Join("docs", "b.txt")}; !reflect.DeepEqual(walked, want) {
+		t.Fatalf("expected walk %v, got %v", want, walked)
+	}
+
+	remover := fsys.(interface{ Remove(string) error })
+	if err := remover.Remove("a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(cfg.Workdir, "a.txt")); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected the remote file removed, got %v", err)
+	}
+	if _, err := fsys.Stat("a.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected a not-exist error, got %v", err)
+	}
+	if err := fsys.WriteFile("/caelis-outside/c.txt", []byte("x"), 0o644); !errors.Is(err, fs.ErrPermission) {
+		t.Fatalf("expected writes outside the workdir to be refused, got %v", err)
+	}
+}
+
+func TestSSHRuntime_RemoteCommandsNeedApproval(t *testing.T) {
+	cfg := startTestSSHServer(t)
+	rt := newTestSSHRuntime(t, cfg, PermissionModeDefault)
+	decision := rt.DecideRoute("rm -rf build", SandboxPermissionAuto)
+	if !decision.NeedApproval || decision.Backend != sshBackendName || decision.Route != ExecutionRouteHost {
+		t.Fatalf("expected remote commands to need approval, got %+v", decision)
+	}
+	decision = rt.DecideRoute("ls", SandboxPermissionAuto)
+	if decision.NeedApproval || decision.Backend != sshBackendName {
+		t.Fatalf("expected safe inspection to pass, got %+v", decision)
+	}
+	if err := rt.(PermissionModeSetter).SetPermissionMode(PermissionModeFullControl); err != nil {
+		t.Fatal(err)
+	}
+	if decision := rt.DecideRoute("rm -rf build", SandboxPermissionRequireEscalated); decision.NeedApproval {
+		t.Fatalf("expected full control to skip approval, got %+v", decision)
+	}
+}
+
+func TestDialSSH_RefusesUnknownHost(t *testing.T) {
+	cfg := startTestSSHServer(t)
+	if err := os.WriteFile(cfg.KnownHostsFile, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_, err := New(Config{PermissionMode: PermissionModeDefault, SSH: &cfg})
+	var keyErr *knownhosts.KeyError
+	if !errors.As(err, &keyErr) {
+		t.Fatalf("expected an unknown host to be refused, got %v", err)
+	}
+}
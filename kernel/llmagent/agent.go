@@ -29,6 +29,9 @@ type Config struct {
 	Reasoning         model.ReasoningConfig
 	EmitPartialEvents bool
 	ToolTruncation    tool.TruncationPolicy
+	// Output requests structured output. The final answer of each run must
+	// validate against it or the run fails with a model.OutputValidationError.
+	Output *model.OutputSpec
 	// ToolResultSanitizer controls how tool results are transformed before
 	// being sent back to model context. Nil uses default sanitizer.
 	ToolResultSanitizer func(map[string]any) map[string]any
@@ -179,6 +182,11 @@ func (a *Agent) runOneTurn(
 	if err != nil {
 		return false, err
 	}
+	if len(resp.Message.ToolCalls()) == 0 {
+		if _, err := a.cfg.Output.ValidateOutput(resp.Message.TextContent()); err != nil {
+			return false, err
+		}
+	}
 	assistantMsg, err := a.emitAssistantTurn(ctx, state.hooks, resp, yield)
 	if err != nil {
 		return false, err
@@ -209,9 +217,13 @@ func (a *Agent) generateTurnResponse(
 	req := &model.Request{
 		Messages:  in.Messages,
 		Tools:     in.Tools,
+		Output:    a.cfg.Output,
 		Reasoning: a.cfg.Reasoning,
 		Stream:    a.cfg.StreamModel,
 	}
+	if sess := ctx.Session(); sess != nil {
+		req.CacheKey = strings.TrimSpace(sess.ID)
+	}
 	if prompt := strings.TrimSpace(a.cfg.SystemPrompt); prompt != "" {
 		req.Instructions = []model.Part{model.NewTextPart(prompt)}
 	}
@@ -226,6 +238,15 @@ func (a *Agent) generateTurnResponse(
 			return errYieldStopped
 		}
 		return nil
+	}, func(from, to model.LLM, cause error) error {
+		ev := session.MarkNotice(&session.Event{
+			ID:   newEventID(),
+			Time: time.Now(),
+		}, session.NoticeLevelWarn, failoverWarningText(from, to, cause))
+		if !yield(ev, nil) {
+			return errYieldStopped
+		}
+		return nil
 	})
 	if err != nil {
 		if interrupted := interruptedResponseError(err); interrupted != nil && !shouldSuppressInterruptedResponseWarning(interrupted) {
@@ -396,7 +417,7 @@ func (a *Agent) executeConcurrentToolCalls(
 func toolCallCanRunConcurrently(call model.ToolCall) bool {
 	name := strings.ToUpper(strings.TrimSpace(call.Name))
 	switch name {
-	case filesystem.WriteToolName, filesystem.PatchToolName:
+	case filesystem.WriteToolName, filesystem.PatchToolName, filesystem.EditToolName:
 		return false
 	default:
 		return true
@@ -440,7 +461,7 @@ func (a *Agent) executeToolCall(
 		return nil
 	}
 
-	toolCapability := toolcap.Of(t)
+	toolCapability := toolcap.OfCall(t, args)
 	toolCtx := toolexec.WithToolCallInfo(context.Context(ctx), call.Name, call.ID)
 	beforeIn, err := policy.ApplyBeforeTool(toolCtx, state.hooks, policy.ToolInput{
 		Call:       call,
@@ -473,7 +494,7 @@ func (a *Agent) executeToolCall(
 		execOut.Err = fmt.Errorf("llmagent: tool %q denied by policy: %s", call.Name, reason)
 		execOut.Result = toolErrorResult(call.Name, execOut.Err)
 	} else {
-		execOut.Capability = toolcap.Of(t)
+		execOut.Capability = toolcap.OfCall(t, args)
 		result, runErr := t.Run(toolCtx, args)
 		execOut.Err = runErr
 		if runErr != nil {
@@ -1085,11 +1106,17 @@ func (a *Agent) generateWithRetry(
 	req *model.Request,
 	onPartial func(*model.Response) error,
 	onRetry func(attempt int, maxRetries int, delay time.Duration, cause error) error,
+	onFailover func(from, to model.LLM, cause error) error,
 ) (*model.Response, error) {
+	// A fallback chain fails over to its next model as soon as the current
+	// one is unavailable; backoff retries only apply to the last model.
+	chain := model.FallbackModels(ctx.Model())
+	current := 0
 	retries := 0
 	for {
+		llm := chain[current]
 		emittedPartial := false
-		resp, err := collectLast(ctx, ctx.Model().Generate(ctx, req), func(partial *model.Response) error {
+		resp, err := collectLast(ctx, llm.Generate(ctx, req), func(partial *model.Response) error {
 			if partial != nil {
 				emittedPartial = true
 			}
@@ -1114,6 +1141,9 @@ func (a *Agent) generateWithRetry(
 					finishReason:   resp.FinishReason,
 				}
 			default:
+				if len(chain) > 1 {
+					resp.Message = model.WithMessageOrigin(resp.Message, llm, resp)
+				}
 				return resp, nil
 			}
 		}
@@ -1129,6 +1159,16 @@ func (a *Agent) generateWithRetry(
 		if errors.Is(err, errYieldStopped) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
 			return nil, err
 		}
+		if current < len(chain)-1 && model.ShouldFailover(err) {
+			if onFailover != nil {
+				if failoverErr := onFailover(llm, chain[current+1], err); failoverErr != nil {
+					return nil, failoverErr
+				}
+			}
+			current++
+			retries = 0
+			continue
+		}
 		if isNonRetryableHTTPError(err) {
 			return nil, err
 		}
@@ -1194,7 +1234,7 @@ func retryDelayForAttemptWithBounds(retry int, baseDelay, maxDelay time.Duration
 }
 
 func retryPolicyForError(err error) retryPolicy {
-	if isRateLimitError(err) {
+	if model.IsRateLimitError(err) {
 		return retryPolicy{
 			maxRetries:  rateLimitRequestMaxRetries,
 			baseDelay:   rateLimitRetryBaseDelay,
@@ -1209,22 +1249,8 @@ func retryPolicyForError(err error) retryPolicy {
 	}
 }
 
-func isRateLimitError(err error) bool {
-	if err == nil {
-		return false
-	}
-	text := strings.ToLower(strings.TrimSpace(err.Error()))
-	if text == "" {
-		return false
-	}
-	return strings.Contains(text, "http status 429") ||
-		strings.Contains(text, "rate limit") ||
-		strings.Contains(text, "ratelimit") ||
-		strings.Contains(text, "too many requests")
-}
-
 func isNonRetryableHTTPError(err error) bool {
-	status, ok := httpStatusCodeFromError(err)
+	status, ok := model.HTTPStatusFromError(err)
 	if !ok {
 		return false
 	}
@@ -1239,35 +1265,8 @@ func isNonRetryableHTTPError(err error) bool {
 	}
 }
 
-func httpStatusCodeFromError(err error) (int, bool) {
-	if err == nil {
-		return 0, false
-	}
-	text := strings.TrimSpace(err.Error())
-	idx := strings.Index(strings.ToLower(text), "http status ")
-	if idx < 0 {
-		return 0, false
-	}
-	rest := text[idx+len("http status "):]
-	if rest == "" {
-		return 0, false
-	}
-	end := 0
-	for end < len(rest) && rest[end] >= '0' && rest[end] <= '9' {
-		end++
-	}
-	if end == 0 {
-		return 0, false
-	}
-	var status int
-	if _, scanErr := fmt.Sscanf(rest[:end], "%d", &status); scanErr != nil || status <= 0 {
-		return 0, false
-	}
-	return status, true
-}
-
 func retryWarningText(attempt int, maxRetries int, delay time.Duration, cause error) string {
-	if isRateLimitError(cause) {
+	if model.IsRateLimitError(cause) {
 		return fmt.Sprintf(
 			"warn: llm request hit rate limits (HTTP 429 / Too Many Requests), retrying in %s (%d/%d). Waiting longer before retrying.",
 			formatRetryDelay(delay),
@@ -1279,6 +1278,18 @@ func retryWarningText(attempt int, maxRetries int, delay time.Duration, cause er
 	return fmt.Sprintf("warn: llm request failed, retrying in %s (%d/%d): %s", formatRetryDelay(delay), attempt, maxRetries, summary)
 }
 
+func failoverWarningText(from, to model.LLM, cause error) string {
+	return fmt.Sprintf("warn: llm request to %s failed, failing over to %s: %s", describeFailoverModel(from), describeFailoverModel(to), summarizeRetryCause(cause))
+}
+
+func describeFailoverModel(llm model.LLM) string {
+	name := strings.TrimSpace(llm.Name())
+	if provider := model.LLMProviderName(llm); provider != "" {
+		return provider + "/" + name
+	}
+	return name
+}
+
 func summarizeRetryCause(err error) string {
 	if err == nil {
 		return "unknown error"
@@ -1451,6 +1462,15 @@ func responseMeta(resp *model.Response) map[string]any {
 	if resp.Usage.TotalTokens > 0 {
 		usage["total_tokens"] = resp.Usage.TotalTokens
 	}
+	if resp.Usage.CacheReadTokens > 0 {
+		usage["cache_read_tokens"] = resp.Usage.CacheReadTokens
+	}
+	if resp.Usage.CacheWriteTokens > 0 {
+		usage["cache_write_tokens"] = resp.Usage.CacheWriteTokens
+	}
+	if resp.Usage.ReasoningTokens > 0 {
+		usage["reasoning_tokens"] = resp.Usage.ReasoningTokens
+	}
 	if len(usage) > 0 {
 		meta["usage"] = usage
 	}
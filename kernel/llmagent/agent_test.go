@@ -846,6 +846,57 @@ func TestLLMAgent_NonRetryableHTTP400SkipsAutomaticRetry(t *testing.T) {
 	}
 }
 
+func TestLLMAgent_FailsOverToNextModelWithNotice(t *testing.T) {
+	primaryAttempts := 0
+	primary := newTestLLM("primary", func(*model.Request) (*model.Response, error) {
+		primaryAttempts++
+		return nil, errors.New("model: http status 503 body=overloaded")
+	})
+	backup := newTestLLM("backup", func(*model.Request) (*model.Response, error) {
+		return &model.Response{Message: model.NewTextMessage(model.RoleAssistant, "done"), TurnComplete: true}, nil
+	})
+	ag, err := New(Config{Name: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := &testCtx{
+		Context: context.Background(),
+		session: &session.Session{AppName: "a", UserID: "u", ID: "s"},
+		history: []*session.Event{{Message: model.NewTextMessage(model.RoleUser, "hi")}},
+		llm:     model.NewFallback(primary, backup),
+		toolMap: map[string]tool.Tool{},
+	}
+	var (
+		warnings []string
+		answer   *model.Message
+	)
+	for ev, runErr := range ag.Run(ctx) {
+		if runErr != nil {
+			t.Fatal(runErr)
+		}
+		if notice, ok := session.EventNotice(ev); ok {
+			warnings = append(warnings, notice.Text)
+			continue
+		}
+		if ev != nil && ev.Message.Role == model.RoleAssistant {
+			msg := ev.Message
+			answer = &msg
+		}
+	}
+	if primaryAttempts != 1 {
+		t.Fatalf("expected failover without retrying the primary, got %d attempts", primaryAttempts)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "failing over to backup") {
+		t.Fatalf("expected one failover notice, got %+v", warnings)
+	}
+	if answer == nil || answer.TextContent() != "done" {
+		t.Fatalf("expected answer from backup model, got %+v", answer)
+	}
+	if answer.Origin == nil || answer.Origin.Model != "backup" {
+		t.Fatalf("expected origin to record the backup model, got %+v", answer.Origin)
+	}
+}
+
 func TestLLMAgent_PartialStreamInterruptionWarnsAndSkipsRetry(t *testing.T) {
 	attempts := 0
 	llm := newSeqLLM("fake", func(req *model.Request) []seqResult {
@@ -2429,3 +2480,52 @@ func TestLLMAgent_PersistsModelUsageMeta(t *testing.T) {
 		t.Fatalf("unexpected total_tokens: %#v", usage["total_tokens"])
 	}
 }
+
+func TestLLMAgent_RejectsFinalAnswerThatFailsOutputSchema(t *testing.T) {
+	var gotOutput *model.OutputSpec
+	llm := newTestLLM("fake", func(req *model.Request) (*model.Response, error) {
+		gotOutput = req.Output
+		return &model.Response{
+			Message:      model.NewTextMessage(model.RoleAssistant, `{"status":"done"}`),
+			TurnComplete: true,
+		}, nil
+	})
+	spec := &model.OutputSpec{
+		Mode:       model.OutputModeSchema,
+		JSONSchema: map[string]any{"type": "object", "required": []any{"count"}},
+	}
+	ag, err := New(Config{Name: "test", Output: spec})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := &testCtx{
+		Context: context.Background(),
+		session: &session.Session{AppName: "a", UserID: "u", ID: "s"},
+		history: []*session.Event{{Message: model.NewTextMessage(model.RoleUser, "hi")}},
+		llm:     llm,
+		toolMap: map[string]tool.Tool{},
+	}
+	var (
+		gotErr     error
+		assistants int
+	)
+	for ev, runErr := range ag.Run(ctx) {
+		if runErr != nil {
+			gotErr = runErr
+			continue
+		}
+		if ev != nil && ev.Message.Role == model.RoleAssistant {
+			assistants++
+		}
+	}
+	if gotOutput != spec {
+		t.Fatalf("expected output spec on model request, got %+v", gotOutput)
+	}
+	var validationErr *model.OutputValidationError
+	if !errors.As(gotErr, &validationErr) {
+		t.Fatalf("expected output validation error, got %v", gotErr)
+	}
+	if assistants != 0 {
+		t.Fatalf("expected rejected answer not to be emitted, got %d assistant events", assistants)
+	}
+}
@@ -0,0 +1,182 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"strings"
+)
+
+type fallbackChain interface {
+	FallbackModels() []LLM
+}
+
+type fallbackLLM struct {
+	models []LLM
+}
+
+// NewFallback returns an LLM that sends each request to primary and fails
+// over to the fallbacks, in order, when a model fails with an error that
+// ShouldFailover accepts before streaming any output. Without usable
+// fallbacks primary is returned unchanged.
+func NewFallback(primary LLM, fallbacks ...LLM) LLM {
+	if primary == nil {
+		return nil
+	}
+	models := []LLM{primary}
+	for _, one := range fallbacks {
+		if one != nil {
+			models = append(models, one)
+		}
+	}
+	if len(models) == 1 {
+		return primary
+	}
+	return &fallbackLLM{models: models}
+}
+
+// FallbackModels returns the ordered failover chain behind llm, or llm alone
+// when it is not a fallback chain.
+func FallbackModels(llm LLM) []LLM {
+	if llm == nil {
+		return nil
+	}
+	if chain, ok := llm.(fallbackChain); ok {
+		if models := chain.FallbackModels(); len(models) > 0 {
+			return models
+		}
+	}
+	return []LLM{llm}
+}
+
+func (l *fallbackLLM) FallbackModels() []LLM {
+	return append([]LLM(nil), l.models...)
+}
+
+func (l *fallbackLLM) Name() string {
+	return l.models[0].Name()
+}
+
+func (l *fallbackLLM) ProviderName() string {
+	return LLMProviderName(l.models[0])
+}
+
+func (l *fallbackLLM) ContextWindowTokens() int {
+	if capped, ok := l.models[0].(requestTraceContextWindow); ok {
+		return capped.ContextWindowTokens()
+	}
+	return 0
+}
+
+func (l *fallbackLLM) Generate(ctx context.Context, req *Request) iter.Seq2[*StreamEvent, error] {
+	return func(yield func(*StreamEvent, error) bool) {
+		for i, llm := range l.models {
+			emitted := false
+			failover := false
+			for event, err := range llm.Generate(ctx, req) {
+				if err != nil && !emitted && i < len(l.models)-1 && ShouldFailover(err) {
+					failover = true
+					break
+				}
+				if err == nil && event != nil && event.Response != nil && event.TurnComplete {
+					event.Response.Message = WithMessageOrigin(event.Response.Message, llm, event.Response)
+				}
+				emitted = true
+				if !yield(event, err) {
+					return
+				}
+			}
+			if !failover {
+				return
+			}
+		}
+	}
+}
+
+// ShouldFailover reports whether err means the model is unavailable or cannot
+// take the request, so another model should be tried: rate limits, 5xx
+// responses and context overflows.
+func ShouldFailover(err error) bool {
+	if err == nil {
+		return false
+	}
+	if IsContextOverflow(err) || IsRateLimitError(err) {
+		return true
+	}
+	status, ok := HTTPStatusFromError(err)
+	return ok && status >= 500
+}
+
+// IsRateLimitError reports whether err looks like a provider rate limit.
+func IsRateLimitError(err error) bool {
+	if err == nil {
+		return false
+	}
+	text := strings.ToLower(strings.TrimSpace(err.Error()))
+	if text == "" {
+		return false
+	}
+	return strings.Contains(text, "http status 429") ||
+		strings.Contains(text, "rate limit") ||
+		strings.Contains(text, "ratelimit") ||
+		strings.Contains(text, "too many requests")
+}
+
+// HTTPStatusFromError extracts the status code from provider errors of the
+// form "http status NNN".
+func HTTPStatusFromError(err error) (int, bool) {
+	if err == nil {
+		return 0, false
+	}
+	text := strings.TrimSpace(err.Error())
+	idx := strings.Index(strings.ToLower(text), "http status ")
+	if idx < 0 {
+		return 0, false
+	}
+	rest := text[idx+len("http status "):]
+	if rest == "" {
+		return 0, false
+	}
+	end := 0
+	for end < len(rest) && rest[end] >= '0' && rest[end] <= '9' {
+		end++
+	}
+	if end == 0 {
+		return 0, false
+	}
+	var status int
+	if _, scanErr := fmt.Sscanf(rest[:end], "%d", &status); scanErr != nil || status <= 0 {
+		return 0, false
+	}
+	return status, true
+}
+
+// LLMProviderName returns the provider name of llm when it reports one.
+func LLMProviderName(llm LLM) string {
+	if named, ok := llm.(requestTraceProvider); ok {
+		return strings.TrimSpace(named.ProviderName())
+	}
+	return ""
+}
+
+// WithMessageOrigin fills msg.Origin with the provider and model that
+// produced resp when the provider did not set it.
+func WithMessageOrigin(msg Message, llm LLM, resp *Response) Message {
+	if msg.Origin != nil {
+		return msg
+	}
+	origin := &MessageOrigin{}
+	if resp != nil {
+		origin.Provider = strings.TrimSpace(resp.Provider)
+		origin.Model = strings.TrimSpace(resp.Model)
+		origin.RawFinishReason = resp.RawFinishReason
+	}
+	if origin.Provider == "" && llm != nil {
+		origin.Provider = LLMProviderName(llm)
+	}
+	if origin.Model == "" && llm != nil {
+		origin.Model = strings.TrimSpace(llm.Name())
+	}
+	msg.Origin = origin
+	return msg
+}
@@ -0,0 +1,83 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"testing"
+)
+
+type fallbackTestLLM struct {
+	name  string
+	err   error
+	calls int
+}
+
+func (l *fallbackTestLLM) Name() string { return l.name }
+
+func (l *fallbackTestLLM) ProviderName() string { return "fallback-test" }
+
+func (l *fallbackTestLLM) Generate(context.Context, *Request) iter.Seq2[*StreamEvent, error] {
+	return func(yield func(*StreamEvent, error) bool) {
+		l.calls++
+		if l.err != nil {
+			yield(nil, l.err)
+			return
+		}
+		yield(StreamEventFromResponse(&Response{Message: NewTextMessage(RoleAssistant, l.name), TurnComplete: true}), nil)
+	}
+}
+
+func generateFallbackText(t *testing.T, llm LLM) (string, *MessageOrigin, error) {
+	t.Helper()
+	var (
+		text   string
+		origin *MessageOrigin
+	)
+	for event, err := range llm.Generate(context.Background(), &Request{}) {
+		if err != nil {
+			return "", nil, err
+		}
+		if event != nil && event.Response != nil && event.TurnComplete {
+			text = event.Response.Message.TextContent()
+			origin = event.Response.Message.Origin
+		}
+	}
+	return text, origin, nil
+}
+
+func TestFallbackLLMFailsOverOnUnavailableModels(t *testing.T) {
+	limited := &fallbackTestLLM{name: "limited", err: errors.New("model: http status 429 body=slow down")}
+	down := &fallbackTestLLM{name: "down", err: errors.New("model: http status 502")}
+	overflow := &fallbackTestLLM{name: "small", err: &ContextOverflowError{Cause: errors.New("prompt is too long")}}
+	backup := &fallbackTestLLM{name: "backup"}
+	llm := NewFallback(limited, down, overflow, backup)
+
+	if llm.Name() != "limited" {
+		t.Fatalf("expected primary name, got %q", llm.Name())
+	}
+	text, origin, err := generateFallbackText(t, llm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "backup" || origin == nil || origin.Model != "backup" || origin.Provider != "fallback-test" {
+		t.Fatalf("expected backup answer with origin, got %q %+v", text, origin)
+	}
+	if got := len(FallbackModels(llm)); got != 4 {
+		t.Fatalf("expected 4 models in chain, got %d", got)
+	}
+}
+
+func TestFallbackLLMReturnsOtherErrorsWithoutFailover(t *testing.T) {
+	unauthorized := &fallbackTestLLM{name: "primary", err: errors.New("model: http status 401")}
+	backup := &fallbackTestLLM{name: "backup"}
+	if _, _, err := generateFallbackText(t, NewFallback(unauthorized, backup)); err == nil {
+		t.Fatal("expected 401 to be returned")
+	}
+	if backup.calls != 0 {
+		t.Fatalf("expected backup untouched, got %d calls", backup.calls)
+	}
+	if single := NewFallback(backup); single != LLM(backup) {
+		t.Fatalf("expected a single model to be returned unwrapped")
+	}
+}
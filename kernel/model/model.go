@@ -21,10 +21,26 @@ const (
 type ContentPartType string
 
 const (
-	ContentPartText  ContentPartType = "text"
-	ContentPartImage ContentPartType = "image"
+	ContentPartText     ContentPartType = "text"
+	ContentPartImage    ContentPartType = "image"
+	ContentPartDocument ContentPartType = "document"
+	ContentPartAudio    ContentPartType = "audio"
 )
 
+// contentPartModalities maps the media content part types to the modality of
+// the MediaPart they become.
+var contentPartModalities = map[ContentPartType]MediaModality{
+	ContentPartImage:    MediaModalityImage,
+	ContentPartDocument: MediaModalityDocument,
+	ContentPartAudio:    MediaModalityAudio,
+}
+
+// IsMedia reports whether the part carries inline media rather than text.
+func (p ContentPart) IsMedia() bool {
+	_, ok := contentPartModalities[p.Type]
+	return ok
+}
+
 type ContentPart struct {
 	Type     ContentPartType `json:"type"`
 	Text     string          `json:"text,omitempty"`
@@ -275,15 +291,13 @@ func NewReasoningMessage(role Role, text string, visibility ReasoningVisibility)
 }
 
 func PartFromContentPart(part ContentPart) Part {
-	switch part.Type {
-	case ContentPartImage:
-		return NewMediaPart(MediaModalityImage, MediaSource{
+	if modality, ok := contentPartModalities[part.Type]; ok {
+		return NewMediaPart(modality, MediaSource{
 			Kind: MediaSourceInline,
 			Data: part.Data,
 		}, part.MimeType, part.FileName)
-	default:
-		return NewTextPart(part.Text)
 	}
+	return NewTextPart(part.Text)
 }
 
 func PartsFromContentParts(parts []ContentPart) []Part {
@@ -305,13 +319,17 @@ func ContentPartFromPart(part Part) (ContentPart, bool) {
 		}
 		return ContentPart{Type: ContentPartText, Text: part.Text.Text}, true
 	case PartKindMedia:
-		if part.Media == nil || part.Media.Modality != MediaModalityImage {
+		if part.Media == nil {
+			return ContentPart{}, false
+		}
+		partType, ok := mediaContentPartType(part.Media.Modality)
+		if !ok {
 			return ContentPart{}, false
 		}
 		switch part.Media.Source.Kind {
 		case MediaSourceInline:
 			return ContentPart{
-				Type:     ContentPartImage,
+				Type:     partType,
 				MimeType: part.Media.MimeType,
 				Data:     part.Media.Source.Data,
 				FileName: part.Media.Name,
@@ -324,6 +342,15 @@ func ContentPartFromPart(part Part) (ContentPart, bool) {
 	}
 }
 
+func mediaContentPartType(modality MediaModality) (ContentPartType, bool) {
+	for partType, one := range contentPartModalities {
+		if one == modality {
+			return partType, true
+		}
+	}
+	return "", false
+}
+
 func ContentPartsFromParts(parts []Part) []ContentPart {
 	if len(parts) == 0 {
 		return nil
@@ -613,13 +640,38 @@ type Request struct {
 	Output       *OutputSpec     `json:"output,omitempty"`
 	Reasoning    ReasoningConfig `json:"reasoning,omitempty"`
 	Stream       bool            `json:"stream,omitempty"`
+	// CacheKey groups requests that share a prompt prefix, typically one per
+	// session, so providers with keyed prompt caches can route them together.
+	CacheKey string `json:"cache_key,omitempty"`
 }
 
-// Usage reports model token usage (best-effort).
+// Usage reports model token usage (best-effort). PromptTokens counts every
+// input token, including the cached ones broken out in CacheReadTokens and
+// CacheWriteTokens; CompletionTokens likewise includes ReasoningTokens.
 type Usage struct {
 	PromptTokens     int `json:"prompt_tokens,omitempty"`
 	CompletionTokens int `json:"completion_tokens,omitempty"`
 	TotalTokens      int `json:"total_tokens,omitempty"`
+	CacheReadTokens  int `json:"cache_read_tokens,omitempty"`
+	CacheWriteTokens int `json:"cache_write_tokens,omitempty"`
+	ReasoningTokens  int `json:"reasoning_tokens,omitempty"`
+}
+
+// IsZero reports whether no token counts were reported.
+func (u Usage) IsZero() bool {
+	return u == Usage{}
+}
+
+// Add returns the field-wise sum of two usage reports.
+func (u Usage) Add(other Usage) Usage {
+	return Usage{
+		PromptTokens:     u.PromptTokens + other.PromptTokens,
+		CompletionTokens: u.CompletionTokens + other.CompletionTokens,
+		TotalTokens:      u.TotalTokens + other.TotalTokens,
+		CacheReadTokens:  u.CacheReadTokens + other.CacheReadTokens,
+		CacheWriteTokens: u.CacheWriteTokens + other.CacheWriteTokens,
+		ReasoningTokens:  u.ReasoningTokens + other.ReasoningTokens,
+	}
 }
 
 // FinishReason describes why a model turn ended.
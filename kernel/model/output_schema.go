@@ -0,0 +1,340 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Structured reports whether the spec asks the model for JSON output.
+func (s *OutputSpec) Structured() bool {
+	return s != nil && (s.Mode == OutputModeJSON || s.Mode == OutputModeSchema)
+}
+
+// OutputValidationError reports model output that does not satisfy the
+// requested OutputSpec.
+type OutputValidationError struct {
+	Problems []string
+}
+
+func (e *OutputValidationError) Error() string {
+	return "model: output does not match requested format: " + strings.Join(e.Problems, "; ")
+}
+
+// ValidateOutput checks text against the spec and returns it as compact JSON.
+// A single surrounding code fence is tolerated. Specs that do not ask for
+// structured output accept any text and return nil.
+func (s *OutputSpec) ValidateOutput(text string) (json.RawMessage, error) {
+	if !s.Structured() {
+		return nil, nil
+	}
+	body := stripCodeFence(text)
+	if body == "" {
+		return nil, &OutputValidationError{Problems: []string{"output is empty"}}
+	}
+	dec := json.NewDecoder(strings.NewReader(body))
+	dec.UseNumber()
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return nil, &OutputValidationError{Problems: []string{"output is not valid JSON: " + err.Error()}}
+	}
+	if dec.More() {
+		return nil, &OutputValidationError{Problems: []string{"output has trailing data after the JSON value"}}
+	}
+	if s.Mode == OutputModeSchema && len(s.JSONSchema) > 0 {
+		var problems []string
+		validateSchemaValue(s.JSONSchema, value, "$", &problems)
+		if len(problems) > 0 {
+			return nil, &OutputValidationError{Problems: problems}
+		}
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, []byte(body)); err != nil {
+		return nil, &OutputValidationError{Problems: []string{"output is not valid JSON: " + err.Error()}}
+	}
+	return json.RawMessage(compact.Bytes()), nil
+}
+
+// validateSchemaValue checks the JSON Schema keywords that model providers
+// accept for structured output: type, enum, const, properties, required,
+// additionalProperties, items, the numeric and length bounds, pattern and the
+// allOf/anyOf/oneOf combinators. Unknown keywords are ignored.
+func validateSchemaValue(schema map[string]any, value any, path string, problems *[]string) {
+	if len(schema) == 0 {
+		return
+	}
+	if types := schemaTypes(schema["type"]); len(types) > 0 {
+		matched := false
+		for _, one := range types {
+			if jsonValueHasType(value, one) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			*problems = append(*problems, fmt.Sprintf("%s: expected %s, got %s", path, strings.Join(types, " or "), jsonTypeName(value)))
+			return
+		}
+	}
+	if enum, ok := schema["enum"].([]any); ok {
+		found := false
+		for _, one := range enum {
+			if jsonValuesEqual(one, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			*problems = append(*problems, fmt.Sprintf("%s: value is not one of the allowed enum values", path))
+		}
+	}
+	if want, ok := schema["const"]; ok && !jsonValuesEqual(want, value) {
+		*problems = append(*problems, fmt.Sprintf("%s: value does not equal the required constant", path))
+	}
+
+	switch typed := value.(type) {
+	case map[string]any:
+		validateSchemaObject(schema, typed, path, problems)
+	case []any:
+		validateSchemaArray(schema, typed, path, problems)
+	case string:
+		length := utf8.RuneCountInString(typed)
+		if limit, ok := schemaNumber(schema["minLength"]); ok && float64(length) < limit {
+			*problems = append(*problems, fmt.Sprintf("%s: string is shorter than %v", path, limit))
+		}
+		if limit, ok := schemaNumber(schema["maxLength"]); ok && float64(length) > limit {
+			*problems = append(*problems, fmt.Sprintf("%s: string is longer than %v", path, limit))
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			if re, err := regexp.Compile(pattern); err == nil && !re.MatchString(typed) {
+				*problems = append(*problems, fmt.Sprintf("%s: string does not match pattern %q", path, pattern))
+			}
+		}
+	case json.Number:
+		number, err := typed.Float64()
+		if err != nil {
+			break
+		}
+		if limit, ok := schemaNumber(schema["minimum"]); ok && number < limit {
+			*problems = append(*problems, fmt.Sprintf("%s: %v is less than minimum %v", path, number, limit))
+		}
+		if limit, ok := schemaNumber(schema["maximum"]); ok && number > limit {
+			*problems = append(*problems, fmt.Sprintf("%s: %v is greater than maximum %v", path, number, limit))
+		}
+		if limit, ok := schemaNumber(schema["exclusiveMinimum"]); ok && number <= limit {
+			*problems = append(*problems, fmt.Sprintf("%s: %v is not greater than %v", path, number, limit))
+		}
+		if limit, ok := schemaNumber(schema["exclusiveMaximum"]); ok && number >= limit {
+			*problems = append(*problems, fmt.Sprintf("%s: %v is not less than %v", path, number, limit))
+		}
+	}
+
+	if subschemas := schemaList(schema["allOf"]); len(subschemas) > 0 {
+		for _, sub := range subschemas {
+			validateSchemaValue(sub, value, path, problems)
+		}
+	}
+	if subschemas := schemaList(schema["anyOf"]); len(subschemas) > 0 && countSchemaMatches(subschemas, value, path) == 0 {
+		*problems = append(*problems, fmt.Sprintf("%s: value matches none of anyOf", path))
+	}
+	if subschemas := schemaList(schema["oneOf"]); len(subschemas) > 0 && countSchemaMatches(subschemas, value, path) != 1 {
+		*problems = append(*problems, fmt.Sprintf("%s: value must match exactly one of oneOf", path))
+	}
+}
+
+func validateSchemaObject(schema map[string]any, value map[string]any, path string, problems *[]string) {
+	properties, _ := schema["properties"].(map[string]any)
+	for _, name := range schemaStrings(schema["required"]) {
+		if _, ok := value[name]; !ok {
+			*problems = append(*problems, fmt.Sprintf("%s: missing required property %q", path, name))
+		}
+	}
+	keys := make([]string, 0, len(value))
+	for key := range value {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		childPath := path + "." + key
+		if sub, ok := properties[key].(map[string]any); ok {
+			validateSchemaValue(sub, value[key], childPath, problems)
+			continue
+		}
+		if _, declared := properties[key]; declared {
+			continue
+		}
+		switch extra := schema["additionalProperties"].(type) {
+		case bool:
+			if !extra {
+				*problems = append(*problems, fmt.Sprintf("%s: property is not allowed", childPath))
+			}
+		case map[string]any:
+			validateSchemaValue(extra, value[key], childPath, problems)
+		}
+	}
+}
+
+func validateSchemaArray(schema map[string]any, value []any, path string, problems *[]string) {
+	if limit, ok := schemaNumber(schema["minItems"]); ok && float64(len(value)) < limit {
+		*problems = append(*problems, fmt.Sprintf("%s: array has fewer than %v items", path, limit))
+	}
+	if limit, ok := schemaNumber(schema["maxItems"]); ok && float64(len(value)) > limit {
+		*problems = append(*problems, fmt.Sprintf("%s: array has more than %v items", path, limit))
+	}
+	items, ok := schema["items"].(map[string]any)
+	if !ok {
+		return
+	}
+	for i, item := range value {
+		validateSchemaValue(items, item, fmt.Sprintf("%s[%d]", path, i), problems)
+	}
+}
+
+func countSchemaMatches(subschemas []map[string]any, value any, path string) int {
+	matches := 0
+	for _, sub := range subschemas {
+		var problems []string
+		validateSchemaValue(sub, value, path, &problems)
+		if len(problems) == 0 {
+			matches++
+		}
+	}
+	return matches
+}
+
+func schemaTypes(raw any) []string {
+	switch typed := raw.(type) {
+	case string:
+		return []string{typed}
+	case []any:
+		return schemaStrings(typed)
+	case []string:
+		return typed
+	default:
+		return nil
+	}
+}
+
+func schemaStrings(raw any) []string {
+	switch typed := raw.(type) {
+	case []string:
+		return typed
+	case []any:
+		out := make([]string, 0, len(typed))
+		for _, one := range typed {
+			if text, ok := one.(string); ok {
+				out = append(out, text)
+			}
+		}
+		return out
+	default:
+		return nil
+	}
+}
+
+func schemaList(raw any) []map[string]any {
+	list, ok := raw.([]any)
+	if !ok {
+		return nil
+	}
+	out := make([]map[string]any, 0, len(list))
+	for _, one := range list {
+		if sub, ok := one.(map[string]any); ok {
+			out = append(out, sub)
+		}
+	}
+	return out
+}
+
+func schemaNumber(raw any) (float64, bool) {
+	switch typed := raw.(type) {
+	case float64:
+		return typed, true
+	case int:
+		return float64(typed), true
+	case json.Number:
+		value, err := typed.Float64()
+		return value, err == nil
+	default:
+		return 0, false
+	}
+}
+
+func jsonValueHasType(value any, want string) bool {
+	switch want {
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "null":
+		return value == nil
+	case "number":
+		_, ok := value.(json.Number)
+		return ok
+	case "integer":
+		number, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		parsed, err := number.Float64()
+		return err == nil && parsed == math.Trunc(parsed)
+	default:
+		return true
+	}
+}
+
+func jsonTypeName(value any) string {
+	switch value.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	case nil:
+		return "null"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// jsonValuesEqual compares a schema literal with a decoded value. Numbers are
+// compared by value since the two sides decode them differently.
+func jsonValuesEqual(want, got any) bool {
+	wantNumber, wantIsNumber := schemaNumber(want)
+	gotNumber, gotIsNumber := schemaNumber(got)
+	if wantIsNumber || gotIsNumber {
+		return wantIsNumber && gotIsNumber && wantNumber == gotNumber
+	}
+	return reflect.DeepEqual(normalizeJSONLiteral(want), normalizeJSONLiteral(got))
+}
+
+func normalizeJSONLiteral(value any) any {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	var out any
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return value
+	}
+	return out
+}
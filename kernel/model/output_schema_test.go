@@ -0,0 +1,68 @@
+package model
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestOutputSpecValidateOutput(t *testing.T) {
+	spec := &OutputSpec{
+		Mode: OutputModeSchema,
+		JSONSchema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"status": map[string]any{"type": "string", "enum": []any{"ok", "failed"}},
+				"count":  map[string]any{"type": "integer", "minimum": 0},
+				"files":  map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+			},
+			"required":             []any{"status", "count"},
+			"additionalProperties": false,
+		},
+	}
+
+	got, err := spec.ValidateOutput("```json\n{\"status\": \"ok\", \"count\": 2, \"files\": [\"a.go\"]}\n```")
+	if err != nil {
+		t.Fatalf("expected valid output, got %v", err)
+	}
+	if string(got) != `{"status":"ok","count":2,"files":["a.go"]}` {
+		t.Fatalf("unexpected compact output %s", got)
+	}
+
+	for name, tc := range map[string]struct {
+		text string
+		want string
+	}{
+		"not json":         {text: "all done", want: "not valid JSON"},
+		"missing required": {text: `{"status":"ok"}`, want: `missing required property "count"`},
+		"wrong enum":       {text: `{"status":"maybe","count":1}`, want: "$.status: value is not one of"},
+		"not integer":      {text: `{"status":"ok","count":1.5}`, want: "$.count: expected integer"},
+		"below minimum":    {text: `{"status":"ok","count":-1}`, want: "less than minimum"},
+		"bad item":         {text: `{"status":"ok","count":1,"files":[3]}`, want: "$.files[0]: expected string"},
+		"extra property":   {text: `{"status":"ok","count":1,"extra":true}`, want: "$.extra: property is not allowed"},
+		"trailing data":    {text: `{"status":"ok","count":1} {}`, want: "trailing data"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := spec.ValidateOutput(tc.text)
+			var validationErr *OutputValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("expected OutputValidationError, got %v", err)
+			}
+			if !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("expected error containing %q, got %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestOutputSpecValidateOutput_NonStructuredModesAcceptText(t *testing.T) {
+	var nilSpec *OutputSpec
+	for _, spec := range []*OutputSpec{nilSpec, {Mode: OutputModeText}} {
+		if got, err := spec.ValidateOutput("plain text"); err != nil || got != nil {
+			t.Fatalf("expected text to pass through, got %s, %v", got, err)
+		}
+	}
+	if _, err := (&OutputSpec{Mode: OutputModeJSON}).ValidateOutput(`[1, 2]`); err != nil {
+		t.Fatalf("expected any JSON value in json mode, got %v", err)
+	}
+}
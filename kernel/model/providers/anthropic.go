@@ -2,6 +2,7 @@ package providers
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"iter"
@@ -89,15 +90,16 @@ func (l *anthropicSDKLLM) Generate(ctx context.Context, req *model.Request) iter
 			yield(nil, err)
 			return
 		}
+		structured := req.Output.Structured()
 		if req.Stream {
-			l.generateStreaming(ctx, params, yield)
+			l.generateStreaming(ctx, params, structured, yield)
 			return
 		}
-		l.generateNonStreaming(ctx, params, yield)
+		l.generateNonStreaming(ctx, params, structured, yield)
 	}
 }
 
-func (l *anthropicSDKLLM) generateNonStreaming(ctx context.Context, params anthropic.MessageNewParams, yield func(*model.StreamEvent, error) bool) {
+func (l *anthropicSDKLLM) generateNonStreaming(ctx context.Context, params anthropic.MessageNewParams, structured bool, yield func(*model.StreamEvent, error) bool) {
 	cli := l.clientOrZero()
 	runCtx := ctx
 	cancel := func() {}
@@ -116,6 +118,9 @@ func (l *anthropicSDKLLM) generateNonStreaming(ctx context.Context, params anthr
 		yield(nil, err)
 		return
 	}
+	if structured {
+		msg, finishReason = anthropicStructuredOutput(msg, finishReason)
+	}
 	yield(&model.StreamEvent{
 		Type: model.StreamEventTurnDone,
 		Response: &model.Response{
@@ -132,7 +137,7 @@ func (l *anthropicSDKLLM) generateNonStreaming(ctx context.Context, params anthr
 	}, nil)
 }
 
-func (l *anthropicSDKLLM) generateStreaming(ctx context.Context, params anthropic.MessageNewParams, yield func(*model.StreamEvent, error) bool) {
+func (l *anthropicSDKLLM) generateStreaming(ctx context.Context, params anthropic.MessageNewParams, structured bool, yield func(*model.StreamEvent, error) bool) {
 	cli := l.clientOrZero()
 	stream := cli.Messages.NewStreaming(ctx, params)
 	if stream == nil {
@@ -168,6 +173,9 @@ func (l *anthropicSDKLLM) generateStreaming(ctx context.Context, params anthropi
 		yield(nil, err)
 		return
 	}
+	if structured {
+		msg, finishReason = anthropicStructuredOutput(msg, finishReason)
+	}
 	yield(&model.StreamEvent{
 		Type: model.StreamEventTurnDone,
 		Response: &model.Response{
@@ -250,12 +258,90 @@ func (l *anthropicSDKLLM) buildRequest(req *model.Request) (anthropic.MessageNew
 		System:    toAnthropicSystem(req.Instructions),
 		Tools:     toAnthropicTools(model.FunctionToolDefinitions(req.Tools)),
 	}
-	if thinking := anthropicThinkingConfig(l.provider, req.Reasoning); thinking != nil {
+	thinking := anthropicThinkingConfig(l.provider, req.Reasoning)
+	if thinking != nil {
 		params.Thinking = *thinking
 	}
+	if req.Output.Structured() {
+		// Anthropic has no response format, but it does enforce tool input
+		// schemas. The answer is requested as a call to a synthetic tool and
+		// unwrapped into text again in anthropicStructuredOutput.
+		entry := anthropic.ToolUnionParamOfTool(anthropicToolInputSchema(outputToolSchema(req.Output)), structuredOutputToolName)
+		if entry.OfTool != nil {
+			entry.OfTool.Description = anthropic.String("Return the final answer of the task as structured output.")
+		}
+		params.Tools = append(params.Tools, entry)
+		// Forcing a tool is incompatible with extended thinking and would keep
+		// the model from calling its other tools.
+		if len(params.Tools) == 1 && thinking == nil {
+			params.ToolChoice = anthropic.ToolChoiceParamOfTool(structuredOutputToolName)
+		} else {
+			params.System = append(params.System, anthropic.TextBlockParam{
+				Text: "When you have the final answer, call the " + structuredOutputToolName + " tool with it instead of replying in text.",
+			})
+		}
+	}
+	applyAnthropicCacheBreakpoints(&params)
 	return params, nil
 }
 
+// anthropicMaxCacheBreakpoints is the number of cache_control markers the
+// Messages API accepts per request.
+const anthropicMaxCacheBreakpoints = 4
+
+// applyAnthropicCacheBreakpoints marks the end of the tool list, the end of
+// the system prompt and the end of the two most recent user turns as prompt
+// cache breakpoints. Tools and the system prompt are frozen for a session, so
+// their prefix is read from cache on every turn; the history markers let the
+// next request read everything up to the previous turn. Anthropic caches the
+// prefix in tools, system, messages order, so each later marker extends the
+// earlier ones.
+func applyAnthropicCacheBreakpoints(params *anthropic.MessageNewParams) {
+	remaining := anthropicMaxCacheBreakpoints
+	mark := func(cc *anthropic.CacheControlEphemeralParam) {
+		if cc == nil || remaining == 0 {
+			return
+		}
+		*cc = anthropic.NewCacheControlEphemeralParam()
+		remaining--
+	}
+	if n := len(params.Tools); n > 0 {
+		mark(params.Tools[n-1].GetCacheControl())
+	}
+	if n := len(params.System); n > 0 {
+		mark(&params.System[n-1].CacheControl)
+	}
+	userTurns := 0
+	for i := len(params.Messages) - 1; i >= 0 && remaining > 0 && userTurns < 2; i-- {
+		msg := params.Messages[i]
+		if msg.Role != anthropic.MessageParamRoleUser {
+			continue
+		}
+		userTurns++
+		// Thinking blocks cannot carry cache_control; mark the last block
+		// that can.
+		for j := len(msg.Content) - 1; j >= 0; j-- {
+			if cc := msg.Content[j].GetCacheControl(); cc != nil {
+				mark(cc)
+				break
+			}
+		}
+	}
+}
+
+// anthropicStructuredOutput unwraps the synthetic output tool call. When it
+// was the only tool call, the turn is reported as a normal stop.
+func anthropicStructuredOutput(msg model.Message, finishReason model.FinishReason) (model.Message, model.FinishReason) {
+	unwrapped, ok := unwrapStructuredOutputTool(msg)
+	if !ok {
+		return msg, finishReason
+	}
+	if len(unwrapped.ToolCalls()) == 0 && finishReason == model.FinishReasonToolCalls {
+		finishReason = model.FinishReasonStop
+	}
+	return unwrapped, finishReason
+}
+
 func (l *anthropicSDKLLM) clientOrZero() anthropic.Client {
 	if l.client != nil {
 		return *l.client
@@ -401,11 +487,11 @@ func toAnthropicContentBlocks(parts []model.Part, userRole bool) []anthropic.Con
 			}
 			out = append(out, anthropic.NewToolUseBlock(part.ToolUse.ID, jsonRawToAny(part.ToolUse.Input), part.ToolUse.Name))
 		case model.PartKindMedia:
-			if !userRole || part.Media == nil || part.Media.Modality != model.MediaModalityImage {
+			if !userRole || part.Media == nil {
 				continue
 			}
-			if part.Media.Source.Kind == model.MediaSourceInline && part.Media.Source.Data != "" {
-				out = append(out, anthropic.NewImageBlockBase64(part.Media.MimeType, part.Media.Source.Data))
+			if block, ok := toAnthropicMediaBlock(part); ok {
+				out = append(out, block)
 			}
 		}
 	}
@@ -415,6 +501,42 @@ func toAnthropicContentBlocks(parts []model.Part, userRole bool) []anthropic.Con
 	return out
 }
 
+// toAnthropicMediaBlock maps inline images to image blocks and PDFs and text
+// documents to document blocks. Other media, such as audio, is sent as its
+// text fallback.
+func toAnthropicMediaBlock(part model.Part) (anthropic.ContentBlockParamUnion, bool) {
+	media := part.Media
+	if media.Source.Kind != model.MediaSourceInline || media.Source.Data == "" {
+		return anthropic.ContentBlockParamUnion{}, false
+	}
+	mime := strings.ToLower(strings.TrimSpace(media.MimeType))
+	switch {
+	case media.Modality == model.MediaModalityImage:
+		return anthropic.NewImageBlockBase64(media.MimeType, media.Source.Data), true
+	case media.Modality == model.MediaModalityDocument && mime == "application/pdf":
+		block := anthropic.NewDocumentBlock(anthropic.Base64PDFSourceParam{Data: media.Source.Data})
+		if name := strings.TrimSpace(media.Name); name != "" {
+			block.OfDocument.Title = anthropic.String(name)
+		}
+		return block, true
+	case media.Modality == model.MediaModalityDocument && strings.HasPrefix(mime, "text/"):
+		raw, err := base64.StdEncoding.DecodeString(media.Source.Data)
+		if err != nil {
+			return anthropic.ContentBlockParamUnion{}, false
+		}
+		block := anthropic.NewDocumentBlock(anthropic.PlainTextSourceParam{Data: string(raw)})
+		if name := strings.TrimSpace(media.Name); name != "" {
+			block.OfDocument.Title = anthropic.String(name)
+		}
+		return block, true
+	}
+	cp, ok := model.ContentPartFromPart(part)
+	if !ok {
+		return anthropic.ContentBlockParamUnion{}, false
+	}
+	return anthropic.NewTextBlock(mediaFallbackText(cp)), true
+}
+
 func toAnthropicToolResultBlocks(parts []model.Part) []anthropic.ContentBlockParamUnion {
 	if len(parts) == 0 {
 		return nil
@@ -586,10 +708,14 @@ func anthropicMessageToKernel(provider string, resp *anthropic.Message) (model.M
 }
 
 func anthropicUsageToKernel(usage anthropic.Usage) model.Usage {
+	// Anthropic reports cache reads and writes separately from input_tokens.
+	prompt := usage.InputTokens + usage.CacheReadInputTokens + usage.CacheCreationInputTokens
 	return model.Usage{
-		PromptTokens:     int(usage.InputTokens),
+		PromptTokens:     int(prompt),
 		CompletionTokens: int(usage.OutputTokens),
-		TotalTokens:      int(usage.InputTokens + usage.OutputTokens),
+		TotalTokens:      int(prompt + usage.OutputTokens),
+		CacheReadTokens:  int(usage.CacheReadInputTokens),
+		CacheWriteTokens: int(usage.CacheCreationInputTokens),
 	}
 }
 
@@ -0,0 +1,76 @@
+package providers
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/OnslaughtSnail/caelis/kernel/model"
+)
+
+const (
+	// AzureOpenAIDefaultAPIVersion is used when Config.APIVersion is empty.
+	AzureOpenAIDefaultAPIVersion = "2025-04-01-preview"
+	// azureOpenAIDeploymentsAPIVersion is the last data-plane version that
+	// lists deployments.
+	azureOpenAIDeploymentsAPIVersion = "2022-12-01"
+)
+
+// newAzureOpenAI sends Responses API requests to an Azure OpenAI resource.
+// cfg.BaseURL is the resource endpoint, such as
+// https://<resource>.openai.azure.com, and cfg.Model is the deployment name.
+// A base URL ending in /openai/v1 uses the versionless v1 API instead. With
+// cfg.Deployment set, requests go to the deployment's chat completions
+// endpoint, which older deployments and api-versions only offer.
+func newAzureOpenAI(cfg Config, token string) model.LLM {
+	if strings.TrimSpace(cfg.Deployment) != "" {
+		return newAzureOpenAIChat(cfg, token)
+	}
+	llm := newOpenAIResponsesLLM(cfg, token)
+	llm.endpoint = azureOpenAIResponsesURL(cfg.BaseURL, cfg.APIVersion)
+	return llm
+}
+
+func newAzureOpenAIChat(cfg Config, token string) *openAICompatLLM {
+	deployment := strings.TrimSpace(cfg.Deployment)
+	if strings.TrimSpace(cfg.Model) == "" {
+		cfg.Model = deployment
+	}
+	llm := newOpenAICompat(cfg, token)
+	llm.endpoint = azureOpenAIChatURL(cfg.BaseURL, deployment, cfg.APIVersion)
+	llm.authConfig = Config{API: APIAzureOpenAI, Provider: cfg.Provider, Auth: cfg.Auth}
+	return llm
+}
+
+func azureOpenAIChatURL(baseURL string, deployment string, apiVersion string) string {
+	apiVersion = strings.TrimSpace(apiVersion)
+	if apiVersion == "" {
+		apiVersion = AzureOpenAIDefaultAPIVersion
+	}
+	return azureOpenAIResourceURL(strings.TrimSpace(baseURL)) + "/openai/deployments/" + url.PathEscape(deployment) + "/chat/completions?api-version=" + url.QueryEscape(apiVersion)
+}
+
+func azureOpenAIResponsesURL(baseURL string, apiVersion string) string {
+	base := strings.TrimRight(strings.TrimSpace(baseURL), "/")
+	if azureOpenAIV1BaseURL(base) {
+		return base + "/responses"
+	}
+	apiVersion = strings.TrimSpace(apiVersion)
+	if apiVersion == "" {
+		apiVersion = AzureOpenAIDefaultAPIVersion
+	}
+	return azureOpenAIResourceURL(base) + "/openai/responses?api-version=" + url.QueryEscape(apiVersion)
+}
+
+func azureOpenAIV1BaseURL(base string) bool {
+	return strings.HasSuffix(strings.ToLower(strings.TrimRight(base, "/")), "/openai/v1")
+}
+
+// azureOpenAIResourceURL strips a trailing /openai so both the bare resource
+// endpoint and the /openai form are accepted.
+func azureOpenAIResourceURL(base string) string {
+	base = strings.TrimRight(base, "/")
+	if strings.HasSuffix(strings.ToLower(base), "/openai") {
+		base = base[:len(base)-len("/openai")]
+	}
+	return base
+}
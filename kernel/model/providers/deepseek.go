@@ -10,6 +10,7 @@ func newDeepSeek(cfg Config, token string) model.LLM {
 	llm := newOpenAICompat(cfg, token)
 	llm.options.IncludeReasoningContent = true
 	llm.options.EmitEmptyReasoningForToolCall = true
+	llm.options.JSONObjectOnly = true
 	llm.options.ApplyReasoning = applyThinkingReasoning
 	return llm
 }
@@ -36,8 +36,10 @@ func DiscoverModels(ctx context.Context, cfg Config) ([]RemoteModel, error) {
 	}
 	client := &http.Client{Timeout: timeout}
 	switch cfg.API {
-	case APIOpenAI, APIOpenAICompatible, APIOpenRouter, APIDeepSeek, APIMimo, APIVolcengine, APIVolcengineCoding:
+	case APIOpenAI, APIOpenAICompatible, APIOpenRouter, APIDeepSeek, APIMimo, APIVolcengine, APIVolcengineCoding, APIOpenAIResponses:
 		return discoverOpenAIModels(ctx, client, cfg, token)
+	case APIAzureOpenAI:
+		return discoverAzureDeployments(ctx, client, cfg, token)
 	case APIGemini:
 		return discoverGeminiModels(ctx, client, cfg, token)
 	case APIAnthropic, APIAnthropicCompatible:
@@ -301,6 +303,56 @@ func discoverOllamaModels(ctx context.Context, client *http.Client, cfg Config)
 	return normalizeRemoteModels(models), nil
 }
 
+// discoverAzureDeployments lists the deployments of an Azure OpenAI resource.
+// Requests name a deployment rather than a model, so deployments are what the
+// user picks from. A /openai/v1 base URL lists models like OpenAI does.
+func discoverAzureDeployments(ctx context.Context, client *http.Client, cfg Config, token string) ([]RemoteModel, error) {
+	base := strings.TrimRight(strings.TrimSpace(cfg.BaseURL), "/")
+	if azureOpenAIV1BaseURL(base) {
+		return discoverOpenAIModels(ctx, client, cfg, token)
+	}
+	endpoint := azureOpenAIResourceURL(base) + "/openai/deployments?api-version=" + url.QueryEscape(azureOpenAIDeploymentsAPIVersion)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	applyDefaultAuthHeader(req, cfg, token, false)
+	applyConfiguredHeaders(req, cfg.Headers)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, statusError(resp)
+	}
+	var payload struct {
+		Data []struct {
+			ID     string `json:"id"`
+			Model  string `json:"model"`
+			Status string `json:"status"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return nil, err
+	}
+	models := make([]RemoteModel, 0, len(payload.Data))
+	for _, item := range payload.Data {
+		name := strings.TrimSpace(item.ID)
+		if name == "" {
+			continue
+		}
+		if status := strings.TrimSpace(item.Status); status != "" && !strings.EqualFold(status, "succeeded") {
+			continue
+		}
+		models = append(models, RemoteModel{
+			Name:         name,
+			Capabilities: appendUniqueStrings(nil, strings.TrimSpace(item.Model)),
+		})
+	}
+	return normalizeRemoteModels(models), nil
+}
+
 func applyDefaultAuthHeader(req *http.Request, cfg Config, token string, geminiBearerOnly bool) {
 	if req == nil {
 		return
@@ -329,6 +381,13 @@ func applyDefaultAuthHeader(req *http.Request, cfg Config, token string, geminiB
 		}
 		req.Header.Set("x-api-key", token)
 		return
+	case APIAzureOpenAI:
+		if auth.Type == AuthOAuthToken || auth.Type == AuthBearerToken {
+			req.Header.Set("Authorization", "Bearer "+token)
+			return
+		}
+		req.Header.Set("api-key", token)
+		return
 	default:
 		req.Header.Set("Authorization", "Bearer "+token)
 	}
@@ -2,6 +2,7 @@ package providers
 
 import (
 	"fmt"
+	"os"
 	"sort"
 	"strings"
 
@@ -10,7 +11,8 @@ import (
 
 // Factory builds model providers from alias configs.
 type Factory struct {
-	configs map[string]Config
+	configs   map[string]Config
+	fallbacks []string
 }
 
 // NewFactory returns an empty provider factory.
@@ -27,7 +29,7 @@ func (f *Factory) Register(cfg Config) error {
 	if alias == "" {
 		return fmt.Errorf("providers: alias is required")
 	}
-	if cfg.API != APIOpenAI && cfg.API != APIOpenAICompatible && cfg.API != APIOpenRouter && cfg.API != APIGemini && cfg.API != APIAnthropic && cfg.API != APIAnthropicCompatible && cfg.API != APIDeepSeek && cfg.API != APIMimo && cfg.API != APIVolcengine && cfg.API != APIVolcengineCoding && cfg.API != APIOllama {
+	if cfg.API != APIOpenAI && cfg.API != APIOpenAICompatible && cfg.API != APIOpenRouter && cfg.API != APIGemini && cfg.API != APIAnthropic && cfg.API != APIAnthropicCompatible && cfg.API != APIDeepSeek && cfg.API != APIMimo && cfg.API != APIVolcengine && cfg.API != APIVolcengineCoding && cfg.API != APIOllama && cfg.API != APIOpenAIResponses && cfg.API != APIAzureOpenAI && cfg.API != APIReplay {
 		return fmt.Errorf("providers: unsupported api type %q", cfg.API)
 	}
 	authType := strings.TrimSpace(string(cfg.Auth.Type))
@@ -35,7 +37,7 @@ func (f *Factory) Register(cfg Config) error {
 		return fmt.Errorf("providers: unsupported auth type %q", cfg.Auth.Type)
 	}
 	if cfg.Auth.Type == "" {
-		if cfg.API == APIOllama {
+		if cfg.API == APIOllama || cfg.API == APIReplay {
 			cfg.Auth.Type = AuthNone
 		} else {
 			cfg.Auth.Type = AuthAPIKey
@@ -46,17 +48,80 @@ func (f *Factory) Register(cfg Config) error {
 	return nil
 }
 
-// NewByAlias creates a model provider by alias.
+// SetFallbacks sets the ordered aliases that models built by NewByAlias fail
+// over to on rate limits, 5xx responses and context overflows.
+func (f *Factory) SetFallbacks(aliases []string) {
+	if f == nil {
+		return
+	}
+	f.fallbacks = f.fallbacks[:0]
+	seen := map[string]struct{}{}
+	for _, alias := range aliases {
+		alias = strings.ToLower(strings.TrimSpace(alias))
+		if alias == "" {
+			continue
+		}
+		if _, dup := seen[alias]; dup {
+			continue
+		}
+		seen[alias] = struct{}{}
+		f.fallbacks = append(f.fallbacks, alias)
+	}
+}
+
+// Fallbacks returns the configured fallback aliases in order.
+func (f *Factory) Fallbacks() []string {
+	if f == nil {
+		return nil
+	}
+	return append([]string(nil), f.fallbacks...)
+}
+
+// NewByAlias creates a model provider by alias. When fallbacks are set, the
+// result is a fallback chain of alias followed by every other fallback alias
+// that can be built; aliases that are unknown or lack credentials are skipped.
+// An unregistered "replay/<path>" alias serves the cassette at path, and when
+// CassetteRecordEnvVar is set the model records into that cassette.
 func (f *Factory) NewByAlias(alias string) (model.LLM, error) {
+	primary, err := f.newSingleByAlias(alias)
+	if err != nil {
+		return nil, err
+	}
+	alias = strings.ToLower(strings.TrimSpace(alias))
+	var chain []model.LLM
+	for _, fallback := range f.fallbacks {
+		if fallback == alias {
+			continue
+		}
+		llm, err := f.newSingleByAlias(fallback)
+		if err != nil {
+			continue
+		}
+		chain = append(chain, llm)
+	}
+	llm := model.NewFallback(primary, chain...)
+	if path := strings.TrimSpace(os.Getenv(CassetteRecordEnvVar)); path != "" {
+		return NewCassetteRecorder(llm, path)
+	}
+	return llm, nil
+}
+
+func (f *Factory) newSingleByAlias(alias string) (model.LLM, error) {
 	if f == nil {
 		return nil, fmt.Errorf("providers: factory is nil")
 	}
-	alias = strings.ToLower(strings.TrimSpace(alias))
+	rawAlias := strings.TrimSpace(alias)
+	alias = strings.ToLower(rawAlias)
 	if alias == "" {
 		return nil, fmt.Errorf("providers: model alias is required")
 	}
 	cfg, ok := f.configs[alias]
 	if !ok {
+		// Cassette paths are case sensitive, so they come from the alias as
+		// given rather than the normalized one.
+		if path, isReplay := ReplayCassettePath(rawAlias); isReplay {
+			return newReplay(Config{Alias: alias, Provider: string(APIReplay), API: APIReplay, Model: path})
+		}
 		return nil, fmt.Errorf("providers: unknown model alias %q", alias)
 	}
 	token, err := resolveToken(cfg.Auth)
@@ -78,13 +143,19 @@ func (f *Factory) NewByAlias(alias string) (model.LLM, error) {
 	case APIOpenRouter:
 		return newOpenRouter(cfg, token), nil
 	case APIOpenAI:
-		return newOpenAICompat(cfg, token), nil
+		return newOpenAI(cfg, token), nil
+	case APIOpenAIResponses:
+		return newOpenAIResponses(cfg, token), nil
+	case APIAzureOpenAI:
+		return newAzureOpenAI(cfg, token), nil
 	case APIAnthropic, APIAnthropicCompatible:
 		return newAnthropic(cfg, token), nil
 	case APIGemini:
 		return newGemini(cfg, token), nil
 	case APIOllama:
 		return newOllama(cfg, token), nil
+	case APIReplay:
+		return newReplay(cfg)
 	default:
 		return nil, fmt.Errorf("providers: unsupported api type %q", cfg.API)
 	}
@@ -65,14 +65,28 @@ func (l *geminiLLM) Generate(ctx context.Context, req *model.Request) iter.Seq2[
 			return
 		}
 
-		system, contents, err := toGeminiContents(req.Instructions, req.Messages)
+		tools := model.FunctionToolDefinitions(req.Tools)
+		instructions := req.Instructions
+		// Gemini rejects a JSON response MIME type alongside function calling,
+		// so requests with tools fall back to a prompt instruction.
+		nativeOutput := req.Output.Structured() && len(tools) == 0
+		if req.Output.Structured() && !nativeOutput {
+			instructions = withOutputInstruction(instructions, req.Output)
+		}
+		system, contents, err := toGeminiContents(instructions, req.Messages)
 		if err != nil {
 			yield(nil, err)
 			return
 		}
 
 		cfg := &genai.GenerateContentConfig{
-			Tools: toGeminiTools(model.FunctionToolDefinitions(req.Tools)),
+			Tools: toGeminiTools(tools),
+		}
+		if nativeOutput {
+			cfg.ResponseMIMEType = "application/json"
+			if req.Output.Mode == model.OutputModeSchema && len(req.Output.JSONSchema) > 0 {
+				cfg.ResponseJsonSchema = req.Output.JSONSchema
+			}
 		}
 		if strings.TrimSpace(system) != "" {
 			cfg.SystemInstruction = &genai.Content{
@@ -387,10 +401,14 @@ func geminiUsageFromResponse(out *genai.GenerateContentResponse) model.Usage {
 	if out == nil || out.UsageMetadata == nil {
 		return model.Usage{}
 	}
+	meta := out.UsageMetadata
+	// Gemini reports thinking tokens separately from candidate tokens.
 	return model.Usage{
-		PromptTokens:     int(out.UsageMetadata.PromptTokenCount),
-		CompletionTokens: int(out.UsageMetadata.CandidatesTokenCount),
-		TotalTokens:      int(out.UsageMetadata.TotalTokenCount),
+		PromptTokens:     int(meta.PromptTokenCount),
+		CompletionTokens: int(meta.CandidatesTokenCount + meta.ThoughtsTokenCount),
+		TotalTokens:      int(meta.TotalTokenCount),
+		CacheReadTokens:  int(meta.CachedContentTokenCount),
+		ReasoningTokens:  int(meta.ThoughtsTokenCount),
 	}
 }
 
@@ -452,7 +470,7 @@ func toGeminiContents(instructions []model.Part, messages []model.Message) (stri
 					switch cp.Type {
 					case model.ContentPartText:
 						parts = append(parts, genai.NewPartFromText(cp.Text))
-					case model.ContentPartImage:
+					case model.ContentPartImage, model.ContentPartDocument, model.ContentPartAudio:
 						data, err := decodeBase64Image(cp.Data)
 						if err != nil {
 							return "", nil, err
@@ -0,0 +1,197 @@
+package providers
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/OnslaughtSnail/caelis/kernel/model"
+)
+
+// maxMediaFallbackRunes caps the text that replaces one attachment, so a
+// large document cannot crowd the conversation out of the context window.
+const maxMediaFallbackRunes = 100_000
+
+const (
+	// maxPDFStreamBytes and maxPDFInflatedBytes cap how much one compressed
+	// content stream and all of a PDF's streams may inflate to, so a
+	// compression bomb cannot exhaust memory.
+	maxPDFStreamBytes   = 8 << 20
+	maxPDFInflatedBytes = 32 << 20
+	// maxMediaFallbackCache is how many attachments keep their fallback
+	// text between requests.
+	maxMediaFallbackCache = 32
+)
+
+// mediaFallbackCache keeps the fallback text of recent attachments, keyed by
+// their content, because every request of a session converts the same
+// attachments again.
+var mediaFallbackCache = struct {
+	sync.Mutex
+	text  map[[sha256.Size]byte]string
+	order [][sha256.Size]byte
+}{text: map[[sha256.Size]byte]string{}}
+
+// mediaFallbackText stands in for an attachment the endpoint cannot take
+// natively. Text documents are inlined, PDFs get a best-effort text
+// extraction, and anything else is only named so the model knows it exists.
+func mediaFallbackText(part model.ContentPart) string {
+	key := sha256.Sum256([]byte(part.FileName + "\x00" + part.MimeType + "\x00" + part.Data))
+	mediaFallbackCache.Lock()
+	text, ok := mediaFallbackCache.text[key]
+	mediaFallbackCache.Unlock()
+	if ok {
+		return text
+	}
+	text = buildMediaFallbackText(part)
+	mediaFallbackCache.Lock()
+	defer mediaFallbackCache.Unlock()
+	if _, ok := mediaFallbackCache.text[key]; !ok {
+		if len(mediaFallbackCache.order) >= maxMediaFallbackCache {
+			delete(mediaFallbackCache.text, mediaFallbackCache.order[0])
+			mediaFallbackCache.order = mediaFallbackCache.order[1:]
+		}
+		mediaFallbackCache.text[key] = text
+		mediaFallbackCache.order = append(mediaFallbackCache.order, key)
+	}
+	return text
+}
+
+func buildMediaFallbackText(part model.ContentPart) string {
+	name := strings.TrimSpace(part.FileName)
+	if name == "" {
+		name = string(part.Type)
+	}
+	label := fmt.Sprintf("[%s attachment: %s (%s)]", part.Type, name, strings.TrimSpace(part.MimeType))
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(part.Data))
+	if err != nil || len(raw) == 0 {
+		return label + "\n(content unavailable)"
+	}
+	mime := strings.ToLower(strings.TrimSpace(part.MimeType))
+	var text string
+	switch {
+	case strings.HasPrefix(mime, "text/"), mime == "application/json":
+		if utf8.Valid(raw) {
+			text = string(raw)
+		}
+	case mime == "application/pdf":
+		text = extractPDFText(raw)
+	}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return label + "\n(this model cannot read this attachment and no text could be extracted)"
+	}
+	if runes := []rune(text); len(runes) > maxMediaFallbackRunes {
+		text = string(runes[:maxMediaFallbackRunes]) + "\n...[truncated]"
+	}
+	return label + "\n" + text
+}
+
+// mediaFallbackPart converts media the endpoint cannot take into a text part
+// and leaves every other part unchanged.
+func mediaFallbackPart(part model.ContentPart) model.ContentPart {
+	if !part.IsMedia() {
+		return part
+	}
+	return model.ContentPart{Type: model.ContentPartText, Text: mediaFallbackText(part)}
+}
+
+var (
+	pdfStreamPattern = regexp.MustCompile(`(?s)<<(.*?)>>\s*stream\r?\n`)
+	pdfTextOperator  = regexp.MustCompile(`(?s)\[(.*?)\]\s*TJ|\((.*?[^\\])?\)\s*(?:Tj|'|")|(T\*|\b(?:Td|TD|ET)\b)`)
+	pdfArrayString   = regexp.MustCompile(`(?s)\((.*?[^\\])?\)`)
+)
+
+// extractPDFText pulls the literal strings shown by text operators out of a
+// PDF's content streams. It handles uncompressed and FlateDecode streams and
+// ignores font encodings, so it works for most generated PDFs and returns
+// little or nothing for scanned or CID-font documents. Inflated streams are
+// capped by maxPDFStreamBytes each and maxPDFInflatedBytes in total.
+func extractPDFText(raw []byte) string {
+	var out strings.Builder
+	inflated := 0
+	for _, loc := range pdfStreamPattern.FindAllSubmatchIndex(raw, -1) {
+		if inflated >= maxPDFInflatedBytes || out.Len() > 4*maxMediaFallbackRunes {
+			break
+		}
+		dict := raw[loc[2]:loc[3]]
+		start := loc[1]
+		end := bytes.Index(raw[start:], []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		stream := raw[start : start+end]
+		if bytes.Contains(dict, []byte("/FlateDecode")) {
+			reader, err := zlib.NewReader(bytes.NewReader(stream))
+			if err != nil {
+				continue
+			}
+			limit := min(maxPDFStreamBytes, maxPDFInflatedBytes-inflated)
+			stream, err = io.ReadAll(io.LimitReader(reader, int64(limit)))
+			inflated += len(stream)
+			if err != nil && len(stream) == 0 {
+				continue
+			}
+		} else if bytes.Contains(dict, []byte("/Filter")) {
+			continue
+		}
+		appendPDFStreamText(&out, stream)
+	}
+	return strings.TrimSpace(strings.ToValidUTF8(out.String(), ""))
+}
+
+func appendPDFStreamText(out *strings.Builder, stream []byte) {
+	for _, match := range pdfTextOperator.FindAllSubmatch(stream, -1) {
+		switch {
+		case match[1] != nil:
+			for _, one := range pdfArrayString.FindAllSubmatch(match[1], -1) {
+				out.WriteString(unescapePDFString(one[1]))
+			}
+		case match[2] != nil:
+			out.WriteString(unescapePDFString(match[2]))
+		case match[3] != nil:
+			if s := out.String(); s != "" && !strings.HasSuffix(s, "\n") {
+				out.WriteByte('\n')
+			}
+		}
+	}
+}
+
+func unescapePDFString(raw []byte) string {
+	var out strings.Builder
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+		if c != '\\' || i+1 >= len(raw) {
+			out.WriteByte(c)
+			continue
+		}
+		i++
+		switch next := raw[i]; next {
+		case 'n':
+			out.WriteByte('\n')
+		case 'r':
+			out.WriteByte('\r')
+		case 't':
+			out.WriteByte('\t')
+		case 'b', 'f':
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			value := 0
+			j := i
+			for ; j < len(raw) && j < i+3 && raw[j] >= '0' && raw[j] <= '7'; j++ {
+				value = value*8 + int(raw[j]-'0')
+			}
+			out.WriteByte(byte(value))
+			i = j - 1
+		default:
+			out.WriteByte(next)
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,150 @@
+package providers
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OnslaughtSnail/caelis/kernel/model"
+)
+
+const openAIChatOKBody = `{"id":"c1","object":"chat.completion","model":"m","choices":[{"index":0,"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`
+
+func testPDF(t *testing.T) []byte {
+	t.Helper()
+	var flate bytes.Buffer
+	w := zlib.NewWriter(&flate)
+	_, _ = w.Write([]byte("BT (Second page) Tj ET"))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	var pdf bytes.Buffer
+	pdf.WriteString("%PDF-1.4\n1 0 obj\n<< /Length 44 >>\nstream\nBT /F1 12 Tf (Quarterly \\(Q3\\)) Tj T* [(rev) -20 (enue)] TJ ET\nendstream\nendobj\n")
+	fmt.Fprintf(&pdf, "2 0 obj\n<< /Length %d /Filter /FlateDecode >>\nstream\n", flate.Len())
+	pdf.Write(flate.Bytes())
+	pdf.WriteString("\nendstream\nendobj\n%%EOF\n")
+	return pdf.Bytes()
+}
+
+func documentRequest(t *testing.T) *model.Request {
+	return &model.Request{Messages: []model.Message{model.MessageFromContentParts(model.RoleUser, []model.ContentPart{
+		{Type: model.ContentPartText, Text: "summarize"},
+		{Type: model.ContentPartDocument, MimeType: "application/pdf", FileName: "report.pdf", Data: base64.StdEncoding.EncodeToString(testPDF(t))},
+		{Type: model.ContentPartAudio, MimeType: "audio/mpeg", FileName: "memo.mp3", Data: base64.StdEncoding.EncodeToString([]byte("ID3"))},
+	})}}
+}
+
+func userContentBlocks(payload map[string]any, key string) []map[string]any {
+	messages, _ := payload[key].([]any)
+	var out []map[string]any
+	for _, raw := range messages {
+		msg, _ := raw.(map[string]any)
+		if msg["role"] != "user" {
+			continue
+		}
+		content, _ := msg["content"].([]any)
+		for _, block := range content {
+			one, _ := block.(map[string]any)
+			out = append(out, one)
+		}
+	}
+	return out
+}
+
+func TestExtractPDFText_ReadsPlainAndFlateStreams(t *testing.T) {
+	got := extractPDFText(testPDF(t))
+	if got != "Quarterly (Q3)\nrevenue\nSecond page" {
+		t.Fatalf("unexpected extracted text %q", got)
+	}
+}
+
+func TestExtractPDFText_CapsInflatedStreams(t *testing.T) {
+	var flate bytes.Buffer
+	w := zlib.NewWriter(&flate)
+	_, _ = w.Write([]byte("BT (kept) Tj ET\n"))
+	_, _ = w.Write(bytes.Repeat([]byte{' '}, maxPDFStreamBytes))
+	_, _ = w.Write([]byte("BT (past the cap) Tj ET"))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	var pdf bytes.Buffer
+	fmt.Fprintf(&pdf, "%%PDF-1.4\n1 0 obj\n<< /Length %d /Filter /FlateDecode >>\nstream\n", flate.Len())
+	pdf.Write(flate.Bytes())
+	pdf.WriteString("\nendstream\nendobj\n%%EOF\n")
+	if got := extractPDFText(pdf.Bytes()); got != "kept" {
+		t.Fatalf("expected extraction to stop at the stream cap, got %q", got)
+	}
+}
+
+func TestMediaFallbackText_CachesPerAttachment(t *testing.T) {
+	part := model.ContentPart{Type: model.ContentPartDocument, MimeType: "application/pdf", FileName: "cached.pdf", Data: base64.StdEncoding.EncodeToString(testPDF(t))}
+	first := mediaFallbackText(part)
+	key := sha256.Sum256([]byte(part.FileName + "\x00" + part.MimeType + "\x00" + part.Data))
+	mediaFallbackCache.Lock()
+	cached, ok := mediaFallbackCache.text[key]
+	mediaFallbackCache.Unlock()
+	if !ok || cached != first {
+		t.Fatalf("expected the fallback text to be cached, got %q", cached)
+	}
+	if second := mediaFallbackText(part); second != first {
+		t.Fatalf("expected the cached text, got %q", second)
+	}
+}
+
+func TestAnthropicRequest_MapsPDFToDocumentBlock(t *testing.T) {
+	server, payload := captureOutputPayload(t, `{"id":"msg_1","type":"message","role":"assistant","model":"test-model","stop_reason":"end_turn","content":[{"type":"text","text":"ok"}],"usage":{"input_tokens":3,"output_tokens":2}}`)
+	llm := newAnthropic(Config{
+		Provider: "anthropic",
+		API:      APIAnthropic,
+		Model:    "test-model",
+		BaseURL:  server.URL,
+		Timeout:  2 * time.Second,
+		Auth:     AuthConfig{Type: AuthAPIKey, Token: "sk"},
+	}, "sk")
+
+	generateFinal(t, llm, documentRequest(t))
+
+	blocks := userContentBlocks(*payload, "messages")
+	if len(blocks) != 3 {
+		t.Fatalf("expected text, document and audio fallback blocks, got %+v", blocks)
+	}
+	source, _ := blocks[1]["source"].(map[string]any)
+	if blocks[1]["type"] != "document" || source["type"] != "base64" || source["media_type"] != "application/pdf" || blocks[1]["title"] != "report.pdf" {
+		t.Fatalf("expected base64 PDF document block, got %+v", blocks[1])
+	}
+	if text, _ := blocks[2]["text"].(string); blocks[2]["type"] != "text" || !strings.Contains(text, "[audio attachment: memo.mp3 (audio/mpeg)]") {
+		t.Fatalf("expected audio text fallback, got %+v", blocks[2])
+	}
+}
+
+func TestOpenAIRequest_MapsPDFToFileInputAndDeepSeekFallsBackToText(t *testing.T) {
+	server, payload := captureOutputPayload(t, openAIChatOKBody)
+	generateFinal(t, newOpenAI(Config{Provider: "openai", Model: "m", BaseURL: server.URL, Timeout: 2 * time.Second}, "token"), documentRequest(t))
+
+	blocks := userContentBlocks(*payload, "messages")
+	if len(blocks) != 3 {
+		t.Fatalf("expected three user content parts, got %+v", blocks)
+	}
+	file, _ := blocks[1]["file"].(map[string]any)
+	if blocks[1]["type"] != "file" || file["filename"] != "report.pdf" || !strings.HasPrefix(fmt.Sprint(file["file_data"]), "data:application/pdf;base64,") {
+		t.Fatalf("expected PDF file input, got %+v", blocks[1])
+	}
+
+	deepSeekServer, deepSeekPayload := captureOutputPayload(t, openAIChatOKBody)
+	generateFinal(t, newDeepSeek(Config{Provider: "deepseek", Model: "deepseek-chat", BaseURL: deepSeekServer.URL, Timeout: 2 * time.Second}, "token"), documentRequest(t))
+
+	messages, _ := (*deepSeekPayload)["messages"].([]any)
+	user, _ := messages[len(messages)-1].(map[string]any)
+	content := fmt.Sprint(user["content"])
+	if !strings.Contains(content, "[document attachment: report.pdf (application/pdf)]") || !strings.Contains(content, "Quarterly (Q3)") {
+		t.Fatalf("expected extracted PDF text for DeepSeek, got %s", content)
+	}
+	if strings.Contains(content, "file_data") {
+		t.Fatalf("expected no file input for DeepSeek, got %s", content)
+	}
+}
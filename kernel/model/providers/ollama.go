@@ -35,6 +35,7 @@ type ollamaChatRequest struct {
 	Tools    []openAICompatTool   `json:"tools,omitempty"`
 	Stream   bool                 `json:"stream"`
 	Think    *bool                `json:"think,omitempty"`
+	Format   any                  `json:"format,omitempty"`
 	Options  *ollamaRequestOption `json:"options,omitempty"`
 }
 
@@ -68,6 +69,18 @@ type ollamaChatResponse struct {
 	EvalCount       int               `json:"eval_count"`
 }
 
+// ollamaFormat maps structured output to the /api/chat format field, which
+// takes either "json" or a JSON Schema object.
+func ollamaFormat(spec *model.OutputSpec) any {
+	if !spec.Structured() {
+		return nil
+	}
+	if spec.Mode == model.OutputModeSchema && len(spec.JSONSchema) > 0 {
+		return spec.JSONSchema
+	}
+	return "json"
+}
+
 // newOllama returns a native Ollama /api/chat client.
 func newOllama(cfg Config, _ string) model.LLM {
 	timeout := cfg.Timeout
@@ -114,6 +127,9 @@ func (l *ollamaLLM) Generate(ctx context.Context, req *model.Request) iter.Seq2[
 			Tools:    fromKernelTools(model.FunctionToolDefinitions(req.Tools)),
 			Stream:   req.Stream,
 		}
+		if format := ollamaFormat(req.Output); format != nil {
+			payload.Format = format
+		}
 		if think := ollamaThinkValue(req.Reasoning); think != nil {
 			payload.Think = think
 		}
@@ -281,6 +297,8 @@ func (l *ollamaLLM) fromKernelMessage(msg model.Message) ollamaChatMessage {
 		for _, part := range contentParts {
 			if part.Type == model.ContentPartImage && strings.TrimSpace(part.Data) != "" {
 				chat.Images = append(chat.Images, part.Data)
+			} else if part.IsMedia() {
+				chat.Content = strings.TrimSpace(chat.Content + "\n\n" + mediaFallbackText(part))
 			}
 		}
 	}
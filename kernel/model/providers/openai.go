@@ -0,0 +1,10 @@
+package providers
+
+import "github.com/OnslaughtSnail/caelis/kernel/model"
+
+func newOpenAI(cfg Config, token string) model.LLM {
+	llm := newOpenAICompat(cfg, token)
+	llm.options.PromptCacheKey = true
+	llm.options.FileInputs = true
+	return llm
+}
@@ -19,6 +19,8 @@ type openAICompatLLM struct {
 	name                string
 	provider            string
 	baseURL             string
+	endpoint            string
+	authConfig          Config
 	token               string
 	headers             map[string]string
 	client              *http.Client
@@ -31,7 +33,16 @@ type openAICompatLLM struct {
 type openAICompatOptions struct {
 	IncludeReasoningContent       bool
 	EmitEmptyReasoningForToolCall bool
-	ApplyReasoning                func(*openAICompatRequest, model.ReasoningConfig)
+	// JSONObjectOnly marks endpoints that accept response_format json_object
+	// but not json_schema; schemas are sent in the prompt instead.
+	JSONObjectOnly bool
+	// PromptCacheKey forwards Request.CacheKey as prompt_cache_key on
+	// endpoints that route cached prefixes by key.
+	PromptCacheKey bool
+	// FileInputs marks endpoints that accept PDF "file" content parts; other
+	// endpoints get the document's extracted text instead.
+	FileInputs     bool
+	ApplyReasoning func(*openAICompatRequest, model.ReasoningConfig)
 }
 
 func defaultOpenAICompatOptions() openAICompatOptions {
@@ -49,6 +60,8 @@ func newOpenAICompat(cfg Config, token string) *openAICompatLLM {
 		name:                cfg.Model,
 		provider:            cfg.Provider,
 		baseURL:             strings.TrimRight(cfg.BaseURL, "/"),
+		endpoint:            strings.TrimRight(cfg.BaseURL, "/") + "/chat/completions",
+		authConfig:          Config{API: APIOpenAICompatible, Provider: cfg.Provider},
 		token:               token,
 		headers:             cloneHeaders(cfg.Headers),
 		client:              &http.Client{},
@@ -78,16 +91,25 @@ func (l *openAICompatLLM) Generate(ctx context.Context, req *model.Request) iter
 			yield(nil, fmt.Errorf("model: request is nil"))
 			return
 		}
+		instructions := req.Instructions
+		responseFormat, needsInstruction := openAIResponseFormatFor(req.Output, !l.options.JSONObjectOnly)
+		if needsInstruction {
+			instructions = withOutputInstruction(instructions, req.Output)
+		}
 		payload := openAICompatRequest{
-			Model:     l.name,
-			Messages:  l.fromKernelMessages(req.Instructions, req.Messages),
-			Tools:     fromKernelTools(model.FunctionToolDefinitions(req.Tools)),
-			Stream:    req.Stream,
-			MaxTokens: l.maxOutputTok,
+			Model:          l.name,
+			Messages:       l.fromKernelMessages(instructions, req.Messages),
+			Tools:          fromKernelTools(model.FunctionToolDefinitions(req.Tools)),
+			Stream:         req.Stream,
+			MaxTokens:      l.maxOutputTok,
+			ResponseFormat: responseFormat,
 		}
 		if req.Stream {
 			payload.StreamOptions = &openAICompatStreamOptions{IncludeUsage: true}
 		}
+		if l.options.PromptCacheKey {
+			payload.PromptCacheKey = strings.TrimSpace(req.CacheKey)
+		}
 		if l.options.ApplyReasoning != nil {
 			l.options.ApplyReasoning(&payload, req.Reasoning)
 		}
@@ -106,13 +128,13 @@ func (l *openAICompatLLM) Generate(ctx context.Context, req *model.Request) iter
 		}
 		defer cancel()
 
-		httpReq, err := http.NewRequestWithContext(runCtx, http.MethodPost, l.baseURL+"/chat/completions", bytes.NewReader(raw))
+		httpReq, err := http.NewRequestWithContext(runCtx, http.MethodPost, l.endpoint, bytes.NewReader(raw))
 		if err != nil {
 			yield(nil, err)
 			return
 		}
 		httpReq.Header.Set("Content-Type", "application/json")
-		applyDefaultAuthHeader(httpReq, Config{API: APIOpenAICompatible, Provider: l.provider}, l.token, false)
+		applyDefaultAuthHeader(httpReq, l.authConfig, l.token, false)
 		applyConfiguredHeaders(httpReq, l.headers)
 
 		resp, err := l.client.Do(httpReq)
@@ -151,11 +173,7 @@ func (l *openAICompatLLM) Generate(ctx context.Context, req *model.Request) iter
 					FinishReason: normalizeOpenAICompatFinishReason(out.Choices[0].FinishReason),
 					Model:        out.Model,
 					Provider:     l.provider,
-					Usage: model.Usage{
-						PromptTokens:     out.Usage.PromptTokens,
-						CompletionTokens: out.Usage.CompletionTokens,
-						TotalTokens:      out.Usage.TotalTokens,
-					},
+					Usage:        out.Usage.toKernel(),
 				},
 			}, nil)
 			return
@@ -173,12 +191,8 @@ func (l *openAICompatLLM) Generate(ctx context.Context, req *model.Request) iter
 			if err := json.Unmarshal(data, &chunk); err != nil {
 				return err
 			}
-			if chunk.Usage.PromptTokens > 0 || chunk.Usage.CompletionTokens > 0 || chunk.Usage.TotalTokens > 0 {
-				usage = model.Usage{
-					PromptTokens:     chunk.Usage.PromptTokens,
-					CompletionTokens: chunk.Usage.CompletionTokens,
-					TotalTokens:      chunk.Usage.TotalTokens,
-				}
+			if one := chunk.Usage.toKernel(); one.PromptTokens > 0 || one.CompletionTokens > 0 || one.TotalTokens > 0 {
+				usage = one
 			}
 			if len(chunk.Choices) == 0 {
 				return nil
@@ -282,6 +296,8 @@ type openAICompatRequest struct {
 	ReasoningEffort string                     `json:"reasoning_effort,omitempty"`
 	Reasoning       *openAIReasoning           `json:"reasoning,omitempty"`
 	Thinking        *openAIThinking            `json:"thinking,omitempty"`
+	ResponseFormat  *openAIResponseFormat      `json:"response_format,omitempty"`
+	PromptCacheKey  string                     `json:"prompt_cache_key,omitempty"`
 }
 
 type openAICompatStreamOptions struct {
@@ -340,9 +356,45 @@ type openAIImageURL struct {
 }
 
 type openAIContentPart struct {
-	Type     string          `json:"type"`
-	Text     string          `json:"text,omitempty"`
-	ImageURL *openAIImageURL `json:"image_url,omitempty"`
+	Type     string           `json:"type"`
+	Text     string           `json:"text,omitempty"`
+	ImageURL *openAIImageURL  `json:"image_url,omitempty"`
+	File     *openAIFileInput `json:"file,omitempty"`
+}
+
+type openAIFileInput struct {
+	Filename string `json:"filename,omitempty"`
+	FileData string `json:"file_data"`
+}
+
+// openAIUserContentParts maps user content to chat completions parts. PDFs
+// become file inputs when the endpoint accepts them; any other media the
+// endpoint cannot take is replaced by its text fallback.
+func openAIUserContentParts(contentParts []model.ContentPart, fileInputs bool) []openAIContentPart {
+	parts := make([]openAIContentPart, 0, len(contentParts))
+	for _, cp := range contentParts {
+		switch {
+		case cp.Type == model.ContentPartImage:
+			parts = append(parts, openAIContentPart{
+				Type:     "image_url",
+				ImageURL: &openAIImageURL{URL: fmt.Sprintf("data:%s;base64,%s", cp.MimeType, cp.Data)},
+			})
+			continue
+		case cp.Type == model.ContentPartDocument && fileInputs && cp.MimeType == "application/pdf":
+			parts = append(parts, openAIContentPart{
+				Type: "file",
+				File: &openAIFileInput{
+					Filename: cp.FileName,
+					FileData: fmt.Sprintf("data:%s;base64,%s", cp.MimeType, cp.Data),
+				},
+			})
+			continue
+		}
+		if cp = mediaFallbackPart(cp); cp.Type == model.ContentPartText {
+			parts = append(parts, openAIContentPart{Type: "text", Text: cp.Text})
+		}
+	}
+	return parts
 }
 
 type openAICompatResponse struct {
@@ -351,11 +403,37 @@ type openAICompatResponse struct {
 		Message      openAICompatMsg `json:"message"`
 		FinishReason string          `json:"finish_reason"`
 	} `json:"choices"`
-	Usage struct {
-		PromptTokens     int `json:"prompt_tokens"`
-		CompletionTokens int `json:"completion_tokens"`
-		TotalTokens      int `json:"total_tokens"`
-	} `json:"usage"`
+	Usage openAICompatUsage `json:"usage"`
+}
+
+type openAICompatUsage struct {
+	PromptTokens        int `json:"prompt_tokens"`
+	CompletionTokens    int `json:"completion_tokens"`
+	TotalTokens         int `json:"total_tokens"`
+	PromptTokensDetails struct {
+		CachedTokens     int `json:"cached_tokens"`
+		CacheWriteTokens int `json:"cache_write_tokens"`
+	} `json:"prompt_tokens_details"`
+	CompletionTokensDetails struct {
+		ReasoningTokens int `json:"reasoning_tokens"`
+	} `json:"completion_tokens_details"`
+	// DeepSeek reports cache hits outside prompt_tokens_details.
+	PromptCacheHitTokens int `json:"prompt_cache_hit_tokens"`
+}
+
+func (u openAICompatUsage) toKernel() model.Usage {
+	cacheRead := u.PromptTokensDetails.CachedTokens
+	if cacheRead == 0 {
+		cacheRead = u.PromptCacheHitTokens
+	}
+	return model.Usage{
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		TotalTokens:      u.TotalTokens,
+		CacheReadTokens:  cacheRead,
+		CacheWriteTokens: u.PromptTokensDetails.CacheWriteTokens,
+		ReasoningTokens:  u.CompletionTokensDetails.ReasoningTokens,
+	}
 }
 
 type openAICompatStreamChunk struct {
@@ -364,11 +442,7 @@ type openAICompatStreamChunk struct {
 		Delta        openAICompatMsg `json:"delta"`
 		FinishReason string          `json:"finish_reason"`
 	} `json:"choices"`
-	Usage struct {
-		PromptTokens     int `json:"prompt_tokens"`
-		CompletionTokens int `json:"completion_tokens"`
-		TotalTokens      int `json:"total_tokens"`
-	} `json:"usage"`
+	Usage openAICompatUsage `json:"usage"`
 }
 
 type openAIStreamAccumulator struct {
@@ -500,21 +574,9 @@ func (l *openAICompatLLM) fromKernelMessage(m model.Message) openAICompatReqMsg
 	if m.Role == model.RoleUser {
 		contentParts := model.ContentPartsFromParts(m.Parts)
 		if len(contentParts) > 0 {
-			parts := make([]openAIContentPart, 0, len(contentParts))
-			for _, cp := range contentParts {
-				switch cp.Type {
-				case model.ContentPartText:
-					parts = append(parts, openAIContentPart{Type: "text", Text: cp.Text})
-				case model.ContentPartImage:
-					parts = append(parts, openAIContentPart{
-						Type:     "image_url",
-						ImageURL: &openAIImageURL{URL: fmt.Sprintf("data:%s;base64,%s", cp.MimeType, cp.Data)},
-					})
-				}
-			}
 			return openAICompatReqMsg{
 				Role:    string(m.Role),
-				Content: parts,
+				Content: openAIUserContentParts(contentParts, l.options.FileInputs),
 			}
 		}
 	}
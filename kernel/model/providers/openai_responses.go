@@ -0,0 +1,604 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/OnslaughtSnail/caelis/kernel/model"
+)
+
+// openAIResponsesReplayKindEncryptedReasoning marks ReasoningPart.Replay
+// tokens that carry a Responses API reasoning item's encrypted_content.
+const openAIResponsesReplayKindEncryptedReasoning = "encrypted_reasoning"
+
+type openAIResponsesLLM struct {
+	name                string
+	provider            string
+	api                 APIType
+	endpoint            string
+	token               string
+	auth                AuthConfig
+	headers             map[string]string
+	client              *http.Client
+	requestTimeout      time.Duration
+	maxOutputTok        int
+	contextWindowTokens int
+}
+
+// newOpenAIResponses talks to the Responses API. Requests are sent with
+// store=false, so reasoning is carried across turns by replaying the
+// encrypted reasoning items the API returns.
+func newOpenAIResponses(cfg Config, token string) *openAIResponsesLLM {
+	llm := newOpenAIResponsesLLM(cfg, token)
+	llm.endpoint = strings.TrimRight(cfg.BaseURL, "/") + "/responses"
+	return llm
+}
+
+func newOpenAIResponsesLLM(cfg Config, token string) *openAIResponsesLLM {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = 60 * time.Second
+	}
+	return &openAIResponsesLLM{
+		name:                cfg.Model,
+		provider:            cfg.Provider,
+		api:                 cfg.API,
+		token:               token,
+		auth:                cfg.Auth,
+		headers:             cloneHeaders(cfg.Headers),
+		client:              &http.Client{},
+		requestTimeout:      timeout,
+		maxOutputTok:        cfg.MaxOutputTok,
+		contextWindowTokens: cfg.ContextWindowTokens,
+	}
+}
+
+func (l *openAIResponsesLLM) Name() string {
+	return l.name
+}
+
+func (l *openAIResponsesLLM) ProviderName() string {
+	return l.provider
+}
+
+func (l *openAIResponsesLLM) ContextWindowTokens() int {
+	return l.contextWindowTokens
+}
+
+type openAIResponsesRequest struct {
+	Model           string                    `json:"model"`
+	Instructions    string                    `json:"instructions,omitempty"`
+	Input           []any                     `json:"input"`
+	Tools           []openAIResponsesTool     `json:"tools,omitempty"`
+	Stream          bool                      `json:"stream"`
+	Store           bool                      `json:"store"`
+	Include         []string                  `json:"include,omitempty"`
+	MaxOutputTokens int                       `json:"max_output_tokens,omitempty"`
+	Reasoning       *openAIResponsesReasoning `json:"reasoning,omitempty"`
+	Text            *openAIResponsesText      `json:"text,omitempty"`
+	PromptCacheKey  string                    `json:"prompt_cache_key,omitempty"`
+}
+
+type openAIResponsesReasoning struct {
+	Effort  string `json:"effort,omitempty"`
+	Summary string `json:"summary,omitempty"`
+}
+
+type openAIResponsesText struct {
+	Format openAIResponsesTextFormat `json:"format"`
+}
+
+type openAIResponsesTextFormat struct {
+	Type   string         `json:"type"`
+	Name   string         `json:"name,omitempty"`
+	Schema map[string]any `json:"schema,omitempty"`
+}
+
+type openAIResponsesTool struct {
+	Type        string         `json:"type"`
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Parameters  map[string]any `json:"parameters,omitempty"`
+}
+
+type openAIResponsesMessageItem struct {
+	Type    string `json:"type"`
+	Role    string `json:"role"`
+	Content any    `json:"content"`
+}
+
+type openAIResponsesInputContent struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	ImageURL string `json:"image_url,omitempty"`
+	Filename string `json:"filename,omitempty"`
+	FileData string `json:"file_data,omitempty"`
+}
+
+type openAIResponsesReasoningItem struct {
+	Type             string                   `json:"type"`
+	Summary          []openAIResponsesSummary `json:"summary"`
+	EncryptedContent string                   `json:"encrypted_content"`
+}
+
+type openAIResponsesSummary struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type openAIResponsesFunctionCallItem struct {
+	Type      string `json:"type"`
+	CallID    string `json:"call_id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+type openAIResponsesFunctionCallOutputItem struct {
+	Type   string `json:"type"`
+	CallID string `json:"call_id"`
+	Output string `json:"output"`
+}
+
+type openAIResponsesResponse struct {
+	Model             string                  `json:"model"`
+	Status            string                  `json:"status"`
+	Output            []openAIResponsesOutput `json:"output"`
+	Usage             openAIResponsesUsage    `json:"usage"`
+	IncompleteDetails *struct {
+		Reason string `json:"reason"`
+	} `json:"incomplete_details"`
+	Error *openAIResponsesError `json:"error"`
+}
+
+// openAIResponsesOutput is the union of the output item types this client
+// understands: message, reasoning and function_call.
+type openAIResponsesOutput struct {
+	Type             string                   `json:"type"`
+	Role             string                   `json:"role"`
+	Content          []openAIResponsesContent `json:"content"`
+	Summary          []openAIResponsesSummary `json:"summary"`
+	EncryptedContent string                   `json:"encrypted_content"`
+	CallID           string                   `json:"call_id"`
+	Name             string                   `json:"name"`
+	Arguments        string                   `json:"arguments"`
+}
+
+type openAIResponsesContent struct {
+	Type    string `json:"type"`
+	Text    string `json:"text"`
+	Refusal string `json:"refusal"`
+}
+
+type openAIResponsesUsage struct {
+	InputTokens        int `json:"input_tokens"`
+	OutputTokens       int `json:"output_tokens"`
+	TotalTokens        int `json:"total_tokens"`
+	InputTokensDetails struct {
+		CachedTokens int `json:"cached_tokens"`
+	} `json:"input_tokens_details"`
+	OutputTokensDetails struct {
+		ReasoningTokens int `json:"reasoning_tokens"`
+	} `json:"output_tokens_details"`
+}
+
+func (u openAIResponsesUsage) toKernel() model.Usage {
+	return model.Usage{
+		PromptTokens:     u.InputTokens,
+		CompletionTokens: u.OutputTokens,
+		TotalTokens:      u.TotalTokens,
+		CacheReadTokens:  u.InputTokensDetails.CachedTokens,
+		ReasoningTokens:  u.OutputTokensDetails.ReasoningTokens,
+	}
+}
+
+type openAIResponsesError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *openAIResponsesError) err() error {
+	if e == nil {
+		return fmt.Errorf("providers: responses stream failed")
+	}
+	err := fmt.Errorf("providers: responses stream failed: %s", strings.TrimSpace(e.Code+" "+e.Message))
+	if e.Code == "context_length_exceeded" {
+		return &model.ContextOverflowError{Cause: err}
+	}
+	return err
+}
+
+type openAIResponsesStreamEvent struct {
+	Type     string                   `json:"type"`
+	Delta    string                   `json:"delta"`
+	Item     *openAIResponsesOutput   `json:"item"`
+	Response *openAIResponsesResponse `json:"response"`
+	Code     string                   `json:"code"`
+	Message  string                   `json:"message"`
+}
+
+func (l *openAIResponsesLLM) Generate(ctx context.Context, req *model.Request) iter.Seq2[*model.StreamEvent, error] {
+	return func(yield func(*model.StreamEvent, error) bool) {
+		if req == nil {
+			yield(nil, fmt.Errorf("model: request is nil"))
+			return
+		}
+		raw, err := json.Marshal(l.buildRequest(req))
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+
+		runCtx := ctx
+		cancel := func() {}
+		if !req.Stream && l.requestTimeout > 0 {
+			runCtx, cancel = context.WithTimeout(ctx, l.requestTimeout)
+		}
+		defer cancel()
+
+		httpReq, err := http.NewRequestWithContext(runCtx, http.MethodPost, l.endpoint, bytes.NewReader(raw))
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		httpReq.Header.Set("Content-Type", "application/json")
+		applyDefaultAuthHeader(httpReq, Config{API: l.api, Provider: l.provider, Auth: l.auth}, l.token, false)
+		applyConfiguredHeaders(httpReq, l.headers)
+
+		resp, err := l.client.Do(httpReq)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			yield(nil, statusError(resp))
+			return
+		}
+
+		if !req.Stream {
+			var out openAIResponsesResponse
+			if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+				yield(nil, err)
+				return
+			}
+			if out.Status == "failed" {
+				yield(nil, out.Error.err())
+				return
+			}
+			yield(l.turnDone(out, out.Output), nil)
+			return
+		}
+
+		var (
+			final    *openAIResponsesResponse
+			done     []openAIResponsesOutput
+			stopped  bool
+			failure  error
+			emitPart = func(kind model.PartKind, text string) error {
+				if text == "" {
+					return nil
+				}
+				if !yield(&model.StreamEvent{
+					Type:      model.StreamEventPartDelta,
+					PartDelta: &model.PartDelta{Kind: kind, TextDelta: text},
+				}, nil) {
+					stopped = true
+					return errStopSSE
+				}
+				return nil
+			}
+		)
+		if err := readSSE(resp.Body, func(data []byte) error {
+			var event openAIResponsesStreamEvent
+			if err := json.Unmarshal(data, &event); err != nil {
+				return err
+			}
+			switch event.Type {
+			case "response.output_text.delta":
+				return emitPart(model.PartKindText, event.Delta)
+			case "response.reasoning_summary_text.delta":
+				return emitPart(model.PartKindReasoning, event.Delta)
+			case "response.output_item.done":
+				if event.Item != nil {
+					done = append(done, *event.Item)
+				}
+			case "response.completed", "response.incomplete":
+				final = event.Response
+				return errStopSSE
+			case "response.failed":
+				if event.Response != nil {
+					failure = event.Response.Error.err()
+				} else {
+					failure = (*openAIResponsesError)(nil).err()
+				}
+				return errStopSSE
+			case "error":
+				failure = (&openAIResponsesError{Code: event.Code, Message: event.Message}).err()
+				return errStopSSE
+			}
+			return nil
+		}); err != nil {
+			yield(nil, err)
+			return
+		}
+		if stopped {
+			return
+		}
+		if failure != nil {
+			yield(nil, failure)
+			return
+		}
+		if final == nil {
+			yield(nil, fmt.Errorf("providers: responses stream ended without a final response"))
+			return
+		}
+		output := final.Output
+		if len(output) == 0 {
+			output = done
+		}
+		yield(l.turnDone(*final, output), nil)
+	}
+}
+
+func (l *openAIResponsesLLM) buildRequest(req *model.Request) openAIResponsesRequest {
+	instructions := req.Instructions
+	format, needsInstruction := openAIResponseFormatFor(req.Output, true)
+	if needsInstruction {
+		instructions = withOutputInstruction(instructions, req.Output)
+	}
+	payload := openAIResponsesRequest{
+		Model:           l.name,
+		Instructions:    strings.TrimSpace(model.NewMessage(model.RoleSystem, instructions...).TextContent()),
+		Input:           fromKernelResponsesInput(req.Messages),
+		Tools:           fromKernelResponsesTools(model.FunctionToolDefinitions(req.Tools)),
+		Stream:          req.Stream,
+		Include:         []string{"reasoning.encrypted_content"},
+		MaxOutputTokens: l.maxOutputTok,
+		PromptCacheKey:  strings.TrimSpace(req.CacheKey),
+	}
+	if format != nil {
+		textFormat := openAIResponsesTextFormat{Type: format.Type}
+		if format.JSONSchema != nil {
+			textFormat.Name = format.JSONSchema.Name
+			textFormat.Schema = format.JSONSchema.Schema
+		}
+		payload.Text = &openAIResponsesText{Format: textFormat}
+	}
+	if effort := strings.TrimSpace(req.Reasoning.Effort); effort != "" {
+		payload.Reasoning = &openAIResponsesReasoning{Effort: effort}
+		if effort != "none" {
+			payload.Reasoning.Summary = "auto"
+		}
+	}
+	return payload
+}
+
+func fromKernelResponsesTools(tools []model.ToolDefinition) []openAIResponsesTool {
+	out := make([]openAIResponsesTool, 0, len(tools))
+	for _, t := range tools {
+		out = append(out, openAIResponsesTool{
+			Type:        "function",
+			Name:        t.Name,
+			Description: t.Description,
+			Parameters:  t.Parameters,
+		})
+	}
+	return out
+}
+
+// fromKernelResponsesInput flattens history into Responses input items.
+// Assistant parts keep their order so replayed reasoning precedes the calls
+// it produced. Tool outputs without a preceding call are dropped, as in the
+// chat completions mapping.
+func fromKernelResponsesInput(messages []model.Message) []any {
+	out := make([]any, 0, len(messages))
+	seenToolCalls := map[string]struct{}{}
+	for _, m := range messages {
+		if resp := m.ToolResponse(); resp != nil {
+			callID := strings.TrimSpace(resp.ID)
+			if _, ok := seenToolCalls[callID]; !ok || callID == "" {
+				continue
+			}
+			raw, _ := json.Marshal(resp.Result)
+			out = append(out, openAIResponsesFunctionCallOutputItem{
+				Type:   "function_call_output",
+				CallID: callID,
+				Output: string(raw),
+			})
+			continue
+		}
+		if m.Role == model.RoleTool {
+			continue
+		}
+		if m.Role == model.RoleUser {
+			if content := fromKernelResponsesUserContent(m); len(content) > 0 {
+				out = append(out, openAIResponsesMessageItem{Type: "message", Role: string(m.Role), Content: content})
+			}
+			continue
+		}
+		var text strings.Builder
+		flushText := func() {
+			if strings.TrimSpace(text.String()) == "" {
+				text.Reset()
+				return
+			}
+			out = append(out, openAIResponsesMessageItem{Type: "message", Role: string(m.Role), Content: text.String()})
+			text.Reset()
+		}
+		for _, part := range m.Parts {
+			switch part.Kind {
+			case model.PartKindText:
+				if part.Text != nil {
+					text.WriteString(part.Text.Text)
+				}
+			case model.PartKindReasoning:
+				item, ok := responsesReasoningItem(part.Reasoning)
+				if !ok {
+					continue
+				}
+				flushText()
+				out = append(out, item)
+			case model.PartKindToolUse:
+				if part.ToolUse == nil {
+					continue
+				}
+				flushText()
+				args := strings.TrimSpace(string(part.ToolUse.Input))
+				if args == "" {
+					args = "{}"
+				}
+				callID := strings.TrimSpace(part.ToolUse.ID)
+				if callID != "" {
+					seenToolCalls[callID] = struct{}{}
+				}
+				out = append(out, openAIResponsesFunctionCallItem{
+					Type:      "function_call",
+					CallID:    callID,
+					Name:      part.ToolUse.Name,
+					Arguments: args,
+				})
+			}
+		}
+		flushText()
+	}
+	return out
+}
+
+func fromKernelResponsesUserContent(m model.Message) []openAIResponsesInputContent {
+	contentParts := model.ContentPartsFromParts(m.Parts)
+	out := make([]openAIResponsesInputContent, 0, len(contentParts))
+	for _, cp := range contentParts {
+		switch {
+		case cp.Type == model.ContentPartImage:
+			out = append(out, openAIResponsesInputContent{
+				Type:     "input_image",
+				ImageURL: fmt.Sprintf("data:%s;base64,%s", cp.MimeType, cp.Data),
+			})
+			continue
+		case cp.Type == model.ContentPartDocument && cp.MimeType == "application/pdf":
+			out = append(out, openAIResponsesInputContent{
+				Type:     "input_file",
+				Filename: cp.FileName,
+				FileData: fmt.Sprintf("data:%s;base64,%s", cp.MimeType, cp.Data),
+			})
+			continue
+		}
+		if cp = mediaFallbackPart(cp); cp.Type == model.ContentPartText {
+			out = append(out, openAIResponsesInputContent{Type: "input_text", Text: cp.Text})
+		}
+	}
+	return out
+}
+
+// responsesReasoningItem rebuilds a reasoning input item from a part this
+// client produced. Reasoning from other providers, or without encrypted
+// content, cannot be replayed and is skipped.
+func responsesReasoningItem(part *model.ReasoningPart) (openAIResponsesReasoningItem, bool) {
+	if part == nil || part.Replay == nil || part.Replay.Kind != openAIResponsesReplayKindEncryptedReasoning {
+		return openAIResponsesReasoningItem{}, false
+	}
+	token := strings.TrimSpace(part.Replay.Token)
+	if token == "" {
+		return openAIResponsesReasoningItem{}, false
+	}
+	item := openAIResponsesReasoningItem{
+		Type:             "reasoning",
+		Summary:          []openAIResponsesSummary{},
+		EncryptedContent: token,
+	}
+	if part.VisibleText != nil && strings.TrimSpace(*part.VisibleText) != "" {
+		item.Summary = append(item.Summary, openAIResponsesSummary{Type: "summary_text", Text: *part.VisibleText})
+	}
+	return item, true
+}
+
+func (l *openAIResponsesLLM) turnDone(out openAIResponsesResponse, output []openAIResponsesOutput) *model.StreamEvent {
+	msg, hasCalls := l.toKernelMessage(output)
+	finishReason := model.FinishReasonStop
+	rawFinishReason := strings.TrimSpace(out.Status)
+	switch {
+	case hasCalls:
+		finishReason = model.FinishReasonToolCalls
+	case out.Status == "incomplete" && out.IncompleteDetails != nil:
+		rawFinishReason = strings.TrimSpace(out.IncompleteDetails.Reason)
+		switch rawFinishReason {
+		case "max_output_tokens":
+			finishReason = model.FinishReasonLength
+		case "content_filter":
+			finishReason = model.FinishReasonContentFilter
+		default:
+			finishReason = model.FinishReason(rawFinishReason)
+		}
+	}
+	name := strings.TrimSpace(out.Model)
+	if name == "" {
+		name = l.name
+	}
+	return &model.StreamEvent{
+		Type: model.StreamEventTurnDone,
+		Response: &model.Response{
+			Message:         msg,
+			TurnComplete:    true,
+			StepComplete:    true,
+			Status:          model.ResponseStatusCompleted,
+			FinishReason:    finishReason,
+			RawFinishReason: rawFinishReason,
+			Model:           name,
+			Provider:        l.provider,
+			Usage:           out.Usage.toKernel(),
+		},
+	}
+}
+
+func (l *openAIResponsesLLM) toKernelMessage(output []openAIResponsesOutput) (model.Message, bool) {
+	parts := make([]model.Part, 0, len(output))
+	hasCalls := false
+	for _, item := range output {
+		switch item.Type {
+		case "reasoning":
+			summaries := make([]string, 0, len(item.Summary))
+			for _, one := range item.Summary {
+				if strings.TrimSpace(one.Text) != "" {
+					summaries = append(summaries, one.Text)
+				}
+			}
+			text := strings.Join(summaries, "\n\n")
+			token := strings.TrimSpace(item.EncryptedContent)
+			if text == "" && token == "" {
+				continue
+			}
+			part := model.NewReasoningPart(text, model.ReasoningVisibilityVisible)
+			if token != "" {
+				part.Reasoning.Replay = &model.ReplayMeta{
+					Provider: l.provider,
+					Kind:     openAIResponsesReplayKindEncryptedReasoning,
+					Token:    token,
+				}
+			}
+			parts = append(parts, part)
+		case "message":
+			for _, content := range item.Content {
+				switch content.Type {
+				case "output_text":
+					if content.Text != "" {
+						parts = append(parts, model.NewTextPart(content.Text))
+					}
+				case "refusal":
+					if content.Refusal != "" {
+						parts = append(parts, model.NewTextPart(content.Refusal))
+					}
+				}
+			}
+		case "function_call":
+			hasCalls = true
+			parts = append(parts, model.NewToolUsePart(item.CallID, item.Name, json.RawMessage(strings.TrimSpace(item.Arguments))))
+		}
+	}
+	return model.Message{Role: model.RoleAssistant, Parts: parts}, hasCalls
+}
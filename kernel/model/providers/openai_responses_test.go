@@ -0,0 +1,227 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OnslaughtSnail/caelis/kernel/model"
+)
+
+func TestOpenAIResponses_ReplaysEncryptedReasoningAndMapsTools(t *testing.T) {
+	server, payload := captureOutputPayload(t, `{
+		"model":"gpt-5",
+		"status":"completed",
+		"output":[
+			{"type":"reasoning","id":"rs_1","summary":[{"type":"summary_text","text":"Check the file."}],"encrypted_content":"enc-2"},
+			{"type":"function_call","call_id":"call_2","name":"READ","arguments":"{\"path\":\"b.go\"}"}
+		],
+		"usage":{"input_tokens":40,"input_tokens_details":{"cached_tokens":32},"output_tokens":12,"output_tokens_details":{"reasoning_tokens":8},"total_tokens":52}
+	}`)
+	factory := NewFactory()
+	if err := factory.Register(Config{
+		Alias:    "openai/gpt-5",
+		Provider: "openai",
+		API:      APIOpenAIResponses,
+		Model:    "gpt-5",
+		BaseURL:  server.URL,
+		Timeout:  2 * time.Second,
+		Auth:     AuthConfig{Type: AuthAPIKey, Token: "sk"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	llm, err := factory.NewByAlias("openai/gpt-5")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prior := model.NewReasoningPart("Look at a.go first.", model.ReasoningVisibilityVisible)
+	prior.Reasoning.Replay = &model.ReplayMeta{Provider: "openai", Kind: openAIResponsesReplayKindEncryptedReasoning, Token: "enc-1"}
+	final := generateFinal(t, llm, &model.Request{
+		Instructions: []model.Part{model.NewTextPart("Be brief.")},
+		Messages: []model.Message{
+			model.NewTextMessage(model.RoleUser, "read a.go"),
+			{Role: model.RoleAssistant, Parts: []model.Part{
+				prior,
+				model.NewToolUsePart("call_1", "READ", json.RawMessage(`{"path":"a.go"}`)),
+			}},
+			model.MessageFromToolResponse(&model.ToolResponse{ID: "call_1", Name: "READ", Result: map[string]any{"content": "package a"}}),
+		},
+		Tools:     []model.ToolSpec{model.NewFunctionToolSpec("READ", "Read a file.", map[string]any{"type": "object"})},
+		Reasoning: model.ReasoningConfig{Effort: "high"},
+		CacheKey:  "session-1",
+	})
+
+	if (*payload)["instructions"] != "Be brief." || (*payload)["store"] != false || (*payload)["prompt_cache_key"] != "session-1" {
+		t.Fatalf("unexpected request envelope: %+v", *payload)
+	}
+	if reasoning, _ := (*payload)["reasoning"].(map[string]any); reasoning["effort"] != "high" || reasoning["summary"] != "auto" {
+		t.Fatalf("unexpected reasoning config: %+v", (*payload)["reasoning"])
+	}
+	if include := fmt.Sprint((*payload)["include"]); !strings.Contains(include, "reasoning.encrypted_content") {
+		t.Fatalf("expected encrypted reasoning to be requested, got %s", include)
+	}
+	tools, _ := (*payload)["tools"].([]any)
+	if tool, _ := tools[0].(map[string]any); tool["type"] != "function" || tool["name"] != "READ" {
+		t.Fatalf("expected flat function tool, got %+v", tools)
+	}
+	input, _ := (*payload)["input"].([]any)
+	var types []string
+	for _, item := range input {
+		one, _ := item.(map[string]any)
+		types = append(types, fmt.Sprint(one["type"]))
+	}
+	if got := strings.Join(types, ","); got != "message,reasoning,function_call,function_call_output" {
+		t.Fatalf("unexpected input item order %s", got)
+	}
+	replayed, _ := input[1].(map[string]any)
+	summary, _ := replayed["summary"].([]any)
+	if replayed["encrypted_content"] != "enc-1" || len(summary) != 1 {
+		t.Fatalf("expected reasoning item replayed with summary, got %+v", replayed)
+	}
+	if output, _ := input[3].(map[string]any); output["call_id"] != "call_1" || !strings.Contains(fmt.Sprint(output["output"]), "package a") {
+		t.Fatalf("unexpected function_call_output %+v", output)
+	}
+
+	parts := final.Message.ReasoningParts()
+	if len(parts) != 1 || parts[0].Replay == nil || parts[0].Replay.Token != "enc-2" || parts[0].Replay.Kind != openAIResponsesReplayKindEncryptedReasoning {
+		t.Fatalf("expected encrypted reasoning stored for replay, got %+v", parts)
+	}
+	if calls := final.Message.ToolCalls(); len(calls) != 1 || calls[0].ID != "call_2" || final.FinishReason != model.FinishReasonToolCalls {
+		t.Fatalf("unexpected calls %+v finish=%q", calls, final.FinishReason)
+	}
+	if final.Usage.PromptTokens != 40 || final.Usage.CacheReadTokens != 32 || final.Usage.ReasoningTokens != 8 {
+		t.Fatalf("unexpected usage %+v", final.Usage)
+	}
+}
+
+func TestOpenAIResponses_StreamsDeltasAndMapsSchemaOutput(t *testing.T) {
+	var payload map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Fatalf("decode request payload: %v", err)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range []string{
+			`{"type":"response.reasoning_summary_text.delta","delta":"Thinking"}`,
+			`{"type":"response.output_text.delta","delta":"{\"answer\":"}`,
+			`{"type":"response.output_text.delta","delta":"\"ok\"}"}`,
+			`{"type":"response.incomplete","response":{"model":"gpt-5","status":"incomplete","incomplete_details":{"reason":"max_output_tokens"},"output":[{"type":"message","role":"assistant","content":[{"type":"output_text","text":"{\"answer\":\"ok\"}"}]}],"usage":{"input_tokens":5,"output_tokens":4,"total_tokens":9}}}`,
+		} {
+			_, _ = fmt.Fprintf(w, "data: %s\n\n", event)
+		}
+	}))
+	defer server.Close()
+	llm := newOpenAIResponses(Config{Provider: "openai", Model: "gpt-5", BaseURL: server.URL, Timeout: 2 * time.Second}, "sk")
+
+	req := schemaOutputRequest()
+	req.Stream = true
+	var deltas []string
+	var final *model.Response
+	for event, err := range llm.Generate(context.Background(), req) {
+		if err != nil {
+			t.Fatalf("generate failed: %v", err)
+		}
+		if event.PartDelta != nil {
+			deltas = append(deltas, string(event.PartDelta.Kind)+":"+event.PartDelta.TextDelta)
+		}
+		if event.Response != nil {
+			final = event.Response
+		}
+	}
+
+	text, _ := payload["text"].(map[string]any)
+	format, _ := text["format"].(map[string]any)
+	if format["type"] != "json_schema" || format["name"] != structuredOutputSchemaName || format["schema"] == nil {
+		t.Fatalf("expected json_schema text format, got %+v", payload["text"])
+	}
+	if len(deltas) != 3 || !strings.HasPrefix(deltas[0], string(model.PartKindReasoning)+":") {
+		t.Fatalf("unexpected deltas %v", deltas)
+	}
+	if final == nil || final.Message.TextContent() != `{"answer":"ok"}` || final.FinishReason != model.FinishReasonLength {
+		t.Fatalf("unexpected final response %+v", final)
+	}
+}
+
+func TestAzureOpenAI_UsesDeploymentEndpointAndAPIKeyHeader(t *testing.T) {
+	var path, query, apiKey, bearer string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, query = r.URL.Path, r.URL.RawQuery
+		apiKey, bearer = r.Header.Get("api-key"), r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodGet {
+			_, _ = fmt.Fprint(w, `{"data":[{"id":"prod-gpt5","model":"gpt-5","status":"succeeded"},{"id":"pending","model":"gpt-4o","status":"running"}]}`)
+			return
+		}
+		_, _ = fmt.Fprint(w, `{"model":"prod-gpt5","status":"completed","output":[{"type":"message","role":"assistant","content":[{"type":"output_text","text":"hi"}]}]}`)
+	}))
+	defer server.Close()
+	cfg := Config{
+		Provider:   "azure-openai",
+		API:        APIAzureOpenAI,
+		Model:      "prod-gpt5",
+		BaseURL:    server.URL + "/openai/",
+		APIVersion: "2025-03-01-preview",
+		Timeout:    2 * time.Second,
+		Auth:       AuthConfig{Type: AuthAPIKey, Token: "azure-key"},
+	}
+
+	generateFinal(t, newAzureOpenAI(cfg, "azure-key"), &model.Request{Messages: []model.Message{model.NewTextMessage(model.RoleUser, "hi")}})
+	if path != "/openai/responses" || query != "api-version=2025-03-01-preview" {
+		t.Fatalf("unexpected endpoint %s?%s", path, query)
+	}
+	if apiKey != "azure-key" || bearer != "" {
+		t.Fatalf("expected api-key auth, got api-key=%q authorization=%q", apiKey, bearer)
+	}
+
+	models, err := DiscoverModels(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("discover deployments: %v", err)
+	}
+	if path != "/openai/deployments" || len(models) != 1 || models[0].Name != "prod-gpt5" {
+		t.Fatalf("expected ready deployments only, got path=%s models=%+v", path, models)
+	}
+
+	if got := azureOpenAIResponsesURL("https://res.openai.azure.com/openai/v1/", ""); got != "https://res.openai.azure.com/openai/v1/responses" {
+		t.Fatalf("unexpected v1 endpoint %s", got)
+	}
+}
+
+func TestAzureOpenAI_DeploymentUsesChatCompletionsDialect(t *testing.T) {
+	var path, query, apiKey, bearer string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, query = r.URL.Path, r.URL.RawQuery
+		apiKey, bearer = r.Header.Get("api-key"), r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprint(w, openAIChatOKBody)
+	}))
+	defer server.Close()
+	llm := newAzureOpenAI(Config{
+		Provider:   "azure-openai",
+		API:        APIAzureOpenAI,
+		BaseURL:    server.URL,
+		APIVersion: "2024-10-21",
+		Deployment: "chat-gpt4o",
+		Timeout:    2 * time.Second,
+		Auth:       AuthConfig{Type: AuthAPIKey, Token: "azure-key"},
+	}, "azure-key")
+
+	final := generateFinal(t, llm, &model.Request{Messages: []model.Message{model.NewTextMessage(model.RoleUser, "hi")}})
+	if final == nil || final.Message.TextContent() != "ok" {
+		t.Fatalf("unexpected final response %+v", final)
+	}
+	if path != "/openai/deployments/chat-gpt4o/chat/completions" || query != "api-version=2024-10-21" {
+		t.Fatalf("unexpected endpoint %s?%s", path, query)
+	}
+	if apiKey != "azure-key" || bearer != "" {
+		t.Fatalf("expected api-key auth, got api-key=%q authorization=%q", apiKey, bearer)
+	}
+	if llm.Name() != "chat-gpt4o" {
+		t.Fatalf("expected the deployment to name the model, got %q", llm.Name())
+	}
+}
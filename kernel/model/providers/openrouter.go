@@ -39,6 +39,7 @@ type openRouterRequest struct {
 	MaxTokens       int                        `json:"max_tokens,omitempty"`
 	ReasoningEffort string                     `json:"reasoning_effort,omitempty"`
 	Reasoning       *openAIReasoning           `json:"reasoning,omitempty"`
+	ResponseFormat  *openAIResponseFormat      `json:"response_format,omitempty"`
 	Transforms      []string                   `json:"transforms,omitempty"`
 	Provider        map[string]any             `json:"provider,omitempty"`
 	Plugins         []map[string]any           `json:"plugins,omitempty"`
@@ -68,11 +69,7 @@ type openRouterResponse struct {
 		Message      openRouterMsg `json:"message"`
 		FinishReason string        `json:"finish_reason"`
 	} `json:"choices"`
-	Usage struct {
-		PromptTokens     int `json:"prompt_tokens"`
-		CompletionTokens int `json:"completion_tokens"`
-		TotalTokens      int `json:"total_tokens"`
-	} `json:"usage"`
+	Usage openAICompatUsage `json:"usage"`
 }
 
 type openRouterStreamChunk struct {
@@ -81,11 +78,7 @@ type openRouterStreamChunk struct {
 		Delta        openRouterMsg `json:"delta"`
 		FinishReason string        `json:"finish_reason"`
 	} `json:"choices"`
-	Usage struct {
-		PromptTokens     int `json:"prompt_tokens"`
-		CompletionTokens int `json:"completion_tokens"`
-		TotalTokens      int `json:"total_tokens"`
-	} `json:"usage"`
+	Usage openAICompatUsage `json:"usage"`
 }
 
 type openRouterStreamAccumulator struct {
@@ -133,17 +126,23 @@ func (l *openRouterLLM) Generate(ctx context.Context, req *model.Request) iter.S
 			yield(nil, fmt.Errorf("model: request is nil"))
 			return
 		}
+		instructions := req.Instructions
+		responseFormat, needsInstruction := openAIResponseFormatFor(req.Output, true)
+		if needsInstruction {
+			instructions = withOutputInstruction(instructions, req.Output)
+		}
 		payload := openRouterRequest{
-			Model:      normalizeOpenRouterModelID(l.name),
-			Models:     normalizeOpenRouterModelIDs(l.config.Models),
-			Route:      strings.TrimSpace(l.config.Route),
-			Messages:   l.fromKernelMessages(req.Instructions, req.Messages),
-			Tools:      fromKernelTools(model.FunctionToolDefinitions(req.Tools)),
-			Stream:     req.Stream,
-			MaxTokens:  l.maxOutputTok,
-			Transforms: cloneStringSlice(l.config.Transforms),
-			Provider:   cloneAnyMap(l.config.Provider),
-			Plugins:    cloneMapSlice(l.config.Plugins),
+			Model:          normalizeOpenRouterModelID(l.name),
+			Models:         normalizeOpenRouterModelIDs(l.config.Models),
+			Route:          strings.TrimSpace(l.config.Route),
+			Messages:       l.fromKernelMessages(instructions, req.Messages),
+			Tools:          fromKernelTools(model.FunctionToolDefinitions(req.Tools)),
+			Stream:         req.Stream,
+			MaxTokens:      l.maxOutputTok,
+			ResponseFormat: responseFormat,
+			Transforms:     cloneStringSlice(l.config.Transforms),
+			Provider:       cloneAnyMap(l.config.Provider),
+			Plugins:        cloneMapSlice(l.config.Plugins),
 		}
 		if req.Stream {
 			payload.StreamOptions = &openAICompatStreamOptions{IncludeUsage: true}
@@ -223,11 +222,7 @@ func (l *openRouterLLM) Generate(ctx context.Context, req *model.Request) iter.S
 					FinishReason: normalizeOpenAICompatFinishReason(out.Choices[0].FinishReason),
 					Model:        out.Model,
 					Provider:     l.provider,
-					Usage: model.Usage{
-						PromptTokens:     out.Usage.PromptTokens,
-						CompletionTokens: out.Usage.CompletionTokens,
-						TotalTokens:      out.Usage.TotalTokens,
-					},
+					Usage:        out.Usage.toKernel(),
 				},
 			}, nil)
 			return
@@ -245,12 +240,8 @@ func (l *openRouterLLM) Generate(ctx context.Context, req *model.Request) iter.S
 			if err := json.Unmarshal(data, &chunk); err != nil {
 				return err
 			}
-			if chunk.Usage.PromptTokens > 0 || chunk.Usage.CompletionTokens > 0 || chunk.Usage.TotalTokens > 0 {
-				usage = model.Usage{
-					PromptTokens:     chunk.Usage.PromptTokens,
-					CompletionTokens: chunk.Usage.CompletionTokens,
-					TotalTokens:      chunk.Usage.TotalTokens,
-				}
+			if one := chunk.Usage.toKernel(); one.PromptTokens > 0 || one.CompletionTokens > 0 || one.TotalTokens > 0 {
+				usage = one
 			}
 			if len(chunk.Choices) == 0 {
 				return nil
@@ -413,21 +404,9 @@ func (l *openRouterLLM) fromKernelMessage(m model.Message) openRouterReqMsg {
 	if m.Role == model.RoleUser {
 		contentParts := model.ContentPartsFromParts(m.Parts)
 		if len(contentParts) > 0 {
-			parts := make([]openAIContentPart, 0, len(contentParts))
-			for _, cp := range contentParts {
-				switch cp.Type {
-				case model.ContentPartText:
-					parts = append(parts, openAIContentPart{Type: "text", Text: cp.Text})
-				case model.ContentPartImage:
-					parts = append(parts, openAIContentPart{
-						Type:     "image_url",
-						ImageURL: &openAIImageURL{URL: fmt.Sprintf("data:%s;base64,%s", cp.MimeType, cp.Data)},
-					})
-				}
-			}
 			return openRouterReqMsg{
 				Role:    string(m.Role),
-				Content: parts,
+				Content: openAIUserContentParts(contentParts, true),
 			}
 		}
 	}
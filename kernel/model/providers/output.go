@@ -0,0 +1,102 @@
+package providers
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/OnslaughtSnail/caelis/kernel/model"
+)
+
+// structuredOutputToolName is the synthetic tool used to carry structured
+// output on providers that only enforce schemas on tool input.
+const structuredOutputToolName = "structured_output"
+
+const structuredOutputSchemaName = "output"
+
+type openAIResponseFormat struct {
+	Type       string                  `json:"type"`
+	JSONSchema *openAIJSONSchemaFormat `json:"json_schema,omitempty"`
+}
+
+type openAIJSONSchemaFormat struct {
+	Name   string         `json:"name"`
+	Schema map[string]any `json:"schema"`
+}
+
+// openAIResponseFormatFor maps spec to an OpenAI response_format. When the
+// endpoint only supports json_object, the schema is moved into the prompt and
+// needsInstruction is set. json_object mode also needs the word "JSON" in the
+// prompt, so it always carries the instruction.
+func openAIResponseFormatFor(spec *model.OutputSpec, schemaSupported bool) (format *openAIResponseFormat, needsInstruction bool) {
+	if !spec.Structured() {
+		return nil, false
+	}
+	if spec.Mode == model.OutputModeSchema && len(spec.JSONSchema) > 0 && schemaSupported {
+		return &openAIResponseFormat{
+			Type: "json_schema",
+			JSONSchema: &openAIJSONSchemaFormat{
+				Name:   structuredOutputSchemaName,
+				Schema: spec.JSONSchema,
+			},
+		}, false
+	}
+	return &openAIResponseFormat{Type: "json_object"}, true
+}
+
+// outputInstruction is the prompt fallback for endpoints that cannot enforce
+// the requested format natively.
+func outputInstruction(spec *model.OutputSpec) string {
+	if !spec.Structured() {
+		return ""
+	}
+	if spec.Mode == model.OutputModeSchema && len(spec.JSONSchema) > 0 {
+		raw, err := json.Marshal(spec.JSONSchema)
+		if err == nil {
+			return "Reply with a single JSON value that conforms to this JSON Schema, with no surrounding prose or code fences:\n" + string(raw)
+		}
+	}
+	return "Reply with a single valid JSON value, with no surrounding prose or code fences."
+}
+
+func withOutputInstruction(instructions []model.Part, spec *model.OutputSpec) []model.Part {
+	text := outputInstruction(spec)
+	if text == "" {
+		return instructions
+	}
+	out := make([]model.Part, 0, len(instructions)+1)
+	out = append(out, instructions...)
+	return append(out, model.NewTextPart(text))
+}
+
+// outputToolSchema is the input schema of the synthetic structured output
+// tool. Tool input must be an object, so JSON mode accepts any object.
+func outputToolSchema(spec *model.OutputSpec) map[string]any {
+	if spec.Mode == model.OutputModeSchema && len(spec.JSONSchema) > 0 {
+		return spec.JSONSchema
+	}
+	return map[string]any{"type": "object"}
+}
+
+// unwrapStructuredOutputTool turns a call to the synthetic output tool back
+// into the text answer the caller asked for.
+func unwrapStructuredOutputTool(msg model.Message) (model.Message, bool) {
+	unwrapped := false
+	parts := make([]model.Part, 0, len(msg.Parts))
+	for _, part := range msg.Parts {
+		if part.ToolUse != nil && strings.EqualFold(part.ToolUse.Name, structuredOutputToolName) {
+			input := strings.TrimSpace(string(part.ToolUse.Input))
+			if input == "" {
+				input = "{}"
+			}
+			parts = append(parts, model.NewTextPart(input))
+			unwrapped = true
+			continue
+		}
+		parts = append(parts, part)
+	}
+	if !unwrapped {
+		return msg, false
+	}
+	msg.Parts = parts
+	return msg, true
+}
@@ -0,0 +1,160 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OnslaughtSnail/caelis/kernel/model"
+)
+
+var testOutputSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"answer": map[string]any{"type": "string"},
+	},
+	"required": []any{"answer"},
+}
+
+// captureOutputPayload serves one canned response and records the decoded
+// request body.
+func captureOutputPayload(t *testing.T, body string) (*httptest.Server, *map[string]any) {
+	t.Helper()
+	var payload map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Fatalf("decode request payload: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprint(w, body)
+	}))
+	t.Cleanup(server.Close)
+	return server, &payload
+}
+
+func generateFinal(t *testing.T, llm model.LLM, req *model.Request) *model.Response {
+	t.Helper()
+	var final *model.Response
+	for event, err := range llm.Generate(context.Background(), req) {
+		if err != nil {
+			t.Fatalf("generate failed: %v", err)
+		}
+		if event != nil && event.Response != nil && event.TurnComplete {
+			final = event.Response
+		}
+	}
+	if final == nil {
+		t.Fatal("expected final response")
+	}
+	return final
+}
+
+func schemaOutputRequest() *model.Request {
+	return &model.Request{
+		Messages: []model.Message{model.NewTextMessage(model.RoleUser, "hi")},
+		Output:   &model.OutputSpec{Mode: model.OutputModeSchema, JSONSchema: testOutputSchema},
+	}
+}
+
+func TestOpenAICompatRequest_MapsSchemaOutputToResponseFormat(t *testing.T) {
+	server, payload := captureOutputPayload(t, `{"model":"m","choices":[{"message":{"role":"assistant","content":"{\"answer\":\"ok\"}"}}]}`)
+	llm := newOpenAICompat(Config{Provider: "openai", Model: "m", BaseURL: server.URL, Timeout: 2 * time.Second}, "token")
+
+	generateFinal(t, llm, schemaOutputRequest())
+
+	format, _ := (*payload)["response_format"].(map[string]any)
+	if format["type"] != "json_schema" {
+		t.Fatalf("expected json_schema response_format, got %+v", (*payload)["response_format"])
+	}
+	jsonSchema, _ := format["json_schema"].(map[string]any)
+	if schema, _ := jsonSchema["schema"].(map[string]any); schema["type"] != "object" {
+		t.Fatalf("expected schema to be forwarded, got %+v", jsonSchema)
+	}
+	if messages, _ := (*payload)["messages"].([]any); len(messages) != 1 {
+		t.Fatalf("expected no extra instruction message, got %+v", messages)
+	}
+}
+
+func TestDeepSeekRequest_FallsBackToJSONObjectWithSchemaInstruction(t *testing.T) {
+	server, payload := captureOutputPayload(t, `{"model":"deepseek-chat","choices":[{"message":{"role":"assistant","content":"{\"answer\":\"ok\"}"}}]}`)
+	llm := newDeepSeek(Config{Provider: "deepseek", Model: "deepseek-chat", BaseURL: server.URL, Timeout: 2 * time.Second}, "token")
+
+	generateFinal(t, llm, schemaOutputRequest())
+
+	format, _ := (*payload)["response_format"].(map[string]any)
+	if format["type"] != "json_object" {
+		t.Fatalf("expected json_object response_format, got %+v", (*payload)["response_format"])
+	}
+	messages, _ := (*payload)["messages"].([]any)
+	system, _ := messages[0].(map[string]any)
+	if system["role"] != "system" || !strings.Contains(fmt.Sprint(system["content"]), `"answer"`) {
+		t.Fatalf("expected schema instruction in system message, got %+v", messages[0])
+	}
+}
+
+func TestGeminiRequest_MapsSchemaOutputWithoutTools(t *testing.T) {
+	server, payload := captureOutputPayload(t, `{"candidates":[{"content":{"role":"model","parts":[{"text":"{\"answer\":\"ok\"}"}]}}]}`)
+	llm := newGemini(Config{Provider: "gemini", Model: "test-model", BaseURL: server.URL, Timeout: 2 * time.Second}, "token")
+
+	generateFinal(t, llm, schemaOutputRequest())
+
+	cfg, _ := (*payload)["generationConfig"].(map[string]any)
+	if cfg["responseMimeType"] != "application/json" {
+		t.Fatalf("expected JSON response MIME type, got %+v", cfg)
+	}
+	if schema, _ := cfg["responseJsonSchema"].(map[string]any); schema["type"] != "object" {
+		t.Fatalf("expected response JSON schema, got %+v", cfg["responseJsonSchema"])
+	}
+}
+
+func TestOllamaRequest_MapsOutputToFormat(t *testing.T) {
+	server, payload := captureOutputPayload(t, `{"model":"llama3","message":{"role":"assistant","content":"{\"answer\":\"ok\"}"},"done":true}`)
+	llm := newOllama(Config{Provider: "ollama", Model: "llama3", BaseURL: server.URL, Timeout: 2 * time.Second}, "")
+
+	req := schemaOutputRequest()
+	req.Output.Mode = model.OutputModeJSON
+	generateFinal(t, llm, req)
+	if (*payload)["format"] != "json" {
+		t.Fatalf("expected format=json, got %+v", (*payload)["format"])
+	}
+
+	generateFinal(t, llm, schemaOutputRequest())
+	if format, _ := (*payload)["format"].(map[string]any); format["type"] != "object" {
+		t.Fatalf("expected schema format, got %+v", (*payload)["format"])
+	}
+}
+
+func TestAnthropicRequest_ForcesStructuredOutputToolAndUnwrapsAnswer(t *testing.T) {
+	server, payload := captureOutputPayload(t, `{"id":"msg_1","type":"message","role":"assistant","model":"test-model","stop_reason":"tool_use","content":[{"type":"tool_use","id":"call_1","name":"structured_output","input":{"answer":"ok"}}],"usage":{"input_tokens":3,"output_tokens":2}}`)
+	llm := newAnthropic(Config{
+		Provider: "anthropic",
+		API:      APIAnthropic,
+		Model:    "test-model",
+		BaseURL:  server.URL,
+		Timeout:  2 * time.Second,
+		Auth:     AuthConfig{Type: AuthAPIKey, Token: "sk"},
+	}, "sk")
+
+	final := generateFinal(t, llm, schemaOutputRequest())
+
+	choice, _ := (*payload)["tool_choice"].(map[string]any)
+	if choice["type"] != "tool" || choice["name"] != structuredOutputToolName {
+		t.Fatalf("expected forced structured output tool, got %+v", (*payload)["tool_choice"])
+	}
+	tools, _ := (*payload)["tools"].([]any)
+	tool0, _ := tools[0].(map[string]any)
+	if schema, _ := tool0["input_schema"].(map[string]any); schema["type"] != "object" || schema["properties"] == nil {
+		t.Fatalf("expected output schema as tool input schema, got %+v", tool0)
+	}
+	if got := final.Message.TextContent(); got != `{"answer":"ok"}` {
+		t.Fatalf("expected unwrapped JSON answer, got %q", got)
+	}
+	if len(final.Message.ToolCalls()) != 0 || final.FinishReason != model.FinishReasonStop {
+		t.Fatalf("expected plain stop, got calls=%+v finish=%q", final.Message.ToolCalls(), final.FinishReason)
+	}
+}
@@ -0,0 +1,103 @@
+package providers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/OnslaughtSnail/caelis/kernel/model"
+)
+
+func cachedConversationRequest() *model.Request {
+	return &model.Request{
+		Instructions: []model.Part{model.NewTextPart("system prompt")},
+		Messages: []model.Message{
+			model.NewTextMessage(model.RoleUser, "first"),
+			model.NewTextMessage(model.RoleAssistant, "first answer"),
+			model.NewTextMessage(model.RoleUser, "second"),
+			model.NewTextMessage(model.RoleAssistant, "second answer"),
+			model.NewTextMessage(model.RoleUser, "third"),
+		},
+		Tools: []model.ToolSpec{
+			model.NewFunctionToolSpec("READ", "read a file", map[string]any{"type": "object"}),
+			model.NewFunctionToolSpec("WRITE", "write a file", map[string]any{"type": "object"}),
+		},
+		CacheKey: "session-1",
+	}
+}
+
+func hasCacheControl(block any) bool {
+	entry, _ := block.(map[string]any)
+	cc, _ := entry["cache_control"].(map[string]any)
+	return cc["type"] == "ephemeral"
+}
+
+func lastContentBlock(message any) any {
+	msg, _ := message.(map[string]any)
+	content, _ := msg["content"].([]any)
+	if len(content) == 0 {
+		return nil
+	}
+	return content[len(content)-1]
+}
+
+func TestAnthropicRequest_SetsPromptCacheBreakpointsAndReportsCacheUsage(t *testing.T) {
+	server, payload := captureOutputPayload(t, `{"id":"msg_1","type":"message","role":"assistant","model":"test-model","stop_reason":"end_turn","content":[{"type":"text","text":"ok"}],"usage":{"input_tokens":5,"output_tokens":2,"cache_read_input_tokens":100,"cache_creation_input_tokens":20}}`)
+	llm := newAnthropic(Config{
+		Provider: "anthropic",
+		API:      APIAnthropic,
+		Model:    "test-model",
+		BaseURL:  server.URL,
+		Timeout:  2 * time.Second,
+		Auth:     AuthConfig{Type: AuthAPIKey, Token: "sk"},
+	}, "sk")
+
+	final := generateFinal(t, llm, cachedConversationRequest())
+
+	tools, _ := (*payload)["tools"].([]any)
+	if len(tools) != 2 || hasCacheControl(tools[0]) || !hasCacheControl(tools[1]) {
+		t.Fatalf("expected breakpoint on the last tool only, got %+v", tools)
+	}
+	system, _ := (*payload)["system"].([]any)
+	if len(system) != 1 || !hasCacheControl(system[0]) {
+		t.Fatalf("expected breakpoint on the system prompt, got %+v", system)
+	}
+	messages, _ := (*payload)["messages"].([]any)
+	if len(messages) != 5 {
+		t.Fatalf("expected 5 messages, got %+v", messages)
+	}
+	marked := 0
+	for i, msg := range messages {
+		if hasCacheControl(lastContentBlock(msg)) {
+			marked++
+			if i != 2 && i != 4 {
+				t.Fatalf("unexpected breakpoint on message %d: %+v", i, msg)
+			}
+		}
+	}
+	if marked != 2 {
+		t.Fatalf("expected breakpoints on the last two user turns, got %d", marked)
+	}
+	want := model.Usage{PromptTokens: 125, CompletionTokens: 2, TotalTokens: 127, CacheReadTokens: 100, CacheWriteTokens: 20}
+	if final.Usage != want {
+		t.Fatalf("unexpected usage %+v", final.Usage)
+	}
+}
+
+func TestOpenAIRequest_SendsPromptCacheKeyOnlyForOpenAI(t *testing.T) {
+	body := `{"model":"m","choices":[{"message":{"role":"assistant","content":"ok"}}],"usage":{"prompt_tokens":10,"completion_tokens":1,"total_tokens":11,"prompt_tokens_details":{"cached_tokens":8}}}`
+	server, payload := captureOutputPayload(t, body)
+
+	final := generateFinal(t, newOpenAI(Config{Provider: "openai", Model: "m", BaseURL: server.URL, Timeout: 2 * time.Second}, "token"), cachedConversationRequest())
+	if (*payload)["prompt_cache_key"] != "session-1" {
+		t.Fatalf("expected prompt_cache_key, got %+v", (*payload)["prompt_cache_key"])
+	}
+	if final.Usage.CacheReadTokens != 8 {
+		t.Fatalf("expected cached tokens in usage, got %+v", final.Usage)
+	}
+
+	compatServer, compatPayload := captureOutputPayload(t, body)
+	generateFinal(t, newOpenAICompat(Config{Provider: "local", Model: "m", BaseURL: compatServer.URL, Timeout: 2 * time.Second}, "token"), cachedConversationRequest())
+	if _, ok := (*compatPayload)["prompt_cache_key"]; ok {
+		t.Fatalf("expected no prompt_cache_key for generic compatible endpoints, got %+v", *compatPayload)
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/OnslaughtSnail/caelis/kernel/model"
+	"github.com/anthropics/anthropic-sdk-go"
 	"google.golang.org/genai"
 )
 
@@ -80,6 +81,32 @@ func TestFactoryRequiresTokenFromConfig(t *testing.T) {
 	}
 }
 
+func TestFactoryBuildsFallbackChainFromUsableAliases(t *testing.T) {
+	factory := NewFactory()
+	for _, cfg := range []Config{
+		{Alias: "primary", Provider: "openai", API: APIOpenAI, Model: "gpt-a", Auth: AuthConfig{Token: "a"}},
+		{Alias: "backup", Provider: "deepseek", API: APIDeepSeek, Model: "deepseek-chat", Auth: AuthConfig{Token: "b"}},
+		{Alias: "no-token", Provider: "openai", API: APIOpenAI, Model: "gpt-c"},
+	} {
+		if err := factory.Register(cfg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	factory.SetFallbacks([]string{"Primary", "no-token", "missing", "backup", "backup"})
+
+	llm, err := factory.NewByAlias("primary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain := model.FallbackModels(llm)
+	if len(chain) != 2 || chain[0].Name() != "gpt-a" || chain[1].Name() != "deepseek-chat" {
+		t.Fatalf("expected primary then backup, got %d models", len(chain))
+	}
+	if got := factory.Fallbacks(); len(got) != 4 {
+		t.Fatalf("expected normalized, deduplicated fallbacks, got %+v", got)
+	}
+}
+
 func TestOpenAICompatStream_PropagatesSSEErrorsWithoutTurnComplete(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path != "/chat/completions" {
@@ -192,7 +219,7 @@ func TestOpenAICompatStream_IncludesUsageRequestOptionAndPropagatesUsage(t *test
 
 		w.Header().Set("Content-Type", "text/event-stream")
 		_, _ = fmt.Fprint(w, "data: {\"model\":\"test-model\",\"choices\":[{\"delta\":{\"content\":\"hello\"}}]}\n\n")
-		_, _ = fmt.Fprint(w, "data: {\"model\":\"test-model\",\"choices\":[],\"usage\":{\"prompt_tokens\":11,\"completion_tokens\":7,\"total_tokens\":18}}\n\n")
+		_, _ = fmt.Fprint(w, "data: {\"model\":\"test-model\",\"choices\":[],\"usage\":{\"prompt_tokens\":11,\"completion_tokens\":7,\"total_tokens\":18,\"prompt_tokens_details\":{\"cached_tokens\":4},\"completion_tokens_details\":{\"reasoning_tokens\":3}}}\n\n")
 		_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
 	}))
 	defer server.Close()
@@ -226,11 +253,42 @@ func TestOpenAICompatStream_IncludesUsageRequestOptionAndPropagatesUsage(t *test
 	if !includeUsage {
 		t.Fatal("expected stream_options.include_usage=true in request payload")
 	}
-	if usage.PromptTokens != 11 || usage.CompletionTokens != 7 || usage.TotalTokens != 18 {
+	if usage.PromptTokens != 11 || usage.CompletionTokens != 7 || usage.TotalTokens != 18 || usage.CacheReadTokens != 4 || usage.ReasoningTokens != 3 {
 		t.Fatalf("unexpected usage: %+v", usage)
 	}
 }
 
+func TestUsageConversions_ReportCacheAndReasoningTokens(t *testing.T) {
+	anthropicUsage := anthropicUsageToKernel(anthropic.Usage{
+		InputTokens:              10,
+		CacheReadInputTokens:     80,
+		CacheCreationInputTokens: 5,
+		OutputTokens:             20,
+	})
+	if anthropicUsage != (model.Usage{PromptTokens: 95, CompletionTokens: 20, TotalTokens: 115, CacheReadTokens: 80, CacheWriteTokens: 5}) {
+		t.Fatalf("unexpected anthropic usage: %+v", anthropicUsage)
+	}
+
+	geminiUsage := geminiUsageFromResponse(&genai.GenerateContentResponse{UsageMetadata: &genai.GenerateContentResponseUsageMetadata{
+		PromptTokenCount:        50,
+		CachedContentTokenCount: 30,
+		CandidatesTokenCount:    12,
+		ThoughtsTokenCount:      8,
+		TotalTokenCount:         70,
+	}})
+	if geminiUsage != (model.Usage{PromptTokens: 50, CompletionTokens: 20, TotalTokens: 70, CacheReadTokens: 30, ReasoningTokens: 8}) {
+		t.Fatalf("unexpected gemini usage: %+v", geminiUsage)
+	}
+
+	var deepseek openAICompatUsage
+	if err := json.Unmarshal([]byte(`{"prompt_tokens":40,"completion_tokens":5,"total_tokens":45,"prompt_cache_hit_tokens":32}`), &deepseek); err != nil {
+		t.Fatal(err)
+	}
+	if got := deepseek.toKernel(); got.CacheReadTokens != 32 || got.PromptTokens != 40 {
+		t.Fatalf("unexpected deepseek usage: %+v", got)
+	}
+}
+
 func TestOpenAICompatNonStream_PropagatesFinishReason(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path != "/chat/completions" {
@@ -0,0 +1,398 @@
+package providers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/OnslaughtSnail/caelis/kernel/model"
+)
+
+const (
+	// CassetteRecordEnvVar names a cassette file that every model built by a
+	// Factory records its requests and streams into.
+	CassetteRecordEnvVar = "CAELIS_RECORD_CASSETTE"
+
+	// ReplayAliasPrefix selects the replay provider from a model alias:
+	// "replay/<cassette path>".
+	ReplayAliasPrefix = "replay/"
+
+	cassetteVersion = 1
+
+	cassetteErrorContextOverflow = "context_overflow"
+)
+
+// cassette is the on-disk form of a recorded model conversation.
+type cassette struct {
+	Version             int                   `json:"version"`
+	Model               string                `json:"model,omitempty"`
+	Provider            string                `json:"provider,omitempty"`
+	ContextWindowTokens int                   `json:"context_window_tokens,omitempty"`
+	Interactions        []cassetteInteraction `json:"interactions"`
+}
+
+// cassetteInteraction is one Generate call: the request, every stream event
+// and the error that ended the stream, if any.
+type cassetteInteraction struct {
+	Fingerprint string          `json:"fingerprint"`
+	Request     *model.Request  `json:"request,omitempty"`
+	Events      []cassetteEvent `json:"events,omitempty"`
+	Error       string          `json:"error,omitempty"`
+	ErrorKind   string          `json:"error_kind,omitempty"`
+}
+
+// cassetteEvent mirrors model.StreamEvent. The embedded Response is kept in
+// its own field because its Message would otherwise collide with the event's
+// Message in JSON.
+type cassetteEvent struct {
+	Type             model.StreamEventType `json:"type"`
+	PartDelta        *model.PartDelta      `json:"part_delta,omitempty"`
+	Message          *model.Message        `json:"message,omitempty"`
+	Response         *model.Response       `json:"response,omitempty"`
+	RawProviderEvent json.RawMessage       `json:"raw_provider_event,omitempty"`
+}
+
+func toCassetteEvent(event *model.StreamEvent) cassetteEvent {
+	out := cassetteEvent{
+		Type:             event.Type,
+		PartDelta:        event.PartDelta,
+		RawProviderEvent: event.RawProviderEvent,
+	}
+	if event.Message != nil {
+		msg := model.CloneMessage(*event.Message)
+		out.Message = &msg
+	}
+	if event.Response != nil {
+		resp := *event.Response
+		resp.Message = model.CloneMessage(event.Response.Message)
+		out.Response = &resp
+	}
+	return out
+}
+
+func (e cassetteEvent) streamEvent() *model.StreamEvent {
+	out := &model.StreamEvent{
+		Type:             e.Type,
+		PartDelta:        e.PartDelta,
+		RawProviderEvent: e.RawProviderEvent,
+	}
+	if e.Message != nil {
+		msg := model.CloneMessage(*e.Message)
+		out.Message = &msg
+	}
+	if e.Response != nil {
+		resp := *e.Response
+		resp.Message = model.CloneMessage(e.Response.Message)
+		out.Response = &resp
+	}
+	return out
+}
+
+func (i cassetteInteraction) err() error {
+	if i.Error == "" {
+		return nil
+	}
+	err := errors.New(i.Error)
+	if i.ErrorKind == cassetteErrorContextOverflow {
+		return &model.ContextOverflowError{Cause: err}
+	}
+	return err
+}
+
+// environmentValuePattern matches the environment context lines whose values
+// change between runs of the same session.
+var environmentValuePattern = regexp.MustCompile(`<(current_date|cwd|timezone)>[^<]*</(?:current_date|cwd|timezone)>`)
+
+// RequestFingerprint identifies a request by the content a provider would
+// see. Streaming, the cache key and message origins do not change what the
+// model answers, so they are left out, and the date, working directory and
+// timezone of the environment context are blanked so a cassette replays on
+// another day or checkout.
+func RequestFingerprint(req *model.Request) string {
+	if req == nil {
+		req = &model.Request{}
+	}
+	instructions := normalizeFingerprintParts(model.CloneParts(req.Instructions))
+	messages := model.CloneMessages(req.Messages)
+	for i := range messages {
+		messages[i].Origin = nil
+		messages[i].Parts = normalizeFingerprintParts(messages[i].Parts)
+	}
+	raw, err := json.Marshal(struct {
+		Instructions []model.Part          `json:"instructions,omitempty"`
+		Messages     []model.Message       `json:"messages,omitempty"`
+		Tools        []model.ToolSpec      `json:"tools,omitempty"`
+		Output       *model.OutputSpec     `json:"output,omitempty"`
+		Reasoning    model.ReasoningConfig `json:"reasoning,omitempty"`
+	}{instructions, messages, req.Tools, req.Output, req.Reasoning})
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:])
+}
+
+func normalizeFingerprintParts(parts []model.Part) []model.Part {
+	for i := range parts {
+		if parts[i].Text != nil {
+			parts[i].Text.Text = environmentValuePattern.ReplaceAllString(parts[i].Text.Text, "<$1></$1>")
+		}
+	}
+	return parts
+}
+
+// ReplayCassettePath returns the cassette path of a "replay/<path>" alias.
+func ReplayCassettePath(alias string) (string, bool) {
+	alias = strings.TrimSpace(alias)
+	if len(alias) <= len(ReplayAliasPrefix) || !strings.EqualFold(alias[:len(ReplayAliasPrefix)], ReplayAliasPrefix) {
+		return "", false
+	}
+	return strings.TrimSpace(alias[len(ReplayAliasPrefix):]), true
+}
+
+func loadCassette(path string) (*cassette, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("providers: read cassette: %w", err)
+	}
+	var out cassette
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return nil, fmt.Errorf("providers: decode cassette %s: %w", path, err)
+	}
+	if out.Version != cassetteVersion {
+		return nil, fmt.Errorf("providers: cassette %s has unsupported version %d", path, out.Version)
+	}
+	// Fingerprints are recomputed from the recorded requests so cassettes
+	// keep matching when the fingerprint rules change.
+	for i := range out.Interactions {
+		if out.Interactions[i].Request != nil {
+			out.Interactions[i].Fingerprint = RequestFingerprint(out.Interactions[i].Request)
+		}
+	}
+	return &out, nil
+}
+
+func writeCassette(path string, c *cassette) error {
+	raw, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(raw, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// cassetteFile is the process-wide state of one cassette path. Every recorder
+// and replay model built for the path shares it, so recordings from several
+// models land in one file and an interaction is replayed once per process.
+type cassetteFile struct {
+	path string
+
+	mu       sync.Mutex
+	cassette *cassette
+	used     []bool
+}
+
+var cassetteFiles = struct {
+	sync.Mutex
+	byPath map[string]*cassetteFile
+}{byPath: map[string]*cassetteFile{}}
+
+// openCassette returns the shared state of the cassette at path, loading it
+// on first use. A missing file is an error unless create is set.
+func openCassette(path string, create bool) (*cassetteFile, error) {
+	key := filepath.Clean(path)
+	if abs, err := filepath.Abs(key); err == nil {
+		key = abs
+	}
+	cassetteFiles.Lock()
+	defer cassetteFiles.Unlock()
+	if file, ok := cassetteFiles.byPath[key]; ok {
+		return file, nil
+	}
+	c := &cassette{Version: cassetteVersion}
+	if _, err := os.Stat(path); err == nil || !create {
+		loaded, err := loadCassette(path)
+		if err != nil {
+			return nil, err
+		}
+		c = loaded
+	}
+	file := &cassetteFile{path: path, cassette: c}
+	cassetteFiles.byPath[key] = file
+	return file, nil
+}
+
+func (f *cassetteFile) identity() (name string, provider string, contextWindow int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.cassette.Model, f.cassette.Provider, f.cassette.ContextWindowTokens
+}
+
+// take marks and returns the first unused interaction with fingerprint.
+func (f *cassetteFile) take(fingerprint string) (cassetteInteraction, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if missing := len(f.cassette.Interactions) - len(f.used); missing > 0 {
+		f.used = append(f.used, make([]bool, missing)...)
+	}
+	for i, interaction := range f.cassette.Interactions {
+		if !f.used[i] && interaction.Fingerprint == fingerprint {
+			f.used[i] = true
+			return interaction, true
+		}
+	}
+	return cassetteInteraction{}, false
+}
+
+func (f *cassetteFile) append(interaction cassetteInteraction) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.cassette.Interactions = append(f.cassette.Interactions, interaction)
+	_ = writeCassette(f.path, f.cassette)
+}
+
+type replayLLM struct {
+	file *cassetteFile
+}
+
+// newReplay serves the cassette at cfg.Model. Each request is answered by the
+// first unused interaction with the same fingerprint, so a session that asks
+// the same thing twice gets both recorded answers in order.
+func newReplay(cfg Config) (model.LLM, error) {
+	path := strings.TrimSpace(cfg.Model)
+	if path == "" {
+		return nil, fmt.Errorf("providers: replay cassette path is required")
+	}
+	file, err := openCassette(path, false)
+	if err != nil {
+		return nil, err
+	}
+	return &replayLLM{file: file}, nil
+}
+
+func (l *replayLLM) Name() string {
+	if name, _, _ := l.file.identity(); name != "" {
+		return name
+	}
+	return l.file.path
+}
+
+func (l *replayLLM) ProviderName() string {
+	if _, provider, _ := l.file.identity(); provider != "" {
+		return provider
+	}
+	return string(APIReplay)
+}
+
+func (l *replayLLM) ContextWindowTokens() int {
+	_, _, contextWindow := l.file.identity()
+	return contextWindow
+}
+
+func (l *replayLLM) Generate(ctx context.Context, req *model.Request) iter.Seq2[*model.StreamEvent, error] {
+	return func(yield func(*model.StreamEvent, error) bool) {
+		fingerprint := RequestFingerprint(req)
+		interaction, ok := l.file.take(fingerprint)
+		if !ok {
+			yield(nil, fmt.Errorf("providers: cassette %s has no unused recording for request %s", l.file.path, fingerprint))
+			return
+		}
+		for _, event := range interaction.Events {
+			if err := ctx.Err(); err != nil {
+				yield(nil, err)
+				return
+			}
+			if !yield(event.streamEvent(), nil) {
+				return
+			}
+		}
+		if err := interaction.err(); err != nil {
+			yield(nil, err)
+		}
+	}
+}
+
+type cassetteRecorder struct {
+	base model.LLM
+	file *cassetteFile
+}
+
+// NewCassetteRecorder wraps llm so every Generate call is appended to the
+// cassette at path, which is created or extended. Recorders for the same path
+// share one cassette, so models built within one process append to it in
+// turn. The result can be served again with the "replay/<path>" alias.
+func NewCassetteRecorder(llm model.LLM, path string) (model.LLM, error) {
+	path = strings.TrimSpace(path)
+	if llm == nil || path == "" {
+		return llm, nil
+	}
+	file, err := openCassette(path, true)
+	if err != nil {
+		return nil, err
+	}
+	file.mu.Lock()
+	file.cassette.Model = llm.Name()
+	file.cassette.Provider = model.LLMProviderName(llm)
+	if capped, ok := llm.(interface{ ContextWindowTokens() int }); ok {
+		file.cassette.ContextWindowTokens = capped.ContextWindowTokens()
+	}
+	file.mu.Unlock()
+	return &cassetteRecorder{base: llm, file: file}, nil
+}
+
+func (r *cassetteRecorder) Name() string {
+	return r.base.Name()
+}
+
+func (r *cassetteRecorder) ProviderName() string {
+	return model.LLMProviderName(r.base)
+}
+
+func (r *cassetteRecorder) ContextWindowTokens() int {
+	if capped, ok := r.base.(interface{ ContextWindowTokens() int }); ok {
+		return capped.ContextWindowTokens()
+	}
+	return 0
+}
+
+func (r *cassetteRecorder) Generate(ctx context.Context, req *model.Request) iter.Seq2[*model.StreamEvent, error] {
+	return func(yield func(*model.StreamEvent, error) bool) {
+		interaction := cassetteInteraction{Fingerprint: RequestFingerprint(req)}
+		if req != nil {
+			cp := *req
+			cp.Messages = model.CloneMessages(req.Messages)
+			interaction.Request = &cp
+		}
+		defer func() {
+			r.file.append(interaction)
+		}()
+		for event, err := range r.base.Generate(ctx, req) {
+			if err != nil {
+				interaction.Error = err.Error()
+				if model.IsContextOverflow(err) {
+					interaction.ErrorKind = cassetteErrorContextOverflow
+				}
+			} else if event != nil {
+				interaction.Events = append(interaction.Events, toCassetteEvent(event))
+			}
+			if !yield(event, err) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/OnslaughtSnail/caelis/kernel/model"
+)
+
+type cassetteStubLLM struct {
+	calls int
+}
+
+func (l *cassetteStubLLM) Name() string { return "stub-model" }
+
+func (l *cassetteStubLLM) ProviderName() string { return "stub" }
+
+func (l *cassetteStubLLM) ContextWindowTokens() int { return 32000 }
+
+func (l *cassetteStubLLM) Generate(_ context.Context, req *model.Request) iter.Seq2[*model.StreamEvent, error] {
+	return func(yield func(*model.StreamEvent, error) bool) {
+		l.calls++
+		last := req.Messages[len(req.Messages)-1].TextContent()
+		if last == "too long" {
+			yield(nil, &model.ContextOverflowError{Cause: errors.New("prompt is too long")})
+			return
+		}
+		if !yield(&model.StreamEvent{Type: model.StreamEventPartDelta, PartDelta: &model.PartDelta{Kind: model.PartKindText, TextDelta: "echo: "}}, nil) {
+			return
+		}
+		yield(model.StreamEventFromResponse(&model.Response{
+			Message:      model.NewTextMessage(model.RoleAssistant, "echo: "+last),
+			TurnComplete: true,
+			Usage:        model.Usage{PromptTokens: 3, CompletionTokens: 2, TotalTokens: 5},
+		}), nil)
+	}
+}
+
+func collectCassetteStream(llm model.LLM, text string) ([]*model.StreamEvent, error) {
+	var events []*model.StreamEvent
+	req := &model.Request{Messages: []model.Message{model.NewTextMessage(model.RoleUser, text)}, Stream: true}
+	for event, err := range llm.Generate(context.Background(), req) {
+		if err != nil {
+			return events, err
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+func TestCassetteRecordAndReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Sessions", "Echo.json")
+	stub := &cassetteStubLLM{}
+	recorder, err := NewCassetteRecorder(stub, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, text := range []string{"hello", "hello", "too long"} {
+		_, _ = collectCassetteStream(recorder, text)
+	}
+	if stub.calls != 3 {
+		t.Fatalf("expected 3 recorded calls, got %d", stub.calls)
+	}
+
+	replay, err := NewFactory().NewByAlias(ReplayAliasPrefix + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replay.Name() != "stub-model" || model.LLMProviderName(replay) != "stub" {
+		t.Fatalf("expected recorded model identity, got %q/%q", model.LLMProviderName(replay), replay.Name())
+	}
+	for range 2 {
+		events, err := collectCassetteStream(replay, "hello")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != 2 || events[0].PartDelta == nil || events[0].PartDelta.TextDelta != "echo: " {
+			t.Fatalf("expected replayed delta then final event, got %+v", events)
+		}
+		final := events[1]
+		if !final.TurnComplete || final.Response.Message.TextContent() != "echo: hello" || final.Response.Usage.TotalTokens != 5 {
+			t.Fatalf("unexpected replayed final event %+v", final.Response)
+		}
+	}
+	if _, err := collectCassetteStream(replay, "too long"); !model.IsContextOverflow(err) {
+		t.Fatalf("expected replayed context overflow, got %v", err)
+	}
+	if _, err := collectCassetteStream(replay, "hello"); err == nil || !strings.Contains(err.Error(), "no unused recording") {
+		t.Fatalf("expected exhausted recording error, got %v", err)
+	}
+	if _, err := collectCassetteStream(replay, "unknown"); err == nil {
+		t.Fatal("expected unrecorded request to fail")
+	}
+}
+
+func TestFactoryRecordsCassetteWhenEnvSet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "record.json")
+	t.Setenv(CassetteRecordEnvVar, path)
+	factory := NewFactory()
+	if err := factory.Register(Config{Alias: "stub", Provider: "ollama", API: APIOllama, Model: "llama3", BaseURL: "http://127.0.0.1:1"}); err != nil {
+		t.Fatal(err)
+	}
+	llm, err := factory.NewByAlias("stub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := llm.(*cassetteRecorder); !ok {
+		t.Fatalf("expected recorder wrapper, got %T", llm)
+	}
+	_, _ = collectCassetteStream(llm, "hi")
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("expected cassette to be written: %v", err)
+	}
+}
+
+func TestRequestFingerprintIgnoresStreamCacheKeyAndOrigin(t *testing.T) {
+	msg := model.NewTextMessage(model.RoleAssistant, "hi")
+	base := &model.Request{Messages: []model.Message{msg}}
+	msg.Origin = &model.MessageOrigin{Provider: "p", Model: "m"}
+	other := &model.Request{Messages: []model.Message{msg}, Stream: true, CacheKey: "s1"}
+	if RequestFingerprint(base) != RequestFingerprint(other) {
+		t.Fatal("expected equal fingerprints")
+	}
+	other.Reasoning.Effort = "high"
+	if RequestFingerprint(base) == RequestFingerprint(other) {
+		t.Fatal("expected reasoning to change the fingerprint")
+	}
+}
+
+func TestRequestFingerprintIgnoresEnvironmentValues(t *testing.T) {
+	envContext := func(date, cwd string) *model.Request {
+		return &model.Request{
+			Instructions: []model.Part{model.NewTextPart("<environment_context>\n  <cwd>" + cwd + "</cwd>\n  <shell>bash</shell>\n  <current_date>" + date + "</current_date>\n  <timezone>UTC</timezone>\n</environment_context>")},
+			Messages:     []model.Message{model.NewTextMessage(model.RoleUser, "hi")},
+		}
+	}
+	base := envContext("2026-01-02", "/home/a/repo")
+	if RequestFingerprint(base) != RequestFingerprint(envContext("2026-03-04", "/tmp/checkout")) {
+		t.Fatal("expected date and cwd to be ignored")
+	}
+	if base.Instructions[0].Text.Text != envContext("2026-01-02", "/home/a/repo").Instructions[0].Text.Text {
+		t.Fatal("expected the request to be left unchanged")
+	}
+	other := envContext("2026-01-02", "/home/a/repo")
+	other.Instructions[0].Text.Text = strings.Replace(other.Instructions[0].Text.Text, "bash", "zsh", 1)
+	if RequestFingerprint(base) == RequestFingerprint(other) {
+		t.Fatal("expected other environment lines to change the fingerprint")
+	}
+}
+
+func TestCassetteRecordersShareOnePath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shared.json")
+	first, err := NewCassetteRecorder(&cassetteStubLLM{}, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := NewCassetteRecorder(&cassetteStubLLM{}, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = collectCassetteStream(first, "one")
+	_, _ = collectCassetteStream(second, "two")
+	c, err := loadCassette(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Interactions) != 2 {
+		t.Fatalf("expected both recorders in one cassette, got %d interactions", len(c.Interactions))
+	}
+
+	factory := NewFactory()
+	replay, err := factory.NewByAlias(ReplayAliasPrefix + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := collectCassetteStream(replay, "one"); err != nil {
+		t.Fatal(err)
+	}
+	again, err := factory.NewByAlias(ReplayAliasPrefix + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := collectCassetteStream(again, "one"); err == nil {
+		t.Fatal("expected a new replay model to see the interaction as used")
+	}
+	if _, err := collectCassetteStream(again, "two"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -17,6 +17,15 @@ const (
 	APIMimo                APIType = "mimo"
 	APIVolcengineCoding    APIType = "volcengine_coding_plan"
 	APIOllama              APIType = "ollama"
+	// APIOpenAIResponses speaks the OpenAI Responses API instead of chat
+	// completions.
+	APIOpenAIResponses APIType = "openai_responses"
+	// APIAzureOpenAI speaks the Responses API of an Azure OpenAI resource,
+	// where the model is a deployment name, or chat completions when
+	// Config.Deployment is set.
+	APIAzureOpenAI APIType = "azure_openai"
+	// APIReplay serves a recorded cassette instead of calling a provider.
+	APIReplay APIType = "replay"
 )
 
 // AuthType defines model provider authentication strategy.
@@ -51,11 +60,16 @@ type OpenRouterConfig struct {
 
 // Config is a provider-agnostic model alias definition.
 type Config struct {
-	Alias                     string
-	Provider                  string
-	API                       APIType
-	Model                     string
-	BaseURL                   string
+	Alias    string
+	Provider string
+	API      APIType
+	Model    string
+	BaseURL  string
+	// APIVersion is the api-version query parameter of Azure OpenAI.
+	APIVersion string
+	// Deployment switches Azure OpenAI to the chat completions dialect of
+	// /openai/deployments/{deployment}/chat/completions.
+	Deployment                string
 	Headers                   map[string]string
 	Timeout                   time.Duration
 	MaxOutputTok              int
@@ -34,7 +34,11 @@ type RequestTraceRecord struct {
 	Tools        []ToolSpec      `json:"tools,omitempty"`
 	Output       *OutputSpec     `json:"output,omitempty"`
 	Reasoning    ReasoningConfig `json:"reasoning,omitempty"`
-	Time         time.Time       `json:"time"`
+	CacheKey     string          `json:"cache_key,omitempty"`
+	// Usage is the token usage of the final response, including prompt cache
+	// reads and writes. It is nil when the provider reported none.
+	Usage *Usage    `json:"usage,omitempty"`
+	Time  time.Time `json:"time"`
 }
 
 type requestTraceProvider interface {
@@ -112,19 +116,51 @@ func (l *requestTraceLLM) ContextWindowTokens() int {
 	return 0
 }
 
+// FallbackModels exposes the chain behind a traced fallback LLM with every
+// member traced, so callers that walk the chain keep recording requests.
+func (l *requestTraceLLM) FallbackModels() []LLM {
+	if l == nil || l.base == nil {
+		return nil
+	}
+	if _, ok := l.base.(fallbackChain); !ok {
+		return nil
+	}
+	models := FallbackModels(l.base)
+	out := make([]LLM, 0, len(models))
+	for _, one := range models {
+		out = append(out, WrapRequestTrace(one))
+	}
+	return out
+}
+
 func (l *requestTraceLLM) Generate(ctx context.Context, req *Request) iter.Seq2[*StreamEvent, error] {
 	if l == nil || l.base == nil {
 		return func(yield func(*StreamEvent, error) bool) {
 			yield(nil, fmt.Errorf("model: request trace wrapper has nil base llm"))
 		}
 	}
-	if RequestTracingEnabled() {
-		_ = appendRequestTrace(ctx, l, req)
+	if !RequestTracingEnabled() {
+		return l.base.Generate(ctx, req)
+	}
+	return func(yield func(*StreamEvent, error) bool) {
+		// The record is written once the stream ends so it can carry the
+		// usage the provider reported for this request.
+		var usage Usage
+		defer func() {
+			_ = appendRequestTrace(ctx, l, req, usage)
+		}()
+		for event, err := range l.base.Generate(ctx, req) {
+			if err == nil && event != nil && event.Response != nil && !event.Response.Usage.IsZero() {
+				usage = event.Response.Usage
+			}
+			if !yield(event, err) {
+				return
+			}
+		}
 	}
-	return l.base.Generate(ctx, req)
 }
 
-func appendRequestTrace(ctx context.Context, llm LLM, req *Request) error {
+func appendRequestTrace(ctx context.Context, llm LLM, req *Request, usage Usage) error {
 	info, ok := RequestTraceContextFromContext(ctx)
 	if !ok || strings.TrimSpace(info.Path) == "" {
 		return nil
@@ -141,8 +177,12 @@ func appendRequestTrace(ctx context.Context, llm LLM, req *Request) error {
 	if named, ok := llm.(requestTraceProvider); ok {
 		record.Provider = strings.TrimSpace(named.ProviderName())
 	}
+	if !usage.IsZero() {
+		record.Usage = &usage
+	}
 	if req != nil {
 		record.Reasoning = req.Reasoning
+		record.CacheKey = strings.TrimSpace(req.CacheKey)
 		if req.Output != nil {
 			output := *req.Output
 			if len(output.JSONSchema) > 0 {
@@ -77,3 +77,41 @@ func TestRequestTraceWrapperWritesOneOutboundRecord(t *testing.T) {
 		t.Fatalf("expected single request trace record, got extra=%+v err=%v", extra, err)
 	}
 }
+
+type traceUsageLLM struct{ traceTestLLM }
+
+func (l *traceUsageLLM) Generate(context.Context, *Request) iter.Seq2[*StreamEvent, error] {
+	return func(yield func(*StreamEvent, error) bool) {
+		yield(StreamEventFromResponse(&Response{
+			Message:      NewTextMessage(RoleAssistant, "ok"),
+			TurnComplete: true,
+			Usage:        Usage{PromptTokens: 120, CompletionTokens: 4, TotalTokens: 124, CacheReadTokens: 100, CacheWriteTokens: 15},
+		}), nil)
+	}
+}
+
+func TestRequestTraceWrapperRecordsCacheKeyAndUsage(t *testing.T) {
+	t.Setenv(RequestTraceEnvVar, "1")
+	path := filepath.Join(t.TempDir(), "requests.jsonl")
+	ctx := WithRequestTraceContext(context.Background(), RequestTraceContext{SessionID: "s-cache", Path: path})
+	llm := WrapRequestTrace(&traceUsageLLM{})
+	for _, err := range llm.Generate(ctx, &Request{Messages: []Message{NewTextMessage(RoleUser, "hello")}, CacheKey: "s-cache"}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var record RequestTraceRecord
+	if err := json.Unmarshal(raw, &record); err != nil {
+		t.Fatal(err)
+	}
+	if record.CacheKey != "s-cache" {
+		t.Fatalf("expected cache key in trace, got %+v", record)
+	}
+	if record.Usage == nil || record.Usage.CacheReadTokens != 100 || record.Usage.CacheWriteTokens != 15 {
+		t.Fatalf("expected cache usage in trace, got %+v", record.Usage)
+	}
+}
@@ -14,12 +14,15 @@ const defaultCommandExecutionToolName = "BASH"
 type CommandExecutionConfig struct {
 	Runtime  toolexec.Runtime
 	ToolName string
+	// Rules lets allow rules run host commands without an approval prompt.
+	Rules PermissionRules
 }
 
 type commandExecutionHook struct {
 	name    string
 	runtime toolexec.Runtime
 	tool    string
+	rules   PermissionRules
 }
 
 func RouteCommandExecution(cfg CommandExecutionConfig) Hook {
@@ -32,6 +35,7 @@ func RouteCommandExecution(cfg CommandExecutionConfig) Hook {
 		name:    name,
 		runtime: cfg.Runtime,
 		tool:    toolName,
+		rules:   cfg.Rules,
 	}
 }
 
@@ -87,7 +91,7 @@ func (h commandExecutionHook) BeforeTool(ctx context.Context, in ToolInput) (Too
 		decision = DecisionWithAnnotation(decision, DecisionAnnotationFallbackOnCommandNotFound)
 		in.Decision = decision
 	case toolexec.ExecutionRouteHost:
-		if routeDecision.Escalation != nil {
+		if routeDecision.Escalation != nil && !h.rules.Allows(in, h.runtime.FileSystem()) {
 			decision := DecisionWithRoute(Decision{
 				Effect: DecisionEffectRequireApproval,
 				Reason: strings.TrimSpace(routeDecision.Escalation.Message),
@@ -0,0 +1,383 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	toolexec "github.com/OnslaughtSnail/caelis/kernel/execenv"
+	"github.com/OnslaughtSnail/caelis/kernel/fsboundary"
+	toolfs "github.com/OnslaughtSnail/caelis/kernel/tool/builtin/filesystem"
+	"github.com/OnslaughtSnail/caelis/kernel/tool/capability"
+)
+
+// PermissionEffect is the outcome of one permission rule.
+type PermissionEffect string
+
+const (
+	PermissionAllow PermissionEffect = "allow"
+	PermissionAsk   PermissionEffect = "ask"
+	PermissionDeny  PermissionEffect = "deny"
+)
+
+// PermissionRule matches tool calls by tool name, capability operation, path
+// globs and command prefixes. Every field that is set must match. Relative
+// path globs are resolved against the workspace directory, and a glob naming a
+// directory also covers everything below it.
+type PermissionRule struct {
+	Effect    PermissionEffect     `json:"effect"`
+	Tool      string               `json:"tool,omitempty"`
+	Operation capability.Operation `json:"operation,omitempty"`
+	Paths     []string             `json:"paths,omitempty"`
+	Commands  []string             `json:"commands,omitempty"`
+	Reason    string               `json:"reason,omitempty"`
+	// Source is the file the rule was loaded from.
+	Source string `json:"-"`
+}
+
+// PermissionRules is an ordered rule set. When several rules match one call
+// the most restrictive effect wins; ties go to the earlier rule.
+type PermissionRules struct {
+	Rules []PermissionRule `json:"rules"`
+	// Dynamic supplies rules that change during a session, such as stored
+	// approval grants. They rank after Rules.
+	Dynamic func() []PermissionRule `json:"-"`
+}
+
+// LoadPermissionRules reads and concatenates rule files in order. Missing
+// files are skipped so workspace and user files are both optional.
+func LoadPermissionRules(paths ...string) (PermissionRules, error) {
+	var out PermissionRules
+	for _, one := range paths {
+		one = strings.TrimSpace(one)
+		if one == "" {
+			continue
+		}
+		raw, err := os.ReadFile(one)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return PermissionRules{}, fmt.Errorf("policy: read permission rules %q: %w", one, err)
+		}
+		var file PermissionRules
+		if err := json.Unmarshal(raw, &file); err != nil {
+			return PermissionRules{}, fmt.Errorf("policy: parse permission rules %q: %w", one, err)
+		}
+		for i, rule := range file.Rules {
+			rule, err := normalizePermissionRule(rule)
+			if err != nil {
+				return PermissionRules{}, fmt.Errorf("policy: permission rules %q: rule %d: %w", one, i+1, err)
+			}
+			rule.Source = one
+			out.Rules = append(out.Rules, rule)
+		}
+	}
+	return out, nil
+}
+
+func normalizePermissionRule(rule PermissionRule) (PermissionRule, error) {
+	rule.Effect = PermissionEffect(strings.ToLower(strings.TrimSpace(string(rule.Effect))))
+	switch rule.Effect {
+	case PermissionAllow, PermissionAsk, PermissionDeny:
+	default:
+		return PermissionRule{}, fmt.Errorf("unsupported effect %q, expected allow, ask or deny", rule.Effect)
+	}
+	rule.Tool = normalizeToolName(rule.Tool)
+	if _, err := path.Match(rule.Tool, ""); err != nil {
+		return PermissionRule{}, fmt.Errorf("invalid tool pattern %q: %w", rule.Tool, err)
+	}
+	rule.Operation = capability.Operation(strings.ToLower(strings.TrimSpace(string(rule.Operation))))
+	switch rule.Operation {
+	case "", capability.OperationFileRead, capability.OperationFileWrite, capability.OperationExec, capability.OperationNetwork:
+	default:
+		return PermissionRule{}, fmt.Errorf("unsupported operation %q", rule.Operation)
+	}
+	rule.Paths = trimNonEmpty(rule.Paths)
+	rule.Commands = trimNonEmpty(rule.Commands)
+	rule.Reason = strings.TrimSpace(rule.Reason)
+	if rule.Tool == "" && rule.Operation == "" && len(rule.Paths) == 0 && len(rule.Commands) == 0 {
+		return PermissionRule{}, fmt.Errorf("rule must set tool, operation, paths or commands")
+	}
+	return rule, nil
+}
+
+// Empty reports whether the rule set has no rules.
+func (r PermissionRules) Empty() bool {
+	return len(r.Rules) == 0 && r.Dynamic == nil
+}
+
+// AllowedTools returns the tools an allow rule grants unconditionally, for
+// use as SecurityBaselineConfig.AutoAllowTools.
+func (r PermissionRules) AllowedTools() []string {
+	var out []string
+	for _, rule := range r.Rules {
+		if rule.Effect != PermissionAllow || rule.Operation != "" || len(rule.Paths) > 0 || len(rule.Commands) > 0 {
+			continue
+		}
+		if rule.Tool == "" || strings.ContainsAny(rule.Tool, "*?[") {
+			continue
+		}
+		out = append(out, rule.Tool)
+	}
+	return out
+}
+
+// Match returns the deciding rule for one tool call. Relative paths are
+// resolved against the working directory of fs.
+func (r PermissionRules) Match(in ToolInput, fs fsboundary.PathContext) (PermissionRule, bool) {
+	var (
+		best  PermissionRule
+		found bool
+	)
+	rules := r.Rules
+	if r.Dynamic != nil {
+		rules = append(slices.Clone(rules), r.Dynamic()...)
+	}
+	for _, rule := range rules {
+		if !rule.matches(in, fs) {
+			continue
+		}
+		if !found || decisionStrictness(rule.Effect.decisionEffect()) > decisionStrictness(best.Effect.decisionEffect()) {
+			best = rule
+			found = true
+		}
+	}
+	return best, found
+}
+
+// Allows reports whether the deciding rule for one call is an allow rule.
+func (r PermissionRules) Allows(in ToolInput, fs fsboundary.PathContext) bool {
+	rule, ok := r.Match(in, fs)
+	return ok && rule.Effect == PermissionAllow
+}
+
+func (e PermissionEffect) decisionEffect() DecisionEffect {
+	switch e {
+	case PermissionDeny:
+		return DecisionEffectDeny
+	case PermissionAsk:
+		return DecisionEffectRequireApproval
+	default:
+		return DecisionEffectAllow
+	}
+}
+
+func (rule PermissionRule) matches(in ToolInput, fs fsboundary.PathContext) bool {
+	if rule.Tool != "" {
+		matched, _ := path.Match(rule.Tool, normalizeToolName(in.Call.Name))
+		if !matched {
+			return false
+		}
+	}
+	if rule.Operation != "" && !in.Capability.HasOperation(rule.Operation) {
+		return false
+	}
+	args := resolveToolInputArgs(in)
+	// Allow rules must cover every target so one matching path or command
+	// cannot smuggle in the rest; ask and deny rules trigger on any.
+	requireAll := rule.Effect == PermissionAllow
+	if len(rule.Paths) > 0 && !rule.matchesPaths(in.Call.Name, args, fs, requireAll) {
+		return false
+	}
+	if len(rule.Commands) > 0 && !rule.matchesCommand(args, requireAll) {
+		return false
+	}
+	return true
+}
+
+func (rule PermissionRule) matchesPaths(toolName string, args map[string]any, fs fsboundary.PathContext, requireAll bool) bool {
+	root := fsboundary.ResolveAbsPath(".", fs)
+	matchedAny := false
+	for _, raw := range writeTargetArgs(toolName, args) {
+		target := fsboundary.ResolveAbsPath(raw, fs)
+		if target == "" {
+			continue
+		}
+		matched := false
+		for _, pattern := range rule.Paths {
+			if permissionPathMatch(pattern, target, root, fs) {
+				matched = true
+				break
+			}
+		}
+		if matched {
+			matchedAny = true
+		} else if requireAll {
+			return false
+		}
+	}
+	return matchedAny
+}
+
+func permissionPathMatch(pattern, target, root string, fs fsboundary.PathContext) bool {
+	rel := target
+	if filepath.IsAbs(pattern) || strings.HasPrefix(pattern, "~/") {
+		pattern = fsboundary.ResolveAbsPath(pattern, fs)
+	} else {
+		var err error
+		rel, err = filepath.Rel(root, target)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return false
+		}
+	}
+	pattern = filepath.ToSlash(pattern)
+	rel = filepath.ToSlash(rel)
+	return toolfs.MatchPathGlob(pattern, rel) ||
+		toolfs.MatchPathGlob(strings.TrimSuffix(pattern, "/")+"/**", rel)
+}
+
+func (rule PermissionRule) matchesCommand(args map[string]any, requireAll bool) bool {
+	command, _ := args["command"].(string)
+	segments := toolexec.CommandSegments(command)
+	if requireAll {
+		// An allow rule covers only commands whose every part is visible
+		// to the prefix match.
+		plain, ok := toolexec.PlainCommandSegments(command)
+		if !ok {
+			return false
+		}
+		segments = plain
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	for _, tokens := range segments {
+		matched := false
+		for _, prefix := range rule.Commands {
+			if commandHasPrefix(tokens, strings.Fields(prefix)) {
+				matched = true
+				break
+			}
+		}
+		if matched && !requireAll {
+			return true
+		}
+		if !matched && requireAll {
+			return false
+		}
+	}
+	return requireAll
+}
+
+// commandHasPrefix compares whole words, so "go test" matches "go test ./..."
+// but not "go testdata".
+func commandHasPrefix(tokens, prefix []string) bool {
+	if len(prefix) == 0 || len(tokens) < len(prefix) {
+		return false
+	}
+	if filepath.Base(tokens[0]) != prefix[0] && tokens[0] != prefix[0] {
+		return false
+	}
+	for i := 1; i < len(prefix); i++ {
+		if tokens[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (rule PermissionRule) reason() string {
+	if rule.Reason != "" {
+		return rule.Reason
+	}
+	if rule.Source != "" {
+		return fmt.Sprintf("%s by permission rule in %s", rule.Effect, rule.Source)
+	}
+	return fmt.Sprintf("%s by permission rule", rule.Effect)
+}
+
+func trimNonEmpty(values []string) []string {
+	var out []string
+	for _, one := range values {
+		if one = strings.TrimSpace(one); one != "" {
+			out = append(out, one)
+		}
+	}
+	return out
+}
+
+// PermissionRulesConfig configures the permission rules hook.
+type PermissionRulesConfig struct {
+	Rules   PermissionRules
+	Runtime toolexec.Runtime
+}
+
+type permissionRulesHook struct {
+	rules   PermissionRules
+	runtime toolexec.Runtime
+}
+
+// EnforcePermissionRules returns a policy hook that denies calls matching a
+// deny rule and asks for authorization on calls matching an ask rule. Allow
+// rules are honored by RouteCommandExecution and the security baseline, since
+// a later hook cannot relax an earlier decision.
+func EnforcePermissionRules(cfg PermissionRulesConfig) Hook {
+	return permissionRulesHook{
+		rules:   cfg.Rules,
+		runtime: cfg.Runtime,
+	}
+}
+
+func (h permissionRulesHook) Name() string {
+	return "permission_rules"
+}
+
+func (h permissionRulesHook) BeforeModel(ctx context.Context, in ModelInput) (ModelInput, error) {
+	_ = ctx
+	return in, nil
+}
+
+func (h permissionRulesHook) BeforeTool(ctx context.Context, in ToolInput) (ToolInput, error) {
+	rule, ok := h.rules.Match(in, h.pathContext())
+	if !ok {
+		return in, nil
+	}
+	switch rule.Effect {
+	case PermissionDeny:
+		in.Decision = Decision{
+			Effect: DecisionEffectDeny,
+			Reason: rule.reason(),
+		}
+	case PermissionAsk:
+		authorizer, ok := ToolAuthorizerFromContext(ctx)
+		if !ok {
+			return ToolInput{}, &toolexec.ApprovalRequiredError{
+				Reason: fmt.Sprintf("tool %q requires authorization: %s", strings.TrimSpace(in.Call.Name), rule.reason()),
+			}
+		}
+		allowed, err := authorizer.AuthorizeTool(ctx, toolAuthorizationRequest(in.Call.Name, resolveToolInputArgs(in), rule.reason()))
+		if err != nil {
+			return ToolInput{}, err
+		}
+		if !allowed {
+			return ToolInput{}, &toolexec.ApprovalAbortedError{
+				Reason: fmt.Sprintf("tool %q authorization denied", strings.TrimSpace(in.Call.Name)),
+			}
+		}
+	}
+	return in, nil
+}
+
+func (h permissionRulesHook) AfterTool(ctx context.Context, out ToolOutput) (ToolOutput, error) {
+	_ = ctx
+	return out, nil
+}
+
+func (h permissionRulesHook) BeforeOutput(ctx context.Context, out Output) (Output, error) {
+	_ = ctx
+	return out, nil
+}
+
+func (h permissionRulesHook) pathContext() fsboundary.PathContext {
+	if h.runtime == nil || h.runtime.FileSystem() == nil {
+		return nil
+	}
+	return h.runtime.FileSystem()
+}
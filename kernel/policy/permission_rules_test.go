@@ -0,0 +1,202 @@
+package policy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	toolexec "github.com/OnslaughtSnail/caelis/kernel/execenv"
+	"github.com/OnslaughtSnail/caelis/kernel/model"
+	"github.com/OnslaughtSnail/caelis/kernel/tool/capability"
+)
+
+func writePermissionRulesFile(t *testing.T, dir string, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, "permissions.json")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func teamPermissionRules() PermissionRules {
+	return PermissionRules{Rules: []PermissionRule{
+		{Effect: PermissionDeny, Paths: []string{"deploy"}, Reason: "deploy/ is managed by CI"},
+		{Effect: PermissionAllow, Tool: "BASH", Commands: []string{"go test", "go vet"}},
+		{Effect: PermissionAsk, Tool: "BASH", Commands: []string{"git push"}},
+	}}
+}
+
+func TestLoadPermissionRules_ConcatenatesFilesInOrder(t *testing.T) {
+	workspace := writePermissionRulesFile(t, t.TempDir(), `{"rules":[{"effect":"deny","paths":["deploy/**"]}]}`)
+	user := writePermissionRulesFile(t, t.TempDir(), `{"rules":[{"effect":"ALLOW","tool":"bash","commands":["go test"]}]}`)
+	missing := filepath.Join(t.TempDir(), "missing.json")
+
+	rules, err := LoadPermissionRules(workspace, missing, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules.Rules) != 2 {
+		t.Fatalf("expected 2 rules, got %#v", rules.Rules)
+	}
+	if rules.Rules[0].Source != workspace || rules.Rules[1].Source != user {
+		t.Fatalf("unexpected rule sources: %q, %q", rules.Rules[0].Source, rules.Rules[1].Source)
+	}
+	if rules.Rules[1].Effect != PermissionAllow || rules.Rules[1].Tool != "BASH" {
+		t.Fatalf("expected normalized allow rule, got %#v", rules.Rules[1])
+	}
+}
+
+func TestLoadPermissionRules_RejectsInvalidRules(t *testing.T) {
+	for _, content := range []string{
+		`{"rules":[{"effect":"maybe","tool":"BASH"}]}`,
+		`{"rules":[{"effect":"deny"}]}`,
+		`{"rules":[{"effect":"deny","operation":"teleport"}]}`,
+		`{"rules":`,
+	} {
+		path := writePermissionRulesFile(t, t.TempDir(), content)
+		if _, err := LoadPermissionRules(path); err == nil {
+			t.Fatalf("expected error for %s", content)
+		}
+	}
+}
+
+func TestPermissionRules_MatchPathsAndCommands(t *testing.T) {
+	rules := teamPermissionRules()
+	cases := []struct {
+		name string
+		in   ToolInput
+		want PermissionEffect
+		ok   bool
+	}{
+		{
+			name: "write under deploy",
+			in:   ToolInput{Call: model.ToolCall{Name: "WRITE"}, Args: map[string]any{"path": "deploy/prod/app.yaml"}},
+			want: PermissionDeny, ok: true,
+		},
+		{
+			name: "write elsewhere",
+			in:   ToolInput{Call: model.ToolCall{Name: "WRITE"}, Args: map[string]any{"path": "deployment.md"}},
+		},
+		{
+			name: "go test",
+			in:   ToolInput{Call: model.ToolCall{Name: "BASH"}, Args: map[string]any{"command": "go test ./... 2>&1 && go vet ./..."}},
+			want: PermissionAllow, ok: true,
+		},
+		{
+			name: "allow skips leading assignments",
+			in:   ToolInput{Call: model.ToolCall{Name: "BASH"}, Args: map[string]any{"command": "GOFLAGS=-toolexec=./x go test ./..."}},
+		},
+		{
+			name: "allow must cover every segment",
+			in:   ToolInput{Call: model.ToolCall{Name: "BASH"}, Args: map[string]any{"command": "go test ./... && curl example.com"}},
+		},
+		{
+			name: "prefix compares whole words",
+			in:   ToolInput{Call: model.ToolCall{Name: "BASH"}, Args: map[string]any{"command": "go testdata"}},
+		},
+		{
+			name: "ask wins over allow",
+			in:   ToolInput{Call: model.ToolCall{Name: "BASH"}, Args: map[string]any{"command": "go test ./... && git push origin main"}},
+			want: PermissionAsk, ok: true,
+		},
+	}
+	for _, tc := range cases {
+		rule, ok := rules.Match(tc.in, nil)
+		if ok != tc.ok || (ok && rule.Effect != tc.want) {
+			t.Fatalf("%s: got rule=%#v ok=%v, want effect=%q ok=%v", tc.name, rule, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestPermissionRules_AllowRefusesShellSyntaxBeyondPlainChains(t *testing.T) {
+	rules := teamPermissionRules()
+	for _, command := range []string{
+		"go test ./... & rm -rf ~",
+		"go test $(rm -rf ~)",
+		"go test `curl example.com`",
+		"go test > ~/.bashrc",
+		"go test <(rm -rf ~)",
+		"go test\nrm -rf ~",
+	} {
+		in := ToolInput{Call: model.ToolCall{Name: "BASH"}, Args: map[string]any{"command": command}}
+		if rules.Allows(in, nil) {
+			t.Fatalf("expected the allow rule not to cover %q", command)
+		}
+	}
+}
+
+func TestPermissionRules_MatchOperation(t *testing.T) {
+	rules := PermissionRules{Rules: []PermissionRule{{Effect: PermissionDeny, Operation: capability.OperationNetwork}}}
+	in := ToolInput{
+		Call:       model.ToolCall{Name: "mcp__fetch"},
+		Capability: capability.Capability{Operations: []capability.Operation{capability.OperationNetwork}},
+	}
+	if _, ok := rules.Match(in, nil); !ok {
+		t.Fatal("expected network operation to match")
+	}
+	if _, ok := rules.Match(ToolInput{Call: model.ToolCall{Name: "READ"}}, nil); ok {
+		t.Fatal("expected tool without network operation not to match")
+	}
+}
+
+func TestEnforcePermissionRules_DenyAndAsk(t *testing.T) {
+	hook := EnforcePermissionRules(PermissionRulesConfig{Rules: teamPermissionRules()})
+
+	out, err := hook.BeforeTool(context.Background(), ToolInput{
+		Call: model.ToolCall{Name: "PATCH"},
+		Args: map[string]any{"path": "deploy/main.tf"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Decision.Effect != DecisionEffectDeny || out.Decision.Reason != "deploy/ is managed by CI" {
+		t.Fatalf("expected deny with rule reason, got %#v", out.Decision)
+	}
+
+	push := ToolInput{Call: model.ToolCall{Name: "BASH"}, Args: map[string]any{"command": "git push"}}
+	if _, err := hook.BeforeTool(context.Background(), push); !toolexec.IsErrorCode(err, toolexec.ErrorCodeApprovalRequired) {
+		t.Fatalf("expected approval required without authorizer, got %v", err)
+	}
+	authorizer := &stubToolAuthorizer{allow: true}
+	ctx := WithToolAuthorizer(context.Background(), authorizer)
+	if _, err := hook.BeforeTool(ctx, push); err != nil {
+		t.Fatal(err)
+	}
+	if authorizer.calls != 1 || !strings.Contains(authorizer.last.Reason, "ask by permission rule") {
+		t.Fatalf("expected one authorization with rule reason, got calls=%d req=%#v", authorizer.calls, authorizer.last)
+	}
+	authorizer.allow = false
+	if _, err := hook.BeforeTool(ctx, push); !toolexec.IsApprovalAborted(err) {
+		t.Fatalf("expected approval aborted, got %v", err)
+	}
+}
+
+func TestRouteCommandExecution_AllowRuleSkipsHostApproval(t *testing.T) {
+	rt, err := toolexec.New(toolexec.Config{
+		PermissionMode: toolexec.PermissionModeDefault,
+		SandboxType:    testSandboxTypeForPolicy(),
+		SandboxRunner:  noopCommandRunner{},
+		HostRunner:     noopCommandRunner{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook := RouteCommandExecution(CommandExecutionConfig{Runtime: rt, Rules: teamPermissionRules()})
+	in, err := hook.BeforeTool(context.Background(), ToolInput{
+		Call: model.ToolCall{Name: "BASH"},
+		Args: map[string]any{"command": "go test ./...", "require_escalated": true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	in.Decision = NormalizeDecision(in.Decision)
+	if in.Decision.Effect != DecisionEffectAllow {
+		t.Fatalf("expected allow decision, got %q", in.Decision.Effect)
+	}
+	if !DecisionHasAnnotation(in.Decision, DecisionAnnotationHostExecutionWithoutApproval) {
+		t.Fatal("expected host execution without approval annotation")
+	}
+}
@@ -9,11 +9,13 @@ import (
 	"strings"
 
 	"github.com/OnslaughtSnail/caelis/kernel/session"
+	toolfs "github.com/OnslaughtSnail/caelis/kernel/tool/builtin/filesystem"
 	"github.com/OnslaughtSnail/caelis/kernel/tool/capability"
 )
 
 const (
 	defaultReadToolName               = "READ"
+	taskToolName                      = "TASK"
 	readBeforeWriteStateKey           = "policy.read_before_write.read_paths"
 	readBeforeWriteIndexReadyStateKey = "policy.read_before_write.index_ready"
 	readBeforeWriteSafeWriteStateKey  = "policy.read_before_write.safe_write_paths"
@@ -53,33 +55,41 @@ func (h readBeforeWriteHook) BeforeTool(ctx context.Context, in ToolInput) (Tool
 	if !in.Capability.HasOperation(capability.OperationFileWrite) {
 		return in, nil
 	}
+	if strings.EqualFold(strings.TrimSpace(in.Call.Name), taskToolName) {
+		// TASK apply merges a reviewed worktree patch; git refuses it when
+		// the working tree no longer matches, so there is nothing to read.
+		return in, nil
+	}
 	args := resolveToolInputArgs(in)
-	targetPath := pathArgFromToolCall(args)
-	if targetPath == "" {
+	targetPaths := writeTargetPathsFromToolCall(in.Call.Name, args)
+	if len(targetPaths) == 0 {
 		in.Decision = Decision{
 			Effect: DecisionEffectDeny,
 			Reason: fmt.Sprintf("write tool %q requires path arg", in.Call.Name),
 		}
 		return in, nil
 	}
-	protectedTarget, statErr := requiresPriorRead(targetPath)
-	if statErr != nil {
-		// Let the tool itself surface filesystem errors instead of hard-stopping policy chain.
-		return in, nil
-	}
-	if !protectedTarget {
-		return in, nil
-	}
-	if hasReadEvidence(ctx, h.readToolName, targetPath) {
-		return in, nil
-	}
-	if hasSafeWriteEvidence(ctx, targetPath) {
+	for _, targetPath := range targetPaths {
+		protectedTarget, statErr := requiresPriorRead(targetPath)
+		if statErr != nil {
+			// Let the tool itself surface filesystem errors instead of hard-stopping policy chain.
+			continue
+		}
+		if !protectedTarget {
+			continue
+		}
+		if hasReadEvidence(ctx, h.readToolName, targetPath) {
+			continue
+		}
+		if hasSafeWriteEvidence(ctx, targetPath) {
+			continue
+		}
+		in.Decision = Decision{
+			Effect: DecisionEffectDeny,
+			Reason: fmt.Sprintf("write tool %q requires prior READ of %q", in.Call.Name, targetPath),
+		}
 		return in, nil
 	}
-	in.Decision = Decision{
-		Effect: DecisionEffectDeny,
-		Reason: fmt.Sprintf("write tool %q requires prior READ of %q", in.Call.Name, targetPath),
-	}
 	return in, nil
 }
 
@@ -97,15 +107,12 @@ func (h readBeforeWriteHook) AfterTool(ctx context.Context, out ToolOutput) (Too
 		_ = persistReadEvidence(ctx, readPath)
 		return out, nil
 	}
-	if out.Err != nil || out.Result == nil || !isSafeWriteBootstrapResult(callName, out.Result) {
+	if out.Err != nil || out.Result == nil {
 		return out, nil
 	}
-	writePathRaw, _ := out.Result["path"].(string)
-	writePath := normalizePathForComparison(writePathRaw)
-	if writePath == "" {
-		return out, nil
+	for _, writePath := range safeWriteResultPaths(callName, out.Result) {
+		_ = persistSafeWriteEvidence(ctx, writePath)
 	}
-	_ = persistSafeWriteEvidence(ctx, writePath)
 	return out, nil
 }
 
@@ -114,6 +121,24 @@ func (h readBeforeWriteHook) BeforeOutput(ctx context.Context, out Output) (Outp
 	return out, nil
 }
 
+// writeTargetPathsFromToolCall returns every normalized path a file-write
+// call targets. EDIT may touch several files; other tools use "path".
+func writeTargetPathsFromToolCall(toolName string, args map[string]any) []string {
+	if !strings.EqualFold(strings.TrimSpace(toolName), toolfs.EditToolName) {
+		if targetPath := pathArgFromToolCall(args); targetPath != "" {
+			return []string{targetPath}
+		}
+		return nil
+	}
+	var out []string
+	for _, raw := range toolfs.EditTargetPaths(args) {
+		if targetPath := normalizePathForComparison(raw); targetPath != "" {
+			out = append(out, targetPath)
+		}
+	}
+	return out
+}
+
 func pathArgFromToolCall(args map[string]any) string {
 	if args == nil {
 		return ""
@@ -197,11 +222,10 @@ func hasSafeWriteEvidenceInEvents(ctx context.Context, targetPath string) bool {
 			continue
 		}
 		resp := ev.Message.ToolResponse()
-		if resp == nil || !isSafeWriteBootstrapResult(strings.ToUpper(strings.TrimSpace(resp.Name)), resp.Result) {
+		if resp == nil {
 			continue
 		}
-		writePathRaw, _ := resp.Result["path"].(string)
-		if normalizePathForComparison(writePathRaw) == targetPath {
+		if slices.Contains(safeWriteResultPaths(resp.Name, resp.Result), targetPath) {
 			return true
 		}
 	}
@@ -366,19 +390,16 @@ func collectSafeWritePaths(events []*session.Event) []string {
 			continue
 		}
 		resp := ev.Message.ToolResponse()
-		if resp == nil || !isSafeWriteBootstrapResult(strings.ToUpper(strings.TrimSpace(resp.Name)), resp.Result) {
-			continue
-		}
-		writePathRaw, _ := resp.Result["path"].(string)
-		writePath := normalizePathForComparison(writePathRaw)
-		if writePath == "" {
+		if resp == nil {
 			continue
 		}
-		if _, exists := seen[writePath]; exists {
-			continue
+		for _, writePath := range safeWriteResultPaths(resp.Name, resp.Result) {
+			if _, exists := seen[writePath]; exists {
+				continue
+			}
+			seen[writePath] = struct{}{}
+			out = append(out, writePath)
 		}
-		seen[writePath] = struct{}{}
-		out = append(out, writePath)
 	}
 	slices.Sort(out)
 	return out
@@ -468,13 +489,45 @@ func persistSafeWritePathIndex(ctx context.Context, paths []string) error {
 	return stateCtx.StateStore.ReplaceState(ctx, stateCtx.Session, values)
 }
 
-func isSafeWriteBootstrapResult(toolName string, result map[string]any) bool {
+// safeWriteResultPaths returns the normalized paths a successful write
+// result created or filled from empty. EDIT reports them per file.
+func safeWriteResultPaths(toolName string, result map[string]any) []string {
 	if len(result) == 0 {
-		return false
+		return nil
 	}
+	var candidates []map[string]any
 	switch strings.ToUpper(strings.TrimSpace(toolName)) {
 	case "WRITE", "PATCH":
+		candidates = []map[string]any{result}
+	case toolfs.EditToolName:
+		switch files := result["files"].(type) {
+		case []map[string]any:
+			candidates = files
+		case []any:
+			for _, one := range files {
+				if file, ok := one.(map[string]any); ok {
+					candidates = append(candidates, file)
+				}
+			}
+		}
 	default:
+		return nil
+	}
+	var out []string
+	for _, candidate := range candidates {
+		if !isSafeWriteBootstrapResult(candidate) {
+			continue
+		}
+		writePathRaw, _ := candidate["path"].(string)
+		if writePath := normalizePathForComparison(writePathRaw); writePath != "" {
+			out = append(out, writePath)
+		}
+	}
+	return out
+}
+
+func isSafeWriteBootstrapResult(result map[string]any) bool {
+	if len(result) == 0 {
 		return false
 	}
 	created, _ := result["created"].(bool)
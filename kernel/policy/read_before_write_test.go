@@ -4,6 +4,7 @@ import (
 	"context"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -443,3 +444,45 @@ func TestRequireReadBeforeWrite_BackfillsReadIndexFromPersistedEvents(t *testing
 		t.Fatalf("expected backfilled read path index, got %#v", values)
 	}
 }
+
+func TestRequireReadBeforeWrite_ChecksEveryEditTarget(t *testing.T) {
+	hook := RequireReadBeforeWrite(ReadBeforeWriteConfig{})
+	dir := t.TempDir()
+	readTarget := filepath.Join(dir, "a.txt")
+	unreadTarget := filepath.Join(dir, "b.txt")
+	for _, path := range []string{readTarget, unreadTarget} {
+		if err := os.WriteFile(path, []byte("non-empty"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx := policyHistoryCtx{
+		Context: context.Background(),
+		events: []*session.Event{{
+			ID:   "read_1",
+			Time: time.Now(),
+			Message: model.MessageFromToolResponse(&model.ToolResponse{
+				ID:     "call_read_1",
+				Name:   "READ",
+				Result: map[string]any{"path": readTarget},
+			}),
+		}},
+	}
+	out, err := hook.BeforeTool(ctx, ToolInput{
+		Call: model.ToolCall{Name: "EDIT", Args: "{}"},
+		Args: map[string]any{"edits": []any{
+			map[string]any{"path": readTarget, "old": "non", "new": "now"},
+			map[string]any{"path": unreadTarget, "old": "non", "new": "now"},
+		}},
+		Capability: capability.Capability{
+			Operations: []capability.Operation{capability.OperationFileWrite},
+			Risk:       capability.RiskMedium,
+		},
+	})
+	if err != nil {
+		t.Fatalf("expected no hard error, got %v", err)
+	}
+	out.Decision = NormalizeDecision(out.Decision)
+	if out.Decision.Effect != DecisionEffectDeny || !strings.Contains(out.Decision.Reason, unreadTarget) {
+		t.Fatalf("expected deny naming the unread EDIT target, got %+v", out.Decision)
+	}
+}
@@ -68,7 +68,7 @@ func NewSecurityBaseline(cfg SecurityBaselineConfig) Hook {
 
 	defaultAutoAllow := []string{
 		"READ", "LIST", "GLOB", "SEARCH",
-		"WRITE", "PATCH",
+		"WRITE", "PATCH", "EDIT",
 		"PLAN",
 		"SPAWN", "TASK",
 		"BASH", // BASH host escalation is gated by execution runtime approval flow.
@@ -102,6 +102,10 @@ func (h securityBaselineHook) BeforeModel(ctx context.Context, in ModelInput) (M
 }
 
 func (h securityBaselineHook) BeforeTool(ctx context.Context, in ToolInput) (ToolInput, error) {
+	if NormalizeDecision(in.Decision).Effect == DecisionEffectDeny {
+		// Already denied; prompting would be pointless.
+		return in, nil
+	}
 	needApproval, reason := h.requiresToolAuthorization(in.Call.Name)
 	if !needApproval {
 		return in, nil
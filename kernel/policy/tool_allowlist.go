@@ -0,0 +1,81 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/OnslaughtSnail/caelis/kernel/model"
+)
+
+// ToolAllowlistConfig configures the tool allowlist hook.
+type ToolAllowlistConfig struct {
+	// Allowed lists the tool names the model may see and call. Names are
+	// matched case-insensitively.
+	Allowed []string
+}
+
+type toolAllowlistHook struct {
+	name    string
+	allowed map[string]struct{}
+}
+
+// ToolAllowlist returns a policy hook that hides every tool outside the
+// allowlist from the model and denies calls to them. It narrows the mandatory
+// core tools as well as session tools.
+func ToolAllowlist(cfg ToolAllowlistConfig) Hook {
+	allowed := make(map[string]struct{}, len(cfg.Allowed))
+	for _, name := range cfg.Allowed {
+		if name = strings.ToUpper(strings.TrimSpace(name)); name != "" {
+			allowed[name] = struct{}{}
+		}
+	}
+	return toolAllowlistHook{
+		name:    "tool_allowlist",
+		allowed: allowed,
+	}
+}
+
+func (h toolAllowlistHook) Name() string {
+	return h.name
+}
+
+func (h toolAllowlistHook) BeforeModel(ctx context.Context, in ModelInput) (ModelInput, error) {
+	_ = ctx
+	tools := make([]model.ToolSpec, 0, len(in.Tools))
+	for _, spec := range in.Tools {
+		if spec.Kind != model.ToolSpecKindFunction || spec.Function == nil || !h.allows(spec.Function.Name) {
+			continue
+		}
+		tools = append(tools, spec)
+	}
+	in.Tools = tools
+	return in, nil
+}
+
+func (h toolAllowlistHook) BeforeTool(ctx context.Context, in ToolInput) (ToolInput, error) {
+	_ = ctx
+	if h.allows(in.Call.Name) {
+		return in, nil
+	}
+	in.Decision = Decision{
+		Effect: DecisionEffectDeny,
+		Reason: fmt.Sprintf("tool %q is not available in this session", in.Call.Name),
+	}
+	return in, nil
+}
+
+func (h toolAllowlistHook) AfterTool(ctx context.Context, out ToolOutput) (ToolOutput, error) {
+	_ = ctx
+	return out, nil
+}
+
+func (h toolAllowlistHook) BeforeOutput(ctx context.Context, out Output) (Output, error) {
+	_ = ctx
+	return out, nil
+}
+
+func (h toolAllowlistHook) allows(name string) bool {
+	_, ok := h.allowed[strings.ToUpper(strings.TrimSpace(name))]
+	return ok
+}
@@ -0,0 +1,39 @@
+package policy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/OnslaughtSnail/caelis/kernel/model"
+)
+
+func TestToolAllowlist_HidesAndDeniesToolsOutsideAllowlist(t *testing.T) {
+	hook := ToolAllowlist(ToolAllowlistConfig{Allowed: []string{"read", " SEARCH "}})
+
+	in, err := hook.BeforeModel(context.Background(), ModelInput{Tools: []model.ToolSpec{
+		model.NewFunctionToolSpec("READ", "", nil),
+		model.NewFunctionToolSpec("BASH", "", nil),
+		model.NewFunctionToolSpec("SEARCH", "", nil),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(in.Tools) != 2 || in.Tools[0].Function.Name != "READ" || in.Tools[1].Function.Name != "SEARCH" {
+		t.Fatalf("expected READ and SEARCH to stay visible, got %+v", in.Tools)
+	}
+
+	allowed, err := hook.BeforeTool(context.Background(), ToolInput{Call: model.ToolCall{Name: "READ"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if NormalizeDecision(allowed.Decision).Effect != DecisionEffectAllow {
+		t.Fatalf("expected READ to be allowed, got %+v", allowed.Decision)
+	}
+	denied, err := hook.BeforeTool(context.Background(), ToolInput{Call: model.ToolCall{Name: "BASH"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if denied.Decision.Effect != DecisionEffectDeny {
+		t.Fatalf("expected BASH to be denied, got %+v", denied.Decision)
+	}
+}
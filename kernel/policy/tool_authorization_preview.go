@@ -19,7 +19,11 @@ func externalWriteAuthorizationRequest(toolName string, args map[string]any, run
 		Path:       strings.TrimSpace(targetPath),
 	}
 	if preview, err := toolfs.BuildMutationPreview(runtime, toolName, args); err == nil {
-		req.Path = strings.TrimSpace(preview.Path)
+		// A multi-file preview leads with its first file, which may be inside
+		// the workspace; keep the external target as the approval path.
+		if len(preview.Files) <= 1 {
+			req.Path = strings.TrimSpace(preview.Path)
+		}
 		req.Preview = strings.TrimSpace(preview.Preview)
 	}
 	req.ScopeKey = approvalScopeKeyForPath(req.Path)
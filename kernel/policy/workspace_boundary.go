@@ -3,9 +3,11 @@ package policy
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	toolexec "github.com/OnslaughtSnail/caelis/kernel/execenv"
 	"github.com/OnslaughtSnail/caelis/kernel/fsboundary"
+	toolfs "github.com/OnslaughtSnail/caelis/kernel/tool/builtin/filesystem"
 	"github.com/OnslaughtSnail/caelis/kernel/tool/capability"
 )
 
@@ -41,7 +43,7 @@ func (h workspaceBoundaryHook) BeforeModel(ctx context.Context, in ModelInput) (
 
 func (h workspaceBoundaryHook) BeforeTool(ctx context.Context, in ToolInput) (ToolInput, error) {
 	_ = ctx
-	if h.runtime == nil {
+	if h.runtime == nil || NormalizeDecision(in.Decision).Effect == DecisionEffectDeny {
 		return in, nil
 	}
 
@@ -69,17 +71,22 @@ func (h workspaceBoundaryHook) BeforeTool(ctx context.Context, in ToolInput) (To
 	}
 
 	args := resolveToolInputArgs(in)
-	rawTargetPath, _ := args["path"].(string)
-	targetPath := fsboundary.ResolveAbsPath(rawTargetPath, h.runtime.FileSystem())
-	if targetPath == "" {
-		// No path arg — let the tool itself handle the missing arg error.
-		return in, nil
-	}
-
-	if fsboundary.IsWithinReadOnlySubpaths(targetPath, policy.ReadOnlySubpaths, h.runtime.FileSystem()) {
-		return ToolInput{}, fmt.Errorf("tool %q targets read-only path %q under current sandbox policy", in.Call.Name, targetPath)
+	targetPath := ""
+	for _, rawTargetPath := range writeTargetArgs(in.Call.Name, args) {
+		resolved := fsboundary.ResolveAbsPath(rawTargetPath, h.runtime.FileSystem())
+		if resolved == "" {
+			continue
+		}
+		if fsboundary.IsWithinReadOnlySubpaths(resolved, policy.ReadOnlySubpaths, h.runtime.FileSystem()) {
+			return ToolInput{}, fmt.Errorf("tool %q targets read-only path %q under current sandbox policy", in.Call.Name, resolved)
+		}
+		if targetPath == "" && !isWithinWritableRoots(resolved, policy.WritableRoots, h.runtime.FileSystem()) {
+			targetPath = resolved
+		}
 	}
-	if isWithinWritableRoots(targetPath, policy.WritableRoots, h.runtime.FileSystem()) {
+	if targetPath == "" {
+		// Every target is writable, or no path arg was given and the tool
+		// itself reports the missing arg.
 		return in, nil
 	}
 
@@ -113,6 +120,16 @@ func (h workspaceBoundaryHook) BeforeOutput(ctx context.Context, out Output) (Ou
 	return out, nil
 }
 
+// writeTargetArgs returns the raw target paths of a file-write call. EDIT
+// may name several files; other write tools carry a single "path".
+func writeTargetArgs(toolName string, args map[string]any) []string {
+	if strings.EqualFold(strings.TrimSpace(toolName), toolfs.EditToolName) {
+		return toolfs.EditTargetPaths(args)
+	}
+	rawTargetPath, _ := args["path"].(string)
+	return []string{rawTargetPath}
+}
+
 // isWithinWritableRoots checks whether the target path falls within any of the
 // declared writable roots. Roots that are relative are resolved against the
 // filesystem working directory.
@@ -58,6 +58,7 @@ type RunTurnRequest struct {
 	Agent               agent.Agent
 	Model               model.LLM
 	ContextWindowTokens int
+	Budget              task.Budget
 }
 
 type RunTurnResult struct {
@@ -155,6 +156,7 @@ func (s *Service) RunTurn(ctx context.Context, req RunTurnRequest) (RunTurnResul
 		Policies:              append([]policy.Hook(nil), s.policies...),
 		ContextWindowTokens:   req.ContextWindowTokens,
 		SubagentRunnerFactory: s.subagentRunnerFactory,
+		Budget:                req.Budget,
 	})
 	if err != nil {
 		return RunTurnResult{}, err
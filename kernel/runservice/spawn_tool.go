@@ -25,7 +25,7 @@ func NewSelfSpawnTool(defaultAgent string) (tool.Tool, error) {
 func (t *selfSpawnTool) Name() string { return tool.SpawnToolName }
 
 func (t *selfSpawnTool) Description() string {
-	return "Start a new ACP child session for bounded delegated work. agent accepts self or any configured ACP agent id such as codex, copilot, or gemini. If the child is still running when yield_time_ms elapses, the result includes task_id; continue with TASK wait, and once that child session reaches completed you can use TASK write to start another turn in the same child session."
+	return "Start a new ACP child session for bounded delegated work. agent accepts self, a subagent profile id such as explorer or reviewer, or any configured ACP agent id such as codex, copilot, or gemini. If the child is still running when yield_time_ms elapses, the result includes task_id; continue with TASK wait, and once that child session reaches completed you can use TASK write to start another turn in the same child session. Set isolation=worktree when children may edit files in parallel: the child then works in its own git worktree and branch, and its changes stay there until you review them with TASK diff and merge them with TASK apply or drop them with TASK discard. To fan out, pass tasks instead of prompt: each entry starts its own child, at most max_concurrency run at once and the rest queue, and the result lists every child with its task_id; use TASK wait_all or wait_any with those task_ids for children still running. max_steps, max_tokens and max_tool_calls cap each child's run; a child that reaches a cap stops with state terminated and error_reason budget_exhausted."
 }
 
 func (t *selfSpawnTool) Declaration() model.ToolDefinition {
@@ -37,18 +37,52 @@ func (t *selfSpawnTool) Declaration() model.ToolDefinition {
 			"properties": map[string]any{
 				"agent": map[string]any{
 					"type":        "string",
-					"description": "Optional target agent id. Use self or any configured ACP agent id such as codex, copilot, or gemini. Defaults to the configured default agent, then self.",
+					"description": "Optional target agent id. Use self, a subagent profile id, or any configured ACP agent id such as codex, copilot, or gemini. Defaults to the configured default agent, then self.",
 				},
 				"prompt": map[string]any{
 					"type":        "string",
-					"description": "Task prompt for the child session.",
+					"description": "Task prompt for the child session. Required unless tasks is set.",
+				},
+				"tasks": map[string]any{
+					"type":        "array",
+					"description": "Optional batch of children to start instead of a single prompt. Each entry takes its own prompt and optional agent and isolation.",
+					"items": map[string]any{
+						"type": "object",
+						"properties": map[string]any{
+							"agent":     map[string]any{"type": "string"},
+							"prompt":    map[string]any{"type": "string"},
+							"isolation": map[string]any{"type": "string", "enum": []string{"none", "worktree"}},
+						},
+						"required":             []string{"prompt"},
+						"additionalProperties": false,
+					},
+				},
+				"max_concurrency": map[string]any{
+					"type":        "integer",
+					"description": "For tasks: how many children may run at once. Defaults to 4.",
+				},
+				"isolation": map[string]any{
+					"type":        "string",
+					"enum":        []string{"none", "worktree"},
+					"description": "Optional. none (default) runs the child in the parent's working tree; worktree runs it in a temporary git worktree whose changes you review and merge through TASK.",
+				},
+				"max_steps": map[string]any{
+					"type":        "integer",
+					"description": "Optional cap on model calls in each child's run.",
+				},
+				"max_tokens": map[string]any{
+					"type":        "integer",
+					"description": "Optional cap on the total model tokens each child's run may use.",
+				},
+				"max_tool_calls": map[string]any{
+					"type":        "integer",
+					"description": "Optional cap on tool calls in each child's run.",
 				},
 				"yield_time_ms": map[string]any{
 					"type":        "integer",
 					"description": "Optional per-call wait for this SPAWN before returning in milliseconds. Defaults to 30000. If the child is still running when this wait expires, the result includes task_id and you continue with TASK wait.",
 				},
 			},
-			"required":             []string{"prompt"},
 			"additionalProperties": false,
 		},
 	}
@@ -63,17 +97,6 @@ func (t *selfSpawnTool) Run(ctx context.Context, args map[string]any) (map[strin
 	if !ok || manager == nil {
 		return nil, fmt.Errorf("tool: task manager is unavailable")
 	}
-	promptText := strings.TrimSpace(asStringArg(args, "prompt"))
-	if promptText == "" {
-		return nil, fmt.Errorf("tool: arg %q is required", "prompt")
-	}
-	agentName := strings.TrimSpace(asStringArg(args, "agent"))
-	if agentName == "" {
-		agentName = strings.TrimSpace(t.defaultAgent)
-	}
-	if agentName == "" {
-		agentName = "self"
-	}
 	for _, legacy := range []string{"session", "session_id", "new_session", "yield_seconds", "timeout_seconds", "timeout_ms"} {
 		if _, ok := args[legacy]; ok {
 			return nil, fmt.Errorf("tool: arg %q is no longer supported", legacy)
@@ -85,18 +108,96 @@ func (t *selfSpawnTool) Run(ctx context.Context, args map[string]any) (map[strin
 	if !yieldSpecified || yieldMS <= 0 {
 		yieldMS = int(defaultSpawnYield / time.Millisecond)
 	}
-	snapshot, err := manager.StartSpawn(ctx, task.SpawnStartRequest{
-		Agent:  agentName,
-		Prompt: promptText,
-		Yield:  time.Duration(yieldMS) * time.Millisecond,
-		Kind:   task.KindSpawn,
-	})
+	if rawTasks, ok := args["tasks"]; ok && rawTasks != nil {
+		return t.runBatch(ctx, manager, args, time.Duration(yieldMS)*time.Millisecond)
+	}
+	req, err := t.spawnRequest(args)
+	if err != nil {
+		return nil, err
+	}
+	req.Budget = spawnBudget(args)
+	req.Yield = time.Duration(yieldMS) * time.Millisecond
+	snapshot, err := manager.StartSpawn(ctx, req)
 	if err != nil {
 		return nil, err
 	}
 	return tool.AppendTaskSnapshotEvents(tool.SnapshotResultMap(snapshot), snapshot), nil
 }
 
+func (t *selfSpawnTool) runBatch(ctx context.Context, manager task.Manager, args map[string]any, yield time.Duration) (map[string]any, error) {
+	if strings.TrimSpace(asStringArg(args, "prompt")) != "" {
+		return nil, fmt.Errorf("tool: args %q and %q cannot be combined", "prompt", "tasks")
+	}
+	batches, ok := manager.(task.BatchManager)
+	if !ok {
+		return nil, fmt.Errorf("tool: batch SPAWN is unavailable")
+	}
+	rawTasks, ok := args["tasks"].([]any)
+	if !ok || len(rawTasks) == 0 {
+		return nil, fmt.Errorf("tool: arg %q must be a non-empty array", "tasks")
+	}
+	budget := spawnBudget(args)
+	children := make([]task.SpawnStartRequest, 0, len(rawTasks))
+	for i, raw := range rawTasks {
+		entry, ok := raw.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("tool: tasks[%d] must be an object", i)
+		}
+		req, err := t.spawnRequest(entry)
+		if err != nil {
+			return nil, fmt.Errorf("tool: tasks[%d]: %w", i, err)
+		}
+		req.Budget = budget
+		children = append(children, req)
+	}
+	snapshots, err := batches.StartSpawnBatch(ctx, task.SpawnBatchRequest{
+		Children:       children,
+		MaxConcurrency: asIntArg(args, "max_concurrency"),
+		Yield:          yield,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tool.BatchSnapshotResult(snapshots), nil
+}
+
+// spawnRequest reads the prompt, agent and isolation of one child.
+func (t *selfSpawnTool) spawnRequest(args map[string]any) (task.SpawnStartRequest, error) {
+	promptText := strings.TrimSpace(asStringArg(args, "prompt"))
+	if promptText == "" {
+		return task.SpawnStartRequest{}, fmt.Errorf("tool: arg %q is required", "prompt")
+	}
+	agentName := strings.TrimSpace(asStringArg(args, "agent"))
+	if agentName == "" {
+		agentName = strings.TrimSpace(t.defaultAgent)
+	}
+	if agentName == "" {
+		agentName = "self"
+	}
+	isolation := strings.ToLower(strings.TrimSpace(asStringArg(args, "isolation")))
+	switch isolation {
+	case "", "none", task.IsolationWorktree:
+	default:
+		return task.SpawnStartRequest{}, fmt.Errorf("tool: arg %q must be none or worktree", "isolation")
+	}
+	return task.SpawnStartRequest{
+		Agent:     agentName,
+		Prompt:    promptText,
+		Kind:      task.KindSpawn,
+		Isolation: isolation,
+	}, nil
+}
+
+// spawnBudget reads the per-child budget caps. In a batch they apply to every
+// child separately.
+func spawnBudget(args map[string]any) task.Budget {
+	return task.Budget{
+		MaxSteps:     asIntArg(args, "max_steps"),
+		MaxTokens:    asIntArg(args, "max_tokens"),
+		MaxToolCalls: asIntArg(args, "max_tool_calls"),
+	}
+}
+
 func asStringArg(args map[string]any, key string) string {
 	if len(args) == 0 {
 		return ""
@@ -157,7 +157,7 @@ func TestSelfSpawnToolDeclarationOmitsLegacyContinuationArgs(t *testing.T) {
 	if _, ok := props["idle_timeout_seconds"]; ok {
 		t.Fatal("did not expect idle timeout arg in SPAWN declaration")
 	}
-	if got := toolImpl.Description(); !strings.Contains(got, "self or any configured ACP agent id such as codex, copilot, or gemini") || !strings.Contains(got, "result includes task_id; continue with TASK wait") || !strings.Contains(got, "use TASK write to start another turn in the same child session") {
+	if got := toolImpl.Description(); !strings.Contains(got, "a subagent profile id such as explorer or reviewer, or any configured ACP agent id such as codex, copilot, or gemini") || !strings.Contains(got, "result includes task_id; continue with TASK wait") || !strings.Contains(got, "use TASK write to start another turn in the same child session") {
 		t.Fatalf("expected SPAWN description to explain agent values and TASK flow, got %q", got)
 	}
 	agentProp, _ := props["agent"].(map[string]any)
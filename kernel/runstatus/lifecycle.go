@@ -29,6 +29,7 @@ const (
 	StatusInterrupted     Status = "interrupted"
 	StatusFailed          Status = "failed"
 	StatusCompleted       Status = "completed"
+	StatusTerminated      Status = "terminated"
 )
 
 type Info struct {
@@ -48,6 +49,9 @@ func StatusForError(err error) Status {
 	if toolexec.IsErrorCode(err, toolexec.ErrorCodeApprovalAborted) || errors.Is(err, context.Canceled) {
 		return StatusInterrupted
 	}
+	if toolexec.IsErrorCode(err, toolexec.ErrorCodeBudgetExhausted) {
+		return StatusTerminated
+	}
 	return StatusFailed
 }
 
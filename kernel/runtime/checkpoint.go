@@ -0,0 +1,258 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/OnslaughtSnail/caelis/kernel/checkpoint"
+	toolexec "github.com/OnslaughtSnail/caelis/kernel/execenv"
+	"github.com/OnslaughtSnail/caelis/kernel/model"
+	"github.com/OnslaughtSnail/caelis/kernel/policy"
+	"github.com/OnslaughtSnail/caelis/kernel/session"
+	toolfs "github.com/OnslaughtSnail/caelis/kernel/tool/builtin/filesystem"
+	"github.com/OnslaughtSnail/caelis/kernel/tool/capability"
+)
+
+const checkpointDirName = "checkpoints"
+
+// checkpointStore returns the pre-image store kept next to the session, or nil
+// when the log store has no on-disk session directory. Session directories may
+// be shared (localstore groups rollouts by day), so checkpoints are further
+// keyed by session ID.
+func (r *Runtime) checkpointStore(sess *session.Session) *checkpoint.Store {
+	if r == nil || sess == nil {
+		return nil
+	}
+	withDir, ok := r.logStore.(requestTraceSessionDirStore)
+	if !ok {
+		return nil
+	}
+	dir, err := withDir.SessionDir(sess)
+	if err != nil || strings.TrimSpace(dir) == "" {
+		return nil
+	}
+	store, err := checkpoint.New(filepath.Join(dir, checkpointDirName, sess.ID))
+	if err != nil {
+		return nil
+	}
+	return store
+}
+
+func (r *Runtime) newCheckpointHook(sess *session.Session, req RunRequest, inv *invocationContext) policy.Hook {
+	if req.CoreTools.Runtime == nil || inv == nil {
+		return nil
+	}
+	store := r.checkpointStore(sess)
+	if store == nil {
+		return nil
+	}
+	return checkpointHook{store: store, fsys: req.CoreTools.Runtime.FileSystem(), inv: inv}
+}
+
+// checkpointHook captures the pre-image of every file a write tool is about to
+// touch, keyed by the user turn that led to the call. It runs after all other
+// hooks so denied calls are skipped.
+type checkpointHook struct {
+	policy.NoopHook
+	store *checkpoint.Store
+	fsys  toolexec.FileSystem
+	inv   *invocationContext
+}
+
+func (h checkpointHook) Name() string {
+	return "checkpoint"
+}
+
+func (h checkpointHook) BeforeTool(ctx context.Context, in policy.ToolInput) (policy.ToolInput, error) {
+	_ = ctx
+	if h.inv.Overlay() || !in.Capability.HasOperation(capability.OperationFileWrite) {
+		return in, nil
+	}
+	if policy.NormalizeDecision(in.Decision).Effect == policy.DecisionEffectDeny {
+		return in, nil
+	}
+	turn, ok := lastTurnEvent(h.inv.events)
+	if !ok {
+		return in, nil
+	}
+	paths := toolfs.MutationTargetPaths(h.fsys, in.Call.Name, in.Args)
+	// Capture is best effort: a failed snapshot must not block the edit.
+	_ = h.store.Capture(h.fsys, turn.ID, paths)
+	return in, nil
+}
+
+func isTurnEvent(ev *session.Event) bool {
+	if ev == nil || strings.TrimSpace(ev.ID) == "" || ev.Message.Role != model.RoleUser {
+		return false
+	}
+	return session.IsCanonicalHistoryEvent(ev) && session.EventTypeOf(ev) == session.EventTypeConversation
+}
+
+func lastTurnEvent(events session.Events) (*session.Event, bool) {
+	if events == nil {
+		return nil, false
+	}
+	for i := events.Len() - 1; i >= 0; i-- {
+		if ev := events.At(i); isTurnEvent(ev) {
+			return ev, true
+		}
+	}
+	return nil, false
+}
+
+// TurnsRequest defines one turn listing query.
+type TurnsRequest struct {
+	AppName   string
+	UserID    string
+	SessionID string
+}
+
+// Turn is one user message in durable history together with the files whose
+// pre-images were captured while answering it.
+type Turn struct {
+	Index   int
+	EventID string
+	Time    time.Time
+	Input   string
+	Files   []string
+}
+
+// Turns lists the user turns of a session from oldest to newest. Index is
+// 1-based and is what Rewind accepts.
+func (r *Runtime) Turns(ctx context.Context, req TurnsRequest) ([]Turn, error) {
+	if ctx == nil {
+		return nil, fmt.Errorf("runtime: context is required")
+	}
+	if strings.TrimSpace(req.AppName) == "" || strings.TrimSpace(req.UserID) == "" || strings.TrimSpace(req.SessionID) == "" {
+		return nil, fmt.Errorf("runtime: app_name, user_id and session_id are required")
+	}
+	sess := &session.Session{AppName: req.AppName, UserID: req.UserID, ID: req.SessionID}
+	return r.listTurns(ctx, sess)
+}
+
+func (r *Runtime) listTurns(ctx context.Context, sess *session.Session) ([]Turn, error) {
+	events, err := r.logStore.ListEvents(ctx, sess)
+	if err != nil {
+		if errors.Is(err, session.ErrSessionNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var turns []Turn
+	for _, ev := range events {
+		if !isTurnEvent(ev) {
+			continue
+		}
+		turns = append(turns, Turn{
+			Index:   len(turns) + 1,
+			EventID: ev.ID,
+			Time:    ev.Time,
+			Input:   strings.TrimSpace(ev.Message.TextContent()),
+		})
+	}
+	if store := r.checkpointStore(sess); store != nil && len(turns) > 0 {
+		checkpoints, err := store.List()
+		if err != nil {
+			return nil, err
+		}
+		byTurn := make(map[string][]string, len(checkpoints))
+		for _, cp := range checkpoints {
+			for _, file := range cp.Files {
+				byTurn[cp.TurnID] = append(byTurn[cp.TurnID], file.Path)
+			}
+		}
+		for i := range turns {
+			turns[i].Files = byTurn[turns[i].EventID]
+		}
+	}
+	return turns, nil
+}
+
+// RewindRequest defines one rewind call.
+type RewindRequest struct {
+	AppName   string
+	UserID    string
+	SessionID string
+	// Turn is the 1-based turn to rewind to. That turn and everything after it
+	// are discarded; zero means the latest turn.
+	Turn int
+	// ExecRuntime provides the filesystem the pre-images are restored through.
+	ExecRuntime toolexec.Runtime
+}
+
+// RewindResult reports what Rewind changed.
+type RewindResult struct {
+	Turn int
+	// Input is the text of the discarded user message so callers can offer it
+	// for editing.
+	Input         string
+	RestoredFiles []string
+	RemovedFiles  []string
+	RemovedEvents int
+}
+
+// Rewind restores the workspace files touched since the given turn to their
+// pre-images and truncates the conversation to just before that turn.
+func (r *Runtime) Rewind(ctx context.Context, req RewindRequest) (RewindResult, error) {
+	if ctx == nil {
+		return RewindResult{}, fmt.Errorf("runtime: context is required")
+	}
+	if strings.TrimSpace(req.AppName) == "" || strings.TrimSpace(req.UserID) == "" || strings.TrimSpace(req.SessionID) == "" {
+		return RewindResult{}, fmt.Errorf("runtime: app_name, user_id and session_id are required")
+	}
+	truncater, ok := r.logStore.(session.TruncateStore)
+	if !ok {
+		return RewindResult{}, fmt.Errorf("runtime: session store does not support rewind")
+	}
+	key := runLeaseKey(req.AppName, req.UserID, req.SessionID)
+	if !r.acquireRunLease(key) {
+		return RewindResult{}, &SessionBusyError{AppName: req.AppName, UserID: req.UserID, SessionID: req.SessionID}
+	}
+	defer r.releaseRunLease(key)
+
+	sess := &session.Session{AppName: req.AppName, UserID: req.UserID, ID: req.SessionID}
+	turns, err := r.listTurns(ctx, sess)
+	if err != nil {
+		return RewindResult{}, err
+	}
+	if len(turns) == 0 {
+		return RewindResult{}, fmt.Errorf("runtime: session has no turns to rewind")
+	}
+	index := req.Turn
+	if index <= 0 {
+		index = len(turns)
+	}
+	if index > len(turns) {
+		return RewindResult{}, fmt.Errorf("runtime: turn %d is out of range (session has %d turns)", index, len(turns))
+	}
+	target := turns[index-1]
+	result := RewindResult{Turn: index, Input: target.Input}
+
+	discarded := make([]string, 0, len(turns)-index+1)
+	hasFiles := false
+	for _, turn := range turns[index-1:] {
+		discarded = append(discarded, turn.EventID)
+		hasFiles = hasFiles || len(turn.Files) > 0
+	}
+	if hasFiles {
+		if req.ExecRuntime == nil {
+			return RewindResult{}, fmt.Errorf("runtime: exec runtime is required to restore files")
+		}
+		restored, err := r.checkpointStore(sess).Restore(req.ExecRuntime.FileSystem(), discarded)
+		result.RestoredFiles = restored.Restored
+		result.RemovedFiles = restored.Removed
+		if err != nil {
+			return result, err
+		}
+	}
+	removed, err := truncater.TruncateEvents(ctx, sess, target.EventID)
+	if err != nil {
+		return result, err
+	}
+	result.RemovedEvents = removed
+	return result, nil
+}
@@ -0,0 +1,107 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/OnslaughtSnail/caelis/kernel/llmagent"
+	"github.com/OnslaughtSnail/caelis/kernel/model"
+	"github.com/OnslaughtSnail/caelis/kernel/session"
+	"github.com/OnslaughtSnail/caelis/kernel/session/filestore"
+	"github.com/OnslaughtSnail/caelis/kernel/tool"
+)
+
+func TestRuntime_RewindRestoresFilesAndTruncatesHistory(t *testing.T) {
+	store, err := filestore.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt, err := New(Config{LogStore: store, StateStore: store})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ag, err := llmagent.New(llmagent.Config{Name: "writer"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	work := t.TempDir()
+	existing := filepath.Join(work, "main.go")
+	created := filepath.Join(work, "extra.go")
+	if err := os.WriteFile(existing, []byte("original"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// Each user message names the file to write; the model writes it and then
+	// finishes the turn.
+	targets := map[string]string{"edit main": existing, "add extra": created, "say hi": ""}
+	llm := &scriptedRuntimeLLM{
+		name: "writer-llm",
+		run: func(req *model.Request) (*model.Response, error) {
+			last := req.Messages[len(req.Messages)-1]
+			if target := targets[last.TextContent()]; last.Role == model.RoleUser && target != "" {
+				args, _ := json.Marshal(map[string]any{"path": target, "content": "changed by " + last.TextContent()})
+				return &model.Response{
+					Message: model.MessageFromToolCalls(model.RoleAssistant, []model.ToolCall{{
+						ID: "call-" + filepath.Base(target), Name: "WRITE", Args: string(args),
+					}}, ""),
+					TurnComplete: true,
+				}, nil
+			}
+			return &model.Response{Message: model.NewTextMessage(model.RoleAssistant, "done"), TurnComplete: true}, nil
+		},
+	}
+	execRT := newCoreRuntime(t)
+	for _, input := range []string{"say hi", "edit main", "add extra"} {
+		for _, runErr := range runEvents(context.Background(), t, rt, RunRequest{
+			AppName:   "app",
+			UserID:    "u",
+			SessionID: "s-rewind",
+			Input:     input,
+			Agent:     ag,
+			Model:     llm,
+			CoreTools: tool.CoreToolsConfig{Runtime: execRT},
+		}) {
+			if runErr != nil {
+				t.Fatal(runErr)
+			}
+		}
+	}
+
+	turns, err := rt.Turns(context.Background(), TurnsRequest{AppName: "app", UserID: "u", SessionID: "s-rewind"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(turns) != 3 || turns[1].Input != "edit main" || len(turns[0].Files) != 0 || len(turns[1].Files) != 1 || turns[2].Files[0] != created {
+		t.Fatalf("unexpected turns %+v", turns)
+	}
+
+	result, err := rt.Rewind(context.Background(), RewindRequest{
+		AppName: "app", UserID: "u", SessionID: "s-rewind", Turn: 2, ExecRuntime: execRT,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Input != "edit main" || len(result.RestoredFiles) != 1 || len(result.RemovedFiles) != 1 || result.RemovedEvents == 0 {
+		t.Fatalf("unexpected rewind result %+v", result)
+	}
+	if raw, err := os.ReadFile(existing); err != nil || string(raw) != "original" {
+		t.Fatalf("expected main.go to be restored, got %q %v", raw, err)
+	}
+	if _, err := os.Stat(created); !os.IsNotExist(err) {
+		t.Fatalf("expected extra.go to be removed, got %v", err)
+	}
+	events, err := store.ListEvents(context.Background(), &session.Session{AppName: "app", UserID: "u", ID: "s-rewind"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ev := range events {
+		if ev.Message.Role == model.RoleUser && ev.Message.TextContent() != "say hi" {
+			t.Fatalf("expected history to stop before turn 2, found %q", ev.Message.TextContent())
+		}
+	}
+	if _, err := rt.Rewind(context.Background(), RewindRequest{AppName: "app", UserID: "u", SessionID: "s-rewind", Turn: 5}); err == nil {
+		t.Fatal("expected out-of-range turn to fail")
+	}
+}
@@ -24,6 +24,7 @@ const (
 	RunLifecycleStatusInterrupted     RunLifecycleStatus = runstatus.StatusInterrupted
 	RunLifecycleStatusFailed          RunLifecycleStatus = runstatus.StatusFailed
 	RunLifecycleStatusCompleted       RunLifecycleStatus = runstatus.StatusCompleted
+	RunLifecycleStatusTerminated      RunLifecycleStatus = runstatus.StatusTerminated
 )
 
 func lifecycleStatusForError(err error) RunLifecycleStatus {
@@ -52,6 +52,13 @@ type runHandle struct {
 	closeOnce     sync.Once
 
 	submitSlot atomic.Pointer[Submission]
+
+	// budget enforces RunRequest.Budget; nil when the run has no budget.
+	budget *budgetTracker
+
+	// turnID is the event ID of the user message currently being answered;
+	// model usage is attributed to it.
+	turnID string
 }
 
 func (h *runHandle) RunID() string { return h.runID }
@@ -299,7 +306,7 @@ func (r *Runtime) newRunner(ctx context.Context, req RunRequest) (*runHandle, er
 	runID := idutil.NewRunID()
 	req.Model = model.WrapRequestTrace(req.Model)
 	runCtx := withRequestTraceContext(ctx, r.logStore, sess, runID)
-	runCtx, cancel := context.WithCancel(runCtx)
+	runCtx, cancel := withWallTimeBudget(runCtx, req.Budget)
 	handle := &runHandle{
 		runtime:        r,
 		req:            req,
@@ -311,6 +318,7 @@ func (r *Runtime) newRunner(ctx context.Context, req RunRequest) (*runHandle, er
 		eventNotifyCh:  make(chan struct{}, 1),
 		submitNotifyCh: make(chan struct{}, 1),
 		doneCh:         make(chan struct{}),
+		budget:         newBudgetTracker(req.Budget, r.pricer),
 	}
 	go handle.runWorker(runCtx, leaseKey)
 	return handle, nil
@@ -419,6 +427,10 @@ func (h *runHandle) driveAgentRun(inv *invocationContext) (restart bool, ok bool
 	for {
 		item, open := pump.next()
 		if !open {
+			if cause := budgetStopCause(h.ctx); cause != nil {
+				h.emitTerminalError(cause)
+				return false, false
+			}
 			if err := h.ctx.Err(); err != nil {
 				_ = h.appendOutputLifecycle(RunLifecycleStatusInterrupted, "run", err)
 				return false, false
@@ -458,6 +470,11 @@ func (h *runHandle) driveAgentRun(inv *invocationContext) (restart bool, ok bool
 				}
 				return false, false
 			}
+			if cause := budgetStopCause(h.ctx); cause != nil {
+				_ = pump.respond(false)
+				h.emitTerminalError(cause)
+				return false, false
+			}
 			if isContextOverflowError(item.err) {
 				_ = pump.respond(false)
 				restarted, handled := h.handleContextOverflow(inv)
@@ -492,6 +509,11 @@ func (h *runHandle) driveAgentRun(inv *invocationContext) (restart bool, ok bool
 			if !isEventPartial(ev) {
 				turnEvents = append(turnEvents, ev)
 			}
+			if err := h.budget.observe(ev); err != nil {
+				_ = pump.respond(false)
+				h.emitTerminalError(err)
+				return false, false
+			}
 		}
 		boundary, terminal := tracker.observe(ev)
 		if boundary {
@@ -0,0 +1,88 @@
+package runtime
+
+import (
+	"context"
+	"time"
+
+	toolexec "github.com/OnslaughtSnail/caelis/kernel/execenv"
+	"github.com/OnslaughtSnail/caelis/kernel/model"
+	"github.com/OnslaughtSnail/caelis/kernel/session"
+	"github.com/OnslaughtSnail/caelis/kernel/task"
+	"github.com/OnslaughtSnail/caelis/kernel/tokenusage"
+)
+
+// budgetTracker accounts the model steps, tool calls, tokens and cost of one
+// run against RunRequest.Budget.
+type budgetTracker struct {
+	budget    task.Budget
+	pricer    tokenusage.Pricer
+	steps     int
+	toolCalls int
+	tokens    int
+	cost      float64
+}
+
+func newBudgetTracker(budget task.Budget, pricer tokenusage.Pricer) *budgetTracker {
+	if budget.IsZero() {
+		return nil
+	}
+	return &budgetTracker{budget: budget, pricer: pricer}
+}
+
+// observe records one final assistant message and returns a budget error when
+// the run may not continue. A message without tool calls ends the run anyway,
+// so it is never stopped: the child keeps its final answer.
+func (t *budgetTracker) observe(ev *session.Event) error {
+	if t == nil || ev == nil || isEventPartial(ev) || ev.Message.Role != model.RoleAssistant {
+		return nil
+	}
+	t.steps++
+	calls := len(ev.Message.ToolCalls())
+	if provider, modelName, usage, ok := tokenusage.FromEventMeta(ev.Meta); ok {
+		t.tokens += usage.TotalTokens
+		if t.pricer != nil {
+			if cost, priced := t.pricer(provider, modelName, usage); priced {
+				t.cost += cost
+			}
+		}
+	}
+	if calls == 0 {
+		return nil
+	}
+	switch {
+	case t.budget.MaxToolCalls > 0 && t.toolCalls+calls > t.budget.MaxToolCalls:
+		return budgetExhaustedError("tool call limit of %d reached", t.budget.MaxToolCalls)
+	case t.budget.MaxSteps > 0 && t.steps >= t.budget.MaxSteps:
+		return budgetExhaustedError("model step limit of %d reached", t.budget.MaxSteps)
+	case t.budget.MaxTokens > 0 && t.tokens >= t.budget.MaxTokens:
+		return budgetExhaustedError("token limit of %d reached after %d tokens", t.budget.MaxTokens, t.tokens)
+	case t.budget.MaxCostUSD > 0 && t.cost >= t.budget.MaxCostUSD:
+		return budgetExhaustedError("cost limit of $%.4f reached after $%.4f", t.budget.MaxCostUSD, t.cost)
+	}
+	t.toolCalls += calls
+	return nil
+}
+
+func budgetExhaustedError(format string, args ...any) error {
+	return toolexec.NewCodedError(toolexec.ErrorCodeBudgetExhausted, "runtime: budget exhausted: "+format, args...)
+}
+
+// withWallTimeBudget bounds ctx by the run's wall time budget. The deadline
+// cause is a budget error so the run ends as terminated, not interrupted.
+func withWallTimeBudget(ctx context.Context, budget task.Budget) (context.Context, context.CancelFunc) {
+	if budget.MaxWallTime <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeoutCause(ctx, budget.MaxWallTime, budgetExhaustedError("wall time limit of %s reached", budget.MaxWallTime.Round(time.Second)))
+}
+
+// budgetStopCause returns the budget error that ended ctx, if any.
+func budgetStopCause(ctx context.Context) error {
+	if ctx.Err() == nil {
+		return nil
+	}
+	if cause := context.Cause(ctx); toolexec.IsErrorCode(cause, toolexec.ErrorCodeBudgetExhausted) {
+		return cause
+	}
+	return nil
+}
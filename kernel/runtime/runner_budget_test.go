@@ -0,0 +1,123 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"testing"
+	"time"
+
+	"github.com/OnslaughtSnail/caelis/kernel/agent"
+	toolexec "github.com/OnslaughtSnail/caelis/kernel/execenv"
+	"github.com/OnslaughtSnail/caelis/kernel/model"
+	"github.com/OnslaughtSnail/caelis/kernel/session"
+	"github.com/OnslaughtSnail/caelis/kernel/session/inmemory"
+	"github.com/OnslaughtSnail/caelis/kernel/task"
+	"github.com/OnslaughtSnail/caelis/kernel/tool"
+)
+
+// busyAgent keeps calling a tool with new arguments so loop detection never
+// stops it.
+type busyAgent struct {
+	steps int
+}
+
+func (a *busyAgent) Name() string { return "busy-agent" }
+func (a *busyAgent) Run(_ agent.InvocationContext) iter.Seq2[*session.Event, error] {
+	return func(yield func(*session.Event, error) bool) {
+		for {
+			a.steps++
+			callID := fmt.Sprintf("call_%d", a.steps)
+			args := fmt.Sprintf(`{"path":"file_%d.txt"}`, a.steps)
+			if !yield(&session.Event{Message: model.MessageFromToolCalls(model.RoleAssistant, []model.ToolCall{
+				{ID: callID, Name: "READ", Args: args},
+			}, "")}, nil) {
+				return
+			}
+			if !yield(&session.Event{Message: model.MessageFromToolResponse(&model.ToolResponse{
+				ID: callID, Name: "READ",
+				Result: map[string]any{"content": "ok"},
+			})}, nil) {
+				return
+			}
+		}
+	}
+}
+
+// stalledAgent waits for the run to end without producing output.
+type stalledAgent struct{}
+
+func (stalledAgent) Name() string { return "stalled-agent" }
+func (stalledAgent) Run(inv agent.InvocationContext) iter.Seq2[*session.Event, error] {
+	return func(yield func(*session.Event, error) bool) {
+		<-inv.Done()
+		yield(nil, inv.Err())
+	}
+}
+
+func runBudgetTest(t *testing.T, sessionID string, ag agent.Agent, budget task.Budget) (RunState, error) {
+	t.Helper()
+	store := inmemory.New()
+	rt, err := New(Config{LogStore: store, StateStore: store})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var runErr error
+	for _, err := range runEvents(context.Background(), t, rt, RunRequest{
+		AppName:   "app",
+		UserID:    "u",
+		SessionID: sessionID,
+		Input:     "hello",
+		Agent:     ag,
+		Model:     newRuntimeTestLLM("fake"),
+		CoreTools: tool.CoreToolsConfig{Runtime: newCoreRuntime(t)},
+		Budget:    budget,
+	}) {
+		if err != nil {
+			runErr = err
+		}
+	}
+	state, err := rt.RunState(context.Background(), RunStateRequest{AppName: "app", UserID: "u", SessionID: sessionID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return state, runErr
+}
+
+func TestRuntime_BudgetStopsRunAfterMaxSteps(t *testing.T) {
+	ag := &busyAgent{}
+	state, runErr := runBudgetTest(t, "s-budget-steps", ag, task.Budget{MaxSteps: 2})
+	if !toolexec.IsErrorCode(runErr, toolexec.ErrorCodeBudgetExhausted) {
+		t.Fatalf("expected a budget error, got %v", runErr)
+	}
+	if ag.steps != 2 {
+		t.Fatalf("expected the run to stop after 2 model steps, got %d", ag.steps)
+	}
+	if state.Status != RunLifecycleStatusTerminated {
+		t.Fatalf("expected terminated status, got %q", state.Status)
+	}
+}
+
+func TestRuntime_BudgetStopsRunBeforeExceedingToolCalls(t *testing.T) {
+	ag := &busyAgent{}
+	state, runErr := runBudgetTest(t, "s-budget-tools", ag, task.Budget{MaxToolCalls: 3})
+	if !toolexec.IsErrorCode(runErr, toolexec.ErrorCodeBudgetExhausted) {
+		t.Fatalf("expected a budget error, got %v", runErr)
+	}
+	if ag.steps != 4 {
+		t.Fatalf("expected the fourth tool call to be refused, got %d steps", ag.steps)
+	}
+	if state.Status != RunLifecycleStatusTerminated {
+		t.Fatalf("expected terminated status, got %q", state.Status)
+	}
+}
+
+func TestRuntime_BudgetStopsRunAfterWallTime(t *testing.T) {
+	state, runErr := runBudgetTest(t, "s-budget-wall", stalledAgent{}, task.Budget{MaxWallTime: 50 * time.Millisecond})
+	if !toolexec.IsErrorCode(runErr, toolexec.ErrorCodeBudgetExhausted) {
+		t.Fatalf("expected a budget error, got %v", runErr)
+	}
+	if state.Status != RunLifecycleStatusTerminated {
+		t.Fatalf("expected terminated status, got %q", state.Status)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"strings"
 	"time"
 
+	toolexec "github.com/OnslaughtSnail/caelis/kernel/execenv"
 	"github.com/OnslaughtSnail/caelis/kernel/session"
 	"github.com/OnslaughtSnail/caelis/kernel/sessionstream"
 )
@@ -54,6 +55,8 @@ func visibleRunErrorNotice(err error) string {
 		return ""
 	case errors.Is(err, errLoopDetected):
 		return "runtime detected repeated identical model output and stopped the turn to prevent an infinite tool loop."
+	case toolexec.IsErrorCode(err, toolexec.ErrorCodeBudgetExhausted):
+		return "runtime stopped the run because it used up its budget."
 	default:
 		return ""
 	}
@@ -70,6 +73,9 @@ func (h *runHandle) appendOutput(ev *session.Event, err error, persist bool) boo
 			}
 		}
 		sessionstream.Emit(h.ctx, ev.SessionID, ev)
+		// Usage accounting is best effort: a failed ledger write must not
+		// abort the run.
+		_ = h.runtime.recordUsage(h.ctx, h.sess, h.turnID, ev)
 	}
 	durable := ev != nil && isDurableReplayEvent(ev)
 	h.replay.Append(ev, err, durable)
@@ -209,6 +209,7 @@ func (h *runHandle) applySubmission(sub *Submission) ([]*session.Event, bool) {
 		h.emitTerminalError(err)
 		return nil, false
 	}
+	h.turnID = userEvent.ID
 	if !h.appendOutput(userEvent, nil, false) {
 		return nil, false
 	}
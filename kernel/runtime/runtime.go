@@ -14,6 +14,7 @@ import (
 	"github.com/OnslaughtSnail/caelis/kernel/runstatus"
 	"github.com/OnslaughtSnail/caelis/kernel/session"
 	"github.com/OnslaughtSnail/caelis/kernel/task"
+	"github.com/OnslaughtSnail/caelis/kernel/tokenusage"
 	"github.com/OnslaughtSnail/caelis/kernel/tool"
 )
 
@@ -23,6 +24,9 @@ type Config struct {
 	StateStore session.StateStore
 	TaskStore  task.Store
 	Compaction CompactionConfig
+	// Pricer prices each model call for the session usage ledger. Nil records
+	// tokens only.
+	Pricer tokenusage.Pricer
 }
 
 // Runtime orchestrates session lifecycle and agent execution.
@@ -30,6 +34,8 @@ type Runtime struct {
 	logStore           session.LogStore
 	stateStore         session.StateStore
 	lifecycleStore     session.SessionStateStore[runstatus.State]
+	usageStore         session.SessionStateStore[tokenusage.State]
+	pricer             tokenusage.Pricer
 	taskStore          task.Store
 	taskRegistry       *task.Registry
 	compaction         CompactionConfig
@@ -50,6 +56,10 @@ func New(cfg Config) (*Runtime, error) {
 	if err != nil {
 		return nil, fmt.Errorf("runtime: lifecycle state store: %w", err)
 	}
+	usageStore, err := tokenusage.NewStore(cfg.StateStore)
+	if err != nil {
+		return nil, fmt.Errorf("runtime: usage state store: %w", err)
+	}
 	compactionCfg := normalizeCompactionConfig(cfg.Compaction)
 	strategy := compactionCfg.Strategy
 	if strategy == nil {
@@ -59,6 +69,8 @@ func New(cfg Config) (*Runtime, error) {
 		logStore:           cfg.LogStore,
 		stateStore:         cfg.StateStore,
 		lifecycleStore:     lifecycleStore,
+		usageStore:         usageStore,
+		pricer:             cfg.Pricer,
 		taskStore:          cfg.TaskStore,
 		taskRegistry:       task.NewRegistry(task.RegistryConfig{}),
 		compaction:         compactionCfg,
@@ -100,6 +112,10 @@ type RunRequest struct {
 	Policies              []policy.Hook
 	ContextWindowTokens   int
 	SubagentRunnerFactory SubagentRunnerFactory
+
+	// Budget limits this run; the runtime stops it as terminated once any
+	// limit is reached.
+	Budget task.Budget
 }
 
 func validateRunRequest(req RunRequest) error {
@@ -185,7 +201,7 @@ func (r *Runtime) buildInvocationContext(
 	if err != nil {
 		return nil, fmt.Errorf("runtime: snapshot readonly state: %w", err)
 	}
-	return &invocationContext{
+	inv := &invocationContext{
 		Context:  ctx,
 		session:  sess,
 		events:   r.projectInvocationEvents(allEvents),
@@ -196,7 +212,11 @@ func (r *Runtime) buildInvocationContext(
 		policies: append([]policy.Hook(nil), req.Policies...),
 		runner:   subagentRunner,
 		tasks:    taskManager,
-	}, nil
+	}
+	if hook := r.newCheckpointHook(sess, req, inv); hook != nil {
+		inv.policies = append(inv.policies, hook)
+	}
+	return inv, nil
 }
 
 func (r *Runtime) newSubagentRunner(sess *session.Session, req RunRequest) agent.SubagentRunner {
@@ -245,6 +245,11 @@ func (r *runtimeSubagentRunner) prepareChildRun(ctx context.Context, req agent.S
 	if r == nil || r.runtime == nil || r.parent == nil {
 		return RunRequest{}, delegationLineage{}, fmt.Errorf("runtime: subagent runner is unavailable")
 	}
+	if strings.TrimSpace(req.ChildCWD) != "" {
+		// Children share the parent's tools and execution runtime, which are
+		// bound to the parent's working directory.
+		return RunRequest{}, delegationLineage{}, fmt.Errorf("runtime: the in-process subagent runner cannot run a child in another directory")
+	}
 	childSessionID, err := resolveChildSessionID(r.parent.ID, req.SessionID)
 	if err != nil {
 		return RunRequest{}, delegationLineage{}, err
@@ -281,6 +286,7 @@ func (r *runtimeSubagentRunner) prepareChildRun(ctx context.Context, req agent.S
 	childReq.SessionID = childSessionID
 	childReq.Input = req.Prompt
 	childReq.ContentParts = model.ContentPartsFromParts(req.Parts)
+	childReq.Budget = req.Budget
 	return childReq, lineage, nil
 }
 
@@ -9,6 +9,7 @@ import (
 
 	toolexec "github.com/OnslaughtSnail/caelis/kernel/execenv"
 	"github.com/OnslaughtSnail/caelis/kernel/task"
+	"github.com/OnslaughtSnail/caelis/kernel/taskruntime"
 )
 
 type ReconcileSessionRequest struct {
@@ -56,7 +57,7 @@ func (r *Runtime) reconcileTaskEntry(ctx context.Context, entry *task.Entry, exe
 		return nil, fmt.Errorf("runtime: task entry is required")
 	}
 	if !entry.Running {
-		return entry, nil
+		return r.reconcileTaskWorktree(ctx, entry)
 	}
 	if live, err := r.resolveTaskRegistry(nil).Get(entry.TaskID); err == nil && live != nil {
 		running := false
@@ -69,6 +70,9 @@ func (r *Runtime) reconcileTaskEntry(ctx context.Context, entry *task.Entry, exe
 	}
 	switch entry.Kind {
 	case task.KindSpawn:
+		if entry.State == task.StateQueued {
+			return r.markTaskInterrupted(ctx, entry, "queued spawn child was never started")
+		}
 		return r.reconcileSubagentTask(ctx, entry)
 	case task.KindBash:
 		return r.reconcileBashTask(ctx, entry, execRuntime)
@@ -77,6 +81,18 @@ func (r *Runtime) reconcileTaskEntry(ctx context.Context, entry *task.Entry, exe
 	}
 }
 
+// reconcileTaskWorktree keeps the worktree of a stopped isolated SPAWN task in
+// sync with the disk so a restarted parent can still apply or discard it.
+func (r *Runtime) reconcileTaskWorktree(ctx context.Context, entry *task.Entry) (*task.Entry, error) {
+	if entry.Kind != task.KindSpawn || !taskruntime.ReconcileWorktree(entry) {
+		return entry, nil
+	}
+	if err := r.taskStore.Upsert(ctx, task.CloneEntry(entry)); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
 func (r *Runtime) reconcileBashTask(ctx context.Context, entry *task.Entry, execRuntime toolexec.Runtime) (*task.Entry, error) {
 	sessionID := strings.TrimSpace(stringValue(entry.Spec, taskSpecExecSessionID))
 	if sessionID == "" {
@@ -206,6 +222,7 @@ func (r *Runtime) reconcileSubagentTask(ctx context.Context, entry *task.Entry)
 	}
 	delete(entry.Result, "interrupted")
 	delete(entry.Result, "error")
+	taskruntime.ReconcileWorktree(entry)
 	if err := r.taskStore.Upsert(ctx, task.CloneEntry(entry)); err != nil {
 		return nil, err
 	}
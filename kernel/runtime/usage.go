@@ -4,10 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 
 	compact "github.com/OnslaughtSnail/caelis/kernel/compaction"
 	"github.com/OnslaughtSnail/caelis/kernel/model"
 	"github.com/OnslaughtSnail/caelis/kernel/session"
+	"github.com/OnslaughtSnail/caelis/kernel/tokenusage"
 )
 
 // UsageRequest defines one context usage inspection request.
@@ -67,3 +69,56 @@ func (r *Runtime) ContextUsage(ctx context.Context, req UsageRequest) (ContextUs
 		EventCount:    len(window),
 	}, nil
 }
+
+// TokenUsageRequest defines one session token usage query.
+type TokenUsageRequest struct {
+	AppName   string
+	UserID    string
+	SessionID string
+}
+
+// TokenUsage is the persisted token and cost ledger of one session.
+type TokenUsage = tokenusage.State
+
+// TokenUsage returns the cumulative, per-turn and per-model token usage that
+// was recorded for a session.
+func (r *Runtime) TokenUsage(ctx context.Context, req TokenUsageRequest) (TokenUsage, error) {
+	if ctx == nil {
+		return TokenUsage{}, fmt.Errorf("runtime: context is required")
+	}
+	if strings.TrimSpace(req.AppName) == "" || strings.TrimSpace(req.UserID) == "" || strings.TrimSpace(req.SessionID) == "" {
+		return TokenUsage{}, fmt.Errorf("runtime: app_name, user_id and session_id are required")
+	}
+	sess := &session.Session{AppName: req.AppName, UserID: req.UserID, ID: req.SessionID}
+	state, err := r.usageStore.Load(ctx, sess)
+	if err != nil {
+		if errors.Is(err, session.ErrSessionNotFound) {
+			return TokenUsage{}, nil
+		}
+		return TokenUsage{}, err
+	}
+	return state, nil
+}
+
+// recordUsage adds the usage an assistant event was annotated with to the
+// session ledger. Runs hold the session lease, so the load/save pair does not
+// race with another writer of the same session.
+func (r *Runtime) recordUsage(ctx context.Context, sess *session.Session, turnID string, ev *session.Event) error {
+	if r == nil || r.usageStore == nil || sess == nil || ev == nil || ev.Message.Role != model.RoleAssistant || isEventPartial(ev) {
+		return nil
+	}
+	provider, modelName, usage, ok := tokenusage.FromEventMeta(ev.Meta)
+	if !ok {
+		return nil
+	}
+	cost, priced := 0.0, false
+	if r.pricer != nil {
+		cost, priced = r.pricer(provider, modelName, usage)
+	}
+	state, err := r.usageStore.Load(ctx, sess)
+	if err != nil {
+		return err
+	}
+	state.Record(turnID, provider, modelName, usage, cost, priced)
+	return r.usageStore.Save(ctx, sess, state)
+}
@@ -0,0 +1,67 @@
+package runtime
+
+import (
+	"context"
+	"testing"
+
+	"github.com/OnslaughtSnail/caelis/kernel/llmagent"
+	"github.com/OnslaughtSnail/caelis/kernel/model"
+	"github.com/OnslaughtSnail/caelis/kernel/session/inmemory"
+	"github.com/OnslaughtSnail/caelis/kernel/tool"
+)
+
+func TestRuntime_TokenUsageRecordsTurnsModelsAndCost(t *testing.T) {
+	store := inmemory.New()
+	rt, err := New(Config{
+		LogStore:   store,
+		StateStore: store,
+		Pricer: func(provider, modelName string, usage model.Usage) (float64, bool) {
+			if modelName != "priced" {
+				return 0, false
+			}
+			return float64(usage.TotalTokens) / 1000, true
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ag, err := llmagent.New(llmagent.Config{Name: "usage"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	execRT := newCoreRuntime(t)
+	for _, name := range []string{"priced", "priced", "free"} {
+		llm := &scriptedRuntimeLLM{
+			name: name,
+			run: func(*model.Request) (*model.Response, error) {
+				return &model.Response{
+					Message:      model.NewTextMessage(model.RoleAssistant, "ok"),
+					TurnComplete: true,
+					Usage:        model.Usage{PromptTokens: 90, CompletionTokens: 10, TotalTokens: 100, CacheReadTokens: 40},
+				}, nil
+			},
+		}
+		for _, runErr := range runEvents(context.Background(), t, rt, RunRequest{
+			AppName: "app", UserID: "u", SessionID: "s-usage", Input: "hi", Agent: ag, Model: llm,
+			CoreTools: tool.CoreToolsConfig{Runtime: execRT},
+		}) {
+			if runErr != nil {
+				t.Fatal(runErr)
+			}
+		}
+	}
+
+	usage, err := rt.TokenUsage(context.Background(), TokenUsageRequest{AppName: "app", UserID: "u", SessionID: "s-usage"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.Total.Requests != 3 || usage.Total.TotalTokens != 300 || usage.Total.CacheReadTokens != 120 || usage.Total.UnpricedRequests != 1 {
+		t.Fatalf("unexpected totals %+v", usage.Total)
+	}
+	if usage.Total.CostUSD < 0.199 || usage.Total.CostUSD > 0.201 {
+		t.Fatalf("expected two priced calls at 0.1 each, got %v", usage.Total.CostUSD)
+	}
+	if len(usage.Turns) != 3 || len(usage.Models) != 2 {
+		t.Fatalf("expected 3 turns and 2 models, got %+v", usage)
+	}
+}
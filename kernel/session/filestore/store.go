@@ -1,6 +1,7 @@
 package filestore
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
@@ -161,6 +162,49 @@ func (s *Store) ListEvents(ctx context.Context, req *session.Session) ([]*sessio
 	return out, nil
 }
 
+// TruncateEvents rewrites events.jsonl without the event eventID and anything
+// appended after it.
+func (s *Store) TruncateEvents(ctx context.Context, req *session.Session, eventID string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	events, err := s.ListEvents(ctx, req)
+	if err != nil {
+		return 0, err
+	}
+	cut := -1
+	for i, ev := range events {
+		if ev != nil && ev.ID == eventID {
+			cut = i
+			break
+		}
+	}
+	if cut < 0 {
+		return 0, session.ErrEventNotFound
+	}
+	dir, err := s.sessionDir(req)
+	if err != nil {
+		return 0, err
+	}
+	var buf bytes.Buffer
+	for _, ev := range events[:cut] {
+		raw, err := json.Marshal(ev)
+		if err != nil {
+			return 0, err
+		}
+		buf.Write(append(raw, '\n'))
+	}
+	path := filepath.Join(dir, "events.jsonl")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o644); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return 0, err
+	}
+	return len(events) - cut, nil
+}
+
 func (s *Store) ListEventsAfter(ctx context.Context, req *session.Session, afterCursor string, limit int) ([]*session.Event, string, error) {
 	events, err := s.ListEvents(ctx, req)
 	if err != nil {
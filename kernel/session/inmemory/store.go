@@ -104,6 +104,28 @@ func (s *Store) ListEvents(ctx context.Context, req *session.Session) ([]*sessio
 	return out, nil
 }
 
+func (s *Store) TruncateEvents(ctx context.Context, req *session.Session, eventID string) (int, error) {
+	_ = ctx
+	k, err := makeKey(req)
+	if err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.data[k]
+	if !ok {
+		return 0, session.ErrSessionNotFound
+	}
+	for i, ev := range e.events {
+		if ev != nil && ev.ID == eventID {
+			removed := len(e.events) - i
+			e.events = e.events[:i:i]
+			return removed, nil
+		}
+	}
+	return 0, session.ErrEventNotFound
+}
+
 func (s *Store) ListEventsAfter(ctx context.Context, req *session.Session, afterCursor string, limit int) ([]*session.Event, string, error) {
 	events, err := s.ListEvents(ctx, req)
 	if err != nil {
@@ -11,6 +11,9 @@ import (
 
 var ErrSessionNotFound = errors.New("session: not found")
 
+// ErrEventNotFound is returned when an event ID does not exist in a session.
+var ErrEventNotFound = errors.New("session: event not found")
+
 // Session identifies a conversation thread.
 type Session struct {
 	AppName string
@@ -76,6 +79,13 @@ type ContextWindowStore interface {
 	ListContextWindowEvents(context.Context, *Session) ([]*Event, error)
 }
 
+// TruncateStore optionally drops a suffix of durable history. TruncateEvents
+// removes the event with the given ID and every event appended after it and
+// returns how many events were removed.
+type TruncateStore interface {
+	TruncateEvents(context.Context, *Session, string) (int, error)
+}
+
 // SessionStateStore is a typed repository for one logical session namespace.
 // It can be layered over a generic StateStore or backed by a dedicated adapter.
 type SessionStateStore[T any] interface {
@@ -21,6 +21,9 @@ import (
 	"github.com/OnslaughtSnail/caelis/pkg/idutil"
 )
 
+// ErrSessionBusy reports that a session already has a turn in flight.
+var ErrSessionBusy = errors.New("already has an active run")
+
 type WorkspaceRef struct {
 	Key string
 	CWD string
@@ -76,6 +79,7 @@ type RunTurnRequest struct {
 	ContextWindowTokens int
 	Mode                string
 	Config              map[string]string
+	Budget              task.Budget
 }
 
 type RunTurnResult struct {
@@ -287,7 +291,7 @@ func (s *Service) RunTurn(ctx context.Context, req RunTurnRequest) (RunTurnResul
 		return RunTurnResult{}, err
 	}
 	if !s.trySetActive(ref.SessionID, &activeTurn{}) {
-		return RunTurnResult{}, fmt.Errorf("sessionsvc: session %q already has an active run", ref.SessionID)
+		return RunTurnResult{}, fmt.Errorf("sessionsvc: session %q %w", ref.SessionID, ErrSessionBusy)
 	}
 	runSvc, err := runservice.New(runservice.ServiceConfig{
 		Runtime:               s.runtime,
@@ -320,6 +324,7 @@ func (s *Service) RunTurn(ctx context.Context, req RunTurnRequest) (RunTurnResul
 		Agent:               req.Agent,
 		Model:               req.Model,
 		ContextWindowTokens: req.ContextWindowTokens,
+		Budget:              req.Budget,
 	})
 	if err != nil {
 		cancel()
@@ -0,0 +1,65 @@
+package task
+
+import (
+	"fmt"
+	"time"
+)
+
+// Budget caps how much work one delegated child run may do. A zero field means
+// no limit. The child runtime enforces it and stops the run as terminated
+// once any limit is reached.
+type Budget struct {
+	// MaxSteps limits model calls in one run.
+	MaxSteps int
+	// MaxTokens limits the total tokens reported by the model across the run.
+	MaxTokens int
+	// MaxToolCalls limits tool calls requested by the model across the run.
+	MaxToolCalls int
+	// MaxCostUSD limits the priced model cost of the run.
+	MaxCostUSD float64
+	// MaxWallTime limits how long the run may take.
+	MaxWallTime time.Duration
+}
+
+// IsZero reports whether the budget sets no limit at all.
+func (b Budget) IsZero() bool {
+	return b == Budget{}
+}
+
+// WithDefaults fills every unset limit of b from defaults.
+func (b Budget) WithDefaults(defaults Budget) Budget {
+	if b.MaxSteps <= 0 {
+		b.MaxSteps = defaults.MaxSteps
+	}
+	if b.MaxTokens <= 0 {
+		b.MaxTokens = defaults.MaxTokens
+	}
+	if b.MaxToolCalls <= 0 {
+		b.MaxToolCalls = defaults.MaxToolCalls
+	}
+	if b.MaxCostUSD <= 0 {
+		b.MaxCostUSD = defaults.MaxCostUSD
+	}
+	if b.MaxWallTime <= 0 {
+		b.MaxWallTime = defaults.MaxWallTime
+	}
+	return b
+}
+
+// Validate rejects negative limits.
+func (b Budget) Validate() error {
+	switch {
+	case b.MaxSteps < 0:
+		return fmt.Errorf("task: budget max steps must not be negative")
+	case b.MaxTokens < 0:
+		return fmt.Errorf("task: budget max tokens must not be negative")
+	case b.MaxToolCalls < 0:
+		return fmt.Errorf("task: budget max tool calls must not be negative")
+	case b.MaxCostUSD < 0:
+		return fmt.Errorf("task: budget max cost must not be negative")
+	case b.MaxWallTime < 0:
+		return fmt.Errorf("task: budget max wall time must not be negative")
+	default:
+		return nil
+	}
+}
@@ -32,6 +32,7 @@ const (
 	StateInterrupted     State = "interrupted"
 	StateTerminated      State = "terminated"
 	StateWaitingApproval State = "waiting_approval"
+	StateQueued          State = "queued"
 )
 
 var ErrTaskNotFound = errors.New("task: not found")
@@ -67,8 +68,21 @@ type BashStartRequest struct {
 	Backend               string
 	EnvOverrides          map[string]string
 	SandboxPolicyOverride *toolexec.SandboxPolicy
+	OnNetworkDenied       func(host string)
+	// Limits are per-call caps; they tighten the runtime's defaults.
+	Limits toolexec.ResourceLimits
+	// Session is a command the caller has already started, such as one in a
+	// persistent shell. The task tracks it instead of starting Command.
+	Session toolexec.Session
+	// NetworkDeniedHosts lists the hosts the sandbox refused so far for a
+	// caller-started Session. Commands the manager starts report theirs
+	// through OnNetworkDenied.
+	NetworkDeniedHosts func() []string
 }
 
+// IsolationWorktree runs a SPAWN child in its own git worktree and branch.
+const IsolationWorktree = "worktree"
+
 type SpawnStartRequest struct {
 	Agent       string
 	Prompt      string
@@ -76,7 +90,9 @@ type SpawnStartRequest struct {
 	Yield       time.Duration
 	Timeout     time.Duration
 	IdleTimeout time.Duration
-	Kind        Kind // defaults to KindSpawn if empty
+	Kind        Kind   // defaults to KindSpawn if empty
+	Isolation   string // empty or IsolationWorktree
+	Budget      Budget
 }
 
 type ControlRequest struct {
@@ -94,6 +110,76 @@ type Manager interface {
 	List(context.Context) ([]Snapshot, error)
 }
 
+// SpawnBatchRequest starts several SPAWN children at once. At most
+// MaxConcurrency of them run at the same time; the rest stay queued until a
+// running child stops.
+type SpawnBatchRequest struct {
+	Children       []SpawnStartRequest
+	MaxConcurrency int
+	Yield          time.Duration
+}
+
+// WaitMode selects when a multi-task wait returns.
+type WaitMode string
+
+const (
+	WaitAny WaitMode = "any"
+	WaitAll WaitMode = "all"
+)
+
+type WaitTasksRequest struct {
+	TaskIDs []string
+	Mode    WaitMode
+	Yield   time.Duration
+}
+
+// BatchManager is implemented by managers that can fan out SPAWN children and
+// wait on several tasks at once.
+type BatchManager interface {
+	StartSpawnBatch(context.Context, SpawnBatchRequest) ([]Snapshot, error)
+	WaitTasks(context.Context, WaitTasksRequest) ([]Snapshot, error)
+}
+
+// WorktreeAction resolves the worktree of an isolated SPAWN task.
+type WorktreeAction string
+
+const (
+	WorktreeActionDiff    WorktreeAction = "diff"
+	WorktreeActionApply   WorktreeAction = "apply"
+	WorktreeActionDiscard WorktreeAction = "discard"
+)
+
+type WorktreeRequest struct {
+	TaskID string
+	Action WorktreeAction
+}
+
+type worktreeApplyBlockedKey struct{}
+
+// WithWorktreeApplyBlocked marks a turn that must not merge worktree changes
+// into the working tree, such as a plan-mode turn. reason says why.
+func WithWorktreeApplyBlocked(ctx context.Context, reason string) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, worktreeApplyBlockedKey{}, reason)
+}
+
+// WorktreeApplyBlocked returns the reason set by WithWorktreeApplyBlocked.
+func WorktreeApplyBlocked(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+	reason, ok := ctx.Value(worktreeApplyBlockedKey{}).(string)
+	return reason, ok
+}
+
+// WorktreeManager is implemented by managers that can run SPAWN children in
+// isolated git worktrees and merge their changes back.
+type WorktreeManager interface {
+	ResolveWorktree(context.Context, WorktreeRequest) (Snapshot, error)
+}
+
 type SessionRef struct {
 	AppName   string
 	UserID    string
@@ -165,6 +251,18 @@ func (r *Record) WithLock(fn func(*Record)) {
 	fn(r)
 }
 
+// Controller returns the record's backend. Use it instead of reading Backend
+// once the record is shared: a queued batch child swaps its backend when it
+// starts.
+func (r *Record) Controller() Controller {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.Backend
+}
+
 func (r *Record) snapshotLocked(output Output) Snapshot {
 	return Snapshot{
 		TaskID:         r.ID,
@@ -0,0 +1,320 @@
+package taskruntime
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/OnslaughtSnail/caelis/kernel/task"
+)
+
+const (
+	// DefaultSpawnBatchConcurrency is how many batch children run at once
+	// when the request does not set a limit.
+	DefaultSpawnBatchConcurrency = 4
+	// MaxSpawnBatchSize caps the number of children in one batch.
+	MaxSpawnBatchSize = 16
+
+	spawnSlotPollInterval = 500 * time.Millisecond
+	waitTasksPollInterval = 150 * time.Millisecond
+)
+
+// StartSpawnBatch reserves a task for every child, starts up to
+// MaxConcurrency of them and queues the rest; a queued child starts as soon as
+// a running one stops. It then waits up to req.Yield for all of them.
+func (m *Manager) StartSpawnBatch(ctx context.Context, req task.SpawnBatchRequest) ([]task.Snapshot, error) {
+	if len(req.Children) == 0 {
+		return nil, fmt.Errorf("task: spawn batch requires at least one child")
+	}
+	if len(req.Children) > MaxSpawnBatchSize {
+		return nil, fmt.Errorf("task: spawn batch accepts at most %d children, got %d", MaxSpawnBatchSize, len(req.Children))
+	}
+	children := make([]task.SpawnStartRequest, len(req.Children))
+	kinds := make([]task.Kind, len(req.Children))
+	for i, child := range req.Children {
+		child.Yield = 0
+		prepared, kind, err := m.prepareSpawn(child)
+		if err != nil {
+			return nil, fmt.Errorf("task: child %d: %w", i+1, err)
+		}
+		children[i], kinds[i] = prepared, kind
+	}
+	limit := req.MaxConcurrency
+	if limit <= 0 {
+		limit = DefaultSpawnBatchConcurrency
+	}
+	limit = min(limit, len(children))
+
+	slots := make(chan struct{}, limit)
+	records := make([]*task.Record, len(children))
+	taskIDs := make([]string, len(children))
+	for i, child := range children {
+		record := m.registry.Create(kinds[i], child.Prompt, nil, true, true)
+		record.CleanupOnTurnEnd = false
+		record.HeartbeatAt = time.Now()
+		records[i], taskIDs[i] = record, record.ID
+	}
+	for i := limit; i < len(records); i++ {
+		if err := m.queueSpawn(ctx, records[i], children[i]); err != nil {
+			return nil, err
+		}
+	}
+	for i := range limit {
+		slots <- struct{}{}
+		m.launchBatchSpawn(ctx, slots, records[i], children[i], kinds[i])
+	}
+	if len(records) > limit {
+		go m.drainSpawnQueue(ctx, slots, records[limit:], children[limit:], kinds[limit:])
+	}
+	return m.WaitTasks(ctx, task.WaitTasksRequest{TaskIDs: taskIDs, Mode: task.WaitAll, Yield: req.Yield})
+}
+
+// WaitTasks polls several tasks until any or all of them stop, or until
+// req.Yield elapses. Child output seen while waiting is accumulated per task.
+func (m *Manager) WaitTasks(ctx context.Context, req task.WaitTasksRequest) ([]task.Snapshot, error) {
+	mode := req.Mode
+	switch mode {
+	case "":
+		mode = task.WaitAll
+	case task.WaitAny, task.WaitAll:
+	default:
+		return nil, fmt.Errorf("task: unsupported wait mode %q", req.Mode)
+	}
+	taskIDs := make([]string, 0, len(req.TaskIDs))
+	seen := map[string]struct{}{}
+	for _, taskID := range req.TaskIDs {
+		taskID = strings.TrimSpace(taskID)
+		if taskID == "" {
+			continue
+		}
+		if _, ok := seen[taskID]; ok {
+			continue
+		}
+		seen[taskID] = struct{}{}
+		taskIDs = append(taskIDs, taskID)
+	}
+	if len(taskIDs) == 0 {
+		return nil, fmt.Errorf("task: at least one task id is required")
+	}
+	deadline := time.Time{}
+	if req.Yield > 0 {
+		deadline = time.Now().Add(req.Yield)
+	}
+	snapshots := make([]task.Snapshot, len(taskIDs))
+	logs := make([]string, len(taskIDs))
+	stopped := make([]bool, len(taskIDs))
+	for {
+		finished := 0
+		for i, taskID := range taskIDs {
+			if stopped[i] {
+				finished++
+				continue
+			}
+			snapshot, err := m.Wait(ctx, task.ControlRequest{TaskID: taskID})
+			if err != nil {
+				return nil, err
+			}
+			logs[i] += snapshot.Output.Log
+			snapshot.Output.Log = logs[i]
+			snapshots[i] = snapshot
+			if !snapshot.Running {
+				stopped[i] = true
+				finished++
+			}
+		}
+		if finished == len(taskIDs) || (mode == task.WaitAny && finished > 0) {
+			return snapshots, nil
+		}
+		if deadline.IsZero() || time.Now().After(deadline) {
+			return snapshots, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(waitTasksPollInterval):
+		}
+	}
+}
+
+// queueSpawn records a batch child that waits for a free slot.
+func (m *Manager) queueSpawn(ctx context.Context, record *task.Record, req task.SpawnStartRequest) error {
+	m.TrackTurnTask(record.ID)
+	record.WithLock(func(one *task.Record) {
+		one.Backend = &queuedSpawnController{store: m.store}
+		one.State = task.StateQueued
+		if m.session != nil {
+			one.Session = *m.session
+		}
+		one.Spec = map[string]any{
+			SpecPrompt: req.Prompt,
+			SpecAgent:  req.Agent,
+		}
+		one.Result = map[string]any{
+			"agent":          req.Agent,
+			"progress_state": string(task.StateQueued),
+		}
+	})
+	return m.persistRecord(ctx, record)
+}
+
+// drainSpawnQueue starts queued children as slots free up. Started children
+// outlive ctx, but once ctx is done the children still queued are cancelled.
+func (m *Manager) drainSpawnQueue(ctx context.Context, slots chan struct{}, records []*task.Record, children []task.SpawnStartRequest, kinds []task.Kind) {
+	for i, record := range records {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			m.cancelQueuedSpawns(context.WithoutCancel(ctx), records[i:])
+			return
+		}
+		if ctx.Err() != nil {
+			<-slots
+			m.cancelQueuedSpawns(context.WithoutCancel(ctx), records[i:])
+			return
+		}
+		claimed := false
+		record.WithLock(func(one *task.Record) {
+			if one.State == task.StateQueued {
+				one.State = task.StateRunning
+				claimed = true
+			}
+		})
+		if !claimed {
+			<-slots
+			continue
+		}
+		m.launchBatchSpawn(context.WithoutCancel(ctx), slots, record, children[i], kinds[i])
+	}
+}
+
+// cancelQueuedSpawns cancels the children among records that never left the
+// queue.
+func (m *Manager) cancelQueuedSpawns(ctx context.Context, records []*task.Record) {
+	for _, record := range records {
+		cancelled := false
+		record.WithLock(func(one *task.Record) {
+			if one.State != task.StateQueued {
+				return
+			}
+			one.State = task.StateCancelled
+			one.Running = false
+			one.UpdatedAt = time.Now()
+			if one.Result == nil {
+				one.Result = map[string]any{}
+			}
+			one.Result["progress_state"] = string(task.StateCancelled)
+			one.Result["error"] = "the parent turn ended before the child started"
+			cancelled = true
+		})
+		if cancelled {
+			_ = m.persistRecord(ctx, record)
+		}
+	}
+}
+
+// launchBatchSpawn starts one batch child in a slot that the caller already
+// holds. The slot is released when the child stops or fails to start.
+func (m *Manager) launchBatchSpawn(ctx context.Context, slots chan struct{}, record *task.Record, req task.SpawnStartRequest, kind task.Kind) {
+	controller, _, err := m.launchSpawn(ctx, record, req, kind)
+	if err != nil {
+		<-slots
+		// The queued controller stays in place: it reports the failed state.
+		m.registry.Put(record)
+		record.WithLock(func(one *task.Record) {
+			one.State = task.StateFailed
+			one.Running = false
+			one.UpdatedAt = time.Now()
+			one.Result = map[string]any{
+				"agent":          req.Agent,
+				"progress_state": string(task.StateFailed),
+				"error":          err.Error(),
+			}
+		})
+		_ = m.persistRecord(ctx, record)
+		return
+	}
+	go func() {
+		defer func() { <-slots }()
+		watchSpawnSlot(context.WithoutCancel(ctx), controller, record)
+	}()
+}
+
+// watchSpawnSlot blocks until the child stops, without consuming the output
+// that TASK wait reports to the model.
+func watchSpawnSlot(ctx context.Context, controller *SubagentTaskController, record *task.Record) {
+	started := time.Now()
+	for {
+		running := false
+		record.WithLock(func(one *task.Record) {
+			running = one.Running
+		})
+		if running {
+			result, err := controller.Runner.InspectSubagent(ctx, controller.SessionID)
+			switch {
+			case err == nil:
+				running = result.Running
+			case isMissingSubagentStateErr(err):
+				running = time.Since(started) <= subagentMissingStateGrace
+			}
+		}
+		if !running {
+			return
+		}
+		time.Sleep(spawnSlotPollInterval)
+	}
+}
+
+// queuedSpawnController stands in for a batch child that is waiting for a
+// free slot. Once the child starts, the record switches to its real controller.
+type queuedSpawnController struct {
+	store task.Store
+}
+
+func (c *queuedSpawnController) Wait(ctx context.Context, record *task.Record, yield time.Duration) (task.Snapshot, error) {
+	deadline := time.Now().Add(yield)
+	for {
+		if backend := record.Controller(); backend != nil && backend != task.Controller(c) {
+			return backend.Wait(ctx, record, max(time.Until(deadline), 0))
+		}
+		snapshot := record.Snapshot(task.Output{})
+		if snapshot.State != task.StateQueued || !time.Now().Before(deadline) {
+			return snapshot, nil
+		}
+		select {
+		case <-ctx.Done():
+			return task.Snapshot{}, ctx.Err()
+		case <-time.After(waitTasksPollInterval):
+		}
+	}
+}
+
+func (c *queuedSpawnController) Write(context.Context, *task.Record, string, time.Duration) (task.Snapshot, error) {
+	return task.Snapshot{}, fmt.Errorf("task: spawn child is still queued; use TASK wait until it starts")
+}
+
+func (c *queuedSpawnController) Cancel(ctx context.Context, record *task.Record) (task.Snapshot, error) {
+	var (
+		snapshot task.Snapshot
+		claimed  bool
+	)
+	record.WithLock(func(one *task.Record) {
+		if one.State != task.StateQueued {
+			claimed = true
+			return
+		}
+		one.State = task.StateCancelled
+		one.Running = false
+		one.UpdatedAt = time.Now()
+		if one.Result == nil {
+			one.Result = map[string]any{}
+		}
+		one.Result["progress_state"] = string(task.StateCancelled)
+		snapshot = one.LockedSnapshot(task.Output{})
+	})
+	if claimed {
+		return task.Snapshot{}, fmt.Errorf("task: spawn child is starting; cancel it again once it is running")
+	}
+	_ = persistControllerRecord(ctx, c.store, record)
+	return snapshot, nil
+}
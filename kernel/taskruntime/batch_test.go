@@ -0,0 +1,218 @@
+package taskruntime
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/OnslaughtSnail/caelis/kernel/agent"
+	"github.com/OnslaughtSnail/caelis/kernel/task"
+)
+
+// batchSubagentRunner keeps every child running until finish is called for it
+// and records how many children ran at the same time.
+type batchSubagentRunner struct {
+	mu         sync.Mutex
+	running    map[string]bool
+	prompts    map[string]string
+	maxRunning int
+}
+
+func newBatchSubagentRunner() *batchSubagentRunner {
+	return &batchSubagentRunner{running: map[string]bool{}, prompts: map[string]string{}}
+}
+
+func (r *batchSubagentRunner) RunSubagent(_ context.Context, req agent.SubagentRunRequest) (agent.SubagentRunResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sessionID := fmt.Sprintf("child-%d", len(r.prompts)+1)
+	r.prompts[sessionID] = req.Prompt
+	r.running[sessionID] = true
+	active := 0
+	for _, running := range r.running {
+		if running {
+			active++
+		}
+	}
+	r.maxRunning = max(r.maxRunning, active)
+	return agent.SubagentRunResult{SessionID: sessionID, Agent: req.Agent, State: string(task.StateRunning), Running: true}, nil
+}
+
+func (r *batchSubagentRunner) InspectSubagent(_ context.Context, sessionID string) (agent.SubagentRunResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.running[sessionID] {
+		return agent.SubagentRunResult{SessionID: sessionID, State: string(task.StateRunning), Running: true}, nil
+	}
+	return agent.SubagentRunResult{SessionID: sessionID, State: string(task.StateCompleted), Assistant: "done " + r.prompts[sessionID]}, nil
+}
+
+func (r *batchSubagentRunner) finishAll() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	finished := 0
+	for sessionID, running := range r.running {
+		if running {
+			r.running[sessionID] = false
+			finished++
+		}
+	}
+	return finished
+}
+
+func TestStartSpawnBatchQueuesChildrenBeyondConcurrencyLimit(t *testing.T) {
+	runner := newBatchSubagentRunner()
+	manager := New(Config{Subagents: runner})
+	ctx := context.Background()
+
+	snapshots, err := manager.StartSpawnBatch(ctx, task.SpawnBatchRequest{
+		Children: []task.SpawnStartRequest{
+			{Prompt: "a"}, {Prompt: "b", Agent: "reviewer"}, {Prompt: "c"},
+		},
+		MaxConcurrency: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 3 {
+		t.Fatalf("expected three snapshots, got %+v", snapshots)
+	}
+	if snapshots[0].State != task.StateRunning || snapshots[1].State != task.StateQueued || snapshots[2].State != task.StateQueued {
+		t.Fatalf("expected one running and two queued children, got %s %s %s", snapshots[0].State, snapshots[1].State, snapshots[2].State)
+	}
+	taskIDs := []string{snapshots[0].TaskID, snapshots[1].TaskID, snapshots[2].TaskID}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for finished := 0; finished < 3 && time.Now().Before(deadline); {
+		finished += runner.finishAll()
+		time.Sleep(50 * time.Millisecond)
+	}
+	results, err := manager.WaitTasks(ctx, task.WaitTasksRequest{TaskIDs: taskIDs, Mode: task.WaitAll, Yield: 5 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, result := range results {
+		if result.Running || result.State != task.StateCompleted {
+			t.Fatalf("expected child %d to complete, got %+v", i, result)
+		}
+	}
+	if results[1].Result["final_result"] != "done b" {
+		t.Fatalf("expected the second child's result, got %+v", results[1].Result)
+	}
+	if runner.maxRunning != 1 {
+		t.Fatalf("expected at most one child to run at once, got %d", runner.maxRunning)
+	}
+}
+
+// Run with -race: the drain goroutine swaps queued controllers while TASK
+// wait reads them.
+func TestSpawnQueueDrainsWhileWaiting(t *testing.T) {
+	runner := newBatchSubagentRunner()
+	manager := New(Config{Subagents: runner})
+	ctx := context.Background()
+
+	snapshots, err := manager.StartSpawnBatch(ctx, task.SpawnBatchRequest{
+		Children:       []task.SpawnStartRequest{{Prompt: "a"}, {Prompt: "b"}, {Prompt: "c"}, {Prompt: "d"}},
+		MaxConcurrency: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for finished := 0; finished < len(snapshots); {
+			finished += runner.finishAll()
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+	deadline := time.Now().Add(10 * time.Second)
+	for _, snapshot := range snapshots {
+		for {
+			got, err := manager.Wait(ctx, task.ControlRequest{TaskID: snapshot.TaskID, Yield: 20 * time.Millisecond})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.State == task.StateCompleted {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("expected %s to complete, got %+v", snapshot.TaskID, got)
+			}
+		}
+	}
+	<-done
+}
+
+func TestSpawnQueueStopsWhenParentContextEnds(t *testing.T) {
+	runner := newBatchSubagentRunner()
+	manager := New(Config{Subagents: runner})
+	ctx, cancel := context.WithCancel(context.Background())
+
+	snapshots, err := manager.StartSpawnBatch(ctx, task.SpawnBatchRequest{
+		Children:       []task.SpawnStartRequest{{Prompt: "a"}, {Prompt: "b"}},
+		MaxConcurrency: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	runner.finishAll()
+
+	bg := context.Background()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		got, err := manager.Wait(bg, task.ControlRequest{TaskID: snapshots[1].TaskID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.State == task.StateCancelled {
+			break
+		}
+		if got.State != task.StateQueued || time.Now().After(deadline) {
+			t.Fatalf("expected the queued child to be cancelled, got %+v", got)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if len(runner.prompts) != 1 {
+		t.Fatalf("expected only the first child to start, got %v", runner.prompts)
+	}
+}
+
+func TestWaitTasksAnyReturnsWhenOneTaskStops(t *testing.T) {
+	runner := newBatchSubagentRunner()
+	manager := New(Config{Subagents: runner})
+	ctx := context.Background()
+
+	snapshots, err := manager.StartSpawnBatch(ctx, task.SpawnBatchRequest{
+		Children: []task.SpawnStartRequest{{Prompt: "a"}, {Prompt: "b"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	runner.mu.Lock()
+	runner.running["child-1"] = false
+	runner.mu.Unlock()
+
+	results, err := manager.WaitTasks(ctx, task.WaitTasksRequest{
+		TaskIDs: []string{snapshots[0].TaskID, snapshots[1].TaskID},
+		Mode:    task.WaitAny,
+		Yield:   5 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].Running || !results[1].Running {
+		t.Fatalf("expected only the first child to have stopped, got %+v", results)
+	}
+
+	cancelled, err := manager.Cancel(ctx, task.ControlRequest{TaskID: snapshots[1].TaskID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cancelled.State != task.StateCancelled {
+		t.Fatalf("expected the second child to be cancelled, got %+v", cancelled)
+	}
+}
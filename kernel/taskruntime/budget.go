@@ -0,0 +1,40 @@
+package taskruntime
+
+import (
+	"time"
+
+	"github.com/OnslaughtSnail/caelis/kernel/task"
+)
+
+// BudgetSpec returns the spec entries that persist a child's budget. Unset
+// limits are left out.
+func BudgetSpec(budget task.Budget) map[string]any {
+	spec := map[string]any{}
+	if budget.MaxSteps > 0 {
+		spec[SpecBudgetMaxSteps] = budget.MaxSteps
+	}
+	if budget.MaxTokens > 0 {
+		spec[SpecBudgetMaxTokens] = budget.MaxTokens
+	}
+	if budget.MaxToolCalls > 0 {
+		spec[SpecBudgetMaxToolCalls] = budget.MaxToolCalls
+	}
+	if budget.MaxCostUSD > 0 {
+		spec[SpecBudgetMaxCostUSD] = budget.MaxCostUSD
+	}
+	if budget.MaxWallTime > 0 {
+		spec[SpecBudgetMaxWallTime] = int(budget.MaxWallTime / time.Second)
+	}
+	return spec
+}
+
+// BudgetFromSpec restores the budget that BudgetSpec persisted.
+func BudgetFromSpec(spec map[string]any) task.Budget {
+	return task.Budget{
+		MaxSteps:     IntValue(spec, SpecBudgetMaxSteps),
+		MaxTokens:    IntValue(spec, SpecBudgetMaxTokens),
+		MaxToolCalls: IntValue(spec, SpecBudgetMaxToolCalls),
+		MaxCostUSD:   FloatValue(spec, SpecBudgetMaxCostUSD),
+		MaxWallTime:  time.Duration(IntValue(spec, SpecBudgetMaxWallTime)) * time.Second,
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	toolexec "github.com/OnslaughtSnail/caelis/kernel/execenv"
@@ -26,6 +28,10 @@ type BashTaskController struct {
 	Route   string
 	Backend string
 	Store   task.Store
+
+	// DeniedHosts lists the hosts the sandbox network proxy has refused the
+	// command so far. Snapshots report them as network_denied_hosts.
+	DeniedHosts func() []string
 }
 
 func (c *BashTaskController) Wait(ctx context.Context, record *task.Record, yield time.Duration) (task.Snapshot, error) {
@@ -129,6 +135,10 @@ func (c *BashTaskController) Wait(ctx context.Context, record *task.Record, yiel
 			if text := strings.TrimSpace(status.Error); text != "" {
 				one.Result["error"] = text
 			}
+			if status.LimitExceeded != "" {
+				one.Result["limit_exceeded"] = status.LimitExceeded
+			}
+			c.appendDeniedHosts(one.Result)
 			if one.Running {
 				output.Stdout += string(stdout)
 				output.Stderr += string(stderr)
@@ -288,12 +298,44 @@ func (c *BashTaskController) Cancel(ctx context.Context, record *task.Record) (t
 		if preview != "" {
 			one.Result["latest_output"] = preview
 		}
+		c.appendDeniedHosts(one.Result)
 		snapshot = one.LockedSnapshot(task.Output{})
 	})
 	_ = persistControllerRecord(ctx, c.Store, record)
 	return snapshot, nil
 }
 
+func (c *BashTaskController) appendDeniedHosts(result map[string]any) {
+	if c.DeniedHosts == nil {
+		return
+	}
+	if hosts := c.DeniedHosts(); len(hosts) > 0 {
+		result["network_denied_hosts"] = hosts
+	}
+}
+
+// networkDenials collects the hosts the sandbox network proxy refused a
+// command the manager started.
+type networkDenials struct {
+	mu    sync.Mutex
+	hosts []string
+}
+
+func (d *networkDenials) add(host string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if slices.Contains(d.hosts, host) {
+		return
+	}
+	d.hosts = append(d.hosts, host)
+}
+
+func (d *networkDenials) list() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.hosts...)
+}
+
 func (c *BashTaskController) previewOutput() (string, error) {
 	if c == nil || c.Session == nil {
 		return "", nil
@@ -0,0 +1,81 @@
+package taskruntime
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	toolexec "github.com/OnslaughtSnail/caelis/kernel/execenv"
+	"github.com/OnslaughtSnail/caelis/kernel/task"
+)
+
+// stubBashSession is a started command the test finishes by hand.
+type stubBashSession struct {
+	mu   sync.Mutex
+	done bool
+}
+
+func (s *stubBashSession) Ref() toolexec.CommandSessionRef {
+	return toolexec.CommandSessionRef{Backend: "host", SessionID: "shell-1"}
+}
+
+func (s *stubBashSession) WriteInput([]byte) error { return nil }
+
+func (s *stubBashSession) ReadOutput(stdoutMarker, stderrMarker int64) ([]byte, []byte, int64, int64, error) {
+	return nil, nil, stdoutMarker, stderrMarker, nil
+}
+
+func (s *stubBashSession) Status() (toolexec.SessionStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.done {
+		return toolexec.SessionStatus{State: toolexec.SessionStateCompleted}, nil
+	}
+	return toolexec.SessionStatus{State: toolexec.SessionStateRunning}, nil
+}
+
+func (s *stubBashSession) Wait(context.Context, time.Duration) (toolexec.CommandResult, error) {
+	return toolexec.CommandResult{}, nil
+}
+
+func (s *stubBashSession) Terminate() error {
+	s.finish()
+	return nil
+}
+
+func (s *stubBashSession) finish() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.done = true
+}
+
+func TestStartBashReportsHostsDeniedAfterTheYield(t *testing.T) {
+	manager := New(Config{})
+	ctx := context.Background()
+	shell := &stubBashSession{}
+	var denials networkDenials
+
+	started, err := manager.StartBash(ctx, task.BashStartRequest{
+		Command: "go mod download",
+		Session: shell,
+
+		NetworkDeniedHosts: denials.list,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := started.Result["network_denied_hosts"]; ok {
+		t.Fatalf("expected no denied hosts before any were refused, got %#v", started.Result)
+	}
+
+	denials.add("proxy.golang.org")
+	shell.finish()
+	waited, err := manager.Wait(ctx, task.ControlRequest{TaskID: started.TaskID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := waited.Result["network_denied_hosts"].([]string); len(got) != 1 || got[0] != "proxy.golang.org" {
+		t.Fatalf("expected the task snapshot to report the denied host, got %#v", waited.Result)
+	}
+}
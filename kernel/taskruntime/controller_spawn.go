@@ -4,6 +4,7 @@ import (
 	"cmp"
 	"context"
 	"fmt"
+	"maps"
 	"strings"
 	"time"
 
@@ -24,6 +25,7 @@ type SubagentTaskController struct {
 	Agent                  string
 	ChildCWD               string
 	IdleTimeout            time.Duration
+	Budget                 task.Budget
 	ContinuationAnchorTool string
 }
 
@@ -72,6 +74,13 @@ func (c *SubagentTaskController) Write(ctx context.Context, record *task.Record,
 	if input == "" {
 		return task.Snapshot{}, fmt.Errorf("task: input is required")
 	}
+	var worktreeStatus string
+	record.WithLock(func(one *task.Record) {
+		worktreeStatus = StringValue(one.Spec, SpecWorktreeStatus)
+	})
+	if worktreeStatus != "" && worktreeStatus != WorktreeStatusPending {
+		return task.Snapshot{}, fmt.Errorf("task: cannot continue a spawn subagent whose worktree is %s", worktreeStatus)
+	}
 	current, err := c.inspect(ctx, record, false)
 	if err != nil {
 		return task.Snapshot{}, err
@@ -92,6 +101,7 @@ func (c *SubagentTaskController) Write(ctx context.Context, record *task.Record,
 		ChildCWD:    c.ChildCWD,
 		Yield:       yield,
 		IdleTimeout: c.IdleTimeout,
+		Budget:      c.Budget,
 	})
 	if err != nil {
 		return task.Snapshot{}, err
@@ -157,6 +167,7 @@ func (c *SubagentTaskController) Cancel(ctx context.Context, record *task.Record
 			"child_cwd":        c.ChildCWD,
 			"progress_state":   string(task.StateCancelled),
 		}
+		AddWorktreeResult(one.Result, one.Spec)
 		if callID := strings.TrimSpace(StringValue(one.Spec, SpecParentToolCall)); callID != "" {
 			one.Result["_ui_parent_tool_call_id"] = callID
 		}
@@ -223,6 +234,10 @@ func (c *SubagentTaskController) inspect(ctx context.Context, record *task.Recor
 		assistant = final
 	}
 	errorReason := SubagentErrorReason(runResult)
+	diffStat := ""
+	if !runResult.Running {
+		diffStat = pendingWorktreeDiffStat(ctx, record)
+	}
 	record.WithLock(func(one *task.Record) {
 		if preview == "" {
 			preview = task.FormatLatestOutput(fmt.Sprint(one.Result["latest_output"]))
@@ -278,6 +293,10 @@ func (c *SubagentTaskController) inspect(ctx context.Context, record *task.Recor
 		if c.IdleTimeout > 0 {
 			one.Result["_ui_idle_timeout_seconds"] = int(c.IdleTimeout / time.Second)
 		}
+		AddWorktreeResult(one.Result, one.Spec)
+		if diffStat != "" {
+			one.Result["worktree_diff_stat"] = diffStat
+		}
 		if preview != "" {
 			one.Result["latest_output"] = preview
 		}
@@ -311,6 +330,24 @@ func (c *SubagentTaskController) inspect(ctx context.Context, record *task.Recor
 	return snapshot, nil
 }
 
+// pendingWorktreeDiffStat summarizes the changes a stopped child left in its
+// worktree so the parent can decide whether to apply or discard them.
+func pendingWorktreeDiffStat(ctx context.Context, record *task.Record) string {
+	var spec map[string]any
+	record.WithLock(func(one *task.Record) {
+		spec = maps.Clone(one.Spec)
+	})
+	wt, ok := WorktreeFromSpec(spec)
+	if !ok || StringValue(spec, SpecWorktreeStatus) != WorktreeStatusPending || !wt.Exists() {
+		return ""
+	}
+	diff, err := wt.Diff(ctx)
+	if err != nil {
+		return ""
+	}
+	return cmp.Or(diff.Stat, "no changes")
+}
+
 func SubagentErrorReason(runResult agent.SubagentRunResult) string {
 	errText := strings.ToLower(strings.TrimSpace(runResult.Error))
 	switch {
@@ -318,6 +355,8 @@ func SubagentErrorReason(runResult agent.SubagentRunResult) string {
 		return ""
 	case strings.Contains(errText, "idle timeout exceeded"):
 		return "runner_idle_timeout"
+	case strings.Contains(errText, "budget exhausted"):
+		return "budget_exhausted"
 	case strings.Contains(errText, "context deadline exceeded"), strings.Contains(errText, "deadline exceeded"), strings.Contains(errText, "timed out"), strings.Contains(errText, "timeout"):
 		return "child_timeout"
 	case strings.Contains(errText, "context canceled"), strings.Contains(errText, "cancelled"), strings.Contains(errText, "canceled"):
@@ -377,6 +416,8 @@ func RuntimeTaskStateName(status string) task.State {
 		return task.StateCancelled
 	case string(task.StateWaitingInput):
 		return task.StateWaitingInput
+	case string(task.StateTerminated):
+		return task.StateTerminated
 	default:
 		return task.StateRunning
 	}
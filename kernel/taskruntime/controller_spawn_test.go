@@ -3,6 +3,7 @@ package taskruntime
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/OnslaughtSnail/caelis/kernel/agent"
 	"github.com/OnslaughtSnail/caelis/kernel/task"
@@ -31,3 +32,62 @@ func TestSubagentTaskControllerWriteRejectsNilContextBeforeInspection(t *testing
 		t.Fatalf("expected context required error, got %v", err)
 	}
 }
+
+type terminatedSubagentRunner struct {
+	budget task.Budget
+}
+
+func (r *terminatedSubagentRunner) RunSubagent(_ context.Context, req agent.SubagentRunRequest) (agent.SubagentRunResult, error) {
+	r.budget = req.Budget
+	return agent.SubagentRunResult{SessionID: "child-1", Agent: req.Agent, State: string(task.StateRunning), Running: true}, nil
+}
+
+func (r *terminatedSubagentRunner) InspectSubagent(_ context.Context, sessionID string) (agent.SubagentRunResult, error) {
+	return agent.SubagentRunResult{
+		SessionID: sessionID,
+		State:     string(task.StateTerminated),
+		Error:     "runtime: budget exhausted: model step limit of 3 reached",
+		Assistant: "partial findings",
+	}, nil
+}
+
+func TestStartSpawnReportsBudgetExhaustedChildAsTerminated(t *testing.T) {
+	runner := &terminatedSubagentRunner{}
+	manager := New(Config{Subagents: runner})
+	ctx := context.Background()
+	budget := task.Budget{MaxSteps: 3, MaxToolCalls: 10}
+
+	started, err := manager.StartSpawn(ctx, task.SpawnStartRequest{Prompt: "explore", Budget: budget})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runner.budget != budget {
+		t.Fatalf("expected the budget to reach the runner, got %+v", runner.budget)
+	}
+	snapshot, err := manager.Wait(ctx, task.ControlRequest{TaskID: started.TaskID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.Running || snapshot.State != task.StateTerminated {
+		t.Fatalf("expected a terminated snapshot, got %+v", snapshot)
+	}
+	if got := snapshot.Result["error_reason"]; got != "budget_exhausted" {
+		t.Fatalf("expected budget_exhausted reason, got %v", got)
+	}
+	if got := snapshot.Result["final_result"]; got != "partial findings" {
+		t.Fatalf("expected the child's last output, got %v", got)
+	}
+	if _, err := manager.Write(ctx, task.ControlRequest{TaskID: started.TaskID, Input: "more"}); err == nil {
+		t.Fatal("expected a terminated child to reject TASK write")
+	}
+}
+
+func TestBudgetSpecRoundTrips(t *testing.T) {
+	budget := task.Budget{MaxSteps: 5, MaxTokens: 1000, MaxToolCalls: 7, MaxCostUSD: 0.25, MaxWallTime: 90 * time.Second}
+	if got := BudgetFromSpec(BudgetSpec(budget)); got != budget {
+		t.Fatalf("expected %+v, got %+v", budget, got)
+	}
+	if spec := BudgetSpec(task.Budget{}); len(spec) != 0 {
+		t.Fatalf("expected no spec entries for a zero budget, got %v", spec)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"cmp"
 	"context"
 	"fmt"
+	"maps"
 	"slices"
 	"strings"
 	"sync"
@@ -34,6 +35,12 @@ const (
 	SpecParentToolName = "parent_tool_name"
 	SpecUISpawnID      = "ui_spawn_id"
 	SpecUIAnchorTool   = "ui_anchor_tool"
+
+	SpecBudgetMaxSteps     = "budget_max_steps"
+	SpecBudgetMaxTokens    = "budget_max_tokens"
+	SpecBudgetMaxToolCalls = "budget_max_tool_calls"
+	SpecBudgetMaxCostUSD   = "budget_max_cost_usd"
+	SpecBudgetMaxWallTime  = "budget_max_wall_time_seconds"
 )
 
 type Config struct {
@@ -87,19 +94,34 @@ func (m *Manager) StartBash(ctx context.Context, req task.BashStartRequest) (tas
 	if req.Yield < 0 {
 		req.Yield = 0
 	}
-	sessionRef, err := m.execenv.Start(ctx, toolexec.CommandRequest{
-		Command:               req.Command,
-		Dir:                   req.Workdir,
-		Timeout:               req.Timeout,
-		IdleTimeout:           req.IdleTimeout,
-		TTY:                   req.TTY,
-		RouteHint:             toolexec.ExecutionRoute(strings.TrimSpace(req.Route)),
-		BackendName:           strings.TrimSpace(req.Backend),
-		EnvOverrides:          req.EnvOverrides,
-		SandboxPolicyOverride: req.SandboxPolicyOverride,
-	})
-	if err != nil {
-		return task.Snapshot{}, err
+	sessionRef := req.Session
+	deniedHosts := req.NetworkDeniedHosts
+	if sessionRef == nil {
+		denied := &networkDenials{}
+		onDenied := func(host string) {
+			denied.add(host)
+			if req.OnNetworkDenied != nil {
+				req.OnNetworkDenied(host)
+			}
+		}
+		deniedHosts = denied.list
+		started, err := m.execenv.Start(ctx, toolexec.CommandRequest{
+			Command:               req.Command,
+			Dir:                   req.Workdir,
+			Timeout:               req.Timeout,
+			IdleTimeout:           req.IdleTimeout,
+			TTY:                   req.TTY,
+			RouteHint:             toolexec.ExecutionRoute(strings.TrimSpace(req.Route)),
+			BackendName:           strings.TrimSpace(req.Backend),
+			EnvOverrides:          req.EnvOverrides,
+			SandboxPolicyOverride: req.SandboxPolicyOverride,
+			OnNetworkDenied:       onDenied,
+			Limits:                req.Limits,
+		})
+		if err != nil {
+			return task.Snapshot{}, err
+		}
+		sessionRef = started
 	}
 	controller := &BashTaskController{
 		Session: sessionRef,
@@ -109,6 +131,8 @@ func (m *Manager) StartBash(ctx context.Context, req task.BashStartRequest) (tas
 		Route:   strings.TrimSpace(req.Route),
 		Backend: strings.TrimSpace(sessionRef.Ref().Backend),
 		Store:   m.store,
+
+		DeniedHosts: deniedHosts,
 	}
 	record := m.registry.Create(task.KindBash, req.Command, controller, true, true)
 	m.TrackTurnTask(record.ID)
@@ -135,35 +159,89 @@ func (m *Manager) StartBash(ctx context.Context, req task.BashStartRequest) (tas
 }
 
 func (m *Manager) StartSpawn(ctx context.Context, req task.SpawnStartRequest) (task.Snapshot, error) {
+	req, kind, err := m.prepareSpawn(req)
+	if err != nil {
+		return task.Snapshot{}, err
+	}
+	record := m.registry.Create(kind, req.Prompt, nil, true, true)
+	record.CleanupOnTurnEnd = false
+	record.HeartbeatAt = time.Now()
+	controller, runResult, err := m.launchSpawn(ctx, record, req, kind)
+	if err != nil {
+		return task.Snapshot{}, err
+	}
+	snapshot, err := controller.Wait(ctx, record, 0)
+	if err != nil {
+		return task.Snapshot{}, err
+	}
+	snapshot.Yielded = runResult.Yielded || snapshot.Running
+	return snapshot, nil
+}
+
+// prepareSpawn validates a SPAWN request and fills in its defaults.
+func (m *Manager) prepareSpawn(req task.SpawnStartRequest) (task.SpawnStartRequest, task.Kind, error) {
 	if strings.TrimSpace(req.Prompt) == "" {
-		return task.Snapshot{}, fmt.Errorf("task: child prompt is required")
+		return req, "", fmt.Errorf("task: child prompt is required")
 	}
 	if m.subagents == nil {
-		return task.Snapshot{}, fmt.Errorf("task: child session runtime is unavailable")
+		return req, "", fmt.Errorf("task: child session runtime is unavailable")
 	}
-	agentName := cmp.Or(strings.TrimSpace(req.Agent), "self")
+	req.Agent = cmp.Or(strings.TrimSpace(req.Agent), "self")
 	if req.Yield < 0 {
 		req.Yield = 0
 	}
 	kind, err := normalizeSpawnTaskKind(req.Kind)
 	if err != nil {
-		return task.Snapshot{}, err
+		return req, "", err
+	}
+	switch isolation := strings.ToLower(strings.TrimSpace(req.Isolation)); isolation {
+	case "", "none":
+		req.Isolation = ""
+	case task.IsolationWorktree:
+		req.Isolation = isolation
+	default:
+		return req, "", fmt.Errorf("task: unsupported isolation %q", req.Isolation)
+	}
+	if err := req.Budget.Validate(); err != nil {
+		return req, "", err
+	}
+	return req, kind, nil
+}
+
+// launchSpawn starts the child session for a reserved SPAWN record and binds
+// the record to its controller. The record is removed from the registry when
+// the child cannot be started.
+func (m *Manager) launchSpawn(ctx context.Context, record *task.Record, req task.SpawnStartRequest, kind task.Kind) (*SubagentTaskController, agent.SubagentRunResult, error) {
+	var (
+		worktree Worktree
+		childCWD string
+		err      error
+	)
+	if req.Isolation == task.IsolationWorktree {
+		worktree, childCWD, err = m.createSpawnWorktree(ctx, record)
+		if err != nil {
+			m.registry.Delete(record.ID)
+			return nil, agent.SubagentRunResult{}, err
+		}
 	}
-	record := m.registry.Create(kind, req.Prompt, nil, true, true)
-	record.CleanupOnTurnEnd = false
-	record.HeartbeatAt = time.Now()
 	m.TrackTurnTask(record.ID)
 	runResult, err := m.subagents.RunSubagent(ctx, agent.SubagentRunRequest{
-		Agent:       agentName,
+		Agent:       req.Agent,
 		Prompt:      req.Prompt,
-		ChildCWD:    "",
+		ChildCWD:    childCWD,
 		Parts:       model.CloneParts(req.Parts),
 		Yield:       req.Yield,
 		Timeout:     req.Timeout,
 		IdleTimeout: req.IdleTimeout,
+		Budget:      req.Budget,
 	})
 	if err != nil {
-		return task.Snapshot{}, err
+		if req.Isolation == task.IsolationWorktree {
+			_ = worktree.Remove(context.WithoutCancel(ctx))
+			m.failSpawnWorktree(ctx, record, WorktreeStatusDiscarded, err)
+		}
+		m.registry.Delete(record.ID)
+		return nil, agent.SubagentRunResult{}, err
 	}
 	controller := &SubagentTaskController{
 		SessionID:              runResult.SessionID,
@@ -175,30 +253,37 @@ func (m *Manager) StartSpawn(ctx context.Context, req task.SpawnStartRequest) (t
 		Agent:                  runResult.Agent,
 		ChildCWD:               runResult.ChildCWD,
 		IdleTimeout:            runResult.IdleTimeout,
+		Budget:                 req.Budget,
 		ContinuationAnchorTool: m.continuationAnchorTool,
 	}
-	record.Backend = controller
-	if m.session != nil {
-		record.Session = *m.session
-	}
-	record.Spec = map[string]any{
-		SpecPrompt:       req.Prompt,
-		SpecChildSession: runResult.SessionID,
-		SpecDelegationID: runResult.DelegationID,
-		SpecAgent:        runResult.Agent,
-		SpecChildCWD:     runResult.ChildCWD,
-	}
-	if runResult.IdleTimeout > 0 {
-		record.Spec[SpecIdleTimeout] = int(runResult.IdleTimeout / time.Second)
-	} else if req.IdleTimeout > 0 {
-		record.Spec[SpecIdleTimeout] = int(req.IdleTimeout / time.Second)
-		controller.IdleTimeout = req.IdleTimeout
-	}
+	record.WithLock(func(one *task.Record) {
+		if m.session != nil {
+			one.Session = *m.session
+		}
+		one.Spec = map[string]any{
+			SpecPrompt:       req.Prompt,
+			SpecChildSession: runResult.SessionID,
+			SpecDelegationID: runResult.DelegationID,
+			SpecAgent:        runResult.Agent,
+			SpecChildCWD:     runResult.ChildCWD,
+		}
+		if req.Isolation == task.IsolationWorktree {
+			maps.Copy(one.Spec, worktree.Spec(WorktreeStatusPending))
+		}
+		maps.Copy(one.Spec, BudgetSpec(req.Budget))
+		if runResult.IdleTimeout > 0 {
+			one.Spec[SpecIdleTimeout] = int(runResult.IdleTimeout / time.Second)
+		} else if req.IdleTimeout > 0 {
+			one.Spec[SpecIdleTimeout] = int(req.IdleTimeout / time.Second)
+			controller.IdleTimeout = req.IdleTimeout
+		}
+		one.Backend = controller
+	})
 	if err := m.persistRecord(ctx, record); err != nil {
 		if controller.CancelFunc != nil {
 			controller.CancelFunc()
 		}
-		return task.Snapshot{}, err
+		return nil, agent.SubagentRunResult{}, err
 	}
 	taskstream.Emit(ctx, taskstream.Event{
 		Label:  strings.ToUpper(string(kind)),
@@ -207,12 +292,7 @@ func (m *Manager) StartSpawn(ctx context.Context, req task.SpawnStartRequest) (t
 		State:  "running",
 		Reset:  true,
 	})
-	snapshot, err := controller.Wait(ctx, record, 0)
-	if err != nil {
-		return task.Snapshot{}, err
-	}
-	snapshot.Yielded = runResult.Yielded || snapshot.Running
-	return snapshot, nil
+	return controller, runResult, nil
 }
 
 func normalizeSpawnTaskKind(kind task.Kind) (task.Kind, error) {
@@ -257,7 +337,7 @@ func (m *Manager) CleanupTurn(ctx context.Context) {
 	m.turnMu.Unlock()
 	for _, taskID := range taskIDs {
 		record, err := m.ensureRecord(ctx, taskID)
-		if err != nil || record == nil || record.Backend == nil {
+		if err != nil || record == nil || record.Controller() == nil {
 			continue
 		}
 		var running, supportsCancel, cleanupOnTurnEnd bool
@@ -269,7 +349,7 @@ func (m *Manager) CleanupTurn(ctx context.Context) {
 		if !running || !supportsCancel || !cleanupOnTurnEnd {
 			continue
 		}
-		_, _ = record.Backend.Cancel(ctx, record)
+		_, _ = record.Controller().Cancel(ctx, record)
 	}
 }
 
@@ -282,7 +362,7 @@ func (m *Manager) InterruptTurn(ctx context.Context) {
 	m.turnMu.Unlock()
 	for _, taskID := range taskIDs {
 		record, err := m.ensureRecord(ctx, taskID)
-		if err != nil || record == nil || record.Backend == nil {
+		if err != nil || record == nil || record.Controller() == nil {
 			continue
 		}
 		var (
@@ -298,7 +378,7 @@ func (m *Manager) InterruptTurn(ctx context.Context) {
 		if !running || !supportsCancel || kind != task.KindSpawn {
 			continue
 		}
-		_, _ = record.Backend.Cancel(ctx, record)
+		_, _ = record.Controller().Cancel(ctx, record)
 	}
 }
 
@@ -310,10 +390,11 @@ func (m *Manager) Wait(ctx context.Context, req task.ControlRequest) (task.Snaps
 	if snapshot, ok := persistedFinalTaskSnapshot(record); ok {
 		return snapshot, nil
 	}
-	if record.Backend == nil {
+	backend := record.Controller()
+	if backend == nil {
 		return task.Snapshot{}, fmt.Errorf("task: controller missing for %q", req.TaskID)
 	}
-	snapshot, err := record.Backend.Wait(ctx, record, req.Yield)
+	snapshot, err := backend.Wait(ctx, record, req.Yield)
 	if err != nil {
 		return task.Snapshot{}, err
 	}
@@ -326,13 +407,14 @@ func (m *Manager) Write(ctx context.Context, req task.ControlRequest) (task.Snap
 	if err != nil {
 		return task.Snapshot{}, err
 	}
-	if record.Backend == nil {
+	backend := record.Controller()
+	if backend == nil {
 		return task.Snapshot{}, fmt.Errorf("task: controller missing for %q", req.TaskID)
 	}
 	if !record.SupportsInput {
 		return task.Snapshot{}, fmt.Errorf("task: %q does not accept input", req.TaskID)
 	}
-	return record.Backend.Write(ctx, record, req.Input, req.Yield)
+	return backend.Write(ctx, record, req.Input, req.Yield)
 }
 
 func (m *Manager) Cancel(ctx context.Context, req task.ControlRequest) (task.Snapshot, error) {
@@ -343,13 +425,14 @@ func (m *Manager) Cancel(ctx context.Context, req task.ControlRequest) (task.Sna
 	if snapshot, ok := persistedFinalTaskSnapshot(record); ok {
 		return snapshot, nil
 	}
-	if record.Backend == nil {
+	backend := record.Controller()
+	if backend == nil {
 		return task.Snapshot{}, fmt.Errorf("task: controller missing for %q", req.TaskID)
 	}
 	if !record.SupportsCancel {
 		return task.Snapshot{}, fmt.Errorf("task: %q cannot be cancelled", req.TaskID)
 	}
-	return record.Backend.Cancel(ctx, record)
+	return backend.Cancel(ctx, record)
 }
 
 func (m *Manager) List(ctx context.Context) ([]task.Snapshot, error) {
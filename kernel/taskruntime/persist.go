@@ -114,6 +114,7 @@ func (m *Manager) rebuildController(entry *task.Entry) task.Controller {
 			Agent:                  StringValue(entry.Spec, SpecAgent),
 			ChildCWD:               StringValue(entry.Spec, SpecChildCWD),
 			IdleTimeout:            time.Duration(IntValue(entry.Spec, SpecIdleTimeout)) * time.Second,
+			Budget:                 BudgetFromSpec(entry.Spec),
 			ContinuationAnchorTool: m.continuationAnchorTool,
 		}
 	default:
@@ -280,3 +281,23 @@ func IntValue(values map[string]any, key string) int {
 		return 0
 	}
 }
+
+func FloatValue(values map[string]any, key string) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	raw, ok := values[key]
+	if !ok || raw == nil {
+		return 0
+	}
+	switch value := raw.(type) {
+	case float64:
+		return value
+	case int:
+		return float64(value)
+	case int64:
+		return float64(value)
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,454 @@
+package taskruntime
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	toolexec "github.com/OnslaughtSnail/caelis/kernel/execenv"
+	"github.com/OnslaughtSnail/caelis/kernel/task"
+)
+
+// Task spec keys describing the git worktree of an isolated SPAWN task.
+const (
+	SpecIsolation      = "isolation"
+	SpecWorktreeRepo   = "worktree_repo"
+	SpecWorktreePath   = "worktree_path"
+	SpecWorktreeBranch = "worktree_branch"
+	SpecWorktreeBase   = "worktree_base"
+	SpecWorktreeStatus = "worktree_status"
+)
+
+// Worktree statuses persisted under SpecWorktreeStatus.
+const (
+	WorktreeStatusPending   = "pending_review"
+	WorktreeStatusApplied   = "applied"
+	WorktreeStatusDiscarded = "discarded"
+	WorktreeStatusMissing   = "missing"
+)
+
+// WorktreeDir is the directory, relative to the repository root, that holds
+// the worktrees of isolated SPAWN children.
+const WorktreeDir = ".caelis/worktrees"
+
+const maxWorktreeDiffChars = 16000
+
+// Worktree is a temporary git worktree and branch created for one child.
+type Worktree struct {
+	Repo   string
+	Path   string
+	Branch string
+	Base   string
+}
+
+// WorktreeDiff is the change set a child made in its worktree relative to the
+// commit the worktree was created from.
+type WorktreeDiff struct {
+	Stat      string
+	Patch     string
+	Truncated bool
+}
+
+// CreateWorktree adds a worktree for taskID on a new branch at the current
+// HEAD of the repository containing dir.
+func CreateWorktree(ctx context.Context, dir string, taskID string) (Worktree, error) {
+	wt, err := PlanWorktree(ctx, dir, taskID)
+	if err != nil {
+		return Worktree{}, err
+	}
+	if err := wt.Create(ctx); err != nil {
+		return Worktree{}, err
+	}
+	return wt, nil
+}
+
+// PlanWorktree resolves the worktree CreateWorktree would add, without
+// touching the repository, so callers can record it first.
+func PlanWorktree(ctx context.Context, dir string, taskID string) (Worktree, error) {
+	taskID = strings.TrimSpace(taskID)
+	if taskID == "" {
+		return Worktree{}, fmt.Errorf("task: worktree requires a task id")
+	}
+	repo, err := runGit(ctx, dir, nil, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return Worktree{}, fmt.Errorf("task: worktree isolation requires a git repository: %w", err)
+	}
+	repo = strings.TrimSpace(repo)
+	base, err := runGit(ctx, repo, nil, "rev-parse", "HEAD")
+	if err != nil {
+		return Worktree{}, fmt.Errorf("task: worktree isolation requires at least one commit: %w", err)
+	}
+	return Worktree{
+		Repo:   repo,
+		Path:   filepath.Join(repo, filepath.FromSlash(WorktreeDir), taskID),
+		Branch: "caelis/spawn-" + taskID,
+		Base:   strings.TrimSpace(base),
+	}, nil
+}
+
+// Create adds the planned worktree and its branch.
+func (w Worktree) Create(ctx context.Context) error {
+	if err := excludeWorktreeDir(ctx, w.Repo); err != nil {
+		return err
+	}
+	if _, err := runGit(ctx, w.Repo, nil, "worktree", "add", "-b", w.Branch, w.Path, w.Base); err != nil {
+		return fmt.Errorf("task: create worktree: %w", err)
+	}
+	return nil
+}
+
+// WorktreeFromSpec reads the worktree persisted in a task spec.
+func WorktreeFromSpec(spec map[string]any) (Worktree, bool) {
+	if StringValue(spec, SpecIsolation) != task.IsolationWorktree {
+		return Worktree{}, false
+	}
+	wt := Worktree{
+		Repo:   StringValue(spec, SpecWorktreeRepo),
+		Path:   StringValue(spec, SpecWorktreePath),
+		Branch: StringValue(spec, SpecWorktreeBranch),
+		Base:   StringValue(spec, SpecWorktreeBase),
+	}
+	return wt, wt.Repo != "" && wt.Path != ""
+}
+
+// Spec returns the task spec entries describing the worktree.
+func (w Worktree) Spec(status string) map[string]any {
+	return map[string]any{
+		SpecIsolation:      task.IsolationWorktree,
+		SpecWorktreeRepo:   w.Repo,
+		SpecWorktreePath:   w.Path,
+		SpecWorktreeBranch: w.Branch,
+		SpecWorktreeBase:   w.Base,
+		SpecWorktreeStatus: status,
+	}
+}
+
+// Exists reports whether the worktree directory is still on disk.
+func (w Worktree) Exists() bool {
+	info, err := os.Stat(w.Path)
+	return err == nil && info.IsDir()
+}
+
+// Diff stages everything in the worktree, including untracked files, and
+// returns its change set relative to the base commit.
+func (w Worktree) Diff(ctx context.Context) (WorktreeDiff, error) {
+	patch, err := w.patch(ctx)
+	if err != nil {
+		return WorktreeDiff{}, err
+	}
+	stat, err := runGit(ctx, w.Path, nil, "diff", "--cached", "--stat", w.Base)
+	if err != nil {
+		return WorktreeDiff{}, fmt.Errorf("task: diff worktree: %w", err)
+	}
+	out := WorktreeDiff{Stat: strings.TrimRight(stat, "\n"), Patch: patch}
+	if len(out.Patch) > maxWorktreeDiffChars {
+		out.Patch = out.Patch[:maxWorktreeDiffChars]
+		out.Truncated = true
+	}
+	return out, nil
+}
+
+// Apply applies the worktree's change set to the repository's working tree
+// and then removes the worktree. It leaves the worktree in place when the
+// patch does not apply cleanly.
+func (w Worktree) Apply(ctx context.Context) error {
+	patch, err := w.patch(ctx)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(patch) != "" {
+		if _, err := runGit(ctx, w.Repo, strings.NewReader(patch), "apply", "--binary", "-"); err != nil {
+			return fmt.Errorf("task: apply worktree changes: %w", err)
+		}
+	}
+	return w.Remove(ctx)
+}
+
+// Remove deletes the worktree and its branch.
+func (w Worktree) Remove(ctx context.Context) error {
+	if w.Exists() {
+		if _, err := runGit(ctx, w.Repo, nil, "worktree", "remove", "--force", w.Path); err != nil {
+			return fmt.Errorf("task: remove worktree: %w", err)
+		}
+	} else if _, err := runGit(ctx, w.Repo, nil, "worktree", "prune"); err != nil {
+		return fmt.Errorf("task: prune worktrees: %w", err)
+	}
+	if strings.TrimSpace(w.Branch) != "" {
+		_, _ = runGit(ctx, w.Repo, nil, "branch", "-D", w.Branch)
+	}
+	return nil
+}
+
+func (w Worktree) patch(ctx context.Context) (string, error) {
+	if !w.Exists() {
+		return "", fmt.Errorf("task: worktree %q no longer exists", w.Path)
+	}
+	if _, err := runGit(ctx, w.Path, nil, "add", "-A"); err != nil {
+		return "", fmt.Errorf("task: stage worktree changes: %w", err)
+	}
+	patch, err := runGit(ctx, w.Path, nil, "diff", "--cached", "--binary", w.Base)
+	if err != nil {
+		return "", fmt.Errorf("task: diff worktree: %w", err)
+	}
+	return patch, nil
+}
+
+// excludeWorktreeDir keeps child worktrees out of the parent's git status.
+func excludeWorktreeDir(ctx context.Context, repo string) error {
+	commonDir, err := runGit(ctx, repo, nil, "rev-parse", "--git-common-dir")
+	if err != nil {
+		return fmt.Errorf("task: locate git dir: %w", err)
+	}
+	commonDir = strings.TrimSpace(commonDir)
+	if !filepath.IsAbs(commonDir) {
+		commonDir = filepath.Join(repo, commonDir)
+	}
+	excludePath := filepath.Join(commonDir, "info", "exclude")
+	pattern := "/" + WorktreeDir + "/"
+	raw, err := os.ReadFile(excludePath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("task: read git exclude: %w", err)
+	}
+	for _, line := range strings.Split(string(raw), "\n") {
+		if strings.TrimSpace(line) == pattern {
+			return nil
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(excludePath), 0o755); err != nil {
+		return fmt.Errorf("task: update git exclude: %w", err)
+	}
+	content := string(raw)
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	if err := os.WriteFile(excludePath, []byte(content+pattern+"\n"), 0o644); err != nil {
+		return fmt.Errorf("task: update git exclude: %w", err)
+	}
+	return nil
+}
+
+func runGit(ctx context.Context, dir string, stdin *strings.Reader, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	if stdin != nil {
+		cmd.Stdin = stdin
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if text := strings.TrimSpace(stderr.String()); text != "" {
+			return "", fmt.Errorf("git %s: %s", args[0], text)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return stdout.String(), nil
+}
+
+// ResolveWorktree shows, applies or discards the worktree of an isolated
+// SPAWN task. Apply and discard require the child to have stopped.
+func (m *Manager) ResolveWorktree(ctx context.Context, req task.WorktreeRequest) (task.Snapshot, error) {
+	record, err := m.ensureRecord(ctx, req.TaskID)
+	if err != nil {
+		return task.Snapshot{}, err
+	}
+	var (
+		spec    map[string]any
+		running bool
+	)
+	record.WithLock(func(one *task.Record) {
+		spec = maps.Clone(one.Spec)
+		running = one.Running
+	})
+	wt, ok := WorktreeFromSpec(spec)
+	if !ok {
+		return task.Snapshot{}, fmt.Errorf("task: %q was not spawned with worktree isolation", req.TaskID)
+	}
+	switch status := StringValue(spec, SpecWorktreeStatus); status {
+	case WorktreeStatusApplied, WorktreeStatusDiscarded:
+		return task.Snapshot{}, fmt.Errorf("task: worktree of %q was already %s", req.TaskID, status)
+	case WorktreeStatusMissing:
+		return task.Snapshot{}, fmt.Errorf("task: worktree of %q no longer exists", req.TaskID)
+	}
+	if backend := record.Controller(); running && backend != nil && req.Action != task.WorktreeActionDiff {
+		snapshot, err := backend.Wait(ctx, record, 0)
+		if err != nil {
+			return task.Snapshot{}, err
+		}
+		running = snapshot.Running
+	}
+	status := WorktreeStatusPending
+	extra := map[string]any{}
+	switch req.Action {
+	case task.WorktreeActionDiff:
+		diff, err := wt.Diff(ctx)
+		if err != nil {
+			return task.Snapshot{}, err
+		}
+		extra["worktree_diff_stat"] = diff.Stat
+		extra["worktree_diff"] = diff.Patch
+		if diff.Truncated {
+			extra["worktree_diff_truncated"] = true
+		}
+	case task.WorktreeActionApply, task.WorktreeActionDiscard:
+		if req.Action == task.WorktreeActionApply {
+			if err := m.checkWorktreeApply(ctx); err != nil {
+				return task.Snapshot{}, err
+			}
+		}
+		if running {
+			return task.Snapshot{}, fmt.Errorf("task: %q is still running; wait for it or cancel it before TASK %s", req.TaskID, req.Action)
+		}
+		if req.Action == task.WorktreeActionApply {
+			if err := wt.Apply(ctx); err != nil {
+				return task.Snapshot{}, err
+			}
+			status = WorktreeStatusApplied
+		} else {
+			if err := wt.Remove(ctx); err != nil {
+				return task.Snapshot{}, err
+			}
+			status = WorktreeStatusDiscarded
+		}
+	default:
+		return task.Snapshot{}, fmt.Errorf("task: unsupported worktree action %q", req.Action)
+	}
+	var snapshot task.Snapshot
+	record.WithLock(func(one *task.Record) {
+		if one.Spec == nil {
+			one.Spec = map[string]any{}
+		}
+		one.Spec[SpecWorktreeStatus] = status
+		if one.Result == nil {
+			one.Result = map[string]any{}
+		}
+		AddWorktreeResult(one.Result, one.Spec)
+		if status != WorktreeStatusPending {
+			delete(one.Result, "worktree_diff_stat")
+		}
+		snapshot = one.LockedSnapshot(task.Output{})
+	})
+	if err := m.persistRecord(ctx, record); err != nil {
+		return task.Snapshot{}, err
+	}
+	maps.Copy(snapshot.Result, extra)
+	return snapshot, nil
+}
+
+// checkWorktreeApply refuses to merge a worktree in turns that must not
+// change the working tree. Apply runs git on the host, so in staged mode it
+// would bypass the staging layer.
+func (m *Manager) checkWorktreeApply(ctx context.Context) error {
+	if reason, blocked := task.WorktreeApplyBlocked(ctx); blocked {
+		return fmt.Errorf("task: TASK apply is not allowed: %s", reason)
+	}
+	if provider, ok := m.execenv.(toolexec.OverlayProvider); ok && provider.Overlay() != nil {
+		return fmt.Errorf("task: TASK apply is not allowed in staged mode; leave staged mode to merge the worktree")
+	}
+	return nil
+}
+
+// createSpawnWorktree creates the worktree for a SPAWN child and returns it
+// with the directory the child should start in, which mirrors the parent's
+// position inside the repository. The worktree is persisted on the record
+// before it is created, so a crash cannot leave one that no task knows about.
+func (m *Manager) createSpawnWorktree(ctx context.Context, record *task.Record) (Worktree, string, error) {
+	dir := ""
+	if m.execenv != nil && m.execenv.FileSystem() != nil {
+		dir, _ = m.execenv.FileSystem().Getwd()
+	}
+	if strings.TrimSpace(dir) == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return Worktree{}, "", fmt.Errorf("task: resolve working directory: %w", err)
+		}
+		dir = wd
+	}
+	wt, err := PlanWorktree(ctx, dir, record.ID)
+	if err != nil {
+		return Worktree{}, "", err
+	}
+	record.WithLock(func(one *task.Record) {
+		if m.session != nil {
+			one.Session = *m.session
+		}
+		if one.Spec == nil {
+			one.Spec = map[string]any{}
+		}
+		maps.Copy(one.Spec, wt.Spec(WorktreeStatusPending))
+	})
+	if err := m.persistRecord(ctx, record); err != nil {
+		return Worktree{}, "", err
+	}
+	if err := wt.Create(ctx); err != nil {
+		m.failSpawnWorktree(ctx, record, WorktreeStatusMissing, err)
+		return Worktree{}, "", err
+	}
+	childCWD := wt.Path
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = resolved
+	}
+	if rel, err := filepath.Rel(wt.Repo, dir); err == nil && rel != "." && !strings.HasPrefix(rel, "..") {
+		childCWD = filepath.Join(wt.Path, rel)
+	}
+	return wt, childCWD, nil
+}
+
+// failSpawnWorktree persists a SPAWN record whose child never started, with
+// the worktree status it was left in.
+func (m *Manager) failSpawnWorktree(ctx context.Context, record *task.Record, status string, cause error) {
+	record.WithLock(func(one *task.Record) {
+		one.Spec[SpecWorktreeStatus] = status
+		one.State = task.StateFailed
+		one.Running = false
+		one.UpdatedAt = time.Now()
+		one.Result = map[string]any{"error": cause.Error()}
+		AddWorktreeResult(one.Result, one.Spec)
+	})
+	_ = m.persistRecord(context.WithoutCancel(ctx), record)
+}
+
+// AddWorktreeResult copies the worktree fields of an isolated task's spec into
+// its result so TASK output shows where the child worked and what is pending.
+func AddWorktreeResult(result map[string]any, spec map[string]any) {
+	if result == nil || StringValue(spec, SpecIsolation) != task.IsolationWorktree {
+		return
+	}
+	result["isolation"] = task.IsolationWorktree
+	result["worktree_path"] = StringValue(spec, SpecWorktreePath)
+	result["worktree_branch"] = StringValue(spec, SpecWorktreeBranch)
+	result["worktree_status"] = StringValue(spec, SpecWorktreeStatus)
+}
+
+// ReconcileWorktree marks a pending worktree as missing when its directory was
+// removed while the session was not running. Pending worktrees that survive
+// stay reviewable so the parent can still apply or discard them. It reports
+// whether the entry changed.
+func ReconcileWorktree(entry *task.Entry) bool {
+	if entry == nil {
+		return false
+	}
+	wt, ok := WorktreeFromSpec(entry.Spec)
+	if !ok {
+		return false
+	}
+	changed := false
+	if StringValue(entry.Spec, SpecWorktreeStatus) == WorktreeStatusPending && !wt.Exists() {
+		entry.Spec[SpecWorktreeStatus] = WorktreeStatusMissing
+		changed = true
+	}
+	if entry.Result == nil {
+		entry.Result = map[string]any{}
+	}
+	if StringValue(entry.Result, "worktree_status") != StringValue(entry.Spec, SpecWorktreeStatus) {
+		changed = true
+	}
+	AddWorktreeResult(entry.Result, entry.Spec)
+	return changed
+}
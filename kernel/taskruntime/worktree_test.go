@@ -0,0 +1,184 @@
+package taskruntime
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/OnslaughtSnail/caelis/kernel/task"
+	"github.com/OnslaughtSnail/caelis/kernel/task/inmemory"
+)
+
+func newWorktreeTestRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	repo := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"config", "user.email", "test@example.com"},
+		{"config", "user.name", "test"},
+	} {
+		if _, err := runGit(context.Background(), repo, nil, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(repo, "a.txt"), []byte("one\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{{"add", "-A"}, {"commit", "-q", "-m", "init"}} {
+		if _, err := runGit(context.Background(), repo, nil, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return repo
+}
+
+func TestWorktreeDiffAndApplyMergesChildChanges(t *testing.T) {
+	repo := newWorktreeTestRepo(t)
+	ctx := context.Background()
+	wt, err := CreateWorktree(ctx, repo, "t-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !wt.Exists() || wt.Branch != "caelis/spawn-t-1" {
+		t.Fatalf("unexpected worktree %+v", wt)
+	}
+	if err := os.WriteFile(filepath.Join(wt.Path, "a.txt"), []byte("two\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(wt.Path, "b.txt"), []byte("new\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	status, err := runGit(ctx, repo, nil, "status", "--porcelain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(status) != "" {
+		t.Fatalf("expected the parent tree to stay clean, got %q", status)
+	}
+	diff, err := wt.Diff(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(diff.Stat, "a.txt") || !strings.Contains(diff.Stat, "b.txt") || !strings.Contains(diff.Patch, "+two") {
+		t.Fatalf("unexpected diff %+v", diff)
+	}
+	if err := wt.Apply(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if raw, _ := os.ReadFile(filepath.Join(repo, "a.txt")); string(raw) != "two\n" {
+		t.Fatalf("expected a.txt to be updated, got %q", raw)
+	}
+	if raw, _ := os.ReadFile(filepath.Join(repo, "b.txt")); string(raw) != "new\n" {
+		t.Fatalf("expected b.txt to be created, got %q", raw)
+	}
+	if wt.Exists() {
+		t.Fatal("expected apply to remove the worktree")
+	}
+	if branches, _ := runGit(ctx, repo, nil, "branch", "--list", wt.Branch); strings.TrimSpace(branches) != "" {
+		t.Fatalf("expected apply to delete the branch, got %q", branches)
+	}
+}
+
+func TestWorktreeRemoveDiscardsChildChanges(t *testing.T) {
+	repo := newWorktreeTestRepo(t)
+	ctx := context.Background()
+	wt, err := CreateWorktree(ctx, repo, "t-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(wt.Path, "a.txt"), []byte("two\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := wt.Remove(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if raw, _ := os.ReadFile(filepath.Join(repo, "a.txt")); string(raw) != "one\n" {
+		t.Fatalf("expected a.txt to be untouched, got %q", raw)
+	}
+	if wt.Exists() {
+		t.Fatal("expected the worktree to be removed")
+	}
+}
+
+func TestReconcileWorktreeMarksMissingWorktrees(t *testing.T) {
+	wt := Worktree{Repo: t.TempDir(), Path: filepath.Join(t.TempDir(), "gone"), Branch: "caelis/spawn-t-3"}
+	entry := &task.Entry{Kind: task.KindSpawn, Spec: wt.Spec(WorktreeStatusPending)}
+	if !ReconcileWorktree(entry) {
+		t.Fatal("expected the entry to change")
+	}
+	if got := StringValue(entry.Spec, SpecWorktreeStatus); got != WorktreeStatusMissing {
+		t.Fatalf("expected missing status, got %q", got)
+	}
+	if got := StringValue(entry.Result, "worktree_status"); got != WorktreeStatusMissing {
+		t.Fatalf("expected result to mirror the status, got %q", got)
+	}
+	if ReconcileWorktree(entry) {
+		t.Fatal("expected a second reconcile to be a no-op")
+	}
+}
+
+// worktreeWatchStore records whether the worktree of each upserted entry was
+// already on disk.
+type worktreeWatchStore struct {
+	*inmemory.Store
+	existed []bool
+}
+
+func (s *worktreeWatchStore) Upsert(ctx context.Context, entry *task.Entry) error {
+	if wt, ok := WorktreeFromSpec(entry.Spec); ok {
+		s.existed = append(s.existed, wt.Exists())
+	}
+	return s.Store.Upsert(ctx, entry)
+}
+
+func TestStartSpawnPersistsWorktreeBeforeCreatingItAndGuardsApply(t *testing.T) {
+	repo := newWorktreeTestRepo(t)
+	t.Chdir(repo)
+	store := &worktreeWatchStore{Store: inmemory.New()}
+	runner := newBatchSubagentRunner()
+	manager := New(Config{Subagents: runner, Store: store})
+	ctx := context.Background()
+
+	snapshot, err := manager.StartSpawn(ctx, task.SpawnStartRequest{Prompt: "a", Isolation: task.IsolationWorktree})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(store.existed) < 2 || store.existed[0] {
+		t.Fatalf("expected the worktree persisted before it was created, got %v", store.existed)
+	}
+	wt, ok := WorktreeFromSpec(mustEntry(t, store, snapshot.TaskID).Spec)
+	if !ok || !wt.Exists() {
+		t.Fatalf("expected the worktree on disk, got %+v", wt)
+	}
+	runner.finishAll()
+
+	blocked := task.WithWorktreeApplyBlocked(ctx, "plan mode does not change the working tree")
+	req := task.WorktreeRequest{TaskID: snapshot.TaskID, Action: task.WorktreeActionApply}
+	if _, err := manager.ResolveWorktree(blocked, req); err == nil || !strings.Contains(err.Error(), "plan mode") {
+		t.Fatalf("expected apply to be refused, got %v", err)
+	}
+	if !wt.Exists() {
+		t.Fatal("expected a refused apply to keep the worktree")
+	}
+	if _, err := manager.ResolveWorktree(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	if wt.Exists() {
+		t.Fatal("expected apply to remove the worktree")
+	}
+}
+
+func mustEntry(t *testing.T, store task.Store, taskID string) *task.Entry {
+	t.Helper()
+	entry, err := store.Get(context.Background(), taskID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entry
+}
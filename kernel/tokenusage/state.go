@@ -0,0 +1,201 @@
+// Package tokenusage aggregates model token usage and cost for a session,
+// broken down per user turn and per provider/model, and persists the running
+// totals as session state.
+package tokenusage
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/OnslaughtSnail/caelis/kernel/model"
+)
+
+const StateKey = "runtime.usage"
+
+// maxTurns bounds how many per-turn records are kept in session state. Older
+// turns still count towards the session and per-model totals.
+const maxTurns = 200
+
+// Pricer returns the USD cost of one model call, or false when the model has
+// no known price.
+type Pricer func(provider, modelName string, usage model.Usage) (float64, bool)
+
+// Totals is the accumulated usage of a set of model calls.
+type Totals struct {
+	model.Usage
+	Requests int     `json:"requests,omitempty"`
+	CostUSD  float64 `json:"cost_usd,omitempty"`
+	// UnpricedRequests counts calls whose model had no price, so CostUSD is a
+	// lower bound whenever it is non-zero.
+	UnpricedRequests int `json:"unpriced_requests,omitempty"`
+}
+
+func (t *Totals) add(usage model.Usage, cost float64, priced bool) {
+	t.Usage = t.Usage.Add(usage)
+	t.Requests++
+	if priced {
+		t.CostUSD += cost
+	} else {
+		t.UnpricedRequests++
+	}
+}
+
+// ModelTotals is the usage attributed to one provider/model pair.
+type ModelTotals struct {
+	Provider string `json:"provider,omitempty"`
+	Model    string `json:"model,omitempty"`
+	Totals
+}
+
+// TurnTotals is the usage spent answering one user turn.
+type TurnTotals struct {
+	TurnID string `json:"turn_id"`
+	Totals
+}
+
+// State is the persisted usage ledger of one session.
+type State struct {
+	Total  Totals        `json:"total"`
+	Models []ModelTotals `json:"models,omitempty"`
+	Turns  []TurnTotals  `json:"turns,omitempty"`
+}
+
+// IsZero reports whether no model call has been recorded.
+func (s State) IsZero() bool {
+	return s.Total.Requests == 0
+}
+
+// Record adds one model call to the session, turn and model totals.
+func (s *State) Record(turnID, provider, modelName string, usage model.Usage, cost float64, priced bool) {
+	if s == nil {
+		return
+	}
+	s.Total.add(usage, cost, priced)
+
+	provider = strings.TrimSpace(provider)
+	modelName = strings.TrimSpace(modelName)
+	idx := -1
+	for i := range s.Models {
+		if s.Models[i].Provider == provider && s.Models[i].Model == modelName {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		s.Models = append(s.Models, ModelTotals{Provider: provider, Model: modelName})
+		idx = len(s.Models) - 1
+	}
+	s.Models[idx].add(usage, cost, priced)
+	sort.SliceStable(s.Models, func(i, j int) bool {
+		if s.Models[i].Provider != s.Models[j].Provider {
+			return s.Models[i].Provider < s.Models[j].Provider
+		}
+		return s.Models[i].Model < s.Models[j].Model
+	})
+
+	turnID = strings.TrimSpace(turnID)
+	if turnID == "" {
+		return
+	}
+	if n := len(s.Turns); n == 0 || s.Turns[n-1].TurnID != turnID {
+		s.Turns = append(s.Turns, TurnTotals{TurnID: turnID})
+		if len(s.Turns) > maxTurns {
+			s.Turns = append([]TurnTotals(nil), s.Turns[len(s.Turns)-maxTurns:]...)
+		}
+	}
+	s.Turns[len(s.Turns)-1].add(usage, cost, priced)
+}
+
+// LastTurn returns the most recent turn with recorded usage.
+func (s State) LastTurn() (TurnTotals, bool) {
+	if len(s.Turns) == 0 {
+		return TurnTotals{}, false
+	}
+	return s.Turns[len(s.Turns)-1], true
+}
+
+// FromEventMeta extracts the provider, model and usage an assistant event was
+// annotated with. It reports false when the event carries no usage.
+func FromEventMeta(meta map[string]any) (provider string, modelName string, usage model.Usage, ok bool) {
+	if len(meta) == 0 {
+		return "", "", model.Usage{}, false
+	}
+	raw, ok := meta["usage"].(map[string]any)
+	if !ok {
+		return "", "", model.Usage{}, false
+	}
+	usage = model.Usage{
+		PromptTokens:     intValue(raw["prompt_tokens"]),
+		CompletionTokens: intValue(raw["completion_tokens"]),
+		TotalTokens:      intValue(raw["total_tokens"]),
+		CacheReadTokens:  intValue(raw["cache_read_tokens"]),
+		CacheWriteTokens: intValue(raw["cache_write_tokens"]),
+		ReasoningTokens:  intValue(raw["reasoning_tokens"]),
+	}
+	if usage.IsZero() {
+		return "", "", model.Usage{}, false
+	}
+	if usage.TotalTokens == 0 {
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	}
+	return stringValue(meta["provider"]), stringValue(meta["model"]), usage, true
+}
+
+// StateFromSnapshot decodes the usage ledger from a session state snapshot.
+func StateFromSnapshot(snapshot map[string]any) (State, bool) {
+	raw, ok := snapshot[StateKey]
+	if !ok || raw == nil {
+		return State{}, false
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return State{}, false
+	}
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return State{}, false
+	}
+	return state, !state.IsZero()
+}
+
+// StateSnapshot encodes the usage ledger as plain JSON values so every state
+// store persists it the same way.
+func StateSnapshot(state State) map[string]any {
+	if state.IsZero() {
+		return map[string]any{}
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return map[string]any{}
+	}
+	var payload map[string]any
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return map[string]any{}
+	}
+	return map[string]any{StateKey: payload}
+}
+
+func intValue(v any) int {
+	switch n := v.(type) {
+	case int:
+		return n
+	case int64:
+		return int(n)
+	case float64:
+		return int(n)
+	case json.Number:
+		i, _ := n.Int64()
+		return int(i)
+	default:
+		return 0
+	}
+}
+
+func stringValue(v any) string {
+	if v == nil {
+		return ""
+	}
+	return strings.TrimSpace(fmt.Sprint(v))
+}
@@ -0,0 +1,54 @@
+package tokenusage
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/OnslaughtSnail/caelis/kernel/model"
+)
+
+func TestState_RecordAggregatesPerTurnAndModelAndRoundTrips(t *testing.T) {
+	var state State
+	state.Record("turn-1", "openai", "gpt-5", model.Usage{PromptTokens: 100, CompletionTokens: 10, TotalTokens: 110, CacheReadTokens: 60}, 0.01, true)
+	state.Record("turn-1", "anthropic", "claude", model.Usage{PromptTokens: 50, CompletionTokens: 5, TotalTokens: 55}, 0, false)
+	state.Record("turn-2", "openai", "gpt-5", model.Usage{PromptTokens: 200, CompletionTokens: 20, TotalTokens: 220, ReasoningTokens: 8}, 0.02, true)
+
+	if state.Total.Requests != 3 || state.Total.TotalTokens != 385 || state.Total.CacheReadTokens != 60 || state.Total.UnpricedRequests != 1 {
+		t.Fatalf("unexpected totals %+v", state.Total)
+	}
+	if len(state.Models) != 2 || state.Models[0].Provider != "anthropic" || state.Models[1].Requests != 2 {
+		t.Fatalf("unexpected model totals %+v", state.Models)
+	}
+	last, ok := state.LastTurn()
+	if !ok || last.TurnID != "turn-2" || last.ReasoningTokens != 8 || len(state.Turns) != 2 {
+		t.Fatalf("unexpected turns %+v", state.Turns)
+	}
+
+	// Persisted state comes back through JSON, so numbers decode as float64.
+	raw, err := json.Marshal(StateSnapshot(state))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var values map[string]any
+	if err := json.Unmarshal(raw, &values); err != nil {
+		t.Fatal(err)
+	}
+	restored, ok := StateFromSnapshot(values)
+	if !ok || restored.Total != state.Total || len(restored.Turns) != 2 || restored.Models[1].CostUSD != state.Models[1].CostUSD {
+		t.Fatalf("unexpected restored state %+v", restored)
+	}
+}
+
+func TestFromEventMeta(t *testing.T) {
+	provider, modelName, usage, ok := FromEventMeta(map[string]any{
+		"provider": "openai",
+		"model":    "gpt-5",
+		"usage":    map[string]any{"prompt_tokens": float64(12), "completion_tokens": 3, "cache_read_tokens": 4},
+	})
+	if !ok || provider != "openai" || modelName != "gpt-5" || usage.TotalTokens != 15 || usage.CacheReadTokens != 4 {
+		t.Fatalf("unexpected usage %q %q %+v %v", provider, modelName, usage, ok)
+	}
+	if _, _, _, ok := FromEventMeta(map[string]any{"provider": "openai"}); ok {
+		t.Fatal("expected events without usage to be skipped")
+	}
+}
@@ -1,6 +1,9 @@
 package tool
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 func asStringArg(args map[string]any, key string) string {
 	if len(args) == 0 {
@@ -35,3 +38,29 @@ func asIntArg(args map[string]any, key string) int {
 		return 0
 	}
 }
+
+func asStringListArg(args map[string]any, key string) []string {
+	if len(args) == 0 {
+		return nil
+	}
+	var values []string
+	switch raw := args[key].(type) {
+	case []string:
+		values = raw
+	case []any:
+		for _, item := range raw {
+			if item != nil {
+				values = append(values, fmt.Sprint(item))
+			}
+		}
+	case string:
+		values = strings.Split(raw, ",")
+	}
+	out := make([]string, 0, len(values))
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			out = append(out, value)
+		}
+	}
+	return out
+}
@@ -0,0 +1,445 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	toolexec "github.com/OnslaughtSnail/caelis/kernel/execenv"
+	"github.com/OnslaughtSnail/caelis/kernel/model"
+	"github.com/OnslaughtSnail/caelis/kernel/tool/builtin/internal/argparse"
+	"github.com/OnslaughtSnail/caelis/kernel/tool/capability"
+)
+
+const (
+	EditToolName = "EDIT"
+)
+
+const maxEditOps = 200
+
+// EditTool applies several exact replacements, possibly across files, as one
+// all-or-nothing change. Every edit is validated against the current file
+// contents before anything is written.
+type EditTool struct {
+	runtime toolexec.Runtime
+}
+
+// editOp is one exact old-to-new replacement. line, when positive, is the
+// 1-based line where old is expected to start (from a unified diff hunk) and
+// picks between repeated matches.
+type editOp struct {
+	path       string
+	old        string
+	new        string
+	replaceAll bool
+	create     bool
+	line       int
+}
+
+type editFileState struct {
+	plan      fileMutationPlan
+	exists    bool
+	content   string
+	lineDelta int
+}
+
+func NewEditWithRuntime(runtime toolexec.Runtime) (*EditTool, error) {
+	resolvedRuntime, err := runtimeOrDefault(runtime)
+	if err != nil {
+		return nil, err
+	}
+	return &EditTool{runtime: resolvedRuntime}, nil
+}
+
+func (t *EditTool) Name() string {
+	return EditToolName
+}
+
+func (t *EditTool) Description() string {
+	return "Apply several exact replacements or a unified diff across one or more files atomically; nothing is written unless every edit applies."
+}
+
+func (t *EditTool) Capability() capability.Capability {
+	return capability.Capability{
+		Operations: []capability.Operation{capability.OperationFileWrite},
+		Risk:       capability.RiskMedium,
+	}
+}
+
+func (t *EditTool) Declaration() model.ToolDefinition {
+	return model.ToolDefinition{
+		Name:        t.Name(),
+		Description: t.Description(),
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"edits": map[string]any{
+					"type":        "array",
+					"description": "Exact replacements applied in order; later edits to the same file see earlier results.",
+					"items": map[string]any{
+						"type": "object",
+						"properties": map[string]any{
+							"path":        map[string]any{"type": "string", "description": "Target file path."},
+							"old":         map[string]any{"type": "string", "description": "Exact original text to replace."},
+							"new":         map[string]any{"type": "string", "description": "Replacement text."},
+							"replace_all": map[string]any{"type": "boolean", "description": "Replace all occurrences instead of one."},
+						},
+						"required": []string{"path", "old", "new"},
+					},
+				},
+				"patch": map[string]any{"type": "string", "description": "Unified diff text (---/+++ headers and @@ hunks). Use instead of edits."},
+			},
+		},
+	}
+}
+
+func (t *EditTool) Run(ctx context.Context, args map[string]any) (map[string]any, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+	fsys := t.runtime.FileSystem()
+	plans, err := planEditMutation(fsys, args)
+	if err != nil {
+		return nil, err
+	}
+	for i, plan := range plans {
+		if err := fsys.WriteFile(plan.path, []byte(plan.after), plan.mode); err != nil {
+			if rollbackErr := rollbackEditWrites(fsys, plans[:i]); rollbackErr != nil {
+				return nil, fmt.Errorf("tool: EDIT failed writing %q: %w; rollback also failed: %v", plan.path, err, rollbackErr)
+			}
+			return nil, fmt.Errorf("tool: EDIT failed writing %q: %w; earlier files were restored", plan.path, err)
+		}
+	}
+
+	files := make([]map[string]any, 0, len(plans))
+	totalAdded, totalRemoved, totalReplaced := 0, 0, 0
+	for _, plan := range plans {
+		diffStats := CountLineDiff(plan.before, plan.after)
+		totalAdded += diffStats.Added
+		totalRemoved += diffStats.Removed
+		totalReplaced += plan.replaced
+		files = append(files, map[string]any{
+			"path":           plan.path,
+			"replaced":       plan.replaced,
+			"created":        plan.created,
+			"previous_empty": plan.before == "",
+			"added_lines":    diffStats.Added,
+			"removed_lines":  diffStats.Removed,
+		})
+	}
+	return map[string]any{
+		"path":          plans[0].path,
+		"files":         files,
+		"file_count":    len(plans),
+		"replaced":      totalReplaced,
+		"added_lines":   totalAdded,
+		"removed_lines": totalRemoved,
+	}, nil
+}
+
+func (t *EditTool) WithRuntime(runtime toolexec.Runtime) (*EditTool, error) {
+	return NewEditWithRuntime(runtime)
+}
+
+// EditTargetPaths lists the raw file paths an EDIT call would touch, in first
+// appearance order, without consulting the filesystem. Policies use it to
+// apply per-path checks to every target of a multi-file edit.
+func EditTargetPaths(args map[string]any) []string {
+	ops, err := parseEditOps(args)
+	if err != nil {
+		return nil
+	}
+	seen := map[string]struct{}{}
+	out := make([]string, 0, len(ops))
+	for _, op := range ops {
+		if _, ok := seen[op.path]; ok {
+			continue
+		}
+		seen[op.path] = struct{}{}
+		out = append(out, op.path)
+	}
+	return out
+}
+
+// MutationTargetPaths resolves the absolute paths a file-write call would
+// touch against fsys: every EDIT target, or the "path" arg of other tools.
+// Unresolvable paths are dropped.
+func MutationTargetPaths(fsys toolexec.FileSystem, toolName string, args map[string]any) []string {
+	var raw []string
+	if strings.EqualFold(strings.TrimSpace(toolName), EditToolName) {
+		raw = EditTargetPaths(args)
+	} else if pathArg, _ := args["path"].(string); strings.TrimSpace(pathArg) != "" {
+		raw = []string{strings.TrimSpace(pathArg)}
+	}
+	out := make([]string, 0, len(raw))
+	for _, item := range raw {
+		target, err := normalizePathWithFS(fsys, item)
+		if err != nil || slices.Contains(out, target) {
+			continue
+		}
+		out = append(out, target)
+	}
+	return out
+}
+
+func parseEditOps(args map[string]any) ([]editOp, error) {
+	patchText, err := argparse.String(args, "patch", false)
+	if err != nil {
+		return nil, err
+	}
+	rawEdits, hasEdits := args["edits"]
+	if hasEdits && rawEdits == nil {
+		hasEdits = false
+	}
+	switch {
+	case patchText != "" && hasEdits:
+		return nil, fmt.Errorf("tool: EDIT accepts either %q or %q, not both", "edits", "patch")
+	case patchText != "":
+		return parseUnifiedDiff(patchText)
+	case !hasEdits:
+		return nil, fmt.Errorf("tool: EDIT requires %q or %q", "edits", "patch")
+	}
+
+	var items []map[string]any
+	switch typed := rawEdits.(type) {
+	case []map[string]any:
+		items = typed
+	case []any:
+		items = make([]map[string]any, 0, len(typed))
+		for i, raw := range typed {
+			item, ok := raw.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("tool: EDIT edits[%d] must be an object", i)
+			}
+			items = append(items, item)
+		}
+	default:
+		return nil, fmt.Errorf("tool: arg %q must be an array", "edits")
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("tool: arg %q must contain at least one edit", "edits")
+	}
+	if len(items) > maxEditOps {
+		return nil, fmt.Errorf("tool: EDIT accepts at most %d edits per call, got %d", maxEditOps, len(items))
+	}
+	ops := make([]editOp, 0, len(items))
+	for i, item := range items {
+		pathArg, err := argparse.String(item, "path", true)
+		if err != nil {
+			return nil, fmt.Errorf("tool: EDIT edits[%d]: %w", i, err)
+		}
+		oldValue, ok := item["old"].(string)
+		if !ok {
+			return nil, fmt.Errorf("tool: EDIT edits[%d]: arg %q must be string", i, "old")
+		}
+		newValue, ok := item["new"].(string)
+		if !ok {
+			return nil, fmt.Errorf("tool: EDIT edits[%d]: arg %q must be string", i, "new")
+		}
+		replaceAll, err := argparse.Bool(item, "replace_all", false)
+		if err != nil {
+			return nil, fmt.Errorf("tool: EDIT edits[%d]: %w", i, err)
+		}
+		ops = append(ops, editOp{path: pathArg, old: oldValue, new: newValue, replaceAll: replaceAll})
+	}
+	return ops, nil
+}
+
+// planEditMutation validates every edit against an in-memory copy of its
+// target and returns one plan per file in first-touched order. No file is
+// written here, so a failing edit leaves the workspace untouched.
+func planEditMutation(fsys toolexec.FileSystem, args map[string]any) ([]fileMutationPlan, error) {
+	ops, err := parseEditOps(args)
+	if err != nil {
+		return nil, err
+	}
+	states := map[string]*editFileState{}
+	order := make([]string, 0, len(ops))
+	for i, op := range ops {
+		target, err := normalizePathWithFS(fsys, op.path)
+		if err != nil {
+			return nil, err
+		}
+		state, ok := states[target]
+		if !ok {
+			state, err = loadEditFileState(fsys, target)
+			if err != nil {
+				return nil, err
+			}
+			states[target] = state
+			order = append(order, target)
+		}
+		if err := state.apply(i, op); err != nil {
+			return nil, err
+		}
+	}
+
+	plans := make([]fileMutationPlan, 0, len(order))
+	for _, target := range order {
+		state := states[target]
+		plan := state.plan
+		plan.after = state.content
+		plan.hunk = buildEditHunk(plan.before, plan.after)
+		plans = append(plans, plan)
+	}
+	return plans, nil
+}
+
+func loadEditFileState(fsys toolexec.FileSystem, target string) (*editFileState, error) {
+	state := &editFileState{plan: fileMutationPlan{
+		tool: EditToolName,
+		path: target,
+		mode: os.FileMode(0o644),
+	}}
+	info, statErr := fsys.Stat(target)
+	switch {
+	case statErr == nil:
+		if info.IsDir() {
+			return nil, fmt.Errorf("tool: target %q is directory", target)
+		}
+		raw, err := fsys.ReadFile(target)
+		if err != nil {
+			return nil, err
+		}
+		state.exists = true
+		state.plan.mode = info.Mode()
+		state.plan.before = string(raw)
+		state.content = state.plan.before
+	case errors.Is(statErr, os.ErrNotExist):
+		state.plan.created = true
+	default:
+		return nil, statErr
+	}
+	return state, nil
+}
+
+func (s *editFileState) apply(index int, op editOp) error {
+	target := s.plan.path
+	if op.create {
+		if s.content != "" {
+			return fmt.Errorf("tool: EDIT edit %d creates %q, but the file already has content; no files were changed", index+1, target)
+		}
+		s.content = op.new
+		s.plan.replaced++
+		return nil
+	}
+	if op.old == "" {
+		switch {
+		case s.content == "":
+			s.content = op.new
+		case op.line > 0:
+			offset := lineByteOffset(s.content, op.line+s.lineDelta)
+			s.content = s.content[:offset] + op.new + s.content[offset:]
+			s.lineDelta += strings.Count(op.new, "\n")
+		default:
+			return fmt.Errorf("tool: EDIT edit %d: arg %q can be empty only when %q is empty; no files were changed", index+1, "old", target)
+		}
+		s.plan.replaced++
+		return nil
+	}
+	if !s.exists && s.content == "" {
+		return fmt.Errorf("tool: EDIT edit %d target %q does not exist; set %q to empty string to create file; no files were changed", index+1, target, "old")
+	}
+
+	count := strings.Count(s.content, op.old)
+	if count == 0 {
+		return fmt.Errorf("tool: EDIT edit %d target %q did not contain an exact match for \"old\"; READ the file again to capture the current text; no files were changed", index+1, target)
+	}
+	if op.replaceAll {
+		s.content = strings.ReplaceAll(s.content, op.old, op.new)
+		s.plan.replaced += count
+		return nil
+	}
+	index0 := strings.Index(s.content, op.old)
+	if count > 1 {
+		if op.line <= 0 {
+			return fmt.Errorf("tool: EDIT edit %d requires exact single match in %q, found %d; add surrounding context or set replace_all=true; no files were changed", index+1, target, count)
+		}
+		index0 = nearestMatchIndex(s.content, op.old, op.line+s.lineDelta)
+	}
+	s.content = s.content[:index0] + op.new + s.content[index0+len(op.old):]
+	s.lineDelta += strings.Count(op.new, "\n") - strings.Count(op.old, "\n")
+	s.plan.replaced++
+	return nil
+}
+
+// nearestMatchIndex returns the byte offset of the occurrence of needle whose
+// start line is closest to line.
+func nearestMatchIndex(content, needle string, line int) int {
+	best, bestDistance := -1, 0
+	offset, currentLine := 0, 1
+	for {
+		found := strings.Index(content[offset:], needle)
+		if found < 0 {
+			return best
+		}
+		currentLine += strings.Count(content[offset:offset+found], "\n")
+		offset += found
+		distance := currentLine - line
+		if distance < 0 {
+			distance = -distance
+		}
+		if best < 0 || distance < bestDistance {
+			best, bestDistance = offset, distance
+		}
+		currentLine += strings.Count(needle[:1], "\n")
+		offset++
+	}
+}
+
+// lineByteOffset returns the byte offset just after the first n lines.
+func lineByteOffset(content string, n int) int {
+	offset := 0
+	for i := 0; i < n; i++ {
+		next := strings.IndexByte(content[offset:], '\n')
+		if next < 0 {
+			return len(content)
+		}
+		offset += next + 1
+	}
+	return offset
+}
+
+// buildEditHunk summarizes the changed line range between before and after
+// as one hunk header.
+func buildEditHunk(before, after string) string {
+	if before == after {
+		return ""
+	}
+	oldLines := splitDiffLines(before)
+	newLines := splitDiffLines(after)
+	prefix := 0
+	for prefix < len(oldLines) && prefix < len(newLines) && oldLines[prefix] == newLines[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(oldLines)-prefix && suffix < len(newLines)-prefix &&
+		oldLines[len(oldLines)-1-suffix] == newLines[len(newLines)-1-suffix] {
+		suffix++
+	}
+	return buildPatchHunk(prefix+1, len(oldLines)-prefix-suffix, len(newLines)-prefix-suffix)
+}
+
+func rollbackEditWrites(fsys toolexec.FileSystem, written []fileMutationPlan) error {
+	var errs []error
+	for i := len(written) - 1; i >= 0; i-- {
+		plan := written[i]
+		// A created file is removed when the file system can remove files
+		// and restored to empty otherwise.
+		if remover, ok := fsys.(interface{ Remove(string) error }); ok && plan.created {
+			if err := remover.Remove(plan.path); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", plan.path, err))
+			}
+			continue
+		}
+		if err := fsys.WriteFile(plan.path, []byte(plan.before), plan.mode); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", plan.path, err))
+		}
+	}
+	return errors.Join(errs...)
+}
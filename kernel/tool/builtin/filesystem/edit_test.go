@@ -0,0 +1,199 @@
+package filesystem
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEditTool_AppliesEditsAcrossFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	first := filepath.Join(tmpDir, "a.go")
+	second := filepath.Join(tmpDir, "b.go")
+	created := filepath.Join(tmpDir, "c.go")
+	if err := os.WriteFile(first, []byte("package a\n\nfunc A() int { return 1 }\nfunc B() int { return 2 }\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(second, []byte("package b\n\nvar name = \"old\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tool, err := NewEditWithRuntime(newTestRuntime(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := tool.Run(context.Background(), map[string]any{
+		"edits": []any{
+			map[string]any{"path": first, "old": "return 1", "new": "return 10"},
+			map[string]any{"path": second, "old": `"old"`, "new": `"new"`},
+			// Later edits see the result of earlier ones on the same file.
+			map[string]any{"path": first, "old": "return 10 }\nfunc B", "new": "return 11 }\nfunc B"},
+			map[string]any{"path": created, "old": "", "new": "package c\n"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out["file_count"] != 3 || out["added_lines"] != 3 || out["removed_lines"] != 2 {
+		t.Fatalf("unexpected aggregate result %+v", out)
+	}
+	files, _ := out["files"].([]map[string]any)
+	if len(files) != 3 || files[0]["path"] != first || files[0]["replaced"] != 2 || files[2]["created"] != true {
+		t.Fatalf("unexpected per-file results %+v", files)
+	}
+	assertFileContent(t, first, "package a\n\nfunc A() int { return 11 }\nfunc B() int { return 2 }\n")
+	assertFileContent(t, second, "package b\n\nvar name = \"new\"\n")
+	assertFileContent(t, created, "package c\n")
+}
+
+func TestEditTool_FailingEditLeavesAllFilesUntouched(t *testing.T) {
+	tmpDir := t.TempDir()
+	first := filepath.Join(tmpDir, "a.txt")
+	second := filepath.Join(tmpDir, "b.txt")
+	if err := os.WriteFile(first, []byte("alpha\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(second, []byte("beta\nbeta\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tool, err := NewEditWithRuntime(newTestRuntime(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = tool.Run(context.Background(), map[string]any{
+		"edits": []any{
+			map[string]any{"path": first, "old": "alpha", "new": "ALPHA"},
+			map[string]any{"path": second, "old": "beta", "new": "BETA"},
+		},
+	})
+	if err == nil || !strings.Contains(err.Error(), "edit 2") || !strings.Contains(err.Error(), "found 2") {
+		t.Fatalf("expected ambiguous second edit to fail, got %v", err)
+	}
+	assertFileContent(t, first, "alpha\n")
+	assertFileContent(t, second, "beta\nbeta\n")
+}
+
+// removingFS is testFS with the Remove the runtime file systems provide.
+type removingFS struct {
+	testFS
+}
+
+func (removingFS) Remove(name string) error { return os.Remove(name) }
+
+func TestRollbackEditWrites_RemovesCreatedFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	edited := filepath.Join(tmpDir, "a.txt")
+	created := filepath.Join(tmpDir, "b.txt")
+	if err := os.WriteFile(edited, []byte("ALPHA\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(created, []byte("beta\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := rollbackEditWrites(removingFS{testFS{cwd: tmpDir}}, []fileMutationPlan{
+		{path: edited, before: "alpha\n", after: "ALPHA\n", mode: 0o644},
+		{path: created, created: true, after: "beta\n", mode: 0o644},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, edited, "alpha\n")
+	if _, err := os.Stat(created); !os.IsNotExist(err) {
+		t.Fatalf("expected the created file to be removed, got %v", err)
+	}
+}
+
+func TestEditTool_AppliesUnifiedDiff(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "main.go")
+	if err := os.WriteFile(path, []byte("one\ntwo\nthree\nfour\none\ntwo\nthree\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rt := newTestRuntime(t)
+	tool, err := NewEditWithRuntime(rt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wd, err := rt.FileSystem().Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rel, err := filepath.Rel(wd, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	relNew, err := filepath.Rel(wd, filepath.Join(tmpDir, "notes.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	patch := strings.Join([]string{
+		"diff --git a/" + rel + " b/" + rel,
+		"--- a/" + rel,
+		"+++ b/" + rel,
+		// The block "one/two" appears twice; the hunk line picks the second.
+		"@@ -5,2 +5,2 @@",
+		" one",
+		"-two",
+		"+TWO",
+		"--- /dev/null",
+		"+++ b/" + relNew,
+		"@@ -0,0 +1,2 @@",
+		"+first",
+		"+second",
+		"\\ No newline at end of file",
+		"",
+	}, "\n")
+
+	preview, err := BuildMutationPreview(rt, EditToolName, map[string]any{"patch": patch})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(preview.Files) != 2 || !strings.Contains(preview.Preview, "=== "+path) || preview.Created {
+		t.Fatalf("unexpected aggregated preview %+v", preview)
+	}
+
+	out, err := tool.Run(context.Background(), map[string]any{"patch": patch})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out["file_count"] != 2 {
+		t.Fatalf("expected two files, got %+v", out)
+	}
+	assertFileContent(t, path, "one\ntwo\nthree\nfour\none\nTWO\nthree\n")
+	assertFileContent(t, filepath.Join(tmpDir, "notes.txt"), "first\nsecond")
+}
+
+func TestEditTool_RejectsDeletionAndMixedInput(t *testing.T) {
+	tool, err := NewEditWithRuntime(newTestRuntime(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = tool.Run(context.Background(), map[string]any{
+		"patch": "--- a/x.txt\n+++ /dev/null\n@@ -1 +0,0 @@\n-x\n",
+	})
+	if err == nil || !strings.Contains(err.Error(), "does not delete") {
+		t.Fatalf("expected deletion to be rejected, got %v", err)
+	}
+	_, err = tool.Run(context.Background(), map[string]any{
+		"patch": "--- a/x.txt\n+++ b/x.txt\n@@ -1 +1 @@\n-x\n+y\n",
+		"edits": []any{map[string]any{"path": "x.txt", "old": "x", "new": "y"}},
+	})
+	if err == nil || !strings.Contains(err.Error(), "not both") {
+		t.Fatalf("expected mixed input to be rejected, got %v", err)
+	}
+}
+
+func assertFileContent(t *testing.T, path, want string) {
+	t.Helper()
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Fatalf("unexpected content in %s: %q", filepath.Base(path), string(got))
+	}
+}
@@ -20,6 +20,9 @@ type MutationPreview struct {
 	New     string
 	Hunk    string
 	Preview string
+	// Files holds one preview per touched file for multi-file tools such as
+	// EDIT; the top-level fields then aggregate all of them.
+	Files []MutationPreview
 }
 
 type fileMutationPlan struct {
@@ -39,6 +42,13 @@ func BuildMutationPreview(runtime toolexec.Runtime, toolName string, args map[st
 	if err != nil {
 		return MutationPreview{}, err
 	}
+	if strings.EqualFold(strings.TrimSpace(toolName), EditToolName) {
+		plans, err := planEditMutation(resolvedRuntime.FileSystem(), args)
+		if err != nil {
+			return MutationPreview{}, err
+		}
+		return aggregateMutationPreview(EditToolName, plans), nil
+	}
 
 	plan, err := buildMutationPlan(resolvedRuntime.FileSystem(), toolName, args)
 	if err != nil {
@@ -55,6 +65,58 @@ func BuildMutationPreview(runtime toolexec.Runtime, toolName string, args map[st
 	}, nil
 }
 
+// aggregateMutationPreview folds per-file plans into one preview. With more
+// than one file, Old and New carry a shared "=== path" header line per file so
+// a line diff of the pair stays aligned file by file.
+func aggregateMutationPreview(toolName string, plans []fileMutationPlan) MutationPreview {
+	files := make([]MutationPreview, 0, len(plans))
+	for _, plan := range plans {
+		files = append(files, MutationPreview{
+			Tool:    plan.tool,
+			Path:    plan.path,
+			Created: plan.created,
+			Old:     plan.before,
+			New:     plan.after,
+			Hunk:    plan.hunk,
+			Preview: buildPatchPreview(plan.before, plan.after),
+		})
+	}
+	if len(files) == 1 {
+		out := files[0]
+		out.Tool = toolName
+		out.Files = files
+		return out
+	}
+	out := MutationPreview{Tool: toolName, Files: files, Created: true}
+	var oldText, newText strings.Builder
+	previews := make([]string, 0, len(files))
+	for i, file := range files {
+		if i == 0 {
+			out.Path = file.Path
+		}
+		out.Created = out.Created && file.Created
+		header := "=== " + file.Path + "\n"
+		oldText.WriteString(header)
+		newText.WriteString(header)
+		oldText.WriteString(withTrailingNewline(file.Old))
+		newText.WriteString(withTrailingNewline(file.New))
+		if file.Preview != "" {
+			previews = append(previews, "=== "+file.Path+"\n"+file.Preview)
+		}
+	}
+	out.Old = oldText.String()
+	out.New = newText.String()
+	out.Preview = strings.Join(previews, "\n")
+	return out
+}
+
+func withTrailingNewline(text string) string {
+	if text == "" || strings.HasSuffix(text, "\n") {
+		return text
+	}
+	return text + "\n"
+}
+
 func buildMutationPlan(fsys toolexec.FileSystem, toolName string, args map[string]any) (fileMutationPlan, error) {
 	switch strings.ToUpper(strings.TrimSpace(toolName)) {
 	case PatchToolName:
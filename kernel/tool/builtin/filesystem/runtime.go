@@ -148,6 +148,12 @@ func hasPathGlobMeta(pattern string) bool {
 	return strings.ContainsAny(pattern, "*?[")
 }
 
+// MatchPathGlob reports whether a slash-separated relative path matches a
+// glob where "**" spans any number of directories.
+func MatchPathGlob(pattern, rel string) bool {
+	return pathGlobMatch(pattern, rel)
+}
+
 func pathGlobMatch(pattern, rel string) bool {
 	pattern = normalizeRelativeMatchPath(pattern)
 	rel = normalizeRelativeMatchPath(rel)
@@ -4,9 +4,13 @@ import (
 	"bufio"
 	"context"
 	"errors"
+	"fmt"
 	"io/fs"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
+	"unicode/utf8"
 
 	toolexec "github.com/OnslaughtSnail/caelis/kernel/execenv"
 	"github.com/OnslaughtSnail/caelis/kernel/model"
@@ -16,6 +20,19 @@ import (
 
 const (
 	SearchToolName = "SEARCH"
+
+	// Output modes accepted by the output_mode arg.
+	SearchOutputContent   = "content"
+	SearchOutputFilesOnly = "files_only"
+	SearchOutputCount     = "count"
+
+	searchDefaultLimit = 50
+	searchMaxLimit     = 200
+	searchMaxContext   = 20
+	// searchMaxMultilineBytes bounds files loaded whole for multiline matching.
+	searchMaxMultilineBytes = 8 * 1024 * 1024
+	// searchMaxHitTextBytes bounds the text reported for one multiline hit.
+	searchMaxHitTextBytes = 4096
 )
 
 var errSearchLimitReached = errors.New("search: limit reached")
@@ -37,7 +54,7 @@ func (t *SearchTool) Name() string {
 }
 
 func (t *SearchTool) Description() string {
-	return "Search text in one file or a directory tree."
+	return "Search text in one file or a directory tree, literally or with RE2 regex."
 }
 
 func (t *SearchTool) Capability() capability.Capability {
@@ -55,20 +72,42 @@ func (t *SearchTool) Declaration() model.ToolDefinition {
 			"type": "object",
 			"properties": map[string]any{
 				"path":           map[string]any{"type": "string", "description": "Target file or directory path."},
-				"query":          map[string]any{"type": "string", "description": "Search text."},
-				"limit":          map[string]any{"type": "integer", "description": "Optional max results."},
+				"query":          map[string]any{"type": "string", "description": "Search text, or an RE2 pattern when regex is true."},
+				"limit":          map[string]any{"type": "integer", "description": "Optional max results (hits, or files in files_only/count mode). Max 200."},
 				"case_sensitive": map[string]any{"type": "boolean", "description": "Set true for case-sensitive search."},
+				"regex":          map[string]any{"type": "boolean", "description": "Treat query as an RE2 regular expression."},
+				"multiline":      map[string]any{"type": "boolean", "description": "Match across line breaks; ^ and $ match at line boundaries and . also matches newlines."},
+				"include": map[string]any{
+					"type":        "array",
+					"description": "Optional globs a file must match, e.g. \"*.go\" or \"src/**/*.ts\". Patterns without a slash match the file name.",
+					"items":       map[string]any{"type": "string"},
+				},
 				"exclude": map[string]any{
 					"type":        "array",
 					"description": "Optional relative path patterns to exclude after gitignore filtering.",
 					"items":       map[string]any{"type": "string"},
 				},
+				"context_before": map[string]any{"type": "integer", "description": "Lines of context to return before each hit (content mode, max 20)."},
+				"context_after":  map[string]any{"type": "integer", "description": "Lines of context to return after each hit (content mode, max 20)."},
+				"output_mode": map[string]any{
+					"type":        "string",
+					"enum":        []string{SearchOutputContent, SearchOutputFilesOnly, SearchOutputCount},
+					"description": "content returns hits (default), files_only returns matching file paths, count returns per-file match counts.",
+				},
 			},
 			"required": []string{"path", "query"},
 		},
 	}
 }
 
+// searchOptions carries the parsed per-call search settings.
+type searchOptions struct {
+	pattern       searchPattern
+	multiline     bool
+	contextBefore int
+	contextAfter  int
+}
+
 func (t *SearchTool) Run(ctx context.Context, args map[string]any) (map[string]any, error) {
 	select {
 	case <-ctx.Done():
@@ -83,24 +122,69 @@ func (t *SearchTool) Run(ctx context.Context, args map[string]any) (map[string]a
 	if err != nil {
 		return nil, err
 	}
-	limit, err := argparse.Int(args, "limit", 50)
+	limit, err := argparse.Int(args, "limit", searchDefaultLimit)
 	if err != nil {
 		return nil, err
 	}
 	if limit <= 0 {
-		limit = 50
+		limit = searchDefaultLimit
 	}
-	if limit > 200 {
-		limit = 200
+	if limit > searchMaxLimit {
+		limit = searchMaxLimit
 	}
 	caseSensitive := false
 	if raw, ok := args["case_sensitive"].(bool); ok {
 		caseSensitive = raw
 	}
+	useRegex, err := argparse.Bool(args, "regex", false)
+	if err != nil {
+		return nil, err
+	}
+	multiline, err := argparse.Bool(args, "multiline", false)
+	if err != nil {
+		return nil, err
+	}
+	mode, err := argparse.String(args, "output_mode", false)
+	if err != nil {
+		return nil, err
+	}
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	switch mode {
+	case "":
+		mode = SearchOutputContent
+	case SearchOutputContent, SearchOutputFilesOnly, SearchOutputCount:
+	default:
+		return nil, fmt.Errorf("tool: arg %q must be one of %s, %s, %s", "output_mode", SearchOutputContent, SearchOutputFilesOnly, SearchOutputCount)
+	}
+	contextBefore, err := argparse.Int(args, "context_before", 0)
+	if err != nil {
+		return nil, err
+	}
+	contextAfter, err := argparse.Int(args, "context_after", 0)
+	if err != nil {
+		return nil, err
+	}
+	if mode != SearchOutputContent {
+		contextBefore, contextAfter = 0, 0
+	}
+	include, err := parseStringSliceArg(args, "include")
+	if err != nil {
+		return nil, err
+	}
 	exclude, err := parseStringSliceArg(args, "exclude")
 	if err != nil {
 		return nil, err
 	}
+	pattern, err := compileSearchPattern(query, useRegex, caseSensitive, multiline)
+	if err != nil {
+		return nil, err
+	}
+	opts := searchOptions{
+		pattern:       pattern,
+		multiline:     multiline,
+		contextBefore: clampInt(contextBefore, 0, searchMaxContext),
+		contextAfter:  clampInt(contextAfter, 0, searchMaxContext),
+	}
 	target, err := normalizePathWithFS(t.runtime.FileSystem(), pathArg)
 	if err != nil {
 		return nil, err
@@ -114,27 +198,7 @@ func (t *SearchTool) Run(ctx context.Context, args map[string]any) (map[string]a
 		return nil, err
 	}
 
-	queryToMatch := query
-	if !caseSensitive {
-		queryToMatch = strings.ToLower(query)
-	}
-	results := make([]map[string]any, 0, limit)
-	filesWithHits := map[string]struct{}{}
-	truncated := false
-	appendMatch := func(path string, lineNum, column int, text string) bool {
-		filesWithHits[path] = struct{}{}
-		results = append(results, map[string]any{
-			"path":   path,
-			"line":   lineNum,
-			"column": column,
-			"text":   text,
-		})
-		if len(results) >= limit {
-			truncated = true
-		}
-		return len(results) >= limit
-	}
-	scannedFiles := 0
+	collector := newSearchCollector(mode, limit)
 	root := target
 	if !info.IsDir() {
 		root = filepath.Dir(target)
@@ -166,10 +230,10 @@ func (t *SearchTool) Run(ctx context.Context, args map[string]any) (map[string]a
 			if d == nil || d.IsDir() {
 				return nil
 			}
-			scannedFiles++
-			matched, stop := searchInFile(t.runtime.FileSystem(), path, queryToMatch, caseSensitive, appendMatch)
-			_ = matched
-			if stop {
+			if !matchesIncludePatterns(root, path, include) {
+				return nil
+			}
+			if searchFile(t.runtime.FileSystem(), path, opts, collector) {
 				return errSearchLimitReached
 			}
 			return nil
@@ -177,66 +241,378 @@ func (t *SearchTool) Run(ctx context.Context, args map[string]any) (map[string]a
 		if walkErr != nil && !errors.Is(walkErr, errSearchLimitReached) {
 			return nil, walkErr
 		}
-	} else {
-		if shouldExcludePath(root, target, false, exclude) {
-			return map[string]any{
-				"path":       target,
-				"query":      query,
-				"count":      0,
-				"file_count": 0,
-				"truncated":  false,
-				"hits":       []map[string]any{},
-			}, nil
-		}
-		scannedFiles = 1
-		_, stop := searchInFile(t.runtime.FileSystem(), target, queryToMatch, caseSensitive, appendMatch)
-		if stop {
-			truncated = true
+	} else if !shouldExcludePath(root, target, false, exclude) {
+		// An explicitly named file is always searched; include only filters
+		// directory walks.
+		searchFile(t.runtime.FileSystem(), target, opts, collector)
+	}
+
+	return collector.result(target, query), nil
+}
+
+func (t *SearchTool) WithRuntime(runtime toolexec.Runtime) (*SearchTool, error) {
+	return NewSearchWithRuntime(runtime)
+}
+
+// searchPattern matches either a literal query or a compiled RE2 expression.
+type searchPattern struct {
+	literal string
+	re      *regexp.Regexp
+}
+
+func compileSearchPattern(query string, useRegex, caseSensitive, multiline bool) (searchPattern, error) {
+	if !useRegex {
+		if caseSensitive {
+			return searchPattern{literal: query}, nil
+		}
+		// Case folding can change a text's byte length, so a lowered copy of
+		// the text would not share its offsets; the regexp folds in place.
+		return searchPattern{re: regexp.MustCompile("(?i)" + regexp.QuoteMeta(query))}, nil
+	}
+	flags := ""
+	if !caseSensitive {
+		flags += "i"
+	}
+	if multiline {
+		flags += "ms"
+	}
+	expr := query
+	if flags != "" {
+		expr = "(?" + flags + ")" + query
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return searchPattern{}, fmt.Errorf("tool: invalid regex %q: %w", query, err)
+	}
+	return searchPattern{re: re}, nil
+}
+
+// find returns the byte offsets of the first match in text, or -1 when there
+// is none.
+func (p searchPattern) find(text string) (int, int) {
+	if p.re != nil {
+		loc := p.re.FindStringIndex(text)
+		if loc == nil {
+			return -1, -1
+		}
+		return loc[0], loc[1]
+	}
+	idx := strings.Index(text, p.literal)
+	if idx < 0 {
+		return -1, -1
+	}
+	return idx, idx + len(p.literal)
+}
+
+// findAll returns the byte offsets of every non-overlapping match in text.
+// Regexps run once over the whole text, so ^, $ and \b see the real text
+// around each match rather than the start of a re-sliced remainder.
+func (p searchPattern) findAll(text string) [][]int {
+	if p.re != nil {
+		return p.re.FindAllStringIndex(text, -1)
+	}
+	var out [][]int
+	for from := 0; from <= len(text); {
+		idx := strings.Index(text[from:], p.literal)
+		if idx < 0 {
+			break
 		}
+		start := from + idx
+		out = append(out, []int{start, start + len(p.literal)})
+		from = start + max(len(p.literal), 1)
 	}
+	return out
+}
 
-	return map[string]any{
+// searchHit is one match before it is rendered into the tool result.
+type searchHit struct {
+	path    string
+	line    int
+	endLine int
+	column  int
+	text    string
+	before  []string
+	after   []string
+}
+
+// searchCollector accumulates hits according to the output mode and enforces
+// the result limit.
+type searchCollector struct {
+	mode      string
+	limit     int
+	hits      []*searchHit
+	fileOrder []string
+	fileHits  map[string]int
+	matches   int
+	truncated bool
+}
+
+func newSearchCollector(mode string, limit int) *searchCollector {
+	return &searchCollector{mode: mode, limit: limit, fileHits: map[string]int{}}
+}
+
+// add records one match. fileDone reports that the rest of the file can be
+// skipped; stop reports that the limit is reached.
+func (c *searchCollector) add(hit *searchHit) (fileDone, stop bool) {
+	if _, seen := c.fileHits[hit.path]; !seen {
+		if c.mode != SearchOutputContent && len(c.fileOrder) >= c.limit {
+			c.truncated = true
+			return true, true
+		}
+		c.fileOrder = append(c.fileOrder, hit.path)
+	}
+	c.fileHits[hit.path]++
+	c.matches++
+	switch c.mode {
+	case SearchOutputFilesOnly:
+		return true, false
+	case SearchOutputCount:
+		return false, false
+	default:
+		c.hits = append(c.hits, hit)
+		if len(c.hits) >= c.limit {
+			c.truncated = true
+			return true, true
+		}
+		return false, false
+	}
+}
+
+func (c *searchCollector) result(target, query string) map[string]any {
+	out := map[string]any{
 		"path":       target,
 		"query":      query,
-		"count":      len(results),
-		"file_count": len(filesWithHits),
-		"truncated":  truncated,
-		"hits":       results,
-	}, nil
+		"file_count": len(c.fileOrder),
+		"truncated":  c.truncated,
+	}
+	switch c.mode {
+	case SearchOutputFilesOnly:
+		files := append([]string(nil), c.fileOrder...)
+		sort.Strings(files)
+		out["output_mode"] = SearchOutputFilesOnly
+		out["count"] = len(files)
+		out["files"] = files
+	case SearchOutputCount:
+		files := make([]map[string]any, 0, len(c.fileOrder))
+		for _, path := range c.fileOrder {
+			files = append(files, map[string]any{"path": path, "count": c.fileHits[path]})
+		}
+		out["output_mode"] = SearchOutputCount
+		out["count"] = c.matches
+		out["files"] = files
+	default:
+		hits := make([]map[string]any, 0, len(c.hits))
+		for _, hit := range c.hits {
+			hits = append(hits, hit.toMap())
+		}
+		out["count"] = len(hits)
+		out["hits"] = hits
+	}
+	return out
 }
 
-func (t *SearchTool) WithRuntime(runtime toolexec.Runtime) (*SearchTool, error) {
-	return NewSearchWithRuntime(runtime)
+func (h *searchHit) toMap() map[string]any {
+	out := map[string]any{
+		"path":   h.path,
+		"line":   h.line,
+		"column": h.column,
+		"text":   h.text,
+	}
+	if h.endLine > h.line {
+		out["end_line"] = h.endLine
+	}
+	if len(h.before) > 0 {
+		out["before"] = h.before
+	}
+	if len(h.after) > 0 {
+		out["after"] = h.after
+	}
+	return out
 }
 
-func searchInFile(fsys toolexec.FileSystem, path, query string, caseSensitive bool, appendMatch func(string, int, int, string) bool) (bool, bool) {
+// searchFile searches one file and reports whether the overall limit was hit.
+func searchFile(fsys toolexec.FileSystem, path string, opts searchOptions, collector *searchCollector) bool {
+	if opts.multiline {
+		return searchFileMultiline(fsys, path, opts, collector)
+	}
+	return searchInFile(fsys, path, opts, collector)
+}
+
+func searchInFile(fsys toolexec.FileSystem, path string, opts searchOptions, collector *searchCollector) bool {
 	file, err := fsys.Open(path)
 	if err != nil {
-		return false, false
+		return false
 	}
 	defer file.Close()
 
 	scanner := bufio.NewScanner(file)
 	scanner.Buffer(make([]byte, 0, 64*1024), 8*1024*1024)
-	matched := false
-	lineNum := 0
+	var (
+		before  []string
+		pending []*searchHit
+		lineNum int
+		// drain keeps reading after the limit only to fill trailing context.
+		drain bool
+		stop  bool
+	)
 	for scanner.Scan() {
 		lineNum++
 		text := scanner.Text()
-		candidate := text
-		if !caseSensitive {
-			candidate = strings.ToLower(candidate)
-		}
-		if strings.Contains(candidate, query) {
-			matched = true
-			column := strings.Index(candidate, query) + 1
-			if column <= 0 {
-				column = 1
+		if len(pending) > 0 {
+			kept := pending[:0]
+			for _, hit := range pending {
+				hit.after = append(hit.after, text)
+				if len(hit.after) < opts.contextAfter {
+					kept = append(kept, hit)
+				}
+			}
+			pending = kept
+		}
+		if drain {
+			if len(pending) == 0 {
+				break
+			}
+			continue
+		}
+		if start, _ := opts.pattern.find(text); start >= 0 {
+			hit := &searchHit{path: path, line: lineNum, column: start + 1, text: text}
+			if opts.contextBefore > 0 && len(before) > 0 {
+				hit.before = append([]string(nil), before...)
 			}
-			if appendMatch(path, lineNum, column, text) {
-				return true, true
+			fileDone, limitHit := collector.add(hit)
+			if opts.contextAfter > 0 {
+				pending = append(pending, hit)
+			}
+			if limitHit {
+				stop = true
+			}
+			if fileDone {
+				drain = true
+				if len(pending) == 0 {
+					break
+				}
+				continue
+			}
+		}
+		if opts.contextBefore > 0 {
+			before = append(before, text)
+			if len(before) > opts.contextBefore {
+				before = before[1:]
 			}
 		}
 	}
-	return matched, false
+	return stop
+}
+
+func searchFileMultiline(fsys toolexec.FileSystem, path string, opts searchOptions, collector *searchCollector) bool {
+	info, err := fsys.Stat(path)
+	if err != nil || info.Size() > searchMaxMultilineBytes {
+		return false
+	}
+	raw, err := fsys.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	content := string(raw)
+	lineStarts := []int{0}
+	for i := 0; i < len(content); i++ {
+		if content[i] == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+	lineAt := func(offset int) int {
+		return sort.Search(len(lineStarts), func(i int) bool { return lineStarts[i] > offset })
+	}
+	lineText := func(line int) string {
+		start := lineStarts[line-1]
+		end := len(content)
+		if line < len(lineStarts) {
+			end = lineStarts[line] - 1
+		}
+		return strings.TrimSuffix(content[start:end], "\r")
+	}
+	lineCount := len(lineStarts)
+	if strings.HasSuffix(content, "\n") {
+		lineCount--
+	}
+
+	for _, loc := range opts.pattern.findAll(content) {
+		start, end := loc[0], loc[1]
+		startLine := lineAt(start)
+		endLine := startLine
+		if end > start {
+			endLine = lineAt(end - 1)
+		}
+		lines := make([]string, 0, endLine-startLine+1)
+		for line := startLine; line <= endLine; line++ {
+			lines = append(lines, lineText(line))
+		}
+		hit := &searchHit{
+			path:    path,
+			line:    startLine,
+			endLine: endLine,
+			column:  start - lineStarts[startLine-1] + 1,
+			text:    truncateHitText(strings.Join(lines, "\n")),
+		}
+		for line := max(1, startLine-opts.contextBefore); line < startLine; line++ {
+			hit.before = append(hit.before, lineText(line))
+		}
+		for line := endLine + 1; line <= min(lineCount, endLine+opts.contextAfter); line++ {
+			hit.after = append(hit.after, lineText(line))
+		}
+		fileDone, stop := collector.add(hit)
+		if stop {
+			return true
+		}
+		if fileDone {
+			return false
+		}
+	}
+	return false
+}
+
+func truncateHitText(text string) string {
+	if len(text) <= searchMaxHitTextBytes {
+		return text
+	}
+	cut := searchMaxHitTextBytes
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return text[:cut] + "\n... (match truncated)"
+}
+
+// matchesIncludePatterns reports whether path passes the include globs.
+// Patterns without a slash are matched against the file name, so "*.go"
+// behaves like rg -g '*.go'.
+func matchesIncludePatterns(root, path string, patterns []string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	rel := path
+	if computed, err := filepath.Rel(root, path); err == nil {
+		rel = computed
+	}
+	rel = normalizeRelativeMatchPath(rel)
+	base := filepath.Base(path)
+	for _, pattern := range patterns {
+		pattern = normalizeRelativeMatchPath(pattern)
+		if pattern == "" {
+			continue
+		}
+		if !strings.Contains(pattern, "/") {
+			if matchPathGlobSegments([]string{pattern}, []string{base}) {
+				return true
+			}
+			continue
+		}
+		if pathGlobMatch(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+func clampInt(value, low, high int) int {
+	return min(max(value, low), high)
 }
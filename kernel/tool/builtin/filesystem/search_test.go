@@ -5,6 +5,7 @@ import (
 	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	toolexec "github.com/OnslaughtSnail/caelis/kernel/execenv"
@@ -77,6 +78,51 @@ func TestSearchTool_ReturnsColumn(t *testing.T) {
 	}
 }
 
+func TestSearchPattern_CaseInsensitiveLiteralKeepsOffsets(t *testing.T) {
+	pattern, err := compileSearchPattern("hello.", false, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// İ lowercases to a longer byte sequence, which would shift offsets
+	// taken from a lowered copy.
+	text := "İİ say HELLO. then hello!"
+	matches := pattern.findAll(text)
+	if want := strings.Index(text, "HELLO."); len(matches) != 1 || matches[0][0] != want || matches[0][1] != want+len("HELLO.") {
+		t.Fatalf("expected one match at %d with the dot matched literally, got %v", want, matches)
+	}
+}
+
+func TestSearchTool_MultilineAnchorsSeeTheWholeFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "a.txt")
+	if err := os.WriteFile(path, []byte("foofoofoo\nbar foo\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tool, err := NewSearchWithRuntime(newTestRuntime(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for query, want := range map[string]int{
+		`^foo`:    1,
+		`\bfoo\b`: 1,
+		`foo$`:    2,
+	} {
+		out, err := tool.Run(context.Background(), map[string]any{
+			"path":        path,
+			"query":       query,
+			"regex":       true,
+			"multiline":   true,
+			"output_mode": "count",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out["count"] != want {
+			t.Fatalf("%s: expected %d matches, got %v", query, want, out)
+		}
+	}
+}
+
 func TestSearchTool_RespectsGitignore(t *testing.T) {
 	tmpDir := t.TempDir()
 	if err := os.Mkdir(filepath.Join(tmpDir, ".git"), 0o755); err != nil {
@@ -204,3 +250,133 @@ func TestSearchTool_RestrictedReadableRootsRejectOutsideWorkspace(t *testing.T)
 		t.Fatalf("expected permission error for outside-workspace search, got %v", err)
 	}
 }
+
+func TestSearchTool_RegexIncludeAndContext(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "main.go"), []byte("package main\n\nfunc Run() {}\nfunc helper() {}\n// end\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "notes.txt"), []byte("func Run() is documented here\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tool, err := NewSearchWithRuntime(newTestRuntime(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := tool.Run(context.Background(), map[string]any{
+		"path":           tmpDir,
+		"query":          `^func [A-Z]\w*\(`,
+		"regex":          true,
+		"case_sensitive": true,
+		"include":        []any{"*.go"},
+		"context_before": 1,
+		"context_after":  2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hits, ok := out["hits"].([]map[string]any)
+	if !ok || len(hits) != 1 {
+		t.Fatalf("expected one exported func hit in main.go, got %v", out["hits"])
+	}
+	hit := hits[0]
+	if hit["path"] != filepath.Join(tmpDir, "main.go") || hit["line"] != 3 || hit["column"] != 1 {
+		t.Fatalf("unexpected hit %v", hit)
+	}
+	before, _ := hit["before"].([]string)
+	after, _ := hit["after"].([]string)
+	if len(before) != 1 || before[0] != "" || len(after) != 2 || after[0] != "func helper() {}" || after[1] != "// end" {
+		t.Fatalf("unexpected context before=%q after=%q", before, after)
+	}
+
+	if _, err := tool.Run(context.Background(), map[string]any{"path": tmpDir, "query": "(", "regex": true}); err == nil {
+		t.Fatal("expected invalid regex to fail")
+	}
+}
+
+func TestSearchTool_FilesOnlyAndCountModes(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "a.txt"), []byte("todo one\ntodo two\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "b.txt"), []byte("TODO three\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "c.txt"), []byte("done\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tool, err := NewSearchWithRuntime(newTestRuntime(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := tool.Run(context.Background(), map[string]any{"path": tmpDir, "query": "todo", "output_mode": "files_only"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, ok := out["files"].([]string)
+	if !ok || len(files) != 2 || out["count"] != 2 || out["hits"] != nil {
+		t.Fatalf("unexpected files_only result %v", out)
+	}
+
+	out, err = tool.Run(context.Background(), map[string]any{"path": tmpDir, "query": "todo", "output_mode": "count"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts, ok := out["files"].([]map[string]any)
+	if !ok || len(counts) != 2 || out["count"] != 3 || out["file_count"] != 2 {
+		t.Fatalf("unexpected count result %v", out)
+	}
+	for _, item := range counts {
+		if item["path"] == filepath.Join(tmpDir, "a.txt") && item["count"] != 2 {
+			t.Fatalf("expected 2 matches in a.txt, got %v", item)
+		}
+	}
+
+	out, err = tool.Run(context.Background(), map[string]any{"path": tmpDir, "query": "todo", "output_mode": "files_only", "limit": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out["count"] != 1 || out["truncated"] != true {
+		t.Fatalf("expected files_only limit to truncate, got %v", out)
+	}
+
+	if _, err := tool.Run(context.Background(), map[string]any{"path": tmpDir, "query": "todo", "output_mode": "lines"}); err == nil {
+		t.Fatal("expected unknown output_mode to fail")
+	}
+}
+
+func TestSearchTool_MultilineRegex(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "config.yaml")
+	if err := os.WriteFile(path, []byte("name: demo\nserver:\n  port: 8080\nclient:\n  port: 9090\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tool, err := NewSearchWithRuntime(newTestRuntime(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := tool.Run(context.Background(), map[string]any{
+		"path":          path,
+		"query":         `^server:\n\s+port: \d+$`,
+		"regex":         true,
+		"multiline":     true,
+		"context_after": 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hits, ok := out["hits"].([]map[string]any)
+	if !ok || len(hits) != 1 {
+		t.Fatalf("expected one multiline hit, got %v", out["hits"])
+	}
+	hit := hits[0]
+	if hit["line"] != 2 || hit["end_line"] != 3 || hit["text"] != "server:\n  port: 8080" {
+		t.Fatalf("unexpected multiline hit %v", hit)
+	}
+	if after, _ := hit["after"].([]string); len(after) != 1 || after[0] != "client:" {
+		t.Fatalf("unexpected trailing context %v", hit["after"])
+	}
+}
@@ -0,0 +1,161 @@
+package filesystem
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const devNullPath = "/dev/null"
+
+var unifiedHunkHeaderPattern = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// parseUnifiedDiff converts unified diff text into exact-match edits. Each
+// hunk becomes one edit whose old text is its context plus removed lines and
+// whose new text is its context plus added lines; the hunk's old start line
+// is kept as a hint to disambiguate repeated blocks.
+func parseUnifiedDiff(text string) ([]editOp, error) {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	lines := strings.Split(text, "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	var (
+		ops      []editOp
+		filePath string
+		creating bool
+		haveFile bool
+	)
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "--- "):
+			if i+1 >= len(lines) || !strings.HasPrefix(lines[i+1], "+++ ") {
+				return nil, fmt.Errorf("tool: EDIT patch line %d: %q must be followed by a \"+++ \" header", i+1, line)
+			}
+			oldPath, newPath := unifiedDiffPaths(line[4:], lines[i+1][4:])
+			i++
+			if newPath == devNullPath {
+				return nil, fmt.Errorf("tool: EDIT does not delete files; %q targets /dev/null", oldPath)
+			}
+			if oldPath != devNullPath && oldPath != newPath {
+				return nil, fmt.Errorf("tool: EDIT does not rename files; got %q -> %q", oldPath, newPath)
+			}
+			filePath = newPath
+			creating = oldPath == devNullPath
+			haveFile = true
+		case strings.HasPrefix(line, "@@"):
+			if !haveFile {
+				return nil, fmt.Errorf("tool: EDIT patch line %d: hunk before file headers", i+1)
+			}
+			op, next, err := parseUnifiedHunk(lines, i)
+			if err != nil {
+				return nil, err
+			}
+			op.path = filePath
+			op.create = creating
+			ops = append(ops, op)
+			i = next - 1
+		default:
+			// git metadata ("diff --git", "index", mode lines) and free-form
+			// preamble carry nothing the edit needs.
+		}
+	}
+	if len(ops) == 0 {
+		return nil, fmt.Errorf("tool: EDIT patch contains no hunks")
+	}
+	return ops, nil
+}
+
+// parseUnifiedHunk reads the hunk starting at lines[start] and returns the
+// edit along with the index of the first line after the hunk.
+func parseUnifiedHunk(lines []string, start int) (editOp, int, error) {
+	match := unifiedHunkHeaderPattern.FindStringSubmatch(lines[start])
+	if match == nil {
+		return editOp{}, 0, fmt.Errorf("tool: EDIT patch line %d: malformed hunk header %q", start+1, lines[start])
+	}
+	oldStart, _ := strconv.Atoi(match[1])
+	oldRemaining := hunkCount(match[2])
+	newRemaining := hunkCount(match[4])
+
+	var oldText, newText strings.Builder
+	lastKind := byte(0)
+	i := start + 1
+	for ; i < len(lines) && (oldRemaining > 0 || newRemaining > 0 || strings.HasPrefix(lines[i], `\`)); i++ {
+		line := lines[i]
+		kind := byte(' ')
+		body := ""
+		if line != "" {
+			kind, body = line[0], line[1:]
+		}
+		switch kind {
+		case ' ':
+			oldText.WriteString(body + "\n")
+			newText.WriteString(body + "\n")
+			oldRemaining--
+			newRemaining--
+		case '-':
+			oldText.WriteString(body + "\n")
+			oldRemaining--
+		case '+':
+			newText.WriteString(body + "\n")
+			newRemaining--
+		case '\\':
+			// "\ No newline at end of file" applies to the preceding line.
+			if lastKind == ' ' || lastKind == '-' {
+				trimBuilderNewline(&oldText)
+			}
+			if lastKind == ' ' || lastKind == '+' {
+				trimBuilderNewline(&newText)
+			}
+		default:
+			return editOp{}, 0, fmt.Errorf("tool: EDIT patch line %d: unexpected hunk line %q", i+1, line)
+		}
+		lastKind = kind
+		if oldRemaining < 0 || newRemaining < 0 {
+			return editOp{}, 0, fmt.Errorf("tool: EDIT patch hunk at line %d has more lines than its header declares", start+1)
+		}
+	}
+	if oldRemaining > 0 || newRemaining > 0 {
+		return editOp{}, 0, fmt.Errorf("tool: EDIT patch hunk at line %d is truncated", start+1)
+	}
+	return editOp{
+		old:  oldText.String(),
+		new:  newText.String(),
+		line: oldStart,
+	}, i, nil
+}
+
+func hunkCount(raw string) int {
+	if raw == "" {
+		return 1
+	}
+	value, _ := strconv.Atoi(raw)
+	return value
+}
+
+func trimBuilderNewline(b *strings.Builder) {
+	text := strings.TrimSuffix(b.String(), "\n")
+	b.Reset()
+	b.WriteString(text)
+}
+
+// unifiedDiffPaths strips timestamps and git's a/ b/ prefixes from one
+// "---"/"+++" header pair.
+func unifiedDiffPaths(rawOld, rawNew string) (string, string) {
+	oldPath := strings.TrimSpace(strings.SplitN(rawOld, "\t", 2)[0])
+	newPath := strings.TrimSpace(strings.SplitN(rawNew, "\t", 2)[0])
+	oldGit := oldPath == devNullPath || strings.HasPrefix(oldPath, "a/")
+	newGit := newPath == devNullPath || strings.HasPrefix(newPath, "b/")
+	if oldGit && newGit && !(oldPath == devNullPath && newPath == devNullPath) {
+		if oldPath != devNullPath {
+			oldPath = oldPath[2:]
+		}
+		if newPath != devNullPath {
+			newPath = newPath[2:]
+		}
+	}
+	return oldPath, newPath
+}
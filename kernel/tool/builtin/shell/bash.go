@@ -2,13 +2,18 @@ package shell
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	toolexec "github.com/OnslaughtSnail/caelis/kernel/execenv"
 	"github.com/OnslaughtSnail/caelis/kernel/model"
 	"github.com/OnslaughtSnail/caelis/kernel/policy"
+	"github.com/OnslaughtSnail/caelis/kernel/session"
 	"github.com/OnslaughtSnail/caelis/kernel/task"
 	"github.com/OnslaughtSnail/caelis/kernel/taskstream"
 	"github.com/OnslaughtSnail/caelis/kernel/tool/builtin/internal/argparse"
@@ -92,6 +97,22 @@ func (t *BashTool) Declaration() model.ToolDefinition {
 					"type":        "integer",
 					"description": "Optional total command timeout in milliseconds. Defaults to 1800000.",
 				},
+				"max_cpu_seconds": map[string]any{
+					"type":        "integer",
+					"description": "Optional CPU time cap in seconds. Can only tighten the workspace limit.",
+				},
+				"max_memory_mb": map[string]any{
+					"type":        "integer",
+					"description": "Optional memory cap in MiB. Can only tighten the workspace limit.",
+				},
+				"max_processes": map[string]any{
+					"type":        "integer",
+					"description": "Optional process count cap. Can only tighten the workspace limit.",
+				},
+				"max_output_bytes": map[string]any{
+					"type":        "integer",
+					"description": "Optional cap on stdout plus stderr; the command is stopped once it is reached.",
+				},
 			},
 			"required":             []string{"command"},
 			"additionalProperties": false,
@@ -111,6 +132,7 @@ func (t *BashTool) Run(ctx context.Context, args map[string]any) (map[string]any
 	if strings.TrimSpace(workingDir) == "" {
 		workingDir, _ = argparse.String(args, "dir", false)
 	}
+	explicitDir := workingDir
 	if strings.TrimSpace(workingDir) == "" && t.runtime != nil && t.runtime.FileSystem() != nil {
 		workingDir, _ = t.runtime.FileSystem().Getwd()
 	}
@@ -132,6 +154,10 @@ func (t *BashTool) Run(ctx context.Context, args map[string]any) (map[string]any
 	if err != nil {
 		return nil, err
 	}
+	limits, err := parseResourceLimitArgs(args)
+	if err != nil {
+		return nil, err
+	}
 
 	timeout := t.cfg.Timeout
 	if timeoutMS > 0 {
@@ -168,6 +194,44 @@ func (t *BashTool) Run(ctx context.Context, args map[string]any) (map[string]any
 			return nil, err
 		}
 	}
+	denials := &networkDenials{}
+	if shellRunner, key, ok := t.persistentShell(ctx); ok {
+		// The shell keeps its own working directory, so only an explicit
+		// workdir moves it. Output streams to the call until it yields.
+		var yielded atomic.Bool
+		shellSession, err := shellRunner.StartInShell(ctx, key, toolexec.CommandRequest{
+			Command:           command,
+			Dir:               explicitDir,
+			Timeout:           timeout,
+			IdleTimeout:       idleTimeout,
+			SandboxPermission: sandboxPermission,
+			RouteHint:         decision.Route,
+			BackendName:       decision.Backend,
+			OnOutput: func(chunk toolexec.CommandOutputChunk) {
+				if !yielded.Load() {
+					toolexec.EmitOutputChunk(ctx, chunk)
+				}
+			},
+			OnNetworkDenied: denials.add,
+			Limits:          limits,
+		})
+		switch {
+		case errors.Is(err, toolexec.ErrShellBusy):
+			// An earlier command is still running in the shell as a task,
+			// so this one runs on its own below.
+		case err != nil:
+			return nil, fmt.Errorf("tool: BASH failed (route=%s): %w", decision.Route, err)
+		default:
+			return t.waitShellCommand(ctx, shellSession, &yielded, shellCommandRun{
+				command: command,
+				workdir: workingDir,
+				yield:   time.Duration(yieldMS) * time.Millisecond,
+				route:   decision.Route,
+				backend: decision.Backend,
+				denials: denials,
+			})
+		}
+	}
 	manager, ok := task.ManagerFromContext(ctx)
 	if !ok || manager == nil {
 		sessionRef, err := t.runtime.Start(ctx, toolexec.CommandRequest{
@@ -181,6 +245,8 @@ func (t *BashTool) Run(ctx context.Context, args map[string]any) (map[string]any
 			OnOutput: func(chunk toolexec.CommandOutputChunk) {
 				toolexec.EmitOutputChunk(ctx, chunk)
 			},
+			OnNetworkDenied: denials.add,
+			Limits:          limits,
 		})
 		if err != nil {
 			return nil, fmt.Errorf("tool: BASH failed (route=%s): %w", decision.Route, err)
@@ -189,17 +255,16 @@ func (t *BashTool) Run(ctx context.Context, args map[string]any) (map[string]any
 		if waitErr != nil {
 			return nil, fmt.Errorf("tool: task manager is unavailable")
 		}
-		return ktoolSnapshotResult(task.Snapshot{
+		result := ktoolSnapshotResult(task.Snapshot{
 			Kind:  task.KindBash,
 			State: task.StateCompleted,
 			Output: task.Output{
 				Stdout: waited.Stdout,
 				Stderr: waited.Stderr,
 			},
-			Result: map[string]any{
-				"exit_code": waited.ExitCode,
-			},
-		}, string(decision.Route)), nil
+			Result: bashExitResult(waited),
+		}, string(decision.Route))
+		return t.reviewNetworkDenials(ctx, result, denials.list())
 	}
 	snapshot, err := manager.StartBash(ctx, task.BashStartRequest{
 		Command:     command,
@@ -209,12 +274,95 @@ func (t *BashTool) Run(ctx context.Context, args map[string]any) (map[string]any
 		IdleTimeout: idleTimeout,
 		Route:       string(decision.Route),
 		Backend:     decision.Backend,
+
+		OnNetworkDenied: denials.add,
+		Limits:          limits,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("tool: BASH failed (route=%s): %w", decision.Route, err)
 	}
 	result := ktoolSnapshotResult(snapshot, string(decision.Route))
-	return ktoolAppendTaskEvents(result, snapshot), nil
+	return t.reviewNetworkDenials(ctx, ktoolAppendTaskEvents(result, snapshot), denials.list())
+}
+
+// shellCommandRun describes a BASH call running in a persistent shell.
+type shellCommandRun struct {
+	command string
+	workdir string
+	yield   time.Duration
+	route   toolexec.ExecutionRoute
+	backend string
+	denials *networkDenials
+}
+
+// waitShellCommand waits up to the call's yield for a command started in a
+// persistent shell. A command that is still running then becomes a BASH
+// task, like any other command that outlives its yield, and keeps the shell
+// until it ends. Without a task manager the call waits for it instead.
+func (t *BashTool) waitShellCommand(ctx context.Context, session toolexec.ShellSession, yielded *atomic.Bool, run shellCommandRun) (map[string]any, error) {
+	manager, hasManager := task.ManagerFromContext(ctx)
+	hasManager = hasManager && manager != nil
+	wait := time.Duration(0)
+	if hasManager {
+		wait = run.yield
+	}
+	if _, err := session.Wait(ctx, wait); err != nil {
+		if ctx.Err() != nil || !errors.Is(err, context.DeadlineExceeded) {
+			_ = session.Terminate()
+			return nil, fmt.Errorf("tool: BASH failed (route=%s): %w", run.route, err)
+		}
+		yielded.Store(true)
+		snapshot, err := manager.StartBash(ctx, task.BashStartRequest{
+			Command: run.command,
+			Workdir: run.workdir,
+			Route:   string(run.route),
+			Backend: run.backend,
+			Session: session,
+
+			NetworkDeniedHosts: run.denials.list,
+		})
+		if err != nil {
+			_ = session.Terminate()
+			return nil, fmt.Errorf("tool: BASH failed (route=%s): %w", run.route, err)
+		}
+		result := ktoolSnapshotResult(snapshot, string(run.route))
+		return t.reviewNetworkDenials(ctx, ktoolAppendTaskEvents(result, snapshot), run.denials.list())
+	}
+	shellResult, err := session.ShellResult()
+	if err != nil {
+		return nil, fmt.Errorf("tool: BASH failed (route=%s): %w", run.route, err)
+	}
+	result := ktoolSnapshotResult(task.Snapshot{
+		Kind:  task.KindBash,
+		State: task.StateCompleted,
+		Output: task.Output{
+			Stdout: shellResult.Stdout,
+			Stderr: shellResult.Stderr,
+		},
+		Result: bashExitResult(shellResult.CommandResult),
+	}, string(run.route))
+	if shellResult.Cwd != "" {
+		result["cwd"] = shellResult.Cwd
+	}
+	if shellResult.Restarted {
+		result["shell_restarted"] = true
+	}
+	return t.reviewNetworkDenials(ctx, result, run.denials.list())
+}
+
+// persistentShell returns the runtime's persistent shell runner and the
+// agent session it is keyed by, when the runtime has persistent shells
+// enabled and the call runs inside a session.
+func (t *BashTool) persistentShell(ctx context.Context) (toolexec.PersistentShellRunner, string, bool) {
+	runner, ok := t.runtime.(toolexec.PersistentShellRunner)
+	if !ok || !runner.PersistentShellEnabled() {
+		return nil, "", false
+	}
+	stateCtx, ok := session.StateContextFromContext(ctx)
+	if !ok || strings.TrimSpace(stateCtx.Session.ID) == "" {
+		return nil, "", false
+	}
+	return runner, stateCtx.Session.ID, true
 }
 
 func (t *BashTool) WithRuntime(runtime toolexec.Runtime) (*BashTool, error) {
@@ -329,7 +477,7 @@ func appendSnapshotMetaFields(result map[string]any, snapshot task.Snapshot) {
 	if value, ok := snapshot.Result["exit_code"]; ok && value != nil {
 		result["exit_code"] = value
 	}
-	for _, key := range []string{"session_id", "route", "backend"} {
+	for _, key := range []string{"session_id", "route", "backend", "limit_exceeded"} {
 		if value, ok := snapshot.Result[key]; ok && value != nil && strings.TrimSpace(fmt.Sprint(value)) != "" {
 			result[key] = value
 		}
@@ -369,6 +517,9 @@ func (t *BashTool) resolveCommandDecision(
 						Message: strings.TrimSpace(decision.Reason),
 					}
 					out.NeedApproval = true
+				} else if policy.DecisionHasAnnotation(decision, policy.DecisionAnnotationHostExecutionWithoutApproval) {
+					out.Escalation = nil
+					out.NeedApproval = false
 				}
 				return out, decision, nil
 			}
@@ -388,6 +539,37 @@ func (t *BashTool) resolveCommandDecision(
 	return t.runtime.DecideRoute(command, sandboxPermission), policy.Decision{}, nil
 }
 
+// parseResourceLimitArgs reads the per-call resource caps.
+func parseResourceLimitArgs(args map[string]any) (toolexec.ResourceLimits, error) {
+	var values [4]int
+	for i, key := range []string{"max_cpu_seconds", "max_memory_mb", "max_processes", "max_output_bytes"} {
+		value, err := argparse.Int(args, key, 0)
+		if err != nil {
+			return toolexec.ResourceLimits{}, err
+		}
+		if value < 0 {
+			return toolexec.ResourceLimits{}, fmt.Errorf("tool: arg %q must not be negative", key)
+		}
+		values[i] = value
+	}
+	return toolexec.ResourceLimits{
+		CPUTime:        time.Duration(values[0]) * time.Second,
+		MemoryBytes:    int64(values[1]) << 20,
+		MaxProcesses:   values[2],
+		MaxOutputBytes: int64(values[3]),
+	}, nil
+}
+
+// bashExitResult is the result map for a command that ran without the task
+// manager.
+func bashExitResult(result toolexec.CommandResult) map[string]any {
+	out := map[string]any{"exit_code": result.ExitCode}
+	if result.LimitExceeded != "" {
+		out["limit_exceeded"] = result.LimitExceeded
+	}
+	return out
+}
+
 func parseSandboxPermissionArgs(args map[string]any) (toolexec.SandboxPermission, error) {
 	requireEscalated, err := argparse.Bool(args, "require_escalated", false)
 	if err != nil {
@@ -414,27 +596,27 @@ func needsRuntimeApproval(runtime toolexec.Runtime, decision toolexec.CommandDec
 }
 
 func hostBackendName(runtime toolexec.Runtime, fallback string) string {
-	return backendNameByKind(runtime, toolexec.BackendKindHost, fallback, "host")
+	return backendNameByKind(runtime, fallback, "host", toolexec.BackendKindHost, toolexec.BackendKindSSH)
 }
 
 func sandboxBackendName(runtime toolexec.Runtime, fallback string) string {
-	return backendNameByKind(runtime, toolexec.BackendKindSandbox, fallback, "sandbox")
+	return backendNameByKind(runtime, fallback, "sandbox", toolexec.BackendKindSandbox)
 }
 
-func backendNameByKind(runtime toolexec.Runtime, kind toolexec.BackendKind, fallback string, defaultName string) string {
+func backendNameByKind(runtime toolexec.Runtime, fallback string, defaultName string, kinds ...toolexec.BackendKind) string {
 	if name := strings.TrimSpace(fallback); name != "" {
 		if runtime == nil {
 			return name
 		}
 		for _, backend := range runtime.State().Backends {
-			if strings.EqualFold(backend.Name, name) && backend.Kind == kind {
+			if strings.EqualFold(backend.Name, name) && slices.Contains(kinds, backend.Kind) {
 				return name
 			}
 		}
 	}
 	if runtime != nil {
 		for _, backend := range runtime.State().Backends {
-			if backend.Kind == kind && strings.TrimSpace(backend.Name) != "" {
+			if slices.Contains(kinds, backend.Kind) && strings.TrimSpace(backend.Name) != "" {
 				return backend.Name
 			}
 		}
@@ -465,3 +647,63 @@ func requestApproval(ctx context.Context, command string, reason string) error {
 	}
 	return nil
 }
+
+// networkDenials collects the hosts the sandbox network proxy refused while a
+// command ran.
+type networkDenials struct {
+	mu    sync.Mutex
+	hosts []string
+}
+
+func (d *networkDenials) add(host string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, existing := range d.hosts {
+		if existing == host {
+			return
+		}
+	}
+	d.hosts = append(d.hosts, host)
+}
+
+func (d *networkDenials) list() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.hosts...)
+}
+
+// reviewNetworkDenials asks once per command whether hosts the sandbox proxy
+// refused may be reached for the rest of the session. Approved hosts join the
+// runtime allowlist so the model can rerun the command; otherwise, or when
+// the approval itself fails, the result lists them as network_denied_hosts.
+// Hosts refused after a command became a task show up on its TASK snapshots.
+func (t *BashTool) reviewNetworkDenials(ctx context.Context, result map[string]any, hosts []string) (map[string]any, error) {
+	if len(hosts) == 0 {
+		return result, nil
+	}
+	updater, canUpdate := t.runtime.(toolexec.NetworkAllowlistUpdater)
+	approver, canAsk := toolexec.ApproverFromContext(ctx)
+	if !canUpdate || !canAsk {
+		result["network_denied_hosts"] = hosts
+		return result, nil
+	}
+	joined := strings.Join(hosts, ", ")
+	allowed, err := approver.Approve(ctx, toolexec.ApprovalRequest{
+		ToolName: BashToolName,
+		Action:   "allow_network",
+		Reason:   "sandbox network proxy blocked " + joined + "; allow for this session",
+		Command:  "network access: " + joined,
+	})
+	if err != nil {
+		result["network_denied_hosts"] = hosts
+		result["network_approval_error"] = err.Error()
+		return result, nil
+	}
+	if !allowed {
+		result["network_denied_hosts"] = hosts
+		return result, nil
+	}
+	updater.AllowNetworkHosts(hosts...)
+	result["network_allowed_hosts"] = hosts
+	return result, nil
+}
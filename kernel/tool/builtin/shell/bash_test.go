@@ -13,6 +13,8 @@ import (
 
 	toolexec "github.com/OnslaughtSnail/caelis/kernel/execenv"
 	"github.com/OnslaughtSnail/caelis/kernel/policy"
+	"github.com/OnslaughtSnail/caelis/kernel/session"
+	"github.com/OnslaughtSnail/caelis/kernel/session/inmemory"
 	"github.com/OnslaughtSnail/caelis/kernel/task"
 )
 
@@ -1346,3 +1348,259 @@ func TestBash_ACPXWrappedByEnvStillRequiresHostEscalation(t *testing.T) {
 		t.Fatalf("expected sandbox runner not called, got %d calls", len(sandbox.calls))
 	}
 }
+
+type recordingApprover struct {
+	allow    bool
+	err      error
+	requests []toolexec.ApprovalRequest
+}
+
+func (a *recordingApprover) Approve(_ context.Context, req toolexec.ApprovalRequest) (bool, error) {
+	a.requests = append(a.requests, req)
+	return a.allow, a.err
+}
+
+func TestBash_ApprovedNetworkDenialWidensAllowlist(t *testing.T) {
+	sandbox := &recordingRunner{
+		result: toolexec.CommandResult{Stdout: "403"},
+		onRun: func(req toolexec.CommandRequest) {
+			if req.OnNetworkDenied != nil {
+				req.OnNetworkDenied("proxy.golang.org")
+				req.OnNetworkDenied("proxy.golang.org")
+			}
+		},
+	}
+	rt, err := toolexec.New(toolexec.Config{
+		PermissionMode: toolexec.PermissionModeDefault,
+		HostRunner:     &recordingRunner{},
+		SandboxRunner:  sandbox,
+		SandboxType:    testSandboxType(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tool, err := NewBash(BashConfig{Runtime: rt})
+	if err != nil {
+		t.Fatal(err)
+	}
+	approver := &recordingApprover{allow: true}
+	out, err := tool.Run(toolexec.WithApprover(context.Background(), approver), map[string]any{"command": "go mod download"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(approver.requests) != 1 || approver.requests[0].Action != "allow_network" {
+		t.Fatalf("expected one allow_network approval, got %+v", approver.requests)
+	}
+	if got, _ := out["network_allowed_hosts"].([]string); len(got) != 1 || got[0] != "proxy.golang.org" {
+		t.Fatalf("expected the approved host in the result, got %#v", out)
+	}
+	policy := rt.(interface{ SandboxPolicy() toolexec.SandboxPolicy }).SandboxPolicy()
+	if len(policy.NetworkAllowlist) != 1 || policy.NetworkAllowlist[0] != "proxy.golang.org" {
+		t.Fatalf("expected the runtime allowlist to include the host, got %v", policy.NetworkAllowlist)
+	}
+}
+
+func TestBash_RefusedNetworkDenialIsReported(t *testing.T) {
+	sandbox := &recordingRunner{
+		onRun: func(req toolexec.CommandRequest) {
+			if req.OnNetworkDenied != nil {
+				req.OnNetworkDenied("example.com")
+			}
+		},
+	}
+	rt, err := toolexec.New(toolexec.Config{
+		PermissionMode: toolexec.PermissionModeDefault,
+		HostRunner:     &recordingRunner{},
+		SandboxRunner:  sandbox,
+		SandboxType:    testSandboxType(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tool, err := NewBash(BashConfig{Runtime: rt})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := tool.Run(toolexec.WithApprover(context.Background(), &recordingApprover{}), map[string]any{"command": "curl https://example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := out["network_denied_hosts"].([]string); len(got) != 1 || got[0] != "example.com" {
+		t.Fatalf("expected the denied host in the result, got %#v", out)
+	}
+	policy := rt.(interface{ SandboxPolicy() toolexec.SandboxPolicy }).SandboxPolicy()
+	if len(policy.NetworkAllowlist) != 0 {
+		t.Fatalf("expected the allowlist unchanged, got %v", policy.NetworkAllowlist)
+	}
+}
+
+func TestBash_FailedNetworkApprovalKeepsTheOutput(t *testing.T) {
+	sandbox := &recordingRunner{
+		result: toolexec.CommandResult{Stdout: "403"},
+		onRun: func(req toolexec.CommandRequest) {
+			if req.OnNetworkDenied != nil {
+				req.OnNetworkDenied("example.com")
+			}
+		},
+	}
+	rt, err := toolexec.New(toolexec.Config{
+		PermissionMode: toolexec.PermissionModeDefault,
+		HostRunner:     &recordingRunner{},
+		SandboxRunner:  sandbox,
+		SandboxType:    testSandboxType(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tool, err := NewBash(BashConfig{Runtime: rt})
+	if err != nil {
+		t.Fatal(err)
+	}
+	approver := &recordingApprover{err: errors.New("approval prompt closed")}
+	out, err := tool.Run(toolexec.WithApprover(context.Background(), approver), map[string]any{"command": "curl https://example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertBashOutput(t, out, "403")
+	if got, _ := out["network_denied_hosts"].([]string); len(got) != 1 || got[0] != "example.com" {
+		t.Fatalf("expected the denied host in the result, got %#v", out)
+	}
+	if out["network_approval_error"] != "approval prompt closed" {
+		t.Fatalf("expected the approval error in the result, got %#v", out)
+	}
+}
+
+func TestBash_ResourceLimitArgsTightenRuntimeDefaults(t *testing.T) {
+	sandbox := &recordingRunner{result: toolexec.CommandResult{Stdout: "ok"}}
+	rt, err := toolexec.New(toolexec.Config{
+		PermissionMode: toolexec.PermissionModeDefault,
+		HostRunner:     &recordingRunner{},
+		SandboxRunner:  sandbox,
+		SandboxType:    testSandboxType(),
+		ResourceLimits: toolexec.ResourceLimits{CPUTime: time.Minute, MemoryBytes: 1 << 30},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tool, err := NewBash(BashConfig{Runtime: rt})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tool.Run(context.Background(), map[string]any{
+		"command":          "make test",
+		"max_cpu_seconds":  300,
+		"max_memory_mb":    256,
+		"max_output_bytes": 4096,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(sandbox.calls) != 1 {
+		t.Fatalf("expected one sandbox call, got %d", len(sandbox.calls))
+	}
+	want := toolexec.ResourceLimits{CPUTime: time.Minute, MemoryBytes: 256 << 20, MaxOutputBytes: 4096}
+	if got := sandbox.calls[0].Limits; got != want {
+		t.Fatalf("expected limits %+v, got %+v", want, got)
+	}
+	if _, err := tool.Run(context.Background(), map[string]any{"command": "make test", "max_processes": -1}); err == nil {
+		t.Fatal("expected a negative limit to be rejected")
+	}
+}
+
+func TestBash_PersistentShellKeepsWorkingDirectory(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	rt, err := toolexec.New(toolexec.Config{
+		PermissionMode:  toolexec.PermissionModeFullControl,
+		SandboxRunner:   &recordingRunner{},
+		PersistentShell: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if closer, ok := rt.(interface{ Close() error }); ok {
+			_ = closer.Close()
+		}
+	}()
+	tool, err := NewBash(BashConfig{Runtime: rt})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	ctx := session.WithStateContext(context.Background(), &session.Session{ID: "session-1"}, inmemory.New())
+
+	if _, err := tool.Run(ctx, map[string]any{"command": "cd " + dir + " && export CAELIS_BASH_TEST=kept"}); err != nil {
+		t.Fatal(err)
+	}
+	out, err := tool.Run(ctx, map[string]any{"command": "echo \"$CAELIS_BASH_TEST\""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertBashOutput(t, out, "kept\n")
+	if out["cwd"] != dir {
+		t.Fatalf("expected cwd %q, got %#v", dir, out)
+	}
+	if _, ok := out["shell_restarted"]; ok {
+		t.Fatalf("expected the same shell, got %#v", out)
+	}
+}
+
+func TestBash_PersistentShellYieldsLongCommandsToTasks(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	rt, err := toolexec.New(toolexec.Config{
+		PermissionMode:  toolexec.PermissionModeFullControl,
+		SandboxRunner:   &recordingRunner{},
+		PersistentShell: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if closer, ok := rt.(interface{ Close() error }); ok {
+			_ = closer.Close()
+		}
+	}()
+	tool, err := NewBash(BashConfig{Runtime: rt})
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager := &stubTaskManager{startBash: task.Snapshot{TaskID: "t-bash-1", Kind: task.KindBash, State: task.StateRunning, Running: true}}
+	ctx := session.WithStateContext(context.Background(), &session.Session{ID: "session-1"}, inmemory.New())
+	ctx = task.WithManager(ctx, manager)
+
+	out, err := tool.Run(ctx, map[string]any{"command": "sleep 1; echo late", "yield_time_ms": 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out["task_id"] != "t-bash-1" {
+		t.Fatalf("expected the command to become a task, got %#v", out)
+	}
+	shellSession := manager.lastBash.Session
+	if shellSession == nil || manager.lastBash.Command != "sleep 1; echo late" {
+		t.Fatalf("expected the running shell command to be handed to the task, got %+v", manager.lastBash)
+	}
+	if manager.lastBash.NetworkDeniedHosts == nil {
+		t.Fatal("expected the task to keep collecting the shell command's network denials")
+	}
+
+	busy, err := tool.Run(ctx, map[string]any{"command": "echo meanwhile"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := busy["cwd"]; ok {
+		t.Fatalf("expected a command to run outside the busy shell, got %#v", busy)
+	}
+	if _, err := shellSession.Wait(context.Background(), 0); err != nil {
+		t.Fatal(err)
+	}
+	stdout, _, _, _, _ := shellSession.ReadOutput(0, 0)
+	if string(stdout) != "late\n" {
+		t.Fatalf("expected the task to see the command's output, got %q", stdout)
+	}
+	after, err := tool.Run(ctx, map[string]any{"command": "echo back"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := after["cwd"]; !ok {
+		t.Fatalf("expected the shell to be free again, got %#v", after)
+	}
+}
@@ -38,6 +38,12 @@ type Provider interface {
 	Capability() Capability
 }
 
+// CallProvider allows a value to declare a different capability for one
+// call, when some arguments do more than the tool usually does.
+type CallProvider interface {
+	CallCapability(args map[string]any) Capability
+}
+
 // Of returns declared capability, or a default unknown profile.
 func Of(value any) Capability {
 	if value == nil {
@@ -47,7 +53,19 @@ func Of(value any) Capability {
 	if !ok {
 		return Capability{Risk: RiskUnknown}
 	}
-	declared := withCap.Capability()
+	return normalize(withCap.Capability())
+}
+
+// OfCall returns the capability value declares for a call with args,
+// falling back to Of.
+func OfCall(value any, args map[string]any) Capability {
+	if withCap, ok := value.(CallProvider); ok {
+		return normalize(withCap.CallCapability(args))
+	}
+	return Of(value)
+}
+
+func normalize(declared Capability) Capability {
 	if declared.Risk == "" {
 		declared.Risk = RiskUnknown
 	}
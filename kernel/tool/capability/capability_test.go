@@ -11,6 +11,28 @@ func (capabilityValue) Capability() Capability {
 	}
 }
 
+type callCapabilityValue struct{ capabilityValue }
+
+func (callCapabilityValue) CallCapability(args map[string]any) Capability {
+	if args["write"] == true {
+		return Capability{Operations: []Operation{OperationFileWrite, OperationFileWrite}}
+	}
+	return Capability{Risk: RiskLow}
+}
+
+func TestOfCall_PrefersCallCapability(t *testing.T) {
+	got := OfCall(callCapabilityValue{}, map[string]any{"write": true})
+	if !got.HasOperation(OperationFileWrite) || len(got.Operations) != 1 || got.Risk != RiskUnknown {
+		t.Fatalf("expected the normalized call capability, got %#v", got)
+	}
+	if got := OfCall(callCapabilityValue{}, nil); got.Risk != RiskLow || len(got.Operations) != 0 {
+		t.Fatalf("expected the low-risk call capability, got %#v", got)
+	}
+	if got := OfCall(capabilityValue{}, nil); got.Risk != RiskMedium {
+		t.Fatalf("expected OfCall to fall back to Of, got %#v", got)
+	}
+}
+
 func TestOf_DefaultUnknown(t *testing.T) {
 	got := Of(nil)
 	if got.Risk != RiskUnknown {
@@ -106,7 +106,7 @@ func SnapshotResultMap(snapshot task.Snapshot) map[string]any {
 	appendBashIdentityFields(result, snapshot)
 	appendSpawnIdentityFields(result, snapshot)
 	appendSnapshotStateFields(result, snapshot)
-	if taskID := strings.TrimSpace(snapshot.TaskID); taskID != "" && snapshotIsActive(snapshot) {
+	if taskID := strings.TrimSpace(snapshot.TaskID); taskID != "" && (snapshotIsActive(snapshot) || snapshotHasPendingWorktree(snapshot)) {
 		result["task_id"] = taskID
 	}
 	switch {
@@ -115,7 +115,7 @@ func SnapshotResultMap(snapshot task.Snapshot) map[string]any {
 			result["msg"] = msg
 		}
 	case snapshotIsSubagent(snapshot):
-		if snapshot.State == task.StateCompleted {
+		if snapshot.State == task.StateCompleted || snapshot.State == task.StateTerminated {
 			if value := snapshotFinalResult(snapshot); value != "" {
 				result["output"] = value
 			}
@@ -123,16 +123,27 @@ func SnapshotResultMap(snapshot task.Snapshot) map[string]any {
 		if text := firstNonEmptyText(fmt.Sprint(snapshot.Result["error"])); text != "" {
 			result["error"] = text
 		}
+		if snapshot.State == task.StateTerminated {
+			if reason := firstNonEmptyText(fmt.Sprint(snapshot.Result["error_reason"])); reason != "" {
+				result["error_reason"] = reason
+			}
+		}
 	default:
 		appendSnapshotTerminalFields(result, snapshot)
 		if value, ok := snapshot.Result["exit_code"]; ok && value != nil {
 			result["exit_code"] = value
 		}
+		if value, ok := snapshot.Result["limit_exceeded"].(string); ok && value != "" {
+			result["limit_exceeded"] = value
+		}
 		appendSnapshotTruncationMessage(result, snapshot)
 		if text := firstNonEmptyText(fmt.Sprint(snapshot.Result["error"])); text != "" {
 			result["error"] = text
 		}
 	}
+	if value, ok := snapshot.Result["network_denied_hosts"]; ok && value != nil && snapshot.Kind == task.KindBash {
+		result["network_denied_hosts"] = value
+	}
 	return result
 }
 
@@ -140,7 +151,11 @@ func appendSpawnIdentityFields(result map[string]any, snapshot task.Snapshot) {
 	if result == nil || !snapshotIsSubagent(snapshot) || len(snapshot.Result) == 0 {
 		return
 	}
-	for _, key := range []string{"child_session_id", "delegation_id", "agent", "child_cwd"} {
+	for _, key := range []string{
+		"child_session_id", "delegation_id", "agent", "child_cwd",
+		"isolation", "worktree_path", "worktree_branch", "worktree_status",
+		"worktree_diff_stat", "worktree_diff", "worktree_diff_truncated",
+	} {
 		value, ok := snapshot.Result[key]
 		if !ok || value == nil || strings.TrimSpace(fmt.Sprint(value)) == "" {
 			continue
@@ -149,6 +164,12 @@ func appendSpawnIdentityFields(result map[string]any, snapshot task.Snapshot) {
 	}
 }
 
+// snapshotHasPendingWorktree reports whether a stopped SPAWN task still has
+// worktree changes waiting for TASK apply or discard.
+func snapshotHasPendingWorktree(snapshot task.Snapshot) bool {
+	return snapshotIsSubagent(snapshot) && strings.TrimSpace(fmt.Sprint(snapshot.Result["worktree_status"])) == "pending_review"
+}
+
 func appendBashIdentityFields(result map[string]any, snapshot task.Snapshot) {
 	if result == nil || snapshot.Kind != task.KindBash || len(snapshot.Result) == 0 {
 		return
@@ -167,7 +188,7 @@ func snapshotIsActive(snapshot task.Snapshot) bool {
 		return true
 	}
 	switch snapshot.State {
-	case task.StateRunning, task.StateWaitingApproval, task.StateWaitingInput:
+	case task.StateRunning, task.StateWaitingApproval, task.StateWaitingInput, task.StateQueued:
 		return true
 	default:
 		return false
@@ -230,11 +251,37 @@ func snapshotActiveMessage(snapshot task.Snapshot) string {
 			return "subagent is still running"
 		}
 		return "bash is still running"
+	case task.StateQueued:
+		return "subagent is queued until another child in its batch stops"
 	default:
 		return ""
 	}
 }
 
+// BatchSnapshotResult reports several tasks at once for SPAWN fan-out and
+// TASK wait_any or wait_all. Every entry keeps its task_id so results can be
+// matched to the children that produced them.
+func BatchSnapshotResult(snapshots []task.Snapshot) map[string]any {
+	result := map[string]any{}
+	items := make([]map[string]any, 0, len(snapshots))
+	pending := 0
+	for _, snapshot := range snapshots {
+		item := SnapshotResultMap(snapshot)
+		if taskID := strings.TrimSpace(snapshot.TaskID); taskID != "" {
+			item["task_id"] = taskID
+		}
+		if snapshotIsActive(snapshot) {
+			pending++
+		}
+		items = append(items, item)
+		result = AppendTaskSnapshotEvents(result, snapshot)
+	}
+	result["tasks"] = items
+	result["finished"] = len(snapshots) - pending
+	result["pending"] = pending
+	return result
+}
+
 func PublicTaskEvents(snapshot task.Snapshot) []map[string]any {
 	events := collectPublicTaskEvents(snapshot)
 	if len(events) == 0 {
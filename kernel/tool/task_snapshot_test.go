@@ -272,3 +272,18 @@ func resultString(value any) string {
 	}
 	return ""
 }
+
+func TestSnapshotResultMap_BashReportsNetworkDeniedHosts(t *testing.T) {
+	result := SnapshotResultMap(task.Snapshot{
+		TaskID:  "t-1",
+		Kind:    task.KindBash,
+		State:   task.StateRunning,
+		Running: true,
+		Result: map[string]any{
+			"network_denied_hosts": []string{"proxy.golang.org"},
+		},
+	})
+	if got, _ := result["network_denied_hosts"].([]string); len(got) != 1 || got[0] != "proxy.golang.org" {
+		t.Fatalf("expected the denied host on a running task, got %#v", result)
+	}
+}
@@ -27,7 +27,7 @@ func (t *taskTool) Name() string {
 }
 
 func (t *taskTool) Description() string {
-	return "Control async tasks from BASH or SPAWN. state is the task lifecycle status. Use wait to check progress on a running task. Use write to send stdin to a running BASH task, or to start a new follow-up turn on a completed SPAWN child session; you can do that multiple times, and each write makes the spawn task running again until it yields or completes. Use cancel to stop a task, and list to inspect recent tasks."
+	return "Control async tasks from BASH or SPAWN. state is the task lifecycle status. Use wait to check progress on a running task. Use write to send stdin to a running BASH task, or to start a new follow-up turn on a completed SPAWN child session; you can do that multiple times, and each write makes the spawn task running again until it yields or completes. Use cancel to stop a task, and list to inspect recent tasks. Use wait_any or wait_all with task_ids to wait on several tasks at once, such as the children of a batch SPAWN; wait_any returns as soon as one of them stops. For a SPAWN child started with isolation=worktree, use diff to review its changes, then apply to merge them into the working tree or discard to drop them; both require the child to have stopped, and apply is refused in plan and staged modes."
 }
 
 func (t *taskTool) Declaration() model.ToolDefinition {
@@ -39,20 +39,25 @@ func (t *taskTool) Declaration() model.ToolDefinition {
 			"properties": map[string]any{
 				"action": map[string]any{
 					"type":        "string",
-					"enum":        []string{"wait", "write", "cancel", "list"},
+					"enum":        []string{"wait", "wait_any", "wait_all", "write", "cancel", "list", "diff", "apply", "discard"},
 					"description": "Task control action.",
 				},
 				"task_id": map[string]any{
 					"type":        "string",
 					"description": "Task handle from an earlier async tool call.",
 				},
+				"task_ids": map[string]any{
+					"type":        "array",
+					"items":       map[string]any{"type": "string"},
+					"description": "For action=wait_any or wait_all: the task handles to wait on.",
+				},
 				"input": map[string]any{
 					"type":        "string",
 					"description": "For action=write: send stdin to a running BASH task, or send a new follow-up prompt to a completed SPAWN task. SPAWN write is not valid while the child is still running; use TASK wait until it completes first.",
 				},
 				"yield_time_ms": map[string]any{
 					"type":        "integer",
-					"description": "For action=wait or write: optional per-call wait before returning. Defaults to 5000. If the task is still active when this wait expires, the result keeps task_id so TASK can be called again.",
+					"description": "For action=wait, wait_any, wait_all or write: optional per-call wait before returning. Defaults to 5000. If the task is still active when this wait expires, the result keeps task_id so TASK can be called again.",
 				},
 				"offset": map[string]any{
 					"type":        "integer",
@@ -73,6 +78,18 @@ func (t *taskTool) Capability() capability.Capability {
 	return capability.Capability{Risk: capability.RiskLow}
 }
 
+// CallCapability marks apply as a file write: it merges a worktree's
+// changes into the working tree, so write policy applies to it.
+func (t *taskTool) CallCapability(args map[string]any) capability.Capability {
+	if strings.TrimSpace(asStringArg(args, "action")) == string(task.WorktreeActionApply) {
+		return capability.Capability{
+			Operations: []capability.Operation{capability.OperationFileWrite},
+			Risk:       capability.RiskMedium,
+		}
+	}
+	return t.Capability()
+}
+
 func (t *taskTool) Run(ctx context.Context, args map[string]any) (map[string]any, error) {
 	manager, ok := task.ManagerFromContext(ctx)
 	if !ok || manager == nil {
@@ -91,7 +108,7 @@ func (t *taskTool) Run(ctx context.Context, args map[string]any) (map[string]any
 	rawYield, yieldSpecified := args["yield_time_ms"]
 	yieldSpecified = yieldSpecified && rawYield != nil
 	yieldMS := asIntArg(args, "yield_time_ms")
-	if (action == "wait" || action == "write") && (!yieldSpecified || yieldMS <= 0) {
+	if (action == "wait" || action == "wait_any" || action == "wait_all" || action == "write") && (!yieldSpecified || yieldMS <= 0) {
 		yieldMS = int(defaultTaskWait / time.Millisecond)
 	}
 	if yieldMS < 0 {
@@ -109,6 +126,28 @@ func (t *taskTool) Run(ctx context.Context, args map[string]any) (map[string]any
 			return nil, err
 		}
 		return taskSnapshotResult(snapshot, true), nil
+	case "wait_any", "wait_all":
+		taskIDs := asStringListArg(args, "task_ids")
+		if len(taskIDs) == 0 {
+			return nil, fmt.Errorf("tool: arg %q is required", "task_ids")
+		}
+		batches, ok := manager.(task.BatchManager)
+		if !ok {
+			return nil, fmt.Errorf("tool: waiting on several tasks is unavailable")
+		}
+		mode := task.WaitAll
+		if action == "wait_any" {
+			mode = task.WaitAny
+		}
+		snapshots, err := batches.WaitTasks(ctx, task.WaitTasksRequest{
+			TaskIDs: taskIDs,
+			Mode:    mode,
+			Yield:   req.Yield,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return BatchSnapshotResult(snapshots), nil
 	case "write":
 		if req.TaskID == "" {
 			return nil, fmt.Errorf("tool: arg %q is required", "task_id")
@@ -127,6 +166,22 @@ func (t *taskTool) Run(ctx context.Context, args map[string]any) (map[string]any
 			return nil, err
 		}
 		return taskSnapshotResult(snapshot, false), nil
+	case "diff", "apply", "discard":
+		if req.TaskID == "" {
+			return nil, fmt.Errorf("tool: arg %q is required", "task_id")
+		}
+		worktrees, ok := manager.(task.WorktreeManager)
+		if !ok {
+			return nil, fmt.Errorf("tool: worktree isolation is unavailable")
+		}
+		snapshot, err := worktrees.ResolveWorktree(ctx, task.WorktreeRequest{
+			TaskID: req.TaskID,
+			Action: task.WorktreeAction(action),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return SnapshotResultMap(snapshot), nil
 	case "list":
 		items, err := manager.List(ctx)
 		if err != nil {
@@ -7,6 +7,7 @@ import (
 
 	"github.com/OnslaughtSnail/caelis/kernel/task"
 	"github.com/OnslaughtSnail/caelis/kernel/taskstream"
+	"github.com/OnslaughtSnail/caelis/kernel/tool/capability"
 )
 
 func TestTaskSnapshotResult_RunningSpawnOmitsPublicEvents(t *testing.T) {
@@ -174,3 +175,16 @@ func taskToolString(value any) string {
 	text, _ := value.(string)
 	return text
 }
+
+func TestTaskTool_ApplyDeclaresFileWrite(t *testing.T) {
+	tool, err := NewTaskTool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := capability.OfCall(tool, map[string]any{"action": "apply", "task_id": "t-1"}); !got.HasOperation(capability.OperationFileWrite) {
+		t.Fatalf("expected apply to declare a file write, got %#v", got)
+	}
+	if got := capability.OfCall(tool, map[string]any{"action": "wait"}); got.HasOperation(capability.OperationFileWrite) || got.Risk != capability.RiskLow {
+		t.Fatalf("expected wait to stay low risk, got %#v", got)
+	}
+}
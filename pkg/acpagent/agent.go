@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"iter"
+	"net/url"
 	"strings"
 	"sync"
 	"time"
@@ -321,6 +322,29 @@ func marshalPromptParts(parts []model.ContentPart) []json.RawMessage {
 				Data:     part.Data,
 				Name:     strings.TrimSpace(part.FileName),
 			}))
+		case model.ContentPartAudio:
+			if strings.TrimSpace(part.Data) == "" {
+				continue
+			}
+			out = append(out, mustMarshalRaw(acpclient.AudioContent{
+				Type:     "audio",
+				MimeType: strings.TrimSpace(part.MimeType),
+				Data:     part.Data,
+			}))
+		case model.ContentPartDocument:
+			if strings.TrimSpace(part.Data) == "" {
+				continue
+			}
+			name := strings.TrimSpace(part.FileName)
+			out = append(out, mustMarshalRaw(acpclient.EmbeddedResource{
+				Type: "resource",
+				Resource: acpclient.EmbeddedResourceData{
+					URI:      "attachment:///" + url.PathEscape(name),
+					Name:     name,
+					Blob:     part.Data,
+					MimeType: strings.TrimSpace(part.MimeType),
+				},
+			}))
 		}
 	}
 	return out
@@ -5,14 +5,18 @@ import (
 	"fmt"
 	"maps"
 	"strings"
+	"time"
 
 	"github.com/OnslaughtSnail/caelis/kernel/session"
+	"github.com/OnslaughtSnail/caelis/kernel/task"
 )
 
 const (
 	metaKeyRoot           = "caelis"
 	metaKeyDelegatedChild = "delegatedChild"
 	metaKeyModelAlias     = "modelAlias"
+	metaKeyAgentProfile   = "agentProfile"
+	metaKeyAgentBudget    = "agentBudget"
 	stateKeyACP           = "acp"
 	stateKeyController    = "controller"
 	stateKeyMeta          = "meta"
@@ -20,6 +24,18 @@ const (
 	controllerKeySession  = "sessionId"
 )
 
+// AgentProfile is the subagent profile a delegated child session runs with.
+// It is stored in the child's session meta so a resumed child keeps the
+// profile it was spawned with.
+type AgentProfile struct {
+	ID              string
+	Prompt          string
+	Tools           []string
+	Model           string
+	ReasoningEffort string
+	ReadOnly        bool
+}
+
 type ControllerSession struct {
 	AgentID   string
 	SessionID string
@@ -99,6 +115,138 @@ func WithModelAlias(meta map[string]any, alias string) map[string]any {
 	return out
 }
 
+func AgentProfileFromMeta(meta map[string]any) (AgentProfile, bool) {
+	if len(meta) == 0 {
+		return AgentProfile{}, false
+	}
+	root, ok := meta[strings.TrimSpace(metaKeyRoot)].(map[string]any)
+	if !ok || len(root) == 0 {
+		return AgentProfile{}, false
+	}
+	raw, ok := root[metaKeyAgentProfile].(map[string]any)
+	if !ok || len(raw) == 0 {
+		return AgentProfile{}, false
+	}
+	profile := AgentProfile{
+		ID:              stringValue(raw["id"]),
+		Prompt:          stringValue(raw["prompt"]),
+		Model:           stringValue(raw["model"]),
+		ReasoningEffort: stringValue(raw["reasoningEffort"]),
+		ReadOnly:        metaBoolValue(raw["readOnly"]),
+	}
+	switch tools := raw["tools"].(type) {
+	case []string:
+		profile.Tools = append([]string(nil), tools...)
+	case []any:
+		for _, one := range tools {
+			if name := stringValue(one); name != "" {
+				profile.Tools = append(profile.Tools, name)
+			}
+		}
+	}
+	if profile.ID == "" {
+		return AgentProfile{}, false
+	}
+	return profile, true
+}
+
+func WithAgentProfile(meta map[string]any, profile AgentProfile) map[string]any {
+	out := CloneMeta(meta)
+	if strings.TrimSpace(profile.ID) == "" {
+		return out
+	}
+	if out == nil {
+		out = map[string]any{}
+	}
+	root, _ := out[metaKeyRoot].(map[string]any)
+	if root == nil {
+		root = map[string]any{}
+	}
+	raw := map[string]any{
+		"id":       strings.TrimSpace(profile.ID),
+		"readOnly": profile.ReadOnly,
+	}
+	if value := strings.TrimSpace(profile.Prompt); value != "" {
+		raw["prompt"] = value
+	}
+	if len(profile.Tools) > 0 {
+		raw["tools"] = append([]string(nil), profile.Tools...)
+	}
+	if value := strings.TrimSpace(profile.Model); value != "" {
+		raw["model"] = value
+	}
+	if value := strings.TrimSpace(profile.ReasoningEffort); value != "" {
+		raw["reasoningEffort"] = value
+	}
+	root[metaKeyAgentProfile] = raw
+	out[metaKeyRoot] = root
+	return out
+}
+
+// AgentBudgetFromMeta returns the budget a delegated child session runs
+// with, if its meta carries one.
+func AgentBudgetFromMeta(meta map[string]any) (task.Budget, bool) {
+	if len(meta) == 0 {
+		return task.Budget{}, false
+	}
+	root, ok := meta[strings.TrimSpace(metaKeyRoot)].(map[string]any)
+	if !ok || len(root) == 0 {
+		return task.Budget{}, false
+	}
+	raw, ok := root[metaKeyAgentBudget].(map[string]any)
+	if !ok || len(raw) == 0 {
+		return task.Budget{}, false
+	}
+	budget := task.Budget{
+		MaxSteps:     int(metaNumberValue(raw["maxSteps"])),
+		MaxTokens:    int(metaNumberValue(raw["maxTokens"])),
+		MaxToolCalls: int(metaNumberValue(raw["maxToolCalls"])),
+		MaxCostUSD:   metaNumberValue(raw["maxCostUsd"]),
+		MaxWallTime:  time.Duration(metaNumberValue(raw["maxWallTimeSeconds"])) * time.Second,
+	}
+	if budget.IsZero() || budget.Validate() != nil {
+		return task.Budget{}, false
+	}
+	return budget, true
+}
+
+// WithAgentBudget stamps a child budget onto meta. A zero budget removes it.
+func WithAgentBudget(meta map[string]any, budget task.Budget) map[string]any {
+	out := CloneMeta(meta)
+	root, _ := out[metaKeyRoot].(map[string]any)
+	if budget.IsZero() {
+		if root != nil {
+			delete(root, metaKeyAgentBudget)
+		}
+		return out
+	}
+	if out == nil {
+		out = map[string]any{}
+	}
+	if root == nil {
+		root = map[string]any{}
+	}
+	raw := map[string]any{}
+	if budget.MaxSteps > 0 {
+		raw["maxSteps"] = budget.MaxSteps
+	}
+	if budget.MaxTokens > 0 {
+		raw["maxTokens"] = budget.MaxTokens
+	}
+	if budget.MaxToolCalls > 0 {
+		raw["maxToolCalls"] = budget.MaxToolCalls
+	}
+	if budget.MaxCostUSD > 0 {
+		raw["maxCostUsd"] = budget.MaxCostUSD
+	}
+	if budget.MaxWallTime > 0 {
+		raw["maxWallTimeSeconds"] = int(budget.MaxWallTime / time.Second)
+	}
+	root[metaKeyAgentBudget] = raw
+	out[metaKeyRoot] = root
+	return out
+}
+
 func SessionMetaFromState(state map[string]any) map[string]any {
 	if len(state) == 0 {
 		return nil
@@ -248,6 +396,19 @@ func metaBoolValue(value any) bool {
 	}
 }
 
+func metaNumberValue(value any) float64 {
+	switch typed := value.(type) {
+	case int:
+		return float64(typed)
+	case int64:
+		return float64(typed)
+	case float64:
+		return typed
+	default:
+		return 0
+	}
+}
+
 func stringValue(value any) string {
 	if value == nil {
 		return ""
@@ -1,10 +1,13 @@
 package acpmeta
 
 import (
+	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/OnslaughtSnail/caelis/kernel/session"
 	sessioninmemory "github.com/OnslaughtSnail/caelis/kernel/session/inmemory"
+	"github.com/OnslaughtSnail/caelis/kernel/task"
 )
 
 func TestSessionMetaStateHelpers(t *testing.T) {
@@ -15,6 +18,60 @@ func TestSessionMetaStateHelpers(t *testing.T) {
 	}
 }
 
+func TestAgentProfileMetaRoundTripsThroughState(t *testing.T) {
+	meta := WithAgentProfile(WithModelAlias(nil, "sonnet"), AgentProfile{
+		ID:              "reviewer",
+		Prompt:          "Review strictly.",
+		Tools:           []string{"READ", "SEARCH"},
+		Model:           "opus",
+		ReasoningEffort: "high",
+		ReadOnly:        true,
+	})
+	raw, err := json.Marshal(StoreSessionMeta(nil, meta))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var state map[string]any
+	if err := json.Unmarshal(raw, &state); err != nil {
+		t.Fatal(err)
+	}
+	profile, ok := AgentProfileFromMeta(SessionMetaFromState(state))
+	if !ok || profile.ID != "reviewer" || profile.Model != "opus" || profile.ReasoningEffort != "high" || !profile.ReadOnly {
+		t.Fatalf("unexpected profile %+v", profile)
+	}
+	if len(profile.Tools) != 2 || profile.Tools[1] != "SEARCH" || profile.Prompt != "Review strictly." {
+		t.Fatalf("unexpected profile tools or prompt %+v", profile)
+	}
+	if got := ModelAlias(SessionMetaFromState(state)); got != "sonnet" {
+		t.Fatalf("expected model alias kept beside profile, got %q", got)
+	}
+}
+
+func TestAgentBudgetMetaRoundTripsThroughState(t *testing.T) {
+	meta := WithAgentBudget(WithModelAlias(nil, "sonnet"), task.Budget{
+		MaxSteps:    8,
+		MaxTokens:   20000,
+		MaxCostUSD:  0.5,
+		MaxWallTime: 10 * time.Minute,
+	})
+	raw, err := json.Marshal(StoreSessionMeta(nil, meta))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var state map[string]any
+	if err := json.Unmarshal(raw, &state); err != nil {
+		t.Fatal(err)
+	}
+	budget, ok := AgentBudgetFromMeta(SessionMetaFromState(state))
+	want := task.Budget{MaxSteps: 8, MaxTokens: 20000, MaxCostUSD: 0.5, MaxWallTime: 10 * time.Minute}
+	if !ok || budget != want {
+		t.Fatalf("unexpected budget %+v", budget)
+	}
+	if _, ok := AgentBudgetFromMeta(WithAgentBudget(meta, task.Budget{})); ok {
+		t.Fatal("expected a zero budget to remove the budget")
+	}
+}
+
 func TestUpdateSessionMetaPersistsThroughStore(t *testing.T) {
 	store := sessioninmemory.New()
 	sess := &session.Session{AppName: "app", UserID: "u", ID: "s"}